@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/demo"
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/utils"
+	"github.com/SteaceP/coderage/webhooks"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const createAdminUsage = `Usage:
+  api create-admin -email address [-username name] [-password password]
+      Create a verified admin account, with a random password unless one is
+      given, which is printed. An existing account of the address is made
+      admin instead, keeping its password.
+`
+
+// runCreateAdminCommand creates an admin account, or promotes the account
+// of the given email address, and returns the exit code.
+func runCreateAdminCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	email := flags.String("email", "", "email address of the account")
+	username := flags.String("username", "", "username of the account, the local part of the email address by default")
+	password := flags.String("password", "", "password of the account, random by default")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || *email == "" {
+		fmt.Fprint(stderr, createAdminUsage)
+		return 2
+	}
+
+	// Load configuration, and connect to the database
+	_, logger, db, err := bootstrap(false)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer logger.Sync()
+
+	// Promote the existing account
+	userRepo := repositories.NewUserRepository(db)
+	if user, err := userRepo.FindByEmail(*email); err == nil {
+		if err := userRepo.UpdateRole(user.ID, types.RoleAdmin); err != nil {
+			logger.Error("Failed to promote the account", zap.Error(err))
+			return 1
+		}
+		if user.VerifiedAt == nil {
+			if err := userRepo.VerifyUser(user.ID); err != nil {
+				logger.Error("Failed to verify the account", zap.Error(err))
+				return 1
+			}
+		}
+		fmt.Fprintf(stdout, "Made %s (%s) admin\n", user.Username, user.Email)
+		return 0
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Error("Failed to look the account up", zap.Error(err))
+		return 1
+	}
+
+	if *username == "" {
+		*username, _, _ = strings.Cut(*email, "@")
+	}
+	generated := *password == ""
+	if generated {
+		if *password, err = utils.GenerateRandomToken(12); err != nil {
+			logger.Error("Failed to generate a password", zap.Error(err))
+			return 1
+		}
+	}
+
+	now := time.Now()
+	admin := &models.User{Username: *username, Email: *email, Password: *password, Role: types.RoleAdmin, VerifiedAt: &now}
+	if err := commandServices(db, logger).Auth.Register(admin); err != nil {
+		fmt.Fprintf(stderr, "Failed to create the account: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Created admin %s (%s)\n", admin.Username, admin.Email)
+	if generated {
+		fmt.Fprintf(stdout, "Password: %s\n", *password)
+	}
+	return 0
+}
+
+const seedUsage = `Usage:
+  api seed
+      Create the sample accounts and content of the demo mode.
+  api seed -fake [-users n] [-authors n] [-posts n] [-comments n] [-likes n] [-views n] [-days n] [-seed n] [-force]
+      Create random accounts, posts, nested comments, likes and views, for
+      development and load tests. The same seed creates the same content.
+      Refused in production unless -force is given.
+`
+
+// runSeedCommand creates the sample accounts and content of the demo mode,
+// or fake content, in the configured database, which must be migrated, and
+// returns the exit code. The accounts log in with the "demo.password"
+// setting.
+func runSeedCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	fake := flags.Bool("fake", false, "create random content instead of the sample content")
+	opts := demo.DefaultFakeOptions
+	flags.IntVar(&opts.Users, "users", opts.Users, "number of accounts, including the authors")
+	flags.IntVar(&opts.Authors, "authors", opts.Authors, "number of editor accounts writing the posts")
+	flags.IntVar(&opts.Posts, "posts", opts.Posts, "number of posts, a tenth of them drafts")
+	flags.IntVar(&opts.Comments, "comments", opts.Comments, "most comments of a post")
+	flags.IntVar(&opts.Likes, "likes", opts.Likes, "most likes of a comment")
+	flags.IntVar(&opts.Views, "views", opts.Views, "most views of a post per day")
+	flags.IntVar(&opts.Days, "days", opts.Days, "number of days over which the posts are published")
+	flags.Uint64Var(&opts.Seed, "seed", 0, "seed of the generator, random by default")
+	force := flags.Bool("force", false, "create fake content even in production")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 {
+		fmt.Fprint(stderr, seedUsage)
+		return 2
+	}
+
+	// Load configuration, and connect to the database
+	cfg, logger, db, err := bootstrap(false)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer logger.Sync()
+
+	if *fake {
+		if cfg.Server.Environment == config.EnvProduction && !*force {
+			fmt.Fprintln(stderr, "Refusing to create fake content in production, use -force to create it anyway")
+			return 1
+		}
+		stats, err := demo.New(db, commandServices(db, logger), logger).SeedFake(opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "Seeding failed after %d users, %d posts and %d comments: %v\n", stats.Users, stats.Posts, stats.Comments, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "Created %d users, %d posts, %d comments, %d likes and %d views, log in with the demo password\n",
+			stats.Users, stats.Posts, stats.Comments, stats.Likes, stats.Views)
+		return 0
+	}
+
+	if err := demo.New(db, commandServices(db, logger), logger).Seed(); err != nil {
+		fmt.Fprintf(stderr, "Seeding failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Created the sample content, log in as %s or %s\n", demo.AdminEmail, demo.ReaderEmail)
+	return 0
+}
+
+// commandServices returns the services of the commands, which send no
+// emails, webhooks or events, and index no posts.
+func commandServices(db *gorm.DB, logger *zap.Logger) *services.Services {
+	return services.New(db, mailer.Discard, webhooks.Discard, events.Discard, nil, nil, logger)
+}
@@ -0,0 +1,162 @@
+// Package alerts watches operational metrics of the API and notifies
+// operators by email or Slack when they cross their thresholds, for
+// deployments without a full monitoring stack.
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+
+	"go.uber.org/zap"
+)
+
+// Alert rules
+const (
+	RuleErrorRateSpike = "error_rate_spike"
+	RuleServerErrors   = "server_errors"
+	RuleFailedLogins   = "failed_logins"
+	RuleQueueBacklog   = "queue_backlog"
+)
+
+// loginPath is the route whose 401 responses count as failed logins
+const loginPath = "/users/login"
+
+// Alert is a rule whose threshold was crossed during an evaluation interval.
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Summary   string    `json:"summary"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	FiredAt   time.Time `json:"fired_at"`
+}
+
+// BacklogFunc returns the number of queued jobs waiting to be processed.
+type BacklogFunc func() int64
+
+// Monitor counts the responses of the API and evaluates the alert rules at a
+// regular interval, over the responses of the interval. A rule that fired is
+// silenced for the configured cooldown.
+type Monitor struct {
+	notifiers []Notifier
+	backlog   BacklogFunc
+	logger    *zap.Logger
+
+	requests     atomic.Int64
+	serverErrors atomic.Int64
+	failedLogins atomic.Int64
+
+	mu        sync.Mutex
+	baseline  float64 // Moving average of the error rate
+	lastFired map[string]time.Time
+}
+
+// NewMonitor returns a new instance of Monitor sending alerts to the given
+// notifiers. The queue backlog rule is skipped when backlog is nil.
+func NewMonitor(notifiers []Notifier, backlog BacklogFunc, logger *zap.Logger) *Monitor {
+	return &Monitor{
+		notifiers: notifiers,
+		backlog:   backlog,
+		logger:    logger,
+		lastFired: make(map[string]time.Time),
+	}
+}
+
+// Observe counts a response sent by the API.
+func (m *Monitor) Observe(r *http.Request, status int) {
+	m.requests.Add(1)
+	if status >= http.StatusInternalServerError {
+		m.serverErrors.Add(1)
+	}
+	if status == http.StatusUnauthorized && r.Method == http.MethodPost && r.URL.Path == loginPath {
+		m.failedLogins.Add(1)
+	}
+}
+
+// Run evaluates the rules at the "alerts.interval" interval until the
+// context is canceled. Intervals under ten seconds are raised to ten seconds.
+func (m *Monitor) Run(ctx context.Context) {
+	interval := config.Get().Alerts.Interval
+	if interval < 10*time.Second {
+		interval = 10 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, alert := range m.Evaluate() {
+				m.notify(ctx, alert)
+			}
+		}
+	}
+}
+
+// Evaluate checks the rules against the responses counted since the last
+// evaluation, resets the counters and returns the alerts to send. Rules with
+// a threshold of zero are disabled.
+func (m *Monitor) Evaluate() []Alert {
+	requests := m.requests.Swap(0)
+	serverErrors := m.serverErrors.Swap(0)
+	failedLogins := m.failedLogins.Swap(0)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cfg := config.Get().Alerts
+	var alerts []Alert
+	now := time.Now()
+	fire := func(rule, summary string, value, threshold float64) {
+		if last, ok := m.lastFired[rule]; ok && now.Sub(last) < cfg.Cooldown {
+			return
+		}
+		m.lastFired[rule] = now
+		alerts = append(alerts, Alert{Rule: rule, Summary: summary, Value: value, Threshold: threshold, FiredAt: now})
+	}
+
+	// A spike is an error rate several times over its moving average
+	if requests > 0 {
+		rate := float64(serverErrors) / float64(requests)
+		factor := cfg.ErrorRate.SpikeFactor
+		minRate := cfg.ErrorRate.MinRate
+		if factor > 0 && requests >= cfg.ErrorRate.MinRequests && rate >= minRate && rate >= m.baseline*factor {
+			fire(RuleErrorRateSpike, fmt.Sprintf("Error rate spiked to %.1f%% of %d requests, against %.1f%% usually", rate*100, requests, m.baseline*100), rate, m.baseline*factor)
+		}
+		m.baseline = 0.8*m.baseline + 0.2*rate
+	}
+
+	if threshold := cfg.ServerErrors.Threshold; threshold > 0 && serverErrors >= threshold {
+		fire(RuleServerErrors, fmt.Sprintf("%d requests failed with a server error", serverErrors), float64(serverErrors), float64(threshold))
+	}
+
+	if threshold := cfg.FailedLogins.Threshold; threshold > 0 && failedLogins >= threshold {
+		fire(RuleFailedLogins, fmt.Sprintf("%d login attempts failed", failedLogins), float64(failedLogins), float64(threshold))
+	}
+
+	if threshold := cfg.QueueBacklog.Threshold; threshold > 0 && m.backlog != nil {
+		if backlog := m.backlog(); backlog >= threshold {
+			fire(RuleQueueBacklog, fmt.Sprintf("%d queued jobs are waiting to be processed", backlog), float64(backlog), float64(threshold))
+		}
+	}
+
+	return alerts
+}
+
+// notify sends an alert to every notifier. Failures are logged, and don't
+// prevent the other notifiers from being tried.
+func (m *Monitor) notify(ctx context.Context, alert Alert) {
+	m.logger.Warn("Alert fired", zap.String("rule", alert.Rule), zap.String("summary", alert.Summary))
+	for _, notifier := range m.notifiers {
+		if err := notifier.Notify(ctx, alert); err != nil {
+			m.logger.Error("Failed to send alert", zap.String("rule", alert.Rule), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/webhooks"
+
+	"github.com/google/uuid"
+)
+
+// AlertEvent is the event type of alerts posted to Slack
+const AlertEvent = "alert"
+
+// Notifier sends alerts to operators.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// NewNotifiers returns the notifiers configured under the "alerts"
+// configuration key: email when recipients are listed, and Slack when an
+// incoming webhook URL is set.
+func NewNotifiers(m mailer.Mailer, sender webhooks.Sender) []Notifier {
+	var notifiers []Notifier
+	if recipients := config.Get().Alerts.Email.Recipients; len(recipients) > 0 {
+		notifiers = append(notifiers, &EmailNotifier{mailer: m, recipients: recipients})
+	}
+	if url := config.Get().Alerts.Slack.WebhookURL; url != "" {
+		notifiers = append(notifiers, &SlackNotifier{sender: sender, url: url})
+	}
+	return notifiers
+}
+
+// EmailNotifier emails alerts to a list of recipients.
+type EmailNotifier struct {
+	mailer     mailer.Mailer
+	recipients []string
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, alert Alert) error {
+	return n.mailer.Send(ctx, mailer.Message{
+		To:      n.recipients,
+		Subject: fmt.Sprintf("[%s] Alert: %s", config.Get().Site.Title, alert.Rule),
+		Text: fmt.Sprintf("%s.\n\nRule: %s\nValue: %g\nThreshold: %g\nFired at: %s\n",
+			alert.Summary, alert.Rule, alert.Value, alert.Threshold, alert.FiredAt.Format("2006-01-02 15:04:05 MST")),
+	})
+}
+
+// SlackNotifier posts alerts to a Slack incoming webhook, through the webhook
+// sender.
+type SlackNotifier struct {
+	sender webhooks.Sender
+	url    string
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf(":rotating_light: *%s*: %s", alert.Rule, alert.Summary),
+	})
+	if err != nil {
+		return err
+	}
+
+	result := n.sender.Send(ctx, webhooks.Request{
+		URL:        n.url,
+		EventType:  AlertEvent,
+		DeliveryID: uuid.New().String(),
+		Body:       body,
+	})
+	if result.Err != nil {
+		return result.Err
+	}
+	if result.StatusCode >= 300 {
+		return fmt.Errorf("slack responded with status %d: %s", result.StatusCode, result.ResponseBody)
+	}
+	return nil
+}
@@ -0,0 +1,1495 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SteaceP/coderage/database"
+	"github.com/SteaceP/coderage/handlers"
+	"github.com/SteaceP/coderage/jwtkeys"
+	"github.com/SteaceP/coderage/licenses"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/openapi"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/webhooks"
+)
+
+// Response shapes used by the API documentation
+
+type pagination struct {
+	Total      int64 `json:"total,omitempty"` // Number of items of the listings counting them, total_posts or total_comments in API version 1
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	TotalPages int64 `json:"total_pages"`
+}
+
+type userSummary struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+type postList struct {
+	Posts      []models.Post `json:"posts"`
+	Pagination pagination    `json:"pagination"`
+	SearchID   uint          `json:"search_id,omitempty"` // Recorded search, for reporting the result clicked
+}
+
+// postResponseList is the page of posts listed by ListPosts.
+type postResponseList struct {
+	Posts      []handlers.PostResponse `json:"posts"`
+	Pagination pagination              `json:"pagination"`
+	SearchID   uint                    `json:"search_id,omitempty"` // Recorded search, for reporting the result clicked
+}
+
+// tagPostList is the page of posts of a tag.
+type tagPostList struct {
+	Tag        models.Tag    `json:"tag"`
+	Posts      []models.Post `json:"posts"`
+	Pagination pagination    `json:"pagination"`
+}
+
+// postMeta is the metadata of a post for link previews.
+type postMeta struct {
+	Title        string            `json:"title"`
+	Description  string            `json:"description"`
+	CanonicalURL string            `json:"canonical_url"`
+	Author       string            `json:"author"`
+	PublishedAt  time.Time         `json:"published_at"`
+	ModifiedAt   time.Time         `json:"modified_at"`
+	Tags         []string          `json:"tags"`
+	Image        string            `json:"image"`
+	License      *licenses.License `json:"license"`
+	OpenGraph    map[string]string `json:"open_graph"`
+	OEmbedURL    string            `json:"oembed_url,omitempty"` // Set while embeds.oembed is on
+}
+
+// commentCreated is the response of the routes creating a comment.
+type commentCreated struct {
+	Message string `json:"message"`
+	Comment struct {
+		ID      string `json:"id"`
+		Content string `json:"content"`
+		Status  string `json:"status"`
+		User    struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"user"`
+		PostID   string `json:"post_id"`
+		ParentID string `json:"parent_id,omitempty"`
+	} `json:"comment"`
+}
+
+type postRanking struct {
+	Posts []models.Post `json:"posts"`
+}
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphqlResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message string        `json:"message"`
+		Path    []interface{} `json:"path,omitempty"`
+	} `json:"errors,omitempty"`
+}
+
+type postWritten struct {
+	Message string      `json:"message"`
+	Post    models.Post `json:"post"`
+}
+
+type postPublished struct {
+	Message string               `json:"message"`
+	Post    models.Post          `json:"post"`
+	Lint    []services.LintIssue `json:"lint"` // Advisory issues found, empty when none
+}
+
+type message struct {
+	Message string `json:"message"`
+}
+
+type settingsView struct {
+	RegistrationOpen bool                `json:"registration_open"`
+	RequireApproval  bool                `json:"require_approval"`
+	CommentsEnabled  bool                `json:"comments_enabled"`
+	SensitiveGating  bool                `json:"sensitive_gating"`
+	ReferralsEnabled bool                `json:"referrals_enabled"`
+	DefaultLicense   licenses.License    `json:"default_license"`
+	RobotsRules      []models.RobotsRule `json:"robots_rules"`
+	SecurityContact  string              `json:"security_contact"`
+	Branding         services.Branding   `json:"branding"`
+	Timezone         string              `json:"timezone"`
+	DateFormat       string              `json:"date_format"`
+	HighlightCSSURL  string              `json:"highlight_css_url"` // Versioned URL of the stylesheet of the highlighted code blocks, cached for good
+}
+
+type planWritten struct {
+	Message string      `json:"message"`
+	Plan    models.Plan `json:"plan"`
+}
+
+// categoryWritten is the response of the routes creating or updating a
+// category.
+type categoryWritten struct {
+	Message  string          `json:"message"`
+	Category models.Category `json:"category"`
+}
+
+// postAuthors is the response of the routes crediting the authors of a
+// post.
+type postAuthors struct {
+	Message string              `json:"message"`
+	Authors []models.PostAuthor `json:"authors"`
+}
+
+// seriesWritten is the response of the routes changing a series.
+type seriesWritten struct {
+	Message string        `json:"message"`
+	Series  models.Series `json:"series"`
+}
+
+type likeState struct {
+	Message   string `json:"message"`
+	Liked     bool   `json:"liked"`
+	LikeCount int    `json:"like_count"`
+}
+
+// geoRestricted describes the routes serving content that can be restricted
+// by country
+const geoRestricted = "Responds 451 to countries the content is blocked in."
+
+// postSanitized describes the routes storing posts
+const postSanitized = "The raw HTML of the content is sanitized before it is stored, as it is when rendered, leaving code spans and blocks untouched. HTML markup is stripped from the title, excerpt, meta title and meta description."
+
+// pageParameters are the pagination query parameters of list routes
+var pageParameters = []openapi.Parameter{
+	{Name: "page", Description: "Page number, starting at 1", Schema: &openapi.Schema{Type: "integer"}},
+	{Name: "limit", Description: "Items per page, at most 100", Schema: &openapi.Schema{Type: "integer"}},
+}
+
+// statsDaysParameter is the period of the view stats
+var statsDaysParameter = openapi.Parameter{
+	Name: "days", Description: "Number of days covered, including today (default: 30, max: 365)", Schema: &openapi.Schema{Type: "integer"},
+}
+
+// fieldsParameter selects the fields of the posts returned
+var fieldsParameter = openapi.Parameter{
+	Name: "fields", Description: "Comma-separated fields of the posts to return, with dots for nested fields, e.g. title,slug,user.username (default: every field). Unknown fields are ignored.", Schema: &openapi.Schema{Type: "string"},
+}
+
+// exportFormatParameter is the format of the exports
+var exportFormatParameter = openapi.Parameter{
+	Name: "format", Description: "json or markdown (default: json)", Schema: &openapi.Schema{Type: "string"},
+}
+
+// auditLogRoute describes GET /admin/audit-logs, also served at its former
+// path.
+var auditLogRoute = openapi.Route{
+	Summary:     "List the recorded actions",
+	Description: "Logins and failed logins with a password, logins with a provider (auth.login with the provider in details), session revocations, password changes and post deletions, along with admin and moderation actions, most recent first. Failed logins have no actor, and target the account of the email address when there is one. Entries are never updated or deleted.",
+	Tags:        []string{"admin"},
+	Auth:        openapi.AuthRequired,
+	Query: append([]openapi.Parameter{
+		{Name: "user_id", Description: "Actions taken by the user, or targeting them", Schema: &openapi.Schema{Type: "integer"}},
+		{Name: "action", Description: "Action, e.g. auth.login_failed, or the actions of a target type with a trailing .*, e.g. auth.*", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "from", Description: "Earliest time, inclusive, RFC 3339 or YYYY-MM-DD", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "to", Description: "Latest time, exclusive, RFC 3339 or YYYY-MM-DD", Schema: &openapi.Schema{Type: "string"}},
+	}, pageParameters...),
+	Response: struct {
+		Entries    []models.AuditLog `json:"entries"`
+		Pagination pagination        `json:"pagination"`
+	}{},
+}
+
+// formerPath describes the former path of an operation, kept for the clients
+// still using it.
+func formerPath(route openapi.Route, operation string) openapi.Route {
+	route.Summary += " (former path)"
+	route.Description = "Former path of " + operation + ", answered alike. " + route.Description
+	return route
+}
+
+// routeDocs describes the routes registered in setupRoutes. Routes missing
+// here are still listed in the specification, without a description.
+var routeDocs = openapi.Registry{
+	"GET /health": {
+		Summary: "Check the health of the API and its database",
+		Tags:    []string{"system"},
+		Response: struct {
+			Status   string `json:"status"`
+			Database string `json:"database"`
+		}{},
+	},
+	"GET /metrics": {
+		Summary:     "Scrape the Prometheus metrics",
+		Description: "Served when metrics are enabled. Request counts and latencies are labeled by route template, along with the database connection pool statistics. Requires the configured bearer token, if any.",
+		Tags:        []string{"system"},
+		ContentType: "text/plain",
+	},
+
+	// Users
+	"POST /users": {
+		Summary:     "Register a new user",
+		Description: "Returns 202 without a token when the account awaits approval. Users signing up with a referral code are credited to its owner. Registration may be closed to some countries.",
+		Tags:        []string{"users"},
+		Query:       []openapi.Parameter{{Name: "ref", Description: "Referral code", Schema: &openapi.Schema{Type: "string"}}},
+		Request:     handlers.CreateUserRequest{},
+		Status:      http.StatusCreated,
+		Response: struct {
+			Message      string      `json:"message"`
+			Token        string      `json:"token"`
+			RefreshToken string      `json:"refresh_token"`
+			User         userSummary `json:"user"`
+		}{},
+	},
+	"POST /users/login": {
+		Summary: "Log in and get an access token",
+		Tags:    []string{"users"},
+		Request: handlers.LoginRequest{},
+		Response: struct {
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token"`
+			Message      string `json:"message"`
+		}{},
+	},
+	"POST /auth/refresh": {
+		Summary:     "Exchange a refresh token for a new token pair",
+		Description: "The refresh token is replaced by the one returned, and extends its session by 7 days. Reusing a replaced refresh token revokes its session. Returns 401 for invalid, expired or revoked refresh tokens, and for access tokens.",
+		Tags:        []string{"users"},
+		Request:     handlers.RefreshTokenRequest{},
+		Response: struct {
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token"`
+		}{},
+	},
+	"POST /users/resend-verification": {
+		Summary:     "Send a new email verification link",
+		Description: "Answers with the same message whether or not the address belongs to an account, is already verified, or the email could be sent.",
+		Tags:        []string{"users"},
+		Request:     handlers.ResendVerificationRequest{},
+		Response:    message{},
+	},
+	"GET /users/verify": {
+		Summary:  "Verify an email address",
+		Tags:     []string{"users"},
+		Query:    []openapi.Parameter{{Name: "token", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+		Response: message{},
+	},
+	"GET /users/profile": {
+		Summary:  "Get the profile of the current user",
+		Tags:     []string{"users"},
+		Auth:     openapi.AuthRequired,
+		Response: userSummary{},
+	},
+	"PATCH /users/profile": {
+		Summary:     "Update the profile of the current user",
+		Description: "Omitted fields are left unchanged, and empty strings clear the field. Links must be absolute URLs. HTML markup is stripped from the names and the bio.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.UpdateProfileRequest{},
+		Response: struct {
+			Message string                 `json:"message"`
+			Profile map[string]interface{} `json:"profile"`
+		}{},
+	},
+	"POST /users/password": {
+		Summary:     "Change the password of the current user",
+		Description: "The new password must be at least 8 characters and include uppercase, lowercase, number, and special character. Refresh tokens issued before the change stop working, so a new token pair is returned.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.ChangePasswordRequest{},
+		Response: struct {
+			Message      string `json:"message"`
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token"`
+		}{},
+	},
+	"PUT /users/preferences": {
+		Summary: "Update the preferences of the current user",
+		Tags:    []string{"users"},
+		Auth:    openapi.AuthRequired,
+		Request: handlers.UpdatePreferencesRequest{},
+		Response: struct {
+			Message     string `json:"message"`
+			Preferences struct {
+				ShowSensitive bool `json:"show_sensitive"`
+				EmailDigest   bool `json:"email_digest"`
+			} `json:"preferences"`
+		}{},
+	},
+	"GET /users/{username}": {
+		Summary:     "Get the public profile of a user and their published posts",
+		Description: "The email address is never included. Deactivated accounts and accounts awaiting approval are not found.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthOptional,
+		Query:       pageParameters,
+		Response: struct {
+			User struct {
+				ID                string           `json:"id"`
+				Username          string           `json:"username"`
+				FirstName         string           `json:"first_name"`
+				LastName          string           `json:"last_name"`
+				Bio               string           `json:"bio"`
+				ProfilePicture    string           `json:"profile_picture"`
+				ProfilePictureSet *models.ImageSet `json:"profile_picture_set"`
+				TwitterHandle     string           `json:"twitter_handle"`
+				LinkedInProfile   string           `json:"linkedin_profile"`
+				PersonalWebsite   string           `json:"personal_website"`
+				Badges            []string         `json:"badges"`
+				JoinedAt          time.Time        `json:"joined_at"`
+			} `json:"user"`
+			Posts      []handlers.PostResponse `json:"posts"`
+			Pagination pagination              `json:"pagination"`
+		}{},
+	},
+	"GET /users/referrals": {
+		Summary:     "Get the referral code of the current user and the users they referred",
+		Description: "Referred users who verified their email address earn perks: a badge and extra daily uploads. Returns 403 while the referral program is disabled.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Query:       pageParameters,
+		Response: struct {
+			Referral   services.ReferralSummary `json:"referral"`
+			Referrals  []models.Referral        `json:"referrals"`
+			Pagination pagination               `json:"pagination"`
+		}{},
+	},
+	"DELETE /users/me": {
+		Summary:     "Delete the account of the current user",
+		Description: "The account and its posts are deleted, and its comments anonymized. Personal data is purged after the configured grace period. Admins must give up their role first.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.DeleteAccountRequest{},
+		Response:    message{},
+	},
+	"GET /users/me/sessions": {
+		Summary:     "List the sessions of the current user",
+		Description: "The devices the user is logged in on, the ones used last first. Each login starts a session, kept alive by refreshing its tokens. current marks the session of the request.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Response: struct {
+			Sessions []handlers.SessionResponse `json:"sessions"`
+		}{},
+	},
+	"DELETE /users/me/sessions": {
+		Summary:     "Log out everywhere",
+		Description: "Revokes every session of the user, including the current one, and the tokens issued before sessions. Their access tokens are rejected.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Response:    message{},
+	},
+	"DELETE /users/me/sessions/{id}": {
+		Summary:     "Revoke a session of the current user",
+		Description: "Logs the device of the session out: its refresh token and access tokens are rejected.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Response:    message{},
+	},
+	"GET /users/me/export": {
+		Summary:     "Export all the data of the current user",
+		Description: "Returns the profile, posts and comments of the user as a JSON attachment.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Response:    services.AccountExport{},
+	},
+	"GET /users/me/stats": {
+		Summary:     "Get the views of the posts of the current user",
+		Description: "Views are counted per day in UTC, once per visitor within the views.dedup_window setting, leaving out bots. Every day of the period is listed, along with the most viewed posts.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Query:       []openapi.Parameter{statsDaysParameter},
+		Response:    services.ViewStats{},
+	},
+	"GET /users/me/analytics": {
+		Summary:     "Get how the posts of the current user perform",
+		Description: "Lists every post of the user, drafts included, with its total views, likes and comments, and its views and published comments over the period, most viewed over the period first. Every day of the period is listed with the views and comments of all the posts, in UTC. With format=csv, the posts are returned as a CSV file, or the days with report=days.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Query: []openapi.Parameter{
+			statsDaysParameter,
+			{Name: "format", Description: "json or csv (default: json)", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "report", Description: "Rows of the CSV file: posts or days (default: posts)", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Response: services.AuthorAnalytics{},
+	},
+	"GET /users/onboarding": {
+		Summary:     "Get the onboarding progress of the current user",
+		Description: "Lists the onboarding steps in order: verify_email, complete_profile (first name, bio and profile picture set) and first_post, with next being the first step left. Steps stay completed once completed. Completing a step publishes an onboarding.step_completed event, and the last one an onboarding.completed event, which webhooks can subscribe to.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Response:    services.OnboardingStatus{},
+	},
+	"GET /users/identities": {
+		Summary:     "List the login methods of the current user",
+		Description: "Tells whether the user has a password, and lists the accounts of login providers they linked.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Response:    services.LoginMethods{},
+	},
+	"POST /users/identities/{provider}": {
+		Summary:     "Link a login method to the current user",
+		Description: "Links the account of a provider, google or github, with the authorization code it redirected the user with after GET /auth/providers/{provider}/authorize. Use password as the provider to set a password, for users who signed up through a provider. Returns 409 when the provider account is linked to another user, or the user already linked an account of the provider.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.LinkIdentityRequest{},
+		Status:      http.StatusCreated,
+		Response:    services.LoginMethods{},
+	},
+	"DELETE /users/identities/{provider}": {
+		Summary:     "Unlink a login method of the current user",
+		Description: "Unlinks the account of a provider, or removes the password with password as the provider. Returns 409 for the last login method of the user. Removing the password revokes the refresh tokens issued before.",
+		Tags:        []string{"users"},
+		Auth:        openapi.AuthRequired,
+		Response:    message{},
+	},
+
+	// Login providers
+	"GET /auth/providers": {
+		Summary: "List the login providers users can log in with",
+		Tags:    []string{"auth"},
+		Response: struct {
+			Providers []string `json:"providers"`
+		}{},
+	},
+	"GET /auth/providers/{provider}/authorize": {
+		Summary:     "Get the authorization URL of a login provider",
+		Description: "Returns the URL of the provider to send the user to, and the state it redirects the user back with, to the configured redirect URL. Keep the state and check it on the redirect before using the code. Returns 404 for providers that aren't configured.",
+		Tags:        []string{"auth"},
+		Response: struct {
+			URL   string `json:"url"`
+			State string `json:"state"`
+		}{},
+	},
+	"POST /auth/providers/{provider}/login": {
+		Summary:     "Log in with a login provider",
+		Description: "Logs in the user whose provider account is linked, with the authorization code the provider redirected them with. Unlinked accounts are signed up, with 201, or 202 without a token when new accounts await approval, their email address being verified if the provider verified it. Accounts are never merged on the email address: when it is the one of an existing user, 409 is returned and the user must log in to that account and link the provider. Returns 401 when the provider rejects the code.",
+		Tags:        []string{"auth"},
+		Query:       []openapi.Parameter{{Name: "ref", Description: "Referral code, for users signing up", Schema: &openapi.Schema{Type: "string"}}},
+		Request:     handlers.ProviderLoginRequest{},
+		Response: struct {
+			Message      string      `json:"message"`
+			Token        string      `json:"token"`
+			RefreshToken string      `json:"refresh_token"`
+			User         userSummary `json:"user"`
+		}{},
+	},
+	"GET /auth/{provider}/login": {
+		Summary:     "Start a login with a login provider",
+		Description: "Redirects the browser to the provider, which redirects it back to GET /auth/{provider}/callback, for sites that let the API complete the login. {server.base_url}/auth/{provider}/callback must be registered as a redirect URL at the provider. Returns 404 for providers that aren't configured.",
+		Tags:        []string{"auth"},
+		Query:       []openapi.Parameter{{Name: "ref", Description: "Referral code, for users signing up", Schema: &openapi.Schema{Type: "string"}}},
+		Status:      http.StatusFound,
+	},
+	"GET /auth/{provider}/callback": {
+		Summary:     "Complete a login with a login provider",
+		Description: "Logs in or signs up the user as POST /auth/providers/{provider}/login does, then redirects to the oauth.complete_path page of the site. The fragment holds status (logged_in, signed_up or pending_approval), token and refresh_token, or error (invalid_state, access_denied, registration_closed, registration_unavailable, email_taken, pending_approval, account_inactive or login_failed) and message.",
+		Tags:        []string{"auth"},
+		Query: []openapi.Parameter{
+			{Name: "code", Description: "Authorization code", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "state", Description: "State sent to the provider", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "error", Description: "Set by the provider when the user declined", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Status: http.StatusFound,
+	},
+
+	// Notifications
+	"GET /notifications": {
+		Summary:     "List the notifications of the current user",
+		Description: "Repeated activity on the same comment or post is grouped into one notification until it is read.",
+		Tags:        []string{"notifications"},
+		Auth:        openapi.AuthRequired,
+		Query: append([]openapi.Parameter{
+			{Name: "unread", Description: "Only unread notifications", Schema: &openapi.Schema{Type: "boolean"}},
+		}, pageParameters...),
+		Response: struct {
+			Notifications []models.Notification `json:"notifications"`
+			UnreadCount   int64                 `json:"unread_count"`
+			Pagination    pagination            `json:"pagination"`
+		}{},
+	},
+	"POST /notifications/read": {
+		Summary:  "Mark every notification as read",
+		Tags:     []string{"notifications"},
+		Auth:     openapi.AuthRequired,
+		Response: message{},
+	},
+	"POST /notifications/{id}/read": {
+		Summary:  "Mark a notification as read",
+		Tags:     []string{"notifications"},
+		Auth:     openapi.AuthRequired,
+		Response: message{},
+	},
+
+	// Admin
+	"POST /admin/posts/bulk": {
+		Summary:     "Change many posts at once",
+		Description: "Sets the status of the posts, adds or removes tags, or deletes them, in a single transaction. The result of every post is returned, not_found for the missing ones. At most 500 posts are changed at once.",
+		Tags:        []string{"admin", "posts"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.BulkPostsRequest{},
+		Response: struct {
+			Results []services.BulkPostResult `json:"results"`
+		}{},
+	},
+	"GET /admin/users": {
+		Summary: "List users",
+		Tags:    []string{"admin"},
+		Auth:    openapi.AuthRequired,
+		Query: append([]openapi.Parameter{
+			{Name: "role", Description: "Only users with this role", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "is_active", Description: "Only active or deactivated users", Schema: &openapi.Schema{Type: "boolean"}},
+			{Name: "pending_approval", Description: "Only users awaiting approval or not", Schema: &openapi.Schema{Type: "boolean"}},
+			{Name: "search", Description: "Text contained in the username or email", Schema: &openapi.Schema{Type: "string"}},
+		}, pageParameters...),
+		Response: struct {
+			Users      []models.User `json:"users"`
+			Pagination pagination    `json:"pagination"`
+		}{},
+	},
+	"PUT /admin/users/{id}/role": {
+		Summary: "Change the role of a user",
+		Tags:    []string{"admin"},
+		Auth:    openapi.AuthRequired,
+		Request: handlers.UpdateRoleRequest{},
+		Response: struct {
+			Message string      `json:"message"`
+			User    models.User `json:"user"`
+		}{},
+	},
+	"PUT /admin/users/{id}/membership": {
+		Summary:     "Grant or revoke the membership of a user",
+		Description: "Members see posts during their early access window.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.UpdateMembershipRequest{},
+		Response: struct {
+			Message string      `json:"message"`
+			User    models.User `json:"user"`
+		}{},
+	},
+	"POST /admin/users/{id}/approve": {
+		Summary:  "Approve a pending account",
+		Tags:     []string{"admin"},
+		Auth:     openapi.AuthRequired,
+		Response: message{},
+	},
+	"POST /admin/users/{id}/verify": {
+		Summary:  "Mark the email address of a user as verified",
+		Tags:     []string{"admin"},
+		Auth:     openapi.AuthRequired,
+		Response: message{},
+	},
+	"POST /admin/users/{id}/deactivate": {
+		Summary:  "Deactivate an account",
+		Tags:     []string{"admin"},
+		Auth:     openapi.AuthRequired,
+		Response: message{},
+	},
+	"DELETE /admin/users/{id}": {
+		Summary:  "Delete an account",
+		Tags:     []string{"admin"},
+		Auth:     openapi.AuthRequired,
+		Response: message{},
+	},
+	"GET /admin/email-suppressions": {
+		Summary:     "List the addresses emails aren't sent to",
+		Description: "Addresses are suppressed when the email provider reports a permanent bounce or a spam complaint. Their users show the reason as email_status.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Query:       pageParameters,
+		Response: struct {
+			Suppressions []models.EmailSuppression `json:"suppressions"`
+			Pagination   pagination                `json:"pagination"`
+		}{},
+	},
+	"DELETE /admin/email-suppressions/{email}": {
+		Summary:     "Lift the suppression of an address",
+		Description: "Emails are sent to the address again, e.g. once its user fixed their mailbox, and the email status of its users is cleared.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Response:    message{},
+	},
+	"GET /admin/plans": {
+		Summary: "List the plans",
+		Tags:    []string{"admin"},
+		Auth:    openapi.AuthRequired,
+		Response: struct {
+			Plans []models.Plan `json:"plans"`
+		}{},
+	},
+	"POST /admin/plans": {
+		Summary:     "Create a plan",
+		Description: "Limits of 0 are unlimited. The site moves to a plan once it is set as the plan_id of the site settings.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.PlanRequest{},
+		Status:      http.StatusCreated,
+		Response:    planWritten{},
+	},
+	"PUT /admin/plans/{id}": {
+		Summary:     "Update a plan",
+		Description: "Limits can be lowered under the current usage: nothing is removed, but no more content is allowed.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.PlanRequest{},
+		Response:    planWritten{},
+	},
+	"DELETE /admin/plans/{id}": {
+		Summary:     "Delete a plan",
+		Description: "The plan of the site can't be deleted.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Response:    message{},
+	},
+	"POST /admin/categories": {
+		Summary:     "Create a category",
+		Description: "The slug is generated from the name when empty. Categories are top-level unless given a parent_id, and ordered by position then name among the categories of the same parent.",
+		Tags:        []string{"admin", "categories"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.CategoryRequest{},
+		Status:      http.StatusCreated,
+		Response:    categoryWritten{},
+	},
+	"PUT /admin/categories/{id}": {
+		Summary:     "Update a category",
+		Description: "A parent_id of 0 makes the category top-level. A category can't be moved under itself or its subcategories.",
+		Tags:        []string{"admin", "categories"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.CategoryRequest{},
+		Response:    categoryWritten{},
+	},
+	"DELETE /admin/categories/{id}": {
+		Summary:     "Delete a category",
+		Description: "Its subcategories move to its parent, and its posts are left without a category.",
+		Tags:        []string{"admin", "categories"},
+		Auth:        openapi.AuthRequired,
+		Response:    message{},
+	},
+	"GET /admin/usage": {
+		Summary:     "Get the usage of the site against the limits of its plan",
+		Description: "Reports the posts, comments, storage in bytes and members. Creating content over a limit responds 402 with the limit_exceeded code.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Response:    services.PlanUsage{},
+	},
+	"GET /admin/search/report": {
+		Summary:     "Get the search report",
+		Description: "Sums up the post searches of the last days, with the most searched queries and the queries finding nothing, so editors know what readers look for and don't find.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Query: []openapi.Parameter{
+			{Name: "days", Description: "Number of days covered (default: 30, max: 365)", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "limit", Description: "Number of queries of each ranking (default: 20, max: 100)", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Response: services.SearchReport{},
+	},
+	"GET /admin/read-only": {
+		Summary:     "Get the read-only mode",
+		Description: "While the site is read-only, requests that may write answer 503 with the read_only code, except logging in and this switch. The mode is on when forced by the read_only.enabled setting, the source being config, or when turned on by PUT /admin/read-only or the migrate command, the source being switch.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Response:    database.ReadOnlyState{},
+	},
+	"PUT /admin/read-only": {
+		Summary:     "Turn the read-only mode on or off",
+		Description: "Every instance applies the change within the read_only.poll_interval setting. Turning it off doesn't end the mode forced by the read_only.enabled setting.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.ReadOnlyRequest{},
+		Response:    database.ReadOnlyState{},
+	},
+	"GET /admin/loglevel": {
+		Summary:     "Get the log level",
+		Description: "Returns the minimum level of the entries logged by this instance.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Response:    handlers.LogLevelResponse{},
+	},
+	"PUT /admin/loglevel": {
+		Summary:     "Change the log level",
+		Description: "Changes the minimum level of the entries logged by this instance until it restarts, which sets the logging.level setting again. Other instances keep their level.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.LogLevelRequest{},
+		Response:    handlers.LogLevelResponse{},
+	},
+	"GET /admin/routes": {
+		Summary:     "List the routes and who may call them",
+		Description: "Lists every registered route with the authentication and roles it requires and its rate limit, along with the rate limit policy.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Response: struct {
+			Routes    []handlers.RouteAccess   `json:"routes"`
+			RateLimit handlers.RateLimitPolicy `json:"rate_limit"`
+		}{},
+	},
+	"GET /admin/audit-logs": auditLogRoute,
+	"GET /admin/audit-log":  formerPath(auditLogRoute, "GET /admin/audit-logs"),
+
+	// Webhooks
+	"GET /admin/webhooks": {
+		Summary: "List webhooks",
+		Tags:    []string{"webhooks"},
+		Auth:    openapi.AuthRequired,
+		Response: struct {
+			Webhooks []models.Webhook `json:"webhooks"`
+		}{},
+	},
+	"POST /admin/webhooks": {
+		Summary:     "Register a webhook",
+		Description: "The secret signing the payloads is only returned in this response. Failed deliveries are retried with an exponential backoff, up to \"webhooks.retries.max_attempts\" attempts.",
+		Tags:        []string{"webhooks"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.CreateWebhookRequest{},
+		Status:      http.StatusCreated,
+		Response: struct {
+			Message string         `json:"message"`
+			Webhook models.Webhook `json:"webhook"`
+			Secret  string         `json:"secret"`
+		}{},
+	},
+	"GET /admin/webhooks/{id}": {
+		Summary:  "Get a webhook",
+		Tags:     []string{"webhooks"},
+		Auth:     openapi.AuthRequired,
+		Response: models.Webhook{},
+	},
+	"DELETE /admin/webhooks/{id}": {
+		Summary:  "Delete a webhook",
+		Tags:     []string{"webhooks"},
+		Auth:     openapi.AuthRequired,
+		Response: message{},
+	},
+	"POST /admin/webhooks/{id}/test": {
+		Summary:     "Send a test delivery",
+		Description: "Sends a sample \"ping\" event, even to inactive webhooks.",
+		Tags:        []string{"webhooks"},
+		Auth:        openapi.AuthRequired,
+		Response:    models.WebhookDelivery{},
+	},
+	"GET /admin/webhooks/{id}/deliveries": {
+		Summary:     "List the deliveries of a webhook",
+		Description: "Most recent first. Each retry is a delivery of its own, pointing to the attempt it retries, and failed attempts awaiting a retry hold its time.",
+		Tags:        []string{"webhooks"},
+		Auth:        openapi.AuthRequired,
+		Query:       pageParameters,
+		Response: struct {
+			Deliveries []models.WebhookDelivery `json:"deliveries"`
+			Pagination pagination               `json:"pagination"`
+		}{},
+	},
+	"GET /admin/webhooks/{id}/deliveries/{deliveryId}": {
+		Summary:     "Get a delivery",
+		Description: "Includes the request and response, and the details needed to check the signature.",
+		Tags:        []string{"webhooks"},
+		Auth:        openapi.AuthRequired,
+		Response:    models.WebhookDelivery{},
+	},
+	"GET /admin/integrations": {
+		Summary: "List the integrations",
+		Tags:    []string{"integrations"},
+		Auth:    openapi.AuthRequired,
+		Response: struct {
+			Integrations []models.Integration `json:"integrations"`
+		}{},
+	},
+	"POST /admin/integrations": {
+		Summary:     "Register an integration",
+		Description: "Integrations create comments through POST /integrations/{id}/comments. The secret verifying their payloads is only returned in this response. Comments of moderated integrations are held hidden until a moderator publishes them.",
+		Tags:        []string{"integrations"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.CreateIntegrationRequest{},
+		Status:      http.StatusCreated,
+		Response: struct {
+			Message     string             `json:"message"`
+			Integration models.Integration `json:"integration"`
+			Secret      string             `json:"secret"`
+		}{},
+	},
+	"GET /admin/integrations/{id}": {
+		Summary:  "Get an integration",
+		Tags:     []string{"integrations"},
+		Auth:     openapi.AuthRequired,
+		Response: models.Integration{},
+	},
+	"DELETE /admin/integrations/{id}": {
+		Summary:     "Delete an integration",
+		Description: "Its payloads are rejected from then on. The comments it created are kept.",
+		Tags:        []string{"integrations"},
+		Auth:        openapi.AuthRequired,
+		Response:    message{},
+	},
+	"POST /admin/webhooks/{id}/deliveries/{deliveryId}/redeliver": {
+		Summary:     "Redeliver a delivery",
+		Description: "Sends the same payload with a new delivery ID, signed with the current secret.",
+		Tags:        []string{"webhooks"},
+		Auth:        openapi.AuthRequired,
+		Response:    models.WebhookDelivery{},
+	},
+
+	// Announcements
+	"GET /announcements": {
+		Summary:     "List the announcements currently shown to the requesting user",
+		Description: "Announcements are shown between their start and end times to their audience, the most severe first.",
+		Tags:        []string{"announcements"},
+		Auth:        openapi.AuthOptional,
+		Response: struct {
+			Announcements []models.Announcement `json:"announcements"`
+		}{},
+	},
+	"GET /admin/announcements": {
+		Summary: "List every announcement, including past and scheduled ones",
+		Tags:    []string{"announcements"},
+		Auth:    openapi.AuthRequired,
+		Query:   pageParameters,
+		Response: struct {
+			Announcements []models.Announcement `json:"announcements"`
+			Pagination    pagination            `json:"pagination"`
+		}{},
+	},
+	"POST /admin/announcements": {
+		Summary: "Create an announcement",
+		Tags:    []string{"announcements"},
+		Auth:    openapi.AuthRequired,
+		Request: handlers.AnnouncementRequest{},
+		Status:  http.StatusCreated,
+		Response: struct {
+			Message      string              `json:"message"`
+			Announcement models.Announcement `json:"announcement"`
+		}{},
+	},
+	"PUT /admin/announcements/{id}": {
+		Summary: "Update an announcement",
+		Tags:    []string{"announcements"},
+		Auth:    openapi.AuthRequired,
+		Request: handlers.AnnouncementRequest{},
+		Response: struct {
+			Message      string              `json:"message"`
+			Announcement models.Announcement `json:"announcement"`
+		}{},
+	},
+	"DELETE /admin/announcements/{id}": {
+		Summary:  "Delete an announcement",
+		Tags:     []string{"announcements"},
+		Auth:     openapi.AuthRequired,
+		Response: message{},
+	},
+
+	// Settings
+	"GET /settings": {
+		Summary:  "Get the public site settings",
+		Tags:     []string{"settings"},
+		Response: settingsView{},
+	},
+	"PUT /settings": {
+		Summary:     "Update the site settings",
+		Description: "Only admins can update settings. Custom head snippets are sanitized down to <meta> and <link> tags.",
+		Tags:        []string{"settings"},
+		Auth:        openapi.AuthRequired,
+		Roles:       []string{types.RoleAdmin},
+		Request:     handlers.UpdateSettingsRequest{},
+		Response: struct {
+			Message  string       `json:"message"`
+			Settings settingsView `json:"settings"`
+		}{},
+	},
+
+	// Posts
+	"GET /posts": {
+		Summary:     "List posts",
+		Description: geoRestricted,
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthOptional,
+		Query: append([]openapi.Parameter{
+			{Name: "month", Description: "Only posts published during this month (YYYY-MM), in the time zone of the site", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "category", Description: "Only posts filed under the category of this slug or one of its subcategories", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "q", Description: "Only posts whose title or content contains this text, or, when a search engine is configured, the posts it finds, most relevant first. The first page of a search is recorded for the search analytics, and returns a search_id.", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "user_id", Description: "Only posts of the author of this ID", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "username", Description: "Only posts of the author of this username", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "tags", Description: "Only posts with any of these comma-separated tag slugs, or all of them with tags_match=all. At most 20 tags.", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "tags_match", Description: "any or all (default: any)", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "published_after", Description: "Only posts published from this date (YYYY-MM-DD, in the time zone of the site) or RFC 3339 time. Can't be combined with month.", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "published_before", Description: "Only posts published before this date (YYYY-MM-DD, in the time zone of the site) or RFC 3339 time. Can't be combined with month.", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "min_views", Description: "Only posts viewed at least this many times", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "status", Description: "published, draft, archived or all (default: published). Other statuses than published are listed to admins, and to authors listing their own posts with user_id or username.", Schema: &openapi.Schema{Type: "string"}},
+			fieldsParameter,
+		}, pageParameters...),
+		Response: postResponseList{},
+	},
+	"POST /search/{id}/click": {
+		Summary:     "Record the post clicked in the results of a search",
+		Description: "The ID is the search_id returned by GET /posts. Only the first click of a search is kept, and who clicked isn't recorded.",
+		Tags:        []string{"posts"},
+		Request:     handlers.SearchClickRequest{},
+		Status:      http.StatusNoContent,
+	},
+	"GET /posts/trending": {
+		Summary:     "List the trending posts",
+		Description: "Posts are ranked on their views and comments over the discovery.trending.window setting and on their likes, each counting half as much every discovery.trending.half_life, likes aging with the post. Only published posts visible to the public are ranked, and the ranking is cached for discovery.cache_ttl. " + geoRestricted,
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthOptional,
+		Response:    postRanking{},
+	},
+	"GET /posts/archive": {
+		Summary:     "List the months posts were published in",
+		Description: "Months are in the time zone of the site, with the number of posts published during each, most recent first.",
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthOptional,
+		Response: struct {
+			Timezone string                  `json:"timezone"`
+			Months   []services.ArchiveMonth `json:"months"`
+		}{},
+	},
+	"POST /posts": {
+		Summary:     "Create a post",
+		Description: postSanitized,
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthRequired,
+		Roles:       []string{types.RoleAdmin},
+		Request:     handlers.CreatePostRequest{},
+		Status:      http.StatusCreated,
+		Response:    postWritten{},
+	},
+	"GET /posts/{id}": {
+		Summary:     "Get a post",
+		Description: "The content is returned as written in content and content_markdown, and rendered to sanitized HTML in content_html, with its code blocks highlighted. The content of sensitive posts is withheld until the reader acknowledges them. Posts in early access are only found by members. Translated posts are served in the language given by lang, or negotiated from the Accept-Language header, falling back to the language the post is written in; the locale served is in locale and the Content-Language header, and the locales of the translations in translations. Posts part of a series are placed in it in series, with links to the posts before and after them. " + geoRestricted,
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthOptional,
+		Query: []openapi.Parameter{
+			{Name: "lang", Description: "Language tag of the translation to serve, e.g. fr or pt-BR, overriding the Accept-Language header", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "Accept-Language", In: "header", Description: "Languages preferred by the reader", Schema: &openapi.Schema{Type: "string"}},
+			fieldsParameter,
+		},
+		Response: handlers.PostResponse{},
+	},
+	"GET /highlight.css": {
+		Summary:     "Stylesheet of the highlighted code blocks",
+		Description: "Styles the code blocks of the content_html of posts, in the configured highlight style. Requested with the current version in v, as in the highlight_css_url setting, the stylesheet is cached for good; the version changes with the style.",
+		Tags:        []string{"posts"},
+		Query:       []openapi.Parameter{{Name: "v", Description: "Version of the stylesheet", Schema: &openapi.Schema{Type: "string"}}},
+		ContentType: "text/css",
+	},
+	"GET /posts/{id}/related": {
+		Summary:     "List the posts related to a post",
+		Description: "Posts are ranked on the tags they share with the post and, with the discovery.related.text_similarity setting, on the words their titles and excerpts share with it. Only published posts visible to the public are ranked, and the ranking is cached for discovery.cache_ttl. " + geoRestricted,
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthOptional,
+		Response:    postRanking{},
+	},
+	"GET /posts/{id}/meta": {
+		Summary:     "Get the metadata of a post for link previews",
+		Description: geoRestricted,
+		Tags:        []string{"posts"},
+		Response:    postMeta{},
+	},
+	"GET /posts/{id}/translations": {
+		Summary:     "List the translations of a post",
+		Description: "Sorted by locale. The content of the translations of sensitive posts is withheld, as the one of the post, until the reader acknowledges them. " + geoRestricted,
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthOptional,
+		Response: struct {
+			Translations []models.PostTranslation `json:"translations"`
+		}{},
+	},
+	"GET /posts/{id}/translations/{locale}": {
+		Summary:     "Get the translation of a post",
+		Description: "The locale is a language tag such as fr or pt-BR. The content of the translation of a sensitive post is withheld, as the one of the post, until the reader acknowledges it. " + geoRestricted,
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthOptional,
+		Response:    models.PostTranslation{},
+	},
+	"PUT /posts/{id}/translations/{locale}": {
+		Summary:     "Create or replace the translation of a post",
+		Description: "Only the authors of a post, contributors included, can translate it, to a language other than the one it is written in, given by its locale or the site.locale setting. The locale is a language tag such as fr or pt-BR. The title and content are required; the excerpt, meta title and meta description fall back to those of the post when empty. Answers 201 when the translation is created and 200 when it is replaced. " + postSanitized,
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.SaveTranslationRequest{},
+		Statuses:    []int{http.StatusCreated},
+		Response: struct {
+			Message     string                 `json:"message"`
+			Translation models.PostTranslation `json:"translation"`
+		}{},
+	},
+	"DELETE /posts/{id}/translations/{locale}": {
+		Summary:     "Delete the translation of a post",
+		Description: "Only the authors of the post, contributors included, can delete its translations.",
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthRequired,
+		Response:    message{},
+	},
+	"PUT /posts/{id}/authors/{userId}": {
+		Summary:     "Credit an author of a post",
+		Description: "Credits a user as an author of the post, or changes their role. Authors can edit, publish and delete the post, contributors can only edit it. Only the lead author, who created the post, can credit its authors, and their own role can't change. Answers 201 when the user is credited and 200 when their role changes, with the authors of the post, the lead author first.",
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.CreditAuthorRequest{},
+		Response:    postAuthors{},
+	},
+	"DELETE /posts/{id}/authors/{userId}": {
+		Summary:     "Uncredit an author of a post",
+		Description: "The lead author can uncredit the other authors, and each author can uncredit themselves. The lead author stays credited.",
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthRequired,
+		Response:    postAuthors{},
+	},
+	"GET /posts/{id}/live": {
+		Summary:     "Stream the live readers of a post",
+		Description: "Served when presence is enabled. A Server-Sent Events stream, open for as long as the client reads the post, sending a readers event whose data is {\"post_id\": 1, \"readers\": 3} when the stream starts and whenever the number of readers changes, checked every presence.heartbeat. Readers are counted on every instance with the redis driver, and on the instance serving the stream otherwise. Answers 503 with the code too_many_streams when the instance holds presence.max_streams streams. " + geoRestricted,
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthOptional,
+		ContentType: "text/event-stream",
+	},
+	"GET /posts/{id}/stats": {
+		Summary:     "Get the views of a post",
+		Description: "Only the author of the post and admins can see them. Views are counted per day in UTC, once per visitor within the views.dedup_window setting, leaving out bots, and every day of the period is listed.",
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthRequired,
+		Query:       []openapi.Parameter{statsDaysParameter},
+		Response:    services.ViewStats{},
+	},
+	"PUT /posts/{id}": {
+		Summary:     "Update a post",
+		Description: "Only the authors of the post, contributors included, can update it. " + postSanitized,
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.CreatePostRequest{},
+		Response:    postWritten{},
+	},
+	"POST /posts/{id}/publish": {
+		Summary:     "Publish a post",
+		Description: "Only the authors of a post can publish it, once, contributors excluded. The post is first checked as set by the posts.lint settings: a featured image, an excerpt, links to posts of the site leading to published posts, headings starting at level 2 without skipping a level, and a meta description of at most posts.lint.meta_description_length characters. Advisory issues are listed with the published post. Blocking issues answer 422 with the code lint_failed, listing every issue found in the details of the error, and the post stays a draft. Send X-Sandbox: true to check a post without publishing it.",
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthRequired,
+		Response:    postPublished{},
+	},
+	"DELETE /posts/{id}": {
+		Summary:     "Delete a post",
+		Description: "Only the authors of the post can delete it, contributors excluded.",
+		Tags:        []string{"posts"},
+		Auth:        openapi.AuthRequired,
+		Response:    message{},
+	},
+
+	// Comments
+	"GET /posts/{postId}/comments": {
+		Summary:     "List the comments of a post",
+		Description: "With tree set, only top-level comments are paginated and their replies are nested in them at every depth.",
+		Tags:        []string{"comments"},
+		Auth:        openapi.AuthOptional,
+		Query: append([]openapi.Parameter{
+			{Name: "tree", Description: "Nest replies in their parent comment", Schema: &openapi.Schema{Type: "boolean"}},
+		}, pageParameters...),
+		Response: struct {
+			Comments   []models.Comment `json:"comments"`
+			Pagination pagination       `json:"pagination"`
+		}{},
+	},
+	"POST /posts/{postId}/comments": {
+		Summary:     "Comment on a post",
+		Description: "Replies set parent_id to a comment of the same post, within the configured nesting depth. HTML markup is stripped from the content before it is stored. Comments suspected of spam, by Akismet or by the moderation.spam heuristics, are created hidden with the status \"hidden\" until a moderator reviews them; the comments of the moderators of the post aren't checked.",
+		Tags:        []string{"comments"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.CreateCommentRequest{},
+		Status:      http.StatusCreated,
+		Response:    commentCreated{},
+	},
+	"POST /posts/{postId}/comments/guest": {
+		Summary:     "Comment on a post without an account",
+		Description: "Answers 403 unless comments.guests.enabled is set. Guests give their name and email address, leave the website field empty, and send the token of the CAPTCHA challenge they solved in captcha_token when comments.guests.captcha.provider is set. Each IP address can send comments.guests.requests comments per comments.guests.window, answering 429 past it. The email address of a registered account answers 409: its owner must log in to comment. Guest comments are hidden, awaiting the approval of a moderator, unless comments.guests.moderated is off.",
+		Tags:        []string{"comments"},
+		Request:     handlers.CreateGuestCommentRequest{},
+		Status:      http.StatusCreated,
+	},
+	"GET /comments/{id}/replies": {
+		Summary: "List the direct replies to a comment",
+		Tags:    []string{"comments"},
+		Auth:    openapi.AuthOptional,
+		Response: struct {
+			Replies []models.Comment `json:"replies"`
+		}{},
+	},
+	"POST /comments/{id}/like": {
+		Summary:  "Like a comment",
+		Tags:     []string{"comments"},
+		Auth:     openapi.AuthRequired,
+		Response: likeState{},
+	},
+	"DELETE /comments/{id}/like": {
+		Summary:  "Remove a like from a comment",
+		Tags:     []string{"comments"},
+		Auth:     openapi.AuthRequired,
+		Response: likeState{},
+	},
+	"POST /comments/{id}/report": {
+		Summary:     "Report a comment",
+		Description: "Reasons are spam, harassment, hate_speech, off_topic and other. A comment is hidden once its open reports reach the configured threshold, until a moderator reviews it.",
+		Tags:        []string{"comments"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.ReportCommentRequest{},
+		Status:      http.StatusCreated,
+		Response: struct {
+			Message string `json:"message"`
+		}{},
+	},
+	"POST /integrations/{id}/comments": {
+		Summary:     "Receive a comment from an integration",
+		Description: "Inbound webhook of external services, such as an email-to-comment bridge or a comment importer. The payload is signed like the outgoing webhooks, and fields it doesn't define are rejected. Comments are attributed to the account of the author's email address, created without a password when missing. A comment whose external_id was already received isn't created again and is replied with 200.",
+		Tags:        []string{"integrations"},
+		Query: []openapi.Parameter{{
+			Name:        webhooks.SignatureHeader,
+			In:          "header",
+			Required:    true,
+			Description: "\"sha256=\" followed by the hex encoded HMAC-SHA256 of the raw body, keyed with the secret of the integration",
+			Schema:      &openapi.Schema{Type: "string"},
+		}},
+		Request: services.InboundComment{},
+		Status:  http.StatusCreated,
+		Response: struct {
+			Message string `json:"message"`
+			Comment struct {
+				ID         string `json:"id"`
+				ExternalID string `json:"external_id"`
+				PostID     string `json:"post_id"`
+				ParentID   string `json:"parent_id,omitempty"`
+				UserID     string `json:"user_id"`
+				Status     string `json:"status"`
+			} `json:"comment"`
+		}{},
+	},
+	"PATCH /comments/{id}/status": {
+		Summary:     "Moderate a comment",
+		Description: "Hidden comments are only listed for admins and the author of the post. Admins can moderate every comment, and authors the comments on their posts.",
+		Tags:        []string{"comments"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.ModerateCommentRequest{},
+		Response: struct {
+			Message string         `json:"message"`
+			Comment models.Comment `json:"comment"`
+		}{},
+	},
+	"GET /admin/moderation/comments": {
+		Summary:     "List the hidden and reported comments awaiting review",
+		Description: "Comments with the most open reports come first, along with their open reports. Moderating a comment resolves its reports.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Query:       pageParameters,
+		Response: struct {
+			Comments   []models.Comment `json:"comments"`
+			Pagination pagination       `json:"pagination"`
+		}{},
+	},
+	"GET /admin/tasks": {
+		Summary:     "List the maintenance tasks and their recent runs",
+		Description: "Tasks are recount-comments, refresh-leaderboards, purge-trash and reindex-search.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Response: struct {
+			Tasks []services.Task    `json:"tasks"`
+			Runs  []services.TaskRun `json:"runs"`
+		}{},
+	},
+	"POST /admin/tasks/{name}": {
+		Summary:     "Run a maintenance task",
+		Description: "The task runs in the background. Poll the run at the URL of the Location header for its status. Returns 409 while the task is already running.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Status:      http.StatusAccepted,
+		Response:    services.TaskRun{},
+	},
+	"GET /admin/tasks/runs/{id}": {
+		Summary:     "Get the status of a task run",
+		Description: "Only the most recent runs are kept, and none survive a restart.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Response:    services.TaskRun{},
+	},
+	"POST /admin/import": {
+		Summary:     "Import content from another platform",
+		Description: "Multipart form with a WordPress WXR export, a Ghost JSON export or a zip of Markdown files with frontmatter in the \"file\" field. The posts, their comments and their authors are imported in the background: poll the run at the URL of the Location header for its status. Posts whose slug is taken are skipped. Authors and commenters are matched to the accounts by email address, and the missing accounts are created without a password. Returns 400 when the file can't be read, and 409 while another import is running.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Status:      http.StatusAccepted,
+		Response:    services.TaskRun{},
+	},
+	"GET /admin/import/{id}": {
+		Summary:     "Get the status of an import",
+		Description: "The result summarizes what was imported once the import succeeded. Only the most recent runs are kept, and none survive a restart.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Response:    services.TaskRun{},
+	},
+	"GET /admin/export": {
+		Summary:     "Export the posts, comments and users",
+		Description: "Streams a zip archive. JSON archives hold an export.json file with the users, posts and comments. Markdown archives hold a Markdown file with frontmatter per post, which POST /admin/import reads back, along with users.json and comments.json. Credentials are never exported. Blogs too large to be exported within the request timeout are exported through POST /admin/export.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Query:       []openapi.Parameter{exportFormatParameter},
+		ContentType: "application/zip",
+	},
+	"POST /admin/export": {
+		Summary:     "Export the posts, comments and users in the background",
+		Description: "Generates the archive of GET /admin/export in the background: poll the run at the URL of the Location header for its status, then download the archive. Only the archive of the latest export is kept, and none survive a restart. Returns 409 while another export is running.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+		Query:       []openapi.Parameter{exportFormatParameter},
+		Status:      http.StatusAccepted,
+		Response:    services.TaskRun{},
+	},
+	"GET /admin/export/{id}": {
+		Summary:  "Get the status of a background export",
+		Tags:     []string{"admin"},
+		Auth:     openapi.AuthRequired,
+		Response: services.TaskRun{},
+	},
+	"GET /admin/export/{id}/download": {
+		Summary:     "Download the archive of a background export",
+		Description: "Returns 404 until the export succeeded, and once a newer export replaced its archive.",
+		Tags:        []string{"admin"},
+		Auth:        openapi.AuthRequired,
+	},
+
+	// Tags
+	"GET /tags": {
+		Summary: "List tags with their post counts",
+		Tags:    []string{"tags"},
+		Response: struct {
+			Tags []models.Tag `json:"tags"`
+		}{},
+	},
+	"GET /tags/{slug}/posts": {
+		Summary:     "List the posts of a tag",
+		Description: geoRestricted,
+		Tags:        []string{"tags"},
+		Auth:        openapi.AuthOptional,
+		Query:       pageParameters,
+		Response:    tagPostList{},
+	},
+
+	// Categories
+	"GET /categories": {
+		Summary:     "List the categories as a tree",
+		Description: "Top-level categories hold their subcategories in children, at every depth. The post count of a category leaves out the posts of its subcategories.",
+		Tags:        []string{"categories"},
+		Response: struct {
+			Categories []models.Category `json:"categories"`
+		}{},
+	},
+	"GET /categories/{slug}": {
+		Summary:     "Get a category",
+		Description: "With its subcategories in children, at every depth. Its posts are listed by GET /posts with the category parameter.",
+		Tags:        []string{"categories"},
+		Response:    models.Category{},
+	},
+
+	// Series
+	"POST /series": {
+		Summary:     "Create a series",
+		Description: "A series groups posts of its author in order, such as the parts of a tutorial. The slug is generated from the title when empty. The series is created empty; posts are added by POST /series/{id}/posts.",
+		Tags:        []string{"series"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.SeriesRequest{},
+		Status:      http.StatusCreated,
+		Response:    seriesWritten{},
+	},
+	"GET /series/{slug}": {
+		Summary:     "Get a series",
+		Description: "With its posts in order. Drafts are only listed to the author of the series, and posts in early access to members. GET /posts/{id} places a post in its series, with links to the posts before and after it.",
+		Tags:        []string{"series"},
+		Auth:        openapi.AuthOptional,
+		Response:    models.Series{},
+	},
+	"PUT /series/{id}": {
+		Summary:     "Update a series",
+		Description: "Only the author of a series can change it.",
+		Tags:        []string{"series"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.SeriesRequest{},
+		Response:    seriesWritten{},
+	},
+	"DELETE /series/{id}": {
+		Summary:     "Delete a series",
+		Description: "Its posts are kept, out of any series.",
+		Tags:        []string{"series"},
+		Auth:        openapi.AuthRequired,
+		Response:    message{},
+	},
+	"POST /series/{id}/posts": {
+		Summary:     "Add a post to a series",
+		Description: "Only posts of the author of the series can be added, and a post belongs to at most one series, answering 409 otherwise. The post is inserted at position, counted from 1, or last when omitted.",
+		Tags:        []string{"series"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.AddSeriesPostRequest{},
+		Response:    seriesWritten{},
+	},
+	"PUT /series/{id}/posts": {
+		Summary:     "Reorder the posts of a series",
+		Description: "post_ids lists every post of the series once, in their new order.",
+		Tags:        []string{"series"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.ReorderSeriesRequest{},
+		Response:    seriesWritten{},
+	},
+	"DELETE /series/{id}/posts/{postId}": {
+		Summary:     "Remove a post from a series",
+		Description: "The post itself is kept.",
+		Tags:        []string{"series"},
+		Auth:        openapi.AuthRequired,
+		Response:    seriesWritten{},
+	},
+
+	// Uploads
+	"POST /uploads": {
+		Summary:     "Upload an image",
+		Description: "Multipart form with the image in the \"file\" field. The URL of the upload contains the hash of its content, returned in content_hash, so uploads are served with immutable caching and new content always gets a new URL. When uploads.variants.enabled is set, JPEG, PNG and WebP images are downscaled in the background to the configured sizes narrower than they are, with lossless WebP versions, and variants_pending is true. Once generated, the variants are listed with srcset strings in the featured_image_set of the posts and the profile_picture_set of the users using the image.",
+		Tags:        []string{"uploads"},
+		Auth:        openapi.AuthRequired,
+		Status:      http.StatusCreated,
+	},
+
+	// Link previews
+	"GET /unfurl": {
+		Summary:     "Get the preview of a link",
+		Description: "Returns the OpenGraph or Twitter card metadata of a web page, for link previews in the editor and in comments. Previews are cached. Only authenticated users can have new pages fetched, other clients get 404 when no preview is cached. Pages on private networks or ports other than 80 and 443 are never fetched. Returns 502 when the page can't be previewed.",
+		Tags:        []string{"previews"},
+		Auth:        openapi.AuthOptional,
+		Query:       []openapi.Parameter{{Name: "url", Required: true, Description: "Absolute http or https URL of the page", Schema: &openapi.Schema{Type: "string"}}},
+		Response: struct {
+			Preview models.LinkPreview `json:"preview"`
+		}{},
+	},
+
+	// Embeds
+	"POST /embed-tokens": {
+		Summary:     "Issue an embed token",
+		Description: "Issues a signed, expiring token letting an external site embed a published post, or a comment thread with its replies, without an API key. Only the author of the post and admins can issue them. A token bound to an origin can only be used by the pages of that site. Tokens can't be revoked, so keep their lifetime short when embedding on sites you don't control.",
+		Tags:        []string{"embeds"},
+		Auth:        openapi.AuthRequired,
+		Request:     handlers.CreateEmbedTokenRequest{},
+		Status:      http.StatusCreated,
+		Response: struct {
+			Token     services.EmbedToken `json:"token"`
+			EmbedURL  string              `json:"embed_url"`
+			APIURL    string              `json:"api_url"`
+			OEmbedURL string              `json:"oembed_url,omitempty"`
+		}{},
+	},
+	"GET /embed": {
+		Summary:     "Get embedded content",
+		Description: "Returns the post or comment thread an embed token gives access to, with only its public fields. Readable across origins by the site the token is bound to. Returns 401 for invalid or expired tokens, 403 when used by another site, and 404 once the content is unpublished or hidden. " + geoRestricted,
+		Tags:        []string{"embeds"},
+		Query:       []openapi.Parameter{{Name: "token", Required: true, Description: "Embed token", Schema: &openapi.Schema{Type: "string"}}},
+	},
+	"GET /oembed": {
+		Summary:     "oEmbed provider",
+		Description: "Returns the oEmbed rich response embedding the URL given: a card linking to the post for the public URL of a published post, or an iframe for an embed URL. The card fits the maxwidth and maxheight asked for. Responses can be cached for cache_age seconds, as told by their Cache-Control header. Only the json format is supported, others get 501. " + geoRestricted,
+		Tags:        []string{"embeds"},
+		Query: []openapi.Parameter{
+			{Name: "url", Required: true, Description: "Public URL of a post, or embed URL as returned when issuing an embed token", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "format", Description: "json, the only format supported", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "maxwidth", Description: "Maximum width of the iframe", Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "maxheight", Description: "Maximum height of the iframe", Schema: &openapi.Schema{Type: "integer"}},
+		},
+	},
+
+	// Email provider notifications
+	"POST /email/feedback/ses": {
+		Summary:     "Receive the bounce and complaint notifications of Amazon SES",
+		Description: "Endpoint of the HTTPS subscription of the SNS topic of the SES notifications, served when email.feedback.token is set. Addresses that bounced permanently or complained aren't sent emails anymore. The subscription is confirmed on its first message.",
+		Tags:        []string{"email"},
+		Query:       []openapi.Parameter{{Name: "token", Required: true, Description: "The configured email.feedback.token", Schema: &openapi.Schema{Type: "string"}}},
+		ContentType: "text/plain",
+	},
+	"POST /email/feedback/sendgrid": {
+		Summary:     "Receive the bounce and spam report events of SendGrid",
+		Description: "Endpoint of the SendGrid Event Webhook, served when email.feedback.token is set. Addresses that bounced or reported spam aren't sent emails anymore; temporarily blocked bounces are ignored.",
+		Tags:        []string{"email"},
+		Query:       []openapi.Parameter{{Name: "token", Required: true, Description: "The configured email.feedback.token", Schema: &openapi.Schema{Type: "string"}}},
+		ContentType: "text/plain",
+	},
+
+	// Community
+	"GET /leaderboards": {
+		Summary:     "Get the top authors and commenters",
+		Description: "Only served when leaderboards are enabled. Authors are ranked on the views and likes of the posts they published during the window, and commenters on the comments they wrote during it. Leaderboards are refreshed periodically.",
+		Tags:        []string{"community"},
+		Query: []openapi.Parameter{
+			{Name: "window", Description: "One of week (the default), month, year or all", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Response: struct {
+			Leaderboard services.Leaderboard `json:"leaderboard"`
+		}{},
+	},
+
+	// Feeds and crawlers
+	"GET /graphql": {
+		Summary:     "Run a GraphQL query",
+		Description: "Served when graphql.enabled is set. The query is sent in the query string. See POST /graphql. " + geoRestricted,
+		Tags:        []string{"graphql"},
+		Auth:        openapi.AuthOptional,
+		Query: []openapi.Parameter{
+			{Name: "query", Description: "GraphQL query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+			{Name: "operationName", Description: "Operation to run, when the query has several", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "variables", Description: "Variables of the query, as a JSON object", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Response: graphqlResponse{},
+	},
+	"POST /graphql": {
+		Summary:     "Run a GraphQL query",
+		Description: "Served when graphql.enabled is set. Queries the posts, comments and users with the access rules of the REST API, read from the schema by introspection unless graphql.introspection is off. Nested authors, tags, comments and posts are loaded in batches. Queries selecting more than graphql.complexity_limit fields are refused. Errors are listed in the errors field of a 200 response, and responses are never enveloped or converted to another field case. " + geoRestricted,
+		Tags:        []string{"graphql"},
+		Auth:        openapi.AuthOptional,
+		Request:     graphqlRequest{},
+		Response:    graphqlResponse{},
+	},
+	"GET /feed.xml": {
+		Summary:     "RSS feed of the latest posts",
+		Description: geoRestricted,
+		Tags:        []string{"feeds"},
+		ContentType: "application/rss+xml",
+	},
+	"GET /sitemap.xml": {
+		Summary:     "Sitemap of the published posts and tags",
+		Description: "Translated posts are listed in each of their languages, the translations at the URL of the post with lang set to their locale, every version linking to the others with xhtml:link hreflang annotations and the post as written being the x-default.",
+		Tags:        []string{"feeds"},
+		ContentType: "application/xml",
+	},
+	"GET /robots.txt": {
+		Summary:     "Crawler rules",
+		Tags:        []string{"feeds"},
+		ContentType: "text/plain",
+	},
+	"GET /.well-known/security.txt": {
+		Summary:     "Security contact",
+		Tags:        []string{"well-known"},
+		ContentType: "text/plain",
+	},
+	"GET /.well-known/change-password": {
+		Summary: "Redirect to the change password page",
+		Tags:    []string{"well-known"},
+		Status:  http.StatusFound,
+	},
+	"GET /.well-known/jwks.json": {
+		Summary:     "Public keys verifying tokens",
+		Description: "The JSON Web Key Set of the asymmetric keys tokens are signed with, matched by the kid header of tokens. Empty when tokens are signed with the shared secret, with HS256.",
+		Tags:        []string{"well-known"},
+		Response:    jwtkeys.JWKS{},
+	},
+
+	// Documentation
+	"GET /openapi.json": {
+		Summary:     "This OpenAPI specification",
+		Tags:        []string{"system"},
+		ContentType: "application/vnd.oai.openapi+json",
+	},
+	"GET /docs": {
+		Summary:     "Swagger UI",
+		Tags:        []string{"system"},
+		ContentType: "text/html",
+	},
+}
@@ -0,0 +1,121 @@
+// Package apperrors writes the JSON error responses shared by all handlers.
+//
+// Every error response has the same shape:
+//
+//	{"error": {"code": "not_found", "message": "Post not found", "request_id": "..."}}
+//
+// Messages are translated to the language of the Accept-Language header of
+// the request when the message catalog of the i18n package has them, and
+// left in English otherwise. Codes are never translated.
+package apperrors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/SteaceP/coderage/i18n"
+	"github.com/SteaceP/coderage/types"
+)
+
+// StatusClientClosedRequest is the status of the requests whose client went
+// away before the reply, as logged by nginx. No body is written for them.
+const StatusClientClosedRequest = 499
+
+// Response is the body of an error response.
+type Response struct {
+	Error Body `json:"error"`
+}
+
+// Body describes an error.
+type Body struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"` // Code specific data, such as the issues of a post that failed linting
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// Error replies to the request with the given message and status code. It is
+// a drop-in replacement for http.Error, using the code derived from the
+// status.
+func Error(w http.ResponseWriter, r *http.Request, message string, status int) {
+	WriteCode(w, r, status, CodeFor(status), message)
+}
+
+// Errorf replies to the request with a message formatted with its arguments
+// in the fmt syntax, using the code derived from the status. The message is
+// translated before it is formatted.
+func Errorf(w http.ResponseWriter, r *http.Request, status int, format string, args ...interface{}) {
+	write(w, r, status, CodeFor(status), i18n.M(format, args...), nil)
+}
+
+// WriteCode replies to the request with an error of the given code.
+func WriteCode(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	WriteDetails(w, r, status, code, message, nil)
+}
+
+// WriteDetails replies to the request with an error of the given code,
+// along with details for the client to act on.
+//
+// Internal errors of requests whose context is done are most likely caused
+// by their queries being cancelled: they are replied as 504 when the
+// request timed out, and with StatusClientClosedRequest when the client
+// went away.
+func WriteDetails(w http.ResponseWriter, r *http.Request, status int, code, message string, details interface{}) {
+	write(w, r, status, code, i18n.M(message), details)
+}
+
+// write replies to the request with an error, translating its message.
+func write(w http.ResponseWriter, r *http.Request, status int, code string, message i18n.Message, details interface{}) {
+	if status == http.StatusInternalServerError {
+		switch err := r.Context().Err(); {
+		case errors.Is(err, context.DeadlineExceeded):
+			status, code, message, details = http.StatusGatewayTimeout, "timeout", i18n.M("Request timed out"), nil
+		case errors.Is(err, context.Canceled):
+			w.WriteHeader(StatusClientClosedRequest)
+			return
+		}
+	}
+
+	body := Body{
+		Code:      code,
+		Message:   message.In(i18n.FromRequest(r)),
+		Details:   details,
+		RequestID: RequestID(r),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Add("Vary", "Accept-Language")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{Error: body})
+}
+
+// CodeFor returns the error code of an HTTP status, e.g. "not_found" for
+// 404.
+func CodeFor(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.ReplaceAll(text, " ", "_"))
+}
+
+// RequestID returns the ID assigned to the request, or an empty string if it
+// has none.
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(types.KeyRequestID).(string)
+	return id
+}
+
+// NotFound replies to the request with a 404 error.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, "Not found", http.StatusNotFound)
+}
+
+// MethodNotAllowed replies to the request with a 405 error.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/demo"
+	"github.com/SteaceP/coderage/jwtkeys"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// authTokens are the tokens returned by a login or a refresh.
+type authTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// authLogin logs in as the demo reader, and returns their tokens.
+func authLogin(t *testing.T, baseURL string) authTokens {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"email": demo.ReaderEmail, "password": config.Get().Demo.Password})
+	resp, err := http.Post(baseURL+"/users/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var tokens authTokens
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil || tokens.Token == "" || tokens.RefreshToken == "" {
+		t.Fatalf("login failed with status %d", resp.StatusCode)
+	}
+	return tokens
+}
+
+// authRefresh posts a refresh token, and returns the response status and
+// the new tokens.
+func authRefresh(t *testing.T, baseURL, refreshToken string) (int, authTokens) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	resp, err := http.Post(baseURL+"/auth/refresh", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var tokens authTokens
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, tokens
+}
+
+// authRequest sends a request with the given bearer token, and returns the
+// response status.
+func authRequest(t *testing.T, method, url, token string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// TestRefreshTokenRotation checks that a refresh replaces the refresh token,
+// and that reusing the replaced one revokes the session.
+func TestRefreshTokenRotation(t *testing.T) {
+	server, _ := newContractServer(t)
+	login := authLogin(t, server.URL)
+
+	status, refreshed := authRefresh(t, server.URL, login.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("refresh answered %d", status)
+	}
+	if status := authRequest(t, "GET", server.URL+"/users/profile", refreshed.Token); status != http.StatusOK {
+		t.Fatalf("the refreshed access token was answered %d", status)
+	}
+
+	if status, _ := authRefresh(t, server.URL, login.RefreshToken); status != http.StatusUnauthorized {
+		t.Fatalf("reusing a replaced refresh token answered %d", status)
+	}
+	if status := authRequest(t, "GET", server.URL+"/users/profile", refreshed.Token); status != http.StatusUnauthorized {
+		t.Errorf("the access token of a session revoked for reuse was answered %d", status)
+	}
+	if status, _ := authRefresh(t, server.URL, refreshed.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("the refresh token of a session revoked for reuse answered %d", status)
+	}
+}
+
+// TestTokenTypes checks that refresh tokens don't authenticate requests, and
+// that access tokens can't refresh a session nor revoke it.
+func TestTokenTypes(t *testing.T) {
+	server, _ := newContractServer(t)
+	login := authLogin(t, server.URL)
+
+	if status := authRequest(t, "GET", server.URL+"/users/profile", login.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("a refresh token used as bearer token was answered %d", status)
+	}
+	if status, _ := authRefresh(t, server.URL, login.Token); status != http.StatusUnauthorized {
+		t.Errorf("refreshing with an access token answered %d", status)
+	}
+
+	// The session wasn't revoked as if the refresh token was reused
+	if status := authRequest(t, "GET", server.URL+"/users/profile", login.Token); status != http.StatusOK {
+		t.Errorf("the access token was answered %d", status)
+	}
+	if status, _ := authRefresh(t, server.URL, login.RefreshToken); status != http.StatusOK {
+		t.Errorf("the refresh token answered %d", status)
+	}
+}
+
+// TestTokenRevocation checks that logging out everywhere rejects the tokens
+// of the sessions, and the access tokens issued before sessions.
+func TestTokenRevocation(t *testing.T) {
+	server, _ := newContractServer(t)
+	login := authLogin(t, server.URL)
+
+	var profile struct {
+		ID string `json:"id"`
+	}
+	req, _ := http.NewRequest("GET", server.URL+"/users/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&profile)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("profile failed with status %d", resp.StatusCode)
+	}
+	userID, err := strconv.ParseUint(profile.ID, 10, 64)
+	if err != nil {
+		t.Fatalf("profile has the ID %q", profile.ID)
+	}
+
+	// An access token issued before sessions, with neither a session, a
+	// version nor a type
+	legacy, err := jwtkeys.Current().Sign(jwt.MapClaims{
+		"user_id":    userID,
+		"authorized": true,
+		"exp":        time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := authRequest(t, "GET", server.URL+"/users/profile", legacy); status != http.StatusOK {
+		t.Fatalf("the access token issued before sessions was answered %d", status)
+	}
+
+	if status := authRequest(t, "DELETE", server.URL+"/users/me/sessions", login.Token); status >= 300 {
+		t.Fatalf("logging out everywhere answered %d", status)
+	}
+	if status := authRequest(t, "GET", server.URL+"/users/profile", login.Token); status != http.StatusUnauthorized {
+		t.Errorf("the access token of a revoked session was answered %d", status)
+	}
+	if status, _ := authRefresh(t, server.URL, login.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("the refresh token of a revoked session answered %d", status)
+	}
+	if status := authRequest(t, "GET", server.URL+"/users/profile", legacy); status != http.StatusUnauthorized {
+		t.Errorf("the access token issued before sessions was answered %d after logging out everywhere", status)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/database"
+	"github.com/SteaceP/coderage/logging"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// bootstrap loads the configuration, in demo mode if demo is set, creates
+// the logger and connects to the database: the steps shared by the server
+// and the commands working on the database. The caller syncs the logger.
+func bootstrap(demo bool) (*config.Config, *zap.Logger, *gorm.DB, error) {
+	// Load configuration
+	config.InitConfig()
+	if demo {
+		config.EnableDemo()
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("configuration loading failed: %v", err)
+	}
+
+	// Initialize logger
+	logger, err := logging.New()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to initialize logger: %v", err)
+	}
+
+	// Initialize database
+	db, err := database.InitDatabase(logger)
+	if err != nil {
+		logger.Sync()
+		return nil, nil, nil, fmt.Errorf("database initialization failed: %v", err)
+	}
+	return cfg, logger, db, nil
+}
@@ -0,0 +1,95 @@
+// Package captcha verifies the challenges solved by clients with a CAPTCHA
+// service: Cloudflare Turnstile, hCaptcha or reCAPTCHA, which share the same
+// verification API.
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+)
+
+// ErrFailed is returned when a challenge wasn't solved.
+var ErrFailed = errors.New("CAPTCHA challenge failed")
+
+// verifyURLs are the verification endpoints of the providers
+var verifyURLs = map[string]string{
+	"turnstile": "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+	"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+}
+
+// Verifier verifies the tokens of the challenges solved by clients.
+type Verifier struct {
+	url    string
+	secret string
+	http   *http.Client
+}
+
+// New returns the verifier of the provider of the configuration, or nil when
+// none is set.
+func New(cfg config.CaptchaConfig) (*Verifier, error) {
+	if cfg.Provider == "" {
+		return nil, nil
+	}
+	verifyURL, ok := verifyURLs[cfg.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unsupported CAPTCHA provider: %s", cfg.Provider)
+	}
+	return &Verifier{
+		url:    verifyURL,
+		secret: cfg.Secret,
+		http:   &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Verify checks the token of a challenge solved by the client of an IP
+// address, and returns ErrFailed when it wasn't solved.
+func (v *Verifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if strings.TrimSpace(token) == "" {
+		return ErrFailed
+	}
+
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CAPTCHA verification returned %s", resp.Status)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid CAPTCHA verification response: %v", err)
+	}
+	if !result.Success {
+		// Errors of the site configuration aren't the client's fault
+		for _, code := range result.ErrorCodes {
+			if strings.Contains(code, "secret") {
+				return fmt.Errorf("CAPTCHA verification rejected the secret: %s", code)
+			}
+		}
+		return ErrFailed
+	}
+	return nil
+}
@@ -1,29 +1,380 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
 
 	"github.com/spf13/viper"
 )
 
+// envPrefix prefixes the environment variables overriding the configuration
+const envPrefix = "CODERAGE"
+
+// current is the configuration in effect
+var current atomic.Pointer[Config]
+
+func init() {
+	current.Store(defaultConfig())
+}
+
+// Get returns the configuration in effect. The returned configuration must
+// not be modified; it is replaced as a whole when the file is reloaded.
+func Get() *Config {
+	return current.Load()
+}
+
+// Set replaces the configuration in effect.
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
+
+// Load reads the configuration file and the environment, validates the
+// result and makes it the configuration in effect. The file is optional in
+// demo mode.
+func Load() (*Config, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) || !viper.GetBool("demo.enabled") {
+			return nil, fmt.Errorf("error reading configuration file: %v", err)
+		}
+	}
+	cfg, err := decode(viper.GetViper())
+	if err != nil {
+		return nil, err
+	}
+	Set(cfg)
+	return cfg, nil
+}
+
+// InitConfig prepares the loading of config.yaml, from the working directory
+// or its config directory, with the defaults and the environment overrides.
 func InitConfig() {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 	viper.AddConfigPath("./config")
 
-	// Default configurations
-	viper.SetDefault("server.port", "8080")
-	viper.SetDefault("server.environment", "development")
-	viper.SetDefault("database.type", "postgres")
-	viper.SetDefault("jwt.secret", "your-secret-key")
-	viper.SetDefault("jwt.expiration", 24)
-	viper.SetDefault("logLevel", "info")
-	viper.SetDefault("cors.allowed_origins", []string{"*"})
-
-	// Read config
-	err := viper.ReadInConfig()
-	if err != nil {
-		log.Fatalf("Error reading configuration file: %v", err)
+	setDefaults(viper.GetViper())
+	bindEnv(viper.GetViper())
+}
+
+// EnableDemo switches to the demo mode, for trying the API without any
+// external service: the database is a SQLite database held in memory,
+// uploads are stored in a temporary directory, and the features relying on
+// other services are turned off, along with the sandbox mode, which would
+// hold the single connection of the database. No email or webhook is sent. It must be
+// called after InitConfig and before Load, and overrides the file and the
+// environment.
+func EnableDemo() {
+	viper.Set("demo.enabled", true)
+	viper.Set("database.type", "sqlite")
+	viper.Set("database.name", "file:demo?mode=memory&cache=shared")
+	viper.Set("database.breaker.enabled", false)
+	viper.Set("database.replicas.hosts", []ReplicaConfig{})
+	viper.Set("events.driver", "inprocess")
+	viper.Set("presence.driver", "memory")
+	viper.Set("storage.driver", "local")
+	viper.Set("storage.local.path", filepath.Join(os.TempDir(), "coderage-demo"))
+	viper.Set("webhooks.events", []string{})
+	viper.Set("search.engine.driver", "")
+	viper.Set("email.feedback.token", "")
+	viper.Set("sandbox.enabled", false)
+	viper.Set("alerts.enabled", false)
+	viper.Set("tracing.enabled", false)
+	viper.Set("geoip.database_path", "")
+}
+
+// decode returns the validated configuration held by v.
+func decode(v *viper.Viper) (*Config, error) {
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// defaultConfig returns the configuration made of the defaults only, in
+// effect until the configuration is loaded.
+func defaultConfig() *Config {
+	v := viper.New()
+	setDefaults(v)
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		panic(fmt.Sprintf("invalid configuration defaults: %v", err))
 	}
+	return &cfg
+}
+
+// bindEnv binds every key of the configuration to its environment variable,
+// so keys missing from the file can be set from the environment too.
+func bindEnv(v *viper.Viper) {
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	walkKeys(func(key string, _ reflect.StructField) {
+		v.BindEnv(key)
+	})
+}
+
+// walkKeys calls fn with every key of the configuration and the field
+// holding it. Sections are walked rather than passed to fn.
+func walkKeys(fn func(key string, field reflect.StructField)) {
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := prefix + field.Tag.Get("mapstructure")
+			if field.Type.Kind() == reflect.Struct && field.Type.PkgPath() == t.PkgPath() {
+				walk(field.Type, key+".")
+				continue
+			}
+			fn(key, field)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+}
+
+// setDefaults sets the value of the keys missing from the file.
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.port", "8080")
+	v.SetDefault("server.environment", "development")
+	v.SetDefault("server.base_url", "http://localhost:8080")
+	v.SetDefault("server.timeouts.default", "10s")
+	v.SetDefault("server.timeouts.admin", "1m")
+	v.SetDefault("server.tls.enabled", false)
+	v.SetDefault("server.tls.autocert.cache_dir", "./certs")
+	v.SetDefault("server.tls.redirect_port", "")
+	v.SetDefault("database.type", "postgres")
+	v.SetDefault("database.connect_retries", 10)
+	v.SetDefault("database.connect_backoff", "1s")
+	v.SetDefault("database.connect_max_backoff", "30s")
+	v.SetDefault("database.breaker.enabled", true)
+	v.SetDefault("database.breaker.failure_threshold", 5)
+	v.SetDefault("database.breaker.check_interval", "5s")
+	v.SetDefault("database.replicas.hosts", []ReplicaConfig{})
+	v.SetDefault("database.replicas.check_interval", "5s")
+	v.SetDefault("database.slow_query_threshold", "200ms")
+	v.SetDefault("database.query_plans.enabled", false)
+	v.SetDefault("database.query_plans.analyze", true)
+	v.SetDefault("database.query_plans.min_duration", "0s")
+	v.SetDefault("database.query_plans.max_queries", 20)
+	v.SetDefault("database.query_plans.routes", []string{})
+	v.SetDefault("jwt.secret", "your-secret-key")
+	v.SetDefault("jwt.expiration_hours", 24)
+	v.SetDefault("jwt.keys", []JWTKeyConfig{})
+	v.SetDefault("jwt.secret_valid_until", "")
+	v.SetDefault("cors.allowed_origins", []string{"*"})
+	v.SetDefault("api.envelope", false)
+	v.SetDefault("api.field_case", "snake")
+	v.SetDefault("api.version", 1)
+	v.SetDefault("graphql.enabled", false)
+	v.SetDefault("graphql.complexity_limit", 500)
+	v.SetDefault("graphql.introspection", true)
+	v.SetDefault("logging.level", "info")
+	v.SetDefault("logging.format", "console")
+	v.SetDefault("logging.output_path", []string{"stdout"})
+	v.SetDefault("logging.sampling.initial", 100)
+	v.SetDefault("logging.sampling.thereafter", 100)
+	v.SetDefault("logging.rotation.max_size_mb", 100)
+	v.SetDefault("logging.rotation.max_backups", 5)
+	v.SetDefault("logging.rotation.max_age_days", 30)
+	v.SetDefault("logging.rotation.compress", true)
+	v.SetDefault("logging.redact", true)
+	v.SetDefault("site.title", "Coderage")
+	v.SetDefault("site.description", "")
+	v.SetDefault("site.url", "http://localhost:3000")
+	v.SetDefault("site.default_license", "all-rights-reserved")
+	v.SetDefault("site.security_contact", "")
+	v.SetDefault("site.primary_color", "")
+	v.SetDefault("site.accent_color", "")
+	v.SetDefault("site.timezone", "UTC")
+	v.SetDefault("site.date_format", "iso")
+	v.SetDefault("site.locale", "en")
+	v.SetDefault("i18n.bundles_dir", "")
+	v.SetDefault("site.change_password_path", "/settings/password")
+	v.SetDefault("features.user_registration", true)
+	v.SetDefault("features.require_approval", false)
+	v.SetDefault("features.comments_enabled", true)
+	v.SetDefault("features.sensitive_gating", true)
+	v.SetDefault("features.referrals", true)
+	v.SetDefault("email.provider", "smtp")
+	v.SetDefault("email.smtp_port", 587)
+	v.SetDefault("email.queue.enabled", true)
+	v.SetDefault("email.queue.workers", 2)
+	v.SetDefault("email.queue.size", 1000)
+	v.SetDefault("email.queue.max_attempts", 5)
+	v.SetDefault("email.queue.backoff", "5s")
+	v.SetDefault("email.verification_ttl_hours", 24)
+	v.SetDefault("email.feedback.token", "")
+	v.SetDefault("storage.driver", "local")
+	v.SetDefault("storage.local.path", "./uploads")
+	v.SetDefault("storage.local.base_url", "http://localhost:8080/media")
+	v.SetDefault("uploads.max_size_mb", 10)
+	v.SetDefault("uploads.allowed_types", []string{"image/jpeg", "image/png", "image/gif", "image/webp"})
+	v.SetDefault("uploads.daily_quota", 50)
+	v.SetDefault("uploads.variants.enabled", true)
+	v.SetDefault("uploads.variants.sizes", []map[string]interface{}{
+		{"name": "thumbnail", "width": 150},
+		{"name": "medium", "width": 600},
+		{"name": "large", "width": 1200},
+	})
+	v.SetDefault("uploads.variants.webp", true)
+	v.SetDefault("uploads.variants.quality", 85)
+	v.SetDefault("posts.early_access_window", "72h")
+	v.SetDefault("posts.counts.mode", "exact")
+	v.SetDefault("posts.counts.cache_ttl", "30s")
+	v.SetDefault("posts.lint.featured_image", "advisory")
+	v.SetDefault("posts.lint.excerpt", "advisory")
+	v.SetDefault("posts.lint.internal_links", "blocking")
+	v.SetDefault("posts.lint.headings", "advisory")
+	v.SetDefault("posts.lint.meta_description", "advisory")
+	v.SetDefault("posts.lint.meta_description_length", 155)
+	v.SetDefault("views.dedup_window", "30m")
+	v.SetDefault("views.ignored_agents", []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "headless", "preview"})
+	v.SetDefault("presence.enabled", true)
+	v.SetDefault("presence.driver", "memory")
+	v.SetDefault("presence.heartbeat", "15s")
+	v.SetDefault("presence.ttl", "45s")
+	v.SetDefault("presence.max_streams", 1000)
+	v.SetDefault("presence.redis.addr", "localhost:6379")
+	v.SetDefault("presence.redis.password", "")
+	v.SetDefault("presence.redis.db", 0)
+	v.SetDefault("presence.redis.key_prefix", "coderage")
+	v.SetDefault("discovery.cache_ttl", "10m")
+	v.SetDefault("discovery.trending.window", "168h")
+	v.SetDefault("discovery.trending.half_life", "24h")
+	v.SetDefault("discovery.trending.view_weight", 1)
+	v.SetDefault("discovery.trending.like_weight", 5)
+	v.SetDefault("discovery.trending.comment_weight", 10)
+	v.SetDefault("discovery.trending.size", 10)
+	v.SetDefault("discovery.related.size", 5)
+	v.SetDefault("discovery.related.text_similarity", true)
+	v.SetDefault("comments.max_depth", 5)
+	v.SetDefault("comments.guests.enabled", false)
+	v.SetDefault("comments.guests.moderated", true)
+	v.SetDefault("comments.guests.requests", 5)
+	v.SetDefault("comments.guests.window", "1h")
+	v.SetDefault("comments.guests.captcha.provider", "")
+	v.SetDefault("comments.guests.captcha.secret", "")
+	v.SetDefault("comments.guests.captcha.timeout", "5s")
+	v.SetDefault("moderation.report_threshold", 3)
+	v.SetDefault("moderation.spam.enabled", true)
+	v.SetDefault("moderation.spam.max_links", 3)
+	v.SetDefault("moderation.spam.banned_words", []string{})
+	v.SetDefault("moderation.spam.akismet.api_key", "")
+	v.SetDefault("moderation.spam.akismet.url", "https://rest.akismet.com")
+	v.SetDefault("moderation.spam.akismet.timeout", "5s")
+	v.SetDefault("leaderboards.enabled", false)
+	v.SetDefault("leaderboards.refresh_interval", "15m")
+	v.SetDefault("leaderboards.size", 10)
+	v.SetDefault("referrals.badge_threshold", 3)
+	v.SetDefault("referrals.upload_bonus", 5)
+	v.SetDefault("referrals.max_upload_bonus", 50)
+	v.SetDefault("accounts.deletion_grace_period", "720h")
+	v.SetDefault("accounts.purge_interval", "1h")
+	v.SetDefault("notifications.digest.enabled", false)
+	v.SetDefault("notifications.digest.interval", "24h")
+	v.SetDefault("search.analytics.enabled", true)
+	v.SetDefault("search.analytics.retention", "2160h")
+	v.SetDefault("search.engine.driver", "")
+	v.SetDefault("search.engine.url", "")
+	v.SetDefault("search.engine.index", "posts")
+	v.SetDefault("search.engine.api_key", "")
+	v.SetDefault("search.engine.timeout", "5s")
+	v.SetDefault("search.engine.max_hits", 1000)
+	v.SetDefault("oauth.timeout", "10s")
+	v.SetDefault("oauth.complete_path", "/login/complete")
+	v.SetDefault("oauth.google.client_id", "")
+	v.SetDefault("oauth.google.client_secret", "")
+	v.SetDefault("oauth.google.redirect_url", "")
+	v.SetDefault("oauth.github.client_id", "")
+	v.SetDefault("oauth.github.client_secret", "")
+	v.SetDefault("oauth.github.redirect_url", "")
+	v.SetDefault("geoip.database_path", "")
+	v.SetDefault("geoip.truncate_stored_ips", true)
+	v.SetDefault("geoip.blocked_registration", []string{})
+	v.SetDefault("geoip.blocked_content", []string{})
+	v.SetDefault("tasks.trash_retention", "720h")
+	v.SetDefault("tasks.import_max_size_mb", 100)
+	v.SetDefault("webhooks.timeout", "10s")
+	v.SetDefault("webhooks.events", []string{})
+	v.SetDefault("webhooks.retries.max_attempts", 5)
+	v.SetDefault("webhooks.retries.backoff", "1m")
+	v.SetDefault("webhooks.retries.interval", "30s")
+	v.SetDefault("sandbox.enabled", true)
+	v.SetDefault("read_only.enabled", false)
+	v.SetDefault("read_only.message", "The site is read-only during maintenance, please try again later")
+	v.SetDefault("read_only.poll_interval", "5s")
+	v.SetDefault("demo.enabled", false)
+	v.SetDefault("demo.reset_interval", "1h")
+	v.SetDefault("demo.password", "demo-password")
+	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("rate_limit.requests", 120)
+	v.SetDefault("rate_limit.window", "1m")
+	v.SetDefault("rate_limit.exempt_paths", []string{"/health", "/metrics"})
+	v.SetDefault("rate_limit.exempt_roles", []string{"admin"})
+	v.SetDefault("rate_limit.service_keys", []ServiceKeyConfig{})
+	v.SetDefault("security_log.enabled", false)
+	v.SetDefault("security_log.output", "stdout")
+	v.SetDefault("security_log.service", "coderage")
+	v.SetDefault("security_log.syslog.network", "")
+	v.SetDefault("security_log.syslog.address", "")
+	v.SetDefault("security_log.syslog.tag", "coderage")
+	v.SetDefault("security_log.http.url", "")
+	v.SetDefault("security_log.http.timeout", "5s")
+	v.SetDefault("security_log.http.headers", map[string]string{})
+	v.SetDefault("alerts.enabled", false)
+	v.SetDefault("alerts.interval", "1m")
+	v.SetDefault("alerts.cooldown", "15m")
+	v.SetDefault("alerts.error_rate.spike_factor", 3)
+	v.SetDefault("alerts.error_rate.min_rate", 0.05)
+	v.SetDefault("alerts.error_rate.min_requests", 20)
+	v.SetDefault("alerts.server_errors.threshold", 50)
+	v.SetDefault("alerts.failed_logins.threshold", 30)
+	v.SetDefault("alerts.queue_backlog.threshold", 500)
+	v.SetDefault("alerts.email.recipients", []string{})
+	v.SetDefault("alerts.slack.webhook_url", "")
+	v.SetDefault("metrics.enabled", false)
+	v.SetDefault("metrics.token", "")
+	v.SetDefault("metrics.latency_buckets", []float64{})
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.endpoint", "localhost:4318")
+	v.SetDefault("tracing.insecure", true)
+	v.SetDefault("tracing.headers", map[string]string{})
+	v.SetDefault("tracing.service_name", "coderage")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+	v.SetDefault("http_client.max_retries", 2)
+	v.SetDefault("http_client.retry_backoff", "500ms")
+	v.SetDefault("http_client.blocked_networks", []string{})
+	v.SetDefault("http_client.allowed_networks", []string{})
+	v.SetDefault("markdown.allowed_tags", []string{
+		"p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "pre", "code", "span",
+		"em", "strong", "del", "a", "img", "ul", "ol", "li", "input", "table", "thead", "tbody",
+		"tr", "th", "td", "sup", "sub", "kbd", "mark", "details", "summary",
+	})
+	v.SetDefault("markdown.highlight_style", "github")
+	v.SetDefault("unfurl.timeout", "5s")
+	v.SetDefault("unfurl.max_body_size", 512<<10)
+	v.SetDefault("unfurl.user_agent", "CoderageBot/1.0 (+link previews)")
+	v.SetDefault("unfurl.cache_ttl", "24h")
+	v.SetDefault("unfurl.error_cache_ttl", "1h")
+	v.SetDefault("embeds.enabled", true)
+	v.SetDefault("embeds.oembed", true)
+	v.SetDefault("embeds.default_ttl", "720h")
+	v.SetDefault("embeds.max_ttl", "8760h")
+	v.SetDefault("embeds.width", 600)
+	v.SetDefault("embeds.height", 400)
+	v.SetDefault("embeds.cache_ttl", "1h")
+	v.SetDefault("events.driver", "inprocess")
+	v.SetDefault("events.subject_prefix", "coderage")
+
 }
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// durationType is the type of the durations, written like "30s" or "24h"
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Key describes a key of the configuration.
+type Key struct {
+	Key        string      `json:"key"`
+	Type       string      `json:"type"`
+	Env        string      `json:"env"`               // Environment variable overriding it
+	Default    interface{} `json:"default,omitempty"` // As written in config.yaml
+	Validation string      `json:"validation,omitempty"`
+	Reloadable bool        `json:"reloadable"` // Whether a change applies without a restart
+}
+
+// Schema returns every key of the configuration, in the order of the Config
+// struct, so the documentation of the keys can't drift from the code.
+func Schema() []Key {
+	defaults := viper.New()
+	setDefaults(defaults)
+
+	var keys []Key
+	walkKeys(func(key string, field reflect.StructField) {
+		keys = append(keys, Key{
+			Key:        key,
+			Type:       typeName(field.Type),
+			Env:        envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_")),
+			Default:    defaults.Get(key),
+			Validation: field.Tag.Get("validate"),
+			Reloadable: Reloadable(key),
+		})
+	})
+	return keys
+}
+
+// ValidateFile reads the configuration file at path with the defaults,
+// ignoring the environment, and returns the configuration it makes if it is
+// valid.
+func ValidateFile(path string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	setDefaults(v)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading configuration file: %v", err)
+	}
+	return decode(v)
+}
+
+// typeName names the type of a key in the schema.
+func typeName(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "duration"
+	case t.Kind() == reflect.Slice:
+		return "[]" + typeName(t.Elem())
+	case t.Kind() == reflect.Map:
+		return "map[" + typeName(t.Key()) + "]" + typeName(t.Elem())
+	case t.Kind() == reflect.Struct:
+		return "object"
+	default:
+		return t.Kind().String()
+	}
+}
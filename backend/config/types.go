@@ -0,0 +1,684 @@
+package config
+
+import "time"
+
+// Environments
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// Config is the configuration of the API, loaded from config.yaml with
+// CODERAGE_ environment variables taking precedence. Keys map to variables
+// by joining their path with underscores, e.g. database.host is set by
+// CODERAGE_DATABASE_HOST.
+type Config struct {
+	Server        ServerConfig        `mapstructure:"server"`
+	Database      DatabaseConfig      `mapstructure:"database"`
+	JWT           JWTConfig           `mapstructure:"jwt"`
+	CORS          CORSConfig          `mapstructure:"cors"`
+	API           APIConfig           `mapstructure:"api"`
+	GraphQL       GraphQLConfig       `mapstructure:"graphql"`
+	Logging       LoggingConfig       `mapstructure:"logging"`
+	Site          SiteConfig          `mapstructure:"site"`
+	I18n          I18nConfig          `mapstructure:"i18n"`
+	Features      FeaturesConfig      `mapstructure:"features"`
+	Posts         PostsConfig         `mapstructure:"posts"`
+	Views         ViewsConfig         `mapstructure:"views"`
+	Presence      PresenceConfig      `mapstructure:"presence"`
+	Discovery     DiscoveryConfig     `mapstructure:"discovery"`
+	Comments      CommentsConfig      `mapstructure:"comments"`
+	Moderation    ModerationConfig    `mapstructure:"moderation"`
+	Leaderboards  LeaderboardsConfig  `mapstructure:"leaderboards"`
+	Referrals     ReferralsConfig     `mapstructure:"referrals"`
+	Accounts      AccountsConfig      `mapstructure:"accounts"`
+	Notifications NotificationsConfig `mapstructure:"notifications"`
+	Search        SearchConfig        `mapstructure:"search"`
+	OAuth         OAuthConfig         `mapstructure:"oauth"`
+	GeoIP         GeoIPConfig         `mapstructure:"geoip"`
+	Storage       StorageConfig       `mapstructure:"storage"`
+	Uploads       UploadsConfig       `mapstructure:"uploads"`
+	RateLimit     RateLimitConfig     `mapstructure:"rate_limit"`
+	Tasks         TasksConfig         `mapstructure:"tasks"`
+	Webhooks      WebhooksConfig      `mapstructure:"webhooks"`
+	Sandbox       SandboxConfig       `mapstructure:"sandbox"`
+	ReadOnly      ReadOnlyConfig      `mapstructure:"read_only"`
+	Demo          DemoConfig          `mapstructure:"demo"`
+	SecurityLog   SecurityLogConfig   `mapstructure:"security_log"`
+	Alerts        AlertsConfig        `mapstructure:"alerts"`
+	Metrics       MetricsConfig       `mapstructure:"metrics"`
+	Tracing       TracingConfig       `mapstructure:"tracing"`
+	HTTPClient    HTTPClientConfig    `mapstructure:"http_client"`
+	Markdown      MarkdownConfig      `mapstructure:"markdown"`
+	Unfurl        UnfurlConfig        `mapstructure:"unfurl"`
+	Embeds        EmbedsConfig        `mapstructure:"embeds"`
+	Events        EventsConfig        `mapstructure:"events"`
+	Email         EmailConfig         `mapstructure:"email"`
+}
+
+type ServerConfig struct {
+	Port        string `mapstructure:"port" validate:"required"`
+	Environment string `mapstructure:"environment" validate:"oneof=development staging production"`
+	BaseURL     string `mapstructure:"base_url" validate:"required,url"`
+	// Timeouts of the route groups, after which the queries of a request are
+	// cancelled. Disabled when 0
+	Timeouts TimeoutsConfig `mapstructure:"timeouts"`
+	TLS      TLSConfig      `mapstructure:"tls"`
+}
+
+// TLSConfig serves HTTPS on the server port, with the certificate of
+// CertFile and KeyFile, or with certificates obtained from Let's Encrypt
+// for the domains of Autocert.
+type TLSConfig struct {
+	Enabled      bool           `mapstructure:"enabled"`
+	CertFile     string         `mapstructure:"cert_file" validate:"required_with=KeyFile"`
+	KeyFile      string         `mapstructure:"key_file" validate:"required_with=CertFile"`
+	Autocert     AutocertConfig `mapstructure:"autocert"`
+	RedirectPort string         `mapstructure:"redirect_port"` // Port redirecting HTTP to HTTPS, disabled when empty
+}
+
+type AutocertConfig struct {
+	Domains      []string `mapstructure:"domains"`
+	Email        string   `mapstructure:"email" validate:"omitempty,email"` // Contact of the Let's Encrypt account
+	CacheDir     string   `mapstructure:"cache_dir" validate:"required_with=Domains"`
+	DirectoryURL string   `mapstructure:"directory_url" validate:"omitempty,url"` // ACME server, Let's Encrypt when empty
+}
+
+type TimeoutsConfig struct {
+	Default time.Duration `mapstructure:"default" validate:"min=0"`
+	Admin   time.Duration `mapstructure:"admin" validate:"min=0"` // Admin routes, such as reports and maintenance tasks
+}
+
+type DatabaseConfig struct {
+	Type     string `mapstructure:"type" validate:"oneof=postgres sqlite"`
+	Host     string `mapstructure:"host" validate:"required_if=Type postgres"`
+	Port     int    `mapstructure:"port" validate:"required_if=Type postgres"`
+	Name     string `mapstructure:"name" validate:"required"` // Database name, or file of a SQLite database
+	User     string `mapstructure:"user" validate:"required_if=Type postgres"`
+	Password string `mapstructure:"password" validate:"required_if=Type postgres"`
+
+	ConnectRetries    int                    `mapstructure:"connect_retries" validate:"min=0"`
+	ConnectBackoff    time.Duration          `mapstructure:"connect_backoff"`
+	ConnectMaxBackoff time.Duration          `mapstructure:"connect_max_backoff"`
+	Breaker           DatabaseBreakerConfig  `mapstructure:"breaker"`
+	QueryPlans        QueryPlansConfig       `mapstructure:"query_plans"`
+	Replicas          DatabaseReplicasConfig `mapstructure:"replicas"`
+	// SlowQueryThreshold is the duration from which queries are logged as
+	// slow, 0 to only log the failed queries
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold" validate:"min=0"`
+}
+
+type DatabaseReplicasConfig struct {
+	// Hosts are the read replicas of a Postgres database, sharing its name
+	// and credentials
+	Hosts         []ReplicaConfig `mapstructure:"hosts" validate:"dive"`
+	CheckInterval time.Duration   `mapstructure:"check_interval" validate:"min=1s"`
+}
+
+type ReplicaConfig struct {
+	Host string `mapstructure:"host" validate:"required"`
+	Port int    `mapstructure:"port" validate:"required"`
+}
+
+type DatabaseBreakerConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	FailureThreshold int           `mapstructure:"failure_threshold" validate:"min=1"`
+	CheckInterval    time.Duration `mapstructure:"check_interval" validate:"min=1s"`
+}
+
+type QueryPlansConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Analyze     bool          `mapstructure:"analyze"`      // Run the queries again to measure them, on PostgreSQL
+	MinDuration time.Duration `mapstructure:"min_duration"` // Queries faster than this are not explained
+	MaxQueries  int           `mapstructure:"max_queries" validate:"min=1"`
+	Routes      []string      `mapstructure:"routes"` // Route templates explained on every request
+}
+
+type JWTConfig struct {
+	Secret          string `mapstructure:"secret" validate:"required"`
+	ExpirationHours int    `mapstructure:"expiration_hours" validate:"min=1"` // Lifetime of the access tokens
+	// Keys are the asymmetric keys tokens are signed with instead of Secret,
+	// the first signing them and the others only verifying them
+	Keys []JWTKeyConfig `mapstructure:"keys" validate:"dive"`
+	// SecretValidUntil is the RFC 3339 time until which tokens signed with
+	// Secret are accepted once Keys are configured, none when empty
+	SecretValidUntil string `mapstructure:"secret_valid_until" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// JWTKeyConfig is a PEM encoded RSA or Ed25519 private key, with the ID
+// set in the header of the tokens it signs.
+type JWTKeyConfig struct {
+	ID   string `mapstructure:"id" validate:"required"`
+	File string `mapstructure:"file" validate:"required"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	Debug          bool     `mapstructure:"debug"`
+}
+
+type APIConfig struct {
+	Envelope  bool   `mapstructure:"envelope"`
+	FieldCase string `mapstructure:"field_case" validate:"oneof=snake camel"`
+	Version   int    `mapstructure:"version" validate:"min=1"` // Version of the responses of the clients not asking for one
+}
+
+type GraphQLConfig struct {
+	Enabled         bool `mapstructure:"enabled"`
+	ComplexityLimit int  `mapstructure:"complexity_limit" validate:"min=1"` // Fields a query may select, at every level of nesting
+	Introspection   bool `mapstructure:"introspection"`
+}
+
+type LoggingConfig struct {
+	Level       string            `mapstructure:"level" validate:"oneof=debug info warn error"`
+	Format      string            `mapstructure:"format" validate:"oneof=console json"`
+	OutputPaths []string          `mapstructure:"output_path" validate:"min=1"` // "stdout", "stderr" or file paths
+	Sampling    LogSamplingConfig `mapstructure:"sampling"`
+	Rotation    LogRotationConfig `mapstructure:"rotation"`
+	Redact      bool              `mapstructure:"redact"`
+}
+
+// LogSamplingConfig limits the repeated entries of the JSON format: past the
+// first Initial entries with the same level and message within a second,
+// only every Thereafter-th one is logged. An Initial of 0 logs them all.
+type LogSamplingConfig struct {
+	Initial    int `mapstructure:"initial" validate:"min=0"`
+	Thereafter int `mapstructure:"thereafter" validate:"min=0"`
+}
+
+// LogRotationConfig rotates the log files once they reach MaxSizeMB,
+// keeping at most MaxBackups old files for at most MaxAgeDays. Zero keeps
+// them all.
+type LogRotationConfig struct {
+	MaxSizeMB  int  `mapstructure:"max_size_mb" validate:"min=1"`
+	MaxBackups int  `mapstructure:"max_backups" validate:"min=0"`
+	MaxAgeDays int  `mapstructure:"max_age_days" validate:"min=0"`
+	Compress   bool `mapstructure:"compress"`
+}
+
+type SiteConfig struct {
+	Title              string `mapstructure:"title"`
+	Description        string `mapstructure:"description"`
+	URL                string `mapstructure:"url" validate:"required,url"`
+	DefaultLicense     string `mapstructure:"default_license"`
+	SecurityContact    string `mapstructure:"security_contact"`
+	ChangePasswordPath string `mapstructure:"change_password_path"`
+	PrimaryColor       string `mapstructure:"primary_color"`
+	AccentColor        string `mapstructure:"accent_color"`
+	Timezone           string `mapstructure:"timezone"`
+	DateFormat         string `mapstructure:"date_format" validate:"oneof=iso us eu long"`
+	Locale             string `mapstructure:"locale"`
+}
+
+type I18nConfig struct {
+	BundlesDir string `mapstructure:"bundles_dir"`
+}
+
+type FeaturesConfig struct {
+	UserRegistration bool `mapstructure:"user_registration"`
+	RequireApproval  bool `mapstructure:"require_approval"`
+	CommentsEnabled  bool `mapstructure:"comments_enabled"`
+	SensitiveGating  bool `mapstructure:"sensitive_gating"`
+	Referrals        bool `mapstructure:"referrals"`
+}
+
+type PostsConfig struct {
+	EarlyAccessWindow time.Duration    `mapstructure:"early_access_window"`
+	Lint              PostLintConfig   `mapstructure:"lint"`
+	Counts            PostCountsConfig `mapstructure:"counts"`
+}
+
+// PostCountsConfig sets how the total count of the posts listed to
+// anonymous readers is obtained: counted every time, cached for CacheTTL, or
+// estimated by the PostgreSQL planner.
+type PostCountsConfig struct {
+	Mode     string        `mapstructure:"mode" validate:"oneof=exact cached estimated"`
+	CacheTTL time.Duration `mapstructure:"cache_ttl" validate:"min=0"`
+}
+
+// PostLintConfig sets how each check run on posts when they are published
+// applies: off, advisory, reporting a warning, or blocking, refusing to
+// publish the post.
+type PostLintConfig struct {
+	FeaturedImage         string `mapstructure:"featured_image" validate:"oneof=off advisory blocking"`
+	Excerpt               string `mapstructure:"excerpt" validate:"oneof=off advisory blocking"`
+	InternalLinks         string `mapstructure:"internal_links" validate:"oneof=off advisory blocking"`
+	Headings              string `mapstructure:"headings" validate:"oneof=off advisory blocking"`
+	MetaDescription       string `mapstructure:"meta_description" validate:"oneof=off advisory blocking"`
+	MetaDescriptionLength int    `mapstructure:"meta_description_length" validate:"min=1"` // Longest meta description shown whole by search engines
+}
+
+type MarkdownConfig struct {
+	AllowedTags    []string `mapstructure:"allowed_tags"`
+	HighlightStyle string   `mapstructure:"highlight_style"`
+}
+
+type CommentsConfig struct {
+	MaxDepth int                 `mapstructure:"max_depth" validate:"min=0"`
+	Guests   GuestCommentsConfig `mapstructure:"guests"`
+}
+
+// GuestCommentsConfig configures the comments of readers without an account,
+// who give their name and email address instead. Guests must leave the
+// honeypot field empty and, when a CAPTCHA provider is set, solve its
+// challenge. Each IP address can send Requests guest comments per Window.
+type GuestCommentsConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Moderated bool          `mapstructure:"moderated"` // Hidden until a moderator approves them
+	Requests  int           `mapstructure:"requests" validate:"min=1"`
+	Window    time.Duration `mapstructure:"window"`
+	Captcha   CaptchaConfig `mapstructure:"captcha"`
+}
+
+// CaptchaConfig configures the CAPTCHA service verifying the challenges
+// solved by guests: Cloudflare Turnstile, hCaptcha or reCAPTCHA.
+type CaptchaConfig struct {
+	Provider string        `mapstructure:"provider" validate:"omitempty,oneof=turnstile hcaptcha recaptcha"`
+	Secret   string        `mapstructure:"secret" validate:"required_with=Provider"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+type ModerationConfig struct {
+	ReportThreshold int        `mapstructure:"report_threshold" validate:"min=0"`
+	Spam            SpamConfig `mapstructure:"spam"`
+}
+
+// SpamConfig configures the detection of spam among new comments, which are
+// hidden until a moderator reviews them. Akismet checks the comments when
+// its API key is set, and the heuristics when it isn't or fails: comments
+// holding more than MaxLinks links or one of the banned words, matched case
+// insensitively, are spam.
+type SpamConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	MaxLinks    int           `mapstructure:"max_links" validate:"min=0"` // 0 disables the limit
+	BannedWords []string      `mapstructure:"banned_words"`
+	Akismet     AkismetConfig `mapstructure:"akismet"`
+}
+
+// AkismetConfig configures the Akismet spam detection service, checking
+// the comments on behalf of "site.url".
+type AkismetConfig struct {
+	APIKey  string        `mapstructure:"api_key"`
+	URL     string        `mapstructure:"url" validate:"omitempty,url"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// ViewsConfig configures the counting of post views. Views of the same
+// visitor within the deduplication window count once, and views from user
+// agents containing one of the ignored agents, matched case insensitively,
+// don't count.
+type ViewsConfig struct {
+	DedupWindow   time.Duration `mapstructure:"dedup_window"`
+	IgnoredAgents []string      `mapstructure:"ignored_agents"`
+}
+
+// PresenceConfig configures the live count of the readers of posts, sent to
+// the clients streaming a post. Readers are counted in the memory of each
+// instance, or in Redis when several instances serve the API.
+type PresenceConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Driver     string        `mapstructure:"driver" validate:"oneof=memory redis"`
+	Heartbeat  time.Duration `mapstructure:"heartbeat" validate:"min=1s"`  // How often streams refresh their reader and send the count
+	TTL        time.Duration `mapstructure:"ttl"`                          // Readers not refreshed for this long, e.g. those of a crashed instance, are dropped
+	MaxStreams int           `mapstructure:"max_streams" validate:"min=0"` // Streams open at once on an instance, unlimited when 0
+	Redis      RedisConfig   `mapstructure:"redis"`
+}
+
+type RedisConfig struct {
+	Addr      string `mapstructure:"addr"`
+	Password  string `mapstructure:"password"`
+	DB        int    `mapstructure:"db" validate:"min=0"`
+	KeyPrefix string `mapstructure:"key_prefix"`
+}
+
+// DiscoveryConfig configures the trending and related posts, cached for
+// cache_ttl.
+type DiscoveryConfig struct {
+	CacheTTL time.Duration  `mapstructure:"cache_ttl"`
+	Trending TrendingConfig `mapstructure:"trending"`
+	Related  RelatedConfig  `mapstructure:"related"`
+}
+
+// TrendingConfig ranks the posts on their views, likes and comments, each
+// counting half as much every half_life. Likes aren't dated, so they age
+// with the post.
+type TrendingConfig struct {
+	Window        time.Duration `mapstructure:"window" validate:"min=1h"` // Views and comments older than this don't count
+	HalfLife      time.Duration `mapstructure:"half_life" validate:"min=1h"`
+	ViewWeight    float64       `mapstructure:"view_weight" validate:"min=0"`
+	LikeWeight    float64       `mapstructure:"like_weight" validate:"min=0"`
+	CommentWeight float64       `mapstructure:"comment_weight" validate:"min=0"`
+	Size          int           `mapstructure:"size" validate:"min=1,max=100"`
+}
+
+// RelatedConfig ranks the posts related to a post on the tags they share
+// with it, and on the words their titles and excerpts share with it when
+// text_similarity is on.
+type RelatedConfig struct {
+	Size           int  `mapstructure:"size" validate:"min=1,max=50"`
+	TextSimilarity bool `mapstructure:"text_similarity"`
+}
+
+type LeaderboardsConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	Size            int           `mapstructure:"size" validate:"min=1,max=100"`
+}
+
+type ReferralsConfig struct {
+	BadgeThreshold int64 `mapstructure:"badge_threshold" validate:"min=0"`
+	UploadBonus    int64 `mapstructure:"upload_bonus" validate:"min=0"`
+	MaxUploadBonus int64 `mapstructure:"max_upload_bonus" validate:"min=0"`
+}
+
+type AccountsConfig struct {
+	DeletionGracePeriod time.Duration `mapstructure:"deletion_grace_period"`
+	PurgeInterval       time.Duration `mapstructure:"purge_interval"`
+}
+
+type NotificationsConfig struct {
+	Digest NotificationDigestConfig `mapstructure:"digest"`
+}
+
+// NotificationDigestConfig configures the emails summing up the unread
+// notifications of the users who opted in.
+type NotificationDigestConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+type SearchConfig struct {
+	Analytics SearchAnalyticsConfig `mapstructure:"analytics"`
+	Engine    SearchEngineConfig    `mapstructure:"engine"`
+}
+
+// SearchEngineConfig configures the external search engine the posts are
+// indexed into, which then ranks the post searches. The database serves
+// them when no driver is set.
+type SearchEngineConfig struct {
+	Driver  string        `mapstructure:"driver" validate:"omitempty,oneof=elasticsearch meilisearch"`
+	URL     string        `mapstructure:"url" validate:"required_with=Driver"`
+	Index   string        `mapstructure:"index"`
+	APIKey  string        `mapstructure:"api_key"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	MaxHits int           `mapstructure:"max_hits" validate:"min=1"` // Results ranked per search, the pages past them being empty
+}
+
+// SearchAnalyticsConfig configures the recording of the post searches, kept
+// without the searcher for the retention period.
+type SearchAnalyticsConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+type OAuthConfig struct {
+	Timeout time.Duration `mapstructure:"timeout"`
+	// CompletePath is the page of the site users are redirected to once the
+	// API completed their login, with the token pair in the fragment
+	CompletePath string              `mapstructure:"complete_path" validate:"startswith=/"`
+	Google       OAuthProviderConfig `mapstructure:"google"`
+	GitHub       OAuthProviderConfig `mapstructure:"github"`
+}
+
+// OAuthProviderConfig configures a login provider, enabled when its client
+// ID is set.
+type OAuthProviderConfig struct {
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret" validate:"required_with=ClientID"`
+	RedirectURL  string `mapstructure:"redirect_url" validate:"required_with=ClientID,omitempty,url"`
+}
+
+type GeoIPConfig struct {
+	DatabasePath        string   `mapstructure:"database_path"`
+	TruncateStoredIPs   bool     `mapstructure:"truncate_stored_ips"`
+	BlockedRegistration []string `mapstructure:"blocked_registration"`
+	BlockedContent      []string `mapstructure:"blocked_content"`
+}
+
+type StorageConfig struct {
+	Driver string             `mapstructure:"driver" validate:"oneof=local s3"`
+	Local  LocalStorageConfig `mapstructure:"local"`
+	S3     S3StorageConfig    `mapstructure:"s3"`
+}
+
+type LocalStorageConfig struct {
+	Path    string `mapstructure:"path"`
+	BaseURL string `mapstructure:"base_url"`
+}
+
+type S3StorageConfig struct {
+	Bucket    string `mapstructure:"bucket"`
+	Region    string `mapstructure:"region"`
+	Endpoint  string `mapstructure:"endpoint"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	PublicURL string `mapstructure:"public_url"`
+}
+
+type UploadsConfig struct {
+	MaxSizeMB    int64          `mapstructure:"max_size_mb" validate:"min=1"`
+	AllowedTypes []string       `mapstructure:"allowed_types"`
+	DailyQuota   int64          `mapstructure:"daily_quota" validate:"min=0"`
+	Variants     VariantsConfig `mapstructure:"variants"`
+}
+
+// VariantsConfig configures the resized variants of the uploaded JPEG, PNG
+// and WebP images, generated in the background for responsive images. The
+// images are downscaled to each of the sizes narrower than they are, and
+// WebP versions of the image and its variants are added when WebP is set.
+type VariantsConfig struct {
+	Enabled bool                `mapstructure:"enabled"`
+	Sizes   []VariantSizeConfig `mapstructure:"sizes" validate:"dive"`
+	WebP    bool                `mapstructure:"webp"`
+	Quality int                 `mapstructure:"quality" validate:"min=1,max=100"` // Of the JPEG variants
+}
+
+// VariantSizeConfig is a size images are downscaled to, keeping their
+// aspect ratio.
+type VariantSizeConfig struct {
+	Name  string `mapstructure:"name" validate:"required,alphanum,max=32"`
+	Width int    `mapstructure:"width" validate:"min=1"`
+}
+
+type RateLimitConfig struct {
+	Enabled     bool               `mapstructure:"enabled"`
+	Requests    int                `mapstructure:"requests" validate:"min=1"`
+	Window      time.Duration      `mapstructure:"window"`
+	ExemptPaths []string           `mapstructure:"exempt_paths"`
+	ExemptRoles []string           `mapstructure:"exempt_roles"`
+	ServiceKeys []ServiceKeyConfig `mapstructure:"service_keys" validate:"dive"`
+}
+
+// ServiceKeyConfig is an API key issued to a service account. Only the
+// SHA-256 hash of the key is configured.
+type ServiceKeyConfig struct {
+	Name    string   `mapstructure:"name" validate:"required"`
+	KeyHash string   `mapstructure:"key_hash" validate:"required"`
+	Scopes  []string `mapstructure:"scopes"`
+}
+
+type TasksConfig struct {
+	TrashRetention  time.Duration `mapstructure:"trash_retention"`
+	ImportMaxSizeMB int64         `mapstructure:"import_max_size_mb" validate:"min=1"` // Largest file accepted by POST /admin/import
+}
+
+type WebhooksConfig struct {
+	Timeout time.Duration        `mapstructure:"timeout"`
+	Events  []string             `mapstructure:"events"`
+	Retries WebhookRetriesConfig `mapstructure:"retries"`
+}
+
+// WebhookRetriesConfig configures the automatic retries of the failed
+// deliveries, which wait twice as long after each attempt.
+type WebhookRetriesConfig struct {
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	Backoff     time.Duration `mapstructure:"backoff"`  // Wait before the first retry
+	Interval    time.Duration `mapstructure:"interval"` // How often the due retries are sent
+}
+
+type SandboxConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// ReadOnlyConfig configures the read-only mode, turning writes away with 503
+// Service Unavailable. Enabled forces it on, whatever the switch of the admin
+// endpoint and the migrate command.
+type ReadOnlyConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Message      string        `mapstructure:"message"`
+	PollInterval time.Duration `mapstructure:"poll_interval"` // How often instances read the switch
+}
+
+// DemoConfig configures the demo mode, enabled by the --demo flag.
+type DemoConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	ResetInterval time.Duration `mapstructure:"reset_interval"`                               // Sample content is restored this often, never when zero
+	Password      string        `mapstructure:"password" validate:"required_if=Enabled true"` // Password of the sample accounts
+}
+
+type SecurityLogConfig struct {
+	Enabled bool                    `mapstructure:"enabled"`
+	Output  string                  `mapstructure:"output" validate:"oneof=stdout syslog http"`
+	Service string                  `mapstructure:"service"`
+	Syslog  SecurityLogSyslogConfig `mapstructure:"syslog"`
+	HTTP    SecurityLogHTTPConfig   `mapstructure:"http"`
+}
+
+type SecurityLogSyslogConfig struct {
+	Network string `mapstructure:"network"`
+	Address string `mapstructure:"address"`
+	Tag     string `mapstructure:"tag"`
+}
+
+type SecurityLogHTTPConfig struct {
+	URL     string            `mapstructure:"url"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+	Headers map[string]string `mapstructure:"headers"`
+}
+
+type AlertsConfig struct {
+	Enabled      bool                 `mapstructure:"enabled"`
+	Interval     time.Duration        `mapstructure:"interval"`
+	Cooldown     time.Duration        `mapstructure:"cooldown"`
+	ErrorRate    ErrorRateAlertConfig `mapstructure:"error_rate"`
+	ServerErrors ThresholdAlertConfig `mapstructure:"server_errors"`
+	FailedLogins ThresholdAlertConfig `mapstructure:"failed_logins"`
+	QueueBacklog ThresholdAlertConfig `mapstructure:"queue_backlog"`
+	Email        AlertEmailConfig     `mapstructure:"email"`
+	Slack        AlertSlackConfig     `mapstructure:"slack"`
+}
+
+type ErrorRateAlertConfig struct {
+	SpikeFactor float64 `mapstructure:"spike_factor"`
+	MinRate     float64 `mapstructure:"min_rate"`
+	MinRequests int64   `mapstructure:"min_requests"`
+}
+
+type ThresholdAlertConfig struct {
+	Threshold int64 `mapstructure:"threshold"`
+}
+
+type AlertEmailConfig struct {
+	Recipients []string `mapstructure:"recipients"`
+}
+
+type AlertSlackConfig struct {
+	WebhookURL string `mapstructure:"webhook_url"`
+}
+
+type MetricsConfig struct {
+	Enabled        bool      `mapstructure:"enabled"`
+	Token          string    `mapstructure:"token"`
+	LatencyBuckets []float64 `mapstructure:"latency_buckets"`
+}
+
+type TracingConfig struct {
+	Enabled     bool              `mapstructure:"enabled"`
+	Endpoint    string            `mapstructure:"endpoint"`
+	Insecure    bool              `mapstructure:"insecure"`
+	Headers     map[string]string `mapstructure:"headers"`
+	ServiceName string            `mapstructure:"service_name"`
+	SampleRatio float64           `mapstructure:"sample_ratio" validate:"min=0,max=1"`
+}
+
+type HTTPClientConfig struct {
+	MaxRetries      int           `mapstructure:"max_retries" validate:"min=0"`
+	RetryBackoff    time.Duration `mapstructure:"retry_backoff"`
+	BlockedNetworks []string      `mapstructure:"blocked_networks" validate:"dive,cidr"`
+	AllowedNetworks []string      `mapstructure:"allowed_networks" validate:"dive,cidr"`
+}
+
+type UnfurlConfig struct {
+	Timeout       time.Duration `mapstructure:"timeout"`
+	MaxBodySize   int64         `mapstructure:"max_body_size" validate:"min=1"`
+	UserAgent     string        `mapstructure:"user_agent"`
+	CacheTTL      time.Duration `mapstructure:"cache_ttl"`
+	ErrorCacheTTL time.Duration `mapstructure:"error_cache_ttl"`
+}
+
+type EmbedsConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	OEmbed     bool          `mapstructure:"oembed"`
+	DefaultTTL time.Duration `mapstructure:"default_ttl"`
+	MaxTTL     time.Duration `mapstructure:"max_ttl"`
+	Width      int           `mapstructure:"width" validate:"min=1"`
+	Height     int           `mapstructure:"height" validate:"min=1"`
+	CacheTTL   time.Duration `mapstructure:"cache_ttl"`
+}
+
+type EventsConfig struct {
+	Driver        string      `mapstructure:"driver" validate:"oneof=inprocess nats kafka"`
+	SubjectPrefix string      `mapstructure:"subject_prefix"`
+	NATS          NATSConfig  `mapstructure:"nats"`
+	Kafka         KafkaConfig `mapstructure:"kafka"`
+}
+
+type NATSConfig struct {
+	URL string `mapstructure:"url"`
+}
+
+type KafkaConfig struct {
+	Brokers []string `mapstructure:"brokers"`
+	GroupID string   `mapstructure:"group_id"`
+}
+
+type EmailConfig struct {
+	Provider             string              `mapstructure:"provider" validate:"oneof=smtp sendgrid ses log"`
+	SMTPHost             string              `mapstructure:"smtp_host"`
+	SMTPPort             int                 `mapstructure:"smtp_port"`
+	SMTPUsername         string              `mapstructure:"smtp_username"`
+	SMTPPassword         string              `mapstructure:"smtp_password"`
+	SenderEmail          string              `mapstructure:"sender_email"`
+	VerificationTTLHours int                 `mapstructure:"verification_ttl_hours" validate:"min=1"`
+	SendGrid             SendGridConfig      `mapstructure:"sendgrid"`
+	SES                  SESConfig           `mapstructure:"ses"`
+	Queue                EmailQueueConfig    `mapstructure:"queue"`
+	Feedback             EmailFeedbackConfig `mapstructure:"feedback"`
+}
+
+type SendGridConfig struct {
+	APIKey string `mapstructure:"api_key"`
+}
+
+// SESConfig configures Amazon SES. The default AWS credential chain is used
+// when no access key is set.
+type SESConfig struct {
+	Region    string `mapstructure:"region"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+}
+
+// EmailQueueConfig configures the queue emails are sent from in the
+// background, retrying the failed ones.
+type EmailQueueConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Workers     int           `mapstructure:"workers" validate:"min=1"`
+	Size        int           `mapstructure:"size" validate:"min=1"`
+	MaxAttempts int           `mapstructure:"max_attempts" validate:"min=1"`
+	Backoff     time.Duration `mapstructure:"backoff"` // Doubled after every failed attempt
+}
+
+type EmailFeedbackConfig struct {
+	Token string `mapstructure:"token"`
+}
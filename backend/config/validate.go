@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/text/language"
+)
+
+// minProductionSecretLength is the length of the shortest JWT secret accepted
+// in production, that of a 32 bytes hex encoded random key being 64
+const minProductionSecretLength = 32
+
+// placeholderSecrets are the JWT secrets shipped as examples, which must
+// never sign tokens in production
+var placeholderSecrets = []string{
+	"your-secret-key",
+	"your-very-secret-and-long-random-key",
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// Report the configuration keys rather than the field names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("mapstructure")
+	})
+	return v
+}
+
+// Validate checks the configuration, and returns an error listing every
+// invalid key.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if err := validate.Struct(c); err != nil {
+		var fieldErrs validator.ValidationErrors
+		if !errors.As(err, &fieldErrs) {
+			return err
+		}
+		for _, fieldErr := range fieldErrs {
+			// Drop the name of the Config type from the namespace
+			_, key, _ := strings.Cut(fieldErr.Namespace(), ".")
+			switch fieldErr.Tag() {
+			case "required":
+				problems = append(problems, fmt.Sprintf("%s is not set", key))
+			case "oneof":
+				problems = append(problems, fmt.Sprintf("%s must be one of %s", key, fieldErr.Param()))
+			default:
+				problems = append(problems, fmt.Sprintf("%s is invalid (%s)", key, fieldErr.Tag()))
+			}
+		}
+	}
+
+	if c.Server.Environment == EnvProduction {
+		for _, placeholder := range placeholderSecrets {
+			if strings.HasPrefix(c.JWT.Secret, placeholder) {
+				problems = append(problems, "jwt.secret must be changed from its example value in production")
+			}
+		}
+		if len(c.JWT.Secret) < minProductionSecretLength {
+			problems = append(problems, fmt.Sprintf("jwt.secret must be at least %d characters in production", minProductionSecretLength))
+		}
+	}
+	if _, err := time.LoadLocation(c.Site.Timezone); err != nil {
+		problems = append(problems, fmt.Sprintf("site.timezone is not a known time zone: %q", c.Site.Timezone))
+	}
+	if _, err := language.Parse(c.Site.Locale); err != nil {
+		problems = append(problems, fmt.Sprintf("site.locale is not a valid language tag: %q", c.Site.Locale))
+	}
+	if c.Storage.Driver == "s3" && c.Storage.S3.Bucket == "" {
+		problems = append(problems, "storage.s3.bucket is not set")
+	}
+	if c.Email.Provider == "sendgrid" && c.Email.SendGrid.APIKey == "" {
+		problems = append(problems, "email.sendgrid.api_key is not set")
+	}
+	if c.Email.Provider == "ses" && c.Email.SES.Region == "" {
+		problems = append(problems, "email.ses.region is not set")
+	}
+	if c.Presence.Enabled && c.Presence.TTL <= c.Presence.Heartbeat {
+		problems = append(problems, "presence.ttl must be longer than presence.heartbeat")
+	}
+	if c.Presence.Enabled && c.Presence.Driver == "redis" && c.Presence.Redis.Addr == "" {
+		problems = append(problems, "presence.redis.addr is not set")
+	}
+	if c.SecurityLog.Enabled && c.SecurityLog.Output == "http" && c.SecurityLog.HTTP.URL == "" {
+		problems = append(problems, "security_log.http.url is not set")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Watch reloads the configuration file when it changes. Only the settings
+// read on every use are applied: the site, features, API format, log
+// redaction, uploads, posts, view counting, trending and related posts,
+// Markdown rendering, comments, moderation, referrals, search analytics and
+// leaderboard size, the GeoIP block lists, the alert thresholds, the link
+// preview cache, the metrics token, the email verification lifetime, the
+// maintenance tasks, the slow query threshold, the query plans captured
+// while enabled, the request timeouts and the read-only mode. Other changes,
+// such as the database or the server port, need a restart, which is logged. A file that fails validation is ignored.
+func Watch(logger *zap.Logger) {
+	viper.OnConfigChange(func(event fsnotify.Event) {
+		next, err := decode(viper.GetViper())
+		if err != nil {
+			logger.Error("Configuration reload failed", zap.String("file", event.Name), zap.Error(err))
+			return
+		}
+
+		cfg := reloadSafe(Get(), next)
+		Set(cfg)
+		logger.Info("Configuration reloaded", zap.String("file", event.Name))
+		if !reflect.DeepEqual(cfg, next) {
+			logger.Warn("Configuration changes need a restart to apply", zap.String("file", event.Name))
+		}
+	})
+	viper.WatchConfig()
+}
+
+// reloadable lists the sections and keys that can change at runtime
+var reloadable = []string{
+	"server.timeouts",
+	"site",
+	"features",
+	"api",
+	"logging.redact",
+	"uploads",
+	"posts",
+	"views",
+	"discovery",
+	"markdown",
+	"comments",
+	"moderation.report_threshold",
+	"moderation.spam.enabled",
+	"moderation.spam.max_links",
+	"moderation.spam.banned_words",
+	"referrals",
+	"search",
+	"leaderboards.size",
+	"geoip.truncate_stored_ips",
+	"geoip.blocked_registration",
+	"geoip.blocked_content",
+	"alerts.cooldown",
+	"alerts.error_rate",
+	"alerts.server_errors",
+	"alerts.failed_logins",
+	"alerts.queue_backlog",
+	"unfurl.cache_ttl",
+	"unfurl.error_cache_ttl",
+	"metrics.token",
+	"email.verification_ttl_hours",
+	"tasks",
+	"database.slow_query_threshold",
+	"database.query_plans.analyze",
+	"database.query_plans.min_duration",
+	"database.query_plans.max_queries",
+	"database.query_plans.routes",
+	"read_only.enabled",
+	"read_only.message",
+	"webhooks.retries.max_attempts",
+	"webhooks.retries.backoff",
+}
+
+// Reloadable reports whether a change of the key is applied without a
+// restart.
+func Reloadable(key string) bool {
+	for _, prefix := range reloadable {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadSafe returns a copy of cfg with the settings that can change at
+// runtime taken from next.
+func reloadSafe(cfg, next *Config) *Config {
+	reloaded := *cfg
+	for _, key := range reloadable {
+		field(&reloaded, key).Set(field(next, key))
+	}
+	return &reloaded
+}
+
+// field returns the field of cfg holding the section or key.
+func field(cfg *Config, key string) reflect.Value {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, name := range strings.Split(key, ".") {
+		next := reflect.Value{}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Tag.Get("mapstructure") == name {
+				next = v.Field(i)
+				break
+			}
+		}
+		if !next.IsValid() {
+			panic(fmt.Sprintf("unknown configuration key %q", key))
+		}
+		v = next
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/SteaceP/coderage/config"
+)
+
+const configUsage = `Usage:
+  api config print-schema [-json]   List the configuration keys, with their type, default, environment
+                                    variable and whether changes apply without a restart
+  api config validate [file]        Validate a configuration file, config.yaml by default
+`
+
+// runConfigCommand runs the config subcommand with its arguments, and
+// returns the exit code.
+func runConfigCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, configUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "print-schema":
+		flags := flag.NewFlagSet("print-schema", flag.ContinueOnError)
+		flags.SetOutput(stderr)
+		asJSON := flags.Bool("json", false, "print the schema as JSON")
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if *asJSON {
+			encoder := json.NewEncoder(stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(config.Schema()); err != nil {
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+			return 0
+		}
+		printSchema(stdout, config.Schema())
+		return 0
+
+	case "validate":
+		path := "config.yaml"
+		if len(args) > 1 {
+			path = args[1]
+		}
+		if _, err := config.ValidateFile(path); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "%s: valid\n", path)
+		return 0
+
+	default:
+		fmt.Fprintf(stderr, "unknown config command %q\n%s", args[0], configUsage)
+		return 2
+	}
+}
+
+// printSchema writes the keys of the configuration as a table.
+func printSchema(w io.Writer, keys []config.Key) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "KEY\tTYPE\tRELOADABLE\tENV\tVALIDATION\tDEFAULT")
+	for _, key := range keys {
+		def := ""
+		if key.Default != nil {
+			def = fmt.Sprint(key.Default)
+		}
+		reloadable := "no"
+		if key.Reloadable {
+			reloadable = "yes"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", key.Key, key.Type, reloadable, key.Env, key.Validation, def)
+	}
+	table.Flush()
+}
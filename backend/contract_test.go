@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/database"
+	"github.com/SteaceP/coderage/demo"
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/i18n"
+	"github.com/SteaceP/coderage/jwtkeys"
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/middleware"
+	"github.com/SteaceP/coderage/openapi"
+	"github.com/SteaceP/coderage/ratelimit"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/storage"
+	"github.com/SteaceP/coderage/webhooks"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// contractPathValues are the values of the path variables of the replayed
+// operations, naming the sample content of the demo mode where they can.
+// Other resources aren't found, and their error responses are checked.
+var contractPathValues = map[string]string{
+	"id":         "1",
+	"postId":     "1",
+	"userId":     "2",
+	"deliveryId": "1",
+	"slug":       "demo",
+	"username":   "reader",
+	"locale":     "fr",
+	"provider":   "github",
+	"email":      "nobody@example.com",
+	"name":       "example",
+}
+
+// contractSuccesses are the operations that must answer with their
+// documented success response, so the test can't pass on errors alone.
+var contractSuccesses = []string{
+	"GET /health",
+	"GET /posts",
+	"GET /posts/{id}",
+	"GET /posts/{postId}/comments",
+	"GET /users/profile",
+	"GET /users/{username}",
+	"GET /settings",
+	"GET /tags",
+	"GET /admin/users",
+	"GET /admin/audit-logs",
+	"GET /openapi.json",
+}
+
+// operation is an operation of the OpenAPI document.
+type operation struct {
+	method string
+	path   string
+	*openapi.Operation
+}
+
+// TestContract replays every documented operation against the demo server,
+// reading before writing and deleting last, and checks the responses
+// against the document: successes must have the documented status and
+// schema, and errors the documented error schema. Server errors fail the
+// test.
+func TestContract(t *testing.T) {
+	server, spec := newContractServer(t)
+	token := contractLogin(t, server.URL)
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		// Redirects are responses of their own
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	succeeded := make(map[string]bool)
+	ops := contractOperations(spec)
+	for _, op := range ops {
+		name := op.method + " " + op.path
+		t.Run(name, func(t *testing.T) {
+			status, ok := replay(t, client, server.URL, token, spec, op)
+			if ok {
+				succeeded[name] = true
+			} else if status >= 500 {
+				t.Errorf("server error %d", status)
+			}
+		})
+	}
+
+	t.Logf("%d of %d operations answered with their documented success", len(succeeded), len(ops))
+	for _, name := range contractSuccesses {
+		if !succeeded[name] {
+			t.Errorf("%s did not answer with its documented success response", name)
+		}
+	}
+}
+
+// newContractServer serves the API in demo mode, with the sample content
+// but without rate limiting, and returns the server with its OpenAPI
+// document.
+func newContractServer(t *testing.T) (*httptest.Server, *openapi.Document) {
+	t.Helper()
+	t.Setenv("CODERAGE_RATE_LIMIT_ENABLED", "false")
+	t.Setenv("CODERAGE_LOGGING_LEVEL", "error")
+
+	config.InitConfig()
+	config.EnableDemo()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("configuration loading failed: %v", err)
+	}
+	logger := zap.NewNop()
+
+	keys, err := jwtkeys.Load()
+	if err != nil {
+		t.Fatalf("JWT key loading failed: %v", err)
+	}
+	jwtkeys.Set(keys)
+	if err := i18n.Load(cfg.I18n.BundlesDir); err != nil {
+		t.Fatalf("translation bundles loading failed: %v", err)
+	}
+
+	db, err := database.InitDatabase(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The in-memory database is dropped with its last connection, for the
+	// next server to start from the sample content
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("database migrations failed: %v", err)
+	}
+	store, err := storage.New(context.Background())
+	if err != nil {
+		t.Fatalf("storage initialization failed: %v", err)
+	}
+	policy, err := ratelimit.NewPolicy()
+	if err != nil {
+		t.Fatalf("rate limit policy initialization failed: %v", err)
+	}
+
+	s := &Server{
+		router:   mux.NewRouter(),
+		db:       db,
+		bus:      events.Discard,
+		mailer:   mailer.Discard,
+		storage:  store,
+		policy:   policy,
+		services: services.New(db, mailer.Discard, webhooks.Discard, events.Discard, nil, nil, logger),
+		readOnly: database.NewReadOnly(db, logger),
+		logger:   logger,
+	}
+	if err := demo.New(db, s.services, logger).Seed(); err != nil {
+		t.Fatalf("demo initialization failed: %v", err)
+	}
+	s.setupRoutes()
+
+	spec, err := s.apiSpec()
+	if err != nil {
+		t.Fatalf("API specification failed: %v", err)
+	}
+	server := httptest.NewServer(middleware.RequestID(middleware.ConfigureCORS().Handler(s.router)))
+	t.Cleanup(server.Close)
+	return server, spec
+}
+
+// contractLogin logs in as the demo admin, and returns the access token.
+func contractLogin(t *testing.T, baseURL string) string {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"email": demo.AdminEmail, "password": config.Get().Demo.Password})
+	resp, err := http.Post(baseURL+"/users/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil || login.Token == "" {
+		t.Fatalf("login failed with status %d", resp.StatusCode)
+	}
+	return login.Token
+}
+
+// contractOperations returns the operations of the document in the order
+// they are replayed: reads, then writes, then deletions, by path.
+func contractOperations(spec *openapi.Document) []operation {
+	var ops []operation
+	for path, item := range spec.Paths {
+		for method, op := range item {
+			ops = append(ops, operation{method: strings.ToUpper(method), path: path, Operation: op})
+		}
+	}
+	rank := func(method string) int {
+		switch method {
+		case http.MethodGet:
+			return 0
+		case http.MethodDelete:
+			return 2
+		}
+		return 1
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ri, rj := rank(ops[i].method), rank(ops[j].method); ri != rj {
+			return ri < rj
+		}
+		if ops[i].path != ops[j].path {
+			return ops[i].path < ops[j].path
+		}
+		return ops[i].method < ops[j].method
+	})
+	return ops
+}
+
+// replay sends the request of an operation, with the required parameters
+// and an example body, and checks the response. It returns the status of
+// the response, and whether it is the documented success.
+func replay(t *testing.T, client *http.Client, baseURL, token string, spec *openapi.Document, op operation) (int, bool) {
+	path := op.path
+	query := make([]string, 0)
+	for _, param := range op.Parameters {
+		switch {
+		case param.In == "path":
+			value, ok := contractPathValues[param.Name]
+			if !ok {
+				t.Fatalf("no value for the path variable %q", param.Name)
+			}
+			path = strings.ReplaceAll(path, "{"+param.Name+"}", value)
+		case param.In == "query" && param.Required:
+			query = append(query, param.Name+"="+fmt.Sprint(example(spec, param.Schema, 0)))
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + strings.Join(query, "&")
+	}
+
+	var body io.Reader
+	if op.RequestBody != nil {
+		data, err := json.Marshal(example(spec, op.RequestBody.Content["application/json"].Schema, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(op.method, baseURL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The document describes the latest version of the responses
+	req.Header.Set(middleware.APIVersionHeader, fmt.Sprint(middleware.LatestAPIVersion))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(op.Security) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented, ok := op.Responses[fmt.Sprint(resp.StatusCode)]
+	switch {
+	case ok:
+	case resp.StatusCode < 400:
+		t.Errorf("undocumented status %d", resp.StatusCode)
+		return resp.StatusCode, false
+	default:
+		documented = op.Responses["default"]
+	}
+	checkBody(t, spec, documented, resp.Header.Get("Content-Type"), data)
+	return resp.StatusCode, ok && resp.StatusCode < 400
+}
+
+// checkBody checks a response body against its documented content.
+func checkBody(t *testing.T, spec *openapi.Document, documented openapi.Response, contentType string, data []byte) {
+	t.Helper()
+	if len(documented.Content) == 0 {
+		if len(data) > 0 && !strings.HasPrefix(contentType, "text/html") {
+			t.Errorf("undocumented %s body", contentType)
+		}
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := documented.Content[mediaType]
+	if !ok {
+		t.Errorf("undocumented content type %q", contentType)
+		return
+	}
+	if media.Schema == nil {
+		return
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		t.Errorf("invalid JSON body: %v", err)
+		return
+	}
+	for _, problem := range validate(spec, media.Schema, value, "body") {
+		t.Error(problem)
+	}
+}
+
+// resolve returns the schema a reference points to.
+func resolve(spec *openapi.Document, schema *openapi.Schema) *openapi.Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = spec.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// validate returns the differences between a JSON value and its schema.
+// Documented properties may be missing, as fields omitted when empty are,
+// but undocumented ones are reported.
+func validate(spec *openapi.Document, schema *openapi.Schema, value interface{}, at string) []string {
+	schema = resolve(spec, schema)
+	if schema == nil {
+		return nil
+	}
+	if value == nil {
+		if schema.Nullable || (schema.Type == "" && len(schema.AllOf) == 0) {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: null, documented as non-nullable", at)}
+	}
+	if len(schema.AllOf) > 0 {
+		var problems []string
+		for _, part := range schema.AllOf {
+			problems = append(problems, validate(spec, part, value, at)...)
+		}
+		return problems
+	}
+	if schema.Type == "" {
+		// Custom encodings are not described
+		return nil
+	}
+
+	mismatch := []string{fmt.Sprintf("%s: %T value, documented as %s", at, value, schema.Type)}
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch
+		}
+		var problems []string
+		for name, field := range object {
+			property, ok := schema.Properties[name]
+			if !ok {
+				property = schema.AdditionalProperties
+			}
+			if property == nil {
+				problems = append(problems, fmt.Sprintf("%s.%s: undocumented property", at, name))
+				continue
+			}
+			problems = append(problems, validate(spec, property, field, at+"."+name)...)
+		}
+		return problems
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return mismatch
+		}
+		var problems []string
+		for i, item := range array {
+			problems = append(problems, validate(spec, schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return problems
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return mismatch
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				return []string{fmt.Sprintf("%s: %q is not a date-time", at, s)}
+			}
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return mismatch
+		}
+		if _, err := n.Int64(); err != nil {
+			return []string{fmt.Sprintf("%s: %s is not an integer", at, n)}
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return mismatch
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return mismatch
+		}
+	}
+	return nil
+}
+
+// maxExampleDepth bounds the nesting of the example bodies, which would
+// never end for recursive schemas.
+const maxExampleDepth = 4
+
+// example returns an example value of a schema.
+func example(spec *openapi.Document, schema *openapi.Schema, depth int) interface{} {
+	schema = resolve(spec, schema)
+	if schema == nil || depth > maxExampleDepth {
+		return nil
+	}
+	if len(schema.AllOf) > 0 {
+		return example(spec, schema.AllOf[0], depth)
+	}
+	switch schema.Type {
+	case "object":
+		object := make(map[string]interface{}, len(schema.Properties))
+		for name, property := range schema.Properties {
+			if value := example(spec, property, depth+1); value != nil {
+				object[name] = value
+			}
+		}
+		return object
+	case "array":
+		if item := example(spec, schema.Items, depth+1); item != nil {
+			return []interface{}{item}
+		}
+		return []interface{}{}
+	case "string":
+		if schema.Format == "date-time" {
+			return time.Now().UTC().Format(time.RFC3339)
+		}
+		return "example"
+	case "integer", "number":
+		return 1
+	case "boolean":
+		return false
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Breaker is a circuit breaker guarding the database. It opens after a run
+// of queries failing to reach the database, or when a health check fails,
+// so requests can be turned away quickly instead of each waiting for the
+// database to time out. While open, the database is checked at every
+// interval and the breaker closes once it answers again.
+type Breaker struct {
+	sqlDB     *sql.DB
+	threshold int64
+	interval  time.Duration
+	logger    *zap.Logger
+
+	failures atomic.Int64 // Consecutive connection failures
+	open     atomic.Bool
+}
+
+// NewBreaker returns a new instance of Breaker guarding db, configured from
+// the "database.breaker" settings.
+func NewBreaker(db *gorm.DB, logger *zap.Logger) (*Breaker, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.Get().Database.Breaker
+	return &Breaker{
+		sqlDB:     sqlDB,
+		threshold: int64(cfg.FailureThreshold),
+		interval:  cfg.CheckInterval,
+		logger:    logger,
+	}, nil
+}
+
+// Open reports whether the database is considered unavailable.
+func (b *Breaker) Open() bool {
+	return b.open.Load()
+}
+
+// RetryAfter returns how long clients should wait before retrying while the
+// breaker is open.
+func (b *Breaker) RetryAfter() time.Duration {
+	return b.interval
+}
+
+// Run checks the database at every interval until the context is canceled.
+func (b *Breaker) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.check(ctx)
+		}
+	}
+}
+
+// check pings the database, opening or closing the breaker accordingly.
+func (b *Breaker) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, b.interval)
+	defer cancel()
+
+	if err := b.sqlDB.PingContext(ctx); err != nil {
+		b.trip(err)
+		return
+	}
+	if b.open.CompareAndSwap(true, false) {
+		b.failures.Store(0)
+		b.recoverPool()
+		b.logger.Info("Database available again, circuit breaker closed")
+	}
+}
+
+// trip opens the breaker.
+func (b *Breaker) trip(err error) {
+	if b.open.CompareAndSwap(false, true) {
+		b.logger.Error("Database unavailable, circuit breaker opened", zap.Error(err))
+	}
+}
+
+// recoverPool drops the idle connections of the pool, which may have been
+// cut by the outage, so the next queries open fresh connections.
+func (b *Breaker) recoverPool() {
+	b.sqlDB.SetMaxIdleConns(0)
+	b.sqlDB.SetMaxIdleConns(maxIdleConns)
+}
+
+// Name returns the name of the breaker as a Gorm plugin.
+func (b *Breaker) Name() string {
+	return "breaker"
+}
+
+// Initialize registers the callbacks counting the queries failing to reach
+// the database.
+func (b *Breaker) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().After("gorm:create").Register("breaker:after_create", b.observe),
+		callbacks.Query().After("gorm:query").Register("breaker:after_query", b.observe),
+		callbacks.Update().After("gorm:update").Register("breaker:after_update", b.observe),
+		callbacks.Delete().After("gorm:delete").Register("breaker:after_delete", b.observe),
+		callbacks.Row().After("gorm:row").Register("breaker:after_row", b.observe),
+		callbacks.Raw().After("gorm:raw").Register("breaker:after_raw", b.observe),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observe counts the consecutive queries failing to reach the database, and
+// opens the breaker when they reach the threshold.
+func (b *Breaker) observe(db *gorm.DB) {
+	if !connectionError(db.Error) {
+		if db.Error == nil {
+			b.failures.Store(0)
+		}
+		return
+	}
+	if b.threshold > 0 && b.failures.Add(1) >= b.threshold {
+		b.trip(db.Error)
+	}
+}
+
+// connectionError reports whether err means the database couldn't be
+// reached, rather than a query being rejected.
+func connectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var (
+		connectErr *pgconn.ConnectError
+		pgErr      *pgconn.PgError
+		netErr     net.Error
+	)
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		// Queries cancelled with their request
+		return false
+	case errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &connectErr),
+		errors.As(err, &netErr):
+		return true
+	case errors.As(err, &pgErr):
+		// Connection exceptions, and the server shutting down or starting up
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "57P")
+	}
+	return false
+}
@@ -2,66 +2,103 @@ package database
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/SteaceP/coderage/internal/models"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
 
-	"github.com/spf13/viper"
+	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func InitDatabase() (*gorm.DB, error) {
-	host := viper.GetString("database.host")
-	if host == "" {
-		return nil, fmt.Errorf("database host is not set")
-	}
+// Connection pool limits
+const (
+	maxOpenConns = 25
+	maxIdleConns = 25
+)
 
-	port := viper.GetInt("database.port")
-	if port == 0 {
-		return nil, fmt.Errorf("database port is not set")
+// InitDatabase connects to the database configured by the "database"
+// settings, which are checked when the configuration is loaded.
+//
+// A database that isn't up yet, such as one starting alongside the API, is
+// retried "database.connect_retries" times, waiting twice as long after
+// every attempt from "database.connect_backoff" up to
+// "database.connect_max_backoff".
+//
+// The failed and slow queries are logged to logger.
+func InitDatabase(logger *zap.Logger) (*gorm.DB, error) {
+	cfg := config.Get().Database
+	if cfg.Type == "sqlite" {
+		return initSQLite(cfg.Name, logger)
 	}
 
-	user := viper.GetString("database.user")
-	if user == "" {
-		return nil, fmt.Errorf("database user is not set")
-	}
+	dsn := postgresDSN(cfg, cfg.Host, cfg.Port)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: NewLogger(logger)})
+	backoff := cfg.ConnectBackoff
+	for attempt := 1; err != nil && attempt <= cfg.ConnectRetries; attempt++ {
+		logger.Warn("Database connection failed, retrying",
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, cfg.ConnectMaxBackoff)
 
-	password := viper.GetString("database.password")
-	if password == "" {
-		return nil, fmt.Errorf("database password is not set")
+		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: NewLogger(logger)})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
 
-	name := viper.GetString("database.name")
-	if name == "" {
-		return nil, fmt.Errorf("database name is not set")
+	// Configure connection pool
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database connection pool: %v", err)
 	}
 
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetConnMaxLifetime(5 * time.Minute)
+
+	return db, nil
+
+}
+
+// postgresDSN returns the data source name of the database on the given
+// host, such as a read replica.
+func postgresDSN(cfg config.DatabaseConfig, host string, port int) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		host,
 		port,
-		user,
-		password,
-		name,
+		cfg.User,
+		cfg.Password,
+		cfg.Name,
 	)
+}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// initSQLite opens the SQLite database held in the file name, or in memory
+// for the demo mode. A single connection is kept open, for the database to
+// stay in memory and because SQLite serializes the writes anyway.
+func initSQLite(name string, logger *zap.Logger) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{Logger: NewLogger(logger)})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %v", err)
+		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
-	// Configure connection pool
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database connection pool: %v", err)
 	}
-
-	sqlDB.SetMaxOpenConns(25)
-	sqlDB.SetMaxIdleConns(25)
-	sqlDB.SetConnMaxLifetime(5 * time.Minute)
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetConnMaxLifetime(0)
 
 	return db, nil
-
 }
 
 func RunMigrations(db *gorm.DB) error {
@@ -74,10 +111,163 @@ func RunMigrations(db *gorm.DB) error {
 		&models.User{},
 		&models.Post{},
 		&models.Comment{},
+		&models.CommentLike{},
+		&models.SiteSettings{},
+		&models.Tag{},
+		&models.VerificationToken{},
+		&models.Media{},
+		&models.MediaVariant{},
+		&models.AuditLog{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.Notification{},
+		&models.CommentReport{},
+		&models.Referral{},
+		&models.Announcement{},
+		&models.LinkPreview{},
+		&models.Identity{},
+		&models.OnboardingStep{},
+		&models.EmailSuppression{},
+		&models.Plan{},
+		&models.SearchQuery{},
+		&models.PostViewDay{},
+		&models.Session{},
+		&models.Integration{},
+		&models.IntegrationComment{},
+		&models.PostTranslation{},
+		&models.Category{},
+		&models.Series{},
+		&models.SeriesPost{},
+		&models.PostAuthor{},
 	)
 	if err != nil {
 		return fmt.Errorf("database migration failed: %v", err)
 	}
 
+	if err := migrateLegacyTags(db); err != nil {
+		return fmt.Errorf("tag migration failed: %v", err)
+	}
+
+	if err := repositories.NewSettingsRepository(db).CreateDefaults(); err != nil {
+		return fmt.Errorf("failed to create the site settings: %v", err)
+	}
+
 	return nil
 }
+
+// migrateLegacyTags moves the tags stored in the former posts.tags text[]
+// column into the tags and post_tags tables, then drops the column. It does
+// nothing once the column is gone.
+func migrateLegacyTags(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("posts", "tags") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		rows, err := legacyTags(tx)
+		if err != nil {
+			return err
+		}
+
+		tagRepo := repositories.NewTagRepository(tx)
+		for _, row := range rows {
+			tags, err := tagRepo.FindOrCreateByNames([]string{row.Name})
+			if err != nil {
+				return err
+			}
+			if len(tags) == 0 {
+				continue
+			}
+
+			post := models.Post{Model: gorm.Model{ID: row.PostID}}
+			if err := tx.Model(&post).Association("Tags").Append(&tags[0]); err != nil {
+				return err
+			}
+		}
+
+		// Dropped with SQL, the SQLite migrator can only drop the columns of
+		// models
+		return tx.Exec("ALTER TABLE posts DROP COLUMN tags").Error
+	})
+}
+
+// legacyTag is a tag of a post in the former posts.tags column
+type legacyTag struct {
+	PostID uint
+	Name   string
+}
+
+// legacyTags reads the former posts.tags column. Postgres unnests the
+// array; SQLite, which has no arrays, holds the text of the array literal,
+// such as {go,"web dev"}, which is parsed.
+func legacyTags(db *gorm.DB) ([]legacyTag, error) {
+	var tags []legacyTag
+	if db.Dialector.Name() == "postgres" {
+		err := db.Raw("SELECT id AS post_id, unnest(tags) AS name FROM posts").
+			Scan(&tags).Error
+		return tags, err
+	}
+
+	var rows []struct {
+		ID   uint
+		Tags *string
+	}
+	if err := db.Raw("SELECT id, tags FROM posts").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if row.Tags == nil {
+			continue
+		}
+		for _, name := range parseTextArray(*row.Tags) {
+			tags = append(tags, legacyTag{PostID: row.ID, Name: name})
+		}
+	}
+	return tags, nil
+}
+
+// parseTextArray parses a Postgres text array literal. Elements may be
+// double-quoted, with backslashes escaping quotes and backslashes, and NULL
+// elements are left out.
+func parseTextArray(literal string) []string {
+	literal = strings.TrimSpace(literal)
+	literal = strings.TrimPrefix(literal, "{")
+	literal = strings.TrimSuffix(literal, "}")
+	if literal == "" {
+		return nil
+	}
+
+	var (
+		elements  []string
+		element   strings.Builder
+		quoted    bool // Inside double quotes
+		wasQuoted bool // The current element was quoted, so it isn't NULL
+	)
+	end := func() {
+		value := element.String()
+		if wasQuoted || !strings.EqualFold(strings.TrimSpace(value), "NULL") {
+			if !wasQuoted {
+				value = strings.TrimSpace(value)
+			}
+			elements = append(elements, value)
+		}
+		element.Reset()
+		wasQuoted = false
+	}
+	for i := 0; i < len(literal); i++ {
+		switch c := literal[i]; {
+		case c == '\\' && i+1 < len(literal):
+			i++
+			element.WriteByte(literal[i])
+		case c == '"':
+			quoted = !quoted
+			wasQuoted = true
+		case c == ',' && !quoted:
+			end()
+		default:
+			element.WriteByte(c)
+		}
+	}
+	end()
+	return elements
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// queryLogger is the gorm logger writing to the application logger. Only
+// the failed queries, and those slower than
+// "database.slow_query_threshold", are logged, with their statement holding
+// the placeholders, never the values.
+type queryLogger struct {
+	logger *zap.Logger
+	level  gormlogger.LogLevel
+}
+
+// NewLogger returns the gorm logger of the queries, writing to logger.
+func NewLogger(logger *zap.Logger) gormlogger.Interface {
+	return &queryLogger{logger: logger.Named("gorm"), level: gormlogger.Warn}
+}
+
+// LogMode returns a copy of the logger with the given level.
+func (l *queryLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &queryLogger{logger: l.logger, level: level}
+}
+
+// Info logs a message of gorm, such as a migration notice.
+func (l *queryLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.logger.Info(fmt.Sprintf(msg, args...), requestField(ctx))
+	}
+}
+
+// Warn logs a warning of gorm.
+func (l *queryLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.logger.Warn(fmt.Sprintf(msg, args...), requestField(ctx))
+	}
+}
+
+// Error logs an error of gorm.
+func (l *queryLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.logger.Error(fmt.Sprintf(msg, args...), requestField(ctx))
+	}
+}
+
+// Trace logs a query which failed or took at least
+// "database.slow_query_threshold". Records not found are not failures.
+func (l *queryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	threshold := config.Get().Database.SlowQueryThreshold
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	slow := threshold > 0 && elapsed >= threshold
+	if !failed && !slow {
+		return
+	}
+
+	sql, rows := fc()
+	fields := []zap.Field{
+		zap.String("sql", sql),
+		zap.Duration("duration", elapsed),
+		zap.Int64("rows", rows),
+		requestField(ctx),
+	}
+	switch {
+	case failed && l.level >= gormlogger.Error:
+		l.logger.Error("Query failed", append(fields, zap.Error(err))...)
+	case slow && l.level >= gormlogger.Warn:
+		l.logger.Warn("Slow query", append(fields, zap.Duration("threshold", threshold))...)
+	}
+}
+
+// ParamsFilter drops the values of the queries, leaving their placeholders
+// in the statements logged.
+func (l *queryLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+// requestField returns the ID of the request running a query, empty for the
+// background jobs.
+func requestField(ctx context.Context) zap.Field {
+	var id string
+	if ctx != nil {
+		id, _ = ctx.Value(types.KeyRequestID).(string)
+	}
+	return zap.String("request_id", id)
+}
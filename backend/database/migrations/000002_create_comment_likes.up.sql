@@ -0,0 +1,11 @@
+CREATE TABLE comment_likes (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  user_id BIGINT NOT NULL,
+  comment_id BIGINT NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id),
+  FOREIGN KEY (comment_id) REFERENCES comments(id)
+);
+
+CREATE UNIQUE INDEX idx_comment_likes_user_comment ON comment_likes (user_id, comment_id);
+CREATE INDEX idx_comment_likes_comment_id ON comment_likes (comment_id);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN pending_approval;
+DROP TABLE IF EXISTS site_settings;
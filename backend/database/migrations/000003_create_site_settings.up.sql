@@ -0,0 +1,11 @@
+CREATE TABLE site_settings (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP NULL,
+  registration_open BOOLEAN DEFAULT TRUE NOT NULL,
+  require_approval BOOLEAN DEFAULT FALSE NOT NULL,
+  comments_enabled BOOLEAN DEFAULT TRUE NOT NULL
+);
+
+ALTER TABLE users ADD COLUMN pending_approval BOOLEAN DEFAULT FALSE NOT NULL;
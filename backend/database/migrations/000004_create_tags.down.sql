@@ -0,0 +1,11 @@
+ALTER TABLE posts ADD COLUMN tags TEXT[] NULL;
+
+UPDATE posts SET tags = (
+  SELECT array_agg(tags.name)
+  FROM post_tags
+  JOIN tags ON tags.id = post_tags.tag_id
+  WHERE post_tags.post_id = posts.id
+);
+
+DROP TABLE IF EXISTS post_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,28 @@
+CREATE TABLE tags (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  name VARCHAR(50) UNIQUE NOT NULL,
+  slug VARCHAR(60) UNIQUE NOT NULL
+);
+
+CREATE TABLE post_tags (
+  post_id BIGINT NOT NULL,
+  tag_id BIGINT NOT NULL,
+  PRIMARY KEY (post_id, tag_id),
+  FOREIGN KEY (post_id) REFERENCES posts(id),
+  FOREIGN KEY (tag_id) REFERENCES tags(id)
+);
+
+-- Move the tags of the posts.tags array into the new tables
+INSERT INTO tags (name, slug)
+SELECT DISTINCT ON (lower(regexp_replace(tag, '\s+', '-', 'g'))) tag, lower(regexp_replace(tag, '\s+', '-', 'g'))
+FROM posts, unnest(tags) AS tag
+ON CONFLICT DO NOTHING;
+
+INSERT INTO post_tags (post_id, tag_id)
+SELECT DISTINCT posts.id, tags.id
+FROM posts, unnest(posts.tags) AS tag
+JOIN tags ON tags.slug = lower(regexp_replace(tag, '\s+', '-', 'g'))
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE posts DROP COLUMN tags;
@@ -0,0 +1,3 @@
+ALTER TABLE site_settings DROP COLUMN sensitive_gating;
+ALTER TABLE users DROP COLUMN show_sensitive;
+ALTER TABLE posts DROP COLUMN sensitive;
@@ -0,0 +1,3 @@
+ALTER TABLE posts ADD COLUMN sensitive BOOLEAN DEFAULT FALSE NOT NULL;
+ALTER TABLE users ADD COLUMN show_sensitive BOOLEAN DEFAULT FALSE NOT NULL;
+ALTER TABLE site_settings ADD COLUMN sensitive_gating BOOLEAN DEFAULT TRUE NOT NULL;
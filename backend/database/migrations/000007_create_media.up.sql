@@ -0,0 +1,16 @@
+CREATE TABLE media (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP NULL,
+  user_id BIGINT NOT NULL,
+  backend VARCHAR(20) NOT NULL,
+  storage_key VARCHAR(255) UNIQUE NOT NULL,
+  url TEXT NOT NULL,
+  original_name VARCHAR(255) NULL,
+  content_type VARCHAR(100) NOT NULL,
+  size BIGINT NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_media_user_id ON media (user_id);
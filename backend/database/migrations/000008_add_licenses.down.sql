@@ -0,0 +1,2 @@
+ALTER TABLE site_settings DROP COLUMN default_license;
+ALTER TABLE posts DROP COLUMN license;
@@ -0,0 +1,2 @@
+ALTER TABLE posts ADD COLUMN license VARCHAR(50) NULL;
+ALTER TABLE site_settings ADD COLUMN default_license VARCHAR(50) DEFAULT 'all-rights-reserved' NOT NULL;
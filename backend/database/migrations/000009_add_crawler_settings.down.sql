@@ -0,0 +1,2 @@
+ALTER TABLE site_settings DROP COLUMN security_contact;
+ALTER TABLE site_settings DROP COLUMN robots_rules;
@@ -0,0 +1,2 @@
+ALTER TABLE site_settings ADD COLUMN robots_rules TEXT NULL;
+ALTER TABLE site_settings ADD COLUMN security_contact VARCHAR(255) DEFAULT '' NOT NULL;
@@ -0,0 +1,15 @@
+CREATE TABLE audit_logs (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  actor_id BIGINT NOT NULL,
+  action VARCHAR(50) NOT NULL,
+  target_type VARCHAR(50) NOT NULL,
+  target_id BIGINT NOT NULL,
+  details TEXT NULL,
+  remote_addr VARCHAR(100) NULL,
+  request_id VARCHAR(128) NULL,
+  FOREIGN KEY (actor_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_audit_logs_actor_id ON audit_logs (actor_id);
+CREATE INDEX idx_audit_logs_action ON audit_logs (action);
@@ -0,0 +1,36 @@
+CREATE TABLE webhooks (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP NULL,
+  url TEXT NOT NULL,
+  secret VARCHAR(255) NOT NULL,
+  events TEXT NOT NULL,
+  active BOOLEAN DEFAULT TRUE,
+  description VARCHAR(255) NULL
+);
+
+CREATE TABLE webhook_deliveries (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  webhook_id BIGINT NOT NULL,
+  guid VARCHAR(36) UNIQUE NOT NULL,
+  event_type VARCHAR(100) NOT NULL,
+  redelivery_of BIGINT NULL,
+  success BOOLEAN DEFAULT FALSE,
+  duration_ms BIGINT NOT NULL DEFAULT 0,
+  error TEXT NULL,
+  request_headers TEXT NULL,
+  request_body TEXT NOT NULL,
+  status_code INT NOT NULL DEFAULT 0,
+  response_headers TEXT NULL,
+  response_body TEXT NULL,
+  signature_algorithm VARCHAR(20) NOT NULL,
+  signature VARCHAR(100) NOT NULL,
+  payload_sha256 VARCHAR(64) NOT NULL,
+  secret_fingerprint VARCHAR(16) NOT NULL,
+  FOREIGN KEY (webhook_id) REFERENCES webhooks(id),
+  FOREIGN KEY (redelivery_of) REFERENCES webhook_deliveries(id)
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
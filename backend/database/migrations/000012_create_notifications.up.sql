@@ -0,0 +1,19 @@
+CREATE TABLE notifications (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP NULL,
+  user_id BIGINT NOT NULL,
+  type VARCHAR(50) NOT NULL,
+  group_key VARCHAR(100) NOT NULL,
+  post_id BIGINT NULL,
+  comment_id BIGINT NULL,
+  count INT NOT NULL DEFAULT 1,
+  actor_count INT NOT NULL DEFAULT 1,
+  latest_actor_ids TEXT NULL,
+  latest_at TIMESTAMP NOT NULL,
+  read_at TIMESTAMP NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_notifications_user_group ON notifications (user_id, group_key);
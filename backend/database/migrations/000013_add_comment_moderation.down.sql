@@ -0,0 +1,5 @@
+DROP INDEX idx_comments_status ON comments;
+
+ALTER TABLE comments DROP COLUMN moderation_reason;
+ALTER TABLE comments DROP COLUMN moderated_at;
+ALTER TABLE comments DROP COLUMN moderated_by_id;
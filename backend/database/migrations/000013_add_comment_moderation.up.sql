@@ -0,0 +1,5 @@
+ALTER TABLE comments ADD COLUMN moderated_by_id BIGINT NULL;
+ALTER TABLE comments ADD COLUMN moderated_at TIMESTAMP NULL;
+ALTER TABLE comments ADD COLUMN moderation_reason VARCHAR(255) NULL;
+
+CREATE INDEX idx_comments_status ON comments (status);
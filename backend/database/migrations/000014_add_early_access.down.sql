@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN member;
+ALTER TABLE posts DROP COLUMN members_only_until;
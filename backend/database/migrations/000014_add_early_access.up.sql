@@ -0,0 +1,2 @@
+ALTER TABLE posts ADD COLUMN members_only_until TIMESTAMP NULL;
+ALTER TABLE users ADD COLUMN member BOOLEAN DEFAULT FALSE NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN report_count;
+
+DROP TABLE IF EXISTS comment_reports;
@@ -0,0 +1,17 @@
+CREATE TABLE comment_reports (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  comment_id BIGINT NOT NULL,
+  user_id BIGINT NOT NULL,
+  reason VARCHAR(20) NOT NULL,
+  details VARCHAR(500) NULL,
+  resolved_at TIMESTAMP NULL,
+  resolved_by_id BIGINT NULL,
+  FOREIGN KEY (comment_id) REFERENCES comments(id),
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX idx_comment_reports_comment_user ON comment_reports (comment_id, user_id);
+CREATE INDEX idx_comment_reports_comment_id ON comment_reports (comment_id);
+
+ALTER TABLE comments ADD COLUMN report_count INT DEFAULT 0 NOT NULL;
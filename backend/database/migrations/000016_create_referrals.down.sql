@@ -0,0 +1,4 @@
+ALTER TABLE site_settings DROP COLUMN referrals_enabled;
+DROP INDEX idx_users_referral_code ON users;
+ALTER TABLE users DROP COLUMN referral_code;
+DROP TABLE IF EXISTS referrals;
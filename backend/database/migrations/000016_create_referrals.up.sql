@@ -0,0 +1,17 @@
+CREATE TABLE referrals (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  referrer_id BIGINT NOT NULL,
+  referred_id BIGINT NOT NULL,
+  code VARCHAR(16) NOT NULL,
+  FOREIGN KEY (referrer_id) REFERENCES users(id),
+  FOREIGN KEY (referred_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX idx_referrals_referred_id ON referrals (referred_id);
+CREATE INDEX idx_referrals_referrer_id ON referrals (referrer_id);
+
+ALTER TABLE users ADD COLUMN referral_code VARCHAR(16) NULL;
+CREATE UNIQUE INDEX idx_users_referral_code ON users (referral_code);
+
+ALTER TABLE site_settings ADD COLUMN referrals_enabled BOOLEAN DEFAULT TRUE NOT NULL;
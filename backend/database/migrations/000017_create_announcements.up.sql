@@ -0,0 +1,15 @@
+CREATE TABLE announcements (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP NULL,
+  message VARCHAR(500) NOT NULL,
+  severity VARCHAR(20) DEFAULT 'info' NOT NULL,
+  audience VARCHAR(20) DEFAULT 'all' NOT NULL,
+  starts_at TIMESTAMP NULL,
+  ends_at TIMESTAMP NULL,
+  created_by_id BIGINT NOT NULL,
+  FOREIGN KEY (created_by_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_announcements_window ON announcements (starts_at, ends_at);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN token_version INT DEFAULT 0 NOT NULL;
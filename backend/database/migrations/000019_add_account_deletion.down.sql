@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN anonymized;
+DROP INDEX idx_users_purge_after ON users;
+ALTER TABLE users DROP COLUMN purge_after;
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN purge_after TIMESTAMP NULL;
+CREATE INDEX idx_users_purge_after ON users (purge_after);
+ALTER TABLE comments ADD COLUMN anonymized BOOLEAN DEFAULT FALSE NOT NULL;
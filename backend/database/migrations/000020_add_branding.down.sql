@@ -0,0 +1,5 @@
+ALTER TABLE site_settings DROP COLUMN custom_head;
+ALTER TABLE site_settings DROP COLUMN accent_color;
+ALTER TABLE site_settings DROP COLUMN primary_color;
+ALTER TABLE site_settings DROP COLUMN favicon_media_id;
+ALTER TABLE site_settings DROP COLUMN logo_media_id;
@@ -0,0 +1,5 @@
+ALTER TABLE site_settings ADD COLUMN logo_media_id BIGINT NULL;
+ALTER TABLE site_settings ADD COLUMN favicon_media_id BIGINT NULL;
+ALTER TABLE site_settings ADD COLUMN primary_color VARCHAR(7) DEFAULT '' NOT NULL;
+ALTER TABLE site_settings ADD COLUMN accent_color VARCHAR(7) DEFAULT '' NOT NULL;
+ALTER TABLE site_settings ADD COLUMN custom_head TEXT NULL;
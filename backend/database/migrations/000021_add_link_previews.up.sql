@@ -0,0 +1,14 @@
+CREATE TABLE link_previews (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  url_hash CHAR(64) NOT NULL,
+  url TEXT NOT NULL,
+  title VARCHAR(300) DEFAULT '' NOT NULL,
+  description TEXT NULL,
+  image TEXT NULL,
+  site_name VARCHAR(300) DEFAULT '' NOT NULL,
+  type VARCHAR(50) DEFAULT '' NOT NULL,
+  error VARCHAR(255) DEFAULT '' NOT NULL,
+  fetched_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_link_previews_url_hash ON link_previews (url_hash);
@@ -0,0 +1,12 @@
+CREATE TABLE identities (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  user_id BIGINT NOT NULL,
+  provider VARCHAR(20) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  email VARCHAR(255) DEFAULT '' NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX idx_identities_user_provider ON identities (user_id, provider);
+CREATE UNIQUE INDEX idx_identities_provider_subject ON identities (provider, subject);
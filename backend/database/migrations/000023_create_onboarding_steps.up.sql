@@ -0,0 +1,9 @@
+CREATE TABLE onboarding_steps (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  step VARCHAR(50) NOT NULL,
+  completed_at TIMESTAMP NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX idx_onboarding_steps_user_step ON onboarding_steps (user_id, step);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN email_status;
+
+DROP TABLE email_suppressions;
@@ -0,0 +1,13 @@
+CREATE TABLE email_suppressions (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  email VARCHAR(255) NOT NULL,
+  reason VARCHAR(20) NOT NULL,
+  provider VARCHAR(20) DEFAULT '' NOT NULL,
+  detail TEXT NULL
+);
+
+CREATE UNIQUE INDEX idx_email_suppressions_email ON email_suppressions (email);
+
+ALTER TABLE users ADD COLUMN email_status VARCHAR(20) DEFAULT '' NOT NULL;
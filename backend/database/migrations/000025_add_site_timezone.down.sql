@@ -0,0 +1,2 @@
+ALTER TABLE site_settings DROP COLUMN date_format;
+ALTER TABLE site_settings DROP COLUMN timezone;
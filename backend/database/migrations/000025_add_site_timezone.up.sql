@@ -0,0 +1,2 @@
+ALTER TABLE site_settings ADD COLUMN timezone VARCHAR(64) DEFAULT 'UTC' NOT NULL;
+ALTER TABLE site_settings ADD COLUMN date_format VARCHAR(16) DEFAULT 'iso' NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE site_settings DROP COLUMN plan_id;
+DROP TABLE IF EXISTS plans;
@@ -0,0 +1,12 @@
+CREATE TABLE plans (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  name VARCHAR(50) NOT NULL UNIQUE,
+  max_posts BIGINT DEFAULT 0 NOT NULL,
+  max_comments BIGINT DEFAULT 0 NOT NULL,
+  max_storage_bytes BIGINT DEFAULT 0 NOT NULL,
+  max_members BIGINT DEFAULT 0 NOT NULL
+);
+
+ALTER TABLE site_settings ADD COLUMN plan_id BIGINT NULL;
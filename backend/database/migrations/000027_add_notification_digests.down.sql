@@ -0,0 +1,2 @@
+ALTER TABLE notifications DROP COLUMN digested_at;
+ALTER TABLE users DROP COLUMN email_digest;
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN email_digest BOOLEAN DEFAULT FALSE NOT NULL;
+ALTER TABLE notifications ADD COLUMN digested_at TIMESTAMP NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE search_queries (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  query VARCHAR(100) NOT NULL,
+  result_count BIGINT DEFAULT 0 NOT NULL,
+  clicked_post_id BIGINT NULL,
+  clicked_at TIMESTAMP NULL
+);
+
+CREATE INDEX idx_search_queries_created_at ON search_queries (created_at);
+CREATE INDEX idx_search_queries_query ON search_queries (query);
@@ -0,0 +1,2 @@
+ALTER TABLE site_settings DROP COLUMN read_only_message;
+ALTER TABLE site_settings DROP COLUMN read_only;
@@ -0,0 +1,2 @@
+ALTER TABLE site_settings ADD COLUMN read_only BOOLEAN DEFAULT FALSE NOT NULL;
+ALTER TABLE site_settings ADD COLUMN read_only_message VARCHAR(255) DEFAULT '' NOT NULL;
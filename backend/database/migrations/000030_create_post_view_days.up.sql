@@ -0,0 +1,10 @@
+CREATE TABLE post_view_days (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  post_id BIGINT NOT NULL,
+  day TIMESTAMP NOT NULL,
+  views BIGINT DEFAULT 0 NOT NULL,
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_post_view_days_post_day ON post_view_days (post_id, day);
+CREATE INDEX idx_post_view_days_day ON post_view_days (day);
@@ -0,0 +1 @@
+ALTER TABLE media ADD COLUMN content_hash VARCHAR(64) DEFAULT '' NOT NULL;
@@ -0,0 +1,15 @@
+CREATE TABLE sessions (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  user_id BIGINT NOT NULL,
+  token_version INT DEFAULT 0 NOT NULL,
+  refresh_uuid VARCHAR(36) NOT NULL,
+  user_agent VARCHAR(512) DEFAULT '' NOT NULL,
+  ip VARCHAR(45) DEFAULT '' NOT NULL,
+  last_used_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX idx_sessions_expires_at ON sessions (expires_at);
@@ -0,0 +1,4 @@
+DROP INDEX idx_webhook_deliveries_next_retry_at ON webhook_deliveries;
+
+ALTER TABLE webhook_deliveries DROP COLUMN next_retry_at;
+ALTER TABLE webhook_deliveries DROP COLUMN attempt;
@@ -0,0 +1,4 @@
+ALTER TABLE webhook_deliveries ADD COLUMN attempt INT DEFAULT 0 NOT NULL;
+ALTER TABLE webhook_deliveries ADD COLUMN next_retry_at TIMESTAMP NULL;
+
+CREATE INDEX idx_webhook_deliveries_next_retry_at ON webhook_deliveries (next_retry_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS integration_comments;
+DROP TABLE IF EXISTS integrations;
@@ -0,0 +1,23 @@
+CREATE TABLE integrations (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP NULL,
+  name VARCHAR(255) NOT NULL,
+  secret VARCHAR(255) NOT NULL,
+  moderated BOOLEAN DEFAULT FALSE,
+  description VARCHAR(255) NULL
+);
+
+CREATE TABLE integration_comments (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  integration_id BIGINT NOT NULL,
+  external_id VARCHAR(255) NOT NULL,
+  comment_id BIGINT NOT NULL,
+  FOREIGN KEY (integration_id) REFERENCES integrations(id),
+  FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_integration_comments_external_id ON integration_comments (integration_id, external_id);
+CREATE INDEX idx_integration_comments_comment_id ON integration_comments (comment_id);
@@ -0,0 +1,17 @@
+ALTER TABLE posts ADD COLUMN locale VARCHAR(35) DEFAULT '' NOT NULL;
+
+CREATE TABLE post_translations (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  post_id BIGINT NOT NULL,
+  locale VARCHAR(35) NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  content TEXT NOT NULL,
+  excerpt TEXT NULL,
+  meta_title VARCHAR(255) NULL,
+  meta_description VARCHAR(255) NULL,
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_post_translations_locale ON post_translations (post_id, locale);
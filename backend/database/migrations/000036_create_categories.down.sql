@@ -0,0 +1,5 @@
+DROP INDEX idx_posts_category_id ON posts;
+ALTER TABLE posts DROP FOREIGN KEY fk_posts_category;
+ALTER TABLE posts DROP COLUMN category_id;
+
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,18 @@
+CREATE TABLE categories (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  name VARCHAR(50) NOT NULL,
+  slug VARCHAR(60) NOT NULL,
+  description TEXT NULL,
+  parent_id BIGINT NULL,
+  position INT DEFAULT 0 NOT NULL,
+  FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX idx_categories_slug ON categories (slug);
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
+
+ALTER TABLE posts ADD COLUMN category_id BIGINT NULL;
+ALTER TABLE posts ADD CONSTRAINT fk_posts_category FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL;
+CREATE INDEX idx_posts_category_id ON posts (category_id);
@@ -0,0 +1,25 @@
+CREATE TABLE series (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  title VARCHAR(200) NOT NULL,
+  slug VARCHAR(200) NOT NULL,
+  description TEXT NULL,
+  user_id BIGINT NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX idx_series_slug ON series (slug);
+CREATE INDEX idx_series_user_id ON series (user_id);
+
+CREATE TABLE series_posts (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  series_id BIGINT NOT NULL,
+  post_id BIGINT NOT NULL,
+  position INT NOT NULL,
+  FOREIGN KEY (series_id) REFERENCES series(id) ON DELETE CASCADE,
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_series_posts_series_id ON series_posts (series_id);
+CREATE UNIQUE INDEX idx_series_posts_post_id ON series_posts (post_id);
@@ -0,0 +1,16 @@
+CREATE TABLE post_authors (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  post_id BIGINT NOT NULL,
+  user_id BIGINT NOT NULL,
+  role VARCHAR(20) NOT NULL DEFAULT 'contributor',
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_post_authors_post_user ON post_authors (post_id, user_id);
+CREATE INDEX idx_post_authors_user_id ON post_authors (user_id);
+
+-- Credit the lead author of the existing posts
+INSERT INTO post_authors (created_at, post_id, user_id, role)
+SELECT created_at, id, user_id, 'author' FROM posts;
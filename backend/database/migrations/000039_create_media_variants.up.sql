@@ -0,0 +1,19 @@
+ALTER TABLE media ADD COLUMN width INT DEFAULT 0 NOT NULL;
+ALTER TABLE media ADD COLUMN height INT DEFAULT 0 NOT NULL;
+
+CREATE TABLE media_variants (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  media_id BIGINT NOT NULL,
+  name VARCHAR(32) NOT NULL,
+  storage_key VARCHAR(255) NOT NULL,
+  url TEXT NOT NULL,
+  content_type VARCHAR(100) NOT NULL,
+  width INT NOT NULL,
+  height INT NOT NULL,
+  size BIGINT NOT NULL,
+  FOREIGN KEY (media_id) REFERENCES media(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_media_variants_media_id ON media_variants (media_id);
+CREATE UNIQUE INDEX idx_media_variants_storage_key ON media_variants (storage_key);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN guest BOOLEAN DEFAULT FALSE NOT NULL;
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/types"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// queryStartKey stores the start time of a query on its statement
+const queryStartKey = "query_plans:start"
+
+// QueryPlan is the plan of a query run by a request.
+type QueryPlan struct {
+	SQL      string // Statement with its placeholders, never the values
+	Duration time.Duration
+	Plan     string
+	Err      error // Set when the query could not be explained
+}
+
+// QueryPlans collects the plans of the queries of a request. It is attached
+// to the request context with WithQueryPlans.
+type QueryPlans struct {
+	mu      sync.Mutex
+	plans   []QueryPlan
+	skipped int
+}
+
+// WithQueryPlans returns a copy of ctx in which the queries are explained
+// into plans.
+func WithQueryPlans(ctx context.Context, plans *QueryPlans) context.Context {
+	return context.WithValue(ctx, types.KeyQueryPlans, plans)
+}
+
+// Plans returns the plans collected so far.
+func (p *QueryPlans) Plans() []QueryPlan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]QueryPlan(nil), p.plans...)
+}
+
+// Skipped returns the number of queries left unexplained once the request
+// reached the most plans allowed by "database.query_plans.max_queries".
+func (p *QueryPlans) Skipped() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.skipped
+}
+
+// reserve returns the index of the plan of another query of the request,
+// or false when the query must be skipped.
+func (p *QueryPlans) reserve(limit int) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.plans) >= limit {
+		p.skipped++
+		return 0, false
+	}
+	p.plans = append(p.plans, QueryPlan{})
+	return len(p.plans) - 1, true
+}
+
+// set stores the plan at the index reserved for it.
+func (p *QueryPlans) set(i int, plan QueryPlan) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.plans[i] = plan
+}
+
+// QueryPlanner explains the SELECT statements run with the context of a
+// request collecting QueryPlans, once they have returned. The plan is
+// obtained by running EXPLAIN on the connection of the query, inside its
+// transaction if any, and is added as an event to the current span.
+//
+// With "database.query_plans.analyze", PostgreSQL runs the query a second
+// time to report the actual rows and timings, so only reads are explained.
+type QueryPlanner struct{}
+
+// Name returns the name of the plugin.
+func (QueryPlanner) Name() string {
+	return "query_plans"
+}
+
+// Initialize registers the callbacks of the plugin on db.
+func (p QueryPlanner) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("query_plans:before_select", p.before); err != nil {
+		return err
+	}
+	return callbacks.Query().After("gorm:query").Register("query_plans:after_select", p.after)
+}
+
+// before records the start of a query of a request collecting plans.
+func (QueryPlanner) before(db *gorm.DB) {
+	if queryPlansFrom(db.Statement.Context) != nil {
+		db.InstanceSet(queryStartKey, time.Now())
+	}
+}
+
+// after explains a query of a request collecting plans.
+func (QueryPlanner) after(db *gorm.DB) {
+	plans := queryPlansFrom(db.Statement.Context)
+	v, _ := db.InstanceGet(queryStartKey)
+	start, ok := v.(time.Time)
+	if plans == nil || !ok || db.Error != nil {
+		return
+	}
+	db.InstanceSet(queryStartKey, nil)
+
+	query := db.Statement.SQL.String()
+	if !isSelect(query) {
+		return
+	}
+	cfg := config.Get().Database.QueryPlans
+	elapsed := time.Since(start)
+	if elapsed < cfg.MinDuration {
+		return
+	}
+	i, ok := plans.reserve(cfg.MaxQueries)
+	if !ok {
+		return
+	}
+
+	plan, err := explain(db, query, cfg.Analyze)
+	plans.set(i, QueryPlan{SQL: query, Duration: elapsed, Plan: plan, Err: err})
+	if err == nil {
+		trace.SpanFromContext(db.Statement.Context).AddEvent("db.query_plan", trace.WithAttributes(
+			semconv.DBQueryText(query),
+			attribute.String("db.query_plan", plan),
+			attribute.Int64("db.query_duration_ms", elapsed.Milliseconds()),
+		))
+	}
+}
+
+// explain returns the plan of a query, one line per step.
+func explain(db *gorm.DB, query string, analyze bool) (string, error) {
+	prefix := "EXPLAIN "
+	switch db.Dialector.Name() {
+	case "postgres":
+		if analyze {
+			prefix = "EXPLAIN (ANALYZE, BUFFERS) "
+		}
+	case "sqlite":
+		prefix = "EXPLAIN QUERY PLAN "
+	}
+
+	rows, err := db.Statement.ConnPool.QueryContext(db.Statement.Context, prefix+query, db.Statement.Vars...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	// The step is described by the last column, the only one of PostgreSQL
+	// and the detail of SQLite
+	values := make([]any, len(columns))
+	for i := range values {
+		values[i] = new(sql.RawBytes)
+	}
+	var lines []string
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return "", err
+		}
+		lines = append(lines, string(*values[len(values)-1].(*sql.RawBytes)))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "", fmt.Errorf("no plan returned")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// queryPlansFrom returns the QueryPlans of the context, if any.
+func queryPlansFrom(ctx context.Context) *QueryPlans {
+	if ctx == nil {
+		return nil
+	}
+	plans, _ := ctx.Value(types.KeyQueryPlans).(*QueryPlans)
+	return plans
+}
+
+// isSelect reports whether a statement only reads, and is safe to run again.
+func isSelect(query string) bool {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	return strings.HasPrefix(query, "SELECT") && !strings.Contains(query, " FOR UPDATE") && !strings.Contains(query, " FOR SHARE")
+}
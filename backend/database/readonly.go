@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ReadOnlyState describes whether the site is read-only, and why.
+type ReadOnlyState struct {
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source,omitempty"` // config or switch, when enabled
+	Message string `json:"message,omitempty"`
+}
+
+// Sources of the read-only mode
+const (
+	ReadOnlyConfig = "config" // The read_only.enabled setting
+	ReadOnlySwitch = "switch" // The admin endpoint or the migrate command
+)
+
+// ReadOnly is the read-only switch of the site, turned on while writes must
+// wait, e.g. during long schema changes. The switch is stored in the site
+// settings so every instance, and the migrate command, share it. Instances
+// read it at every interval rather than on every request, so it takes up
+// to an interval to apply everywhere.
+type ReadOnly struct {
+	db     *gorm.DB
+	logger *zap.Logger
+
+	mu      sync.RWMutex
+	enabled bool
+	message string
+}
+
+// NewReadOnly returns a new instance of ReadOnly stored in db, with the
+// current state of the switch.
+func NewReadOnly(db *gorm.DB, logger *zap.Logger) *ReadOnly {
+	ro := &ReadOnly{db: db, logger: logger}
+	ro.refresh(context.Background())
+	return ro
+}
+
+// State returns whether the site is read-only, from the configuration or the
+// switch.
+func (ro *ReadOnly) State() ReadOnlyState {
+	cfg := config.Get().ReadOnly
+	if cfg.Enabled {
+		return ReadOnlyState{Enabled: true, Source: ReadOnlyConfig, Message: cfg.Message}
+	}
+
+	ro.mu.RLock()
+	defer ro.mu.RUnlock()
+	if !ro.enabled {
+		return ReadOnlyState{}
+	}
+	message := ro.message
+	if message == "" {
+		message = cfg.Message
+	}
+	return ReadOnlyState{Enabled: true, Source: ReadOnlySwitch, Message: message}
+}
+
+// Set turns the switch on or off, with the message given to the clients
+// turned away, the configured one when empty.
+func (ro *ReadOnly) Set(ctx context.Context, enabled bool, message string) error {
+	if !enabled {
+		message = ""
+	}
+	err := SetReadOnly(ro.db.WithContext(ctx), enabled, message)
+	if err != nil {
+		return err
+	}
+
+	ro.mu.Lock()
+	ro.enabled, ro.message = enabled, message
+	ro.mu.Unlock()
+	return nil
+}
+
+// Run reads the switch at every interval until the context is canceled.
+// Intervals under a second are raised to a second.
+func (ro *ReadOnly) Run(ctx context.Context, interval time.Duration) {
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ro.refresh(ctx)
+		}
+	}
+}
+
+// refresh reads the switch. The previous state is kept when it can't be
+// read, e.g. while its table is being changed.
+func (ro *ReadOnly) refresh(ctx context.Context) {
+	var settings models.SiteSettings
+	err := ro.db.WithContext(ctx).
+		Select("read_only", "read_only_message").
+		Order("id ASC").
+		Limit(1).
+		Find(&settings).Error
+	if err != nil {
+		ro.logger.Warn("Failed to read the read-only switch", zap.Error(err))
+		return
+	}
+
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+	if settings.ReadOnly != ro.enabled {
+		ro.logger.Info("Read-only switch changed", zap.Bool("read_only", settings.ReadOnly))
+	}
+	ro.enabled, ro.message = settings.ReadOnly, settings.ReadOnlyMessage
+}
+
+// SetReadOnly stores the read-only switch in the site settings, if they
+// exist yet.
+func SetReadOnly(db *gorm.DB, enabled bool, message string) error {
+	return db.Model(&models.SiteSettings{}).
+		Where("1 = 1").
+		Updates(map[string]interface{}{
+			"read_only":         enabled,
+			"read_only_message": message,
+		}).Error
+}
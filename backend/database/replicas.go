@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// Replicas spreads reads over the read replicas of the "database.replicas"
+// settings, through the dbresolver plugin. Only the queries of connections
+// returned by Replica are sent to them: the primary database runs every
+// other query, and every write.
+//
+// Replicas are pinged at every interval. Reads are spread over the ones
+// that answered the last check, and fall back to the primary database
+// while none did.
+type Replicas struct {
+	primary  *sql.DB
+	replicas []*replica
+	interval time.Duration
+	logger   *zap.Logger
+
+	next atomic.Uint64 // Round robin counter
+}
+
+// replica is a read replica and its health.
+type replica struct {
+	addr    string
+	sqlDB   *sql.DB
+	healthy atomic.Bool
+}
+
+// NewReplicas connects to the read replicas of db, configured from the
+// "database.replicas" settings. Replicas that can't be reached yet are
+// considered unhealthy until they answer a check.
+func NewReplicas(db *gorm.DB, logger *zap.Logger) (*Replicas, error) {
+	cfg := config.Get().Database
+	if cfg.Type != "postgres" {
+		return nil, errors.New("read replicas need a postgres database")
+	}
+	primary, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	rs := &Replicas{primary: primary, interval: cfg.Replicas.CheckInterval, logger: logger}
+	for _, settings := range cfg.Replicas.Hosts {
+		sqlDB, err := sql.Open("pgx", postgresDSN(cfg, settings.Host, settings.Port))
+		if err != nil {
+			rs.Close()
+			return nil, fmt.Errorf("invalid replica %s: %v", settings.Host, err)
+		}
+		sqlDB.SetMaxOpenConns(maxOpenConns)
+		sqlDB.SetMaxIdleConns(maxIdleConns)
+		sqlDB.SetConnMaxLifetime(5 * time.Minute)
+
+		r := &replica{addr: net.JoinHostPort(settings.Host, strconv.Itoa(settings.Port)), sqlDB: sqlDB}
+		rs.replicas = append(rs.replicas, r)
+	}
+	rs.check(context.Background())
+	return rs, nil
+}
+
+// Plugin returns the Gorm plugin routing the reads of Replica connections
+// to the replicas.
+func (rs *Replicas) Plugin() gorm.Plugin {
+	dialectors := make([]gorm.Dialector, 0, len(rs.replicas)+1)
+	for _, r := range rs.replicas {
+		dialectors = append(dialectors, postgres.New(postgres.Config{Conn: r.sqlDB}))
+	}
+	// The primary is listed as well: dbresolver only asks the policy to
+	// pick between several replicas, and always uses a single one
+	dialectors = append(dialectors, postgres.New(postgres.Config{Conn: rs.primary}))
+	return dbresolver.Register(dbresolver.Config{Replicas: dialectors, Policy: rs})
+}
+
+// Resolve picks the replica running a read, for dbresolver: the next
+// healthy replica, or the primary database when none is.
+func (rs *Replicas) Resolve([]gorm.ConnPool) gorm.ConnPool {
+	n := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := rs.replicas[(start+i)%n]; r.healthy.Load() {
+			return r.sqlDB
+		}
+	}
+	return rs.primary
+}
+
+// Run checks the replicas at every interval until the context is canceled.
+func (rs *Replicas) Run(ctx context.Context) {
+	ticker := time.NewTicker(rs.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rs.check(ctx)
+		}
+	}
+}
+
+// check pings every replica, logging the ones becoming unhealthy or
+// healthy again.
+func (rs *Replicas) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, rs.interval)
+	defer cancel()
+
+	for _, r := range rs.replicas {
+		err := r.sqlDB.PingContext(ctx)
+		switch {
+		case err != nil && r.healthy.CompareAndSwap(true, false):
+			rs.logger.Error("Read replica unavailable, reading from the others", zap.String("replica", r.addr), zap.Error(err))
+		case err == nil && r.healthy.CompareAndSwap(false, true):
+			rs.logger.Info("Read replica available", zap.String("replica", r.addr))
+		}
+	}
+}
+
+// Close closes the connections to the replicas.
+func (rs *Replicas) Close() error {
+	var errs []error
+	for _, r := range rs.replicas {
+		errs = append(errs, r.sqlDB.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Primary returns a connection running every query on the primary
+// database, the default once replicas are in use.
+func Primary(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Write).Session(&gorm.Session{})
+}
+
+// Replica returns a connection reading from the replicas, for the requests
+// that only read and can be served slightly stale data. Writes still go to
+// the primary database, but transactions run on the replica they started
+// on. Without replicas, it runs every query on the database.
+func Replica(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Read).Session(&gorm.Session{})
+}
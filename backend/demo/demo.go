@@ -0,0 +1,160 @@
+// Package demo seeds the sample content of the demo mode, and restores it
+// periodically so visitors always find the API in the same state.
+package demo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Email addresses of the sample accounts, which log in with the
+// "demo.password" setting
+const (
+	AdminEmail  = "admin@demo.local"
+	ReaderEmail = "reader@demo.local"
+)
+
+// samplePost is a post of the sample content.
+type samplePost struct {
+	title   string
+	content string
+	tags    []string
+	status  string
+}
+
+var samplePosts = []samplePost{
+	{
+		title:   "Welcome to the demo",
+		content: "This site runs in **demo mode**: everything is stored in memory and restored periodically, so feel free to try anything.\n\nLog in as `" + AdminEmail + "` to write posts, or as `" + ReaderEmail + "` to comment.",
+		tags:    []string{"demo", "getting-started"},
+		status:  "published",
+	},
+	{
+		title:   "Writing posts in Markdown",
+		content: "Posts are written in Markdown and rendered to sanitized HTML.\n\n```go\nfmt.Println(\"Hello, world!\")\n```\n\nCode blocks are highlighted, and `GET /highlight.css` serves their styles.",
+		tags:    []string{"markdown"},
+		status:  "published",
+	},
+	{
+		title:   "A draft waiting to be published",
+		content: "This post is a draft: publish it by setting its status to published with PUT /posts/{id}.",
+		tags:    []string{"demo"},
+		status:  "draft",
+	},
+}
+
+// Demo seeds and restores the sample content.
+type Demo struct {
+	db     *gorm.DB
+	svc    *services.Services
+	logger *zap.Logger
+}
+
+// New returns a new instance of Demo seeding db through svc.
+func New(db *gorm.DB, svc *services.Services, logger *zap.Logger) *Demo {
+	return &Demo{db: db, svc: svc, logger: logger}
+}
+
+// Seed creates the sample accounts and content.
+func (d *Demo) Seed() error {
+	password := config.Get().Demo.Password
+	now := time.Now()
+	admin := &models.User{Username: "admin", Email: AdminEmail, Password: password, Role: types.RoleAdmin, VerifiedAt: &now}
+	reader := &models.User{Username: "reader", Email: ReaderEmail, Password: password, Role: types.RoleUser, VerifiedAt: &now}
+	for _, user := range []*models.User{admin, reader} {
+		if err := d.svc.Auth.Register(user); err != nil {
+			return fmt.Errorf("failed to create user %s: %v", user.Username, err)
+		}
+	}
+
+	var posts []*models.Post
+	for _, sample := range samplePosts {
+		post := &models.Post{
+			Title:   sample.title,
+			Content: sample.content,
+			UserID:  admin.ID,
+			Status:  sample.status,
+		}
+		for _, tag := range sample.tags {
+			post.Tags = append(post.Tags, models.Tag{Name: tag})
+		}
+		if err := d.svc.Posts.CreatePost(post); err != nil {
+			return fmt.Errorf("failed to create post %q: %v", sample.title, err)
+		}
+		posts = append(posts, post)
+	}
+
+	comment := &models.Comment{Content: "Nice to see a demo that needs no setup!", UserID: reader.ID, PostID: posts[0].ID}
+	if err := d.svc.Posts.AddComment(comment, services.CommentOrigin{}); err != nil {
+		return fmt.Errorf("failed to create comment: %v", err)
+	}
+	reply := &models.Comment{Content: "Thanks! Everything goes back to this state on the next reset.", UserID: admin.ID, PostID: posts[0].ID, ParentID: &comment.ID}
+	if err := d.svc.Posts.AddComment(reply, services.CommentOrigin{}); err != nil {
+		return fmt.Errorf("failed to create comment: %v", err)
+	}
+	return nil
+}
+
+// Reset deletes every row of the database and the uploaded files, then
+// stores the default site settings and seeds the sample content again.
+func (d *Demo) Reset() error {
+	tables, err := d.db.Migrator().GetTables()
+	if err != nil {
+		return err
+	}
+	err = d.db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range tables {
+			if err := tx.Exec(fmt.Sprintf("DELETE FROM %q", table)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if path := config.Get().Storage.Local.Path; path != "" {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	if err := repositories.NewSettingsRepository(d.db).CreateDefaults(); err != nil {
+		return err
+	}
+	return d.Seed()
+}
+
+// Run resets the sample content at the given interval until the context is
+// canceled. Intervals under a minute are raised to a minute.
+func (d *Demo) Run(ctx context.Context, interval time.Duration) {
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Reset(); err != nil {
+				d.logger.Error("Failed to reset the demo", zap.Error(err))
+				continue
+			}
+			d.logger.Info("Demo reset")
+		}
+	}
+}
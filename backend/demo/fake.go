@@ -0,0 +1,263 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"gorm.io/gorm"
+)
+
+// FakeOptions sets the amount of fake content created by SeedFake.
+type FakeOptions struct {
+	Users    int    // Accounts, including the authors
+	Authors  int    // Editor accounts writing the posts
+	Posts    int    // Posts, a tenth of them drafts
+	Comments int    // Most comments of a published post, a third of them replies
+	Likes    int    // Most likes of a comment
+	Views    int    // Most views of a published post per day
+	Days     int    // Period over which the posts are published, up to now
+	Seed     uint64 // Seed of the generator, random when 0
+}
+
+// DefaultFakeOptions are the amounts of fake content created by default.
+var DefaultFakeOptions = FakeOptions{
+	Users:    50,
+	Authors:  5,
+	Posts:    100,
+	Comments: 8,
+	Likes:    5,
+	Views:    200,
+	Days:     90,
+}
+
+// FakeStats counts the fake content created by SeedFake.
+type FakeStats struct {
+	Users    int
+	Posts    int
+	Comments int
+	Likes    int
+	Views    int64
+}
+
+// fakeTags are the tags of the fake posts.
+var fakeTags = []string{"go", "javascript", "databases", "devops", "security", "testing", "performance", "career", "tutorial", "opinion"}
+
+// fakeAttempts bounds the attempts at generating an account whose username
+// and email address are free.
+const fakeAttempts = 5
+
+// SeedFake creates random accounts, posts written in Markdown, nested
+// comments, likes and daily views, for development and load tests. The
+// accounts log in with the "demo.password" setting and email addresses at
+// example.com, which receive no mail. The authors are editors rather than
+// admins, so their posts are created with the post repository, as imports
+// are, without the events of the post service. The same seed generates the
+// same content in an empty database.
+func (d *Demo) SeedFake(opts FakeOptions) (FakeStats, error) {
+	var stats FakeStats
+	if opts.Users < 1 || opts.Authors < 1 || opts.Authors > opts.Users {
+		return stats, errors.New("at least one user is needed, and no more authors than users")
+	}
+	if opts.Posts < 0 || opts.Comments < 0 || opts.Likes < 0 || opts.Views < 0 || opts.Days < 1 {
+		return stats, errors.New("the amounts must not be negative, and the period at least a day")
+	}
+	f := gofakeit.New(opts.Seed)
+
+	// Create the accounts, authors first
+	users := make([]uint, 0, opts.Users)
+	for i := 0; i < opts.Users; i++ {
+		role := types.RoleUser
+		if i < opts.Authors {
+			role = types.RoleEditor
+		}
+		user, err := d.fakeUser(f, role)
+		if err != nil {
+			return stats, err
+		}
+		users = append(users, user.ID)
+		stats.Users++
+	}
+	authors := users[:opts.Authors]
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -opts.Days)
+	maxDepth := config.Get().Comments.MaxDepth
+	postRepo := repositories.NewPostRepository(d.db)
+	titles := map[string]bool{}
+	for i := 0; i < opts.Posts; i++ {
+		// Titles are unique, as the slugs derived from them
+		title := f.Sentence()
+		for titles[title] || len(title) < 5 {
+			title = f.Sentence()
+		}
+		titles[title] = true
+		post := &models.Post{
+			Title:       title,
+			Content:     fakeMarkdown(f),
+			UserID:      authors[f.IntN(len(authors))],
+			Status:      "published",
+			PublishedAt: f.DateRange(since, now),
+		}
+		if f.IntN(10) == 0 {
+			post.Status = "draft"
+		}
+		for _, tag := range fakeSample(f, fakeTags, f.IntRange(1, 3)) {
+			post.Tags = append(post.Tags, models.Tag{Name: tag})
+		}
+		if err := postRepo.Create(post); err != nil {
+			return stats, fmt.Errorf("failed to create post %q: %v", post.Title, err)
+		}
+		stats.Posts++
+		if post.Status != "published" {
+			continue
+		}
+
+		// Comment on the post, replying to earlier comments within the
+		// nesting limit
+		var comments []*models.Comment
+		depths := map[uint]int{}
+		for n := f.IntRange(0, opts.Comments); n > 0; n-- {
+			comment := &models.Comment{Content: fakeComment(f), UserID: users[f.IntN(len(users))], PostID: post.ID}
+			depth := 1
+			if len(comments) > 0 && f.IntN(3) == 0 {
+				parent := comments[f.IntN(len(comments))]
+				if maxDepth == 0 || depths[parent.ID] < maxDepth {
+					comment.ParentID = &parent.ID
+					depth = depths[parent.ID] + 1
+				}
+			}
+			if err := d.svc.Posts.AddComment(comment, services.CommentOrigin{}); err != nil {
+				return stats, fmt.Errorf("failed to create comment: %v", err)
+			}
+			comments = append(comments, comment)
+			depths[comment.ID] = depth
+			stats.Comments++
+
+			for _, userID := range fakeSample(f, users, f.IntRange(0, opts.Likes)) {
+				if _, err := d.svc.Posts.LikeComment(comment.ID, userID); err != nil {
+					return stats, fmt.Errorf("failed to like comment: %v", err)
+				}
+				stats.Likes++
+			}
+		}
+
+		views, err := d.fakeViews(f, post, opts.Views, now)
+		if err != nil {
+			return stats, fmt.Errorf("failed to record views: %v", err)
+		}
+		stats.Views += views
+	}
+	return stats, nil
+}
+
+// fakeUser creates a verified account with a random name and profile.
+func (d *Demo) fakeUser(f *gofakeit.Faker, role string) (*models.User, error) {
+	now := time.Now()
+	for attempt := 1; ; attempt++ {
+		username := strings.Map(func(r rune) rune {
+			if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return -1
+		}, strings.ToLower(f.Username()))
+		user := &models.User{
+			Username:   username,
+			Email:      username + "@example.com",
+			Password:   config.Get().Demo.Password,
+			FirstName:  f.FirstName(),
+			LastName:   f.LastName(),
+			Bio:        f.Bio(),
+			Role:       role,
+			VerifiedAt: &now,
+		}
+		err := d.svc.Auth.Register(user)
+		if err == nil {
+			return user, nil
+		}
+		taken := errors.Is(err, services.ErrUsernameTaken) || errors.Is(err, services.ErrEmailTaken)
+		if !taken || attempt == fakeAttempts {
+			return nil, fmt.Errorf("failed to create user %s: %v", username, err)
+		}
+	}
+}
+
+// fakeViews records random daily views of a post from its publication up to
+// now, along with its view count and a like count of the same scale, and
+// returns the number of views.
+func (d *Demo) fakeViews(f *gofakeit.Faker, post *models.Post, most int, now time.Time) (int64, error) {
+	var (
+		days  []models.PostViewDay
+		total int64
+	)
+	today := now.UTC().Truncate(24 * time.Hour)
+	for day := post.PublishedAt.UTC().Truncate(24 * time.Hour); !day.After(today); day = day.AddDate(0, 0, 1) {
+		if views := int64(f.IntRange(0, most)); views > 0 {
+			days = append(days, models.PostViewDay{PostID: post.ID, Day: day, Views: views})
+			total += views
+		}
+	}
+	if len(days) == 0 {
+		return 0, nil
+	}
+
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(days, 100).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumns(map[string]interface{}{
+			"view_count": total,
+			"like_count": f.IntRange(0, int(total/20)),
+		}).Error
+	})
+	return total, err
+}
+
+// fakeMarkdown returns the Markdown content of a post: paragraphs under a
+// few headings, with a list, a quote and a code block.
+func fakeMarkdown(f *gofakeit.Faker) string {
+	var b strings.Builder
+	b.WriteString(f.Paragraph() + "\n\n")
+	for section := f.IntRange(2, 4); section > 0; section-- {
+		fmt.Fprintf(&b, "## %s\n\n", strings.TrimRight(f.Sentence(), ".!?"))
+		for n := f.IntRange(1, 3); n > 0; n-- {
+			b.WriteString(f.Paragraph() + "\n\n")
+		}
+		switch f.IntN(4) {
+		case 0:
+			for n := f.IntRange(2, 5); n > 0; n-- {
+				fmt.Fprintf(&b, "- %s\n", f.HackerPhrase())
+			}
+			b.WriteString("\n")
+		case 1:
+			fmt.Fprintf(&b, "> %s\n\n", f.Quote())
+		case 2:
+			verb := f.Verb()
+			fmt.Fprintf(&b, "```go\nfunc do%s() error {\n\treturn errors.New(%q)\n}\n```\n\n", strings.ToUpper(verb[:1])+verb[1:], f.HackerPhrase())
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// fakeComment returns the content of a comment.
+func fakeComment(f *gofakeit.Faker) string {
+	if f.IntN(2) == 0 {
+		return f.Question()
+	}
+	return f.Sentence() + " " + f.Sentence()
+}
+
+// fakeSample returns up to n distinct elements of values, in random order.
+func fakeSample[T any](f *gofakeit.Faker, values []T, n int) []T {
+	sample := append([]T(nil), values...)
+	f.ShuffleAnySlice(sample)
+	return sample[:min(n, len(sample))]
+}
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Event is a message published on the bus. Payload holds the JSON encoded
+// event data so that events survive a round trip through external brokers.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Handler processes a single event delivered by the bus.
+type Handler func(ctx context.Context, event Event) error
+
+// Bus is the internal event bus used to decouple producers from consumers
+// such as notifications, webhooks, and the search index.
+type Bus interface {
+	// Publish delivers the event to every subscriber of its type.
+	Publish(ctx context.Context, event Event) error
+	// Subscribe registers a handler for the given event type.
+	Subscribe(eventType string, handler Handler) error
+	// Close stops delivery and releases any broker connection.
+	Close() error
+}
+
+// NewEvent builds an event of the given type, encoding the payload as JSON.
+func NewEvent(eventType string, payload interface{}) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode event payload: %v", err)
+	}
+
+	return Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Payload:    data,
+	}, nil
+}
+
+// Decode unmarshals the event payload into v.
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// deliver runs every handler subscribed to the type of an event, logging
+// their failures. The broker adapters consume each event type once per
+// instance and fan the events out to its handlers, so that every handler
+// receives every event while the instances share the work.
+func deliver(ctx context.Context, handlers []Handler, event Event, logger *zap.Logger) {
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			logger.Error("Event handler failed",
+				zap.String("event_type", event.Type),
+				zap.String("event_id", event.ID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// NewBus returns the event bus selected by the "events.driver" configuration
+// key. The in-process bus is used by default; "nats" and "kafka" select the
+// corresponding broker adapters.
+func NewBus(logger *zap.Logger) (Bus, error) {
+	cfg := config.Get().Events
+	prefix := cfg.SubjectPrefix
+
+	switch driver := cfg.Driver; driver {
+	case "", "inprocess":
+		return NewInProcessBus(logger), nil
+	case "nats":
+		return NewNATSBus(cfg.NATS.URL, prefix, logger)
+	case "kafka":
+		return NewKafkaBus(
+			cfg.Kafka.Brokers,
+			cfg.Kafka.GroupID,
+			prefix,
+			logger,
+		)
+	default:
+		return nil, fmt.Errorf("unsupported event bus driver: %s", driver)
+	}
+}
+
+// subject returns the broker subject or topic for an event type.
+func subject(prefix, eventType string) string {
+	if prefix == "" {
+		return eventType
+	}
+	return prefix + "." + eventType
+}
@@ -0,0 +1,24 @@
+package events
+
+import "context"
+
+// Discard is a Bus that drops every event, used for requests whose side
+// effects must not leave the server, such as sandbox requests.
+var Discard Bus = discardBus{}
+
+type discardBus struct{}
+
+// Publish drops the event.
+func (discardBus) Publish(ctx context.Context, event Event) error {
+	return nil
+}
+
+// Subscribe ignores the handler, which never receives events.
+func (discardBus) Subscribe(eventType string, handler Handler) error {
+	return nil
+}
+
+// Close does nothing.
+func (discardBus) Close() error {
+	return nil
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// ErrBusClosed is returned when publishing or subscribing on a closed bus.
+var ErrBusClosed = errors.New("event bus is closed")
+
+// InProcessBus delivers events to subscribers in the same process. Each
+// handler runs in its own goroutine so a slow consumer never blocks the
+// publisher.
+type InProcessBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+	wg       sync.WaitGroup
+	pending  atomic.Int64
+	closed   bool
+	logger   *zap.Logger
+}
+
+// NewInProcessBus returns a new instance of InProcessBus.
+func NewInProcessBus(logger *zap.Logger) *InProcessBus {
+	return &InProcessBus{
+		handlers: make(map[string][]Handler),
+		logger:   logger,
+	}
+}
+
+// Publish dispatches the event to every handler subscribed to its type.
+// Handler errors are logged and never returned to the publisher.
+func (b *InProcessBus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	for _, handler := range b.handlers[event.Type] {
+		b.wg.Add(1)
+		b.pending.Add(1)
+		go func(handler Handler) {
+			defer b.wg.Done()
+			defer b.pending.Add(-1)
+			// Detach from the request context so delivery outlives the request
+			if err := handler(context.WithoutCancel(ctx), event); err != nil {
+				b.logger.Error("Event handler failed",
+					zap.String("event_type", event.Type),
+					zap.String("event_id", event.ID),
+					zap.Error(err),
+				)
+			}
+		}(handler)
+	}
+
+	return nil
+}
+
+// Pending returns the number of deliveries to handlers still in progress.
+func (b *InProcessBus) Pending() int64 {
+	return b.pending.Load()
+}
+
+// Subscribe registers a handler for the given event type.
+func (b *InProcessBus) Subscribe(eventType string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	b.handlers[eventType] = append(b.handlers[eventType], handler)
+	return nil
+}
+
+// Close stops accepting events and waits for in-flight handlers to finish.
+func (b *InProcessBus) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	return nil
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// KafkaBus publishes events to Kafka topics and consumes them with a consumer
+// group, so consumers can be scaled independently of the API instances. Each
+// topic is read once per instance, and every handler of the instance
+// receiving an event runs.
+type KafkaBus struct {
+	brokers []string
+	groupID string
+	prefix  string
+	writer  *kafka.Writer
+	logger  *zap.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	handlers map[string][]Handler
+	readers  []*kafka.Reader
+}
+
+// NewKafkaBus returns a new KafkaBus writing to and reading from the given brokers.
+func NewKafkaBus(brokers []string, groupID, prefix string, logger *zap.Logger) (*KafkaBus, error) {
+	if len(brokers) == 0 {
+		return nil, errors.New("kafka brokers are not set")
+	}
+	if groupID == "" {
+		groupID = "coderage"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &KafkaBus{
+		brokers: brokers,
+		groupID: groupID,
+		prefix:  prefix,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Balancer:               &kafka.Hash{},
+			AllowAutoTopicCreation: true,
+		},
+		logger:   logger,
+		ctx:      ctx,
+		cancel:   cancel,
+		handlers: make(map[string][]Handler),
+	}, nil
+}
+
+// Publish writes the JSON encoded event to the topic of its type, keyed by
+// event ID.
+func (b *KafkaBus) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+
+	return b.writer.WriteMessages(ctx, kafka.Message{
+		Topic: subject(b.prefix, event.Type),
+		Key:   []byte(event.ID),
+		Value: data,
+	})
+}
+
+// Subscribe registers a handler for the event type. The first handler of a
+// type starts the consumer group reader of its topic.
+func (b *KafkaBus) Subscribe(eventType string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.handlers[eventType]; ok {
+		b.handlers[eventType] = append(b.handlers[eventType], handler)
+		return nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: b.brokers,
+		GroupID: b.groupID,
+		Topic:   subject(b.prefix, eventType),
+	})
+	b.handlers[eventType] = []Handler{handler}
+	b.readers = append(b.readers, reader)
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			msg, err := reader.ReadMessage(b.ctx)
+			if err != nil {
+				if b.ctx.Err() == nil {
+					b.logger.Error("Failed to read event", zap.String("topic", reader.Config().Topic), zap.Error(err))
+				}
+				return
+			}
+
+			var event Event
+			if err := json.Unmarshal(msg.Value, &event); err != nil {
+				b.logger.Error("Failed to decode event", zap.String("topic", msg.Topic), zap.Error(err))
+				continue
+			}
+
+			b.mu.Lock()
+			handlers := b.handlers[eventType]
+			b.mu.Unlock()
+			deliver(b.ctx, handlers, event, b.logger)
+		}
+	}()
+
+	return nil
+}
+
+// Close stops the consumers and flushes pending writes.
+func (b *KafkaBus) Close() error {
+	b.cancel()
+	b.wg.Wait()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, reader := range b.readers {
+		reader.Close()
+	}
+
+	return b.writer.Close()
+}
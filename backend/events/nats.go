@@ -0,0 +1,86 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// NATSBus publishes events to NATS subjects and delivers them to subscribers
+// through a queue group per subject, so several API instances share the work
+// of a consumer. Every handler of the instance receiving an event runs.
+type NATSBus struct {
+	conn   *nats.Conn
+	prefix string
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	handlers map[string][]Handler
+	subs     []*nats.Subscription
+}
+
+// NewNATSBus connects to the NATS server at url and returns a new NATSBus.
+func NewNATSBus(url, prefix string, logger *zap.Logger) (*NATSBus, error) {
+	if url == "" {
+		url = nats.DefaultURL
+	}
+
+	conn, err := nats.Connect(url, nats.Name("coderage"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %v", err)
+	}
+
+	return &NATSBus{conn: conn, prefix: prefix, logger: logger, handlers: make(map[string][]Handler)}, nil
+}
+
+// Publish sends the JSON encoded event on the subject of its type.
+func (b *NATSBus) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %v", err)
+	}
+
+	return b.conn.Publish(subject(b.prefix, event.Type), data)
+}
+
+// Subscribe registers a handler for the event type. The first handler of a
+// type joins the queue group of its subject.
+func (b *NATSBus) Subscribe(eventType string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.handlers[eventType]; ok {
+		b.handlers[eventType] = append(b.handlers[eventType], handler)
+		return nil
+	}
+
+	subj := subject(b.prefix, eventType)
+	sub, err := b.conn.QueueSubscribe(subj, subj, func(msg *nats.Msg) {
+		var event Event
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			b.logger.Error("Failed to decode event", zap.String("subject", msg.Subject), zap.Error(err))
+			return
+		}
+
+		b.mu.Lock()
+		handlers := b.handlers[eventType]
+		b.mu.Unlock()
+		deliver(context.Background(), handlers, event, b.logger)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %v", subj, err)
+	}
+
+	b.handlers[eventType] = []Handler{handler}
+	b.subs = append(b.subs, sub)
+	return nil
+}
+
+// Close drains the subscriptions and closes the connection.
+func (b *NATSBus) Close() error {
+	return b.conn.Drain()
+}
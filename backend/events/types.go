@@ -0,0 +1,55 @@
+package events
+
+import (
+	"time"
+)
+
+// Types of the events published by the services. Subscribers, such as the
+// webhooks listed in "webhooks.events", select them by type.
+const (
+	PostCreated   = "post.created"
+	PostPublished = "post.published" // On creation or once a draft is published
+	PostUpdated   = "post.updated"
+	PostDeleted   = "post.deleted"
+
+	CommentCreated = "comment.created"
+	CommentLiked   = "comment.liked"
+
+	UserRegistered = "user.registered"
+)
+
+// PostPayload is the payload of the post events.
+type PostPayload struct {
+	PostID      uint      `json:"post_id"`
+	UserID      uint      `json:"user_id"`
+	Title       string    `json:"title"`
+	Slug        string    `json:"slug"`
+	Status      string    `json:"status"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// CommentPayload is the payload of the comment events.
+type CommentPayload struct {
+	CommentID uint   `json:"comment_id"`
+	PostID    uint   `json:"post_id"`
+	UserID    uint   `json:"user_id"`
+	ParentID  *uint  `json:"parent_id,omitempty"`
+	Status    string `json:"status"`
+}
+
+// CommentLikePayload is the payload of the comment.liked event.
+type CommentLikePayload struct {
+	CommentID uint `json:"comment_id"`
+	PostID    uint `json:"post_id"`
+	AuthorID  uint `json:"author_id"` // Author of the comment
+	UserID    uint `json:"user_id"`   // User who liked it
+}
+
+// UserPayload is the payload of the user events. Events can leave the
+// server, through brokers and webhooks, so they carry no personal data
+// beyond the public username.
+type UserPayload struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Provider string `json:"provider,omitempty"` // Login provider the user signed up with, if any
+}
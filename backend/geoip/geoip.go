@@ -0,0 +1,128 @@
+// Package geoip resolves the country of client IP addresses from a MaxMind
+// GeoIP2 or GeoLite2 database, and truncates IP addresses before they are
+// stored.
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Resolver resolves the country of IP addresses.
+type Resolver interface {
+	// Country returns the ISO 3166-1 alpha-2 code of the country of ip, or an
+	// empty string when it is unknown.
+	Country(ip net.IP) string
+	// Close releases the resources of the resolver.
+	Close() error
+}
+
+// New returns the resolver reading the MaxMind database at the
+// "geoip.database_path" configuration key. Countries are never resolved when
+// no database is configured.
+func New() (Resolver, error) {
+	path := config.Get().GeoIP.DatabasePath
+	if path == "" {
+		return None, nil
+	}
+
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	return &maxMindResolver{reader: reader}, nil
+}
+
+// maxMindResolver resolves countries from a MaxMind database.
+type maxMindResolver struct {
+	reader *geoip2.Reader
+}
+
+func (m *maxMindResolver) Country(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	record, err := m.reader.Country(ip)
+	if err != nil {
+		return ""
+	}
+	return record.Country.IsoCode
+}
+
+func (m *maxMindResolver) Close() error {
+	return m.reader.Close()
+}
+
+// None is a resolver that never knows the country.
+var None Resolver = none{}
+
+type none struct{}
+
+func (none) Country(net.IP) string { return "" }
+func (none) Close() error          { return nil }
+
+// RemoteIP returns the IP address the request was received from.
+func RemoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// TruncateIP anonymizes an IP address, optionally followed by a port, by
+// zeroing its host part: the last octet of IPv4 addresses, and everything
+// past the /48 prefix of IPv6 addresses. Values that aren't IP addresses are
+// returned unchanged.
+func TruncateIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return addr
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// StoredIP returns an IP address as it should be stored: truncated unless
+// "geoip.truncate_stored_ips" is turned off.
+func StoredIP(addr string) string {
+	if !config.Get().GeoIP.TruncateStoredIPs {
+		return addr
+	}
+	return TruncateIP(addr)
+}
+
+// RequestCountry returns the country the request was resolved to, or an
+// empty string when it is unknown.
+func RequestCountry(r *http.Request) string {
+	country, _ := r.Context().Value(types.KeyCountry).(string)
+	return country
+}
+
+// Blocked reports whether a country is in the given list of ISO codes.
+// Unknown countries are never blocked.
+func Blocked(country string, list []string) bool {
+	if country == "" {
+		return false
+	}
+	for _, blocked := range list {
+		if strings.EqualFold(blocked, country) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"sync"
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+)
+
+// batchWait is how long a loader waits for the sibling fields of a list,
+// resolved concurrently, to ask for their keys before fetching them
+const batchWait = 2 * time.Millisecond
+
+// loaders fetch the associations of the posts, comments and users of a
+// request in a single query per level of the selection, instead of one per
+// object.
+type loaders struct {
+	users    *loader[uint, *models.User]
+	tags     *loader[uint, []models.Tag]
+	comments *loader[uint, []models.Comment]
+	posts    *loader[uint, []models.Post] // Posts of users
+}
+
+// newLoaders returns the loaders of a request of the viewer.
+func newLoaders(svc *services.Services, viewerID uint) *loaders {
+	return &loaders{
+		users:    newLoader(svc.Users.PublicProfiles),
+		tags:     newLoader(svc.Tags.TagsByPosts),
+		comments: newLoader(svc.Posts.CommentsByPosts),
+		posts: newLoader(func(userIDs []uint) (map[uint][]models.Post, error) {
+			return svc.Posts.PostsByAuthors(userIDs, viewerID)
+		}),
+	}
+}
+
+// loader batches the keys loaded at about the same time into a single fetch,
+// and keeps the results for the rest of the request. Keys missing from the
+// results of the fetch load the zero value.
+type loader[K comparable, V any] struct {
+	fetch func(keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	batches map[K]*batch[K, V] // Batch of every key loaded
+	pending *batch[K, V]       // Batch still collecting keys
+}
+
+// batch is a set of keys fetched together.
+type batch[K comparable, V any] struct {
+	keys   []K
+	done   chan struct{}
+	values map[K]V
+	err    error
+}
+
+// newLoader returns a new loader fetching the values with fetch.
+func newLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, batches: make(map[K]*batch[K, V])}
+}
+
+// Load returns the value of a key, waiting for the batch fetching it.
+func (l *loader[K, V]) Load(key K) (V, error) {
+	l.mu.Lock()
+	b, ok := l.batches[key]
+	if !ok {
+		b = l.pending
+		if b == nil {
+			b = &batch[K, V]{done: make(chan struct{})}
+			l.pending = b
+			time.AfterFunc(batchWait, func() { l.run(b) })
+		}
+		b.keys = append(b.keys, key)
+		l.batches[key] = b
+	}
+	l.mu.Unlock()
+
+	<-b.done
+	return b.values[key], b.err
+}
+
+// run fetches the keys of a batch, which no longer collects keys.
+func (l *loader[K, V]) run(b *batch[K, V]) {
+	l.mu.Lock()
+	if l.pending == b {
+		l.pending = nil
+	}
+	l.mu.Unlock()
+
+	b.values, b.err = l.fetch(b.keys)
+	close(b.done)
+}
@@ -0,0 +1,22 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package model
+
+import (
+	"github.com/SteaceP/coderage/models"
+)
+
+type Pagination struct {
+	Total      int `json:"total"`
+	Page       int `json:"page"`
+	Limit      int `json:"limit"`
+	TotalPages int `json:"totalPages"`
+}
+
+type PostPage struct {
+	Posts      []*models.Post `json:"posts"`
+	Pagination *Pagination    `json:"pagination"`
+}
+
+type Query struct {
+}
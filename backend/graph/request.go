@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+)
+
+// Request is the GraphQL request being resolved.
+type Request struct {
+	Services *services.Services
+	ViewerID uint // Authenticated user, 0 for anonymous requests
+
+	// Prepare applies the site settings to posts before they are returned
+	Prepare func(posts []models.Post) error
+
+	loaders *loaders
+}
+
+// WithRequest returns a copy of ctx carrying the request, with loaders of
+// its own so results are only shared within the request.
+func WithRequest(ctx context.Context, req *Request) context.Context {
+	req.loaders = newLoaders(req.Services, req.ViewerID)
+	return context.WithValue(ctx, types.KeyGraphQL, req)
+}
+
+// requestFrom returns the request carried by ctx.
+func requestFrom(ctx context.Context) *Request {
+	return ctx.Value(types.KeyGraphQL).(*Request)
+}
@@ -0,0 +1,38 @@
+package graph
+
+//go:generate go run github.com/99designs/gqlgen generate
+
+import (
+	"errors"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+)
+
+// Resolver resolves the GraphQL queries with the services of the request,
+// found in its context along with its loaders.
+type Resolver struct{}
+
+// preparePosts applies the site settings to posts, and returns them for a
+// list field.
+func preparePosts(req *Request, posts []models.Post) ([]*models.Post, error) {
+	if err := req.Prepare(posts); err != nil {
+		return nil, errors.New("failed to retrieve settings")
+	}
+	prepared := make([]*models.Post, len(posts))
+	for i := range posts {
+		prepared[i] = &posts[i]
+	}
+	return prepared, nil
+}
+
+// failure returns the error reported to the client for an error of a
+// service. Unexpected errors are reported with the fallback message, without
+// leaking their details.
+func failure(err error, fallback string) error {
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		return err
+	}
+	return errors.New(fallback)
+}
@@ -0,0 +1,184 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/SteaceP/coderage/graph/model"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+)
+
+// Author is the resolver for the author field.
+func (r *commentResolver) Author(ctx context.Context, obj *models.Comment) (*models.User, error) {
+	if obj.Anonymized {
+		return nil, nil
+	}
+	user, err := requestFrom(ctx).loaders.users.Load(obj.UserID)
+	if err != nil {
+		return nil, failure(err, "failed to retrieve users")
+	}
+	return user, nil
+}
+
+// Author is the resolver for the author field.
+func (r *postResolver) Author(ctx context.Context, obj *models.Post) (*models.User, error) {
+	user, err := requestFrom(ctx).loaders.users.Load(obj.UserID)
+	if err != nil {
+		return nil, failure(err, "failed to retrieve users")
+	}
+	return user, nil
+}
+
+// Tags is the resolver for the tags field.
+func (r *postResolver) Tags(ctx context.Context, obj *models.Post) ([]*models.Tag, error) {
+	tags, err := requestFrom(ctx).loaders.tags.Load(obj.ID)
+	if err != nil {
+		return nil, failure(err, "failed to retrieve tags")
+	}
+	list := make([]*models.Tag, len(tags))
+	for i := range tags {
+		list[i] = &tags[i]
+	}
+	return list, nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *postResolver) Comments(ctx context.Context, obj *models.Post) ([]*models.Comment, error) {
+	comments, err := requestFrom(ctx).loaders.comments.Load(obj.ID)
+	if err != nil {
+		return nil, failure(err, "failed to retrieve comments")
+	}
+	list := make([]*models.Comment, len(comments))
+	for i := range comments {
+		list[i] = &comments[i]
+	}
+	return list, nil
+}
+
+// Posts is the resolver for the posts field.
+func (r *queryResolver) Posts(ctx context.Context, page *int, limit *int, tag *string, search *string) (*model.PostPage, error) {
+	req := requestFrom(ctx)
+
+	// The author and tags are resolved by the loaders when selected
+	filters := map[string]interface{}{"preload": false}
+	if tag != nil && *tag != "" {
+		filters["tags"] = []string{*tag}
+	}
+	if search != nil && strings.TrimSpace(*search) != "" {
+		filters["search"] = strings.TrimSpace(*search)
+	}
+
+	// Pagination is validated as by GET /posts
+	p, l := 1, 10
+	if page != nil && *page >= 1 {
+		p = *page
+	}
+	if limit != nil && *limit >= 1 && *limit <= 100 {
+		l = *limit
+	}
+	posts, total, err := req.Services.Posts.ListPosts(p, l, filters, req.ViewerID)
+	if err != nil {
+		return nil, failure(err, "failed to retrieve posts")
+	}
+
+	prepared, err := preparePosts(req, posts)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PostPage{
+		Posts: prepared,
+		Pagination: &model.Pagination{
+			Total:      int(total),
+			Page:       p,
+			Limit:      l,
+			TotalPages: int((total + int64(l) - 1) / int64(l)),
+		},
+	}, nil
+}
+
+// Post is the resolver for the post field.
+func (r *queryResolver) Post(ctx context.Context, id *uint, slug *string) (*models.Post, error) {
+	req := requestFrom(ctx)
+
+	var post *models.Post
+	var err error
+	switch {
+	case id != nil:
+		post, err = req.Services.Posts.FindPost(*id, req.ViewerID)
+	case slug != nil:
+		post, err = req.Services.Posts.GetPost(*slug, req.ViewerID)
+	default:
+		return nil, errors.New("id or slug is required")
+	}
+	if errors.Is(err, services.ErrPostNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, failure(err, "failed to retrieve post")
+	}
+
+	prepared, err := preparePosts(req, []models.Post{*post})
+	if err != nil {
+		return nil, err
+	}
+	return prepared[0], nil
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, username string) (*models.User, error) {
+	user, err := requestFrom(ctx).Services.Users.GetPublicProfile(username)
+	if errors.Is(err, services.ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, failure(err, "failed to retrieve user")
+	}
+	return user, nil
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*models.User, error) {
+	req := requestFrom(ctx)
+	if req.ViewerID == 0 {
+		return nil, nil
+	}
+	user, err := req.Services.Users.GetUserProfile(req.ViewerID)
+	if err != nil {
+		return nil, failure(err, "failed to retrieve user")
+	}
+	return user, nil
+}
+
+// Posts is the resolver for the posts field.
+func (r *userResolver) Posts(ctx context.Context, obj *models.User) ([]*models.Post, error) {
+	req := requestFrom(ctx)
+	posts, err := req.loaders.posts.Load(obj.ID)
+	if err != nil {
+		return nil, failure(err, "failed to retrieve posts")
+	}
+	// Prepare a copy, the posts being kept by the loader
+	return preparePosts(req, append([]models.Post(nil), posts...))
+}
+
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
+// Post returns PostResolver implementation.
+func (r *Resolver) Post() PostResolver { return &postResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type commentResolver struct{ *Resolver }
+type postResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/geoip"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/securitylog"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/gorilla/mux"
+)
+
+// UpdateRoleRequest represents the structure for changing the role of a user
+type UpdateRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// UpdateMembershipRequest represents the structure for granting or revoking
+// the membership of a user
+type UpdateMembershipRequest struct {
+	Member bool `json:"member"`
+}
+
+// ListUsers lists users for admins, with optional filters on the role
+// ("role"), the account status ("is_active", "pending_approval") and the
+// username or email ("search").
+func ListUsers(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Parse pagination parameters
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	// Parse filters
+	filters := map[string]interface{}{}
+	if role := query.Get("role"); role != "" {
+		filters["role"] = role
+	}
+	if search := query.Get("search"); search != "" {
+		filters["search"] = search
+	}
+	for _, name := range []string{"is_active", "pending_approval"} {
+		if v := query.Get(name); v != "" {
+			value, err := strconv.ParseBool(v)
+			if err != nil {
+				apperrors.Error(w, r, "Invalid "+name+" filter", http.StatusBadRequest)
+				return
+			}
+			filters[name] = value
+		}
+	}
+
+	users, totalCount, err := svc.Users.ListUsers(page, limit, filters)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve users", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users": users,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// UpdateUserRole changes the role of a user.
+func UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	targetID, ok := targetUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := svc.Users.GetUserProfile(targetID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve user")
+		return
+	}
+	previousRole := previous.Role
+
+	user, err := svc.Users.ChangeRole(userID, targetID, req.Role)
+	if err != nil {
+		writeServiceError(w, r, err, "Role change failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditUserRoleChanged, targetID, map[string]interface{}{
+		"from": previousRole,
+		"to":   user.Role,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Role updated successfully",
+		"user":    user,
+	})
+}
+
+// UpdateUserMembership grants or revokes the membership of a user.
+func UpdateUserMembership(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	targetID, ok := targetUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateMembershipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Granting memberships counts against the plan of the site
+	if req.Member {
+		target, err := svc.Users.GetUserProfile(targetID)
+		if err != nil {
+			writeServiceError(w, r, err, "Failed to retrieve user")
+			return
+		}
+		if !target.Member {
+			if err := svc.Plans.Check(services.QuotaMembers, 1); err != nil {
+				writeServiceError(w, r, err, "Failed to check plan limits")
+				return
+			}
+		}
+	}
+
+	user, err := svc.Users.SetMembership(targetID, req.Member)
+	if err != nil {
+		writeServiceError(w, r, err, "Membership update failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditUserMembership, targetID, map[string]interface{}{
+		"member": user.Member,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Membership updated successfully",
+		"user":    user,
+	})
+}
+
+// DeactivateUser deactivates the account of a user.
+func DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	targetID, ok := targetUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := svc.Users.DeactivateUser(userID, targetID); err != nil {
+		writeServiceError(w, r, err, "User deactivation failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditUserDeactivated, targetID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "User deactivated successfully",
+	})
+}
+
+// DeleteUser deletes the account of a user.
+func DeleteUser(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	targetID, ok := targetUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := svc.Users.DeleteUser(userID, targetID); err != nil {
+		writeServiceError(w, r, err, "User deletion failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditUserDeleted, targetID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "User deleted successfully",
+	})
+}
+
+// VerifyUser marks the email address of a user as verified without going
+// through the verification email.
+func VerifyUser(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	targetID, ok := targetUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := svc.Users.VerifyUser(targetID); err != nil {
+		writeServiceError(w, r, err, "User verification failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditUserVerified, targetID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "User verified successfully",
+	})
+}
+
+// ListAuditLog lists the recorded actions, most recent first, optionally
+// filtered by user, action and time range.
+func ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Parse pagination parameters
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	// Parse filters
+	query := r.URL.Query()
+	filter := services.AuditFilter{Action: query.Get("action")}
+	if value := query.Get("user_id"); value != "" {
+		userID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || userID == 0 {
+			apperrors.Error(w, r, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		filter.UserID = uint(userID)
+	}
+	for name, bound := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			t, ok := parseTimeBound(value)
+			if !ok {
+				apperrors.Error(w, r, "Invalid "+name+" time, use RFC 3339 or YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+			*bound = t
+		}
+	}
+
+	entries, totalCount, err := svc.Audit.ListEntries(filter, page, limit)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve audit log")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// parseTimeBound parses a time given as RFC 3339, or as a date, which stands
+// for its start in UTC.
+func parseTimeBound(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// targetUserID parses the ID of the user targeted by an admin route, replying
+// with an error when it is invalid.
+func targetUserID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	return routeID(w, r, types.IDField, "Invalid user ID")
+}
+
+// routeID parses the ID held by a route variable, replying with an error
+// when it is invalid.
+func routeID(w http.ResponseWriter, r *http.Request, name, message string) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)[name], 10, 64)
+	if err != nil || id == 0 {
+		apperrors.Error(w, r, message, http.StatusBadRequest)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// recordAdminAction adds an admin or moderation action of the current user to
+// the audit log. The
+// target type is the prefix of the action, e.g. "user" for "user.deleted".
+func recordAdminAction(r *http.Request, svc *services.Services, action string, targetID uint, details map[string]interface{}) {
+	actorID, _ := r.Context().Value(types.KeyUserID).(uint)
+	entry := auditEntry(r, actorID, action, targetID, details)
+	svc.Audit.Record(entry)
+	securitylog.AdminAction(r, action, entry.TargetType, targetID)
+}
+
+// recordAction adds a security-relevant action of a user, such as a
+// password change, to the audit log. The target type is the prefix of the
+// action, except for the auth actions, which target the user.
+func recordAction(r *http.Request, svc *services.Services, actorID uint, action string, targetID uint, details map[string]interface{}) {
+	entry := auditEntry(r, actorID, action, targetID, details)
+	if strings.HasPrefix(action, "auth.") {
+		entry.TargetType = "user"
+	}
+	svc.Audit.Record(entry)
+}
+
+// auditEntry returns the audit log entry of an action taken in a request.
+func auditEntry(r *http.Request, actorID uint, action string, targetID uint, details map[string]interface{}) *models.AuditLog {
+	targetType, _, _ := strings.Cut(action, ".")
+	return &models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		RemoteAddr: geoip.StoredIP(r.RemoteAddr),
+		RequestID:  apperrors.RequestID(r),
+	}
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/utils"
+)
+
+// AnnouncementRequest represents the structure for creating or updating an
+// announcement. Omitted fields are left unchanged on updates.
+type AnnouncementRequest struct {
+	Message  *string    `json:"message"`
+	Severity *string    `json:"severity"` // info (the default), warning or critical
+	Audience *string    `json:"audience"` // all (the default), authenticated, members or admins
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// ListActiveAnnouncements lists the announcements currently shown to the
+// requesting user, or to anonymous visitors.
+func ListActiveAnnouncements(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	announcements, err := svc.Announcements.ListActiveAnnouncements(viewerID)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve announcements", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"announcements": announcements,
+	})
+}
+
+// ListAnnouncements lists every announcement, including past and scheduled
+// ones.
+func ListAnnouncements(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Parse pagination parameters
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	announcements, totalCount, err := svc.Announcements.ListAnnouncements(page, limit)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve announcements", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"announcements": announcements,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// CreateAnnouncement creates an announcement.
+func CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req AnnouncementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	announcement := &models.Announcement{
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		CreatedByID: userID,
+	}
+	if req.Message != nil {
+		announcement.Message = utils.SanitizeInput(*req.Message)
+	}
+	if req.Severity != nil {
+		announcement.Severity = *req.Severity
+	}
+	if req.Audience != nil {
+		announcement.Audience = *req.Audience
+	}
+	if err := svc.Announcements.CreateAnnouncement(announcement); err != nil {
+		writeServiceError(w, r, err, "Announcement creation failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditAnnouncementCreated, announcement.ID, map[string]interface{}{
+		"severity": announcement.Severity,
+		"audience": announcement.Audience,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Announcement created successfully",
+		"announcement": announcement,
+	})
+}
+
+// UpdateAnnouncement updates an announcement.
+func UpdateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	announcementID, ok := routeID(w, r, types.IDField, "Invalid announcement ID")
+	if !ok {
+		return
+	}
+
+	var req AnnouncementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	update := services.AnnouncementUpdate{
+		Severity: req.Severity,
+		Audience: req.Audience,
+		StartsAt: req.StartsAt,
+		EndsAt:   req.EndsAt,
+	}
+	if req.Message != nil {
+		message := utils.SanitizeInput(*req.Message)
+		update.Message = &message
+	}
+
+	announcement, err := svc.Announcements.UpdateAnnouncement(announcementID, update)
+	if err != nil {
+		writeServiceError(w, r, err, "Announcement update failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditAnnouncementUpdated, announcement.ID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Announcement updated successfully",
+		"announcement": announcement,
+	})
+}
+
+// DeleteAnnouncement removes an announcement.
+func DeleteAnnouncement(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	announcementID, ok := routeID(w, r, types.IDField, "Invalid announcement ID")
+	if !ok {
+		return
+	}
+
+	if err := svc.Announcements.DeleteAnnouncement(announcementID); err != nil {
+		writeServiceError(w, r, err, "Announcement deletion failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditAnnouncementDeleted, announcementID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Announcement deleted successfully",
+	})
+}
@@ -3,12 +3,15 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/geoip"
+	"github.com/SteaceP/coderage/jwtkeys"
 	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
 	"github.com/SteaceP/coderage/utils"
-
-	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
 type CreateUserRequest struct {
@@ -22,65 +25,88 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 func CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req CreateUserRequest
 
 	// Decode request body
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		http.Error(w, "Password hashing failed", http.StatusInternalServerError)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
 		return
 	}
 
-	// Create user model
-	user := models.User{
-		Username: req.Username,
-		Email:    req.Email,
-		Password: string(hashedPassword),
+	// Check if registration is open
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
 	}
-
-	// Get database from context
-	dbValue := r.Context().Value("db")
-	if dbValue == nil {
-		http.Error(w, "Internal Server Error (Database unavailable)", http.StatusInternalServerError)
+	if !settings.RegistrationOpen {
+		apperrors.Error(w, r, "Registration is closed", http.StatusForbidden)
 		return
 	}
-	db, ok := dbValue.(*gorm.DB)
-	if !ok {
-		http.Error(w, "Invalid database type", http.StatusInternalServerError)
+	if geoip.Blocked(geoip.RequestCountry(r), config.Get().GeoIP.BlockedRegistration) {
+		apperrors.Error(w, r, "Registration is not available in your country", http.StatusForbidden)
 		return
 	}
 
-	// Check if user already exists
-	var existingUser models.User
-	if err := db.Where("email = ?", user.Email).First(&existingUser).Error; err == nil {
-		http.Error(w, "User with this email already exists", http.StatusConflict)
+	// Create user
+	user := models.User{
+		Username:        req.Username,
+		Email:           req.Email,
+		Password:        req.Password,
+		PendingApproval: settings.RequireApproval,
+	}
+	if err := svc.Auth.Register(&user); err != nil {
+		writeServiceError(w, r, err, "User creation failed")
 		return
 	}
 
-	// Create user
-	if err := db.Create(&user).Error; err != nil {
-		http.Error(w, "User creation failed", http.StatusInternalServerError)
+	// Send verification email. Failures don't abort the registration since a
+	// new email can be requested through /users/resend-verification.
+	_ = svc.Verification.SendVerification(r.Context(), &user)
+
+	// Credit the referrer of the new user. Failures are logged by the service
+	// and don't abort the registration either.
+	_ = svc.Referrals.Attribute(user.ID, r.URL.Query().Get("ref"))
+
+	// Accounts awaiting approval don't get a token until an admin approves them
+	if user.PendingApproval {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "User created successfully, awaiting approval",
+			"user": map[string]string{
+				"id":       utils.UintToString(user.ID),
+				"username": user.Username,
+				"email":    user.Email,
+			},
+		})
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWTToken(user.ID)
+	// Generate tokens
+	tokens, err := svc.Auth.CreateTokenPair(&user, requestClient(r))
 	if err != nil {
-		http.Error(w, "Token generation failed", http.StatusInternalServerError)
+		apperrors.Error(w, r, "Token generation failed", http.StatusInternalServerError)
 		return
 	}
 
 	// Prepare response
 	response := map[string]interface{}{
-		"message": "User created successfully",
-		"token":   token,
+		"message":       "User created successfully",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 		"user": map[string]string{
 			"id":       utils.UintToString(user.ID),
 			"username": user.Username,
@@ -96,46 +122,96 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 
 func Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
-	var user models.User
 
 	// Decode request body
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Find user by email
-	db, ok := r.Context().Value("db").(*gorm.DB)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
 	if !ok {
-		http.Error(w, "Internal Server Error (Database unavailable)", http.StatusInternalServerError)
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Verify credentials and generate tokens
+	tokens, err := svc.Auth.Login(req.Email, req.Password, requestClient(r))
+	if err != nil {
+		writeServiceError(w, r, err, "Login failed")
 		return
 	}
-	if err := db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+
+	// Prepare response
+	response := map[string]string{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"message":       "Login successful",
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// RefreshToken exchanges a refresh token for a new token pair. The refresh
+// token is replaced, and can't be used again.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+
+	// Decode request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWTToken(user.ID)
+	tokens, err := svc.Auth.RefreshToken(req.RefreshToken, requestClient(r))
 	if err != nil {
-		http.Error(w, "Token generation failed", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "Token refresh failed")
 		return
 	}
 
-	// Prepare response
-	response := map[string]string{
-		"token":   token,
-		"message": "Login successful",
-	}
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+	})
+}
 
+// GetJWKS serves the public keys verifying the tokens of the API as a JSON
+// Web Key Set, empty when tokens are signed with the shared secret.
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(jwtkeys.Current().JWKS())
+}
+
+// maxUserAgentLength bounds the user agents stored with sessions
+const maxUserAgentLength = 512
+
+// requestClient returns the client a request was sent from, as stored with
+// the sessions it starts or refreshes.
+func requestClient(r *http.Request) services.Client {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	client := services.Client{UserAgent: userAgent, RequestID: apperrors.RequestID(r)}
+	if ip := geoip.RemoteIP(r); ip != nil {
+		client.IP = geoip.StoredIP(ip.String())
+	}
+	return client
 }
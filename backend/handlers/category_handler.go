@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/gorilla/mux"
+)
+
+// CategoryRequest represents the structure for creating or updating a
+// category. Omitted fields are left unchanged on updates.
+type CategoryRequest struct {
+	Name        *string `json:"name"`
+	Slug        *string `json:"slug"` // Generated from the name when empty
+	Description *string `json:"description"`
+	ParentID    *uint   `json:"parent_id"` // 0 for a top-level category
+	Position    *int    `json:"position"`
+}
+
+// fields returns the fields of the category set in the request.
+func (req CategoryRequest) fields() services.CategoryUpdate {
+	return services.CategoryUpdate{
+		Name:        req.Name,
+		Slug:        req.Slug,
+		Description: req.Description,
+		ParentID:    req.ParentID,
+		Position:    req.Position,
+	}
+}
+
+// ListCategories lists the categories as a tree, each holding its
+// subcategories.
+func ListCategories(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	categories, err := svc.Categories.ListCategories()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve categories", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"categories": categories,
+	})
+}
+
+// GetCategory retrieves a category by its slug, with its subcategories.
+func GetCategory(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	category, err := svc.Categories.GetCategory(mux.Vars(r)["slug"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve category")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(category)
+}
+
+// CreateCategory creates a category, top-level or under another one.
+func CreateCategory(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	category, err := svc.Categories.CreateCategory(req.fields())
+	if err != nil {
+		writeServiceError(w, r, err, "Category creation failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditCategoryCreated, category.ID, map[string]interface{}{
+		"name":      category.Name,
+		"parent_id": category.ParentID,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Category created successfully",
+		"category": category,
+	})
+}
+
+// UpdateCategory updates a category, moving it under another category when
+// its parent changes.
+func UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	categoryID, ok := routeID(w, r, types.IDField, "Invalid category ID")
+	if !ok {
+		return
+	}
+
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	category, err := svc.Categories.UpdateCategory(categoryID, req.fields())
+	if err != nil {
+		writeServiceError(w, r, err, "Category update failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditCategoryUpdated, category.ID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Category updated successfully",
+		"category": category,
+	})
+}
+
+// DeleteCategory removes a category. Its subcategories move to its parent,
+// and its posts are left without a category.
+func DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	categoryID, ok := routeID(w, r, types.IDField, "Invalid category ID")
+	if !ok {
+		return
+	}
+
+	if err := svc.Categories.DeleteCategory(categoryID); err != nil {
+		writeServiceError(w, r, err, "Category deletion failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditCategoryDeleted, categoryID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Category deleted successfully",
+	})
+}
@@ -5,78 +5,127 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/geoip"
 	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
 	"github.com/SteaceP/coderage/utils"
 
 	"github.com/gorilla/mux"
-	"gorm.io/gorm"
 )
 
 // CreateCommentRequest represents the structure for creating a new comment
 type CreateCommentRequest struct {
-	Content string `json:"content"`
+	Content  string `json:"content"`
+	ParentID *uint  `json:"parent_id,omitempty"` // Comment replied to
+}
+
+// CreateGuestCommentRequest represents the structure for creating a comment
+// without an account
+type CreateGuestCommentRequest struct {
+	Content      string `json:"content"`
+	ParentID     *uint  `json:"parent_id,omitempty"` // Comment replied to
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Website      string `json:"website,omitempty"`       // Honeypot, must be left empty
+	CaptchaToken string `json:"captcha_token,omitempty"` // Required when a CAPTCHA provider is set
+}
+
+// ModerateCommentRequest represents the structure for changing the status of
+// a comment
+type ModerateCommentRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReportCommentRequest represents the structure for reporting a comment
+type ReportCommentRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details,omitempty"`
 }
 
 // CreateComment handles creating a new comment on a post
 func CreateComment(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
-	userID := r.Context().Value("user_id").(uint)
+	userID := r.Context().Value(types.KeyUserID).(uint)
 
 	// Get post ID from URL
 	vars := mux.Vars(r)
 	postID, err := strconv.ParseUint(vars["postId"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		apperrors.Error(w, r, "Invalid post ID", http.StatusBadRequest)
 		return
 	}
 
 	// Decode request body
 	var req CreateCommentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Get database from context
-	db := r.Context().Value("db").(*gorm.DB)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
 
-	// Verify post exists
-	var post models.Post
-	if err := db.First(&post, postID).Error; err != nil {
-		http.Error(w, "Post not found", http.StatusNotFound)
+	// Check if commenting is enabled
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+	if !settings.CommentsEnabled {
+		apperrors.Error(w, r, "Commenting is disabled", http.StatusForbidden)
 		return
 	}
 
 	// Create comment
 	comment := models.Comment{
-		Content: req.Content,
-		UserID:  userID,
-		PostID:  uint(postID),
+		Content:  req.Content,
+		UserID:   userID,
+		PostID:   uint(postID),
+		ParentID: req.ParentID,
 	}
 
-	if err := db.Create(&comment).Error; err != nil {
-		http.Error(w, "Comment creation failed", http.StatusInternalServerError)
+	if err := svc.Plans.Check(services.QuotaComments, 1); err != nil {
+		writeServiceError(w, r, err, "Failed to check plan limits")
 		return
 	}
-
-	// Preload user for the response
-	if err := db.Preload("User").First(&comment, comment.ID).Error; err != nil {
-		http.Error(w, "Failed to fetch comment details", http.StatusInternalServerError)
+	origin := services.CommentOrigin{
+		IP:        geoip.RemoteIP(r).String(),
+		UserAgent: r.UserAgent(),
+		Referrer:  r.Referer(),
+	}
+	if err := svc.Posts.AddComment(&comment, origin); err != nil {
+		writeServiceError(w, r, err, "Comment creation failed")
 		return
 	}
 
 	// Prepare response
-	response := map[string]interface{}{
-		"message": "Comment created successfully",
-		"comment": map[string]interface{}{
-			"id":      utils.UintToString(comment.ID),
-			"content": comment.Content,
-			"user": map[string]string{
-				"id":       utils.UintToString(comment.User.ID),
-				"username": comment.User.Username,
-			},
-			"post_id": utils.UintToString(comment.PostID),
+	message := "Comment created successfully"
+	if comment.Status == models.CommentHidden {
+		message = "Comment awaiting approval"
+	}
+	created := map[string]interface{}{
+		"id":      utils.UintToString(comment.ID),
+		"content": comment.Content,
+		"status":  comment.Status,
+		"user": map[string]string{
+			"id":       utils.UintToString(comment.User.ID),
+			"username": comment.User.Username,
 		},
+		"post_id": utils.UintToString(comment.PostID),
+	}
+	if comment.ParentID != nil {
+		created["parent_id"] = utils.UintToString(*comment.ParentID)
+	}
+	response := map[string]interface{}{
+		"message": message,
+		"comment": created,
 	}
 
 	// Send response
@@ -85,18 +134,111 @@ func CreateComment(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// ListComments retrieves comments for a specific post
+// CreateGuestComment creates a comment of a reader without an account, who
+// gives their name and email address instead. Guest comments await the
+// approval of a moderator unless "comments.guests.moderated" is off.
+func CreateGuestComment(w http.ResponseWriter, r *http.Request) {
+	// Get post ID from URL
+	postID, ok := routeID(w, r, "postId", "Invalid post ID")
+	if !ok {
+		return
+	}
+
+	// Decode request body
+	var req CreateGuestCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Check if commenting is enabled
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+	if !settings.CommentsEnabled {
+		apperrors.Error(w, r, "Commenting is disabled", http.StatusForbidden)
+		return
+	}
+
+	// Create comment
+	comment := models.Comment{
+		Content:  req.Content,
+		PostID:   postID,
+		ParentID: req.ParentID,
+	}
+	guest := services.Guest{
+		Name:         req.Name,
+		Email:        req.Email,
+		IP:           geoip.RemoteIP(r).String(),
+		UserAgent:    r.UserAgent(),
+		Referrer:     r.Referer(),
+		Honeypot:     req.Website,
+		CaptchaToken: req.CaptchaToken,
+	}
+
+	if err := svc.Plans.Check(services.QuotaComments, 1); err != nil {
+		writeServiceError(w, r, err, "Failed to check plan limits")
+		return
+	}
+	if err := svc.GuestComments.AddComment(r.Context(), &comment, guest); err != nil {
+		writeServiceError(w, r, err, "Comment creation failed")
+		return
+	}
+
+	// Prepare response
+	message := "Comment created successfully"
+	if comment.Status == models.CommentHidden {
+		message = "Comment awaiting approval"
+	}
+	created := map[string]interface{}{
+		"id":      utils.UintToString(comment.ID),
+		"content": comment.Content,
+		"status":  comment.Status,
+		"user": map[string]string{
+			"id":       utils.UintToString(comment.User.ID),
+			"username": comment.User.Username,
+		},
+		"post_id": utils.UintToString(comment.PostID),
+	}
+	if comment.ParentID != nil {
+		created["parent_id"] = utils.UintToString(*comment.ParentID)
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"comment": created,
+	})
+}
+
+// ListComments retrieves comments for a specific post. With ?tree=true, only
+// top-level comments are paginated and their replies are nested in them.
 func ListComments(w http.ResponseWriter, r *http.Request) {
 	// Get post ID from URL
 	vars := mux.Vars(r)
 	postID, err := strconv.ParseUint(vars["postId"], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		apperrors.Error(w, r, "Invalid post ID", http.StatusBadRequest)
 		return
 	}
 
-	// Get database from context
-	db := r.Context().Value("db").(*gorm.DB)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
 
 	// Parse query parameters for pagination
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
@@ -107,25 +249,16 @@ func ListComments(w http.ResponseWriter, r *http.Request) {
 	if limit < 1 || limit > 100 {
 		limit = 10
 	}
-	offset := (page - 1) * limit
-
-	// Verify post exists
-	var post models.Post
-	if err := db.First(&post, postID).Error; err != nil {
-		http.Error(w, "Post not found", http.StatusNotFound)
-		return
-	}
 
-	// Fetch comments with pagination and preload user
-	var comments []models.Comment
-	var totalCount int64
-	if err := db.Model(&models.Comment{}).Where("post_id = ?", postID).Count(&totalCount).Error; err != nil {
-		http.Error(w, "Failed to count comments", http.StatusInternalServerError)
-		return
+	// Fetch comments, marking the ones liked by the requesting user, if any
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	listComments := svc.Posts.ListComments
+	if tree, _ := strconv.ParseBool(r.URL.Query().Get("tree")); tree {
+		listComments = svc.Posts.ListCommentThreads
 	}
-
-	if err := db.Preload("User").Where("post_id = ?", postID).Offset(offset).Limit(limit).Find(&comments).Error; err != nil {
-		http.Error(w, "Failed to retrieve comments", http.StatusInternalServerError)
+	comments, totalCount, err := listComments(uint(postID), page, limit, viewerID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve comments")
 		return
 	}
 
@@ -133,10 +266,10 @@ func ListComments(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"comments": comments,
 		"pagination": map[string]interface{}{
-			"total_comments": totalCount,
-			"page":           page,
-			"limit":          limit,
-			"total_pages":    (totalCount + int64(limit) - 1) / int64(limit),
+			"total":       totalCount,
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
 		},
 	}
 
@@ -145,3 +278,235 @@ func ListComments(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// ListReplies retrieves the direct replies to a comment
+func ListReplies(w http.ResponseWriter, r *http.Request) {
+	// Get comment ID from URL
+	vars := mux.Vars(r)
+	commentID, err := strconv.ParseUint(vars[types.IDField], 10, 64)
+	if err != nil {
+		apperrors.Error(w, r, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Fetch replies, marking the ones liked by the requesting user, if any
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	replies, err := svc.Posts.ListReplies(uint(commentID), viewerID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve replies")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"replies": replies,
+	})
+}
+
+// LikeComment handles liking a comment as the authenticated user
+func LikeComment(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get comment ID from URL
+	vars := mux.Vars(r)
+	commentID, err := strconv.ParseUint(vars[types.IDField], 10, 64)
+	if err != nil {
+		apperrors.Error(w, r, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Like comment
+	comment, err := svc.Posts.LikeComment(uint(commentID), userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to like comment")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Comment liked successfully",
+		"liked":      comment.Liked,
+		"like_count": comment.LikeCount,
+	})
+}
+
+// UnlikeComment handles removing the authenticated user's like from a comment
+func UnlikeComment(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get comment ID from URL
+	vars := mux.Vars(r)
+	commentID, err := strconv.ParseUint(vars[types.IDField], 10, 64)
+	if err != nil {
+		apperrors.Error(w, r, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Unlike comment
+	comment, err := svc.Posts.UnlikeComment(uint(commentID), userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to unlike comment")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Comment unliked successfully",
+		"liked":      comment.Liked,
+		"like_count": comment.LikeCount,
+	})
+}
+
+// ModerateComment changes the status of a comment, hiding it from readers,
+// deleting it or publishing it again. Admins can moderate every comment, and
+// authors the comments on their posts.
+func ModerateComment(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get comment ID from URL
+	vars := mux.Vars(r)
+	commentID, err := strconv.ParseUint(vars[types.IDField], 10, 64)
+	if err != nil {
+		apperrors.Error(w, r, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req ModerateCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	comment, err := svc.Posts.ModerateComment(uint(commentID), userID, req.Status, utils.SanitizeInput(req.Reason))
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to moderate comment")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditCommentModerated, comment.ID, map[string]interface{}{
+		"status": comment.Status,
+		"reason": comment.ModerationReason,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Comment status updated successfully",
+		"comment": comment,
+	})
+}
+
+// ReportComment handles reporting an abusive comment
+func ReportComment(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get comment ID from URL
+	vars := mux.Vars(r)
+	commentID, err := strconv.ParseUint(vars[types.IDField], 10, 64)
+	if err != nil {
+		apperrors.Error(w, r, "Invalid comment ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req ReportCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := svc.Posts.ReportComment(uint(commentID), userID, req.Reason, utils.SanitizeInput(req.Details)); err != nil {
+		writeServiceError(w, r, err, "Failed to report comment")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Comment reported successfully",
+	})
+}
+
+// ListModerationQueue lists the hidden and reported comments awaiting review.
+func ListModerationQueue(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Parse query parameters for pagination
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	comments, totalCount, err := svc.Posts.ListModerationQueue(page, limit)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve moderation queue", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"comments": comments,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
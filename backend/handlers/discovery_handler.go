@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/types"
+)
+
+// GetTrendingPosts lists the posts trending on their recent views, likes and
+// comments, best first. The ranking is cached for a few minutes.
+func GetTrendingPosts(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	posts, err := svc.Discovery.Trending()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve trending posts", http.StatusInternalServerError)
+		return
+	}
+
+	// Apply site settings to posts
+	if err := preparePosts(r, svc, posts); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"posts": posts})
+}
+
+// GetRelatedPosts lists the posts related to a post, best first. The
+// ranking is cached for a few minutes.
+func GetRelatedPosts(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	postID, ok := routeID(w, r, types.IDField, "Invalid post ID")
+	if !ok {
+		return
+	}
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	post, err := svc.Posts.FindPost(postID, viewerID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve post")
+		return
+	}
+
+	posts, err := svc.Discovery.Related(post)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve related posts", http.StatusInternalServerError)
+		return
+	}
+
+	// Apply site settings to posts
+	if err := preparePosts(r, svc, posts); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"posts": posts})
+}
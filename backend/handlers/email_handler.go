@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/securitylog"
+
+	"github.com/gorilla/mux"
+)
+
+// maxFeedbackSize bounds the notifications of the email providers
+const maxFeedbackSize = 1 << 20
+
+// SESFeedback receives the bounce and complaint notifications of Amazon SES,
+// posted by an SNS topic. The subscription of the topic is confirmed on its
+// first message.
+func SESFeedback(w http.ResponseWriter, r *http.Request) {
+	body, ok := readFeedback(w, r)
+	if !ok {
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var message mailer.SNSMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		apperrors.Error(w, r, "Invalid SNS message", http.StatusBadRequest)
+		return
+	}
+
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		if err := mailer.ConfirmSNSSubscription(r.Context(), message.SubscribeURL); err != nil {
+			apperrors.Error(w, r, "Failed to confirm the subscription", http.StatusBadGateway)
+			return
+		}
+	case "Notification":
+		feedback, err := mailer.ParseSES(message.Message)
+		if err != nil {
+			apperrors.Error(w, r, "Invalid SES notification", http.StatusBadRequest)
+			return
+		}
+		if err := svc.Suppressions.Record("ses", feedback); err != nil {
+			apperrors.Error(w, r, "Failed to record the notification", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Send response
+	w.WriteHeader(http.StatusOK)
+}
+
+// SendGridFeedback receives the bounce and spam report events of the
+// SendGrid Event Webhook.
+func SendGridFeedback(w http.ResponseWriter, r *http.Request) {
+	body, ok := readFeedback(w, r)
+	if !ok {
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	feedback, err := mailer.ParseSendGrid(body)
+	if err != nil {
+		apperrors.Error(w, r, "Invalid SendGrid events", http.StatusBadRequest)
+		return
+	}
+	if err := svc.Suppressions.Record("sendgrid", feedback); err != nil {
+		apperrors.Error(w, r, "Failed to record the events", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.WriteHeader(http.StatusOK)
+}
+
+// readFeedback checks the token of a notification of an email provider, and
+// returns its body. It replies with an error when the token is wrong or the
+// body can't be read.
+func readFeedback(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	token := config.Get().Email.Feedback.Token
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+		securitylog.AuthFailure(r, "invalid email feedback token")
+		apperrors.Error(w, r, "Invalid token", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFeedbackSize))
+	if err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// ListEmailSuppressions lists the addresses emails aren't sent to anymore,
+// most recently suppressed first.
+func ListEmailSuppressions(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Parse pagination parameters
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	suppressions, totalCount, err := svc.Suppressions.List(page, limit)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve email suppressions", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"suppressions": suppressions,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// DeleteEmailSuppression lifts the suppression of an address, so emails are
+// sent to it again.
+func DeleteEmailSuppression(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	email := mux.Vars(r)["email"]
+	if err := svc.Suppressions.Lift(email); err != nil {
+		writeServiceError(w, r, err, "Failed to lift email suppression")
+		return
+	}
+	recordAdminAction(r, svc, "email_suppression.lifted", 0, map[string]interface{}{"email": email})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Email suppression lifted successfully",
+	})
+}
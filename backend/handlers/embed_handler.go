@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/types"
+)
+
+// oEmbedVersion is the version of the oEmbed specification implemented
+const oEmbedVersion = "1.0"
+
+type CreateEmbedTokenRequest struct {
+	Type           string `json:"type"` // post or comment
+	ID             uint   `json:"id"`
+	Origin         string `json:"origin,omitempty"`           // Site allowed to use the token, any when empty
+	ExpiresInHours int    `json:"expires_in_hours,omitempty"` // embeds.default_ttl when zero
+}
+
+// CreateEmbedToken issues a token letting an external site embed a post or a
+// comment thread, without an API key. Only the author of the post and admins
+// can issue them.
+func CreateEmbedToken(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req CreateEmbedTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := r.Context().Value(types.KeyUserID).(uint)
+	ttl := time.Duration(req.ExpiresInHours) * time.Hour
+	token, err := svc.Embeds.Issue(userID, req.Type, req.ID, req.Origin, ttl)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to issue embed token")
+		return
+	}
+
+	response := map[string]interface{}{
+		"token":     token,
+		"embed_url": embedURL(token.Token),
+		"api_url":   strings.TrimRight(config.Get().Server.BaseURL, "/") + "/embed?token=" + url.QueryEscape(token.Token),
+	}
+	if config.Get().Embeds.OEmbed {
+		response["oembed_url"] = strings.TrimRight(config.Get().Server.BaseURL, "/") + "/oembed?url=" + url.QueryEscape(embedURL(token.Token))
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetEmbed returns the post or comment thread an embed token gives access
+// to, in the restricted form shown by embeds. Tokens bound to a site can only
+// be used by its pages, which are allowed to read the response across
+// origins.
+func GetEmbed(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	embed, err := svc.Embeds.Resolve(r.URL.Query().Get("token"), r.Header.Get("Origin"))
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve embed")
+		return
+	}
+
+	// Apply site settings to the post
+	prepared := []models.Post{*embed.Post}
+	if err := preparePosts(r, svc, prepared); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"type":       embed.Token.Type,
+		"expires_at": embed.Token.ExpiresAt,
+		"post":       embedPost(prepared[0]),
+	}
+	if embed.Comment != nil {
+		response["comment"] = embedComment(*embed.Comment)
+	}
+
+	// Send response
+	origin := embed.Token.Origin
+	if origin == "" {
+		origin = "*"
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Add("Vary", "Origin")
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// OEmbed is the oEmbed provider of the site. Given the public URL of a
+// post, it returns a rich card linking to it; given the URL of an embed,
+// with its token, the HTML embedding it in an iframe. The card is sized to
+// the maxwidth and maxheight of the consumer, and can be cached for
+// "embeds.cache_ttl", or until the embed token expires. Only the JSON format
+// is supported.
+func OEmbed(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "json" {
+		apperrors.Error(w, r, "Only the json format is supported", http.StatusNotImplemented)
+		return
+	}
+
+	cfg := config.Get()
+	width := boundedSize(query.Get("maxwidth"), cfg.Embeds.Width)
+	height := boundedSize(query.Get("maxheight"), cfg.Embeds.Height)
+	cacheAge := cfg.Embeds.CacheTTL
+	response := map[string]interface{}{
+		"version":       oEmbedVersion,
+		"type":          "rich",
+		"provider_name": cfg.Site.Title,
+		"provider_url":  cfg.Site.URL,
+		"width":         width,
+		"height":        height,
+	}
+
+	target := query.Get("url")
+	if token, ok := embedToken(target); ok && cfg.Embeds.Enabled {
+		embed, err := svc.Embeds.Resolve(token, "")
+		if err != nil {
+			writeServiceError(w, r, err, "Failed to retrieve embed")
+			return
+		}
+
+		title := embed.Post.Title
+		author := embed.Post.User.Username
+		if embed.Comment != nil {
+			title = "Comment on " + embed.Post.Title
+			author = embed.Comment.User.Username
+		}
+		response["title"] = title
+		response["author_name"] = author
+		response["html"] = fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" sandbox="allow-scripts allow-popups" title="%s"></iframe>`,
+			html.EscapeString(embedURL(token)), width, height, html.EscapeString(title))
+		if untilExpiry := time.Until(embed.Token.ExpiresAt); untilExpiry < cacheAge {
+			cacheAge = untilExpiry
+		}
+	} else if slug, ok := postSlug(target); ok {
+		post, err := svc.Embeds.PublicPost(slug)
+		if err != nil {
+			writeServiceError(w, r, err, "Failed to retrieve post")
+			return
+		}
+
+		response["title"] = post.Title
+		response["author_name"] = post.User.Username
+		response["author_url"] = strings.TrimRight(cfg.Site.URL, "/") + "/users/" + url.PathEscape(post.User.Username)
+		response["html"] = postCard(*post, width)
+		if post.FeaturedImage != "" {
+			response["thumbnail_url"] = post.FeaturedImage
+		}
+		w.Header().Set("Last-Modified", post.UpdatedAt.UTC().Format(http.TimeFormat))
+	} else {
+		apperrors.Error(w, r, "The url must be the URL of a post or an embed of this site", http.StatusNotFound)
+		return
+	}
+	response["cache_age"] = int64(cacheAge.Seconds())
+
+	// Send response
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(cacheAge.Seconds())))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// postCard returns the HTML of the card embedding a post, at most width
+// pixels wide.
+func postCard(post models.Post, width int) string {
+	var card strings.Builder
+	fmt.Fprintf(&card, `<blockquote class="coderage-embed" style="max-width:%dpx">`, width)
+	fmt.Fprintf(&card, `<p><a href="%s">%s</a></p>`, html.EscapeString(postURL(post)), html.EscapeString(post.Title))
+	if post.Excerpt != "" {
+		fmt.Fprintf(&card, `<p>%s</p>`, html.EscapeString(post.Excerpt))
+	}
+	fmt.Fprintf(&card, `<footer>%s</footer>`, html.EscapeString(post.User.Username))
+	card.WriteString(`</blockquote>`)
+	return card.String()
+}
+
+// postSlug returns the slug of the public URL of a post on the site.
+func postSlug(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	site, err := url.Parse(config.Get().Site.URL)
+	if err != nil || !strings.EqualFold(site.Host, u.Host) {
+		return "", false
+	}
+	slug, ok := strings.CutPrefix(u.Path, strings.TrimRight(site.Path, "/")+"/posts/")
+	if !ok || slug == "" || strings.Contains(slug, "/") {
+		return "", false
+	}
+	return slug, true
+}
+
+// embedURL returns the URL of the page of the site showing an embed.
+func embedURL(token string) string {
+	return strings.TrimRight(config.Get().Site.URL, "/") + "/embed?token=" + url.QueryEscape(token)
+}
+
+// embedToken returns the token of an embed URL, either of the site page or
+// of the API endpoint.
+func embedToken(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Path != "/embed" {
+		return "", false
+	}
+	for _, base := range []string{config.Get().Site.URL, config.Get().Server.BaseURL} {
+		if baseURL, err := url.Parse(base); err == nil && strings.EqualFold(baseURL.Host, u.Host) {
+			token := u.Query().Get("token")
+			return token, token != ""
+		}
+	}
+	return "", false
+}
+
+// boundedSize returns the configured size of an embed, reduced to the
+// maximum requested by the consumer, if any.
+func boundedSize(max string, size int) int {
+	if limit, err := strconv.Atoi(max); err == nil && limit > 0 && limit < size {
+		return limit
+	}
+	return size
+}
+
+// embedPost returns the public fields of an embedded post.
+func embedPost(post models.Post) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             post.ID,
+		"title":          post.Title,
+		"excerpt":        post.Excerpt,
+		"content":        post.Content,
+		"content_gated":  post.ContentGated,
+		"featured_image": post.FeaturedImage,
+		"published_at":   post.PublishedAt,
+		"url":            postURL(post),
+		"license":        post.LicenseInfo,
+		"author":         embedAuthor(post.User),
+	}
+}
+
+// embedComment returns the public fields of an embedded comment and its
+// replies.
+func embedComment(comment models.Comment) map[string]interface{} {
+	replies := make([]map[string]interface{}, len(comment.Replies))
+	for i, reply := range comment.Replies {
+		replies[i] = embedComment(reply)
+	}
+	return map[string]interface{}{
+		"id":         comment.ID,
+		"content":    comment.Content,
+		"like_count": comment.LikeCount,
+		"created_at": comment.CreatedAt,
+		"author":     embedAuthor(comment.User),
+		"replies":    replies,
+	}
+}
+
+// embedAuthor returns the public fields of the author of embedded content.
+func embedAuthor(user models.User) map[string]interface{} {
+	return map[string]interface{}{
+		"username":        user.Username,
+		"profile_picture": user.ProfilePicture,
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/gorilla/mux"
+)
+
+// exportFormat returns the format of an export requested by the "format"
+// query parameter, json by default.
+func exportFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	return services.ExportJSON
+}
+
+// ExportContent streams the zip archive of the posts, comments and users, in
+// the format of the "format" query parameter: json or markdown. Exports too
+// large to be generated within the request timeout run in the background
+// through StartExport instead.
+func ExportContent(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	format := exportFormat(r)
+	if format != services.ExportJSON && format != services.ExportMarkdown {
+		apperrors.Error(w, r, "Format must be json or markdown", http.StatusBadRequest)
+		return
+	}
+	recordAdminAction(r, svc, services.AuditExportStarted, 0, map[string]interface{}{
+		"format": format,
+	})
+
+	// Stream the archive. Errors past this point can only abort the
+	// response, so the client doesn't mistake it for a complete archive.
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", exportDisposition(time.Now()))
+	w.WriteHeader(http.StatusOK)
+	if err := svc.Exports.Write(r.Context(), w, format); err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// StartExport generates the zip archive of the posts, comments and users in
+// the background, in the format of the "format" query parameter. The run is
+// returned right away, and its status can be polled at the URL of the
+// Location header.
+func StartExport(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	userID, _ := r.Context().Value(types.KeyUserID).(uint)
+	run, err := svc.Exports.Start(exportFormat(r), userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to start export")
+		return
+	}
+	recordAdminAction(r, svc, services.AuditExportStarted, 0, map[string]interface{}{
+		"format": exportFormat(r),
+		"run_id": run.ID,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/admin/export/"+run.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// GetExport retrieves the status of a background export.
+func GetExport(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	run, err := svc.Exports.GetRun(mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve export")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(run)
+}
+
+// DownloadExport sends the zip archive of a background export once it
+// succeeded. Only the archive of the latest export is kept.
+func DownloadExport(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	run, err := svc.Exports.GetRun(mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve export")
+		return
+	}
+	file, err := svc.Exports.Open(run.ID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to open export")
+		return
+	}
+	defer file.Close()
+
+	// Send the archive
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", exportDisposition(*run.FinishedAt))
+	if info, err := file.Stat(); err == nil {
+		w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, file)
+}
+
+// exportDisposition returns the Content-Disposition header of an export
+// archive generated at the given time.
+func exportDisposition(at time.Time) string {
+	return fmt.Sprintf("attachment; filename=\"export-%s.zip\"", at.UTC().Format("2006-01-02-150405"))
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/licenses"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+)
+
+// feedSize is the number of posts included in the feed
+const feedSize = 20
+
+// rssFeed is an RSS 2.0 document using the Creative Commons RSS module to
+// expose machine-readable reuse terms.
+type rssFeed struct {
+	XMLName        xml.Name   `xml:"rss"`
+	Version        string     `xml:"version,attr"`
+	CreativeCommon string     `xml:"xmlns:creativeCommons,attr"`
+	Channel        rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Copyright     string    `xml:"copyright,omitempty"`
+	License       string    `xml:"creativeCommons:license,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	Description string   `xml:"description"`
+	Author      string   `xml:"author,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+	License     string   `xml:"creativeCommons:license,omitempty"`
+}
+
+// GetFeed serves the RSS feed of the latest posts
+func GetFeed(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	posts, _, err := svc.Posts.ListPosts(1, feedSize, nil, 0)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve posts", http.StatusInternalServerError)
+		return
+	}
+	if err := preparePosts(r, svc, posts); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+	siteLicense := licenses.Resolve(settings.DefaultLicense, "")
+
+	feed := rssFeed{
+		Version:        "2.0",
+		CreativeCommon: "http://backend.userland.com/creativeCommonsRssModule",
+		Channel: rssChannel{
+			Title:       config.Get().Site.Title,
+			Link:        config.Get().Site.URL,
+			Description: config.Get().Site.Description,
+			Copyright:   siteLicense.Name,
+			License:     siteLicense.URL,
+			Items:       make([]rssItem, 0, len(posts)),
+		},
+	}
+	loc := services.SiteLocation(settings)
+	if len(posts) > 0 {
+		feed.Channel.LastBuildDate = posts[0].PublishedAt.In(loc).Format(time.RFC1123Z)
+	}
+
+	for _, post := range posts {
+		feed.Channel.Items = append(feed.Channel.Items, feedItem(post, loc))
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(feed)
+}
+
+// feedItem converts a post to an RSS item, dated in the given time zone.
+func feedItem(post models.Post, loc *time.Location) rssItem {
+	link := postURL(post)
+
+	description := post.Excerpt
+	if description == "" {
+		description = post.Content
+	}
+
+	categories := make([]string, len(post.Tags))
+	for i, tag := range post.Tags {
+		categories[i] = tag.Name
+	}
+
+	item := rssItem{
+		Title:       post.Title,
+		Link:        link,
+		GUID:        link,
+		Description: description,
+		Author:      post.User.Username,
+		Categories:  categories,
+		PubDate:     post.PublishedAt.In(loc).Format(time.RFC1123Z),
+	}
+	if post.LicenseInfo != nil {
+		item.License = post.LicenseInfo.URL
+	}
+	return item
+}
+
+// postURL returns the public URL of a post on the frontend.
+func postURL(post models.Post) string {
+	return strings.TrimRight(config.Get().Site.URL, "/") + "/posts/" + post.Slug
+}
+
+// translatedPostURL returns the public URL of the translation of a post to a
+// locale.
+func translatedPostURL(post models.Post, locale string) string {
+	return postURL(post) + "?lang=" + url.QueryEscape(locale)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// fieldSet is the sparse fieldset asked for with the fields query parameter:
+// a comma-separated list of the fields of a resource to return, with dots
+// selecting the fields of nested objects, e.g. "title,user.username". A
+// field mapped to nil is returned whole.
+type fieldSet map[string]fieldSet
+
+// requestedFields returns the fields asked for by a request, nil when it
+// asks for every field.
+func requestedFields(r *http.Request) fieldSet {
+	param := r.URL.Query().Get("fields")
+	if strings.TrimSpace(param) == "" {
+		return nil
+	}
+
+	fields := fieldSet{}
+	for _, path := range strings.Split(param, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		set := fields
+		names := strings.Split(path, ".")
+		for i, name := range names {
+			nested, selected := set[name]
+			if i == len(names)-1 {
+				set[name] = nil
+				break
+			}
+			if selected && nested == nil {
+				// The whole field is already selected
+				break
+			}
+			if nested == nil {
+				nested = fieldSet{}
+				set[name] = nested
+			}
+			set = nested
+		}
+	}
+	return fields
+}
+
+// apply returns the JSON form of v reduced to the fields of the set, which
+// apply to each element of arrays. v is returned as is when the set is nil.
+// Unknown fields are ignored.
+func (fields fieldSet) apply(v interface{}) (interface{}, error) {
+	if fields == nil {
+		return v, nil
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+	return fields.prune(data), nil
+}
+
+func (fields fieldSet) prune(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		kept := make(map[string]interface{}, len(fields))
+		for name, nested := range fields {
+			value, ok := v[name]
+			if !ok {
+				continue
+			}
+			if nested != nil {
+				value = nested.prune(value)
+			}
+			kept[name] = value
+		}
+		return kept
+	case []interface{}:
+		for i := range v {
+			v[i] = fields.prune(v[i])
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/graph"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// GraphQL serves the GraphQL API, an alternative to the REST API for the
+// clients preferring to select the posts, comments and users they need in
+// one query. Queries are sent as JSON with POST, or in the query string with
+// GET, and are resolved with the services of the request, so the same access
+// rules apply. The associations of the objects selected are loaded in
+// batches, one query per level of the selection.
+func GraphQL() http.HandlerFunc {
+	cfg := config.Get().GraphQL
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.FixedComplexityLimit(cfg.ComplexityLimit))
+	if cfg.Introspection {
+		srv.Use(extension.Introspection{})
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get services from context
+		svc, ok := servicesFromContext(r)
+		if !ok {
+			servicesUnavailable(w, r)
+			return
+		}
+
+		viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+		ctx := graph.WithRequest(r.Context(), &graph.Request{
+			Services: svc,
+			ViewerID: viewerID,
+			Prepare: func(posts []models.Post) error {
+				return preparePosts(r, svc, posts)
+			},
+		})
+		srv.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
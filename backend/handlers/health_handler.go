@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/types"
+
+	"gorm.io/gorm"
+)
+
+// HealthCheck reports whether the API and its database are available. It is
+// meant for load balancer and orchestrator probes.
+func HealthCheck(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	database := "ok"
+
+	// Check database connection
+	db, ok := r.Context().Value(types.KeyDB).(*gorm.DB)
+	if !ok {
+		status, database = http.StatusServiceUnavailable, "unavailable"
+	} else if sqlDB, err := db.DB(); err != nil || sqlDB.PingContext(r.Context()) != nil {
+		status, database = http.StatusServiceUnavailable, "unavailable"
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   http.StatusText(status),
+		"database": database,
+	})
+}
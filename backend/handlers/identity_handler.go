@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/geoip"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/oauth"
+	"github.com/SteaceP/coderage/securitylog"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type ProviderLoginRequest struct {
+	Code string `json:"code"` // Authorization code the provider redirected the user with
+}
+
+type LinkIdentityRequest struct {
+	Code     string `json:"code,omitempty"`     // Authorization code, to link a provider
+	Password string `json:"password,omitempty"` // New password, to link a password
+}
+
+// ListLoginProviders lists the login providers users can log in with.
+func ListLoginProviders(w http.ResponseWriter, r *http.Request) {
+	providers := oauth.Enabled()
+	if providers == nil {
+		providers = []string{}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"providers": providers,
+	})
+}
+
+// AuthorizeProvider returns the URL of a provider the site sends users to,
+// to log in or link their account, and the state the provider redirects
+// them back with. The site keeps the state and checks it on the redirect.
+func AuthorizeProvider(w http.ResponseWriter, r *http.Request) {
+	provider, err := oauth.Get(mux.Vars(r)["provider"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve login provider")
+		return
+	}
+
+	state, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to generate state", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"url":   provider.AuthCodeURL(state),
+		"state": state,
+	})
+}
+
+// ProviderLogin logs a user in with the authorization code a provider
+// redirected them with. Users whose provider account isn't linked are
+// signed up, unless the email address of the account is already in use: they
+// must then log in to that account and link the provider.
+func ProviderLogin(w http.ResponseWriter, r *http.Request) {
+	var req ProviderLoginRequest
+
+	// Decode request body
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	profile, err := svc.Identities.Profile(r.Context(), mux.Vars(r)["provider"], req.Code)
+	if err != nil {
+		writeServiceError(w, r, err, "Login failed")
+		return
+	}
+
+	session, err := loginWithProvider(r, svc, profile, r.URL.Query().Get("ref"))
+	if err != nil {
+		var refused *loginRefused
+		if errors.As(err, &refused) {
+			apperrors.Error(w, r, refused.message, http.StatusForbidden)
+			return
+		}
+		writeServiceError(w, r, err, "Login failed")
+		return
+	}
+
+	// Prepare response
+	user := map[string]string{
+		"id":       utils.UintToString(session.user.ID),
+		"username": session.user.Username,
+		"email":    session.user.Email,
+	}
+	response := map[string]interface{}{
+		"message": session.message,
+		"user":    user,
+	}
+	if session.tokens != nil {
+		response["token"] = session.tokens.AccessToken
+		response["refresh_token"] = session.tokens.RefreshToken
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(session.status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ProviderRedirect starts a login the API drives itself, for sites that
+// don't handle the redirect of the provider: it sends the user to the
+// provider, which redirects them back to ProviderCallback. The state is kept
+// in a cookie scoped to the callback, with the referral code of the user.
+func ProviderRedirect(w http.ResponseWriter, r *http.Request) {
+	provider, err := oauth.Callback(mux.Vars(r)["provider"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve login provider")
+		return
+	}
+
+	state, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to generate state", http.StatusInternalServerError)
+		return
+	}
+
+	setCallbackCookie(w, provider.Name(), oauthStateCookie, state)
+	if ref := r.URL.Query().Get("ref"); ref != "" {
+		setCallbackCookie(w, provider.Name(), oauthRefCookie, ref)
+	}
+	http.Redirect(w, r, provider.AuthCodeURL(state), http.StatusFound)
+}
+
+// ProviderCallback completes a login started by ProviderRedirect, once the
+// provider redirected the user back with a code. The user is logged in or
+// signed up as with ProviderLogin, and redirected to the login completion
+// page of the site with the token pair, or the failure, in the fragment.
+func ProviderCallback(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["provider"]
+	query := r.URL.Query()
+
+	// Check the state, then forget it: it is only good once
+	cookie, err := r.Cookie(oauthStateCookie)
+	ref := ""
+	if c, err := r.Cookie(oauthRefCookie); err == nil {
+		ref = c.Value
+	}
+	clearCallbackCookie(w, name, oauthStateCookie)
+	clearCallbackCookie(w, name, oauthRefCookie)
+	if err != nil || query.Get("state") == "" ||
+		subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		securitylog.AuthFailure(r, "login provider state mismatch")
+		completeLogin(w, r, url.Values{"error": {"invalid_state"}, "message": {"Login expired, try again"}})
+		return
+	}
+	if reason := query.Get("error"); reason != "" {
+		// The user declined, or the provider refused to authorize the site
+		completeLogin(w, r, url.Values{"error": {"access_denied"}, "message": {"Login was cancelled"}})
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		completeLogin(w, r, url.Values{"error": {"login_failed"}, "message": {"Login failed"}})
+		return
+	}
+
+	profile, err := svc.Identities.CallbackProfile(r.Context(), name, query.Get("code"))
+	if err == nil {
+		var session *providerSession
+		session, err = loginWithProvider(r, svc, profile, ref)
+		if err == nil {
+			fragment := url.Values{"status": {session.outcome}}
+			if session.tokens != nil {
+				fragment.Set("token", session.tokens.AccessToken)
+				fragment.Set("refresh_token", session.tokens.RefreshToken)
+			}
+			completeLogin(w, r, fragment)
+			return
+		}
+	}
+
+	var refused *loginRefused
+	fragment := url.Values{"error": {"login_failed"}, "message": {"Login failed"}}
+	switch {
+	case errors.As(err, &refused):
+		fragment = url.Values{"error": {refused.code}, "message": {refused.message}}
+	case errors.Is(err, services.ErrIdentityEmailTaken):
+		fragment = url.Values{"error": {"email_taken"}, "message": {"Log in to the account using this email address and link the provider"}}
+	case errors.Is(err, services.ErrPendingApproval):
+		fragment = url.Values{"error": {"pending_approval"}, "message": {"Your account is awaiting approval"}}
+	case errors.Is(err, services.ErrAccountInactive):
+		fragment = url.Values{"error": {"account_inactive"}, "message": {"Your account is deactivated"}}
+	case errors.Is(err, oauth.ErrExchangeFailed):
+		securitylog.AuthFailure(r, err.Error())
+	}
+	completeLogin(w, r, fragment)
+}
+
+// OAuth callback cookies
+const (
+	oauthStateCookie = "oauth_state"
+	oauthRefCookie   = "oauth_ref"
+)
+
+// oauthCookieMaxAge bounds the time users have to authorize the site at the
+// provider.
+const oauthCookieMaxAge = 10 * 60
+
+// setCallbackCookie sets a cookie only sent to the callback of a provider.
+// It is sent on the top-level redirect of the provider, so SameSite is Lax.
+func setCallbackCookie(w http.ResponseWriter, provider, name, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/auth/" + provider + "/callback",
+		MaxAge:   oauthCookieMaxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.Get().Server.BaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearCallbackCookie removes a cookie set by setCallbackCookie.
+func clearCallbackCookie(w http.ResponseWriter, provider, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     "/auth/" + provider + "/callback",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(config.Get().Server.BaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// completeLogin redirects the user to the login completion page of the
+// site. Values are passed in the fragment, which browsers don't send to
+// servers or in the Referer header.
+func completeLogin(w http.ResponseWriter, r *http.Request, fragment url.Values) {
+	target := strings.TrimRight(config.Get().Site.URL, "/") + config.Get().OAuth.CompletePath
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	http.Redirect(w, r, target+"#"+fragment.Encode(), http.StatusFound)
+}
+
+// providerSession is a successful login with a provider account.
+type providerSession struct {
+	user    *models.User
+	tokens  *services.TokenDetails // Unset while a new account awaits approval
+	status  int                    // Status of the JSON response
+	outcome string                 // logged_in, signed_up or pending_approval
+	message string
+}
+
+// loginRefused is a sign-up with a provider account the site doesn't
+// accept, answered with 403.
+type loginRefused struct {
+	code    string
+	message string
+}
+
+func (e *loginRefused) Error() string {
+	return e.message
+}
+
+// loginWithProvider logs in the user a provider account is linked to, and
+// issues them a token pair. Users whose account isn't linked are signed up,
+// with the referral code they came with, if registration is open to them.
+func loginWithProvider(r *http.Request, svc *services.Services, profile *oauth.Profile, ref string) (*providerSession, error) {
+	user, err := svc.Identities.Login(profile)
+	session := &providerSession{status: http.StatusOK, outcome: "logged_in", message: "Login successful"}
+	if errors.Is(err, services.ErrIdentityNotFound) {
+		// Sign the user up, if registration is open
+		settings, err := svc.Settings.Get()
+		if err != nil {
+			return nil, err
+		}
+		if !settings.RegistrationOpen {
+			return nil, &loginRefused{code: "registration_closed", message: "Registration is closed"}
+		}
+		if geoip.Blocked(geoip.RequestCountry(r), config.Get().GeoIP.BlockedRegistration) {
+			return nil, &loginRefused{code: "registration_unavailable", message: "Registration is not available in your country"}
+		}
+
+		user, err = svc.Identities.SignUp(profile, settings.RequireApproval)
+		if err != nil {
+			return nil, err
+		}
+
+		// Verify the email address if the provider didn't, and credit the
+		// referrer, as on a regular sign-up
+		if user.VerifiedAt == nil {
+			_ = svc.Verification.SendVerification(r.Context(), user)
+		}
+		_ = svc.Referrals.Attribute(user.ID, ref)
+
+		if user.PendingApproval {
+			return &providerSession{
+				user:    user,
+				status:  http.StatusAccepted,
+				outcome: "pending_approval",
+				message: "User created successfully, awaiting approval",
+			}, nil
+		}
+		session = &providerSession{status: http.StatusCreated, outcome: "signed_up", message: "User created successfully"}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Generate tokens
+	tokens, err := svc.Auth.CreateTokenPair(user, requestClient(r))
+	if err != nil {
+		return nil, err
+	}
+	session.user = user
+	session.tokens = tokens
+	recordAction(r, svc, user.ID, services.AuditLogin, user.ID, map[string]interface{}{
+		"provider": profile.Provider,
+	})
+	return session, nil
+}
+
+// ListIdentities lists the login methods of the authenticated user: whether
+// they have a password, and the provider accounts they linked.
+func ListIdentities(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	methods, err := svc.Identities.LoginMethods(userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve login methods")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(methods)
+}
+
+// LinkIdentity links a login method to the authenticated user: the account
+// of a provider, with the authorization code it redirected the user with, or
+// a password, for users who signed up through a provider.
+func LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req LinkIdentityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	provider := mux.Vars(r)["provider"]
+	if provider == services.PasswordLogin {
+		if err := svc.Identities.SetPassword(userID, req.Password); err != nil {
+			writeServiceError(w, r, err, "Failed to set password")
+			return
+		}
+	} else {
+		profile, err := svc.Identities.Profile(r.Context(), provider, req.Code)
+		if err != nil {
+			writeServiceError(w, r, err, "Failed to link login provider")
+			return
+		}
+		if _, err := svc.Identities.Link(userID, profile); err != nil {
+			writeServiceError(w, r, err, "Failed to link login provider")
+			return
+		}
+	}
+
+	methods, err := svc.Identities.LoginMethods(userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve login methods")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(methods)
+}
+
+// UnlinkIdentity removes a login method of the authenticated user, a
+// provider or their password. The last login method can't be removed.
+func UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	if err := svc.Identities.Unlink(userID, mux.Vars(r)["provider"]); err != nil {
+		writeServiceError(w, r, err, "Failed to unlink login method")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Login method unlinked successfully",
+	})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/gorilla/mux"
+)
+
+// ImportContent imports a WordPress WXR export, a Ghost JSON export or a zip
+// of Markdown files, expected in the "file" form field. The import runs in
+// the background: its run is returned right away, and its status can be
+// polled at the URL of the Location header.
+//
+// Files larger than "tasks.import_max_size_mb" are rejected.
+func ImportContent(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Limit request size, leaving room for the multipart envelope
+	maxSize := config.Get().Tasks.ImportMaxSizeMB << 20
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+(1<<20))
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			apperrors.Error(w, r, "File too large", http.StatusRequestEntityTooLarge)
+		} else {
+			apperrors.Error(w, r, "Invalid multipart form", http.StatusBadRequest)
+		}
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		apperrors.Error(w, r, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxSize {
+		apperrors.Error(w, r, "File too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+
+	// Start the import
+	userID, _ := r.Context().Value(types.KeyUserID).(uint)
+	run, err := svc.Imports.Start(data, userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to start import")
+		return
+	}
+	recordAdminAction(r, svc, services.AuditImportStarted, 0, map[string]interface{}{
+		"file":   header.Filename,
+		"run_id": run.ID,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/admin/import/"+run.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// GetImport retrieves the status of an import.
+func GetImport(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	run, err := svc.Imports.GetRun(mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve import")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(run)
+}
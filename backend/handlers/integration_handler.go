@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/utils"
+	"github.com/SteaceP/coderage/webhooks"
+)
+
+// maxInboundPayload is the largest payload accepted by the inbound webhooks
+const maxInboundPayload = 64 << 10
+
+// CreateIntegrationRequest represents the structure for registering an
+// integration
+type CreateIntegrationRequest struct {
+	Name        string `json:"name"`
+	Secret      string `json:"secret,omitempty"` // Generated when empty
+	Moderated   bool   `json:"moderated"`        // Hold the comments for moderation
+	Description string `json:"description,omitempty"`
+}
+
+// ListIntegrations lists the registered integrations.
+func ListIntegrations(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	integrations, err := svc.Integrations.ListIntegrations()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve integrations", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"integrations": integrations,
+	})
+}
+
+// CreateIntegration registers an integration. The response is the only one
+// including the secret its payloads are signed with.
+func CreateIntegration(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req CreateIntegrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	integration := &models.Integration{
+		Name:        req.Name,
+		Secret:      req.Secret,
+		Moderated:   req.Moderated,
+		Description: req.Description,
+	}
+	if err := svc.Integrations.CreateIntegration(integration); err != nil {
+		writeServiceError(w, r, err, "Integration creation failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditIntegrationCreated, integration.ID, map[string]interface{}{
+		"name":      integration.Name,
+		"moderated": integration.Moderated,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Integration created successfully",
+		"integration": integration,
+		"secret":      integration.Secret,
+	})
+}
+
+// GetIntegration retrieves an integration.
+func GetIntegration(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	integrationID, ok := routeID(w, r, types.IDField, "Invalid integration ID")
+	if !ok {
+		return
+	}
+
+	integration, err := svc.Integrations.GetIntegration(integrationID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve integration")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(integration)
+}
+
+// DeleteIntegration removes an integration, whose payloads are rejected from
+// then on.
+func DeleteIntegration(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	integrationID, ok := routeID(w, r, types.IDField, "Invalid integration ID")
+	if !ok {
+		return
+	}
+
+	if err := svc.Integrations.DeleteIntegration(integrationID); err != nil {
+		writeServiceError(w, r, err, "Integration deletion failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditIntegrationDeleted, integrationID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Integration deleted successfully",
+	})
+}
+
+// ReceiveIntegrationComment creates a comment sent by an external service to
+// the inbound webhook of its integration. Payloads are signed like those of
+// the outgoing webhooks: the X-Coderage-Signature-256 header holds the hex
+// encoded HMAC-SHA256 of the raw body keyed with the secret, prefixed with
+// "sha256=". Fields the payload schema doesn't define are rejected.
+//
+// A comment sent again is answered with 200 instead of 201, and isn't
+// created twice.
+func ReceiveIntegrationComment(w http.ResponseWriter, r *http.Request) {
+	integrationID, ok := routeID(w, r, types.IDField, "Invalid integration ID")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInboundPayload))
+	if err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Authenticate the payload before reading it
+	integration, err := svc.Integrations.Verify(integrationID, r.Header.Get(webhooks.SignatureHeader), body)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to verify the payload")
+		return
+	}
+
+	var payload services.InboundComment
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&payload); err != nil {
+		apperrors.Error(w, r, "Invalid payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Check if commenting is enabled
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+	if !settings.CommentsEnabled {
+		apperrors.Error(w, r, "Commenting is disabled", http.StatusForbidden)
+		return
+	}
+
+	if err := svc.Plans.Check(services.QuotaComments, 1); err != nil {
+		writeServiceError(w, r, err, "Failed to check plan limits")
+		return
+	}
+	comment, created, err := svc.Integrations.CreateComment(integration, payload)
+	if err != nil {
+		writeServiceError(w, r, err, "Comment creation failed")
+		return
+	}
+
+	// Prepare response
+	result := map[string]interface{}{
+		"id":          utils.UintToString(comment.ID),
+		"external_id": payload.ExternalID,
+		"post_id":     utils.UintToString(comment.PostID),
+		"user_id":     utils.UintToString(comment.UserID),
+		"status":      comment.Status,
+	}
+	if comment.ParentID != nil {
+		result["parent_id"] = utils.UintToString(*comment.ParentID)
+	}
+	status, message := http.StatusOK, "Comment already received"
+	if created {
+		status, message = http.StatusCreated, "Comment created successfully"
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"comment": result,
+	})
+}
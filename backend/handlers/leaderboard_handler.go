@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/services"
+)
+
+// GetLeaderboards returns the top authors and commenters over a window, one
+// of week (the default), month, year or all
+func GetLeaderboards(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = services.LeaderboardWeek
+	}
+
+	leaderboard, err := svc.Leaderboards.GetLeaderboard(window)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve leaderboards")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"leaderboard": leaderboard,
+	})
+}
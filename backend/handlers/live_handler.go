@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/presence"
+	"github.com/SteaceP/coderage/types"
+)
+
+// LiveReaders is the data of the readers events of a post stream.
+type LiveReaders struct {
+	PostID  uint `json:"post_id"`
+	Readers int  `json:"readers"` // Clients streaming the post, this one included
+}
+
+// StreamPost streams the live events of a post as Server-Sent Events, for as
+// long as the client reads the post. A readers event carries the number of
+// readers of the post when the stream starts and whenever it changes, checked
+// every heartbeat, and a comment keeps the connection alive otherwise.
+func StreamPost(hub *presence.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get services from context
+		svc, ok := servicesFromContext(r)
+		if !ok {
+			servicesUnavailable(w, r)
+			return
+		}
+
+		postID, ok := routeID(w, r, types.IDField, "Invalid post ID")
+		if !ok {
+			return
+		}
+		viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+		post, err := svc.Posts.FindPost(postID, viewerID)
+		if err != nil {
+			writeServiceError(w, r, err, "Failed to retrieve post")
+			return
+		}
+
+		// Lift the write timeout of the server for the stream
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			apperrors.Error(w, r, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		reader, err := hub.Join(r.Context(), post.ID)
+		if errors.Is(err, presence.ErrTooManyStreams) {
+			apperrors.WriteCode(w, r, http.StatusServiceUnavailable, "too_many_streams", "Too many live streams, please try again later")
+			return
+		}
+		if err != nil {
+			apperrors.Error(w, r, "Failed to join the post", http.StatusInternalServerError)
+			return
+		}
+		defer hub.Leave(r.Context(), post.ID, reader)
+
+		// Send events until the client or the server goes away
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		ticker := time.NewTicker(config.Get().Presence.Heartbeat)
+		defer ticker.Stop()
+		sent := -1
+		for {
+			if readers, ok := hub.Heartbeat(r.Context(), post.ID, reader); ok && readers != sent {
+				data, _ := json.Marshal(LiveReaders{PostID: post.ID, Readers: readers})
+				fmt.Fprintf(w, "event: readers\ndata: %s\n\n", data)
+				sent = readers
+			} else {
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-hub.Closing():
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+}
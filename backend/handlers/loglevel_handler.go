@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/services"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogLevelRequest changes the minimum level of the entries logged.
+type LogLevelRequest struct {
+	Level string `json:"level"` // debug, info, warn or error
+}
+
+// LogLevelResponse holds the minimum level of the entries logged.
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
+
+// GetLogLevel returns the minimum level of the entries logged.
+func GetLogLevel(level zap.AtomicLevel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LogLevelResponse{Level: level.String()})
+	}
+}
+
+// SetLogLevel changes the minimum level of the entries logged, until the
+// server restarts.
+func SetLogLevel(level zap.AtomicLevel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get services from context
+		svc, ok := servicesFromContext(r)
+		if !ok {
+			servicesUnavailable(w, r)
+			return
+		}
+
+		var req LogLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		next, err := zapcore.ParseLevel(req.Level)
+		if err != nil || next > zapcore.ErrorLevel {
+			apperrors.Error(w, r, "Level must be debug, info, warn or error", http.StatusBadRequest)
+			return
+		}
+
+		previous := level.Level()
+		level.SetLevel(next)
+		recordAdminAction(r, svc, services.AuditLogLevelChanged, 0, map[string]interface{}{
+			"from": previous.String(),
+			"to":   next.String(),
+		})
+
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LogLevelResponse{Level: level.String()})
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/types"
+)
+
+// ListNotifications lists the notifications of the current user, most recent
+// activity first. Only unread notifications are listed when "unread" is true.
+func ListNotifications(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Parse pagination parameters
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	unreadOnly, _ := strconv.ParseBool(r.URL.Query().Get("unread"))
+
+	notifications, totalCount, err := svc.Notifications.ListNotifications(userID, unreadOnly, page, limit)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+
+	unreadCount, err := svc.Notifications.CountUnread(userID)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notifications": notifications,
+		"unread_count":  unreadCount,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// MarkNotificationRead marks a notification of the current user as read.
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	notificationID, ok := routeID(w, r, types.IDField, "Invalid notification ID")
+	if !ok {
+		return
+	}
+
+	if err := svc.Notifications.MarkRead(userID, notificationID); err != nil {
+		writeServiceError(w, r, err, "Failed to update notification")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Notification marked as read",
+	})
+}
+
+// MarkAllNotificationsRead marks every notification of the current user as
+// read.
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	if err := svc.Notifications.MarkAllRead(userID); err != nil {
+		apperrors.Error(w, r, "Failed to update notifications", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Notifications marked as read",
+	})
+}
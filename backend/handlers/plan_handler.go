@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+)
+
+// PlanRequest represents the structure for creating or updating a plan.
+// Limits of 0 are unlimited. Omitted fields are left unchanged on updates.
+type PlanRequest struct {
+	Name            *string `json:"name"`
+	MaxPosts        *int64  `json:"max_posts"`
+	MaxComments     *int64  `json:"max_comments"`
+	MaxStorageBytes *int64  `json:"max_storage_bytes"`
+	MaxMembers      *int64  `json:"max_members"`
+}
+
+// fields returns the fields of the plan set in the request.
+func (req PlanRequest) fields() services.PlanUpdate {
+	return services.PlanUpdate{
+		Name:            req.Name,
+		MaxPosts:        req.MaxPosts,
+		MaxComments:     req.MaxComments,
+		MaxStorageBytes: req.MaxStorageBytes,
+		MaxMembers:      req.MaxMembers,
+	}
+}
+
+// ListPlans lists the plans the site can be assigned.
+func ListPlans(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	plans, err := svc.Plans.ListPlans()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve plans", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"plans": plans,
+	})
+}
+
+// CreatePlan creates a plan. The site moves to it once it is set as the
+// plan_id of the site settings.
+func CreatePlan(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req PlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	plan, err := svc.Plans.CreatePlan(req.fields())
+	if err != nil {
+		writeServiceError(w, r, err, "Plan creation failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditPlanCreated, plan.ID, map[string]interface{}{
+		"name": plan.Name,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Plan created successfully",
+		"plan":    plan,
+	})
+}
+
+// UpdatePlan updates a plan. Limits can be lowered under the current usage of
+// the site: nothing is removed, but no more content is allowed.
+func UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	planID, ok := routeID(w, r, types.IDField, "Invalid plan ID")
+	if !ok {
+		return
+	}
+
+	var req PlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	plan, err := svc.Plans.UpdatePlan(planID, req.fields())
+	if err != nil {
+		writeServiceError(w, r, err, "Plan update failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditPlanUpdated, plan.ID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Plan updated successfully",
+		"plan":    plan,
+	})
+}
+
+// DeletePlan removes a plan. The plan of the site can't be removed.
+func DeletePlan(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	planID, ok := routeID(w, r, types.IDField, "Invalid plan ID")
+	if !ok {
+		return
+	}
+
+	if err := svc.Plans.DeletePlan(planID); err != nil {
+		writeServiceError(w, r, err, "Plan deletion failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditPlanDeleted, planID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Plan deleted successfully",
+	})
+}
+
+// GetUsage returns the usage of the site against the limits of its plan.
+func GetUsage(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	usage, err := svc.Plans.Usage()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve usage", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(usage)
+}
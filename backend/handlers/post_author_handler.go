@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/types"
+)
+
+// CreditAuthorRequest is the role of a user credited as an author of a post.
+type CreditAuthorRequest struct {
+	Role string `json:"role"` // author or contributor
+}
+
+// CreditPostAuthor credits a user as an author of a post of the
+// authenticated user, or changes their role.
+func CreditPostAuthor(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	editorID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	postID, ok := routeID(w, r, types.IDField, "Invalid post ID")
+	if !ok {
+		return
+	}
+	userID, ok := routeID(w, r, "userId", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	var req CreditAuthorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	post, created, err := svc.Posts.CreditAuthor(postID, userID, req.Role, editorID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to credit author")
+		return
+	}
+
+	status := http.StatusOK
+	message := "Author updated successfully"
+	if created {
+		status = http.StatusCreated
+		message = "Author credited successfully"
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"authors": post.Authors,
+	})
+}
+
+// UncreditPostAuthor stops crediting a user as an author of a post.
+func UncreditPostAuthor(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	editorID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	postID, ok := routeID(w, r, types.IDField, "Invalid post ID")
+	if !ok {
+		return
+	}
+	userID, ok := routeID(w, r, "userId", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	post, err := svc.Posts.UncreditAuthor(postID, userID, editorID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to uncredit author")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Author uncredited successfully",
+		"authors": post.Authors,
+	})
+}
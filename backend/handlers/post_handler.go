@@ -2,58 +2,62 @@ package handlers
 
 import (
 	"encoding/json"
-	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/licenses"
+	"github.com/SteaceP/coderage/markdown"
 	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/storage"
 	"github.com/SteaceP/coderage/types"
 	"github.com/SteaceP/coderage/utils"
 
 	"github.com/gorilla/mux"
-	"gorm.io/gorm"
 )
 
 type CreatePostRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	Sensitive *bool    `json:"sensitive"`
+	License   *string  `json:"license"` // License identifier, empty to use the site default
+	Locale    *string  `json:"locale"`  // Language tag, empty for the site locale
+	// Category the post is filed under, 0 for none
+	CategoryID *uint `json:"category_id"`
+	// Members see the post right away, others once the window has passed
+	EarlyAccess      *bool `json:"early_access"`
+	EarlyAccessHours int   `json:"early_access_hours,omitempty"` // Window length, the site default when zero
+	// Checked by the linter when the post is published
+	Excerpt         *string `json:"excerpt"`
+	FeaturedImage   *string `json:"featured_image"`
+	MetaTitle       *string `json:"meta_title"`
+	MetaDescription *string `json:"meta_description"`
 }
 
 func CreatePost(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
 	userID, ok := r.Context().Value(types.KeyUserID).(uint)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-	// Get database from context
-	db, ok := r.Context().Value(types.KeyDB).(*gorm.DB)
-	if !ok || db == nil {
-		http.Error(w, "Internal Server Error (Database unavailable)", http.StatusInternalServerError)
-		return
-	}
-	// Check user role
-	var user models.User
-	if err := db.First(&user, userID).Error; err != nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
+		apperrors.Error(w, r, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Check if user is an admin
-	if user.Role != types.RoleAdmin {
-		http.Error(w, "Forbidden: Only admins can create posts", http.StatusForbidden)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
 		return
 	}
 
 	var req CreatePostRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate request
-	if req.Title == "" || req.Content == "" {
-		http.Error(w, "Title and content are required", http.StatusBadRequest)
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
@@ -62,20 +66,70 @@ func CreatePost(w http.ResponseWriter, r *http.Request) {
 		Title:   req.Title,
 		Content: req.Content,
 		UserID:  userID,
+		Tags:    tagsFromNames(req.Tags),
+	}
+	if req.Sensitive != nil {
+		post.Sensitive = *req.Sensitive
+	}
+	if req.License != nil {
+		post.License = *req.License
+	}
+	if req.Locale != nil {
+		post.Locale = *req.Locale
+	}
+	if req.CategoryID != nil && *req.CategoryID != 0 {
+		if err := svc.Categories.CheckCategory(*req.CategoryID); err != nil {
+			writeServiceError(w, r, err, "Post creation failed")
+			return
+		}
+		post.CategoryID = req.CategoryID
+	}
+	if req.Excerpt != nil {
+		post.Excerpt = *req.Excerpt
+	}
+	if req.FeaturedImage != nil {
+		post.FeaturedImage = *req.FeaturedImage
+	}
+	if req.MetaTitle != nil {
+		post.MetaTitle = *req.MetaTitle
+	}
+	if req.MetaDescription != nil {
+		post.MetaDescription = *req.MetaDescription
+	}
+	if req.EarlyAccess != nil && *req.EarlyAccess {
+		post.PublishedAt = time.Now()
+		until, err := services.EarlyAccessUntil(post.PublishedAt, req.EarlyAccessHours)
+		if err != nil {
+			writeServiceError(w, r, err, "Post creation failed")
+			return
+		}
+		post.MembersOnlyUntil = &until
 	}
 
-	if err := db.Create(&post).Error; err != nil {
-		http.Error(w, "Post creation failed", http.StatusInternalServerError)
+	if err := svc.Plans.Check(services.QuotaPosts, 1); err != nil {
+		writeServiceError(w, r, err, "Failed to check plan limits")
+		return
+	}
+	if err := svc.Posts.CreatePost(&post); err != nil {
+		writeServiceError(w, r, err, "Post creation failed")
 		return
 	}
+	svc.Onboarding.Refresh(r.Context(), userID)
 
 	// Prepare response
 	response := map[string]interface{}{
 		"message": "Post created successfully",
 		"post": map[string]interface{}{
-			"id":      post.ID,
-			"title":   post.Title,
-			"content": post.Content,
+			"id":                 post.ID,
+			"title":              post.Title,
+			"slug":               post.Slug,
+			"content":            post.Content,
+			"tags":               post.Tags,
+			"sensitive":          post.Sensitive,
+			"license":            post.License,
+			"locale":             post.Locale,
+			"category_id":        post.CategoryID,
+			"members_only_until": post.MembersOnlyUntil,
 		},
 	}
 
@@ -83,15 +137,15 @@ func CreatePost(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Response encoding failed", http.StatusInternalServerError)
+		apperrors.Error(w, r, "Response encoding failed", http.StatusInternalServerError)
 	}
 }
 
 func ListPosts(w http.ResponseWriter, r *http.Request) {
-	// Get database from context
-	db, ok := r.Context().Value(types.KeyDB).(*gorm.DB)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
 	if !ok {
-		http.Error(w, "Internal Server Error (Database unavailable)", http.StatusInternalServerError)
+		servicesUnavailable(w, r)
 		return
 	}
 
@@ -106,31 +160,53 @@ func ListPosts(w http.ResponseWriter, r *http.Request) {
 		limit = 10
 	}
 
-	offset := (page - 1) * limit
+	// Parse the filters
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	filters, ok := postFilters(w, r, svc, viewerID)
+	if !ok {
+		return
+	}
+	query, _ := filters["search"].(string)
 
-	// Fetch posts with pagination and preload user
-	var posts []models.Post
-	var totalCount int64
-	if err := db.Model(&models.Post{}).Count(&totalCount).Error; err != nil {
-		http.Error(w, "Failed to count posts", http.StatusInternalServerError)
+	// Fetch posts with pagination
+	posts, totalCount, err := svc.Posts.ListPosts(page, limit, filters, viewerID)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve posts", http.StatusInternalServerError)
 		return
 	}
 
-	if err := db.Preload("User").Offset(offset).Limit(limit).Find(&posts).Error; err != nil {
-		http.Error(w, "Failed to retrieve posts", http.StatusInternalServerError)
+	// Record the search for the search analytics, once rather than per page
+	var searchID uint
+	if query != "" && page == 1 {
+		searchID = svc.Search.Record(query, totalCount)
+	}
+
+	// Apply site settings to posts
+	if err := preparePosts(r, svc, posts); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	// Return only the fields asked for
+	selected, err := requestedFields(r).apply(newPostResponses(posts))
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve posts", http.StatusInternalServerError)
 		return
 	}
 
 	// Prepare response
 	response := map[string]interface{}{
-		"posts": posts,
+		"posts": selected,
 		"pagination": map[string]interface{}{
-			"total_posts": totalCount,
+			"total":       totalCount,
 			"page":        page,
 			"limit":       limit,
 			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
 		},
 	}
+	if searchID != 0 {
+		response["search_id"] = searchID
+	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
@@ -138,12 +214,218 @@ func ListPosts(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// postFilters returns the filters of the posts listed by ListPosts, set by
+// the query parameters of the request. It writes the error response and
+// returns false when a filter is invalid.
+//
+// Only published posts are listed, unless an admin, or an author listing
+// their own posts, asks for another status.
+func postFilters(w http.ResponseWriter, r *http.Request, svc *services.Services, viewerID uint) (map[string]interface{}, bool) {
+	params := r.URL.Query()
+	filters := map[string]interface{}{}
+
+	// Dates are in the time zone of the site
+	loc := time.UTC
+	if params.Get("month") != "" || params.Get("published_after") != "" || params.Get("published_before") != "" {
+		settings, err := svc.Settings.Get()
+		if err != nil {
+			apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+			return nil, false
+		}
+		loc = services.SiteLocation(settings)
+	}
+
+	// Restrict to the posts published during a month of the archive
+	if month := params.Get("month"); month != "" {
+		if params.Get("published_after") != "" || params.Get("published_before") != "" {
+			apperrors.Error(w, r, "Month can't be combined with published_after or published_before", http.StatusBadRequest)
+			return nil, false
+		}
+		since, before, err := services.MonthRange(month, loc)
+		if err != nil {
+			writeServiceError(w, r, err, "Invalid month")
+			return nil, false
+		}
+		filters["published_since"] = since
+		filters["published_before"] = before
+	}
+
+	// Restrict to the posts published within a date range
+	for _, bound := range []struct{ param, filter string }{
+		{"published_after", "published_since"},
+		{"published_before", "published_before"},
+	} {
+		value := params.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := parseDate(value, loc)
+		if err != nil {
+			apperrors.Errorf(w, r, http.StatusBadRequest, "Invalid %s, expected a date (YYYY-MM-DD) or an RFC 3339 time", bound.param)
+			return nil, false
+		}
+		filters[bound.filter] = t
+	}
+	since, hasSince := filters["published_since"].(time.Time)
+	before, hasBefore := filters["published_before"].(time.Time)
+	if hasSince && hasBefore && !since.Before(before) {
+		apperrors.Error(w, r, "The published_after date must be before published_before", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// Restrict to the posts filed under a category or its subcategories
+	if slug := params.Get("category"); slug != "" {
+		categoryIDs, err := svc.Categories.SubtreeIDs(slug)
+		if err != nil {
+			writeServiceError(w, r, err, "Failed to retrieve category")
+			return nil, false
+		}
+		filters["category_ids"] = categoryIDs
+	}
+
+	// Restrict to the posts whose title or content contains the query
+	if query := strings.TrimSpace(params.Get("q")); query != "" {
+		filters["search"] = query
+	}
+
+	// Restrict to the posts of an author, by ID or username
+	var authorID uint
+	switch userID, username := params.Get("user_id"), params.Get("username"); {
+	case userID != "" && username != "":
+		apperrors.Error(w, r, "Set either user_id or username", http.StatusBadRequest)
+		return nil, false
+	case userID != "":
+		id, err := strconv.ParseUint(userID, 10, 64)
+		if err != nil || id == 0 {
+			apperrors.Error(w, r, "Invalid user ID", http.StatusBadRequest)
+			return nil, false
+		}
+		authorID = uint(id)
+	case username != "":
+		user, err := svc.Users.GetPublicProfile(username)
+		if err != nil {
+			writeServiceError(w, r, err, "Failed to retrieve user")
+			return nil, false
+		}
+		authorID = user.ID
+	}
+	if authorID != 0 {
+		filters["user_id"] = authorID
+	}
+
+	// Restrict to the posts of any, or all, of the tags
+	if param := params.Get("tags"); param != "" {
+		var tags []string
+		seen := map[string]bool{}
+		for _, tag := range strings.Split(param, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag != "" && !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) > 20 {
+			apperrors.Error(w, r, "At most 20 tags can be given", http.StatusBadRequest)
+			return nil, false
+		}
+		filters["tags"] = tags
+	}
+	switch params.Get("tags_match") {
+	case "", "any":
+	case "all":
+		filters["all_tags"] = true
+	default:
+		apperrors.Error(w, r, "The tags_match parameter must be any or all", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// Restrict to the posts viewed at least a number of times
+	if param := params.Get("min_views"); param != "" {
+		minViews, err := strconv.Atoi(param)
+		if err != nil || minViews < 0 {
+			apperrors.Error(w, r, "The min_views parameter must be a positive number", http.StatusBadRequest)
+			return nil, false
+		}
+		filters["min_views"] = minViews
+	}
+
+	// Other statuses than published are for admins, and authors listing
+	// their own posts
+	status := params.Get("status")
+	switch status {
+	case "":
+		status = "published"
+	case "published", "draft", "archived", "all":
+	default:
+		apperrors.Error(w, r, "Status must be published, draft, archived or all", http.StatusBadRequest)
+		return nil, false
+	}
+	if status != "published" && (viewerID == 0 || authorID != viewerID) {
+		if viewerID == 0 {
+			apperrors.Error(w, r, "Authentication required to list posts of this status", http.StatusUnauthorized)
+			return nil, false
+		}
+		if err := svc.Users.RequireAdmin(viewerID); err != nil {
+			writeServiceError(w, r, err, "Failed to retrieve user")
+			return nil, false
+		}
+	}
+	if status != "all" {
+		filters["status"] = status
+	}
+	return filters, true
+}
+
+// parseDate parses a date, at midnight in the given location, or an RFC
+// 3339 time.
+func parseDate(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// GetPostArchive lists the months posts were published in, in the time zone
+// of the site, with the number of posts of each. The posts of a month are
+// listed by ListPosts with its month parameter.
+func GetPostArchive(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	loc := services.SiteLocation(settings)
+	months, err := svc.Posts.Archive(loc, viewerID)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve archive", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"timezone": loc.String(),
+		"months":   months,
+	})
+}
+
 // GetPost retrieves a single post by ID, including the user and comments.
+// The fields query parameter restricts the fields returned.
 func GetPost(w http.ResponseWriter, r *http.Request) {
-	// Get database from context
-	db := r.Context().Value(types.KeyDB).(*gorm.DB)
-	if db == nil {
-		http.Error(w, "Internal Server Error (Database unavailable)", http.StatusInternalServerError)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
 		return
 	}
 
@@ -151,25 +433,64 @@ func GetPost(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID, err := strconv.ParseUint(vars[types.IDField], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		apperrors.Error(w, r, "Invalid post ID", http.StatusBadRequest)
 		return
 	}
 
-	// Fetch post with user
-	var post models.Post
-	if err := db.Preload("User").Preload("Comments").First(&post, postID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			http.Error(w, "Post not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Failed to retrieve post", http.StatusInternalServerError)
-		}
+	// Fetch post
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	post, err := svc.Posts.GetPost(uint(postID), viewerID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve post")
+		return
+	}
+
+	// Count the view, once per visitor within the deduplication window
+	svc.Views.Record(post.ID, visitor(r, viewerID), r.UserAgent())
+
+	// Serve the translation to the language asked for, or preferred by the reader
+	locale, err := svc.Translations.Localize(post, r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"))
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve translations", http.StatusInternalServerError)
+		return
+	}
+	post.Locale = locale
+
+	// Link to the posts before and after this one in its series
+	if post.Series, err = svc.Series.Navigate(post, viewerID); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve series", http.StatusInternalServerError)
+		return
+	}
+
+	// Apply site settings to post
+	prepared := []models.Post{*post}
+	if err := preparePosts(r, svc, prepared); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	// Render the content, or the excerpt standing for gated content
+	rendered, err := markdown.Render(prepared[0].Content)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to render post", http.StatusInternalServerError)
+		return
+	}
+	prepared[0].ContentMarkdown = prepared[0].Content
+	prepared[0].ContentHTML = rendered
+
+	// Return only the fields asked for
+	selected, err := requestedFields(r).apply(newPostResponse(&prepared[0]))
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve post", http.StatusInternalServerError)
 		return
 	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", locale)
+	w.Header().Add("Vary", "Accept-Language")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(post)
+	json.NewEncoder(w).Encode(selected)
 }
 
 func UpdatePost(w http.ResponseWriter, r *http.Request) {
@@ -180,57 +501,63 @@ func UpdatePost(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID, err := strconv.ParseUint(vars[types.IDField], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid post ID", http.StatusBadRequest)
-		return
-	}
-
-	// Get database from context
-	db := r.Context().Value(types.KeyDB).(*gorm.DB)
-	if db == nil {
-		http.Error(w, "Internal Server Error (Database unavailable)", http.StatusInternalServerError)
+		apperrors.Error(w, r, "Invalid post ID", http.StatusBadRequest)
 		return
 	}
 
-	// Find existing post
-	var post models.Post
-	if err := db.First(&post, postID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			http.Error(w, "Post not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Failed to retrieve post", http.StatusInternalServerError)
-		}
-		return
-	}
-
-	// Check if the user owns the post
-	if post.UserID != userID {
-		http.Error(w, "Unauthorized to update this post", http.StatusForbidden)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
 		return
 	}
 
 	// Parse update request
 	var req CreatePostRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Update post
-	post.Title = req.Title
-	post.Content = req.Content
-	if err := db.Save(&post).Error; err != nil {
-		http.Error(w, "Post update failed", http.StatusInternalServerError)
+	// Update post, keeping the fields that aren't provided
+	update := services.PostUpdate{
+		Excerpt:          req.Excerpt,
+		FeaturedImage:    req.FeaturedImage,
+		MetaTitle:        req.MetaTitle,
+		MetaDescription:  req.MetaDescription,
+		Sensitive:        req.Sensitive,
+		License:          req.License,
+		Locale:           req.Locale,
+		CategoryID:       req.CategoryID,
+		EarlyAccess:      req.EarlyAccess,
+		EarlyAccessHours: req.EarlyAccessHours,
+	}
+	if req.Title != "" {
+		update.Title = &req.Title
+	}
+	if req.Content != "" {
+		update.Content = &req.Content
+	}
+	if req.Tags != nil {
+		update.Tags = tagsFromNames(req.Tags)
+	}
+	if req.CategoryID != nil && *req.CategoryID != 0 {
+		if err := svc.Categories.CheckCategory(*req.CategoryID); err != nil {
+			writeServiceError(w, r, err, "Post update failed")
+			return
+		}
+	}
+
+	post, err := svc.Posts.UpdatePost(uint(postID), userID, update)
+	if err != nil {
+		writeServiceError(w, r, err, "Post update failed")
 		return
 	}
 
 	// Prepare response
 	response := map[string]interface{}{
 		"message": "Post updated successfully",
-		"post": map[string]string{
-			"id":      utils.UintToString(post.ID),
-			"title":   post.Title,
-			"content": post.Content,
-		},
+		"post":    writtenPost(post),
 	}
 
 	// Send response
@@ -239,6 +566,66 @@ func UpdatePost(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// PublishPost publishes a draft of the authenticated user, after running the
+// checks of the "posts.lint" settings. Advisory issues are listed in the
+// response, and blocking ones refuse the publication with a 422 listing
+// every issue found.
+func PublishPost(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	postID, ok := routeID(w, r, types.IDField, "Invalid post ID")
+	if !ok {
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	post, issues, err := svc.Posts.PublishPost(postID, userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Post publication failed")
+		return
+	}
+	if issues == nil {
+		issues = []services.LintIssue{}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Post published successfully",
+		"post":    writtenPost(post),
+		"lint":    issues,
+	})
+}
+
+// writtenPost returns the fields of a post returned once it was written.
+func writtenPost(post *models.Post) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                 utils.UintToString(post.ID),
+		"title":              post.Title,
+		"slug":               post.Slug,
+		"content":            post.Content,
+		"excerpt":            post.Excerpt,
+		"featured_image":     post.FeaturedImage,
+		"meta_title":         post.MetaTitle,
+		"meta_description":   post.MetaDescription,
+		"status":             post.Status,
+		"tags":               post.Tags,
+		"sensitive":          post.Sensitive,
+		"license":            post.License,
+		"locale":             post.Locale,
+		"category_id":        post.CategoryID,
+		"members_only_until": post.MembersOnlyUntil,
+	}
+}
+
 func DeletePost(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
 	userID := r.Context().Value(types.KeyUserID).(uint)
@@ -247,44 +634,247 @@ func DeletePost(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	postID, err := strconv.ParseUint(vars[types.IDField], 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		apperrors.Error(w, r, "Invalid post ID", http.StatusBadRequest)
 		return
 	}
 
-	// Get database from context
-	db, ok := r.Context().Value(types.KeyDB).(*gorm.DB)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
 	if !ok {
-		http.Error(w, "Internal Server Error (Database unavailable)", http.StatusInternalServerError)
+		servicesUnavailable(w, r)
 		return
 	}
 
-	// Find existing post
-	var post models.Post
-	if err := db.First(&post, postID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			http.Error(w, "Post not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Failed to retrieve post", http.StatusInternalServerError)
-		}
+	// Delete post
+	if err := svc.Posts.DeletePost(uint(postID), userID); err != nil {
+		writeServiceError(w, r, err, "Post deletion failed")
 		return
 	}
+	recordAction(r, svc, userID, services.AuditPostDeleted, uint(postID), nil)
 
-	// Check if the user owns the post
-	if post.UserID != userID {
-		http.Error(w, "Unauthorized to delete this post", http.StatusForbidden)
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Post deleted successfully",
+	})
+}
+
+// BulkPostsRequest is a change applied to many posts at once.
+type BulkPostsRequest struct {
+	PostIDs    []uint   `json:"post_ids"`
+	Status     string   `json:"status"`
+	AddTags    []string `json:"add_tags"`
+	RemoveTags []string `json:"remove_tags"`
+	Delete     bool     `json:"delete"`
+}
+
+// BulkUpdatePosts changes the status or the tags of many posts, or deletes
+// them, in a single transaction. The result of every post is returned.
+func BulkUpdatePosts(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
 		return
 	}
 
-	// Delete post
-	if err := db.Delete(&post).Error; err != nil {
-		http.Error(w, "Post deletion failed", http.StatusInternalServerError)
+	var req BulkPostsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Apply the change
+	results, err := svc.Posts.BulkUpdate(services.BulkPostChange{
+		PostIDs:    req.PostIDs,
+		Status:     req.Status,
+		AddTags:    req.AddTags,
+		RemoveTags: req.RemoveTags,
+		Delete:     req.Delete,
+	})
+	if err != nil {
+		writeServiceError(w, r, err, "Bulk update failed")
 		return
 	}
 
+	changed := make([]uint, 0, len(results))
+	for _, result := range results {
+		if result.Status != services.BulkNotFound {
+			changed = append(changed, result.PostID)
+		}
+	}
+	if len(changed) > 0 {
+		recordAdminAction(r, svc, services.AuditPostsBulk, 0, map[string]interface{}{
+			"post_ids":    changed,
+			"status":      req.Status,
+			"add_tags":    req.AddTags,
+			"remove_tags": req.RemoveTags,
+			"delete":      req.Delete,
+		})
+	}
+
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Post deleted successfully",
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
 	})
 }
+
+// tagsFromNames builds unsaved tags from their names. The repository resolves
+// them to existing tags or creates them on save.
+func tagsFromNames(names []string) []models.Tag {
+	tags := make([]models.Tag, len(names))
+	for i, name := range names {
+		tags[i] = models.Tag{Name: name}
+	}
+	return tags
+}
+
+// preparePosts applies the site settings to posts before they are returned:
+// sensitive content is gated, and the effective license is resolved. The
+// variants of their featured images are listed too.
+func preparePosts(r *http.Request, svc *services.Services, posts []models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		return err
+	}
+
+	gateSensitivePosts(r, svc, settings, posts)
+	images := make([]string, len(posts))
+	for i := range posts {
+		license := licenses.Resolve(posts[i].License, settings.DefaultLicense)
+		posts[i].LicenseInfo = &license
+		images[i] = posts[i].FeaturedImage
+	}
+
+	sets, err := svc.Images.ImageSets(images)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].FeaturedImageSet = sets[posts[i].FeaturedImage]
+	}
+	return nil
+}
+
+// GetPostMeta returns the machine-readable metadata of a post, such as its
+// canonical URL, Open Graph properties, and license, for syndication partners
+// and crawlers.
+func GetPostMeta(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Get post ID from URL
+	vars := mux.Vars(r)
+	postID, err := strconv.ParseUint(vars[types.IDField], 10, 64)
+	if err != nil {
+		apperrors.Error(w, r, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	// Fetch post with user and tags
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	found, err := svc.Posts.FindPost(uint(postID), viewerID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve post")
+		return
+	}
+
+	prepared := []models.Post{*found}
+	if err := preparePosts(r, svc, prepared); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+	post := prepared[0]
+
+	title := post.MetaTitle
+	if title == "" {
+		title = post.Title
+	}
+	description := post.MetaDescription
+	if description == "" {
+		description = post.Excerpt
+	}
+	tags := make([]string, len(post.Tags))
+	for i, tag := range post.Tags {
+		tags[i] = tag.Name
+	}
+
+	// Prepare response
+	response := map[string]interface{}{
+		"title":         title,
+		"description":   description,
+		"canonical_url": postURL(post),
+		"author":        post.User.Username,
+		"published_at":  post.PublishedAt,
+		"modified_at":   post.UpdatedAt,
+		"tags":          tags,
+		"image":         post.FeaturedImage,
+		"license":       post.LicenseInfo,
+		"open_graph": map[string]string{
+			"og:type":        "article",
+			"og:title":       title,
+			"og:description": description,
+			"og:url":         postURL(post),
+			"og:image":       post.FeaturedImage,
+		},
+	}
+	if config.Get().Embeds.OEmbed {
+		// For the oEmbed discovery link of the post page
+		response["oembed_url"] = strings.TrimRight(config.Get().Server.BaseURL, "/") + "/oembed?url=" + url.QueryEscape(postURL(post))
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetHighlightCSS serves the stylesheet of the code blocks highlighted in
+// the rendered content of posts. Requested with its current version in v, as
+// linked by the highlight_css_url setting, it is cached for good.
+func GetHighlightCSS(w http.ResponseWriter, r *http.Request) {
+	css, err := markdown.CSS()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to generate stylesheet", http.StatusInternalServerError)
+		return
+	}
+	cacheControl := "public, max-age=3600"
+	if v := r.URL.Query().Get("v"); v != "" && v == assetVersion(css) {
+		cacheControl = storage.CacheControl
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", cacheControl)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(css))
+}
+
+// highlightCSSURL returns the URL of the stylesheet of the highlighted code
+// blocks, versioned by the hash of its content so it changes with the style.
+func highlightCSSURL() string {
+	cssURL := strings.TrimRight(config.Get().Server.BaseURL, "/") + "/highlight.css"
+	css, err := markdown.CSS()
+	if err != nil {
+		return cssURL
+	}
+	return cssURL + "?v=" + assetVersion(css)
+}
+
+// assetVersion returns the version of an asset, the start of the hash of its
+// content.
+func assetVersion(content string) string {
+	hash, _ := storage.ContentHash(strings.NewReader(content))
+	return hash[:storage.HashLength]
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/SteaceP/coderage/licenses"
+	"github.com/SteaceP/coderage/models"
+)
+
+// PostResponse is a post as returned by GetPost and ListPosts. Its author
+// and the authors of its comments are reduced to their public profile.
+// The ID and timestamps keep the names they had when posts were returned
+// as stored.
+type PostResponse struct {
+	ID               uint                     `json:"ID"`
+	CreatedAt        time.Time                `json:"CreatedAt"`
+	UpdatedAt        time.Time                `json:"UpdatedAt"`
+	Title            string                   `json:"title"`
+	Slug             string                   `json:"slug"`
+	Content          string                   `json:"content"`
+	Excerpt          string                   `json:"excerpt"`
+	UserID           uint                     `json:"user_id"`
+	User             AuthorResponse           `json:"user"`
+	Comments         []CommentResponse        `json:"comments,omitempty"`
+	PublishedAt      time.Time                `json:"published_at"`
+	Status           string                   `json:"status"`
+	Tags             []models.Tag             `json:"tags"`
+	CategoryID       *uint                    `json:"category_id,omitempty"`
+	Category         *models.Category         `json:"category,omitempty"`
+	ViewCount        int                      `json:"view_count"`
+	LikeCount        int                      `json:"like_count"`
+	CommentCount     int                      `json:"comment_count"`
+	FeaturedImage    string                   `json:"featured_image,omitempty"`
+	FeaturedImageSet *models.ImageSet         `json:"featured_image_set,omitempty"`
+	MetaTitle        string                   `json:"meta_title,omitempty"`
+	MetaDescription  string                   `json:"meta_description,omitempty"`
+	Sensitive        bool                     `json:"sensitive"`
+	ContentGated     bool                     `json:"content_gated,omitempty"`
+	MembersOnlyUntil *time.Time               `json:"members_only_until,omitempty"`
+	Locale           string                   `json:"locale"`
+	Translations     []string                 `json:"translations,omitempty"`
+	Series           *models.SeriesNavigation `json:"series,omitempty"`
+	License          *licenses.License        `json:"license"`
+	ContentMarkdown  string                   `json:"content_markdown,omitempty"`
+	ContentHTML      string                   `json:"content_html,omitempty"`
+	Authors          []PostAuthorResponse     `json:"authors,omitempty"`
+}
+
+// AuthorResponse is the public profile of the author of a post or comment,
+// without their email address, role or account details.
+type AuthorResponse struct {
+	ID              uint   `json:"ID"`
+	Username        string `json:"username"`
+	FirstName       string `json:"first_name,omitempty"`
+	LastName        string `json:"last_name,omitempty"`
+	Bio             string `json:"bio,omitempty"`
+	ProfilePicture  string `json:"profile_picture,omitempty"`
+	TwitterHandle   string `json:"twitter_handle,omitempty"`
+	LinkedInProfile string `json:"linkedin_profile,omitempty"`
+	PersonalWebsite string `json:"personal_website,omitempty"`
+}
+
+// PostAuthorResponse is a user credited as an author of a post.
+type PostAuthorResponse struct {
+	UserID    uint           `json:"user_id"`
+	User      AuthorResponse `json:"user"`
+	Role      string         `json:"role"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// CommentResponse is a comment of a post, returned along with the post.
+type CommentResponse struct {
+	ID               uint              `json:"ID"`
+	CreatedAt        time.Time         `json:"CreatedAt"`
+	UpdatedAt        time.Time         `json:"UpdatedAt"`
+	Content          string            `json:"content"`
+	UserID           uint              `json:"user_id"`
+	User             *AuthorResponse   `json:"user,omitempty"` // Set when the author was loaded
+	PostID           uint              `json:"post_id"`
+	ParentID         *uint             `json:"parent_id,omitempty"`
+	Replies          []CommentResponse `json:"replies,omitempty"`
+	Status           string            `json:"status"`
+	LikeCount        int               `json:"like_count"`
+	Liked            bool              `json:"liked"`
+	ModeratedAt      *time.Time        `json:"moderated_at,omitempty"`
+	ModerationReason string            `json:"moderation_reason,omitempty"`
+	Anonymized       bool              `json:"anonymized"`
+}
+
+// newPostResponses returns the responses of posts.
+func newPostResponses(posts []models.Post) []PostResponse {
+	responses := make([]PostResponse, len(posts))
+	for i := range posts {
+		responses[i] = newPostResponse(&posts[i])
+	}
+	return responses
+}
+
+// newPostResponse returns the response of a post.
+func newPostResponse(post *models.Post) PostResponse {
+	response := PostResponse{
+		ID:               post.ID,
+		CreatedAt:        post.CreatedAt,
+		UpdatedAt:        post.UpdatedAt,
+		Title:            post.Title,
+		Slug:             post.Slug,
+		Content:          post.Content,
+		Excerpt:          post.Excerpt,
+		UserID:           post.UserID,
+		User:             newAuthorResponse(&post.User),
+		PublishedAt:      post.PublishedAt,
+		Status:           post.Status,
+		Tags:             post.Tags,
+		CategoryID:       post.CategoryID,
+		Category:         post.Category,
+		ViewCount:        post.ViewCount,
+		LikeCount:        post.LikeCount,
+		CommentCount:     post.CommentCount,
+		FeaturedImage:    post.FeaturedImage,
+		FeaturedImageSet: post.FeaturedImageSet,
+		MetaTitle:        post.MetaTitle,
+		MetaDescription:  post.MetaDescription,
+		Sensitive:        post.Sensitive,
+		ContentGated:     post.ContentGated,
+		MembersOnlyUntil: post.MembersOnlyUntil,
+		Locale:           post.Locale,
+		Translations:     post.Translations,
+		Series:           post.Series,
+		License:          post.LicenseInfo,
+		ContentMarkdown:  post.ContentMarkdown,
+		ContentHTML:      post.ContentHTML,
+		Comments:         newCommentResponses(post.Comments),
+	}
+	if response.Tags == nil {
+		response.Tags = []models.Tag{}
+	}
+	for i := range post.Authors {
+		author := &post.Authors[i]
+		response.Authors = append(response.Authors, PostAuthorResponse{
+			UserID:    author.UserID,
+			User:      newAuthorResponse(&author.User),
+			Role:      author.Role,
+			CreatedAt: author.CreatedAt,
+		})
+	}
+	return response
+}
+
+// newAuthorResponse returns the public profile of a user.
+func newAuthorResponse(user *models.User) AuthorResponse {
+	return AuthorResponse{
+		ID:              user.ID,
+		Username:        user.Username,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		Bio:             user.Bio,
+		ProfilePicture:  user.ProfilePicture,
+		TwitterHandle:   user.TwitterHandle,
+		LinkedInProfile: user.LinkedInProfile,
+		PersonalWebsite: user.PersonalWebsite,
+	}
+}
+
+// newCommentResponses returns the responses of comments and of their
+// replies.
+func newCommentResponses(comments []models.Comment) []CommentResponse {
+	if len(comments) == 0 {
+		return nil
+	}
+	responses := make([]CommentResponse, len(comments))
+	for i := range comments {
+		comment := &comments[i]
+		responses[i] = CommentResponse{
+			ID:               comment.ID,
+			CreatedAt:        comment.CreatedAt,
+			UpdatedAt:        comment.UpdatedAt,
+			Content:          comment.Content,
+			UserID:           comment.UserID,
+			PostID:           comment.PostID,
+			ParentID:         comment.ParentID,
+			Replies:          newCommentResponses(comment.Replies),
+			Status:           comment.Status,
+			LikeCount:        comment.LikeCount,
+			Liked:            comment.Liked,
+			ModeratedAt:      comment.ModeratedAt,
+			ModerationReason: comment.ModerationReason,
+			Anonymized:       comment.Anonymized,
+		}
+		if comment.User.ID != 0 {
+			author := newAuthorResponse(&comment.User)
+			responses[i].User = &author
+		}
+	}
+	return responses
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/database"
+	"github.com/SteaceP/coderage/services"
+)
+
+// ReadOnlyRequest turns the read-only switch on or off.
+type ReadOnlyRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"` // Given to the clients turned away, the configured one when empty
+}
+
+// GetReadOnly returns whether the site is read-only, and why.
+func GetReadOnly(readOnly *database.ReadOnly) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(readOnly.State())
+	}
+}
+
+// SetReadOnly turns the read-only switch on or off. Turning it off doesn't
+// end the read-only mode forced by configuration.
+func SetReadOnly(readOnly *database.ReadOnly) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get services from context
+		svc, ok := servicesFromContext(r)
+		if !ok {
+			servicesUnavailable(w, r)
+			return
+		}
+
+		var req ReadOnlyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if len(req.Message) > 255 {
+			apperrors.Error(w, r, "Message must be at most 255 characters", http.StatusBadRequest)
+			return
+		}
+
+		// The switch is stored in the site settings, created on first read
+		if _, err := svc.Settings.Get(); err != nil {
+			apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+			return
+		}
+		if err := readOnly.Set(r.Context(), req.Enabled, req.Message); err != nil {
+			apperrors.Error(w, r, "Failed to update read-only mode", http.StatusInternalServerError)
+			return
+		}
+		recordAdminAction(r, svc, services.AuditReadOnlyChanged, 0, map[string]interface{}{
+			"enabled": req.Enabled,
+			"message": req.Message,
+		})
+
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(readOnly.State())
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/openapi"
+	"github.com/SteaceP/coderage/ratelimit"
+)
+
+// Rate limits applying to a route
+const (
+	RateLimitDefault  = "default"  // The limit of every client
+	RateLimitExempt   = "exempt"   // Health probes and such, never limited
+	RateLimitDisabled = "disabled" // Rate limiting is disabled
+)
+
+// RouteAccess describes who may call a route, and how it is rate limited.
+type RouteAccess struct {
+	openapi.Access
+	RateLimit string `json:"rate_limit"`
+}
+
+// RateLimitPolicy describes the rate limit and its exemptions.
+type RateLimitPolicy struct {
+	Enabled  bool   `json:"enabled"`
+	Requests int    `json:"requests"`
+	Window   string `json:"window"`
+	ratelimit.Summary
+}
+
+// ListRoutes lists the registered routes, with the authentication and roles
+// they require and their rate limit, derived from the router and the rate
+// limit policy, so reviews and the permission editor of the admin UI follow
+// the code.
+func ListRoutes(matrix func() ([]openapi.Access, error), policy *ratelimit.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		access, err := matrix()
+		if err != nil {
+			apperrors.Error(w, r, "Failed to list routes", http.StatusInternalServerError)
+			return
+		}
+
+		cfg := config.Get().RateLimit
+		routes := make([]RouteAccess, 0, len(access))
+		for _, a := range access {
+			route := RouteAccess{Access: a, RateLimit: RateLimitDefault}
+			if _, exempt := policy.ForPath(a.Path); exempt {
+				route.RateLimit = RateLimitExempt
+			} else if !cfg.Enabled {
+				route.RateLimit = RateLimitDisabled
+			}
+			routes = append(routes, route)
+		}
+
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"routes": routes,
+			"rate_limit": RateLimitPolicy{
+				Enabled:  cfg.Enabled,
+				Requests: cfg.Requests,
+				Window:   cfg.Window.String(),
+				Summary:  policy.Summary(),
+			},
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+)
+
+// SearchClickRequest is the post clicked in the results of a search.
+type SearchClickRequest struct {
+	PostID uint `json:"post_id"`
+}
+
+// RecordSearchClick records the post clicked in the results of a search,
+// identified by the search_id returned by ListPosts. Only the first click of
+// a search is kept.
+func RecordSearchClick(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	searchID, ok := routeID(w, r, "id", "Invalid search ID")
+	if !ok {
+		return
+	}
+	var req SearchClickRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := svc.Search.RecordClick(searchID, req.PostID); err != nil {
+		writeServiceError(w, r, err, "Failed to record click")
+		return
+	}
+
+	// Send response
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSearchReport returns the searches of the last days (30 by default, 365
+// at most), with the most searched queries and the queries finding nothing,
+// up to limit of each (20 by default, 100 at most).
+func GetSearchReport(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days < 1 || days > 365 {
+		days = 30
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	report, err := svc.Search.Report(time.Now().AddDate(0, 0, -days), limit)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve search report", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+)
+
+const (
+	// sensitiveAckHeader lets clients acknowledge sensitive content for a request
+	sensitiveAckHeader = "X-Sensitive-Ack"
+	// sensitiveAckCookie lets clients acknowledge sensitive content for a session
+	sensitiveAckCookie = "sensitive_ack"
+)
+
+// gateSensitivePosts withholds the content of sensitive posts unless the
+// reader acknowledged sensitive content, either through their preference or
+// for the current session. Gated posts only expose their excerpt.
+//
+// Gating is skipped entirely when disabled in the site settings.
+func gateSensitivePosts(r *http.Request, svc *services.Services, settings *models.SiteSettings, posts []models.Post) {
+	hasSensitive := false
+	for _, post := range posts {
+		if post.Sensitive {
+			hasSensitive = true
+			break
+		}
+	}
+	if !hasSensitive || !settings.SensitiveGating || sensitiveAcknowledged(r, svc) {
+		return
+	}
+
+	for i := range posts {
+		if posts[i].Sensitive {
+			posts[i].Content = posts[i].Excerpt
+			posts[i].ContentGated = true
+		}
+	}
+}
+
+// gateSensitiveTranslations withholds the content of the translations of a
+// sensitive post as gateSensitivePosts does for posts. Gated translations
+// only expose their excerpt, or the one of the post when they have none.
+func gateSensitiveTranslations(r *http.Request, svc *services.Services, post *models.Post, translations []models.PostTranslation) error {
+	if !post.Sensitive || len(translations) == 0 {
+		return nil
+	}
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		return err
+	}
+	if !settings.SensitiveGating || sensitiveAcknowledged(r, svc) {
+		return nil
+	}
+
+	for i := range translations {
+		translations[i].Content = translations[i].Excerpt
+		if translations[i].Content == "" {
+			translations[i].Content = post.Excerpt
+		}
+		translations[i].ContentGated = true
+	}
+	return nil
+}
+
+// sensitiveAcknowledged reports whether the reader acknowledged sensitive
+// content for this request, their session, or through their preference.
+func sensitiveAcknowledged(r *http.Request, svc *services.Services) bool {
+	if isTruthy(r.Header.Get(sensitiveAckHeader)) {
+		return true
+	}
+	if cookie, err := r.Cookie(sensitiveAckCookie); err == nil && isTruthy(cookie.Value) {
+		return true
+	}
+
+	userID, ok := r.Context().Value(types.KeyUserID).(uint)
+	if !ok {
+		return false
+	}
+	return svc.Users.ShowsSensitive(userID)
+}
+
+// isTruthy reports whether a header or cookie value means "yes".
+func isTruthy(value string) bool {
+	switch value {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
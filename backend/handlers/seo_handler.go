@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+)
+
+// defaultRobotsRules is used when no crawler rules have been configured
+var defaultRobotsRules = []models.RobotsRule{
+	{UserAgent: "*", Allow: []string{"/"}},
+}
+
+type sitemapURLSet struct {
+	XMLName    xml.Name     `xml:"urlset"`
+	XMLNS      string       `xml:"xmlns,attr"`
+	XHTMLXMLNS string       `xml:"xmlns:xhtml,attr,omitempty"`
+	URLs       []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string             `xml:"loc"`
+	LastMod    string             `xml:"lastmod,omitempty"`
+	Alternates []sitemapAlternate `xml:"xhtml:link"`
+}
+
+// sitemapAlternate links a page to its version in another language
+type sitemapAlternate struct {
+	Rel      string `xml:"rel,attr"`
+	HrefLang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// GetRobots serves robots.txt from the crawler rules of the site settings,
+// referencing the sitemap and the RSS feed.
+func GetRobots(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	rules := settings.RobotsRules
+	if len(rules) == 0 {
+		rules = defaultRobotsRules
+	}
+
+	var b strings.Builder
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "User-agent: %s\n", rule.UserAgent)
+		for _, path := range rule.Allow {
+			fmt.Fprintf(&b, "Allow: %s\n", path)
+		}
+		for _, path := range rule.Disallow {
+			fmt.Fprintf(&b, "Disallow: %s\n", path)
+		}
+		if rule.CrawlDelay > 0 {
+			fmt.Fprintf(&b, "Crawl-delay: %d\n", rule.CrawlDelay)
+		}
+		b.WriteString("\n")
+	}
+
+	baseURL := strings.TrimRight(config.Get().Server.BaseURL, "/")
+	fmt.Fprintf(&b, "Sitemap: %s/sitemap.xml\n", baseURL)
+	fmt.Fprintf(&b, "Sitemap: %s/feed.xml\n", baseURL)
+
+	// Send response
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(b.String()))
+}
+
+// GetSitemap serves the XML sitemap of the published posts and tag pages.
+// Translated posts are listed in each of their languages, every version
+// linking to the others with hreflang annotations.
+func GetSitemap(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	posts, err := svc.Posts.ListPublishedPosts()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve posts", http.StatusInternalServerError)
+		return
+	}
+
+	tags, err := svc.Tags.ListTags()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve tags", http.StatusInternalServerError)
+		return
+	}
+
+	translations, err := svc.Translations.Locales()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve translations", http.StatusInternalServerError)
+		return
+	}
+
+	siteURL := strings.TrimRight(config.Get().Site.URL, "/")
+	sitemap := sitemapURLSet{
+		XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		URLs:  make([]sitemapURL, 0, len(posts)+len(tags)+1),
+	}
+	sitemap.URLs = append(sitemap.URLs, sitemapURL{Loc: siteURL + "/"})
+	for _, post := range posts {
+		lastMod := post.UpdatedAt.Format(time.RFC3339)
+		locales := translations[post.ID]
+		if len(locales) == 0 {
+			sitemap.URLs = append(sitemap.URLs, sitemapURL{Loc: postURL(post), LastMod: lastMod})
+			continue
+		}
+
+		// The post as written is the default for the other languages
+		sitemap.XHTMLXMLNS = "http://www.w3.org/1999/xhtml"
+		alternates := []sitemapAlternate{
+			{Rel: "alternate", HrefLang: services.PostLocale(&post), Href: postURL(post)},
+		}
+		for _, locale := range locales {
+			alternates = append(alternates, sitemapAlternate{Rel: "alternate", HrefLang: locale, Href: translatedPostURL(post, locale)})
+		}
+		alternates = append(alternates, sitemapAlternate{Rel: "alternate", HrefLang: "x-default", Href: postURL(post)})
+		for _, alternate := range alternates[:len(alternates)-1] {
+			sitemap.URLs = append(sitemap.URLs, sitemapURL{Loc: alternate.Href, LastMod: lastMod, Alternates: alternates})
+		}
+	}
+	for _, tag := range tags {
+		if tag.PostCount == 0 {
+			continue
+		}
+		sitemap.URLs = append(sitemap.URLs, sitemapURL{Loc: siteURL + "/tags/" + tag.Slug})
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(sitemap)
+}
+
+// GetSecurityTxt serves /.well-known/security.txt as described in RFC 9116.
+// It is only available once a security contact has been configured.
+func GetSecurityTxt(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+	if settings.SecurityContact == "" {
+		apperrors.NotFound(w, r)
+		return
+	}
+
+	baseURL := strings.TrimRight(config.Get().Server.BaseURL, "/")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Contact: %s\n", settings.SecurityContact)
+	fmt.Fprintf(&b, "Expires: %s\n", time.Now().AddDate(1, 0, 0).UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Canonical: %s/.well-known/security.txt\n", baseURL)
+
+	// Send response
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(b.String()))
+}
+
+// ChangePasswordRedirect redirects password managers to the change password
+// page of the frontend.
+func ChangePasswordRedirect(w http.ResponseWriter, r *http.Request) {
+	target := strings.TrimRight(config.Get().Site.URL, "/") + config.Get().Site.ChangePasswordPath
+	http.Redirect(w, r, target, http.StatusFound)
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/gorilla/mux"
+)
+
+// SeriesRequest represents the structure for creating or updating a series.
+// Omitted fields are left unchanged on updates.
+type SeriesRequest struct {
+	Title       *string `json:"title"`
+	Slug        *string `json:"slug"` // Generated from the title when empty
+	Description *string `json:"description"`
+}
+
+// fields returns the fields of the series set in the request.
+func (req SeriesRequest) fields() services.SeriesUpdate {
+	return services.SeriesUpdate{
+		Title:       req.Title,
+		Slug:        req.Slug,
+		Description: req.Description,
+	}
+}
+
+// AddSeriesPostRequest adds a post to a series.
+type AddSeriesPostRequest struct {
+	PostID   uint `json:"post_id"`
+	Position int  `json:"position,omitempty"` // From 1, last when omitted
+}
+
+// ReorderSeriesRequest lists every post of a series in their new order.
+type ReorderSeriesRequest struct {
+	PostIDs []uint `json:"post_ids"`
+}
+
+// GetSeries retrieves a series by its slug, with its posts in order.
+func GetSeries(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	series, err := svc.Series.GetSeries(mux.Vars(r)["slug"], viewerID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve series")
+		return
+	}
+
+	// Apply site settings to posts
+	if err := preparePosts(r, svc, series.Posts); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(series)
+}
+
+// CreateSeries creates a series of the authenticated user.
+func CreateSeries(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req SeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	series, err := svc.Series.CreateSeries(userID, req.fields())
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to create series")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Series created successfully",
+		"series":  series,
+	})
+}
+
+// UpdateSeries updates a series of the authenticated user.
+func UpdateSeries(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	seriesID, ok := routeID(w, r, types.IDField, "Invalid series ID")
+	if !ok {
+		return
+	}
+
+	var req SeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	series, err := svc.Series.UpdateSeries(seriesID, userID, req.fields())
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to update series")
+		return
+	}
+
+	writeSeries(w, r, svc, series, "Series updated successfully")
+}
+
+// DeleteSeries removes a series of the authenticated user, keeping its
+// posts.
+func DeleteSeries(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	seriesID, ok := routeID(w, r, types.IDField, "Invalid series ID")
+	if !ok {
+		return
+	}
+
+	if err := svc.Series.DeleteSeries(seriesID, userID); err != nil {
+		writeServiceError(w, r, err, "Failed to delete series")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Series deleted successfully",
+	})
+}
+
+// AddSeriesPost adds a post of the authenticated user to a series of
+// theirs.
+func AddSeriesPost(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	seriesID, ok := routeID(w, r, types.IDField, "Invalid series ID")
+	if !ok {
+		return
+	}
+
+	var req AddSeriesPostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	series, err := svc.Series.AddPost(seriesID, req.PostID, req.Position, userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to add post to series")
+		return
+	}
+
+	writeSeries(w, r, svc, series, "Post added to series successfully")
+}
+
+// ReorderSeriesPosts puts the posts of a series of the authenticated user in
+// a new order.
+func ReorderSeriesPosts(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	seriesID, ok := routeID(w, r, types.IDField, "Invalid series ID")
+	if !ok {
+		return
+	}
+
+	var req ReorderSeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	series, err := svc.Series.ReorderPosts(seriesID, req.PostIDs, userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to reorder series")
+		return
+	}
+
+	writeSeries(w, r, svc, series, "Series reordered successfully")
+}
+
+// RemoveSeriesPost takes a post out of a series of the authenticated user.
+func RemoveSeriesPost(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	seriesID, ok := routeID(w, r, types.IDField, "Invalid series ID")
+	if !ok {
+		return
+	}
+	postID, ok := routeID(w, r, "postId", "Invalid post ID")
+	if !ok {
+		return
+	}
+
+	series, err := svc.Series.RemovePost(seriesID, postID, userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to remove post from series")
+		return
+	}
+
+	writeSeries(w, r, svc, series, "Post removed from series successfully")
+}
+
+// writeSeries writes a series changed by its author, with its posts.
+func writeSeries(w http.ResponseWriter, r *http.Request, svc *services.Services, series *models.Series, message string) {
+	// Apply site settings to posts
+	if err := preparePosts(r, svc, series.Posts); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"series":  series,
+	})
+}
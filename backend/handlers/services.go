@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/oauth"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/securitylog"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+
+	"gorm.io/gorm"
+)
+
+// servicesFromContext returns the services attached to the request context,
+// scoped to its database connection. Handlers call it once per request.
+func servicesFromContext(r *http.Request) (*services.Services, bool) {
+	svc, ok := r.Context().Value(types.KeyServices).(*services.Services)
+	if !ok || svc == nil {
+		return nil, false
+	}
+	if db, ok := r.Context().Value(types.KeyDB).(*gorm.DB); ok {
+		svc = svc.WithDB(db)
+	}
+	return svc, true
+}
+
+// writeServiceError replies to the request with the error response matching
+// an error returned by a service. Unexpected errors are reported with the
+// fallback message, without leaking their details.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	var validationErr *services.ValidationError
+	var quotaErr *services.QuotaExceededError
+	var lintErr *services.LintError
+
+	status := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &validationErr):
+		// Validation messages are translated before they are formatted
+		message := validationErr.Message
+		apperrors.Errorf(w, r, http.StatusBadRequest, strings.ToUpper(message[:1])+message[1:], validationErr.Args...)
+		return
+	case errors.As(err, &quotaErr):
+		message := quotaErr.Error()
+		apperrors.WriteCode(w, r, http.StatusPaymentRequired, "limit_exceeded", strings.ToUpper(message[:1])+message[1:])
+		return
+	case errors.As(err, &lintErr):
+		message := lintErr.Error()
+		apperrors.WriteDetails(w, r, http.StatusUnprocessableEntity, "lint_failed", strings.ToUpper(message[:1])+message[1:], lintErr.Issues)
+		return
+	case errors.Is(err, services.ErrPostNotFound),
+		errors.Is(err, services.ErrCommentNotFound),
+		errors.Is(err, services.ErrUserNotFound),
+		errors.Is(err, services.ErrTagNotFound),
+		errors.Is(err, services.ErrCategoryNotFound),
+		errors.Is(err, services.ErrSeriesNotFound),
+		errors.Is(err, services.ErrAuthorNotFound),
+		errors.Is(err, services.ErrWebhookNotFound),
+		errors.Is(err, services.ErrDeliveryNotFound),
+		errors.Is(err, services.ErrIntegrationNotFound),
+		errors.Is(err, services.ErrTranslationNotFound),
+		errors.Is(err, services.ErrNotificationNotFound),
+		errors.Is(err, services.ErrAnnouncementNotFound),
+		errors.Is(err, services.ErrTaskNotFound),
+		errors.Is(err, services.ErrTaskRunNotFound),
+		errors.Is(err, services.ErrImportNotFound),
+		errors.Is(err, services.ErrExportNotFound),
+		errors.Is(err, services.ErrExportUnavailable),
+		errors.Is(err, services.ErrPreviewNotFound),
+		errors.Is(err, services.ErrIdentityNotFound),
+		errors.Is(err, services.ErrSuppressionNotFound),
+		errors.Is(err, services.ErrPlanNotFound),
+		errors.Is(err, services.ErrSearchNotFound),
+		errors.Is(err, services.ErrSessionNotFound),
+		errors.Is(err, oauth.ErrUnknownProvider),
+		errors.Is(err, repositories.ErrNotLiked):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidCredentials),
+		errors.Is(err, services.ErrInvalidEmbedToken),
+		errors.Is(err, services.ErrInvalidRefreshToken),
+		errors.Is(err, services.ErrInvalidSignature),
+		errors.Is(err, oauth.ErrExchangeFailed):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrForbidden),
+		errors.Is(err, services.ErrEmbedOriginMismatch),
+		errors.Is(err, services.ErrPendingApproval),
+		errors.Is(err, services.ErrAccountInactive),
+		errors.Is(err, services.ErrGuestCommentsDisabled),
+		errors.Is(err, services.ErrReferralsDisabled):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrUsernameTaken),
+		errors.Is(err, services.ErrEmailTaken),
+		errors.Is(err, services.ErrAlreadyVerified),
+		errors.Is(err, repositories.ErrAlreadyLiked),
+		errors.Is(err, repositories.ErrAlreadyReported),
+		errors.Is(err, services.ErrTaskRunning),
+		errors.Is(err, services.ErrIdentityTaken),
+		errors.Is(err, services.ErrIdentityLinked),
+		errors.Is(err, services.ErrIdentityEmailTaken),
+		errors.Is(err, services.ErrLastLoginMethod),
+		errors.Is(err, services.ErrPlanInUse),
+		errors.Is(err, services.ErrPlanNameTaken),
+		errors.Is(err, services.ErrCategorySlugTaken),
+		errors.Is(err, services.ErrSeriesSlugTaken),
+		errors.Is(err, services.ErrGuestEmailTaken),
+		errors.Is(err, services.ErrPostInSeries),
+		errors.Is(err, services.ErrAlreadyPublished):
+		status = http.StatusConflict
+	case errors.Is(err, services.ErrTooManyComments):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, services.ErrPreviewUnavailable),
+		errors.Is(err, services.ErrCaptchaUnavailable):
+		status = http.StatusBadGateway
+	}
+
+	switch status {
+	case http.StatusUnauthorized:
+		securitylog.AuthFailure(r, err.Error())
+	case http.StatusForbidden:
+		if errors.Is(err, services.ErrForbidden) {
+			securitylog.PermissionDenied(r, err.Error())
+		}
+	}
+
+	if status == http.StatusInternalServerError {
+		apperrors.Error(w, r, fallback, status)
+		return
+	}
+	message := err.Error()
+	apperrors.Error(w, r, strings.ToUpper(message[:1])+message[1:], status)
+}
+
+// servicesUnavailable replies to requests received without services attached.
+func servicesUnavailable(w http.ResponseWriter, r *http.Request) {
+	apperrors.Error(w, r, "Internal Server Error (Services unavailable)", http.StatusInternalServerError)
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+)
+
+// SessionResponse is an active session of the authenticated user.
+type SessionResponse struct {
+	models.Session
+	Device  string `json:"device"`  // Browser and operating system, from the user agent
+	Current bool   `json:"current"` // Session the request was authenticated with
+}
+
+// ListSessions lists the active sessions of the authenticated user, the
+// devices they are logged in on, the ones used last first.
+func ListSessions(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+	sessionID, _ := r.Context().Value(types.KeySessionID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	sessions, err := svc.Auth.Sessions(userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve sessions")
+		return
+	}
+
+	response := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = SessionResponse{
+			Session: session,
+			Device:  deviceName(session.UserAgent),
+			Current: session.ID == sessionID,
+		}
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": response,
+	})
+}
+
+// RevokeSession logs the authenticated user out of one of their sessions.
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	sessionID, ok := routeID(w, r, types.IDField, "Invalid session ID")
+	if !ok {
+		return
+	}
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	if err := svc.Auth.RevokeSession(userID, sessionID); err != nil {
+		writeServiceError(w, r, err, "Failed to revoke session")
+		return
+	}
+	recordAction(r, svc, userID, services.AuditSessionRevoked, userID, map[string]interface{}{
+		"session_id": sessionID,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Session revoked successfully",
+	})
+}
+
+// RevokeSessions logs the authenticated user out everywhere, including the
+// session of the request.
+func RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	if err := svc.Auth.RevokeSessions(userID); err != nil {
+		writeServiceError(w, r, err, "Failed to revoke sessions")
+		return
+	}
+	recordAction(r, svc, userID, services.AuditSessionsRevoked, userID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Logged out of all sessions",
+	})
+}
+
+// deviceName describes the browser and operating system of a user agent,
+// such as "Firefox on Linux". Unknown parts are left out.
+func deviceName(userAgent string) string {
+	var browser, system string
+
+	// Order matters: Edge and Opera also claim to be Chrome, which claims to
+	// be Safari
+	for _, candidate := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+	for _, candidate := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, candidate.token) {
+			system = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	return "Unknown device"
+}
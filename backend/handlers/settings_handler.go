@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/licenses"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/utils"
+)
+
+// UpdateSettingsRequest represents the structure for updating site settings.
+// Omitted fields are left unchanged.
+type UpdateSettingsRequest struct {
+	RegistrationOpen *bool                `json:"registration_open"`
+	RequireApproval  *bool                `json:"require_approval"`
+	CommentsEnabled  *bool                `json:"comments_enabled"`
+	SensitiveGating  *bool                `json:"sensitive_gating"`
+	ReferralsEnabled *bool                `json:"referrals_enabled"`
+	DefaultLicense   *string              `json:"default_license"`
+	RobotsRules      *[]models.RobotsRule `json:"robots_rules"`
+	SecurityContact  *string              `json:"security_contact"`
+	// Branding. Media IDs refer to uploaded images, 0 removes the logo or favicon
+	LogoMediaID    *uint   `json:"logo_media_id"`
+	FaviconMediaID *uint   `json:"favicon_media_id"`
+	PrimaryColor   *string `json:"primary_color"` // #rrggbb, empty for the frontend default
+	AccentColor    *string `json:"accent_color"`
+	CustomHead     *string `json:"custom_head"` // Only <meta> and <link> tags are kept
+	// Dates
+	Timezone   *string `json:"timezone"`    // IANA name, e.g. Europe/Paris
+	DateFormat *string `json:"date_format"` // iso, us, eu or long
+	// Plan capping the content of the site, 0 removes the limits
+	PlanID *uint `json:"plan_id"`
+}
+
+// GetSettings returns the public site settings so the frontend can adjust its UI
+func GetSettings(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(settingsResponse(svc, settings))
+}
+
+// UpdateSettings updates the site settings. Only admins can update settings.
+func UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Check if user is an admin
+	if err := svc.Users.RequireAdmin(userID); err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve user")
+		return
+	}
+
+	var req UpdateSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.DefaultLicense != nil && !licenses.Valid(*req.DefaultLicense) {
+		apperrors.Error(w, r, "Unsupported license", http.StatusBadRequest)
+		return
+	}
+
+	if req.RobotsRules != nil {
+		for _, rule := range *req.RobotsRules {
+			if rule.UserAgent == "" {
+				apperrors.Error(w, r, "Robots rules require a user agent", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	for _, id := range []*uint{req.LogoMediaID, req.FaviconMediaID} {
+		if id != nil && *id != 0 {
+			if err := svc.Settings.ValidateBrandingMedia(*id); err != nil {
+				writeServiceError(w, r, err, "Failed to retrieve media")
+				return
+			}
+		}
+	}
+	for _, color := range []*string{req.PrimaryColor, req.AccentColor} {
+		if color != nil {
+			if err := services.ValidateColor(*color); err != nil {
+				writeServiceError(w, r, err, "Invalid color")
+				return
+			}
+		}
+	}
+
+	if req.Timezone != nil {
+		if err := services.ValidateTimezone(*req.Timezone); err != nil {
+			writeServiceError(w, r, err, "Invalid timezone")
+			return
+		}
+	}
+	if req.DateFormat != nil {
+		if err := services.ValidateDateFormat(*req.DateFormat); err != nil {
+			writeServiceError(w, r, err, "Invalid date format")
+			return
+		}
+	}
+
+	if req.PlanID != nil && *req.PlanID != 0 {
+		if _, err := svc.Plans.GetPlan(*req.PlanID); err != nil {
+			writeServiceError(w, r, err, "Failed to retrieve plan")
+			return
+		}
+	}
+
+	settings, err := svc.Settings.Get()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	// Apply provided fields
+	if req.RegistrationOpen != nil {
+		settings.RegistrationOpen = *req.RegistrationOpen
+	}
+	if req.RequireApproval != nil {
+		settings.RequireApproval = *req.RequireApproval
+	}
+	if req.CommentsEnabled != nil {
+		settings.CommentsEnabled = *req.CommentsEnabled
+	}
+	if req.SensitiveGating != nil {
+		settings.SensitiveGating = *req.SensitiveGating
+	}
+	if req.ReferralsEnabled != nil {
+		settings.ReferralsEnabled = *req.ReferralsEnabled
+	}
+	if req.DefaultLicense != nil {
+		settings.DefaultLicense = *req.DefaultLicense
+	}
+	if req.RobotsRules != nil {
+		settings.RobotsRules = *req.RobotsRules
+	}
+	if req.SecurityContact != nil {
+		settings.SecurityContact = *req.SecurityContact
+	}
+	if req.LogoMediaID != nil {
+		settings.LogoMediaID = optionalID(*req.LogoMediaID)
+	}
+	if req.FaviconMediaID != nil {
+		settings.FaviconMediaID = optionalID(*req.FaviconMediaID)
+	}
+	if req.PrimaryColor != nil {
+		settings.PrimaryColor = *req.PrimaryColor
+	}
+	if req.AccentColor != nil {
+		settings.AccentColor = *req.AccentColor
+	}
+	if req.CustomHead != nil {
+		settings.CustomHead = utils.SanitizeHeadSnippet(*req.CustomHead)
+	}
+	if req.Timezone != nil {
+		settings.Timezone = *req.Timezone
+	}
+	if req.DateFormat != nil {
+		settings.DateFormat = *req.DateFormat
+	}
+	if req.PlanID != nil {
+		settings.PlanID = optionalID(*req.PlanID)
+	}
+
+	if err := svc.Settings.Update(settings); err != nil {
+		apperrors.Error(w, r, "Settings update failed", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Settings updated successfully",
+		"settings": settingsResponse(svc, settings),
+	})
+}
+
+// settingsResponse returns the public representation of the site settings.
+func settingsResponse(svc *services.Services, settings *models.SiteSettings) map[string]interface{} {
+	return map[string]interface{}{
+		"registration_open": settings.RegistrationOpen,
+		"require_approval":  settings.RequireApproval,
+		"comments_enabled":  settings.CommentsEnabled,
+		"sensitive_gating":  settings.SensitiveGating,
+		"referrals_enabled": settings.ReferralsEnabled,
+		"default_license":   licenses.Resolve(settings.DefaultLicense, ""),
+		"robots_rules":      settings.RobotsRules,
+		"security_contact":  settings.SecurityContact,
+		"branding":          svc.Settings.Branding(settings),
+		"timezone":          services.SiteLocation(settings).String(),
+		"date_format":       dateFormat(settings),
+		"highlight_css_url": highlightCSSURL(),
+	}
+}
+
+// dateFormat returns the date format of the site settings, iso when unset.
+func dateFormat(settings *models.SiteSettings) string {
+	if _, ok := services.DateFormats[settings.DateFormat]; !ok {
+		return "iso"
+	}
+	return settings.DateFormat
+}
+
+// optionalID returns a reference to a record, such as a media, by its ID,
+// nil for 0.
+func optionalID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/geoip"
+	"github.com/SteaceP/coderage/types"
+)
+
+// GetPostStats returns the views of a post for each of the last days (30 by
+// default, 365 at most). Only the author of the post and admins can see them.
+func GetPostStats(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	postID, ok := routeID(w, r, types.IDField, "Invalid post ID")
+	if !ok {
+		return
+	}
+	userID, _ := r.Context().Value(types.KeyUserID).(uint)
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+
+	stats, err := svc.Views.PostStats(postID, userID, days)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve post stats")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetAuthorStats returns the views of the posts of the current user for each
+// of the last days (30 by default, 365 at most), with their most viewed
+// posts, for the dashboard of authors.
+func GetAuthorStats(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	userID := r.Context().Value(types.KeyUserID).(uint)
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+
+	stats, err := svc.Views.AuthorStats(userID, days)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve stats", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetAuthorAnalytics returns how the posts of the current user perform: the
+// views, likes and comments of each post, in total and over the last days
+// (30 by default, 365 at most), and the views and comments of all their
+// posts for each of these days. With format=csv, the posts are exported as
+// CSV instead, or the days with report=days.
+func GetAuthorAnalytics(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	userID := r.Context().Value(types.KeyUserID).(uint)
+	query := r.URL.Query()
+	days, _ := strconv.Atoi(query.Get("days"))
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		apperrors.Error(w, r, "Format must be json or csv", http.StatusBadRequest)
+		return
+	}
+	report := query.Get("report")
+	if report != "" && report != "posts" && report != "days" {
+		apperrors.Error(w, r, "Report must be posts or days", http.StatusBadRequest)
+		return
+	}
+
+	analytics, err := svc.Analytics.AuthorAnalytics(userID, days)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve analytics", http.StatusInternalServerError)
+		return
+	}
+
+	if format != "csv" {
+		// Send response
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(analytics)
+		return
+	}
+
+	rows := [][]string{{"post_id", "title", "slug", "status", "published_at", "views", "likes", "comments", "period_views", "period_comments"}}
+	if report == "days" {
+		rows = [][]string{{"day", "views", "comments"}}
+		for _, day := range analytics.Days {
+			rows = append(rows, []string{day.Day.Format(time.DateOnly), fmt.Sprint(day.Views), fmt.Sprint(day.Comments)})
+		}
+	} else {
+		report = "posts"
+		for _, post := range analytics.Posts {
+			publishedAt := ""
+			if post.PublishedAt != nil {
+				publishedAt = post.PublishedAt.UTC().Format(time.RFC3339)
+			}
+			rows = append(rows, []string{
+				fmt.Sprint(post.PostID), post.Title, post.Slug, post.Status, publishedAt,
+				fmt.Sprint(post.Views), fmt.Sprint(post.Likes), fmt.Sprint(post.Comments),
+				fmt.Sprint(post.PeriodViews), fmt.Sprint(post.PeriodComments),
+			})
+		}
+	}
+
+	// Send the CSV file
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="analytics-%s-%s.csv"`, report, time.Now().UTC().Format(time.DateOnly)))
+	w.WriteHeader(http.StatusOK)
+	csv.NewWriter(w).WriteAll(rows)
+}
+
+// visitor identifies the visitor viewing a post: the user, or else the IP
+// address and user agent of the request.
+func visitor(r *http.Request, viewerID uint) string {
+	if viewerID != 0 {
+		return fmt.Sprintf("user:%d", viewerID)
+	}
+	return fmt.Sprintf("ip:%s %s", geoip.RemoteIP(r), r.UserAgent())
+}
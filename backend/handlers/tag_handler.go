@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/gorilla/mux"
+)
+
+// ListTags retrieves all tags with their post counts
+func ListTags(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	tags, err := svc.Tags.ListTags()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve tags", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tags": tags,
+	})
+}
+
+// ListTagPosts retrieves the posts of a tag with pagination
+func ListTagPosts(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Find tag
+	vars := mux.Vars(r)
+	tag, err := svc.Tags.GetTag(vars["slug"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve tag")
+		return
+	}
+
+	// Parse query parameters for pagination
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	// Fetch posts
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	posts, totalCount, err := svc.Posts.ListPosts(page, limit, map[string]interface{}{
+		"tags": []string{tag.Slug},
+	}, viewerID)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve posts", http.StatusInternalServerError)
+		return
+	}
+
+	// Apply site settings to posts
+	if err := preparePosts(r, svc, posts); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	// Prepare response
+	response := map[string]interface{}{
+		"tag":   tag,
+		"posts": posts,
+		"pagination": map[string]interface{}{
+			"total":       totalCount,
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		},
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/gorilla/mux"
+)
+
+// ListTasks lists the maintenance tasks admins can run, along with their
+// recent runs.
+func ListTasks(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tasks": svc.Tasks.ListTasks(),
+		"runs":  svc.Tasks.ListRuns(),
+	})
+}
+
+// RunTask starts a maintenance task in the background. The run is returned
+// right away, and its status can be polled at the URL of the Location header.
+func RunTask(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	userID, _ := r.Context().Value(types.KeyUserID).(uint)
+	run, err := svc.Tasks.Start(mux.Vars(r)["name"], userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to start task")
+		return
+	}
+	recordAdminAction(r, svc, services.AuditTaskStarted, 0, map[string]interface{}{
+		"task":   run.Task,
+		"run_id": run.ID,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/admin/tasks/runs/"+run.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// GetTaskRun retrieves the status of a task run.
+func GetTaskRun(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	run, err := svc.Tasks.GetRun(mux.Vars(r)["id"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve task run")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(run)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/gorilla/mux"
+)
+
+// SaveTranslationRequest is the translation of a post to the locale of the
+// route. Fields left empty, other than the title and content, fall back to
+// those of the post.
+type SaveTranslationRequest struct {
+	Title           string `json:"title"`
+	Content         string `json:"content"`
+	Excerpt         string `json:"excerpt,omitempty"`
+	MetaTitle       string `json:"meta_title,omitempty"`
+	MetaDescription string `json:"meta_description,omitempty"`
+}
+
+// ListPostTranslations lists the translations of a post. The content of the
+// translations of a sensitive post is gated as the one of the post.
+func ListPostTranslations(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	postID, ok := routeID(w, r, types.IDField, "Invalid post ID")
+	if !ok {
+		return
+	}
+
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	translations, post, err := svc.Translations.List(postID, viewerID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve translations")
+		return
+	}
+	if err := gateSensitiveTranslations(r, svc, post, translations); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"translations": translations,
+	})
+}
+
+// GetPostTranslation retrieves the translation of a post to a locale. The
+// content of the translation of a sensitive post is gated as the one of the
+// post.
+func GetPostTranslation(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	postID, ok := routeID(w, r, types.IDField, "Invalid post ID")
+	if !ok {
+		return
+	}
+
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	translation, post, err := svc.Translations.Get(postID, mux.Vars(r)["locale"], viewerID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve translation")
+		return
+	}
+	translations := []models.PostTranslation{*translation}
+	if err := gateSensitiveTranslations(r, svc, post, translations); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+	translation = &translations[0]
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", translation.Locale)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(translation)
+}
+
+// SavePostTranslation creates or replaces the translation of a post of the
+// authenticated user to a locale.
+func SavePostTranslation(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	postID, ok := routeID(w, r, types.IDField, "Invalid post ID")
+	if !ok {
+		return
+	}
+
+	var req SaveTranslationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	translation, created, err := svc.Translations.Save(postID, userID, &models.PostTranslation{
+		Locale:          mux.Vars(r)["locale"],
+		Title:           req.Title,
+		Content:         req.Content,
+		Excerpt:         req.Excerpt,
+		MetaTitle:       req.MetaTitle,
+		MetaDescription: req.MetaDescription,
+	})
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to save translation")
+		return
+	}
+
+	status := http.StatusOK
+	message := "Translation updated successfully"
+	if created {
+		status = http.StatusCreated
+		message = "Translation created successfully"
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     message,
+		"translation": translation,
+	})
+}
+
+// DeletePostTranslation removes the translation of a post of the
+// authenticated user to a locale.
+func DeletePostTranslation(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	postID, ok := routeID(w, r, types.IDField, "Invalid post ID")
+	if !ok {
+		return
+	}
+
+	if err := svc.Translations.Delete(postID, mux.Vars(r)["locale"], userID); err != nil {
+		writeServiceError(w, r, err, "Failed to delete translation")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Translation deleted successfully",
+	})
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SteaceP/coderage/types"
+)
+
+// Unfurl returns the preview of the web page at the url query parameter,
+// built from its OpenGraph and Twitter card metadata. Only authenticated
+// users can have new pages fetched, other clients get the cached previews.
+func Unfurl(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	_, authenticated := r.Context().Value(types.KeyUserID).(uint)
+	preview, err := svc.Previews.Preview(r.Context(), r.URL.Query().Get("url"), authenticated)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve preview")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"preview": preview,
+	})
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/imaging"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/ratelimit"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/storage"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/google/uuid"
+)
+
+// uploadExtensions maps the accepted content types to file extensions
+var uploadExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// CreateUpload handles multipart file uploads.
+//
+// The file is expected in the "file" form field. Its content type is sniffed
+// from its content rather than trusted from the client, and must be one of
+// the configured "uploads.allowed_types". Files larger than
+// "uploads.max_size_mb" are rejected, as are uploads beyond the daily quota
+// of "uploads.daily_quota" files per user, unless the request is exempt. The
+// quota is extended by the upload bonus earned through referrals.
+func CreateUpload(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services and storage from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+	store, ok := r.Context().Value(types.KeyStorage).(storage.Storage)
+	if !ok {
+		apperrors.Error(w, r, "Internal Server Error (Storage unavailable)", http.StatusInternalServerError)
+		return
+	}
+
+	// Check daily upload quota
+	if quota := config.Get().Uploads.DailyQuota; quota > 0 && !requestExempt(r) {
+		perks, err := svc.Referrals.GetPerks(userID)
+		if err != nil {
+			apperrors.Error(w, r, "Failed to check upload quota", http.StatusInternalServerError)
+			return
+		}
+		quota += perks.UploadBonus
+
+		count, err := svc.Media.CountRecentUploads(userID, 24*time.Hour)
+		if err != nil {
+			apperrors.Error(w, r, "Failed to check upload quota", http.StatusInternalServerError)
+			return
+		}
+		if count >= quota {
+			apperrors.Error(w, r, "Daily upload quota exceeded", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	// Limit request size, leaving room for the multipart envelope
+	maxSize := config.Get().Uploads.MaxSizeMB << 20
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+(1<<20))
+	if err := r.ParseMultipartForm(maxSize); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			apperrors.Error(w, r, "File too large", http.StatusRequestEntityTooLarge)
+		} else {
+			apperrors.Error(w, r, "Invalid multipart form", http.StatusBadRequest)
+		}
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		apperrors.Error(w, r, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxSize {
+		apperrors.Error(w, r, "File too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := svc.Plans.Check(services.QuotaStorage, header.Size); err != nil {
+		writeServiceError(w, r, err, "Failed to check plan limits")
+		return
+	}
+
+	// Detect content type from the first bytes of the file
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		apperrors.Error(w, r, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+	contentType := http.DetectContentType(sniff[:n])
+	if !uploadTypeAllowed(contentType) {
+		apperrors.Error(w, r, fmt.Sprintf("Unsupported file type: %s", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		apperrors.Error(w, r, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	// Record the dimensions of the image
+	dimensions, err := imaging.DecodeConfig(file)
+	if err != nil {
+		apperrors.Error(w, r, "Invalid image", http.StatusBadRequest)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		apperrors.Error(w, r, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	// Hash the content, so new content always gets a new URL
+	hash, err := storage.ContentHash(file)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		apperrors.Error(w, r, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	// Store file
+	name := uuid.New().String() + "." + hash[:storage.HashLength] + uploadExtensions[contentType]
+	key := path.Join("uploads", time.Now().UTC().Format("2006/01"), name)
+	if err := store.Put(r.Context(), key, file, header.Size, contentType); err != nil {
+		apperrors.Error(w, r, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	media := models.Media{
+		UserID:       userID,
+		Backend:      store.Name(),
+		StorageKey:   key,
+		URL:          store.URL(key),
+		OriginalName: header.Filename,
+		ContentType:  contentType,
+		Size:         header.Size,
+		ContentHash:  hash,
+		Width:        dimensions.Width,
+		Height:       dimensions.Height,
+	}
+	if err := svc.Media.CreateMedia(&media); err != nil {
+		store.Delete(r.Context(), key)
+		apperrors.Error(w, r, "Upload failed", http.StatusInternalServerError)
+		return
+	}
+
+	// Generate the variants of the image in the background, once stored
+	variantsPending := false
+	if config.Get().Uploads.Variants.Enabled && imaging.Resizable(contentType) && !storage.Sandboxed(store) {
+		_, err := svc.Images.GenerateVariants(store, &media)
+		variantsPending = err == nil
+	}
+
+	// Prepare response
+	response := map[string]interface{}{
+		"message": "File uploaded successfully",
+		"upload": map[string]interface{}{
+			"id":               media.ID,
+			"url":              media.URL,
+			"content_type":     media.ContentType,
+			"size":             media.Size,
+			"content_hash":     media.ContentHash,
+			"width":            media.Width,
+			"height":           media.Height,
+			"variants_pending": variantsPending,
+		},
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// uploadTypeAllowed reports whether a content type is both supported and
+// allowed by configuration.
+func uploadTypeAllowed(contentType string) bool {
+	if _, ok := uploadExtensions[contentType]; !ok {
+		return false
+	}
+	for _, allowed := range config.Get().Uploads.AllowedTypes {
+		if allowed == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// requestExempt reports whether the request was exempted from rate limits and
+// quotas by the rate limit policy.
+func requestExempt(r *http.Request) bool {
+	_, ok := r.Context().Value(types.KeyExemption).(*ratelimit.Exemption)
+	return ok
+}
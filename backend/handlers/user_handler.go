@@ -3,12 +3,14 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
-	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/services"
 	"github.com/SteaceP/coderage/types"
 	"github.com/SteaceP/coderage/utils"
 
-	"gorm.io/gorm"
+	"github.com/gorilla/mux"
 )
 
 // GetUserProfile retrieves a user's profile details
@@ -16,31 +18,26 @@ func GetUserProfile(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context (set by AuthMiddleware)
 	userIDValue := r.Context().Value(types.KeyUserID)
 	if userIDValue == nil {
-		http.Error(w, "User ID not found in context", http.StatusUnauthorized)
+		apperrors.Error(w, r, "User ID not found in context", http.StatusUnauthorized)
 		return
 	}
 	userID, ok := userIDValue.(uint)
 	if !ok {
-		http.Error(w, "Invalid user ID type", http.StatusInternalServerError)
+		apperrors.Error(w, r, "Invalid user ID type", http.StatusInternalServerError)
 		return
 	}
 
-	// Get database from context
-	dbValue := r.Context().Value(types.KeyDB)
-	if dbValue == nil {
-		http.Error(w, "Database not found in context", http.StatusInternalServerError)
-		return
-	}
-	db, ok := dbValue.(*gorm.DB)
+	// Get services from context
+	svc, ok := servicesFromContext(r)
 	if !ok {
-		http.Error(w, "Invalid database type", http.StatusInternalServerError)
+		servicesUnavailable(w, r)
 		return
 	}
 
 	// Find user
-	var user models.User
-	if err := db.First(&user, userID).Error; err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+	user, err := svc.Users.GetUserProfile(userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve user")
 		return
 	}
 
@@ -55,6 +52,511 @@ func GetUserProfile(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		apperrors.Error(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// UpdateProfileRequest represents the structure for updating the
+// authenticated user's profile. Omitted fields are left unchanged, and empty
+// strings clear the field.
+type UpdateProfileRequest struct {
+	FirstName       *string `json:"first_name"`
+	LastName        *string `json:"last_name"`
+	Bio             *string `json:"bio"`
+	ProfilePicture  *string `json:"profile_picture"`
+	TwitterHandle   *string `json:"twitter_handle"`
+	LinkedInProfile *string `json:"linkedin_profile"`
+	PersonalWebsite *string `json:"personal_website"`
+}
+
+// UpdateProfile updates the authenticated user's profile
+func UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := svc.Users.UpdateProfile(userID, services.ProfileUpdate{
+		FirstName:       sanitized(req.FirstName),
+		LastName:        sanitized(req.LastName),
+		Bio:             sanitized(req.Bio),
+		ProfilePicture:  sanitized(req.ProfilePicture),
+		TwitterHandle:   sanitized(req.TwitterHandle),
+		LinkedInProfile: sanitized(req.LinkedInProfile),
+		PersonalWebsite: sanitized(req.PersonalWebsite),
+	})
+	if err != nil {
+		writeServiceError(w, r, err, "Profile update failed")
+		return
+	}
+	svc.Onboarding.Refresh(r.Context(), userID)
+	pictureSet, err := svc.Images.ImageSet(user.ProfilePicture)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Profile updated successfully",
+		"profile": map[string]interface{}{
+			"id":                  utils.UintToString(user.ID),
+			"username":            user.Username,
+			"email":               user.Email,
+			"first_name":          user.FirstName,
+			"last_name":           user.LastName,
+			"bio":                 user.Bio,
+			"profile_picture":     user.ProfilePicture,
+			"profile_picture_set": pictureSet,
+			"twitter_handle":      user.TwitterHandle,
+			"linkedin_profile":    user.LinkedInProfile,
+			"personal_website":    user.PersonalWebsite,
+		},
+	})
+}
+
+// sanitized returns the sanitized value of an optional text field.
+func sanitized(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	s := utils.SanitizeInput(*value)
+	return &s
+}
+
+// ChangePasswordRequest represents the structure for changing the
+// authenticated user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword changes the authenticated user's password. The refresh
+// tokens issued before are invalidated, so a new token pair is returned.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		apperrors.Error(w, r, "Current and new passwords are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := svc.Users.ChangePassword(userID, req.CurrentPassword, req.NewPassword); err != nil {
+		writeServiceError(w, r, err, "Password change failed")
+		return
+	}
+	recordAction(r, svc, userID, services.AuditPasswordChanged, userID, nil)
+
+	// Generate tokens of the new version
+	user, err := svc.Users.GetUserProfile(userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve user")
+		return
+	}
+	tokens, err := svc.Auth.CreateTokenPair(user, requestClient(r))
+	if err != nil {
+		apperrors.Error(w, r, "Token generation failed", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "Password changed successfully",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+	})
+}
+
+// DeleteAccountRequest represents the structure for deleting the
+// authenticated user's account
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// DeleteAccount deletes the authenticated user's account, after checking
+// their password. Their posts are removed and their comments anonymized.
+func DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" {
+		apperrors.Error(w, r, "Password is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := svc.Accounts.Delete(userID, req.Password); err != nil {
+		writeServiceError(w, r, err, "Account deletion failed")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Account deleted successfully",
+	})
+}
+
+// ExportAccount returns all the data of the authenticated user as a JSON
+// attachment: their profile, posts and comments.
+func ExportAccount(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	export, err := svc.Accounts.Export(userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to export account")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="account-export.json"`)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(export)
+}
+
+// GetOnboarding returns the progress of the authenticated user through the
+// onboarding, so the frontend can guide them to the next step.
+func GetOnboarding(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	status, err := svc.Onboarding.Status(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve onboarding")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(status)
+}
+
+// GetPublicProfile retrieves the public profile of a user by their username,
+// along with their published posts. The email address is never included.
+func GetPublicProfile(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Find user
+	user, err := svc.Users.GetPublicProfile(mux.Vars(r)["username"])
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve user")
+		return
 	}
+
+	// Parse query parameters for pagination
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	// Fetch the published posts of the user
+	viewerID, _ := r.Context().Value(types.KeyUserID).(uint)
+	filters := map[string]interface{}{
+		"status":  "published",
+		"user_id": user.ID,
+	}
+	posts, totalCount, err := svc.Posts.ListPosts(page, limit, filters, viewerID)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve posts", http.StatusInternalServerError)
+		return
+	}
+
+	// Apply site settings to posts
+	if err := preparePosts(r, svc, posts); err != nil {
+		apperrors.Error(w, r, "Failed to retrieve settings", http.StatusInternalServerError)
+		return
+	}
+
+	perks, err := svc.Referrals.GetPerks(user.ID)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+	pictureSet, err := svc.Images.ImageSet(user.ProfilePicture)
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": map[string]interface{}{
+			"id":                  utils.UintToString(user.ID),
+			"username":            user.Username,
+			"first_name":          user.FirstName,
+			"last_name":           user.LastName,
+			"bio":                 user.Bio,
+			"profile_picture":     user.ProfilePicture,
+			"profile_picture_set": pictureSet,
+			"twitter_handle":      user.TwitterHandle,
+			"linkedin_profile":    user.LinkedInProfile,
+			"personal_website":    user.PersonalWebsite,
+			"badges":              perks.Badges,
+			"joined_at":           user.CreatedAt,
+		},
+		"posts": newPostResponses(posts),
+		"pagination": map[string]interface{}{
+			"total":       totalCount,
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// ApproveUser approves an account registered while the site required approval.
+func ApproveUser(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	targetID, ok := targetUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := svc.Users.ApproveUser(targetID); err != nil {
+		writeServiceError(w, r, err, "User approval failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditUserApproved, targetID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "User approved successfully",
+	})
+}
+
+// ResendVerificationRequest represents the structure for requesting a new
+// verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// ResendVerification sends a new verification email to the given address.
+//
+// The response doesn't reveal whether the address belongs to an account, nor
+// whether it is verified or the email failed to be sent, which the service
+// logs.
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req ResendVerificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	svc.Verification.ResendVerification(r.Context(), utils.SanitizeInput(req.Email))
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If an account exists for this email, a verification email has been sent",
+	})
+}
+
+// VerifyEmail redeems the verification token given in the query string
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		apperrors.Error(w, r, "Missing verification token", http.StatusBadRequest)
+		return
+	}
+
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	userID, err := svc.Verification.Verify(token)
+	if err != nil {
+		apperrors.Error(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	svc.Onboarding.Refresh(r.Context(), userID)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Email verified successfully",
+	})
+}
+
+// UpdatePreferencesRequest represents the structure for updating the
+// authenticated user's preferences
+type UpdatePreferencesRequest struct {
+	ShowSensitive *bool `json:"show_sensitive"`
+	EmailDigest   *bool `json:"email_digest"` // Emailed a digest of unread notifications, when the site sends them
+}
+
+// UpdatePreferences updates the authenticated user's preferences
+func UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req UpdatePreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := svc.Users.UpdatePreferences(userID, services.Preferences{
+		ShowSensitive: req.ShowSensitive,
+		EmailDigest:   req.EmailDigest,
+	})
+	if err != nil {
+		writeServiceError(w, r, err, "Preferences update failed")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Preferences updated successfully",
+		"preferences": map[string]bool{
+			"show_sensitive": user.ShowSensitive,
+			"email_digest":   user.EmailDigest,
+		},
+	})
+}
+
+// ListReferrals returns the referral code of the authenticated user, the
+// users they referred and the perks they earned
+func ListReferrals(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID := r.Context().Value(types.KeyUserID).(uint)
+
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	// Parse pagination parameters
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	summary, err := svc.Referrals.GetSummary(userID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve referrals")
+		return
+	}
+
+	referrals, totalCount, err := svc.Referrals.ListReferrals(userID, page, limit)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve referrals")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"referral":  summary,
+		"referrals": referrals,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		},
+	})
 }
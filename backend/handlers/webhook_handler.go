@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+)
+
+// CreateWebhookRequest represents the structure for registering a webhook
+type CreateWebhookRequest struct {
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"` // Generated when empty
+	Events      []string `json:"events"`
+	Description string   `json:"description,omitempty"`
+}
+
+// ListWebhooks lists the registered webhooks.
+func ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	webhooks, err := svc.Webhooks.ListWebhooks()
+	if err != nil {
+		apperrors.Error(w, r, "Failed to retrieve webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhooks": webhooks,
+	})
+}
+
+// CreateWebhook registers a webhook. The response is the only one including
+// the secret signing its payloads.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperrors.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	webhook := &models.Webhook{
+		URL:         req.URL,
+		Secret:      req.Secret,
+		Events:      req.Events,
+		Description: req.Description,
+	}
+	if err := svc.Webhooks.CreateWebhook(webhook); err != nil {
+		writeServiceError(w, r, err, "Webhook creation failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditWebhookCreated, webhook.ID, map[string]interface{}{
+		"url":    webhook.URL,
+		"events": webhook.Events,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Webhook created successfully",
+		"webhook": webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+// GetWebhook retrieves a webhook.
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	webhookID, ok := routeID(w, r, types.IDField, "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	webhook, err := svc.Webhooks.GetWebhook(webhookID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve webhook")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// DeleteWebhook removes a webhook.
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	webhookID, ok := routeID(w, r, types.IDField, "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	if err := svc.Webhooks.DeleteWebhook(webhookID); err != nil {
+		writeServiceError(w, r, err, "Webhook deletion failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditWebhookDeleted, webhookID, nil)
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// ListWebhookDeliveries lists the deliveries of a webhook, most recent first,
+// without their request and response bodies.
+func ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	webhookID, ok := routeID(w, r, types.IDField, "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	// Parse pagination parameters
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	deliveries, totalCount, err := svc.Webhooks.ListDeliveries(webhookID, page, limit)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve deliveries")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries": deliveries,
+		"pagination": map[string]interface{}{
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (totalCount + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetWebhookDelivery retrieves a delivery with its request, response and
+// signature details.
+func GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	webhookID, ok := routeID(w, r, types.IDField, "Invalid webhook ID")
+	if !ok {
+		return
+	}
+	deliveryID, ok := routeID(w, r, "deliveryId", "Invalid delivery ID")
+	if !ok {
+		return
+	}
+
+	delivery, err := svc.Webhooks.GetDelivery(webhookID, deliveryID)
+	if err != nil {
+		writeServiceError(w, r, err, "Failed to retrieve delivery")
+		return
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// TestWebhook sends a sample ping event to a webhook and returns the delivery.
+func TestWebhook(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	webhookID, ok := routeID(w, r, types.IDField, "Invalid webhook ID")
+	if !ok {
+		return
+	}
+
+	delivery, err := svc.Webhooks.SendTest(r.Context(), webhookID)
+	if err != nil {
+		writeServiceError(w, r, err, "Test delivery failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditWebhookTested, webhookID, map[string]interface{}{
+		"delivery_id": delivery.ID,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// RedeliverWebhookDelivery sends the payload of a delivery again and returns
+// the new delivery.
+func RedeliverWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	// Get services from context
+	svc, ok := servicesFromContext(r)
+	if !ok {
+		servicesUnavailable(w, r)
+		return
+	}
+
+	webhookID, ok := routeID(w, r, types.IDField, "Invalid webhook ID")
+	if !ok {
+		return
+	}
+	deliveryID, ok := routeID(w, r, "deliveryId", "Invalid delivery ID")
+	if !ok {
+		return
+	}
+
+	delivery, err := svc.Webhooks.Redeliver(r.Context(), webhookID, deliveryID)
+	if err != nil {
+		writeServiceError(w, r, err, "Redelivery failed")
+		return
+	}
+
+	recordAdminAction(r, svc, services.AuditWebhookRedelivered, webhookID, map[string]interface{}{
+		"delivery_id":   delivery.ID,
+		"redelivery_of": deliveryID,
+	})
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(delivery)
+}
@@ -0,0 +1,153 @@
+// Package httpclient builds the HTTP clients reaching URLs supplied by users
+// and integrators, such as webhook endpoints and pages to preview.
+//
+// Those URLs make the API a target for server-side request forgery, so the
+// clients only connect to public addresses. Addresses are checked after name
+// resolution, on the connection itself, so a host name resolving to an
+// internal address, or rebinding to one between two lookups, is refused.
+// Proxies from the environment are ignored, as they would hide the address
+// actually reached. Every request is bounded in time, retried when the
+// remote end is temporarily unavailable, and counted in the metrics.
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+)
+
+// maxRedirects is the number of redirects followed before giving up
+const maxRedirects = 5
+
+// ErrBlockedAddress is returned when a request would reach an address the
+// clients don't connect to.
+var ErrBlockedAddress = errors.New("address is not allowed")
+
+// reservedNetworks are the ranges not reachable on the public internet,
+// besides those recognised by the net/netip predicates.
+var reservedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, may map to private IPv4
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Options configures a client.
+type Options struct {
+	// Name identifies the client in the metrics, e.g. "webhooks"
+	Name string
+	// Timeout bounds a request, from connecting to reading the response body
+	Timeout time.Duration
+	// Ports restricts the ports the client connects to, any port being
+	// allowed when empty
+	Ports []uint16
+}
+
+// guard decides which addresses a client connects to.
+type guard struct {
+	blocked []netip.Prefix
+	allowed []netip.Prefix
+	ports   []uint16
+}
+
+// New returns a new HTTP client configured from the "http_client" settings.
+func New(opts Options) *http.Client {
+	cfg := config.Get().HTTPClient
+	g := &guard{
+		blocked: append(slices.Clone(reservedNetworks), parsePrefixes(cfg.BlockedNetworks)...),
+		allowed: parsePrefixes(cfg.AllowedNetworks),
+		ports:   opts.Ports,
+	}
+
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return g.check(address)
+		},
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: &retryTransport{
+			next:       transport,
+			name:       opts.Name,
+			maxRetries: cfg.MaxRetries,
+			backoff:    cfg.RetryBackoff,
+		},
+		Timeout: opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return CheckURL(req.URL)
+		},
+	}
+}
+
+// CheckURL rejects the URLs the clients won't request.
+func CheckURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return errors.New("url must have a host and no credentials")
+	}
+	return nil
+}
+
+// check rejects connections to addresses outside of the public internet,
+// unless explicitly allowed, or to ports the client doesn't connect to.
+func (g *guard) check(address string) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if len(g.ports) > 0 && !slices.Contains(g.ports, addrPort.Port()) {
+		return ErrBlockedAddress
+	}
+
+	addr := addrPort.Addr().Unmap()
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() {
+		return ErrBlockedAddress
+	}
+	for _, prefix := range g.blocked {
+		if prefix.Contains(addr) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+// parsePrefixes parses CIDR ranges, which are checked when the configuration
+// is loaded.
+func parsePrefixes(cidrs []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
@@ -0,0 +1,28 @@
+package httpclient
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "coderage",
+		Name:      "http_client_requests_total",
+		Help:      "Number of outbound HTTP requests, by client and status code, \"error\" when no response was received and \"blocked\" when the address was refused.",
+	}, []string{"client", "status"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "coderage",
+		Name:      "http_client_request_duration_seconds",
+		Help:      "Latency of outbound HTTP requests, by client.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"client"})
+	retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "coderage",
+		Name:      "http_client_retries_total",
+		Help:      "Number of outbound HTTP requests retried, by client.",
+	}, []string{"client"})
+)
+
+// Collectors returns the collectors of the outbound request metrics, to be
+// registered with those of the API.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{requests, requestDuration, retries}
+}
@@ -0,0 +1,85 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryTransport retries the requests failing because the remote end is
+// temporarily unavailable, and records every attempt in the metrics.
+type retryTransport struct {
+	next       http.RoundTripper
+	name       string
+	maxRetries int
+	backoff    time.Duration
+}
+
+// RoundTrip sends the request, retrying it after a connection failure or a
+// 429, 502, 503 or 504 response. The wait doubles after every attempt. A
+// request whose body can't be sent again, or which was refused by the
+// address checks, isn't retried.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			retries.WithLabelValues(t.name).Inc()
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			}
+		}
+
+		start := time.Now()
+		resp, err := t.next.RoundTrip(req)
+		observe(t.name, resp, err, time.Since(start))
+
+		if attempt >= t.maxRetries || !retryable(req, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// retryable reports whether the outcome of a request is worth retrying.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, ErrBlockedAddress) && req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// observe records an attempt in the metrics.
+func observe(name string, resp *http.Response, err error, duration time.Duration) {
+	status := "error"
+	switch {
+	case errors.Is(err, ErrBlockedAddress):
+		status = "blocked"
+	case err == nil:
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	requests.WithLabelValues(name, status).Inc()
+	requestDuration.WithLabelValues(name).Observe(duration.Seconds())
+}
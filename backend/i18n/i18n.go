@@ -0,0 +1,186 @@
+// Package i18n translates the messages of the API, such as its error and
+// validation messages, to the language preferred by the client.
+//
+// Messages are written in English in the code, and their English text is
+// their key in the message catalog. The catalog is built from the bundles
+// shipped with the API, one JSON object per language mapping messages to
+// their translation, which the bundles of the "i18n.bundles_dir"
+// configuration key extend. Messages missing from a bundle are left in
+// English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// bundleFS holds the bundles shipped with the API, named after their
+// language tag.
+//
+//go:embed locales/*.json
+var bundleFS embed.FS
+
+var (
+	mu      sync.RWMutex
+	current catalog.Catalog
+	matcher language.Matcher
+	tags    []language.Tag
+)
+
+func init() {
+	builder := catalog.NewBuilder(catalog.Fallback(language.English))
+	entries, err := bundleFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := bundleFS.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		if err := addBundle(builder, entry.Name(), data); err != nil {
+			panic(err)
+		}
+	}
+	SetCatalog(builder)
+}
+
+// Message is a message to translate, its key being formatted with its
+// arguments in the fmt syntax, e.g. "%s is required".
+type Message struct {
+	Key  string
+	Args []interface{}
+}
+
+// M returns the message of a key and its arguments.
+func M(key string, args ...interface{}) Message {
+	return Message{Key: key, Args: args}
+}
+
+// String returns the message in English.
+func (m Message) String() string {
+	if len(m.Args) == 0 {
+		return m.Key
+	}
+	return fmt.Sprintf(m.Key, m.Args...)
+}
+
+// In returns the message translated to a language.
+func (m Message) In(tag language.Tag) string {
+	return Sprintf(tag, m.Key, m.Args...)
+}
+
+// SetCatalog replaces the message catalog, e.g. with one backed by a
+// translation service. Catalogs should fall back to English.
+func SetCatalog(c catalog.Catalog) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = c
+	tags = c.Languages()
+	matcher = language.NewMatcher(tags)
+}
+
+// Load extends the catalog with the bundles of a directory, each a JSON
+// file named after the language tag of its messages, e.g. fr.json or
+// pt-BR.json. Translations of the directory replace those shipped with the
+// API. Load does nothing when the directory is empty.
+func Load(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	builder, ok := current.(*catalog.Builder)
+	if !ok {
+		return fmt.Errorf("the message catalog can't be extended")
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := addBundle(builder, filepath.Base(file), data); err != nil {
+			return err
+		}
+	}
+	tags = builder.Languages()
+	matcher = language.NewMatcher(tags)
+	return nil
+}
+
+// addBundle adds the translations of a bundle to a catalog.
+func addBundle(builder *catalog.Builder, name string, data []byte) error {
+	tag, err := language.Parse(strings.TrimSuffix(name, filepath.Ext(name)))
+	if err != nil {
+		return fmt.Errorf("invalid bundle name %s: %v", name, err)
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("invalid bundle %s: %v", name, err)
+	}
+	for key, translation := range messages {
+		if err := builder.SetString(tag, key, translation); err != nil {
+			return fmt.Errorf("invalid translation of %q in %s: %v", key, name, err)
+		}
+	}
+	return nil
+}
+
+// Languages returns the languages messages are translated to.
+func Languages() []language.Tag {
+	mu.RLock()
+	defer mu.RUnlock()
+	return tags
+}
+
+// Negotiate returns the language of the catalog best matching an
+// Accept-Language header, English when none does.
+func Negotiate(acceptLanguage string) language.Tag {
+	preferred, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(preferred) == 0 {
+		return language.English
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	_, index, confidence := matcher.Match(preferred...)
+	if confidence == language.No {
+		return language.English
+	}
+	return tags[index]
+}
+
+// FromRequest returns the language of the catalog best matching the
+// Accept-Language header of a request.
+func FromRequest(r *http.Request) language.Tag {
+	return Negotiate(r.Header.Get("Accept-Language"))
+}
+
+// Sprintf translates a message to a language and formats it with its
+// arguments. Messages without arguments are never formatted, so they may
+// hold percent signs.
+func Sprintf(tag language.Tag, key string, args ...interface{}) string {
+	mu.RLock()
+	c := current
+	mu.RUnlock()
+
+	if len(args) == 0 && strings.Contains(key, "%") {
+		return key
+	}
+	return message.NewPrinter(tag, message.Catalog(c)).Sprintf(key, args...)
+}
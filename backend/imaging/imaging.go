@@ -0,0 +1,87 @@
+// Package imaging decodes, resizes and encodes the uploaded images, to
+// generate their variants for responsive images.
+package imaging
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+
+	// Registers the GIF decoder, WebP being registered by nativewebp
+	_ "image/gif"
+)
+
+// maxPixels is the size of the largest image decoded, so small files
+// holding huge images can't exhaust the memory
+const maxPixels = 50_000_000
+
+// ErrTooLarge is returned when decoding an image of more than maxPixels
+// pixels.
+var ErrTooLarge = errors.New("image is too large")
+
+// Resizable reports whether the images of a content type can be resized.
+// Animated GIFs would lose their animation, so they are left as they are.
+func Resizable(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
+// DecodeConfig returns the dimensions of an image without decoding it.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	return cfg, err
+}
+
+// Decode decodes a JPEG, PNG, GIF or WebP image, refusing the images of more
+// than maxPixels pixels. The reader must be seekable, as the dimensions of
+// the image are checked first.
+func Decode(r io.ReadSeeker) (image.Image, error) {
+	cfg, err := DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+// Resize downscales an image to the given width, keeping its aspect ratio.
+func Resize(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	resized := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Src, nil)
+	return resized
+}
+
+// Encode writes an image in the format of a content type: JPEG of the given
+// quality, PNG, or lossless WebP.
+func Encode(w io.Writer, img image.Image, contentType string, quality int) error {
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "image/png":
+		return png.Encode(w, img)
+	case "image/webp":
+		return nativewebp.Encode(w, img, nil)
+	default:
+		return fmt.Errorf("unsupported image type: %s", contentType)
+	}
+}
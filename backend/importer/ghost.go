@@ -0,0 +1,142 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ghostData is the content of a Ghost JSON export.
+type ghostData struct {
+	Posts []struct {
+		ID              string     `json:"id"`
+		Title           string     `json:"title"`
+		Slug            string     `json:"slug"`
+		HTML            string     `json:"html"`
+		Plaintext       string     `json:"plaintext"`
+		CustomExcerpt   string     `json:"custom_excerpt"`
+		FeatureImage    string     `json:"feature_image"`
+		MetaTitle       string     `json:"meta_title"`
+		MetaDescription string     `json:"meta_description"`
+		Status          string     `json:"status"`
+		Type            string     `json:"type"` // post or page, since Ghost 4
+		Page            bool       `json:"page"` // Before Ghost 4
+		AuthorID        string     `json:"author_id"`
+		PublishedAt     *time.Time `json:"published_at"`
+		CreatedAt       *time.Time `json:"created_at"`
+	} `json:"posts"`
+	Users []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Slug  string `json:"slug"`
+		Email string `json:"email"`
+	} `json:"users"`
+	Tags []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"tags"`
+	PostsTags []struct {
+		PostID string `json:"post_id"`
+		TagID  string `json:"tag_id"`
+	} `json:"posts_tags"`
+	PostsAuthors []struct {
+		PostID   string `json:"post_id"`
+		AuthorID string `json:"author_id"`
+		Order    int    `json:"sort_order"`
+	} `json:"posts_authors"`
+}
+
+// ghostExport is a Ghost JSON export, whose content is wrapped in a "db"
+// list by the admin panel but not by the Admin API.
+type ghostExport struct {
+	DB []struct {
+		Data ghostData `json:"data"`
+	} `json:"db"`
+	Data *ghostData `json:"data"`
+}
+
+// parseGhost reads a Ghost JSON export. Pages are skipped, and posts that
+// aren't published are imported as drafts. Ghost exports hold no comments.
+func parseGhost(data []byte) (*Archive, error) {
+	var export ghostExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid Ghost export: %v", err)
+	}
+	content := export.Data
+	if content == nil {
+		if len(export.DB) == 0 {
+			return nil, ErrUnknownFormat
+		}
+		content = &export.DB[0].Data
+	}
+
+	archive := &Archive{Format: FormatGhost}
+	for _, user := range content.Users {
+		archive.Authors = append(archive.Authors, Author{
+			Key:      user.ID,
+			Username: user.Slug,
+			Email:    strings.TrimSpace(user.Email),
+			Name:     user.Name,
+		})
+	}
+
+	tagNames := make(map[string]string, len(content.Tags))
+	for _, tag := range content.Tags {
+		// Internal tags organize the site, not the posts
+		if !strings.HasPrefix(tag.Name, "#") {
+			tagNames[tag.ID] = tag.Name
+		}
+	}
+	tags := make(map[string][]string)
+	for _, postTag := range content.PostsTags {
+		if name, ok := tagNames[postTag.TagID]; ok {
+			tags[postTag.PostID] = append(tags[postTag.PostID], name)
+		}
+	}
+	// The primary author comes first
+	authors := make(map[string]string)
+	orders := make(map[string]int)
+	for _, postAuthor := range content.PostsAuthors {
+		if order, ok := orders[postAuthor.PostID]; !ok || postAuthor.Order < order {
+			authors[postAuthor.PostID] = postAuthor.AuthorID
+			orders[postAuthor.PostID] = postAuthor.Order
+		}
+	}
+
+	for _, p := range content.Posts {
+		if p.Type == "page" || p.Page {
+			continue
+		}
+
+		post := Post{
+			Title:           strings.TrimSpace(p.Title),
+			Slug:            p.Slug,
+			Content:         strings.TrimSpace(p.HTML),
+			Excerpt:         p.CustomExcerpt,
+			Status:          "draft",
+			AuthorKey:       p.AuthorID,
+			Tags:            tags[p.ID],
+			FeaturedImage:   p.FeatureImage,
+			MetaTitle:       p.MetaTitle,
+			MetaDescription: p.MetaDescription,
+		}
+		if post.Content == "" {
+			post.Content = strings.TrimSpace(p.Plaintext)
+		}
+		if p.Status == "published" {
+			post.Status = "published"
+		}
+		if author, ok := authors[p.ID]; ok {
+			post.AuthorKey = author
+		}
+		switch {
+		case p.PublishedAt != nil:
+			post.PublishedAt = *p.PublishedAt
+		case p.CreatedAt != nil:
+			post.PublishedAt = *p.CreatedAt
+		}
+		archive.Posts = append(archive.Posts, post)
+	}
+	return archive, nil
+}
@@ -0,0 +1,121 @@
+// Package importer reads the exports of other blogging platforms: WordPress
+// WXR exports, Ghost JSON exports and zip archives of Markdown files with
+// frontmatter.
+//
+// Exports are parsed into an Archive, which holds the posts, their comments
+// and their authors in a form independent of the platform. Storing them is
+// left to the caller.
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// Formats of the exports
+const (
+	FormatWordPress = "wordpress"
+	FormatGhost     = "ghost"
+	FormatMarkdown  = "markdown"
+)
+
+// ErrUnknownFormat is returned when an export is in none of the supported
+// formats.
+var ErrUnknownFormat = errors.New("unknown export format, expected a WordPress WXR export, a Ghost JSON export or a zip of Markdown files")
+
+// Archive is the content of an export.
+type Archive struct {
+	Format  string
+	Authors []Author
+	Posts   []Post
+}
+
+// Author is an author of posts.
+type Author struct {
+	Key      string // Identifier of the author in the export
+	Username string
+	Email    string // Empty when the export doesn't have it
+	Name     string
+}
+
+// Post is a post of an export.
+type Post struct {
+	Title           string
+	Slug            string // Empty when the export doesn't have one
+	Content         string // Markdown, or HTML for the platforms storing it
+	Excerpt         string
+	Status          string // draft or published
+	PublishedAt     time.Time
+	AuthorKey       string // Key of the author, empty when unknown
+	Tags            []string
+	FeaturedImage   string
+	MetaTitle       string
+	MetaDescription string
+	Comments        []Comment // Parents come before their replies
+}
+
+// Comment is a comment of a post.
+type Comment struct {
+	Key         string // Identifier of the comment in the export
+	ParentKey   string // Key of the comment replied to, empty for top-level comments
+	AuthorName  string
+	AuthorEmail string
+	Content     string
+	Approved    bool
+	CreatedAt   time.Time
+}
+
+// Parse reads an export, whose format is detected from its content.
+func Parse(data []byte) (*Archive, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return parseMarkdown(data)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return parseGhost(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return parseWordPress(trimmed)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// orderComments orders comments so parents come before their replies.
+// Comments replying to a comment missing from the export become top-level
+// comments.
+func orderComments(comments []Comment) []Comment {
+	keys := make(map[string]bool, len(comments))
+	for _, comment := range comments {
+		keys[comment.Key] = true
+	}
+
+	ordered := make([]Comment, 0, len(comments))
+	placed := make(map[string]bool, len(comments))
+	for len(ordered) < len(comments) {
+		progress := false
+		for _, comment := range comments {
+			if placed[comment.Key] {
+				continue
+			}
+			if comment.ParentKey != "" && (!keys[comment.ParentKey] || comment.ParentKey == comment.Key) {
+				comment.ParentKey = ""
+			}
+			if comment.ParentKey == "" || placed[comment.ParentKey] {
+				ordered = append(ordered, comment)
+				placed[comment.Key] = true
+				progress = true
+			}
+		}
+		if !progress {
+			// Replies in a cycle: the rest become top-level comments
+			for _, comment := range comments {
+				if !placed[comment.Key] {
+					comment.ParentKey = ""
+					ordered = append(ordered, comment)
+					placed[comment.Key] = true
+				}
+			}
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,203 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxMarkdownFile is the largest Markdown file read from an archive, so a
+// small archive can't unpack to an unbounded size
+const maxMarkdownFile = 10 << 20
+
+// frontmatterDates are the layouts of the dates of frontmatters
+var frontmatterDates = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// frontmatter is the metadata of a Markdown file, with the names used by
+// the common static site generators.
+type frontmatter struct {
+	Title           string     `yaml:"title"`
+	Slug            string     `yaml:"slug"`
+	Date            string     `yaml:"date"`
+	Status          string     `yaml:"status"`
+	Draft           bool       `yaml:"draft"`
+	Published       *bool      `yaml:"published"`
+	Tags            stringList `yaml:"tags"`
+	Categories      stringList `yaml:"categories"`
+	Excerpt         string     `yaml:"excerpt"`
+	Description     string     `yaml:"description"`
+	Summary         string     `yaml:"summary"`
+	Author          string     `yaml:"author"` // Name or email address
+	AuthorEmail     string     `yaml:"author_email"`
+	Image           string     `yaml:"image"`
+	FeaturedImage   string     `yaml:"featured_image"`
+	Cover           string     `yaml:"cover"`
+	MetaTitle       string     `yaml:"meta_title"`
+	MetaDescription string     `yaml:"meta_description"`
+}
+
+// stringList is a list of strings, written as a YAML list or as a comma
+// separated string.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = nil
+		for _, item := range strings.Split(value.Value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*l = append(*l, item)
+			}
+		}
+		return nil
+	}
+	var items []string
+	if err := value.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// parseMarkdown reads a zip archive of Markdown files, each one a post.
+// Frontmatters are optional: the slug defaults to the file name, and the
+// title to the first heading. Posts are published unless marked as drafts.
+func parseMarkdown(data []byte) (*Archive, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %v", err)
+	}
+
+	files := make([]*zip.File, 0, len(archive.File))
+	for _, file := range archive.File {
+		ext := strings.ToLower(path.Ext(file.Name))
+		if file.FileInfo().IsDir() || (ext != ".md" && ext != ".markdown") ||
+			strings.HasPrefix(file.Name, "__MACOSX/") || strings.HasPrefix(path.Base(file.Name), ".") {
+			continue
+		}
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Markdown files in the zip archive")
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	result := &Archive{Format: FormatMarkdown}
+	authors := make(map[string]bool)
+	for _, file := range files {
+		source, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+		post, author, err := markdownPost(file.Name, source)
+		if err != nil {
+			return nil, err
+		}
+		if author != nil && !authors[author.Key] {
+			authors[author.Key] = true
+			result.Authors = append(result.Authors, *author)
+		}
+		result.Posts = append(result.Posts, post)
+	}
+	return result, nil
+}
+
+// readZipFile returns the content of a file of a zip archive.
+func readZipFile(file *zip.File) (string, error) {
+	r, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", file.Name, err)
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(io.LimitReader(r, maxMarkdownFile+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", file.Name, err)
+	}
+	if len(content) > maxMarkdownFile {
+		return "", fmt.Errorf("%s is larger than %d MB", file.Name, maxMarkdownFile>>20)
+	}
+	return string(content), nil
+}
+
+// markdownPost returns the post of a Markdown file, along with its author
+// when the frontmatter names one.
+func markdownPost(name, source string) (Post, *Author, error) {
+	source = strings.ReplaceAll(strings.TrimPrefix(source, "\ufeff"), "\r\n", "\n")
+
+	// Split the frontmatter from the content
+	var meta frontmatter
+	if rest, ok := strings.CutPrefix(source, "---\n"); ok {
+		header, body, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			header, body, found = strings.Cut(rest, "\n---")
+		}
+		if found {
+			if err := yaml.Unmarshal([]byte(header), &meta); err != nil {
+				return Post{}, nil, fmt.Errorf("invalid frontmatter in %s: %v", name, err)
+			}
+			source = body
+		}
+	}
+	content := strings.TrimSpace(source)
+
+	post := Post{
+		Title:           strings.TrimSpace(meta.Title),
+		Slug:            meta.Slug,
+		Content:         content,
+		Excerpt:         firstOf(meta.Excerpt, meta.Description, meta.Summary),
+		Status:          "published",
+		Tags:            append(meta.Tags, meta.Categories...),
+		FeaturedImage:   firstOf(meta.FeaturedImage, meta.Image, meta.Cover),
+		MetaTitle:       meta.MetaTitle,
+		MetaDescription: meta.MetaDescription,
+	}
+	if post.Slug == "" {
+		post.Slug = strings.TrimSuffix(path.Base(name), path.Ext(name))
+	}
+	if post.Title == "" {
+		// Use the first heading, which is kept out of the content
+		if heading, body, _ := strings.Cut(content, "\n"); strings.HasPrefix(heading, "# ") {
+			post.Title = strings.TrimSpace(strings.TrimPrefix(heading, "# "))
+			post.Content = strings.TrimSpace(body)
+		}
+	}
+	if meta.Draft || (meta.Published != nil && !*meta.Published) || (meta.Status != "" && meta.Status != "published" && meta.Status != "publish") {
+		post.Status = "draft"
+	}
+	for _, layout := range frontmatterDates {
+		if t, err := time.Parse(layout, strings.TrimSpace(meta.Date)); err == nil {
+			post.PublishedAt = t
+			break
+		}
+	}
+
+	// Authors are told apart by their email address, or their name
+	email := strings.TrimSpace(meta.AuthorEmail)
+	authorName := strings.TrimSpace(meta.Author)
+	if email == "" && strings.Contains(authorName, "@") {
+		email, authorName = authorName, ""
+	}
+	if email == "" && authorName == "" {
+		return post, nil, nil
+	}
+	author := &Author{Key: firstOf(email, authorName), Email: email, Name: authorName}
+	post.AuthorKey = author.Key
+	return post, author, nil
+}
+
+// firstOf returns the first of the values that isn't empty.
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
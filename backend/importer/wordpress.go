@@ -0,0 +1,160 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// wordPressDate is the layout of the dates of WXR exports, which hold
+// "0000-00-00 00:00:00" when unset.
+const wordPressDate = "2006-01-02 15:04:05"
+
+// wxr is a WordPress eXtended RSS export. Elements are matched by their
+// local name, as the namespaces change with the version of the format.
+type wxr struct {
+	Channel struct {
+		Authors []struct {
+			Login       string `xml:"author_login"`
+			Email       string `xml:"author_email"`
+			DisplayName string `xml:"author_display_name"`
+		} `xml:"author"`
+		Items []wxrItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// wxrItem is a post, a page or an attachment of a WXR export.
+type wxrItem struct {
+	Title   string `xml:"title"`
+	Creator string `xml:"creator"`
+	// content:encoded and excerpt:encoded, told apart by their namespace
+	Encoded []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:"encoded"`
+	PostDateGMT string `xml:"post_date_gmt"`
+	PostName    string `xml:"post_name"`
+	Status      string `xml:"status"`
+	PostType    string `xml:"post_type"`
+	Categories  []struct {
+		Domain   string `xml:"domain,attr"`
+		Nicename string `xml:"nicename,attr"`
+		Name     string `xml:",chardata"`
+	} `xml:"category"`
+	Meta []struct {
+		Key   string `xml:"meta_key"`
+		Value string `xml:"meta_value"`
+	} `xml:"postmeta"`
+	Comments []struct {
+		ID          string `xml:"comment_id"`
+		Author      string `xml:"comment_author"`
+		AuthorEmail string `xml:"comment_author_email"`
+		DateGMT     string `xml:"comment_date_gmt"`
+		Content     string `xml:"comment_content"`
+		Approved    string `xml:"comment_approved"`
+		Type        string `xml:"comment_type"`
+		Parent      string `xml:"comment_parent"`
+	} `xml:"comment"`
+}
+
+// parseWordPress reads a WXR export. Only posts are imported: pages,
+// attachments and trashed posts are skipped, as are pingbacks and the
+// comments marked as spam or trashed. Posts that aren't published are
+// imported as drafts.
+func parseWordPress(data []byte) (*Archive, error) {
+	var export wxr
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	if err := decoder.Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid WordPress export: %v", err)
+	}
+
+	archive := &Archive{Format: FormatWordPress}
+	for _, author := range export.Channel.Authors {
+		archive.Authors = append(archive.Authors, Author{
+			Key:      author.Login,
+			Username: author.Login,
+			Email:    strings.TrimSpace(author.Email),
+			Name:     author.DisplayName,
+		})
+	}
+
+	for _, item := range export.Channel.Items {
+		if item.PostType != "post" || item.Status == "trash" || item.Status == "auto-draft" {
+			continue
+		}
+
+		post := Post{
+			Title:       strings.TrimSpace(item.Title),
+			Slug:        item.PostName,
+			Status:      "draft",
+			PublishedAt: wordPressTime(item.PostDateGMT),
+			AuthorKey:   item.Creator,
+		}
+		if slug, err := url.PathUnescape(item.PostName); err == nil {
+			post.Slug = slug
+		}
+		if item.Status == "publish" {
+			post.Status = "published"
+		}
+		for _, encoded := range item.Encoded {
+			switch {
+			case strings.Contains(encoded.XMLName.Space, "excerpt"):
+				post.Excerpt = strings.TrimSpace(encoded.Value)
+			case strings.Contains(encoded.XMLName.Space, "content"):
+				post.Content = strings.TrimSpace(encoded.Value)
+			}
+		}
+		for _, category := range item.Categories {
+			// Posts without a category are in the default one
+			if category.Domain == "post_tag" || (category.Domain == "category" && category.Nicename != "uncategorized") {
+				post.Tags = append(post.Tags, strings.TrimSpace(category.Name))
+			}
+		}
+		for _, meta := range item.Meta {
+			// Set by the Yoast SEO plugin
+			switch meta.Key {
+			case "_yoast_wpseo_title":
+				post.MetaTitle = meta.Value
+			case "_yoast_wpseo_metadesc":
+				post.MetaDescription = meta.Value
+			}
+		}
+
+		for _, comment := range item.Comments {
+			if comment.Type == "pingback" || comment.Type == "trackback" || (comment.Approved != "1" && comment.Approved != "0") {
+				continue
+			}
+			parent := comment.Parent
+			if parent == "0" {
+				parent = ""
+			}
+			post.Comments = append(post.Comments, Comment{
+				Key:         comment.ID,
+				ParentKey:   parent,
+				AuthorName:  comment.Author,
+				AuthorEmail: strings.TrimSpace(comment.AuthorEmail),
+				Content:     comment.Content,
+				Approved:    comment.Approved == "1",
+				CreatedAt:   wordPressTime(comment.DateGMT),
+			})
+		}
+		post.Comments = orderComments(post.Comments)
+		archive.Posts = append(archive.Posts, post)
+	}
+	return archive, nil
+}
+
+// wordPressTime parses a date of a WXR export, returning the zero time when
+// it is unset or invalid.
+func wordPressTime(value string) time.Time {
+	t, err := time.Parse(wordPressDate, strings.TrimSpace(value))
+	if err != nil || t.Year() < 1 {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,187 @@
+// Package jwtkeys holds the keys signing and verifying the JSON Web Tokens
+// issued by the API.
+//
+// Tokens are signed with HS256 and the "jwt.secret" setting, unless
+// asymmetric keys are configured in "jwt.keys": the first key then signs
+// tokens, with RS256 or EdDSA depending on its type, and its ID in the "kid"
+// header. The other keys only verify the tokens they signed, which lets keys
+// be rotated without logging users out. The public keys are published as a
+// JSON Web Key Set, for other services to verify tokens.
+//
+// Tokens without a key ID are verified with the secret. Once keys are
+// configured, they are only accepted until "jwt.secret_valid_until", so the
+// ones issued before can stay valid until they expire, but the secret
+// doesn't sign valid tokens forever.
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	// ErrUnknownKey is returned for tokens signed with a key that isn't
+	// configured, or no longer is.
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrUnexpectedMethod is returned for tokens signed with another
+	// algorithm than the one of their key.
+	ErrUnexpectedMethod = errors.New("unexpected signing method")
+	// ErrSecretRetired is returned for tokens signed with the secret once
+	// keys are configured, after "jwt.secret_valid_until".
+	ErrSecretRetired = errors.New("tokens signed with the secret are no longer accepted")
+)
+
+// Key is an asymmetric signing key.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// KeySet is the set of keys tokens are signed and verified with.
+type KeySet struct {
+	secret      []byte
+	secretUntil time.Time // Tokens signed with the secret are rejected after it once keys are configured
+	signing     *Key
+	keys        []*Key
+	byID        map[string]*Key
+}
+
+// current is the key set loaded at startup
+var current atomic.Pointer[KeySet]
+
+// Set sets the key set returned by Current.
+func Set(ks *KeySet) {
+	current.Store(ks)
+}
+
+// Current returns the key set set with Set, or the one of the configured
+// secret, without keys, before it is.
+func Current() *KeySet {
+	if ks := current.Load(); ks != nil {
+		return ks
+	}
+	return &KeySet{secret: []byte(config.Get().JWT.Secret), byID: map[string]*Key{}}
+}
+
+// Load reads the keys configured by the "jwt" settings.
+func Load() (*KeySet, error) {
+	cfg := config.Get().JWT
+	ks := &KeySet{
+		secret: []byte(cfg.Secret),
+		byID:   make(map[string]*Key, len(cfg.Keys)),
+	}
+	if cfg.SecretValidUntil != "" {
+		until, err := time.Parse(time.RFC3339, cfg.SecretValidUntil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwt.secret_valid_until: %w", err)
+		}
+		ks.secretUntil = until
+	}
+
+	for _, settings := range cfg.Keys {
+		if _, ok := ks.byID[settings.ID]; ok {
+			return nil, fmt.Errorf("duplicate JWT key ID %q", settings.ID)
+		}
+		data, err := os.ReadFile(settings.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT key %q: %w", settings.ID, err)
+		}
+		key, err := parseKey(settings.ID, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT key %q: %w", settings.ID, err)
+		}
+		ks.keys = append(ks.keys, key)
+		ks.byID[key.ID] = key
+	}
+	if len(ks.keys) > 0 {
+		ks.signing = ks.keys[0]
+	}
+	return ks, nil
+}
+
+// parseKey parses a PEM encoded RSA or Ed25519 private key, in PKCS #8 or,
+// for RSA, PKCS #1 form.
+func parseKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		var pkcs1Err error
+		if private, pkcs1Err = x509.ParsePKCS1PrivateKey(block.Bytes); pkcs1Err != nil {
+			return nil, err
+		}
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, private: private, public: &private.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, private: private, public: private.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use an RSA or Ed25519 key", private)
+	}
+}
+
+// Sign signs claims with the signing key, or the secret when no key is
+// configured.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.signing == nil {
+		if len(ks.secret) == 0 {
+			return "", errors.New("JWT secret is not configured")
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.secret)
+	}
+
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.private)
+}
+
+// Keyfunc returns the key verifying a token, for jwt.Parse: the key of its
+// key ID, or the secret for tokens without one, as long as the secret is
+// accepted. The algorithm of the token must be the one of the key, so a
+// public key is never used as an HMAC secret.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if id, ok := token.Header["kid"].(string); ok {
+		key, ok := ks.byID[id]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, ErrUnexpectedMethod
+		}
+		return key.public, nil
+	}
+
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || len(ks.secret) == 0 {
+		return nil, ErrUnexpectedMethod
+	}
+	if len(ks.keys) > 0 && !time.Now().Before(ks.secretUntil) {
+		return nil, ErrSecretRetired
+	}
+	return ks.secret, nil
+}
+
+// Keys returns the asymmetric keys, the signing key first.
+func (ks *KeySet) Keys() []*Key {
+	return ks.keys
+}
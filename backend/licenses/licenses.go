@@ -0,0 +1,49 @@
+package licenses
+
+// AllRightsReserved is the identifier used when content may not be reused.
+const AllRightsReserved = "all-rights-reserved"
+
+// License describes the reuse terms of a piece of content.
+type License struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// known lists the supported licenses by identifier. Creative Commons
+// identifiers follow SPDX.
+var known = map[string]License{
+	AllRightsReserved: {ID: AllRightsReserved, Name: "All rights reserved"},
+	"CC0-1.0":         {ID: "CC0-1.0", Name: "CC0 1.0 Universal", URL: "https://creativecommons.org/publicdomain/zero/1.0/"},
+	"CC-BY-4.0":       {ID: "CC-BY-4.0", Name: "Creative Commons Attribution 4.0", URL: "https://creativecommons.org/licenses/by/4.0/"},
+	"CC-BY-SA-4.0":    {ID: "CC-BY-SA-4.0", Name: "Creative Commons Attribution-ShareAlike 4.0", URL: "https://creativecommons.org/licenses/by-sa/4.0/"},
+	"CC-BY-ND-4.0":    {ID: "CC-BY-ND-4.0", Name: "Creative Commons Attribution-NoDerivatives 4.0", URL: "https://creativecommons.org/licenses/by-nd/4.0/"},
+	"CC-BY-NC-4.0":    {ID: "CC-BY-NC-4.0", Name: "Creative Commons Attribution-NonCommercial 4.0", URL: "https://creativecommons.org/licenses/by-nc/4.0/"},
+	"CC-BY-NC-SA-4.0": {ID: "CC-BY-NC-SA-4.0", Name: "Creative Commons Attribution-NonCommercial-ShareAlike 4.0", URL: "https://creativecommons.org/licenses/by-nc-sa/4.0/"},
+	"CC-BY-NC-ND-4.0": {ID: "CC-BY-NC-ND-4.0", Name: "Creative Commons Attribution-NonCommercial-NoDerivatives 4.0", URL: "https://creativecommons.org/licenses/by-nc-nd/4.0/"},
+}
+
+// Lookup returns the license with the given identifier.
+func Lookup(id string) (License, bool) {
+	license, ok := known[id]
+	return license, ok
+}
+
+// Valid reports whether id identifies a supported license.
+func Valid(id string) bool {
+	_, ok := known[id]
+	return ok
+}
+
+// Resolve returns the license of a piece of content, falling back to the
+// site default when the content doesn't set one, and to all rights reserved
+// when neither is known.
+func Resolve(id, siteDefault string) License {
+	if license, ok := known[id]; ok {
+		return license
+	}
+	if license, ok := known[siteDefault]; ok {
+		return license
+	}
+	return known[AllRightsReserved]
+}
@@ -0,0 +1,102 @@
+// Package logging builds the application logger from the "logging"
+// configuration key: human-readable console lines for development, or
+// sampled JSON lines for production, written to the standard streams or to
+// files rotated by size.
+package logging
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/redact"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Formats of the log lines
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// level is the minimum level of the entries logged, shared by the loggers
+// built by New so it can change at runtime.
+var level = zap.NewAtomicLevel()
+
+// Level returns the minimum level of the entries logged, which can be read
+// and changed while the application runs.
+func Level() zap.AtomicLevel {
+	return level
+}
+
+// New returns the logger configured under the "logging" configuration key.
+// Its entries are redacted while "logging.redact" is on.
+func New() (*zap.Logger, error) {
+	cfg := config.Get().Logging
+
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+	sink, err := output(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		core    zapcore.Core
+		options []zap.Option
+	)
+	switch cfg.Format {
+	case FormatConsole:
+		encoderConfig := zap.NewDevelopmentEncoderConfig()
+		core = zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), sink, level)
+		options = []zap.Option{zap.Development(), zap.AddStacktrace(zapcore.WarnLevel)}
+	case FormatJSON:
+		encoderConfig := zap.NewProductionEncoderConfig()
+		encoderConfig.TimeKey = "timestamp"
+		encoderConfig.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+		core = zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), sink, level)
+		// Repeated entries are sampled, as zap's production logger does
+		if cfg.Sampling.Initial > 0 {
+			core = zapcore.NewSamplerWithOptions(core, time.Second, cfg.Sampling.Initial, cfg.Sampling.Thereafter)
+		}
+		options = []zap.Option{zap.AddStacktrace(zapcore.ErrorLevel)}
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	options = append(options, zap.AddCaller(), zap.ErrorOutput(zapcore.Lock(os.Stderr)))
+	return zap.New(redact.Core(core), options...), nil
+}
+
+// output returns the destination of the log lines: the standard streams
+// named "stdout" and "stderr", and files rotated as set under
+// "logging.rotation" for the other paths.
+func output(cfg config.LoggingConfig) (zapcore.WriteSyncer, error) {
+	if len(cfg.OutputPaths) == 0 {
+		return nil, fmt.Errorf("logging.output_path must list at least one output")
+	}
+
+	sinks := make([]zapcore.WriteSyncer, 0, len(cfg.OutputPaths))
+	for _, path := range cfg.OutputPaths {
+		switch path {
+		case "stdout":
+			sinks = append(sinks, zapcore.Lock(os.Stdout))
+		case "stderr":
+			sinks = append(sinks, zapcore.Lock(os.Stderr))
+		default:
+			sinks = append(sinks, zapcore.AddSync(&lumberjack.Logger{
+				Filename:   path,
+				MaxSize:    cfg.Rotation.MaxSizeMB,
+				MaxBackups: cfg.Rotation.MaxBackups,
+				MaxAge:     cfg.Rotation.MaxAgeDays,
+				Compress:   cfg.Rotation.Compress,
+			}))
+		}
+	}
+	return zapcore.NewMultiWriteSyncer(sinks...), nil
+}
@@ -0,0 +1,16 @@
+package mailer
+
+import (
+	"context"
+)
+
+// Discard is a Mailer that drops every message, used for requests whose side
+// effects must not leave the server, such as sandbox requests.
+var Discard Mailer = discardMailer{}
+
+type discardMailer struct{}
+
+// Send drops the message.
+func (discardMailer) Send(ctx context.Context, msg Message) error {
+	return nil
+}
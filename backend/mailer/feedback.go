@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/httpclient"
+)
+
+// Kinds of feedback
+const (
+	// FeedbackBounce reports an address that can't receive emails
+	FeedbackBounce = "bounce"
+	// FeedbackComplaint reports a recipient who marked an email as spam
+	FeedbackComplaint = "complaint"
+)
+
+// snsHost matches the hosts of the Amazon SNS endpoints
+var snsHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// Feedback reports that an address can't, or must not, receive emails
+// anymore, as notified by the email provider.
+type Feedback struct {
+	Email  string
+	Kind   string
+	Detail string // Diagnostic given by the provider, if any
+}
+
+// SNSMessage is a message posted by Amazon SNS to an HTTP subscription, as
+// SES notifications are.
+type SNSMessage struct {
+	Type         string `json:"Type"` // SubscriptionConfirmation, Notification or UnsubscribeConfirmation
+	MessageID    string `json:"MessageId"`
+	TopicArn     string `json:"TopicArn"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+// ParseSES returns the feedback reported by an SES bounce or complaint
+// notification, the Message of an SNS notification. Transient bounces,
+// such as a full mailbox, and other notifications are ignored.
+func ParseSES(message string) ([]Feedback, error) {
+	var notification struct {
+		NotificationType string `json:"notificationType"`
+		EventType        string `json:"eventType"` // Set instead by configuration set events
+		Bounce           struct {
+			BounceType        string `json:"bounceType"`
+			BounceSubType     string `json:"bounceSubType"`
+			BouncedRecipients []struct {
+				EmailAddress   string `json:"emailAddress"`
+				DiagnosticCode string `json:"diagnosticCode"`
+			} `json:"bouncedRecipients"`
+		} `json:"bounce"`
+		Complaint struct {
+			ComplaintFeedbackType string `json:"complaintFeedbackType"`
+			ComplainedRecipients  []struct {
+				EmailAddress string `json:"emailAddress"`
+			} `json:"complainedRecipients"`
+		} `json:"complaint"`
+	}
+	if err := json.Unmarshal([]byte(message), &notification); err != nil {
+		return nil, fmt.Errorf("invalid SES notification: %v", err)
+	}
+
+	var feedback []Feedback
+	notificationType := notification.NotificationType
+	if notificationType == "" {
+		notificationType = notification.EventType
+	}
+	switch notificationType {
+	case "Bounce":
+		if notification.Bounce.BounceType != "Permanent" {
+			return nil, nil
+		}
+		for _, recipient := range notification.Bounce.BouncedRecipients {
+			detail := recipient.DiagnosticCode
+			if detail == "" {
+				detail = notification.Bounce.BounceSubType
+			}
+			feedback = append(feedback, Feedback{Email: recipient.EmailAddress, Kind: FeedbackBounce, Detail: detail})
+		}
+	case "Complaint":
+		for _, recipient := range notification.Complaint.ComplainedRecipients {
+			feedback = append(feedback, Feedback{Email: recipient.EmailAddress, Kind: FeedbackComplaint, Detail: notification.Complaint.ComplaintFeedbackType})
+		}
+	}
+	return feedback, nil
+}
+
+// ParseSendGrid returns the feedback reported by a batch of SendGrid Event
+// Webhook events. Bounces blocked temporarily by the receiving server and
+// events other than bounces and spam reports are ignored.
+func ParseSendGrid(data []byte) ([]Feedback, error) {
+	var events []struct {
+		Email  string `json:"email"`
+		Event  string `json:"event"`
+		Type   string `json:"type"` // bounce or blocked, for bounce events
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("invalid SendGrid events: %v", err)
+	}
+
+	var feedback []Feedback
+	for _, event := range events {
+		switch {
+		case event.Event == "bounce" && event.Type != "blocked":
+			feedback = append(feedback, Feedback{Email: event.Email, Kind: FeedbackBounce, Detail: event.Reason})
+		case event.Event == "spamreport":
+			feedback = append(feedback, Feedback{Email: event.Email, Kind: FeedbackComplaint})
+		}
+	}
+	return feedback, nil
+}
+
+// ConfirmSNSSubscription confirms the subscription of the API to an SNS
+// topic, by visiting the SubscribeURL of the confirmation message. Only URLs
+// of the SNS endpoints are visited.
+func ConfirmSNSSubscription(ctx context.Context, subscribeURL string) error {
+	u, err := url.Parse(subscribeURL)
+	if err != nil || u.Scheme != "https" || !snsHost.MatchString(strings.ToLower(u.Hostname())) {
+		return errors.New("subscribe URL is not an Amazon SNS URL")
+	}
+
+	client := httpclient.New(httpclient.Options{
+		Name:    "sns",
+		Timeout: 10 * time.Second,
+		Ports:   []uint16{443},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("subscription confirmation returned status %d", resp.StatusCode)
+	}
+	return nil
+}
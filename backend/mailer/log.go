@@ -0,0 +1,27 @@
+package mailer
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// LogMailer writes emails to the logger instead of sending them.
+type LogMailer struct {
+	logger *zap.Logger
+}
+
+// NewLogMailer returns a new instance of LogMailer.
+func NewLogMailer(logger *zap.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+// Send logs the message.
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.logger.Info("Email not sent (logged instead)",
+		zap.Strings("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("text", msg.Text),
+	)
+	return nil
+}
@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/SteaceP/coderage/config"
+
+	"go.uber.org/zap"
+)
+
+// Message is an email to send. HTML is optional; when set, the message is
+// sent as multipart/alternative with Text as the plain text part.
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the Mailer of the provider configured under the "email"
+// configuration key, sending in the background through a Queue when
+// "email.queue.enabled" is set. The queue must then be closed on shutdown.
+//
+// When the provider is log, or smtp without an SMTP host, messages are
+// written to the logger instead of being sent, which is convenient during
+// development.
+func New(ctx context.Context, logger *zap.Logger) (Mailer, error) {
+	cfg := config.Get().Email
+
+	var m Mailer
+	switch {
+	case cfg.Provider == "sendgrid":
+		m = NewSendGridMailer(cfg.SendGrid.APIKey, cfg.SenderEmail)
+	case cfg.Provider == "ses":
+		ses, err := NewSESMailer(ctx, SESConfig{
+			Region:    cfg.SES.Region,
+			AccessKey: cfg.SES.AccessKey,
+			SecretKey: cfg.SES.SecretKey,
+			From:      cfg.SenderEmail,
+		})
+		if err != nil {
+			return nil, err
+		}
+		m = ses
+	case cfg.Provider == "log", cfg.SMTPHost == "":
+		return NewLogMailer(logger), nil
+	default:
+		m = NewSMTPMailer(SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SenderEmail,
+		})
+	}
+
+	if !cfg.Queue.Enabled {
+		return m, nil
+	}
+	return NewQueue(m, QueueOptions{
+		Workers:     cfg.Queue.Workers,
+		Size:        cfg.Queue.Size,
+		MaxAttempts: cfg.Queue.MaxAttempts,
+		Backoff:     cfg.Queue.Backoff,
+	}, logger), nil
+}
@@ -0,0 +1,156 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrQueueFull is returned when sending while the queue is full.
+	ErrQueueFull = errors.New("email queue is full")
+	// ErrQueueClosed is returned when sending once the queue is closed.
+	ErrQueueClosed = errors.New("email queue is closed")
+)
+
+// permanentError marks a failure that retrying won't fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that retrying won't fix, such as a
+// message the provider rejects, so the queue doesn't retry it.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// QueueOptions configures a Queue.
+type QueueOptions struct {
+	Workers     int
+	Size        int
+	MaxAttempts int
+	Backoff     time.Duration // Wait after the first failed attempt, doubled after every other
+}
+
+// Queue is a Mailer sending messages in the background through another
+// Mailer, so requests don't wait on the provider. Failed attempts are
+// retried with an exponential backoff, and messages still failing after the
+// last attempt are logged and dropped.
+type Queue struct {
+	next     Mailer
+	opts     QueueOptions
+	messages chan Message
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	logger   *zap.Logger
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewQueue returns a new instance of Queue sending through next, and starts
+// its workers.
+func NewQueue(next Mailer, opts QueueOptions, logger *zap.Logger) *Queue {
+	q := &Queue{
+		next:     next,
+		opts:     opts,
+		messages: make(chan Message, max(opts.Size, 1)),
+		stop:     make(chan struct{}),
+		logger:   logger,
+	}
+	for i := 0; i < max(opts.Workers, 1); i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Send queues the message. It only fails when the queue is full or closed.
+func (q *Queue) Send(ctx context.Context, msg Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.messages <- msg:
+		return nil
+	default:
+		q.logger.Error("Email dropped, the queue is full", zap.String("subject", msg.Subject))
+		return ErrQueueFull
+	}
+}
+
+// Pending returns the number of messages waiting to be sent.
+func (q *Queue) Pending() int {
+	return len(q.messages)
+}
+
+// Close stops accepting messages and waits for the queued ones to be sent.
+// Failed messages are then retried once, right away.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.messages)
+	close(q.stop)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+	return nil
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for msg := range q.messages {
+		q.deliver(msg)
+	}
+}
+
+// deliver sends a message, retrying failed attempts.
+func (q *Queue) deliver(msg Message) {
+	backoff := q.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		err := q.next.Send(context.Background(), msg)
+		if err == nil {
+			return
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= q.opts.MaxAttempts {
+			q.logger.Error("Failed to send email",
+				zap.String("subject", msg.Subject),
+				zap.Int("attempts", attempt),
+				zap.Error(err),
+			)
+			return
+		}
+		q.logger.Warn("Failed to send email, retrying",
+			zap.String("subject", msg.Subject),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-q.stop:
+			// Shutting down: a last attempt, right away
+			if err := q.next.Send(context.Background(), msg); err != nil {
+				q.logger.Error("Failed to send email", zap.String("subject", msg.Subject), zap.Error(err))
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/SteaceP/coderage/httpclient"
+)
+
+// sendGridURL is the endpoint of the SendGrid Mail Send API
+const sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGridMailer sends emails through the SendGrid Mail Send API.
+type SendGridMailer struct {
+	apiKey string
+	from   string
+	client *http.Client
+}
+
+// NewSendGridMailer returns a new instance of SendGridMailer sending from
+// the given address with the given API key.
+func NewSendGridMailer(apiKey, from string) *SendGridMailer {
+	return &SendGridMailer{
+		apiKey: apiKey,
+		from:   from,
+		client: httpclient.New(httpclient.Options{
+			Name:    "sendgrid",
+			Timeout: 30 * time.Second,
+			Ports:   []uint16{443},
+		}),
+	}
+}
+
+// Send delivers the message through SendGrid. Requests SendGrid rejects as
+// invalid are permanent failures.
+func (m *SendGridMailer) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("message has no recipients")
+	}
+
+	type address struct {
+		Email string `json:"email"`
+	}
+	type content struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+	to := make([]address, len(msg.To))
+	for i, email := range msg.To {
+		to[i] = address{Email: email}
+	}
+	contents := []content{{Type: "text/plain", Value: msg.Text}}
+	if msg.HTML != "" {
+		contents = append(contents, content{Type: "text/html", Value: msg.HTML})
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"personalizations": []map[string]interface{}{{"to": to}},
+		"from":             address{Email: m.from},
+		"subject":          msg.Subject,
+		"content":          contents,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	defer resp.Body.Close()
+	return providerError("SendGrid", resp)
+}
+
+// providerError returns the error of an email provider response, if any.
+// Client errors other than rate limiting are permanent.
+func providerError(provider string, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		return nil
+	}
+
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	err := fmt.Errorf("failed to send email: %s returned status %d: %s", provider, resp.StatusCode, bytes.TrimSpace(detail))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
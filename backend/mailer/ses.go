@@ -0,0 +1,121 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SteaceP/coderage/httpclient"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// SESConfig holds the settings of Amazon SES.
+type SESConfig struct {
+	Region    string
+	AccessKey string // Optional, the default AWS credential chain is used otherwise
+	SecretKey string
+	From      string
+}
+
+// SESMailer sends emails through the SendEmail action of the Amazon SES v2
+// API.
+type SESMailer struct {
+	region      string
+	from        string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+// NewSESMailer returns a new instance of SESMailer, with the credentials
+// of the configuration or of the default AWS credential chain.
+func NewSESMailer(ctx context.Context, config SESConfig) (*SESMailer, error) {
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(config.Region),
+	}
+	if config.AccessKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(config.AccessKey, config.SecretKey, ""),
+		))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+	}
+
+	return &SESMailer{
+		region:      config.Region,
+		from:        config.From,
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		client: httpclient.New(httpclient.Options{
+			Name:    "ses",
+			Timeout: 30 * time.Second,
+			Ports:   []uint16{443},
+		}),
+	}, nil
+}
+
+// Send delivers the message through SES. Requests SES rejects as invalid,
+// such as from an unverified sender, are permanent failures.
+func (m *SESMailer) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return errors.New("message has no recipients")
+	}
+
+	type content struct {
+		Data    string `json:"Data"`
+		Charset string `json:"Charset"`
+	}
+	emailBody := map[string]content{"Text": {Data: msg.Text, Charset: "UTF-8"}}
+	if msg.HTML != "" {
+		emailBody["Html"] = content{Data: msg.HTML, Charset: "UTF-8"}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"FromEmailAddress": m.from,
+		"Destination":      map[string][]string{"ToAddresses": msg.To},
+		"Content": map[string]interface{}{
+			"Simple": map[string]interface{}{
+				"Subject": content{Data: msg.Subject, Charset: "UTF-8"},
+				"Body":    emailBody,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://email.%s.amazonaws.com/v2/email/outbound-emails", m.region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	creds, err := m.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %v", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := m.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "ses", m.region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign SES request: %v", err)
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	defer resp.Body.Close()
+	return providerError("SES", resp)
+}
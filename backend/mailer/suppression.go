@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Suppressions tells which addresses must not receive emails anymore, such
+// as addresses that bounced or complained.
+type Suppressions interface {
+	// Suppressed returns the suppressed addresses among addresses, lower
+	// cased.
+	Suppressed(addresses []string) ([]string, error)
+}
+
+// SuppressingMailer drops the suppressed recipients of the messages it sends
+// through the Mailer it wraps.
+type SuppressingMailer struct {
+	mailer       Mailer
+	suppressions Suppressions
+	logger       *zap.Logger
+}
+
+// NewSuppressingMailer returns a new instance of SuppressingMailer sending
+// through m, checking the recipients against suppressions.
+func NewSuppressingMailer(m Mailer, suppressions Suppressions, logger *zap.Logger) *SuppressingMailer {
+	return &SuppressingMailer{mailer: m, suppressions: suppressions, logger: logger}
+}
+
+// Send sends the message to its recipients that aren't suppressed. A message
+// left without recipients is dropped. When the suppressions can't be
+// checked, the message is sent to every recipient.
+func (m *SuppressingMailer) Send(ctx context.Context, msg Message) error {
+	suppressed, err := m.suppressions.Suppressed(msg.To)
+	if err != nil {
+		m.logger.Error("Failed to check email suppressions", zap.Error(err))
+		return m.mailer.Send(ctx, msg)
+	}
+	if len(suppressed) == 0 {
+		return m.mailer.Send(ctx, msg)
+	}
+
+	to := make([]string, 0, len(msg.To))
+	for _, address := range msg.To {
+		if !slices.Contains(suppressed, strings.ToLower(address)) {
+			to = append(to, address)
+		}
+	}
+	m.logger.Info("Email not sent to suppressed addresses",
+		zap.Strings("suppressed", suppressed),
+		zap.String("subject", msg.Subject),
+	)
+	if len(to) == 0 {
+		return nil
+	}
+	msg.To = to
+	return m.mailer.Send(ctx, msg)
+}
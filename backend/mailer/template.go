@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/SteaceP/coderage/config"
+)
+
+// Templates of the emails sent by the API
+const (
+	TemplateVerification = "verification"
+	TemplateDigest       = "digest"
+)
+
+// defaultColor is the color of the emails of sites without a primary color
+const defaultColor = "#2563eb"
+
+// templateFS holds the templates. Each email has a text template, which
+// defines its "subject", and an HTML template, which defines the "content"
+// of the layout.
+//
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	textTemplates = make(map[string]*texttemplate.Template)
+	htmlTemplates = make(map[string]*htmltemplate.Template)
+)
+
+func init() {
+	funcs := htmltemplate.FuncMap{
+		"button": func(url, label, color string) map[string]string {
+			return map[string]string{"URL": url, "Label": label, "Color": color}
+		},
+	}
+	for _, name := range []string{TemplateVerification, TemplateDigest} {
+		textTemplates[name] = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/"+name+".txt"))
+		htmlTemplates[name] = htmltemplate.Must(htmltemplate.New(name).Funcs(funcs).ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html"))
+	}
+}
+
+// TemplateSite describes the site in the templates, as .Site.
+type TemplateSite struct {
+	Title string
+	URL   string
+	Color string
+}
+
+// templateData is the data templates are executed with, the data of the
+// email being .Data.
+type templateData struct {
+	Site TemplateSite
+	Data interface{}
+}
+
+// Render returns the message of a template for the given recipients,
+// with both a text and an HTML body.
+func Render(name string, to []string, data interface{}) (Message, error) {
+	text, ok := textTemplates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	cfg := config.Get().Site
+	td := templateData{
+		Site: TemplateSite{Title: cfg.Title, URL: cfg.URL, Color: cfg.PrimaryColor},
+		Data: data,
+	}
+	if td.Site.Color == "" {
+		td.Site.Color = defaultColor
+	}
+
+	var subject, body, html bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", td); err != nil {
+		return Message{}, fmt.Errorf("failed to render email subject: %v", err)
+	}
+	if err := text.Execute(&body, td); err != nil {
+		return Message{}, fmt.Errorf("failed to render email: %v", err)
+	}
+	if err := htmlTemplates[name].ExecuteTemplate(&html, "layout", td); err != nil {
+		return Message{}, fmt.Errorf("failed to render email: %v", err)
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    body.String(),
+		HTML:    html.String(),
+	}, nil
+}
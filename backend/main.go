@@ -2,45 +2,169 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Time zones of the site, on hosts without a zoneinfo database
 
+	"github.com/SteaceP/coderage/alerts"
+	"github.com/SteaceP/coderage/apperrors"
 	"github.com/SteaceP/coderage/config"
 	"github.com/SteaceP/coderage/database"
+	"github.com/SteaceP/coderage/demo"
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/geoip"
 	"github.com/SteaceP/coderage/handlers"
+	"github.com/SteaceP/coderage/i18n"
+	"github.com/SteaceP/coderage/jwtkeys"
+	"github.com/SteaceP/coderage/logging"
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/metrics"
 	"github.com/SteaceP/coderage/middleware"
+	"github.com/SteaceP/coderage/openapi"
+	"github.com/SteaceP/coderage/presence"
+	"github.com/SteaceP/coderage/ratelimit"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/search"
+	"github.com/SteaceP/coderage/securitylog"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/spam"
+	"github.com/SteaceP/coderage/storage"
+	"github.com/SteaceP/coderage/tlsserver"
+	"github.com/SteaceP/coderage/tracing"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/webhooks"
 
 	"github.com/gorilla/mux"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 type Server struct {
-	router *mux.Router
-	db     *gorm.DB
-	logger *zap.Logger
+	router   *mux.Router
+	db       *gorm.DB
+	bus      events.Bus
+	mailer   mailer.Mailer
+	storage  storage.Storage
+	policy   *ratelimit.Policy
+	services *services.Services
+	metrics  *metrics.Metrics
+	breaker  *database.Breaker
+	readOnly *database.ReadOnly
+	presence *presence.Hub
+	logger   *zap.Logger
 }
 
+const usage = `Usage:
+  api [serve] [-demo]                     Run the API
+  api migrate [up]                        Migrate the database schema
+  api create-admin -email address [...]   Create an admin account, or promote an existing one
+  api seed [-fake ...]                    Create the sample content of the demo mode, or fake content
+  api config print-schema|validate        Describe or validate the configuration
+  api storage ...                         Manage the uploaded files
+
+Run a command with -h for its options.
+`
+
 func main() {
-	// Load configuration
-	config.InitConfig()
+	flag.Usage = func() { fmt.Fprint(flag.CommandLine.Output(), usage) }
+	demoMode := flag.Bool("demo", false, "run with an in-memory database seeded with sample content, reset periodically, and without sending emails or webhooks")
+	flag.Parse()
+
+	// Run a subcommand instead of the API, e.g. "config validate"
+	switch flag.Arg(0) {
+	case "", "serve":
+	case "config":
+		os.Exit(runConfigCommand(flag.Args()[1:], os.Stdout, os.Stderr))
+	case "storage":
+		os.Exit(runStorageCommand(flag.Args()[1:], os.Stdout, os.Stderr))
+	case "migrate":
+		os.Exit(runMigrateCommand(flag.Args()[1:], os.Stdout, os.Stderr))
+	case "create-admin":
+		os.Exit(runCreateAdminCommand(flag.Args()[1:], os.Stdout, os.Stderr))
+	case "seed":
+		os.Exit(runSeedCommand(flag.Args()[1:], os.Stdout, os.Stderr))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", flag.Arg(0), usage)
+		os.Exit(2)
+	}
+	if flag.Arg(0) == "serve" {
+		flags := flag.NewFlagSet("serve", flag.ExitOnError)
+		flags.BoolVar(demoMode, "demo", *demoMode, "run with an in-memory database seeded with sample content, reset periodically, and without sending emails or webhooks")
+		flags.Parse(flag.Args()[1:])
+	}
 
-	// Initialize logger
-	logger, err := zap.NewDevelopment()
+	// Load configuration, and connect to the database
+	cfg, logger, db, err := bootstrap(*demoMode)
 	if err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
+		log.Fatalf("Startup failed: %v", err)
 	}
 	defer logger.Sync()
 
-	// Initialize database
-	db, err := database.InitDatabase()
+	// Apply changes to the configuration file without a restart
+	config.Watch(logger)
+
+	// Initialize security event logging
+	securityLogger, closeSecurityLog, err := securitylog.New()
+	if err != nil {
+		logger.Fatal("Security log initialization failed", zap.Error(err))
+	}
+	defer closeSecurityLog()
+	securitylog.SetLogger(securityLogger)
+
+	// Load the keys signing tokens
+	keys, err := jwtkeys.Load()
 	if err != nil {
-		logger.Fatal("Database initialization failed", zap.Error(err))
+		logger.Fatal("JWT key loading failed", zap.Error(err))
+	}
+	jwtkeys.Set(keys)
+
+	// Trace the requests and their queries
+	if cfg.Tracing.Enabled {
+		shutdownTracing, err := tracing.Init(context.Background())
+		if err != nil {
+			logger.Fatal("Tracing initialization failed", zap.Error(err))
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				logger.Error("Tracing shutdown error", zap.Error(err))
+			}
+		}()
+
+		if err := db.Use(tracing.GormPlugin{}); err != nil {
+			logger.Fatal("Tracing initialization failed", zap.Error(err))
+		}
+	}
+
+	// Explain the queries of the requests asking for it
+	if cfg.Database.QueryPlans.Enabled {
+		if err := db.Use(database.QueryPlanner{}); err != nil {
+			logger.Fatal("Query plans initialization failed", zap.Error(err))
+		}
+	}
+
+	// Serve the reads of some routes from the read replicas
+	if len(cfg.Database.Replicas.Hosts) > 0 {
+		replicas, err := database.NewReplicas(db, logger)
+		if err != nil {
+			logger.Fatal("Read replicas initialization failed", zap.Error(err))
+		}
+		defer replicas.Close()
+		if err := db.Use(replicas.Plugin()); err != nil {
+			logger.Fatal("Read replicas initialization failed", zap.Error(err))
+		}
+		db = database.Primary(db)
+
+		checking, stopChecking := context.WithCancel(context.Background())
+		defer stopChecking()
+		go replicas.Run(checking)
 	}
 
 	// Run migrations
@@ -48,33 +172,235 @@ func main() {
 		logger.Fatal("Database migrations failed", zap.Error(err))
 	}
 
+	// Initialize event bus
+	bus, err := events.NewBus(logger)
+	if err != nil {
+		logger.Fatal("Event bus initialization failed", zap.Error(err))
+	}
+	defer bus.Close()
+
+	// Initialize the search engine, if any
+	engine, err := search.New()
+	if err != nil {
+		logger.Fatal("Search engine initialization failed", zap.Error(err))
+	}
+
+	// Initialize file storage
+	store, err := storage.New(context.Background())
+	if err != nil {
+		logger.Fatal("Storage initialization failed", zap.Error(err))
+	}
+
+	// Initialize rate limit policy
+	policy, err := ratelimit.NewPolicy()
+	if err != nil {
+		logger.Fatal("Rate limit policy initialization failed", zap.Error(err))
+	}
+
+	// Load the translation bundles of the site
+	if err := i18n.Load(cfg.I18n.BundlesDir); err != nil {
+		logger.Fatal("Translation bundles loading failed", zap.Error(err))
+	}
+
+	// Initialize GeoIP resolution
+	resolver, err := geoip.New()
+	if err != nil {
+		logger.Fatal("GeoIP initialization failed", zap.Error(err))
+	}
+	defer resolver.Close()
+
+	// Initialize email sending
+	provider, err := mailer.New(context.Background(), logger)
+	if err != nil {
+		logger.Fatal("Mailer initialization failed", zap.Error(err))
+	}
+	if queue, ok := provider.(*mailer.Queue); ok {
+		// Send the queued emails before exiting
+		defer queue.Close()
+	}
+
 	// Create server
+	// Emails are never sent to the addresses that bounced or complained
+	var m mailer.Mailer = mailer.NewSuppressingMailer(provider, repositories.NewEmailSuppressionRepository(db), logger)
+	var sender webhooks.Sender = webhooks.NewSender()
+	if cfg.Demo.Enabled {
+		m, sender = mailer.Discard, webhooks.Discard
+	}
 	server := &Server{
-		router: mux.NewRouter(),
-		db:     db,
-		logger: logger,
+		router:   mux.NewRouter(),
+		db:       db,
+		bus:      bus,
+		mailer:   m,
+		storage:  store,
+		policy:   policy,
+		services: services.New(db, m, sender, bus, engine, spam.New(logger), logger),
+		logger:   logger,
+	}
+
+	// Instrument the API for Prometheus
+	if cfg.Metrics.Enabled {
+		if server.metrics, err = metrics.New(db); err != nil {
+			logger.Fatal("Metrics initialization failed", zap.Error(err))
+		}
+		if err := db.Use(server.metrics); err != nil {
+			logger.Fatal("Metrics initialization failed", zap.Error(err))
+		}
+	}
+
+	// Turn requests away while the database is down, and recover the
+	// connection pool once it is back
+	if cfg.Database.Breaker.Enabled {
+		if server.breaker, err = database.NewBreaker(db, logger); err != nil {
+			logger.Fatal("Circuit breaker initialization failed", zap.Error(err))
+		}
+		if err := db.Use(server.breaker); err != nil {
+			logger.Fatal("Circuit breaker initialization failed", zap.Error(err))
+		}
+
+		checking, stopChecking := context.WithCancel(context.Background())
+		defer stopChecking()
+		go server.breaker.Run(checking)
+	}
+
+	// Turn writes away while the site is read-only
+	server.readOnly = database.NewReadOnly(db, logger)
+	polling, stopPolling := context.WithCancel(context.Background())
+	defer stopPolling()
+	go server.readOnly.Run(polling, cfg.ReadOnly.PollInterval)
+
+	// Count the live readers of posts
+	if cfg.Presence.Enabled {
+		readers, err := presence.NewStore(context.Background())
+		if err != nil {
+			logger.Fatal("Presence initialization failed", zap.Error(err))
+		}
+		defer readers.Close()
+		server.presence = presence.NewHub(readers, logger)
+	}
+
+	// Deliver events to webhooks
+	for _, eventType := range cfg.Webhooks.Events {
+		if err := bus.Subscribe(eventType, server.services.Webhooks.HandleEvent); err != nil {
+			logger.Fatal("Webhook subscription failed", zap.String("event_type", eventType), zap.Error(err))
+		}
+	}
+	retries, stopRetries := context.WithCancel(context.Background())
+	defer stopRetries()
+	go server.services.Webhooks.RunRetries(retries, cfg.Webhooks.Retries.Interval)
+
+	// Keep the index of the search engine up to date
+	if engine != nil {
+		for _, eventType := range services.SearchIndexEvents {
+			if err := bus.Subscribe(eventType, server.services.Search.HandleEvent); err != nil {
+				logger.Fatal("Search index subscription failed", zap.String("event_type", eventType), zap.Error(err))
+			}
+		}
+	}
+
+	// Notify users of the activity on their content, and email the digests
+	for _, eventType := range services.NotificationEvents {
+		if err := bus.Subscribe(eventType, server.services.Notifications.HandleEvent); err != nil {
+			logger.Fatal("Notification subscription failed", zap.String("event_type", eventType), zap.Error(err))
+		}
+	}
+	if cfg.Notifications.Digest.Enabled {
+		digests, stopDigests := context.WithCancel(context.Background())
+		defer stopDigests()
+		go server.services.Notifications.RunDigests(digests, cfg.Notifications.Digest.Interval)
+	}
+
+	// Refresh the leaderboards in the background
+	if cfg.Leaderboards.Enabled {
+		background, stopBackground := context.WithCancel(context.Background())
+		defer stopBackground()
+		go server.services.Leaderboards.Run(background, cfg.Leaderboards.RefreshInterval)
+	}
+
+	// Purge the deleted accounts in the background
+	if cfg.Accounts.DeletionGracePeriod > 0 {
+		purge, stopPurge := context.WithCancel(context.Background())
+		defer stopPurge()
+		go server.services.Accounts.Run(purge, cfg.Accounts.PurgeInterval)
+	}
+
+	// Seed the sample content of the demo, and restore it periodically
+	if cfg.Demo.Enabled {
+		sample := demo.New(db, server.services, logger)
+		if err := sample.Seed(); err != nil {
+			logger.Fatal("Demo initialization failed", zap.Error(err))
+		}
+		logger.Info("Running in demo mode",
+			zap.Strings("accounts", []string{demo.AdminEmail, demo.ReaderEmail}),
+			zap.Duration("reset_interval", cfg.Demo.ResetInterval),
+		)
+
+		if cfg.Demo.ResetInterval > 0 {
+			resetting, stopResetting := context.WithCancel(context.Background())
+			defer stopResetting()
+			go sample.Run(resetting, cfg.Demo.ResetInterval)
+		}
 	}
 
 	// Setup routes
 	server.setupRoutes()
 
 	// Configure CORS
-	corsHandler := middleware.ConfigureCORS().Handler(server.router)
+	var handler http.Handler = middleware.ConfigureCORS().Handler(server.router)
+
+	// Alert operators when the API misbehaves
+	if cfg.Alerts.Enabled {
+		var backlog alerts.BacklogFunc
+		if pending, ok := bus.(interface{ Pending() int64 }); ok {
+			backlog = pending.Pending
+		}
+		monitor := alerts.NewMonitor(alerts.NewNotifiers(m, webhooks.NewSender()), backlog, logger)
+		handler = middleware.Alerts(monitor)(handler)
+
+		monitoring, stopMonitoring := context.WithCancel(context.Background())
+		defer stopMonitoring()
+		go monitor.Run(monitoring)
+	}
 
 	// HTTP Server configuration
-	port := viper.GetString("server.port")
+	port := cfg.Server.Port
 	httpServer := &http.Server{
 		Addr:         ":" + port,
-		Handler:      middleware.LoggingMiddleware(logger)(corsHandler),
+		Handler:      middleware.RequestID(middleware.GeoIP(resolver)(middleware.LoggingMiddleware(logger)(handler))),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
+	if server.presence != nil {
+		// End the live streams, which would otherwise hold the shutdown
+		httpServer.RegisterOnShutdown(server.presence.Close)
+	}
+
+	// Serve HTTPS without a reverse proxy when enabled
+	tlsConfig, redirect, err := tlsserver.Config()
+	if err != nil {
+		logger.Fatal("TLS initialization failed", zap.Error(err))
+	}
+	httpServer.TLSConfig = tlsConfig
+	redirectServer := tlsserver.RedirectServer(redirect)
+	if redirectServer != nil {
+		go func() {
+			logger.Info("Redirecting HTTP to HTTPS", zap.String("port", cfg.Server.TLS.RedirectPort))
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatal("Redirect listener startup failed", zap.Error(err))
+			}
+		}()
+	}
 
 	// Graceful server start
 	go func() {
-		logger.Info("Starting server", zap.String("port", port))
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("Starting server", zap.String("port", port), zap.Bool("tls", tlsConfig != nil))
+		var err error
+		if tlsConfig != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatal("Server startup failed", zap.Error(err))
 		}
 	}()
@@ -92,26 +418,293 @@ func main() {
 	if err := httpServer.Shutdown(ctx); err != nil {
 		logger.Error("Server shutdown error", zap.Error(err))
 	}
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(ctx); err != nil {
+			logger.Error("Redirect listener shutdown error", zap.Error(err))
+		}
+	}
 
 	logger.Info("Server gracefully stopped")
 }
 
 func (s *Server) setupRoutes() {
+	cfg := config.Get()
+
+	s.router.NotFoundHandler = http.HandlerFunc(apperrors.NotFound)
+	s.router.MethodNotAllowedHandler = http.HandlerFunc(apperrors.MethodNotAllowed)
+	if s.metrics != nil {
+		instrument := middleware.Metrics(s.metrics)
+		s.router.NotFoundHandler = instrument(s.router.NotFoundHandler)
+		s.router.MethodNotAllowedHandler = instrument(s.router.MethodNotAllowedHandler)
+		s.router.Use(instrument)
+	}
+	if s.breaker != nil {
+		s.router.Use(middleware.CircuitBreaker(s.breaker, "/health", "/metrics"))
+	}
+	// The GraphQL API only reads, whatever the method
+	s.router.Use(middleware.ReadOnly(s.readOnly, "/users/login", "/auth/refresh", "/admin/read-only", "/graphql"))
 
+	// Timed out requests are cancelled along with their queries
+	s.router.Use(middleware.Timeout(
+		func(c *config.Config) time.Duration { return c.Server.Timeouts.Default },
+		middleware.TimeoutGroup{Prefix: "/admin/", Timeout: func(c *config.Config) time.Duration { return c.Server.Timeouts.Admin }},
+		middleware.TimeoutGroup{Prefix: "/posts/{id}/live"},
+	))
 	s.router.Use(middleware.Database(s.db))
+	s.router.Use(middleware.Mailer(s.mailer))
+	s.router.Use(middleware.Storage(s.storage))
+	if cfg.Database.QueryPlans.Enabled {
+		s.router.Use(middleware.QueryPlans(s.db, s.logger))
+	}
+	if cfg.Tracing.Enabled {
+		s.router.Use(middleware.Tracing)
+	}
+	if cfg.Sandbox.Enabled {
+		s.router.Use(middleware.Sandbox(s.db, s.storage, s.policy, s.logger))
+	}
+	s.router.Use(middleware.Services(s.services))
+	s.router.Use(middleware.ResponseFormat("/graphql"))
+	if cfg.RateLimit.Enabled {
+		limiter := ratelimit.NewLimiter(cfg.RateLimit.Requests, cfg.RateLimit.Window)
+		s.router.Use(middleware.RateLimit(s.db, limiter, s.policy, s.logger))
+	}
+
+	// Health check
+	s.router.HandleFunc("/health", handlers.HealthCheck).Methods("GET")
+
+	// Prometheus metrics
+	if s.metrics != nil {
+		s.router.Handle("/metrics", s.metrics.Handler()).Methods("GET")
+	}
+
 	// User routes
 	s.router.HandleFunc("/users", handlers.CreateUser).Methods("POST")
 	s.router.HandleFunc("/users/login", handlers.Login).Methods("POST")
+	s.router.HandleFunc("/users/resend-verification", handlers.ResendVerification).Methods("POST")
+	s.router.HandleFunc("/users/verify", handlers.VerifyEmail).Methods("GET")
 	s.router.HandleFunc("/users/profile", middleware.AuthMiddleware(s.db)(handlers.GetUserProfile)).Methods("GET")
+	s.router.HandleFunc("/users/profile", middleware.AuthMiddleware(s.db)(handlers.UpdateProfile)).Methods("PATCH")
+	s.router.HandleFunc("/users/password", middleware.AuthMiddleware(s.db)(handlers.ChangePassword)).Methods("POST")
+	s.router.HandleFunc("/users/preferences", middleware.AuthMiddleware(s.db)(handlers.UpdatePreferences)).Methods("PUT")
+	s.router.HandleFunc("/users/referrals", middleware.AuthMiddleware(s.db)(handlers.ListReferrals)).Methods("GET")
+	s.router.HandleFunc("/users/me", middleware.AuthMiddleware(s.db)(handlers.DeleteAccount)).Methods("DELETE")
+	s.router.HandleFunc("/users/me/export", middleware.AuthMiddleware(s.db)(handlers.ExportAccount)).Methods("GET")
+	s.router.HandleFunc("/users/me/stats", middleware.AuthMiddleware(s.db)(handlers.GetAuthorStats)).Methods("GET")
+	s.router.HandleFunc("/users/me/analytics", middleware.AuthMiddleware(s.db)(handlers.GetAuthorAnalytics)).Methods("GET")
+	s.router.HandleFunc("/users/me/sessions", middleware.AuthMiddleware(s.db)(handlers.ListSessions)).Methods("GET")
+	s.router.HandleFunc("/users/me/sessions", middleware.AuthMiddleware(s.db)(handlers.RevokeSessions)).Methods("DELETE")
+	s.router.HandleFunc("/users/me/sessions/{id}", middleware.AuthMiddleware(s.db)(handlers.RevokeSession)).Methods("DELETE")
+	s.router.HandleFunc("/users/onboarding", middleware.AuthMiddleware(s.db)(handlers.GetOnboarding)).Methods("GET")
+	s.router.HandleFunc("/users/identities", middleware.AuthMiddleware(s.db)(handlers.ListIdentities)).Methods("GET")
+	s.router.HandleFunc("/users/identities/{provider}", middleware.AuthMiddleware(s.db)(handlers.LinkIdentity)).Methods("POST")
+	s.router.HandleFunc("/users/identities/{provider}", middleware.AuthMiddleware(s.db)(handlers.UnlinkIdentity)).Methods("DELETE")
+
+	// Login providers
+	s.router.HandleFunc("/auth/refresh", handlers.RefreshToken).Methods("POST")
+	s.router.HandleFunc("/auth/providers", handlers.ListLoginProviders).Methods("GET")
+	s.router.HandleFunc("/auth/providers/{provider}/authorize", handlers.AuthorizeProvider).Methods("GET")
+	s.router.HandleFunc("/auth/providers/{provider}/login", handlers.ProviderLogin).Methods("POST")
+	s.router.HandleFunc("/auth/{provider}/login", handlers.ProviderRedirect).Methods("GET")
+	s.router.HandleFunc("/auth/{provider}/callback", handlers.ProviderCallback).Methods("GET")
+	// Registered last so that the routes above take precedence over usernames
+	s.router.HandleFunc("/users/{username}", middleware.OptionalAuthMiddleware(s.db)(handlers.GetPublicProfile)).Methods("GET")
+
+	// Notification routes
+	s.router.HandleFunc("/notifications", middleware.AuthMiddleware(s.db)(handlers.ListNotifications)).Methods("GET")
+	s.router.HandleFunc("/notifications/read", middleware.AuthMiddleware(s.db)(handlers.MarkAllNotificationsRead)).Methods("POST")
+	s.router.HandleFunc("/notifications/{id}/read", middleware.AuthMiddleware(s.db)(handlers.MarkNotificationRead)).Methods("POST")
+
+	// Admin routes
+	admin := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.AuthMiddleware(s.db)(middleware.RequireRole(s.db, types.RoleAdmin)(next))
+	}
+	s.router.HandleFunc("/admin/posts/bulk", admin(handlers.BulkUpdatePosts)).Methods("POST")
+	s.router.HandleFunc("/admin/users", admin(handlers.ListUsers)).Methods("GET")
+	s.router.HandleFunc("/admin/users/{id}", admin(handlers.DeleteUser)).Methods("DELETE")
+	s.router.HandleFunc("/admin/users/{id}/role", admin(handlers.UpdateUserRole)).Methods("PUT")
+	s.router.HandleFunc("/admin/users/{id}/membership", admin(handlers.UpdateUserMembership)).Methods("PUT")
+	s.router.HandleFunc("/admin/users/{id}/approve", admin(handlers.ApproveUser)).Methods("POST")
+	s.router.HandleFunc("/admin/users/{id}/verify", admin(handlers.VerifyUser)).Methods("POST")
+	s.router.HandleFunc("/admin/users/{id}/deactivate", admin(handlers.DeactivateUser)).Methods("POST")
+	s.router.HandleFunc("/admin/audit-logs", admin(handlers.ListAuditLog)).Methods("GET")
+	s.router.HandleFunc("/admin/audit-log", admin(handlers.ListAuditLog)).Methods("GET") // Former path
+	s.router.HandleFunc("/admin/email-suppressions", admin(handlers.ListEmailSuppressions)).Methods("GET")
+	s.router.HandleFunc("/admin/email-suppressions/{email}", admin(handlers.DeleteEmailSuppression)).Methods("DELETE")
+	s.router.HandleFunc("/admin/moderation/comments", admin(handlers.ListModerationQueue)).Methods("GET")
+	s.router.HandleFunc("/admin/plans", admin(handlers.ListPlans)).Methods("GET")
+	s.router.HandleFunc("/admin/plans", admin(handlers.CreatePlan)).Methods("POST")
+	s.router.HandleFunc("/admin/plans/{id}", admin(handlers.UpdatePlan)).Methods("PUT")
+	s.router.HandleFunc("/admin/plans/{id}", admin(handlers.DeletePlan)).Methods("DELETE")
+	s.router.HandleFunc("/admin/categories", admin(handlers.CreateCategory)).Methods("POST")
+	s.router.HandleFunc("/admin/categories/{id}", admin(handlers.UpdateCategory)).Methods("PUT")
+	s.router.HandleFunc("/admin/categories/{id}", admin(handlers.DeleteCategory)).Methods("DELETE")
+	s.router.HandleFunc("/admin/usage", admin(handlers.GetUsage)).Methods("GET")
+	s.router.HandleFunc("/admin/search/report", admin(handlers.GetSearchReport)).Methods("GET")
+	s.router.HandleFunc("/admin/read-only", admin(handlers.GetReadOnly(s.readOnly))).Methods("GET")
+	s.router.HandleFunc("/admin/read-only", admin(handlers.SetReadOnly(s.readOnly))).Methods("PUT")
+	s.router.HandleFunc("/admin/loglevel", admin(handlers.GetLogLevel(logging.Level()))).Methods("GET")
+	s.router.HandleFunc("/admin/loglevel", admin(handlers.SetLogLevel(logging.Level()))).Methods("PUT")
+	s.router.HandleFunc("/admin/routes", admin(handlers.ListRoutes(s.routeAccess, s.policy))).Methods("GET")
+	s.router.HandleFunc("/admin/tasks", admin(handlers.ListTasks)).Methods("GET")
+	s.router.HandleFunc("/admin/tasks/{name}", admin(handlers.RunTask)).Methods("POST")
+	s.router.HandleFunc("/admin/tasks/runs/{id}", admin(handlers.GetTaskRun)).Methods("GET")
+	s.router.HandleFunc("/admin/import", admin(handlers.ImportContent)).Methods("POST")
+	s.router.HandleFunc("/admin/import/{id}", admin(handlers.GetImport)).Methods("GET")
+	s.router.HandleFunc("/admin/export", admin(handlers.ExportContent)).Methods("GET")
+	s.router.HandleFunc("/admin/export", admin(handlers.StartExport)).Methods("POST")
+	s.router.HandleFunc("/admin/export/{id}", admin(handlers.GetExport)).Methods("GET")
+	s.router.HandleFunc("/admin/export/{id}/download", admin(handlers.DownloadExport)).Methods("GET")
+
+	// Webhook routes
+	s.router.HandleFunc("/admin/webhooks", admin(handlers.ListWebhooks)).Methods("GET")
+	s.router.HandleFunc("/admin/webhooks", admin(handlers.CreateWebhook)).Methods("POST")
+	s.router.HandleFunc("/admin/webhooks/{id}", admin(handlers.GetWebhook)).Methods("GET")
+	s.router.HandleFunc("/admin/webhooks/{id}", admin(handlers.DeleteWebhook)).Methods("DELETE")
+	s.router.HandleFunc("/admin/webhooks/{id}/test", admin(handlers.TestWebhook)).Methods("POST")
+	s.router.HandleFunc("/admin/webhooks/{id}/deliveries", admin(handlers.ListWebhookDeliveries)).Methods("GET")
+	s.router.HandleFunc("/admin/webhooks/{id}/deliveries/{deliveryId}", admin(handlers.GetWebhookDelivery)).Methods("GET")
+	s.router.HandleFunc("/admin/webhooks/{id}/deliveries/{deliveryId}/redeliver", admin(handlers.RedeliverWebhookDelivery)).Methods("POST")
+
+	// Integration routes
+	s.router.HandleFunc("/admin/integrations", admin(handlers.ListIntegrations)).Methods("GET")
+	s.router.HandleFunc("/admin/integrations", admin(handlers.CreateIntegration)).Methods("POST")
+	s.router.HandleFunc("/admin/integrations/{id}", admin(handlers.GetIntegration)).Methods("GET")
+	s.router.HandleFunc("/admin/integrations/{id}", admin(handlers.DeleteIntegration)).Methods("DELETE")
+
+	// Announcement routes
+	s.router.HandleFunc("/announcements", middleware.OptionalAuthMiddleware(s.db)(handlers.ListActiveAnnouncements)).Methods("GET")
+	s.router.HandleFunc("/admin/announcements", admin(handlers.ListAnnouncements)).Methods("GET")
+	s.router.HandleFunc("/admin/announcements", admin(handlers.CreateAnnouncement)).Methods("POST")
+	s.router.HandleFunc("/admin/announcements/{id}", admin(handlers.UpdateAnnouncement)).Methods("PUT")
+	s.router.HandleFunc("/admin/announcements/{id}", admin(handlers.DeleteAnnouncement)).Methods("DELETE")
+
+	// Settings routes
+	s.router.HandleFunc("/settings", handlers.GetSettings).Methods("GET")
+	s.router.HandleFunc("/settings", middleware.AuthMiddleware(s.db)(handlers.UpdateSettings)).Methods("PUT")
 
 	// Post routes
-	s.router.HandleFunc("/posts", handlers.ListPosts).Methods("GET")
+	content := middleware.GeoRestrict(func(c *config.Config) []string { return c.GeoIP.BlockedContent })
+	// The most read routes are served from the read replicas, if any
+	replica := func(next http.HandlerFunc) http.HandlerFunc { return next }
+	if len(cfg.Database.Replicas.Hosts) > 0 {
+		replica = middleware.ReadReplica
+	}
+	s.router.HandleFunc("/posts", content(middleware.OptionalAuthMiddleware(s.db)(replica(handlers.ListPosts)))).Methods("GET")
 	s.router.HandleFunc("/posts", middleware.AuthMiddleware(s.db)(handlers.CreatePost)).Methods("POST")
-	s.router.HandleFunc("/posts/{id}", handlers.GetPost).Methods("GET")
+	s.router.HandleFunc("/posts/archive", content(middleware.OptionalAuthMiddleware(s.db)(handlers.GetPostArchive))).Methods("GET")
+	s.router.HandleFunc("/posts/trending", content(middleware.OptionalAuthMiddleware(s.db)(handlers.GetTrendingPosts))).Methods("GET")
+	s.router.HandleFunc("/posts/{id}", content(middleware.OptionalAuthMiddleware(s.db)(replica(handlers.GetPost)))).Methods("GET")
+	s.router.HandleFunc("/posts/{id}/related", content(middleware.OptionalAuthMiddleware(s.db)(handlers.GetRelatedPosts))).Methods("GET")
+	s.router.HandleFunc("/posts/{id}/meta", content(handlers.GetPostMeta)).Methods("GET")
+	s.router.HandleFunc("/posts/{id}/translations", content(middleware.OptionalAuthMiddleware(s.db)(replica(handlers.ListPostTranslations)))).Methods("GET")
+	s.router.HandleFunc("/posts/{id}/translations/{locale}", content(middleware.OptionalAuthMiddleware(s.db)(replica(handlers.GetPostTranslation)))).Methods("GET")
+	if s.presence != nil {
+		s.router.HandleFunc("/posts/{id}/live", content(middleware.OptionalAuthMiddleware(s.db)(handlers.StreamPost(s.presence)))).Methods("GET")
+	}
+	s.router.HandleFunc("/posts/{id}/stats", middleware.AuthMiddleware(s.db)(handlers.GetPostStats)).Methods("GET")
+	s.router.HandleFunc("/highlight.css", handlers.GetHighlightCSS).Methods("GET")
 	s.router.HandleFunc("/posts/{id}", middleware.AuthMiddleware(s.db)(handlers.UpdatePost)).Methods("PUT")
+	s.router.HandleFunc("/posts/{id}/publish", middleware.AuthMiddleware(s.db)(handlers.PublishPost)).Methods("POST")
+	s.router.HandleFunc("/posts/{id}/translations/{locale}", middleware.AuthMiddleware(s.db)(handlers.SavePostTranslation)).Methods("PUT")
+	s.router.HandleFunc("/posts/{id}/translations/{locale}", middleware.AuthMiddleware(s.db)(handlers.DeletePostTranslation)).Methods("DELETE")
+	s.router.HandleFunc("/posts/{id}/authors/{userId}", middleware.AuthMiddleware(s.db)(handlers.CreditPostAuthor)).Methods("PUT")
+	s.router.HandleFunc("/posts/{id}/authors/{userId}", middleware.AuthMiddleware(s.db)(handlers.UncreditPostAuthor)).Methods("DELETE")
 	s.router.HandleFunc("/posts/{id}", middleware.AuthMiddleware(s.db)(handlers.DeletePost)).Methods("DELETE")
+	s.router.HandleFunc("/search/{id}/click", handlers.RecordSearchClick).Methods("POST")
+
+	// GraphQL routes
+	if cfg.GraphQL.Enabled {
+		graphql := content(middleware.OptionalAuthMiddleware(s.db)(handlers.GraphQL()))
+		s.router.HandleFunc("/graphql", graphql).Methods("GET", "POST")
+	}
+
+	// Feed routes
+	s.router.HandleFunc("/feed.xml", content(handlers.GetFeed)).Methods("GET")
+
+	// Community routes
+	if cfg.Leaderboards.Enabled {
+		s.router.HandleFunc("/leaderboards", handlers.GetLeaderboards).Methods("GET")
+	}
+
+	// Crawler and well-known routes
+	s.router.HandleFunc("/robots.txt", handlers.GetRobots).Methods("GET")
+	s.router.HandleFunc("/sitemap.xml", handlers.GetSitemap).Methods("GET")
+	s.router.HandleFunc("/.well-known/security.txt", handlers.GetSecurityTxt).Methods("GET")
+	s.router.HandleFunc("/.well-known/change-password", handlers.ChangePasswordRedirect).Methods("GET")
+	s.router.HandleFunc("/.well-known/jwks.json", handlers.GetJWKS).Methods("GET")
+
+	// Tag routes
+	s.router.HandleFunc("/tags", handlers.ListTags).Methods("GET")
+	s.router.HandleFunc("/categories", handlers.ListCategories).Methods("GET")
+	s.router.HandleFunc("/categories/{slug}", handlers.GetCategory).Methods("GET")
+	s.router.HandleFunc("/tags/{slug}/posts", content(middleware.OptionalAuthMiddleware(s.db)(handlers.ListTagPosts))).Methods("GET")
+
+	// Series routes
+	s.router.HandleFunc("/series", middleware.AuthMiddleware(s.db)(handlers.CreateSeries)).Methods("POST")
+	s.router.HandleFunc("/series/{slug}", content(middleware.OptionalAuthMiddleware(s.db)(handlers.GetSeries))).Methods("GET")
+	s.router.HandleFunc("/series/{id}", middleware.AuthMiddleware(s.db)(handlers.UpdateSeries)).Methods("PUT")
+	s.router.HandleFunc("/series/{id}", middleware.AuthMiddleware(s.db)(handlers.DeleteSeries)).Methods("DELETE")
+	s.router.HandleFunc("/series/{id}/posts", middleware.AuthMiddleware(s.db)(handlers.AddSeriesPost)).Methods("POST")
+	s.router.HandleFunc("/series/{id}/posts", middleware.AuthMiddleware(s.db)(handlers.ReorderSeriesPosts)).Methods("PUT")
+	s.router.HandleFunc("/series/{id}/posts/{postId}", middleware.AuthMiddleware(s.db)(handlers.RemoveSeriesPost)).Methods("DELETE")
+
+	// Upload routes
+	s.router.HandleFunc("/uploads", middleware.AuthMiddleware(s.db)(handlers.CreateUpload)).Methods("POST")
+	if local, ok := s.storage.(*storage.LocalStorage); ok {
+		s.router.PathPrefix("/media/").Handler(http.StripPrefix("/media/", local.Handler())).Methods("GET")
+	}
+
+	// Link preview routes
+	s.router.HandleFunc("/unfurl", middleware.OptionalAuthMiddleware(s.db)(handlers.Unfurl)).Methods("GET")
+
+	// Embeds
+	if cfg.Embeds.Enabled {
+		s.router.HandleFunc("/embed-tokens", middleware.AuthMiddleware(s.db)(handlers.CreateEmbedToken)).Methods("POST")
+		s.router.HandleFunc("/embed", content(handlers.GetEmbed)).Methods("GET")
+	}
+	if cfg.Embeds.OEmbed {
+		s.router.HandleFunc("/oembed", content(handlers.OEmbed)).Methods("GET")
+	}
 
 	// Comment routes
 	s.router.HandleFunc("/posts/{postId}/comments", middleware.AuthMiddleware(s.db)(handlers.CreateComment)).Methods("POST")
-	s.router.HandleFunc("/posts/{postId}/comments", handlers.ListComments).Methods("GET")
+	s.router.HandleFunc("/posts/{postId}/comments", middleware.OptionalAuthMiddleware(s.db)(replica(handlers.ListComments))).Methods("GET")
+	s.router.HandleFunc("/posts/{postId}/comments/guest", handlers.CreateGuestComment).Methods("POST")
+	s.router.HandleFunc("/comments/{id}/replies", middleware.OptionalAuthMiddleware(s.db)(handlers.ListReplies)).Methods("GET")
+	s.router.HandleFunc("/comments/{id}/like", middleware.AuthMiddleware(s.db)(handlers.LikeComment)).Methods("POST")
+	s.router.HandleFunc("/comments/{id}/like", middleware.AuthMiddleware(s.db)(handlers.UnlikeComment)).Methods("DELETE")
+	s.router.HandleFunc("/comments/{id}/report", middleware.AuthMiddleware(s.db)(handlers.ReportComment)).Methods("POST")
+	s.router.HandleFunc("/comments/{id}/status", middleware.AuthMiddleware(s.db)(handlers.ModerateComment)).Methods("PATCH")
+
+	// Comments sent by external services, authenticated by their signature
+	s.router.HandleFunc("/integrations/{id}/comments", handlers.ReceiveIntegrationComment).Methods("POST")
+
+	// Email provider notifications
+	if cfg.Email.Feedback.Token != "" {
+		s.router.HandleFunc("/email/feedback/ses", handlers.SESFeedback).Methods("POST")
+		s.router.HandleFunc("/email/feedback/sendgrid", handlers.SendGridFeedback).Methods("POST")
+	}
+
+	// API documentation, built from the routes registered above
+	s.router.HandleFunc("/openapi.json", openapi.SpecHandler(s.apiSpec)).Methods("GET")
+	s.router.HandleFunc("/docs", openapi.UIHandler(cfg.Site.Title+" API", "/openapi.json")).Methods("GET")
+}
+
+// routeAccess builds the access matrix of the registered routes. Every
+// /admin route is registered through admin(), which requires the admin role.
+func (s *Server) routeAccess() ([]openapi.Access, error) {
+	return openapi.AccessMatrix(s.router, routeDocs, map[string][]string{
+		"/admin/": {types.RoleAdmin},
+	})
+}
+
+// apiSpec builds the OpenAPI specification of the registered routes.
+func (s *Server) apiSpec() (*openapi.Document, error) {
+	cfg := config.Get()
+	info := openapi.Info{
+		Title:       cfg.Site.Title + " API",
+		Description: cfg.Site.Description,
+		Version:     "1.0.0",
+	}
+	return openapi.Build(s.router, routeDocs, info, openapi.Server{URL: cfg.Server.BaseURL})
 }
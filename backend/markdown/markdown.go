@@ -0,0 +1,105 @@
+// Package markdown renders the Markdown content of posts to HTML.
+//
+// Content is rendered with the GitHub Flavored Markdown extensions, and code
+// blocks are highlighted with CSS classes, styled by the stylesheet returned
+// by CSS. Raw HTML written by authors is kept, but the output is sanitized:
+// only the "markdown.allowed_tags" elements and a few safe attributes are
+// let through, and links get rel="nofollow".
+package markdown
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/SteaceP/coderage/config"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// classPattern matches the class attributes kept, as set by highlighting and
+// by fenced code blocks, e.g. "language-go"
+var classPattern = regexp.MustCompile(`^[A-Za-z0-9_ -]+$`)
+
+// renderer converts Markdown with the settings it was built for.
+type renderer struct {
+	cfg      config.MarkdownConfig
+	markdown goldmark.Markdown
+	policy   *bluemonday.Policy
+}
+
+var (
+	mu      sync.Mutex
+	current *renderer
+)
+
+// Render converts Markdown source to sanitized HTML.
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	r := get()
+	if err := r.markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return r.policy.Sanitize(buf.String()), nil
+}
+
+// CSS returns the stylesheet of the highlighted code blocks, in the
+// "markdown.highlight_style" chroma style.
+func CSS() (string, error) {
+	var buf bytes.Buffer
+	style := styles.Get(config.Get().Markdown.HighlightStyle)
+	if err := chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(&buf, style); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// get returns the renderer for the current settings, building a new one when
+// they changed.
+func get() *renderer {
+	cfg := config.Get().Markdown
+
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil || !reflect.DeepEqual(current.cfg, cfg) {
+		current = newRenderer(cfg)
+	}
+	return current
+}
+
+// newRenderer returns a new instance of renderer for the given settings.
+func newRenderer(cfg config.MarkdownConfig) *renderer {
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle(cfg.HighlightStyle),
+				highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+			),
+		),
+		// Raw HTML is sanitized afterwards
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+
+	policy := bluemonday.NewPolicy()
+	policy.AllowElements(cfg.AllowedTags...)
+	policy.AllowStandardURLs()
+	policy.RequireNoFollowOnLinks(true)
+	policy.AllowAttrs("href", "title").OnElements("a")
+	policy.AllowAttrs("src", "alt", "title").OnElements("img")
+	policy.AllowAttrs("class").Matching(classPattern).OnElements("pre", "code", "span")
+	policy.AllowStyles("text-align").MatchingEnum("left", "center", "right").OnElements("th", "td")
+	// Task list checkboxes
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^(|checked|disabled)$`)).OnElements("input")
+	policy.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+
+	return &renderer{cfg: cfg, markdown: md, policy: policy}
+}
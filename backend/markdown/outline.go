@@ -0,0 +1,43 @@
+package markdown
+
+import (
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Heading is a heading of Markdown content.
+type Heading struct {
+	Level int // 1 to 6
+	Text  string
+}
+
+// Outline is the structure of Markdown content: its headings and the
+// destinations of its links, in the order they appear. Links written as raw
+// HTML are left out.
+type Outline struct {
+	Headings []Heading
+	Links    []string
+}
+
+// Inspect returns the outline of Markdown source, parsed as it is rendered.
+func Inspect(source string) Outline {
+	src := []byte(source)
+	doc := get().markdown.Parser().Parse(text.NewReader(src))
+
+	var outline Outline
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Heading:
+			outline.Headings = append(outline.Headings, Heading{Level: node.Level, Text: string(node.Text(src))})
+		case *ast.Link:
+			outline.Links = append(outline.Links, string(node.Destination))
+		case *ast.AutoLink:
+			outline.Links = append(outline.Links, string(node.URL(src)))
+		}
+		return ast.WalkContinue, nil
+	})
+	return outline
+}
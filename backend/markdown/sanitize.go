@@ -0,0 +1,70 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Sanitize applies the policy of rendered posts to the raw HTML of Markdown
+// source, so the source stored is as safe as its rendering: elements that
+// aren't allowed are removed, keeping their text, along with unsafe
+// attributes. The Markdown itself is left untouched, including code spans
+// and blocks, whose HTML is rendered escaped.
+func Sanitize(source string) string {
+	r := get()
+	src := []byte(source)
+	doc := r.markdown.Parser().Parse(text.NewReader(src))
+
+	// Collect the ranges of raw HTML, in the order they appear
+	var ranges []text.Segment
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.HTMLBlock:
+			lines := node.Lines().Sliced(0, node.Lines().Len())
+			if node.HasClosure() {
+				lines = append(lines, node.ClosureLine)
+			}
+			ranges = append(ranges, contiguous(lines)...)
+		case *ast.RawHTML:
+			ranges = append(ranges, contiguous(node.Segments.Sliced(0, node.Segments.Len()))...)
+		}
+		return ast.WalkContinue, nil
+	})
+	if len(ranges) == 0 {
+		return source
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, segment := range ranges {
+		if segment.Start < last {
+			continue
+		}
+		b.Write(src[last:segment.Start])
+		b.WriteString(r.policy.Sanitize(string(segment.Value(src))))
+		last = segment.Stop
+	}
+	b.Write(src[last:])
+	return b.String()
+}
+
+// contiguous merges the segments that follow each other, so an element
+// spanning lines is sanitized whole. Segments of HTML nested in a block
+// quote or a list are separated by the markers of their lines, and are
+// sanitized apart.
+func contiguous(segments []text.Segment) []text.Segment {
+	var merged []text.Segment
+	for _, segment := range segments {
+		if n := len(merged); n > 0 && merged[n-1].Stop == segment.Start {
+			merged[n-1].Stop = segment.Stop
+			continue
+		}
+		merged = append(merged, text.NewSegment(segment.Start, segment.Stop))
+	}
+	return merged
+}
@@ -0,0 +1,179 @@
+// Package metrics records instrumentation of the API and its database
+// connection pool, exposed in the Prometheus text format.
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/httpclient"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// namespace prefixes the name of every metric
+const namespace = "coderage"
+
+// UnmatchedRoute labels the requests which matched no route, so unknown
+// paths can't grow the number of series.
+const UnmatchedRoute = "unmatched"
+
+// Metrics holds the collectors of the API, registered on their own registry.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	queries  *prometheus.HistogramVec
+}
+
+// queryStartKey stores the start time of a query on its statement
+const queryStartKey = "metrics:start"
+
+// New returns a new instance of Metrics, collecting the statistics of the
+// connection pool of db and the outbound requests along with the Go runtime
+// and process metrics. The durations of the queries are measured once
+// Metrics is used as a plugin of db.
+func New(db *gorm.DB) (*Metrics, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests handled, by route and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests, by route and status code.",
+			Buckets:   latencyBuckets(),
+		}, []string{"method", "route", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests being handled, by route.",
+		}, []string{"method", "route"}),
+		queries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Latency of database queries, by operation.",
+			// From half a millisecond to about 8 seconds
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 15),
+		}, []string{"operation"}),
+	}
+
+	for _, c := range append([]prometheus.Collector{
+		m.requests,
+		m.duration,
+		m.inFlight,
+		m.queries,
+		collectors.NewDBStatsCollector(sqlDB, db.Name()),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	}, httpclient.Collectors()...) {
+		if err := m.registry.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Begin records the start of a request to a route, and returns the function
+// recording its end with the status of the response.
+func (m *Metrics) Begin(method, route string) func(status int) {
+	start := time.Now()
+	inFlight := m.inFlight.WithLabelValues(method, route)
+	inFlight.Inc()
+
+	return func(status int) {
+		inFlight.Dec()
+		code := strconv.Itoa(status)
+		m.requests.WithLabelValues(method, route, code).Inc()
+		m.duration.WithLabelValues(method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Name returns the name of the plugin measuring the queries.
+func (m *Metrics) Name() string {
+	return "metrics"
+}
+
+// Initialize registers the callbacks measuring the duration of the queries
+// run on db, by operation: insert, select, update, delete, row or raw.
+func (m *Metrics) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("gorm:create").Register("metrics:before_insert", m.beforeQuery),
+		callbacks.Create().After("gorm:create").Register("metrics:after_insert", m.afterQuery("insert")),
+		callbacks.Query().Before("gorm:query").Register("metrics:before_select", m.beforeQuery),
+		callbacks.Query().After("gorm:query").Register("metrics:after_select", m.afterQuery("select")),
+		callbacks.Update().Before("gorm:update").Register("metrics:before_update", m.beforeQuery),
+		callbacks.Update().After("gorm:update").Register("metrics:after_update", m.afterQuery("update")),
+		callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", m.beforeQuery),
+		callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", m.afterQuery("delete")),
+		callbacks.Row().Before("gorm:row").Register("metrics:before_row", m.beforeQuery),
+		callbacks.Row().After("gorm:row").Register("metrics:after_row", m.afterQuery("row")),
+		callbacks.Raw().Before("gorm:raw").Register("metrics:before_raw", m.beforeQuery),
+		callbacks.Raw().After("gorm:raw").Register("metrics:after_raw", m.afterQuery("raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beforeQuery records the start of a query.
+func (m *Metrics) beforeQuery(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+// afterQuery records the duration of a query of an operation.
+func (m *Metrics) afterQuery(operation string) func(*gorm.DB) {
+	observer := m.queries.WithLabelValues(operation)
+	return func(db *gorm.DB) {
+		v, _ := db.InstanceGet(queryStartKey)
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+		db.InstanceSet(queryStartKey, nil)
+		observer.Observe(time.Since(start).Seconds())
+	}
+}
+
+// latencyBuckets returns the "metrics.latency_buckets" setting, in seconds,
+// falling back to the Prometheus default buckets.
+func latencyBuckets() []float64 {
+	if buckets := config.Get().Metrics.LatencyBuckets; len(buckets) > 0 {
+		return buckets
+	}
+	return prometheus.DefBuckets
+}
+
+// Handler serves the metrics for Prometheus scraping. When "metrics.token" is
+// set, scrapers must send it as a bearer token.
+func (m *Metrics) Handler() http.Handler {
+	metrics := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := config.Get().Metrics.Token; token != "" {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+				apperrors.Error(w, r, "Invalid metrics token", http.StatusUnauthorized)
+				return
+			}
+		}
+		metrics.ServeHTTP(w, r)
+	})
+}
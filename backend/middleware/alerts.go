@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/SteaceP/coderage/alerts"
+)
+
+// Alerts reports the status of every response to the alert monitor.
+func Alerts(monitor *alerts.Monitor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			crw := &customResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(crw, r)
+			monitor.Observe(r, crw.status)
+		})
+	}
+}
@@ -2,9 +2,13 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/securitylog"
 	"github.com/SteaceP/coderage/types"
 	"github.com/SteaceP/coderage/utils"
 	"gorm.io/gorm"
@@ -12,93 +16,180 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
-type contextKey string // Define a custom type for context keys
-
-const (
-	keyUserID contextKey = "user_id"
-	keyDB     contextKey = "db"
+var (
+	errMissingToken  = errors.New("Missing authorization token")
+	errInvalidFormat = errors.New("Invalid token format")
+	errInvalidToken  = errors.New("Invalid or expired token")
+	errInvalidClaims = errors.New("Invalid token claims")
+	errNotAccess     = errors.New("Not an access token")
+	errInvalidUserID = errors.New("Invalid user ID in token")
+	errRevoked       = errors.New("Session has been revoked")
+	errDBUnavailable = errors.New("Database connection is unavailable")
 )
 
 func AuthMiddleware(db *gorm.DB) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			// Check for authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "Missing authorization token", http.StatusUnauthorized)
+			userID, session, err := userIDFromRequest(r)
+			if err != nil {
+				if err != errMissingToken {
+					securitylog.AuthFailure(r, err.Error())
+				}
+				apperrors.Error(w, r, err.Error(), http.StatusUnauthorized)
 				return
 			}
 
-			// Validate token format
-			bearerToken := strings.Split(authHeader, " ")
-			if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-				http.Error(w, "Invalid token format", http.StatusUnauthorized)
+			// Check database connection
+			if db == nil {
+				apperrors.Error(w, r, errDBUnavailable.Error(), http.StatusInternalServerError)
 				return
 			}
 
-			// Validate token
-			token, err := utils.ValidateJWTToken(bearerToken[1])
-			if err != nil || token == nil {
-				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			// Reject the tokens of revoked sessions
+			if err := checkSession(r, db, userID, session); err != nil {
+				if err == errRevoked {
+					securitylog.AuthFailure(r, err.Error())
+					apperrors.Error(w, r, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				apperrors.Error(w, r, "Failed to check session", http.StatusInternalServerError)
 				return
 			}
 
-			// Validate claims
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok || !token.Valid {
-				http.Error(w, "Invalid token claims", http.StatusUnauthorized)
-				return
-			}
+			// Attach user and session IDs to request context
+			ctx := context.WithValue(r.Context(), types.KeyUserID, userID)
+			ctx = context.WithValue(ctx, types.KeySessionID, session.id)
+			ctx = withDB(ctx, db)
 
-			//TODO: Make sure this is correct, all with the next else if.
-			// Validate user ID
-			userIDFloat, ok := claims[types.UserID]
-			if !ok {
-				http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
+			// Call next handler
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+	}
+}
+
+// OptionalAuthMiddleware attaches the user ID to the request context when a
+// valid token is supplied, and lets anonymous requests through untouched.
+// It is meant for public routes whose response varies for signed-in users.
+func OptionalAuthMiddleware(db *gorm.DB) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			userID, session, err := userIDFromRequest(r)
+			if err == nil && db != nil {
+				err = checkSession(r, db, userID, session)
+			}
+			if err != nil && err != errMissingToken {
+				securitylog.AuthFailure(r, err.Error())
+			}
+			if err != nil || db == nil {
+				next.ServeHTTP(w, r)
 				return
 			}
-			if userIDInt, ok := userIDFloat.(int64); ok {
-				userID := uint(userIDInt)
-				if userID == 0 {
-					http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
-					return
-				}
 
-				// Check database connection
-				if db == nil {
-					http.Error(w, "Database connection is unavailable", http.StatusInternalServerError)
-					return
-				}
+			ctx := context.WithValue(r.Context(), types.KeyUserID, userID)
+			ctx = context.WithValue(ctx, types.KeySessionID, session.id)
+			ctx = withDB(ctx, db)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+	}
+}
 
-				// Attach user ID to request context
-				ctx := context.WithValue(r.Context(), keyUserID, userID)
-				ctx = context.WithValue(ctx, keyDB, db)
+// withDB attaches db to the context unless a connection is already attached,
+// such as the transaction of a sandbox request.
+func withDB(ctx context.Context, db *gorm.DB) context.Context {
+	if _, ok := ctx.Value(types.KeyDB).(*gorm.DB); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, types.KeyDB, db)
+}
 
-				// Call next handler
-				next.ServeHTTP(w, r.WithContext(ctx))
-			} else if userIDFloat64, ok := userIDFloat.(float64); ok {
-				userID := uint(userIDFloat64)
-				if userID == 0 {
-					http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
-					return
-				}
+// tokenSession is the session an access token was issued to.
+type tokenSession struct {
+	id      uint // Zero for tokens issued before sessions
+	version int  // Token version of the user when the token was issued
+}
 
-				// Check database connection
-				if db == nil {
-					http.Error(w, "Database connection is unavailable", http.StatusInternalServerError)
-					return
-				}
+// userIDFromRequest extracts and validates the bearer token of the request,
+// which must be an access token, and returns the user ID stored in its
+// claims and the session it was issued to.
+func userIDFromRequest(r *http.Request) (uint, tokenSession, error) {
+	// Check for authorization header
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return 0, tokenSession{}, errMissingToken
+	}
+
+	// Validate token format
+	bearerToken := strings.Split(authHeader, " ")
+	if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+		return 0, tokenSession{}, errInvalidFormat
+	}
 
-				// Attach user ID to request context
-				ctx := context.WithValue(r.Context(), keyUserID, userID)
-				ctx = context.WithValue(ctx, keyDB, db)
+	// Validate token
+	token, err := utils.ValidateJWTToken(bearerToken[1])
+	if err != nil || token == nil {
+		return 0, tokenSession{}, errInvalidToken
+	}
 
-				// Call next handler
-				next.ServeHTTP(w, r.WithContext(ctx))
-			} else {
-				http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
-				return
-			}
+	// Validate claims
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return 0, tokenSession{}, errInvalidClaims
+	}
+
+	// Refresh tokens can't be used to authenticate requests
+	if utils.TokenType(claims) != types.TokenAccess {
+		return 0, tokenSession{}, errNotAccess
+	}
+
+	// Validate user ID, which is decoded as float64 from JSON but may be an
+	// integer when the claims were built in-process.
+	var userID uint
+	switch v := claims[types.UserID].(type) {
+	case float64:
+		userID = uint(v)
+	case int64:
+		userID = uint(v)
+	default:
+		return 0, tokenSession{}, errInvalidUserID
+	}
+	if userID == 0 {
+		return 0, tokenSession{}, errInvalidUserID
+	}
+
+	// Tokens without a version predate versioning and count as version 0
+	sessionID, _ := claims["sid"].(float64)
+	version, _ := claims["version"].(float64)
+	return userID, tokenSession{id: uint(sessionID), version: int(version)}, nil
+}
+
+// checkSession returns errRevoked when the session a token was issued to was
+// revoked, or expired, or when its user was deactivated or deleted. Tokens
+// issued before sessions have no session, only their user to check, whose
+// token version changes when they log out everywhere or change their
+// password.
+func checkSession(r *http.Request, db *gorm.DB, userID uint, session tokenSession) error {
+	if tx, ok := r.Context().Value(types.KeyDB).(*gorm.DB); ok {
+		db = tx
+	}
+	if session.id == 0 {
+		user, err := repositories.NewUserRepository(db).FindByIDLite(userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errRevoked
+		}
+		if err != nil {
+			return err
 		}
+		if !user.IsActive || user.TokenVersion != session.version {
+			return errRevoked
+		}
+		return nil
+	}
+	active, err := repositories.NewSessionRepository(db).Active(session.id, userID)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return errRevoked
 	}
+	return nil
 }
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/database"
+)
+
+// CircuitBreaker answers 503 Service Unavailable, with a Retry-After header,
+// while the breaker reports the database as down, instead of letting every
+// request wait for the database to fail. Requests to the exempt paths, such
+// as the health check, are always served.
+func CircuitBreaker(breaker *database.Breaker, exempt ...string) func(http.Handler) http.Handler {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !breaker.Open() || exemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			retryAfter := int(math.Ceil(breaker.RetryAfter().Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			apperrors.Error(w, r, "Service temporarily unavailable", http.StatusServiceUnavailable)
+		})
+	}
+}
@@ -1,18 +1,20 @@
 package middleware
 
 import (
+	"github.com/SteaceP/coderage/config"
+
 	"github.com/rs/cors"
-	"github.com/spf13/viper"
 )
 
 // ConfigureCORS sets up CORS middleware with configuration from Viper
 func ConfigureCORS() *cors.Cors {
 	return cors.New(cors.Options{
-		AllowedOrigins:   viper.GetStringSlice("cors.allowed_origins"),
+		AllowedOrigins:   config.Get().CORS.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Origin", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Origin", "Authorization", "Content-Type", "X-Request-ID", "X-Response-Envelope", "X-Field-Case", "X-API-Version", "X-Sandbox", "X-Query-Plans", "X-API-Key"},
+		ExposedHeaders:   []string{"X-Request-ID", "X-API-Version", "Deprecation", "X-Sandbox", "X-Query-Plans"},
 		AllowCredentials: true,
 		// Optional: Add debug logging for CORS errors
-		Debug: viper.GetBool("cors.debug"),
+		Debug: config.Get().CORS.Debug,
 	})
 }
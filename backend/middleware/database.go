@@ -4,13 +4,17 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/SteaceP/coderage/types"
 	"gorm.io/gorm"
 )
 
+// Database attaches the database connection to the request context, scoped
+// to it so that queries are cancelled when the client goes away or the
+// request times out.
 func Database(db *gorm.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := context.WithValue(r.Context(), "db", db)
+			ctx := context.WithValue(r.Context(), types.KeyDB, db.WithContext(r.Context()))
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+
+	"github.com/gorilla/mux"
+)
+
+// APIVersionHeader selects the version of the responses
+const APIVersionHeader = "X-API-Version"
+
+// LatestAPIVersion is the version of the responses as written by the
+// handlers. Responses of earlier versions are rewritten by the field aliases.
+const LatestAPIVersion = 2
+
+// FieldAlias is a response field renamed in an API version. Clients asking
+// for an earlier version keep getting it under its previous name.
+type FieldAlias struct {
+	Route    string // Route template, e.g. "GET /posts"
+	Field    string // Path of the field in the response, e.g. "pagination.total"
+	Previous string // Name of the field before Version
+	Version  int    // First version with the new name
+}
+
+// fieldAliases lists the response fields renamed since the first version
+var fieldAliases = []FieldAlias{
+	{Route: "GET /posts", Field: "pagination.total", Previous: "total_posts", Version: 2},
+	{Route: "GET /tags/{slug}/posts", Field: "pagination.total", Previous: "total_posts", Version: 2},
+	{Route: "GET /users/{username}", Field: "pagination.total", Previous: "total_posts", Version: 2},
+	{Route: "GET /posts/{postId}/comments", Field: "pagination.total", Previous: "total_comments", Version: 2},
+}
+
+// negotiateVersion returns the API version requested by the request, the
+// "api.version" setting by default.
+func negotiateVersion(r *http.Request) int {
+	version := config.Get().API.Version
+	if v, err := strconv.Atoi(r.Header.Get(APIVersionHeader)); err == nil {
+		version = v
+	}
+	if version < 1 || version > LatestAPIVersion {
+		return LatestAPIVersion
+	}
+	return version
+}
+
+// routeAliases returns the aliases of the fields renamed after the given
+// version in the responses of the route of the request.
+func routeAliases(r *http.Request, version int) []FieldAlias {
+	if version >= LatestAPIVersion {
+		return nil
+	}
+	current := mux.CurrentRoute(r)
+	if current == nil {
+		return nil
+	}
+	template, err := current.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+
+	var aliases []FieldAlias
+	route := r.Method + " " + template
+	for _, alias := range fieldAliases {
+		if alias.Route == route && alias.Version > version {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// applyAliases renames the aliased fields of a JSON document back to their
+// previous names. The document is returned unchanged if it isn't valid JSON.
+func applyAliases(body []byte, aliases []FieldAlias) []byte {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return body
+	}
+
+	for _, alias := range aliases {
+		renameField(data, strings.Split(alias.Field, "."), alias.Previous)
+	}
+	renamed, err := json.Marshal(data)
+	if err != nil {
+		return body
+	}
+	return append(renamed, '\n')
+}
+
+// renameField renames the field at path in v, in every element of the
+// arrays along the path.
+func renameField(v interface{}, path []string, name string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		value, ok := v[path[0]]
+		if !ok {
+			return
+		}
+		if len(path) > 1 {
+			renameField(value, path[1:], name)
+			return
+		}
+		delete(v, path[0])
+		v[name] = value
+	case []interface{}:
+		for _, value := range v {
+			renameField(value, path, name)
+		}
+	}
+}
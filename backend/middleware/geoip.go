@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/geoip"
+	"github.com/SteaceP/coderage/types"
+)
+
+// GeoIP resolves the country requests are received from and attaches its ISO
+// code to the request context. Nothing is attached when the country is
+// unknown.
+func GeoIP(resolver geoip.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if country := resolver.Country(geoip.RemoteIP(r)); country != "" {
+				r = r.WithContext(context.WithValue(r.Context(), types.KeyCountry, country))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GeoRestrict rejects requests from the countries returned by list for the
+// configuration in effect with a 451 error. Requests from unknown countries
+// are let through.
+func GeoRestrict(list func(*config.Config) []string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if geoip.Blocked(geoip.RequestCountry(r), list(config.Get())) {
+				apperrors.Error(w, r, "This content is not available in your country", http.StatusUnavailableForLegalReasons)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/geoip"
+	"github.com/SteaceP/coderage/redact"
+
 	"go.uber.org/zap"
 )
 
@@ -26,9 +30,12 @@ func LoggingMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
 			logger.Info("HTTP Request",
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
+				zap.String("query", redact.Query(r.URL.RawQuery)),
 				zap.Int("status", crw.status),
 				zap.Duration("latency", time.Since(start)),
-				zap.String("remote_addr", r.RemoteAddr),
+				zap.String("remote_addr", geoip.StoredIP(r.RemoteAddr)),
+				zap.String("request_id", apperrors.RequestID(r)),
+				zap.String("country", geoip.RequestCountry(r)),
 			)
 		})
 	}
@@ -45,3 +52,8 @@ func (crw *customResponseWriter) WriteHeader(status int) {
 	crw.status = status
 	crw.ResponseWriter.WriteHeader(status)
 }
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (crw *customResponseWriter) Unwrap() http.ResponseWriter {
+	return crw.ResponseWriter
+}
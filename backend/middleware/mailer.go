@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/types"
+)
+
+// Mailer attaches the mailer to the request context.
+func Mailer(m mailer.Mailer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), types.KeyMailer, m)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
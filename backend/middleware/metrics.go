@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/SteaceP/coderage/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// Metrics records the count, latency and concurrency of the requests, labeled
+// by the template of the route they matched rather than their path.
+func Metrics(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := metrics.UnmatchedRoute
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+
+			done := m.Begin(r.Method, route)
+			crw := &customResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(crw, r)
+			done(crw.status)
+		})
+	}
+}
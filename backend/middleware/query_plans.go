@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/database"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/securitylog"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// QueryPlansHeader asks for the plans of the queries of a request
+const QueryPlansHeader = "X-Query-Plans"
+
+// QueryPlans explains the queries of a request and logs their plans once the
+// handler has replied, to diagnose slow endpoints on the live data. The
+// database.QueryPlanner plugin must be in use.
+//
+// A request is explained when an admin sets the X-Query-Plans header to
+// true, or when it matches one of the routes of the
+// "database.query_plans.routes" setting. The header is ignored for other
+// users. Responses to explained requests carry "X-Query-Plans: true".
+//
+// Like Tracing, it replaces the database connection of the request context,
+// and must come after Database and before Sandbox.
+func QueryPlans(db *gorm.DB, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, explained := queryPlansRoute(r)
+			if !explained && !queryPlansAllowed(db, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			plans := &database.QueryPlans{}
+			ctx := database.WithQueryPlans(r.Context(), plans)
+			if db, ok := ctx.Value(types.KeyDB).(*gorm.DB); ok {
+				ctx = context.WithValue(ctx, types.KeyDB, db.WithContext(ctx))
+			}
+
+			w.Header().Set(QueryPlansHeader, "true")
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			for _, plan := range plans.Plans() {
+				fields := []zap.Field{
+					zap.String("method", r.Method),
+					zap.String("route", route),
+					zap.String("request_id", apperrors.RequestID(r)),
+					zap.String("sql", plan.SQL),
+					zap.Duration("duration", plan.Duration),
+				}
+				if plan.Err != nil {
+					logger.Warn("Failed to explain query", append(fields, zap.Error(plan.Err))...)
+					continue
+				}
+				logger.Info("Query plan", append(fields, zap.String("plan", plan.Plan))...)
+			}
+			if skipped := plans.Skipped(); skipped > 0 {
+				logger.Info("Query plans skipped",
+					zap.String("route", route),
+					zap.String("request_id", apperrors.RequestID(r)),
+					zap.Int("queries", skipped),
+				)
+			}
+		})
+	}
+}
+
+// queryPlansRoute returns the template of the route of the request, and
+// whether the route is always explained.
+func queryPlansRoute(r *http.Request) (string, bool) {
+	route := r.URL.Path
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			route = template
+		}
+	}
+	for _, explained := range config.Get().Database.QueryPlans.Routes {
+		if route == explained {
+			return route, true
+		}
+	}
+	return route, false
+}
+
+// queryPlansAllowed reports whether the request asks for its query plans
+// and is made by an admin.
+func queryPlansAllowed(db *gorm.DB, r *http.Request) bool {
+	if wanted, _ := strconv.ParseBool(r.Header.Get(QueryPlansHeader)); !wanted || db == nil {
+		return false
+	}
+	userID, _, err := userIDFromRequest(r)
+	if err != nil {
+		return false
+	}
+	role, err := repositories.NewUserRepository(db).FindRole(userID)
+	if err != nil || role != types.RoleAdmin {
+		securitylog.PermissionDenied(r, "query plans need the admin role")
+		return false
+	}
+	return true
+}
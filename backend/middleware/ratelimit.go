@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/ratelimit"
+	"github.com/SteaceP/coderage/securitylog"
+	"github.com/SteaceP/coderage/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ServiceKeyHeader is the header carrying service account API keys
+const ServiceKeyHeader = "X-API-Key"
+
+// RateLimit limits the number of requests per client. Clients are identified
+// by user ID when authenticated, and by remote address otherwise.
+//
+// Requests exempted by the policy bypass the limiter. Every exemption granted
+// to an account is logged, and attached to the request context so handlers
+// can skip their quotas too.
+func RateLimit(db *gorm.DB, limiter *ratelimit.Limiter, policy *ratelimit.Policy, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			exemption, key := classifyRequest(r, db, policy)
+			if exemption != nil {
+				// Health probes are too frequent to be worth auditing
+				if exemption.Reason != ratelimit.ReasonHealthProbe {
+					logger.Info("Rate limit exemption",
+						zap.String("reason", exemption.Reason),
+						zap.String("subject", exemption.Subject),
+						zap.String("method", r.Method),
+						zap.String("path", r.URL.Path),
+						zap.String("remote_addr", r.RemoteAddr),
+						zap.String("request_id", apperrors.RequestID(r)),
+					)
+				}
+				ctx := context.WithValue(r.Context(), types.KeyExemption, exemption)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			result := limiter.Allow(key)
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(result.Reset.Unix(), 10))
+
+			if !result.Allowed {
+				retryAfter := int(time.Until(result.Reset).Seconds()) + 1
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				securitylog.RateLimited(r, key)
+				apperrors.Error(w, r, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// classifyRequest returns the exemption of the request if it has one, or the
+// key the request is rate limited under.
+func classifyRequest(r *http.Request, db *gorm.DB, policy *ratelimit.Policy) (*ratelimit.Exemption, string) {
+	if exemption, ok := policy.ForPath(r.URL.Path); ok {
+		return exemption, ""
+	}
+
+	if exemption, ok := policy.ForServiceKey(r.Header.Get(ServiceKeyHeader)); ok {
+		return exemption, ""
+	}
+
+	if userID, _, err := userIDFromRequest(r); err == nil {
+		// Use the role stored for the token subject rather than anything
+		// supplied with the request
+		var role string
+		if db != nil {
+			db.WithContext(r.Context()).Model(&models.User{}).Select("role").Where("id = ?", userID).Scan(&role)
+		}
+		if exemption, ok := policy.ForUser(userID, role); ok {
+			return exemption, ""
+		}
+		return nil, fmt.Sprintf("user:%d", userID)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return nil, "ip:" + host
+}
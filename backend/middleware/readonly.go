@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/database"
+)
+
+// ReadOnly answers 503 Service Unavailable, with the read_only error code,
+// to the requests that may write while the site is read-only. Reads, and
+// requests to the exempt paths, such as logging in or the read-only switch
+// itself, are always served.
+func ReadOnly(readOnly *database.ReadOnly, exempt ...string) func(http.Handler) http.Handler {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			state := readOnly.State()
+			if !state.Enabled || exemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			apperrors.WriteCode(w, r, http.StatusServiceUnavailable, "read_only", state.Message)
+		})
+	}
+}
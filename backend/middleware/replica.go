@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SteaceP/coderage/database"
+	"github.com/SteaceP/coderage/types"
+
+	"gorm.io/gorm"
+)
+
+// ReadReplica runs the reads of a route on the read replicas, see
+// database.Replica. It replaces the database connection of the request
+// context, which the handler scopes its services to, and must come after
+// the authentication middlewares, so sessions are checked against the
+// primary database and a revoked one is turned away at once.
+func ReadReplica(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if db, ok := ctx.Value(types.KeyDB).(*gorm.DB); ok {
+			ctx = context.WithValue(ctx, types.KeyDB, database.Replica(db))
+		}
+		next(w, r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,270 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/config"
+)
+
+// Headers negotiating the rendering of JSON responses
+const (
+	EnvelopeHeader  = "X-Response-Envelope"
+	FieldCaseHeader = "X-Field-Case"
+)
+
+// Field cases
+const (
+	FieldCaseSnake = "snake"
+	FieldCaseCamel = "camel"
+)
+
+// responseFormat is the rendering of JSON responses requested by a client.
+type responseFormat struct {
+	envelope bool
+	camel    bool
+	version  int
+	aliases  []FieldAlias // Fields to rename back for the version
+}
+
+// ResponseFormat renders JSON responses in the format requested by the
+// client, falling back to the "api.envelope" and "api.field_case" settings.
+//
+// Clients can ask for successful responses to be wrapped in a
+// {"data": ..., "meta": ...} envelope, and for camelCase field names. Clients
+// using camelCase may send request bodies in camelCase too. Clients of an
+// earlier API version, the "api.version" setting by default, get the fields
+// renamed since under their previous names. Responses of other content
+// types, such as feeds or the OpenAPI document, are untouched, and so are
+// the requests and responses of the exempt paths, such as the GraphQL API
+// whose format is set by its schema.
+func ResponseFormat(exempt ...string) func(http.Handler) http.Handler {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			format := negotiateFormat(r)
+			w.Header().Add("Vary", EnvelopeHeader+", "+FieldCaseHeader+", "+APIVersionHeader)
+			w.Header().Set(APIVersionHeader, strconv.Itoa(format.version))
+			if !format.envelope && !format.camel && len(format.aliases) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if format.camel && isJSON(r.Header.Get("Content-Type")) && r.Body != nil {
+				if body, err := io.ReadAll(r.Body); err == nil {
+					r.Body = io.NopCloser(bytes.NewReader(convertKeys(body, snakeCase)))
+					r.ContentLength = -1
+					r.Header.Del("Content-Length")
+				}
+			}
+
+			bw := &bufferedResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(bw, r)
+			bw.flush(r, format)
+		})
+	}
+}
+
+// negotiateFormat returns the response format requested by the request.
+func negotiateFormat(r *http.Request) responseFormat {
+	format := responseFormat{
+		envelope: config.Get().API.Envelope,
+		camel:    config.Get().API.FieldCase == FieldCaseCamel,
+	}
+	if v := r.Header.Get(EnvelopeHeader); v != "" {
+		if envelope, err := strconv.ParseBool(v); err == nil {
+			format.envelope = envelope
+		}
+	}
+	switch strings.ToLower(r.Header.Get(FieldCaseHeader)) {
+	case FieldCaseCamel:
+		format.camel = true
+	case FieldCaseSnake:
+		format.camel = false
+	}
+	format.version = negotiateVersion(r)
+	format.aliases = routeAliases(r, format.version)
+	return format
+}
+
+// bufferedResponseWriter holds back the response so it can be rewritten.
+// Responses flushed before the end, such as event streams, are written
+// through unless they are JSON.
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (bw *bufferedResponseWriter) WriteHeader(status int) {
+	if !bw.streaming {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if bw.streaming {
+		return bw.ResponseWriter.Write(b)
+	}
+	return bw.body.Write(b)
+}
+
+// FlushError writes the response through from now on and flushes it, unless
+// it is JSON, which is only written once complete.
+func (bw *bufferedResponseWriter) FlushError() error {
+	if !bw.streaming {
+		if isJSON(bw.Header().Get("Content-Type")) {
+			return nil
+		}
+		bw.streaming = true
+		bw.ResponseWriter.WriteHeader(bw.status)
+		if _, err := bw.ResponseWriter.Write(bw.body.Bytes()); err != nil {
+			return err
+		}
+		bw.body.Reset()
+	}
+	return http.NewResponseController(bw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (bw *bufferedResponseWriter) Unwrap() http.ResponseWriter {
+	return bw.ResponseWriter
+}
+
+// flush writes the buffered response, converted to the requested format when
+// it is JSON.
+func (bw *bufferedResponseWriter) flush(r *http.Request, format responseFormat) {
+	if bw.streaming {
+		return
+	}
+	body := bw.body.Bytes()
+	if isJSON(bw.Header().Get("Content-Type")) && len(body) > 0 {
+		if len(format.aliases) > 0 && bw.status < http.StatusBadRequest {
+			body = applyAliases(body, format.aliases)
+			bw.Header().Set("Deprecation", "true")
+		}
+		if format.envelope && bw.status < http.StatusBadRequest {
+			body = envelope(r, body)
+		}
+		if format.camel {
+			body = convertKeys(body, camelCase)
+		}
+		bw.Header().Del("Content-Length")
+	}
+
+	bw.ResponseWriter.WriteHeader(bw.status)
+	bw.ResponseWriter.Write(body)
+}
+
+// envelope wraps a response body in a {"data": ..., "meta": ...} envelope.
+// Pagination details move to the meta object.
+func envelope(r *http.Request, body []byte) []byte {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return body
+	}
+
+	meta := map[string]interface{}{}
+	if id := apperrors.RequestID(r); id != "" {
+		meta["request_id"] = id
+	}
+	if object, ok := data.(map[string]interface{}); ok {
+		if pagination, ok := object["pagination"]; ok {
+			meta["pagination"] = pagination
+			delete(object, "pagination")
+		}
+	}
+
+	wrapped, err := json.Marshal(map[string]interface{}{"data": data, "meta": meta})
+	if err != nil {
+		return body
+	}
+	return append(wrapped, '\n')
+}
+
+// convertKeys renames the object keys of a JSON document with the given
+// function. The document is returned unchanged if it isn't valid JSON.
+func convertKeys(body []byte, rename func(string) string) []byte {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return body
+	}
+
+	converted, err := json.Marshal(renameKeys(data, rename))
+	if err != nil {
+		return body
+	}
+	return append(converted, '\n')
+}
+
+func renameKeys(v interface{}, rename func(string) string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			renamed[rename(key)] = renameKeys(value, rename)
+		}
+		return renamed
+	case []interface{}:
+		for i, value := range v {
+			v[i] = renameKeys(value, rename)
+		}
+		return v
+	}
+	return v
+}
+
+// camelCase converts a snake_case name to camelCase, e.g. "total_pages" to
+// "totalPages".
+func camelCase(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// snakeCase converts a camelCase name to snake_case, e.g. "totalPages" to
+// "total_pages".
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, c := range s {
+		if unicode.IsUpper(c) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// isJSON reports whether a content type is plain JSON.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/securitylog"
+	"github.com/SteaceP/coderage/types"
+	"gorm.io/gorm"
+)
+
+// RequireRole lets requests through only when the authenticated user has one
+// of the given roles. It must run after AuthMiddleware, and reads the role
+// from the database so that role changes apply to existing tokens.
+func RequireRole(db *gorm.DB, roles ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(types.KeyUserID).(uint)
+			if !ok {
+				apperrors.Error(w, r, errMissingToken.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			// Check database connection
+			if db == nil {
+				apperrors.Error(w, r, errDBUnavailable.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			role, err := repositories.NewUserRepository(db.WithContext(r.Context())).FindRole(userID)
+			if err != nil {
+				securitylog.PermissionDenied(r, "user not found")
+				apperrors.Error(w, r, "Forbidden", http.StatusForbidden)
+				return
+			}
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			securitylog.PermissionDenied(r, "role "+role+" is not allowed")
+			apperrors.Error(w, r, "Forbidden", http.StatusForbidden)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/ratelimit"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/storage"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/webhooks"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SandboxHeader asks for a request to run in sandbox mode
+const SandboxHeader = "X-Sandbox"
+
+// Sandbox runs sandbox requests inside a database transaction that is rolled
+// back once the handler has replied. The handler performs its usual
+// validation and policy checks and returns what would have happened, but
+// nothing is stored, no email or webhook is sent and uploaded files are
+// discarded. The services attached run on the transaction, so Sandbox must
+// come before Services.
+//
+// A request is in sandbox mode when it sets the X-Sandbox header to true, or
+// when it is made with a service key holding the sandbox scope. Responses to
+// sandbox requests carry "X-Sandbox: true".
+func Sandbox(db *gorm.DB, store storage.Storage, policy *ratelimit.Policy, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sandboxed, _ := strconv.ParseBool(r.Header.Get(SandboxHeader))
+			subject := SandboxHeader
+			if key, ok := policy.SandboxKey(r.Header.Get(ServiceKeyHeader)); ok {
+				sandboxed = true
+				subject = "service_key:" + key
+			}
+			if !sandboxed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger.Info("Sandbox request",
+				zap.String("subject", subject),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("request_id", apperrors.RequestID(r)),
+			)
+
+			// Check database connection
+			if db == nil {
+				apperrors.Error(w, r, errDBUnavailable.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			tx := db.WithContext(r.Context()).Begin()
+			if tx.Error != nil {
+				apperrors.Error(w, r, "Failed to start sandbox transaction", http.StatusInternalServerError)
+				return
+			}
+			defer func() {
+				if err := tx.Rollback().Error; err != nil {
+					logger.Error("Sandbox rollback failed",
+						zap.String("request_id", apperrors.RequestID(r)),
+						zap.Error(err),
+					)
+				}
+			}()
+
+			ctx := context.WithValue(r.Context(), types.KeyDB, tx)
+			ctx = context.WithValue(ctx, types.KeyMailer, mailer.Discard)
+			ctx = context.WithValue(ctx, types.KeyStorage, storage.NewSandboxStorage(store))
+			ctx = context.WithValue(ctx, types.KeyServices, services.New(tx, mailer.Discard, webhooks.Discard, events.Discard, nil, nil, logger))
+
+			w.Header().Set(SandboxHeader, "true")
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
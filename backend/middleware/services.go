@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/types"
+)
+
+// Services attaches the application services to the request context, unless
+// services are already attached, such as the ones of a sandbox request. The
+// handlers scope them to the database connection of the request, once the
+// middlewares have set it up.
+func Services(svc *services.Services) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value(types.KeyServices).(*services.Services); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := context.WithValue(r.Context(), types.KeyServices, svc)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SteaceP/coderage/storage"
+	"github.com/SteaceP/coderage/types"
+)
+
+// Storage attaches the file storage to the request context.
+func Storage(s storage.Storage) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), types.KeyStorage, s)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
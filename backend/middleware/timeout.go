@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+
+	"github.com/gorilla/mux"
+)
+
+// timeoutGrace is the time left to reply once a request timed out, before
+// the connection is closed
+const timeoutGrace = 5 * time.Second
+
+// TimeoutGroup is a group of routes sharing a timeout: the routes whose path
+// template starts with Prefix, such as "/admin/". A nil Timeout lets the
+// requests of the group run until the client goes away.
+type TimeoutGroup struct {
+	Prefix  string
+	Timeout func(*config.Config) time.Duration
+}
+
+// Timeout cancels the context of requests running longer than the timeout
+// of their route group, or fallback for the configuration in effect if
+// their route is in none. A zero timeout disables it. The longest matching
+// prefix wins.
+//
+// The queries of the handler are cancelled with the context, their error
+// replied as 504, but the handler itself is not interrupted. The write
+// deadline of the server is moved to the timeout, plus a grace period to
+// reply, so groups can be given more time than the server allows by
+// default. It must come before the middlewares attaching the database
+// connection and the services, which are scoped to the request context.
+func Timeout(fallback func(*config.Config) time.Duration, groups ...TimeoutGroup) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := routeTimeout(r, fallback, groups)
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Writers that can't move the deadline keep the one of the server
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + timeoutGrace))
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// routeTimeout returns the timeout of the route group of a request.
+func routeTimeout(r *http.Request, fallback func(*config.Config) time.Duration, groups []TimeoutGroup) time.Duration {
+	route := r.URL.Path
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			route = template
+		}
+	}
+
+	timeout := fallback
+	matched := -1
+	for _, group := range groups {
+		if strings.HasPrefix(route, group.Prefix) && len(group.Prefix) > matched {
+			timeout = group.Timeout
+			matched = len(group.Prefix)
+		}
+	}
+	if timeout == nil {
+		return 0
+	}
+	return timeout(config.Get())
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/tracing"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// Tracing records a span for every request, named after the template of the
+// route it matched, continuing the trace of the caller if any.
+//
+// The database connection of the request context is replaced by a copy
+// carrying the span, so the queries run by the handler, and by the services
+// it scopes to the connection, are traced as its children. It must
+// therefore come after Database, and before Sandbox, whose transaction
+// starts with the context of the request.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				attribute.String("request.id", apperrors.RequestID(r)),
+			),
+		)
+		defer span.End()
+
+		if db, ok := ctx.Value(types.KeyDB).(*gorm.DB); ok {
+			ctx = context.WithValue(ctx, types.KeyDB, db.WithContext(ctx))
+		}
+
+		crw := &customResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(crw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(crw.status))
+		if crw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(crw.status))
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/SteaceP/coderage/database"
+	"github.com/SteaceP/coderage/models"
+
+	"go.uber.org/zap"
+)
+
+// migrationMessage is given to the clients turned away during migrations
+const migrationMessage = "The site is read-only during an upgrade, please try again in a few minutes"
+
+// migrateUsage describes the migrate command. The schema is migrated from
+// the models on every start, so the command doesn't revert migrations: a
+// reverted schema would be migrated back by the next instance starting.
+const migrateUsage = `Usage:
+  api migrate [up]   Migrate the database schema, with the site read-only meanwhile
+`
+
+// runMigrateCommand migrates the database schema with the site read-only,
+// and returns the exit code. The running instances turn writes away within
+// their read_only.poll_interval, which is waited for before migrating. The
+// site stays read-only if the migration fails.
+func runMigrateCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "up" {
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		fmt.Fprint(stderr, migrateUsage)
+		return 2
+	}
+
+	// Load configuration, and connect to the database
+	cfg, logger, db, err := bootstrap(false)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer logger.Sync()
+
+	// The switch lives in the site settings, whose table is migrated first
+	// so the switch exists when upgrading from a version without it
+	if err := db.AutoMigrate(&models.SiteSettings{}); err != nil {
+		logger.Error("Database migrations failed", zap.Error(err))
+		return 1
+	}
+	if err := database.SetReadOnly(db, true, migrationMessage); err != nil {
+		logger.Error("Failed to turn the read-only mode on", zap.Error(err))
+		return 1
+	}
+	logger.Info("Read-only mode on, waiting for the instances to apply it", zap.Duration("poll_interval", cfg.ReadOnly.PollInterval))
+	time.Sleep(cfg.ReadOnly.PollInterval)
+
+	started := time.Now()
+	if err := database.RunMigrations(db); err != nil {
+		logger.Error("Database migrations failed, the site stays read-only until PUT /admin/read-only turns it off", zap.Error(err))
+		return 1
+	}
+	if err := database.SetReadOnly(db, false, ""); err != nil {
+		logger.Error("Failed to turn the read-only mode off", zap.Error(err))
+		return 1
+	}
+	fmt.Fprintf(stdout, "Migrated the database in %s\n", time.Since(started).Round(time.Millisecond))
+	return 0
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Announcement severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Announcement audiences
+const (
+	AudienceAll           = "all"
+	AudienceAuthenticated = "authenticated"
+	AudienceMembers       = "members" // Members and admins
+	AudienceAdmins        = "admins"
+)
+
+// Announcement is a sitewide banner, such as a maintenance notice, shown to
+// its audience between its start and end times.
+type Announcement struct {
+	gorm.Model
+	Message     string     `json:"message" validate:"required,max=500"`
+	Severity    string     `json:"severity" validate:"oneof=info warning critical" gorm:"default:info"`
+	Audience    string     `json:"audience" validate:"oneof=all authenticated members admins" gorm:"default:all"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"` // Shown right away when unset
+	EndsAt      *time.Time `json:"ends_at,omitempty"`   // Shown until deleted when unset
+	CreatedByID uint       `json:"created_by_id"`
+}
+
+// TableName overrides the table name used by Announcement to `announcements`
+func (Announcement) TableName() string {
+	return "announcements"
+}
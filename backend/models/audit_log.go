@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditLog records a security-relevant action, such as a login, a password
+// change or an admin changing the role of a user. Entries are never updated
+// or deleted. ActorID is zero for actions without an authenticated actor,
+// such as failed logins.
+type AuditLog struct {
+	ID         uint                   `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time              `json:"created_at"`
+	ActorID    uint                   `json:"actor_id" gorm:"index"`
+	Action     string                 `json:"action" gorm:"index"`
+	TargetType string                 `json:"target_type"`
+	TargetID   uint                   `json:"target_id"`
+	Details    map[string]interface{} `json:"details,omitempty" gorm:"serializer:json;type:text"`
+	RemoteAddr string                 `json:"remote_addr"`
+	RequestID  string                 `json:"request_id"`
+}
+
+// TableName overrides the table name used by AuditLog to `audit_logs`
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
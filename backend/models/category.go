@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Category is a section of the site. Categories form a tree, and a post is
+// filed under at most one of them, unlike tags.
+type Category struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Name        string     `json:"name" validate:"required,max=50"`
+	Slug        string     `json:"slug" gorm:"uniqueIndex" validate:"max=60"`
+	Description string     `json:"description,omitempty" validate:"max=500"`
+	ParentID    *uint      `json:"parent_id,omitempty" gorm:"index"` // Unset for top-level categories
+	Position    int        `json:"position" gorm:"default:0"`        // Order among the categories of the same parent
+	Children    []Category `json:"children,omitempty" gorm:"-"`
+	PostCount   int64      `json:"post_count,omitempty" gorm:"-"` // Posts filed directly under the category
+}
+
+// TableName overrides the table name used by Category to `categories`
+func (Category) TableName() string {
+	return "categories"
+}
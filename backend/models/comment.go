@@ -1,9 +1,18 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// Comment statuses
+const (
+	CommentPublished = "published"
+	CommentHidden    = "hidden" // Only visible to moderators
+	CommentDeleted   = "deleted"
+)
+
 type Comment struct {
 	gorm.Model
 	Content   string    `json:"content" validate:"required,min=1,max=500"`
@@ -16,6 +25,21 @@ type Comment struct {
 	Replies   []Comment `json:"replies,omitempty" gorm:"foreignKey:ParentID"`
 	Status    string    `json:"status" validate:"oneof=published hidden deleted" default:"published"`
 	LikeCount int       `json:"like_count" gorm:"default:0"`
+	Liked     bool      `json:"liked" gorm:"-"` // Whether the requesting user liked the comment
+	// Moderation
+	ModeratedByID    *uint      `json:"moderated_by_id,omitempty"`
+	ModeratedAt      *time.Time `json:"moderated_at,omitempty"`
+	ModerationReason string     `json:"moderation_reason,omitempty"`
+	// Open reports, awaiting review
+	ReportCount int             `json:"report_count" gorm:"default:0"`
+	Reports     []CommentReport `json:"reports,omitempty" gorm:"foreignKey:CommentID"`
+	// Set once the author deleted their account
+	Anonymized bool `json:"anonymized" gorm:"default:false"`
+}
+
+// Visible reports whether the comment is shown to regular readers.
+func (c *Comment) Visible() bool {
+	return c.Status != CommentHidden && c.Status != CommentDeleted
 }
 
 // TableName overrides the table name used by Comment to `comments`
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// CommentLike records that a user liked a comment. The composite unique index
+// guarantees a user can like a given comment at most once.
+type CommentLike struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_comment_likes_user_comment" validate:"required"`
+	CommentID uint      `json:"comment_id" gorm:"uniqueIndex:idx_comment_likes_user_comment;index" validate:"required"`
+}
+
+// TableName overrides the table name used by CommentLike to `comment_likes`
+func (CommentLike) TableName() string {
+	return "comment_likes"
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// Report reasons
+const (
+	ReportSpam       = "spam"
+	ReportHarassment = "harassment"
+	ReportHate       = "hate_speech"
+	ReportOffTopic   = "off_topic"
+	ReportOther      = "other"
+)
+
+// CommentReport records that a user reported a comment as abusive. The
+// composite unique index guarantees a user can report a given comment at
+// most once. Reports stay open until a moderator reviews the comment.
+type CommentReport struct {
+	ID           uint       `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CommentID    uint       `json:"comment_id" gorm:"uniqueIndex:idx_comment_reports_comment_user;index"`
+	UserID       uint       `json:"user_id" gorm:"uniqueIndex:idx_comment_reports_comment_user"`
+	Reason       string     `json:"reason"`
+	Details      string     `json:"details,omitempty"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	ResolvedByID *uint      `json:"resolved_by_id,omitempty"`
+}
+
+// TableName overrides the table name used by CommentReport to `comment_reports`
+func (CommentReport) TableName() string {
+	return "comment_reports"
+}
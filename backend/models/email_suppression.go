@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// EmailSuppression records an address emails aren't sent to anymore, as it
+// bounced or its recipient complained, so the reputation of the sender stays
+// healthy.
+type EmailSuppression struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Email     string    `json:"email" gorm:"size:255;not null;uniqueIndex"` // Lower cased
+	Reason    string    `json:"reason" gorm:"size:20;not null"`             // bounce or complaint
+	Provider  string    `json:"provider" gorm:"size:20"`                    // Email provider that reported it
+	Detail    string    `json:"detail,omitempty" gorm:"type:text"`          // Diagnostic given by the provider
+}
+
+// TableName overrides the table name used by EmailSuppression to `email_suppressions`
+func (EmailSuppression) TableName() string {
+	return "email_suppressions"
+}
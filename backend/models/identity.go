@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Identity links a user to an account of an external login provider, such as
+// Google or GitHub. A provider account is linked to a single user, and a user
+// links at most one account of each provider. Logging in with a password
+// needs no identity, only the password of the user.
+type Identity struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	CreatedAt time.Time `json:"linked_at"`
+	UserID    uint      `json:"-" gorm:"not null;uniqueIndex:idx_identities_user_provider"`
+	Provider  string    `json:"provider" gorm:"size:20;not null;uniqueIndex:idx_identities_user_provider;uniqueIndex:idx_identities_provider_subject"`
+	Subject   string    `json:"-" gorm:"size:255;not null;uniqueIndex:idx_identities_provider_subject"` // ID of the account at the provider
+	Email     string    `json:"email"`                                                                  // Email address of the account at the provider
+}
+
+// TableName overrides the table name used by Identity to `identities`
+func (Identity) TableName() string {
+	return "identities"
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Integration is an external service, such as an email-to-comment bridge or
+// a comment importer, allowed to create comments by posting signed payloads
+// to its inbound webhook.
+type Integration struct {
+	gorm.Model
+	Name        string `json:"name"`
+	Secret      string `json:"-"`         // Verifies the payloads, only returned on creation
+	Moderated   bool   `json:"moderated"` // Comments are held hidden until a moderator publishes them
+	Description string `json:"description,omitempty"`
+}
+
+// TableName overrides the table name used by Integration to `integrations`
+func (Integration) TableName() string {
+	return "integrations"
+}
+
+// IntegrationComment links a comment to its ID in the external service, so
+// a payload sent twice creates a single comment, and replies find the
+// comment they answer.
+type IntegrationComment struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	IntegrationID uint   `gorm:"uniqueIndex:idx_integration_comments_external_id"`
+	ExternalID    string `gorm:"size:255;uniqueIndex:idx_integration_comments_external_id"`
+	CommentID     uint   `gorm:"index"`
+}
+
+// TableName overrides the table name used by IntegrationComment to
+// `integration_comments`
+func (IntegrationComment) TableName() string {
+	return "integration_comments"
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// LinkPreview caches the metadata of a web page, shown as a preview of the
+// links in posts and comments. A failed fetch is cached too, with its error,
+// so the page isn't requested again on every view.
+type LinkPreview struct {
+	ID          uint      `json:"-" gorm:"primarykey"`
+	URLHash     string    `json:"-" gorm:"size:64;uniqueIndex"` // SHA-256 of the requested URL
+	URL         string    `json:"url" gorm:"type:text"`         // Canonical URL of the page
+	Title       string    `json:"title"`
+	Description string    `json:"description" gorm:"type:text"`
+	Image       string    `json:"image" gorm:"type:text"`
+	SiteName    string    `json:"site_name"`
+	Type        string    `json:"type"`
+	Error       string    `json:"-"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// TableName overrides the table name used by LinkPreview to `link_previews`
+func (LinkPreview) TableName() string {
+	return "link_previews"
+}
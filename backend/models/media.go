@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Media is an uploaded file, such as a featured image or a profile picture.
+type Media struct {
+	gorm.Model
+	UserID       uint   `json:"user_id" gorm:"index"`
+	User         User   `json:"-" gorm:"foreignKey:UserID"`
+	Backend      string `json:"backend"` // Storage backend holding the file
+	StorageKey   string `json:"storage_key" gorm:"uniqueIndex"`
+	URL          string `json:"url"`
+	OriginalName string `json:"original_name"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	ContentHash  string `json:"content_hash" gorm:"size:64"` // Hex encoded SHA-256 of the content, empty for files uploaded before it was recorded
+	// Dimensions of images, 0 for files uploaded before they were recorded
+	Width    int            `json:"width,omitempty" gorm:"default:0"`
+	Height   int            `json:"height,omitempty" gorm:"default:0"`
+	Variants []MediaVariant `json:"variants,omitempty" gorm:"foreignKey:MediaID"`
+}
+
+// TableName overrides the table name used by Media to `media`
+func (Media) TableName() string {
+	return "media"
+}
+
+// MediaVariant is a resized or WebP version of an uploaded image, stored
+// next to it.
+type MediaVariant struct {
+	ID          uint      `json:"-" gorm:"primarykey"`
+	CreatedAt   time.Time `json:"-"`
+	MediaID     uint      `json:"-" gorm:"index"`
+	Name        string    `json:"name" gorm:"size:32"` // Size of the variant, "original" for the WebP version of the image
+	StorageKey  string    `json:"-" gorm:"uniqueIndex"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	Size        int64     `json:"size"`
+}
+
+// TableName overrides the table name used by MediaVariant to
+// `media_variants`
+func (MediaVariant) TableName() string {
+	return "media_variants"
+}
+
+// ImageSet describes an uploaded image and its variants for responsive
+// images: each source lists the widths available in a format as a srcset,
+// WebP first when available.
+type ImageSet struct {
+	Src      string         `json:"src"`
+	Width    int            `json:"width,omitempty"`
+	Height   int            `json:"height,omitempty"`
+	Sources  []ImageSource  `json:"sources"`
+	Variants []MediaVariant `json:"variants"`
+}
+
+// ImageSource lists the versions of an image in a format, as the srcset
+// attribute of a <source> element.
+type ImageSource struct {
+	Type   string `json:"type"`
+	Srcset string `json:"srcset"` // e.g. "https://.../a-medium.webp 600w, https://.../a.webp 1600w"
+}
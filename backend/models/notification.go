@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification tells a user about activity on their content. Repeated
+// activity on the same subject, such as several likes on one comment, is
+// grouped into a single notification until the user reads it.
+type Notification struct {
+	gorm.Model
+	UserID    uint   `json:"user_id" gorm:"index:idx_notifications_user_group"` // Recipient
+	Type      string `json:"type"`
+	GroupKey  string `json:"-" gorm:"index:idx_notifications_user_group"` // Subject grouping the activity
+	PostID    *uint  `json:"post_id,omitempty"`
+	CommentID *uint  `json:"comment_id,omitempty"`
+	// Count is the number of grouped events, and ActorCount the number of
+	// distinct users behind them
+	Count          int        `json:"count" gorm:"default:1"`
+	ActorCount     int        `json:"actor_count" gorm:"default:1"`
+	LatestActorIDs []uint     `json:"latest_actor_ids" gorm:"serializer:json;type:text"` // Most recent first
+	LatestAt       time.Time  `json:"latest_at"`
+	ReadAt         *time.Time `json:"read_at,omitempty"`
+	DigestedAt     *time.Time `json:"-"`                         // Last included in an email digest
+	Actors         []User     `json:"actors,omitempty" gorm:"-"` // Loaded from LatestActorIDs
+}
+
+// TableName overrides the table name used by Notification to `notifications`
+func (Notification) TableName() string {
+	return "notifications"
+}
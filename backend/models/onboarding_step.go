@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// OnboardingStep records when a user completed a step of the onboarding,
+// such as verifying their email address. Steps are recorded once, the first
+// time they are found completed.
+type OnboardingStep struct {
+	ID          uint      `json:"-" gorm:"primarykey"`
+	UserID      uint      `json:"-" gorm:"not null;uniqueIndex:idx_onboarding_steps_user_step"`
+	Step        string    `json:"step" gorm:"size:50;not null;uniqueIndex:idx_onboarding_steps_user_step"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// TableName overrides the table name used by OnboardingStep to `onboarding_steps`
+func (OnboardingStep) TableName() string {
+	return "onboarding_steps"
+}
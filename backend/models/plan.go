@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Plan caps the content of the site, for operators hosting it on free and
+// paid tiers. A limit of 0 is unlimited.
+type Plan struct {
+	ID              uint      `json:"id" gorm:"primarykey"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	Name            string    `json:"name" gorm:"uniqueIndex;size:50" validate:"required,max=50"`
+	MaxPosts        int64     `json:"max_posts"`
+	MaxComments     int64     `json:"max_comments"`
+	MaxStorageBytes int64     `json:"max_storage_bytes"` // Total size of the uploaded files
+	MaxMembers      int64     `json:"max_members"`
+}
+
+// TableName overrides the table name used by Plan to `plans`
+func (Plan) TableName() string {
+	return "plans"
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"github.com/SteaceP/coderage/licenses"
+
 	"gorm.io/gorm"
 )
 
@@ -17,16 +19,56 @@ type Post struct {
 	Comments        []Comment `json:"comments,omitempty"`
 	PublishedAt     time.Time `json:"published_at"`
 	Status          string    `json:"status" validate:"oneof=draft published archived" default:"draft"`
-	Tags            []string  `json:"tags" gorm:"type:text[]"`
+	Tags            []Tag     `json:"tags" gorm:"many2many:post_tags"`
+	CategoryID      *uint     `json:"category_id,omitempty" gorm:"index"`
+	Category        *Category `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
 	ViewCount       int       `json:"view_count" gorm:"default:0"`
 	LikeCount       int       `json:"like_count" gorm:"default:0"`
 	CommentCount    int       `json:"comment_count" gorm:"default:0"`
 	FeaturedImage   string    `json:"featured_image,omitempty"`
 	MetaTitle       string    `json:"meta_title,omitempty" validate:"max=60"`
 	MetaDescription string    `json:"meta_description,omitempty" validate:"max=160"`
+	Sensitive       bool      `json:"sensitive" gorm:"default:false"`
+	// Only members can see the post until then, others once it has passed
+	MembersOnlyUntil *time.Time        `json:"members_only_until,omitempty"`
+	License          string            `json:"-"`                                   // License identifier, empty to use the site default
+	Locale           string            `json:"locale" gorm:"size:35"`               // Language of the post, empty for the site locale
+	Translations     []string          `json:"translations,omitempty" gorm:"-"`     // Locales the post is translated to
+	Series           *SeriesNavigation `json:"series,omitempty" gorm:"-"`           // Place of the post in its series
+	LicenseInfo      *licenses.License `json:"license" gorm:"-"`                    // Effective license, resolved against the site default
+	ContentGated     bool              `json:"content_gated,omitempty" gorm:"-"`    // Content withheld until sensitive content is acknowledged
+	ContentMarkdown  string            `json:"content_markdown,omitempty" gorm:"-"` // Content as written, when rendered
+	ContentHTML      string            `json:"content_html,omitempty" gorm:"-"`     // Content rendered to sanitized HTML
+	// Users credited as authors of the post, the lead author first
+	Authors []PostAuthor `json:"authors,omitempty" gorm:"foreignKey:PostID"`
+	// Variants of the featured image, when it was uploaded
+	FeaturedImageSet *ImageSet `json:"featured_image_set,omitempty" gorm:"-"`
 }
 
 // TableName overrides the table name used by Post to `posts`
 func (Post) TableName() string {
 	return "posts"
 }
+
+// AuthorRole returns the role of a user among the authors of the post, empty
+// when they aren't one of them. The lead author, who created the post, is
+// always an author.
+func (p *Post) AuthorRole(userID uint) string {
+	if userID == 0 {
+		return ""
+	}
+	if p.UserID == userID {
+		return AuthorRoleAuthor
+	}
+	for _, author := range p.Authors {
+		if author.UserID == userID {
+			return author.Role
+		}
+	}
+	return ""
+}
+
+// InEarlyAccess reports whether the post is still only visible to members.
+func (p *Post) InEarlyAccess() bool {
+	return p.MembersOnlyUntil != nil && p.MembersOnlyUntil.After(time.Now())
+}
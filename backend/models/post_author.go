@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Post author roles
+const (
+	AuthorRoleAuthor      = "author"      // Can edit, publish and delete the post
+	AuthorRoleContributor = "contributor" // Can edit the post
+)
+
+// PostAuthor credits a user as an author of a post, with their role. The
+// user who created the post is its lead author, the one managing the
+// others.
+type PostAuthor struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	PostID    uint      `json:"-" gorm:"uniqueIndex:idx_post_authors_post_user"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_post_authors_post_user;index"`
+	User      User      `json:"user" gorm:"foreignKey:UserID"`
+	Role      string    `json:"role"`
+}
+
+// TableName overrides the table name used by PostAuthor to `post_authors`
+func (PostAuthor) TableName() string {
+	return "post_authors"
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// PostTranslation is a post translated to another language, served to the
+// readers preferring it. Fields left empty fall back to those of the post.
+type PostTranslation struct {
+	ID              uint      `json:"id" gorm:"primarykey"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	PostID          uint      `json:"post_id" gorm:"uniqueIndex:idx_post_translations_locale"`
+	Locale          string    `json:"locale" gorm:"size:35;uniqueIndex:idx_post_translations_locale"` // BCP 47 language tag, e.g. fr or pt-BR
+	Title           string    `json:"title"`
+	Content         string    `json:"content"`
+	Excerpt         string    `json:"excerpt,omitempty"`
+	MetaTitle       string    `json:"meta_title,omitempty"`
+	MetaDescription string    `json:"meta_description,omitempty"`
+	ContentGated    bool      `json:"content_gated,omitempty" gorm:"-"` // Content withheld until sensitive content is acknowledged
+}
+
+// TableName overrides the table name used by PostTranslation to
+// `post_translations`
+func (PostTranslation) TableName() string {
+	return "post_translations"
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PostViewDay counts the views of a post during a day, in UTC. Views of the
+// same visitor within the deduplication window count once, and views of
+// known bots don't count.
+type PostViewDay struct {
+	ID     uint      `json:"-" gorm:"primarykey"`
+	PostID uint      `json:"post_id" gorm:"not null;uniqueIndex:idx_post_view_days_post_day"`
+	Day    time.Time `json:"day" gorm:"not null;uniqueIndex:idx_post_view_days_post_day;index"` // Midnight UTC
+	Views  int64     `json:"views" gorm:"not null;default:0"`
+}
+
+// TableName overrides the table name used by PostViewDay to `post_view_days`
+func (PostViewDay) TableName() string {
+	return "post_view_days"
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Referral records a user who signed up with the referral code of another.
+type Referral struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReferrerID uint      `json:"referrer_id" gorm:"not null;index"`
+	ReferredID uint      `json:"referred_id" gorm:"not null;uniqueIndex"` // A user is referred once
+	Code       string    `json:"code" gorm:"size:16;not null"`            // Code used on sign-up
+	Referred   *User     `json:"referred,omitempty" gorm:"foreignKey:ReferredID"`
+}
+
+// TableName overrides the table name used by Referral to `referrals`
+func (Referral) TableName() string {
+	return "referrals"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SearchQuery records a search of posts, for the search analytics. The
+// searcher isn't recorded, only the normalized query, the number of results
+// and the first result clicked, if any.
+type SearchQuery struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"index"`
+	Query         string     `json:"query" gorm:"size:100;not null;index"` // Lower cased, with single spaces
+	ResultCount   int64      `json:"result_count"`
+	ClickedPostID *uint      `json:"clicked_post_id,omitempty"`
+	ClickedAt     *time.Time `json:"clicked_at,omitempty"`
+}
+
+// TableName overrides the table name used by SearchQuery to `search_queries`
+func (SearchQuery) TableName() string {
+	return "search_queries"
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+// Series is an ordered collection of posts of an author, such as the parts
+// of a tutorial. A post belongs to at most one series.
+type Series struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Title       string    `json:"title" validate:"required,max=200"`
+	Slug        string    `json:"slug" gorm:"uniqueIndex" validate:"max=200"`
+	Description string    `json:"description,omitempty" validate:"max=500"`
+	UserID      uint      `json:"user_id" gorm:"index"`
+	Posts       []Post    `json:"posts" gorm:"-"` // In the order of the series
+}
+
+// TableName overrides the table name used by Series to `series`
+func (Series) TableName() string {
+	return "series"
+}
+
+// SeriesPost is the position of a post in its series.
+type SeriesPost struct {
+	ID       uint `gorm:"primarykey"`
+	SeriesID uint `gorm:"index"`
+	PostID   uint `gorm:"uniqueIndex"`
+	Position int  // From 0
+}
+
+// TableName overrides the table name used by SeriesPost to `series_posts`
+func (SeriesPost) TableName() string {
+	return "series_posts"
+}
+
+// SeriesNavigation places a post in its series, linking to the posts before
+// and after it.
+type SeriesNavigation struct {
+	ID       uint        `json:"id"`
+	Slug     string      `json:"slug"`
+	Title    string      `json:"title"`
+	Position int         `json:"position"` // From 1
+	Total    int         `json:"total"`
+	Previous *SeriesLink `json:"previous,omitempty"`
+	Next     *SeriesLink `json:"next,omitempty"`
+}
+
+// SeriesLink links to a post of a series.
+type SeriesLink struct {
+	ID    uint   `json:"id"`
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
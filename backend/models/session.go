@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Session is a login of a user on a device, kept while its refresh token can
+// be used. Refresh tokens are rotated: only the one issued last is accepted,
+// and using an older one revokes the session. Sessions issued before the
+// token version of the user changed, on a password change or a "log out
+// everywhere", are no longer valid.
+type Session struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time `json:"created_at"`
+	UserID       uint      `json:"-" gorm:"not null;index"`
+	TokenVersion int       `json:"-" gorm:"not null;default:0"`
+	RefreshUUID  string    `json:"-" gorm:"size:36;not null"` // ID of the refresh token issued last
+	UserAgent    string    `json:"user_agent" gorm:"size:512"`
+	IP           string    `json:"ip" gorm:"size:45"` // Stored as geoip.StoredIP
+	LastUsedAt   time.Time `json:"last_used_at"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+}
+
+// TableName overrides the table name used by Session to `sessions`
+func (Session) TableName() string {
+	return "sessions"
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// RobotsRule is a group of robots.txt directives for a user agent.
+type RobotsRule struct {
+	UserAgent  string   `json:"user_agent"`
+	Allow      []string `json:"allow,omitempty"`
+	Disallow   []string `json:"disallow,omitempty"`
+	CrawlDelay int      `json:"crawl_delay,omitempty"`
+}
+
+// SiteSettings holds the runtime settings of the site. There is a single row,
+// created from the configured defaults the first time it is read.
+type SiteSettings struct {
+	gorm.Model
+	RegistrationOpen bool   `json:"registration_open"`
+	RequireApproval  bool   `json:"require_approval"`
+	CommentsEnabled  bool   `json:"comments_enabled"`
+	SensitiveGating  bool   `json:"sensitive_gating"`
+	DefaultLicense   string `json:"default_license"`
+	ReferralsEnabled bool   `json:"referrals_enabled"`
+	// Crawler and /.well-known settings
+	RobotsRules     []RobotsRule `json:"robots_rules" gorm:"serializer:json;type:text"`
+	SecurityContact string       `json:"security_contact"`
+	// Branding
+	LogoMediaID    *uint  `json:"logo_media_id,omitempty"`
+	FaviconMediaID *uint  `json:"favicon_media_id,omitempty"`
+	PrimaryColor   string `json:"primary_color" gorm:"size:7"` // Hex color, e.g. #1a73e8
+	AccentColor    string `json:"accent_color" gorm:"size:7"`
+	CustomHead     string `json:"custom_head" gorm:"type:text"` // Sanitized tags added to the <head> of pages
+	// Dates, stored in UTC and presented in the time zone of the site
+	Timezone   string `json:"timezone" gorm:"size:64"` // IANA name, e.g. Europe/Paris
+	DateFormat string `json:"date_format" gorm:"size:16"`
+	// Plan capping the content of the site, unlimited when unset
+	PlanID *uint `json:"plan_id,omitempty"`
+	// Read-only switch, turning writes away while it is on
+	ReadOnly        bool   `json:"read_only" gorm:"default:false"`
+	ReadOnlyMessage string `json:"read_only_message,omitempty"`
+}
+
+// TableName overrides the table name used by SiteSettings to `site_settings`
+func (SiteSettings) TableName() string {
+	return "site_settings"
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name" gorm:"uniqueIndex" validate:"required,max=50"`
+	Slug      string    `json:"slug" gorm:"uniqueIndex"`
+	Posts     []Post    `json:"posts,omitempty" gorm:"many2many:post_tags"`
+	PostCount int64     `json:"post_count,omitempty" gorm:"-"`
+}
+
+// TableName overrides the table name used by Tag to `tags`
+func (Tag) TableName() string {
+	return "tags"
+}
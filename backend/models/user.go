@@ -14,17 +14,28 @@ type User struct {
 	FirstName      string     `json:"first_name,omitempty" validate:"max=50"`
 	LastName       string     `json:"last_name,omitempty" validate:"max=50"`
 	Bio            string     `json:"bio,omitempty" validate:"max=500"`
-	ProfilePicture string     `json:"profile_picture,omitempty"`
+	ProfilePicture string     `json:"profile_picture,omitempty" validate:"omitempty,url,max=255"`
 	Role           string     `json:"role" validate:"oneof=user editor admin" default:"user"`
 	LastLogin      *time.Time `json:"last_login,omitempty"`
 	IsActive       bool       `json:"is_active" gorm:"default:true"`
 	VerifiedAt     *time.Time `json:"verified_at,omitempty"`
-	Posts          []Post     `json:"posts,omitempty"`
-	Comments       []Comment  `json:"comments,omitempty"`
+	// Set to bounce or complaint once emails stopped being sent to the address
+	EmailStatus string `json:"email_status,omitempty" gorm:"size:20;default:''"`
+	// Set on sign-up while the site requires new accounts to be approved
+	PendingApproval bool       `json:"pending_approval" gorm:"default:false"`
+	ShowSensitive   bool       `json:"show_sensitive" gorm:"default:false"` // Acknowledged sensitive content once for all
+	EmailDigest     bool       `json:"email_digest" gorm:"default:false"`   // Emailed a digest of their unread notifications
+	Member          bool       `json:"member" gorm:"default:false"`         // Members get early access to posts
+	Guest           bool       `json:"-" gorm:"default:false"`              // Created for a guest comment, reused by the guest until claimed
+	ReferralCode    *string    `json:"-" gorm:"uniqueIndex;size:16"`        // Generated the first time it is requested
+	TokenVersion    int        `json:"-" gorm:"default:0"`                  // Refresh tokens of older versions are rejected
+	PurgeAfter      *time.Time `json:"-" gorm:"index"`                      // Personal data of deleted accounts is erased then
+	Posts           []Post     `json:"posts,omitempty"`
+	Comments        []Comment  `json:"comments,omitempty"`
 	// Social links
-	TwitterHandle   string `json:"twitter_handle,omitempty"`
-	LinkedInProfile string `json:"linkedin_profile,omitempty"`
-	PersonalWebsite string `json:"personal_website,omitempty"`
+	TwitterHandle   string `json:"twitter_handle,omitempty" validate:"max=15"`
+	LinkedInProfile string `json:"linkedin_profile,omitempty" validate:"omitempty,url,max=255"`
+	PersonalWebsite string `json:"personal_website,omitempty" validate:"omitempty,url,max=255"`
 }
 
 // TableName overrides the table name used by User to `users`
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// VerificationToken is a single-use token sent by email to verify a user's
+// email address. Only the hash of the token is stored.
+type VerificationToken struct {
+	ID        uint       `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// TableName overrides the table name used by VerificationToken to `verification_tokens`
+func (VerificationToken) TableName() string {
+	return "verification_tokens"
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook is an endpoint registered by an integrator to receive events.
+type Webhook struct {
+	gorm.Model
+	URL         string   `json:"url"`
+	Secret      string   `json:"-"`                                       // Signs the payloads, only returned on creation
+	Events      []string `json:"events" gorm:"serializer:json;type:text"` // "*" subscribes to every event
+	Active      bool     `json:"active" gorm:"default:true"`
+	Description string   `json:"description,omitempty"`
+}
+
+// TableName overrides the table name used by Webhook to `webhooks`
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Subscribes reports whether the webhook receives events of the given type.
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, e := range w.Events {
+		if e == "*" || e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records an attempt to deliver an event to a webhook, with
+// what was sent and received so integrators can debug their endpoint.
+type WebhookDelivery struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time `json:"created_at"`
+	WebhookID    uint      `json:"webhook_id" gorm:"index"`
+	GUID         string    `json:"guid" gorm:"uniqueIndex"` // Sent in the X-Coderage-Delivery header
+	EventType    string    `json:"event_type"`
+	RedeliveryOf *uint     `json:"redelivery_of,omitempty"` // Delivery replayed by this one
+	// Automatic attempt delivering the event, 0 for the tests and the manual
+	// redeliveries. Failed attempts are retried at NextRetryAt.
+	Attempt     int        `json:"attempt,omitempty"`
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty" gorm:"index"`
+	Success     bool       `json:"success"`
+	DurationMs  int64      `json:"duration_ms"`
+	Error       string     `json:"error,omitempty"`
+	// Request and response
+	RequestHeaders  map[string]string `json:"request_headers" gorm:"serializer:json;type:text"`
+	RequestBody     string            `json:"request_body" gorm:"type:text"`
+	StatusCode      int               `json:"status_code"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty" gorm:"serializer:json;type:text"`
+	ResponseBody    string            `json:"response_body,omitempty" gorm:"type:text"`
+	// Signature debugging
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	Signature          string `json:"signature"`
+	PayloadSHA256      string `json:"payload_sha256"`
+	SecretFingerprint  string `json:"secret_fingerprint"`
+}
+
+// TableName overrides the table name used by WebhookDelivery to `webhook_deliveries`
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,184 @@
+// Package oauth lets users log in with their account of an external
+// provider, Google or GitHub, through the OAuth 2.0 authorization code flow.
+//
+// The site sends users to the authorization URL of a provider, which
+// redirects them back to the site with a code. The code is exchanged by the
+// API for an access token, used once to read the profile of the account.
+// Provider tokens aren't kept. Providers returned by Callback redirect users
+// to the API instead, which then completes the login itself.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// Providers
+const (
+	Google = "google"
+	GitHub = "github"
+)
+
+// maxProfileSize bounds the profile responses read
+const maxProfileSize = 1 << 20
+
+var (
+	// ErrUnknownProvider is returned for providers that don't exist or aren't
+	// configured.
+	ErrUnknownProvider = errors.New("unknown login provider")
+	// ErrExchangeFailed is returned when the provider rejects the
+	// authorization code, or the profile of the account can't be read.
+	ErrExchangeFailed = errors.New("login with the provider failed")
+)
+
+// Profile is the account of a user at a provider.
+type Profile struct {
+	Provider string
+	// Subject identifies the account at the provider, and never changes
+	Subject string
+	// Email is the primary email address of the account, empty when the
+	// provider doesn't share it
+	Email string
+	// EmailVerified is set when the provider verified Email
+	EmailVerified bool
+	// Login is the user name of the account, or its display name
+	Login string
+}
+
+// Provider is a login provider.
+type Provider struct {
+	name    string
+	config  *oauth2.Config
+	client  *http.Client
+	profile func(ctx context.Context, client *http.Client) (*Profile, error)
+}
+
+// Enabled returns the names of the configured providers.
+func Enabled() []string {
+	var names []string
+	for _, name := range []string{Google, GitHub} {
+		if _, err := Get(name); err == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Get returns the provider of the given name configured from the "oauth"
+// settings. It returns ErrUnknownProvider when the provider isn't
+// configured.
+func Get(name string) (*Provider, error) {
+	cfg := config.Get().OAuth
+	p := &Provider{
+		name:   name,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+
+	var settings config.OAuthProviderConfig
+	var endpoint oauth2.Endpoint
+	var scopes []string
+	switch name {
+	case Google:
+		settings, endpoint, p.profile = cfg.Google, endpoints.Google, googleProfile
+		scopes = []string{"openid", "email", "profile"}
+	case GitHub:
+		settings, endpoint, p.profile = cfg.GitHub, endpoints.GitHub, githubProfile
+		scopes = []string{"read:user", "user:email"}
+	default:
+		return nil, ErrUnknownProvider
+	}
+	if settings.ClientID == "" {
+		return nil, ErrUnknownProvider
+	}
+
+	p.config = &oauth2.Config{
+		ClientID:     settings.ClientID,
+		ClientSecret: settings.ClientSecret,
+		RedirectURL:  settings.RedirectURL,
+		Endpoint:     endpoint,
+		Scopes:       scopes,
+	}
+	return p, nil
+}
+
+// Callback returns the provider of the given name, redirecting users to the
+// callback endpoint of the API instead of the configured redirect URL, for
+// logins the API drives itself.
+func Callback(name string) (*Provider, error) {
+	p, err := Get(name)
+	if err != nil {
+		return nil, err
+	}
+	config := *p.config
+	config.RedirectURL = CallbackURL(name)
+	p.config = &config
+	return p, nil
+}
+
+// CallbackURL returns the URL of the callback endpoint of a provider.
+func CallbackURL(name string) string {
+	return strings.TrimRight(config.Get().Server.BaseURL, "/") + "/auth/" + name + "/callback"
+}
+
+// Name returns the name of the provider.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL returns the URL of the provider users are sent to, to allow
+// the site to read their profile. The provider redirects them to the
+// configured redirect URL with state, which the site checks to be the one it
+// sent.
+func (p *Provider) AuthCodeURL(state string) string {
+	return p.config.AuthCodeURL(state)
+}
+
+// Exchange exchanges an authorization code for an access token, and returns
+// the profile of the account that granted it.
+func (p *Provider) Exchange(ctx context.Context, code string) (*Profile, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+
+	profile, err := p.profile(ctx, p.config.Client(ctx, token))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+	if profile.Subject == "" {
+		return nil, fmt.Errorf("%w: profile has no account ID", ErrExchangeFailed)
+	}
+	profile.Provider = p.name
+	return profile, nil
+}
+
+// getJSON decodes the JSON response to a GET request.
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxProfileSize)).Decode(v)
+}
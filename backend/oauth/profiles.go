@@ -0,0 +1,67 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// Profile endpoints of the providers
+const (
+	googleUserInfoURL = "https://openidconnect.googleapis.com/v1/userinfo"
+	githubUserURL     = "https://api.github.com/user"
+	githubEmailsURL   = "https://api.github.com/user/emails"
+)
+
+// googleProfile reads the OpenID Connect profile of a Google account.
+func googleProfile(ctx context.Context, client *http.Client) (*Profile, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := getJSON(ctx, client, googleUserInfoURL, &info); err != nil {
+		return nil, err
+	}
+	return &Profile{
+		Subject:       info.Sub,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+		Login:         info.Name,
+	}, nil
+}
+
+// githubProfile reads the profile of a GitHub account. The email address of
+// the profile is the public one, so the primary address is read from the
+// addresses of the account instead.
+func githubProfile(ctx context.Context, client *http.Client) (*Profile, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := getJSON(ctx, client, githubUserURL, &user); err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, client, githubEmailsURL, &emails); err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{Login: user.Login}
+	if user.ID != 0 {
+		profile.Subject = strconv.FormatInt(user.ID, 10)
+	}
+	for _, email := range emails {
+		if email.Primary {
+			profile.Email = email.Email
+			profile.EmailVerified = email.Verified
+		}
+	}
+	return profile, nil
+}
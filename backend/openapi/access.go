@@ -0,0 +1,78 @@
+package openapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// authNames names the authentication requirements in access matrices
+var authNames = map[int]string{
+	AuthNone:     "none",
+	AuthRequired: "required",
+	AuthOptional: "optional",
+}
+
+// Access describes who may call a route registered on the router.
+type Access struct {
+	Method  string   `json:"method"`
+	Path    string   `json:"path"`
+	Summary string   `json:"summary,omitempty"`
+	Auth    string   `json:"auth"`            // none, required or optional
+	Roles   []string `json:"roles,omitempty"` // Empty when any authenticated user may call it
+	// Documented reports whether the route is described by the registry.
+	// Undocumented routes are listed as public, and should be reviewed.
+	Documented bool `json:"documented"`
+}
+
+// AccessMatrix returns the access of every route of the router, sorted by
+// path and method. Roles are those of the registry, or else those required
+// for every route under a path prefix of prefixRoles, e.g. "/admin/".
+func AccessMatrix(router *mux.Router, registry Registry, prefixRoles map[string][]string) ([]Access, error) {
+	var matrix []Access
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		path := pathVariable.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			desc, documented := registry[method+" "+template]
+			access := Access{
+				Method:     method,
+				Path:       path,
+				Summary:    desc.Summary,
+				Auth:       authNames[desc.Auth],
+				Roles:      desc.Roles,
+				Documented: documented,
+			}
+			if access.Roles == nil {
+				for prefix, roles := range prefixRoles {
+					if strings.HasPrefix(path, prefix) {
+						access.Roles = roles
+					}
+				}
+			}
+			matrix = append(matrix, access)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk routes: %v", err)
+	}
+
+	sort.SliceStable(matrix, func(i, j int) bool {
+		if matrix[i].Path != matrix[j].Path {
+			return matrix[i].Path < matrix[j].Path
+		}
+		return matrix[i].Method < matrix[j].Method
+	})
+	return matrix, nil
+}
@@ -0,0 +1,188 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Authentication requirements of a route
+const (
+	AuthNone = iota
+	AuthRequired
+	AuthOptional
+)
+
+// bearerScheme is the name of the JWT security scheme
+const bearerScheme = "bearerAuth"
+
+// pathVariable matches the variables of mux path templates
+var pathVariable = regexp.MustCompile(`\{([^}:]+)(:[^}]+)?\}`)
+
+// Route describes a route registered on the router.
+type Route struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Auth        int
+	Roles       []string // Roles allowed to call the route, when restricted
+	Query       []Parameter
+	Request     interface{} // Value whose type is the JSON request body
+	Response    interface{} // Value whose type is the JSON success response
+	Status      int         // Success status, http.StatusOK when unset
+	Statuses    []int       // Other success statuses, answered with the same response
+	ContentType string      // Content type of non JSON responses
+}
+
+// Registry describes routes, keyed by "METHOD /path/template".
+type Registry map[string]Route
+
+// Build returns the document describing every route of the router. Routes
+// missing from the registry are still listed, so the document never drifts
+// from the routes actually served.
+func Build(router *mux.Router, registry Registry, info Info, servers ...Server) (*Document, error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Servers: servers,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+	gen := newSchemaGenerator()
+	tags := make(map[string]bool)
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			// Prefix-only routes such as static files aren't API operations
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		path := pathVariable.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			desc := registry[method+" "+template]
+			op := buildOperation(gen, method, path, desc)
+			for _, tag := range op.Tags {
+				tags[tag] = true
+			}
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(PathItem)
+			}
+			doc.Paths[path][strings.ToLower(method)] = op
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk routes: %v", err)
+	}
+
+	for tag := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: tag})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	doc.Components.Schemas = gen.schemas
+	return doc, nil
+}
+
+// buildOperation builds the operation of a route from its description.
+func buildOperation(gen *schemaGenerator, method, path string, desc Route) *Operation {
+	op := &Operation{
+		OperationID: operationID(method, path),
+		Summary:     desc.Summary,
+		Description: desc.Description,
+		Tags:        desc.Tags,
+		Parameters:  append([]Parameter(nil), desc.Query...),
+		Responses:   make(map[string]Response),
+	}
+	if len(op.Tags) == 0 {
+		op.Tags = []string{defaultTag(path)}
+	}
+	for i := range op.Parameters {
+		if op.Parameters[i].In == "" {
+			op.Parameters[i].In = "query"
+		}
+	}
+
+	for _, match := range pathVariable.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	if desc.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: gen.schemaOf(desc.Request)}},
+		}
+	}
+
+	status := desc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	switch {
+	case desc.ContentType != "":
+		success.Content = map[string]MediaType{desc.ContentType: {}}
+	case desc.Response != nil:
+		success.Content = map[string]MediaType{"application/json": {Schema: gen.schemaOf(desc.Response)}}
+	}
+	op.Responses[fmt.Sprint(status)] = success
+	for _, other := range desc.Statuses {
+		op.Responses[fmt.Sprint(other)] = Response{Description: http.StatusText(other), Content: success.Content}
+	}
+	op.Responses["default"] = Response{
+		Description: "Error, its message translated to the language of the Accept-Language header when available",
+		Content:     map[string]MediaType{"application/json": {Schema: gen.schemaOf(errorResponse{})}},
+	}
+
+	switch desc.Auth {
+	case AuthRequired:
+		op.Security = []map[string][]string{{bearerScheme: {}}}
+	case AuthOptional:
+		// An empty requirement marks authentication as optional
+		op.Security = []map[string][]string{{}, {bearerScheme: {}}}
+	}
+	return op
+}
+
+// errorResponse mirrors the error body written by the apperrors package.
+type errorResponse struct {
+	Error struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	} `json:"error"`
+}
+
+// defaultTag returns the tag of a path from its first segment.
+func defaultTag(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	segment = strings.TrimSuffix(segment, ".xml")
+	if segment == "" || strings.HasPrefix(segment, "{") {
+		return "default"
+	}
+	return segment
+}
+
+// operationID derives a stable operation ID from a method and path, e.g.
+// "get_posts_id_comments".
+func operationID(method, path string) string {
+	replacer := strings.NewReplacer("/", "_", "{", "", "}", "", ".", "_", "-", "_")
+	return strings.ToLower(method) + strings.TrimRight(replacer.Replace(path), "_")
+}
@@ -0,0 +1,71 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+
+	"github.com/SteaceP/coderage/apperrors"
+)
+
+// swaggerUIVersion is the Swagger UI release loaded from the CDN
+const swaggerUIVersion = "5.17.14"
+
+var swaggerUI = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: {{.SpecURL}}, dom_id: '#swagger-ui' });
+    };
+  </script>
+</body>
+</html>
+`))
+
+// SpecHandler serves the document as JSON. The document is built on the
+// first request, once every route has been registered.
+func SpecHandler(build func() (*Document, error)) http.HandlerFunc {
+	var (
+		once sync.Once
+		spec []byte
+		err  error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			var doc *Document
+			if doc, err = build(); err == nil {
+				spec, err = json.Marshal(doc)
+			}
+		})
+		if err != nil {
+			apperrors.Error(w, r, fmt.Sprintf("Failed to build API specification: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.oai.openapi+json;version=3.0")
+		w.WriteHeader(http.StatusOK)
+		w.Write(spec)
+	}
+}
+
+// UIHandler serves a Swagger UI page for the document at specURL.
+func UIHandler(title, specURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		swaggerUI.Execute(w, map[string]string{
+			"Title":   title,
+			"Version": swaggerUIVersion,
+			"SpecURL": specURL,
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package presence
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/SteaceP/coderage/config"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrTooManyStreams is returned when joining while the instance already holds
+// the most streams allowed by "presence.max_streams".
+var ErrTooManyStreams = errors.New("too many live streams")
+
+// Hub tracks the readers streaming posts from this instance. Each stream
+// joins the hub as a reader, refreshes its presence at every heartbeat and
+// leaves when it ends. Streams are told to end when the server shuts down,
+// so they don't hold the shutdown.
+type Hub struct {
+	store  Store
+	logger *zap.Logger
+	open   atomic.Int64
+
+	closing chan struct{}
+	once    sync.Once
+}
+
+// NewHub returns a new instance of Hub counting the readers in store.
+func NewHub(store Store, logger *zap.Logger) *Hub {
+	return &Hub{store: store, logger: logger, closing: make(chan struct{})}
+}
+
+// Join adds a reader to the post, and returns it.
+func (h *Hub) Join(ctx context.Context, postID uint) (string, error) {
+	if limit := config.Get().Presence.MaxStreams; limit > 0 && h.open.Load() >= int64(limit) {
+		return "", ErrTooManyStreams
+	}
+
+	reader := uuid.New().String()
+	if err := h.store.Touch(ctx, postID, reader, config.Get().Presence.TTL); err != nil {
+		h.logger.Error("Failed to add a reader", zap.Uint("post_id", postID), zap.Error(err))
+		return "", err
+	}
+	h.open.Add(1)
+	return reader, nil
+}
+
+// Heartbeat refreshes the presence of a reader, and returns the number of
+// readers of the post. Failures are only logged, the reader staying until
+// its presence expires, and reported by ok.
+func (h *Hub) Heartbeat(ctx context.Context, postID uint, reader string) (readers int, ok bool) {
+	if err := h.store.Touch(ctx, postID, reader, config.Get().Presence.TTL); err != nil {
+		h.logger.Warn("Failed to refresh a reader", zap.Uint("post_id", postID), zap.Error(err))
+		return 0, false
+	}
+	readers, err := h.store.Count(ctx, postID)
+	if err != nil {
+		h.logger.Warn("Failed to count the readers", zap.Uint("post_id", postID), zap.Error(err))
+		return 0, false
+	}
+	return readers, true
+}
+
+// Leave removes a reader from the post, even when the stream was canceled.
+func (h *Hub) Leave(ctx context.Context, postID uint, reader string) {
+	h.open.Add(-1)
+	if err := h.store.Leave(context.WithoutCancel(ctx), postID, reader); err != nil {
+		h.logger.Warn("Failed to remove a reader", zap.Uint("post_id", postID), zap.Error(err))
+	}
+}
+
+// Open returns the number of streams open on this instance.
+func (h *Hub) Open() int64 {
+	return h.open.Load()
+}
+
+// Closing returns a channel closed when the streams must end.
+func (h *Hub) Closing() <-chan struct{} {
+	return h.closing
+}
+
+// Close tells the streams to end. The store is left open for them to leave.
+func (h *Hub) Close() {
+	h.once.Do(func() { close(h.closing) })
+}
@@ -0,0 +1,70 @@
+package presence
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the readers in memory, so each instance only counts the
+// readers it serves.
+type MemoryStore struct {
+	mu      sync.Mutex
+	readers map[uint]map[string]time.Time // Expiry of the readers of each post
+}
+
+// NewMemoryStore returns a new instance of MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{readers: make(map[uint]map[string]time.Time)}
+}
+
+// Touch marks the reader present on the post for ttl.
+func (s *MemoryStore) Touch(_ context.Context, postID uint, reader string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	readers, ok := s.readers[postID]
+	if !ok {
+		readers = make(map[string]time.Time)
+		s.readers[postID] = readers
+	}
+	readers[reader] = time.Now().Add(ttl)
+	return nil
+}
+
+// Leave removes the reader from the post.
+func (s *MemoryStore) Leave(_ context.Context, postID uint, reader string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.readers[postID], reader)
+	if len(s.readers[postID]) == 0 {
+		delete(s.readers, postID)
+	}
+	return nil
+}
+
+// Count returns the number of readers present on the post, dropping the
+// expired ones.
+func (s *MemoryStore) Count(_ context.Context, postID uint) (int, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	readers := s.readers[postID]
+	for reader, expiry := range readers {
+		if now.After(expiry) {
+			delete(readers, reader)
+		}
+	}
+	if len(readers) == 0 {
+		delete(s.readers, postID)
+	}
+	return len(readers), nil
+}
+
+// Close does nothing, the readers being held in memory.
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,39 @@
+package presence
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+)
+
+// Store keeps the readers present on posts. A reader stays present until it
+// leaves or its presence expires, so the readers of a crashed instance drop
+// out on their own.
+type Store interface {
+	// Touch marks the reader present on the post for ttl.
+	Touch(ctx context.Context, postID uint, reader string, ttl time.Duration) error
+	// Leave removes the reader from the post.
+	Leave(ctx context.Context, postID uint, reader string) error
+	// Count returns the number of readers present on the post.
+	Count(ctx context.Context, postID uint) (int, error)
+	// Close releases any connection.
+	Close() error
+}
+
+// NewStore returns the store selected by the "presence.driver" configuration
+// key. Readers are counted in memory by default; "redis" shares the counts
+// of every instance.
+func NewStore(ctx context.Context) (Store, error) {
+	cfg := config.Get().Presence
+
+	switch driver := cfg.Driver; driver {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "redis":
+		return NewRedisStore(ctx, cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Redis.KeyPrefix)
+	default:
+		return nil, fmt.Errorf("unsupported presence driver: %s", driver)
+	}
+}
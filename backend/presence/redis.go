@@ -0,0 +1,90 @@
+package presence
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// connectTimeout bounds the check of the Redis connection at startup
+const connectTimeout = 5 * time.Second
+
+// RedisStore keeps the readers in Redis, so every instance counts the readers
+// of all of them. The readers of a post are a sorted set scored by their
+// expiry, which expires itself once its last reader does.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore connects to the Redis server at addr and returns a new
+// RedisStore.
+func NewRedisStore(ctx context.Context, addr, password string, db int, prefix string) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+
+	return &RedisStore{client: client, prefix: prefix}, nil
+}
+
+// Touch marks the reader present on the post for ttl.
+func (s *RedisStore) Touch(ctx context.Context, postID uint, reader string, ttl time.Duration) error {
+	key := s.key(postID)
+	expiry := time.Now().Add(ttl)
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(expiry.UnixMilli()), Member: reader})
+		pipe.PExpire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+// Leave removes the reader from the post.
+func (s *RedisStore) Leave(ctx context.Context, postID uint, reader string) error {
+	return s.client.ZRem(ctx, s.key(postID), reader).Err()
+}
+
+// Count returns the number of readers present on the post, dropping the
+// expired ones.
+func (s *RedisStore) Count(ctx context.Context, postID uint) (int, error) {
+	key := s.key(postID)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	var count *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", now)
+		count = pipe.ZCard(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(count.Val()), nil
+}
+
+// Close closes the connection to Redis.
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// key returns the key of the readers of a post.
+func (s *RedisStore) key(postID uint) string {
+	key := "presence:post:" + strconv.FormatUint(uint64(postID), 10)
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + ":" + key
+}
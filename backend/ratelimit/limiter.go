@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is an in-memory fixed window rate limiter keyed by client.
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mu       sync.Mutex
+	counters map[string]*counter
+	swept    time.Time
+}
+
+type counter struct {
+	count int
+	reset time.Time
+}
+
+// Result describes the outcome of a rate limit check.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// NewLimiter returns a new instance of Limiter allowing limit requests per
+// window for each key.
+func NewLimiter(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:    limit,
+		window:   window,
+		counters: make(map[string]*counter),
+		swept:    time.Now(),
+	}
+}
+
+// Allow records a request for the key and reports whether it is within the
+// limit of the current window.
+func (l *Limiter) Allow(key string) Result {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop expired counters once per window so idle clients don't pile up
+	if now.Sub(l.swept) > l.window {
+		for k, c := range l.counters {
+			if now.After(c.reset) {
+				delete(l.counters, k)
+			}
+		}
+		l.swept = now
+	}
+
+	c, ok := l.counters[key]
+	if !ok || now.After(c.reset) {
+		c = &counter{reset: now.Add(l.window)}
+		l.counters[key] = c
+	}
+	c.count++
+
+	remaining := l.limit - c.count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Result{
+		Allowed:   c.count <= l.limit,
+		Limit:     l.limit,
+		Remaining: remaining,
+		Reset:     c.reset,
+	}
+}
@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/utils"
+)
+
+// Service key scopes
+const (
+	// ScopeExempt bypasses rate limits and quotas
+	ScopeExempt = "ratelimit:exempt"
+	// ScopeSandbox runs every request of the key in sandbox mode, so that
+	// integrators can test against production without changing its data
+	ScopeSandbox = "sandbox"
+)
+
+// Exemption reasons
+const (
+	ReasonHealthProbe = "health_probe"
+	ReasonServiceKey  = "service_key"
+	ReasonRole        = "role"
+)
+
+// Exemption records why a request bypasses rate limits and quotas.
+type Exemption struct {
+	Reason  string
+	Subject string
+}
+
+// ServiceKey is an API key issued to a service account. Only the SHA-256
+// hash of the key is configured.
+type ServiceKey config.ServiceKeyConfig
+
+// Policy decides which requests are exempt from rate limits and quotas.
+//
+// Exemptions are only granted from server-side facts: the request path, a
+// configured service key, or the role stored for an authenticated user.
+// Nothing the client can set freely, such as forwarded headers or custom
+// claims, is taken into account.
+type Policy struct {
+	exemptPaths map[string]bool
+	exemptRoles map[string]bool
+	serviceKeys []ServiceKey
+}
+
+// NewPolicy returns a new instance of Policy built from the "rate_limit"
+// configuration.
+func NewPolicy() (*Policy, error) {
+	cfg := config.Get().RateLimit
+	keys := make([]ServiceKey, 0, len(cfg.ServiceKeys))
+	for _, key := range cfg.ServiceKeys {
+		if key.Name == "" || key.KeyHash == "" {
+			return nil, fmt.Errorf("service keys require a name and a key_hash")
+		}
+		keys = append(keys, ServiceKey(key))
+	}
+
+	p := &Policy{
+		exemptPaths: make(map[string]bool),
+		exemptRoles: make(map[string]bool),
+		serviceKeys: keys,
+	}
+	for _, path := range cfg.ExemptPaths {
+		p.exemptPaths[path] = true
+	}
+	for _, role := range cfg.ExemptRoles {
+		p.exemptRoles[role] = true
+	}
+	return p, nil
+}
+
+// ForPath returns an exemption for health probe paths.
+func (p *Policy) ForPath(path string) (*Exemption, bool) {
+	if !p.exemptPaths[path] {
+		return nil, false
+	}
+	return &Exemption{Reason: ReasonHealthProbe, Subject: path}, true
+}
+
+// ForServiceKey returns an exemption when the key matches a configured
+// service key holding the exempt scope.
+func (p *Policy) ForServiceKey(key string) (*Exemption, bool) {
+	sk, ok := p.serviceKey(key)
+	if !ok || !sk.hasScope(ScopeExempt) {
+		return nil, false
+	}
+	return &Exemption{Reason: ReasonServiceKey, Subject: sk.Name}, true
+}
+
+// SandboxKey returns the name of the service key matching key when it holds
+// the sandbox scope.
+func (p *Policy) SandboxKey(key string) (string, bool) {
+	sk, ok := p.serviceKey(key)
+	if !ok || !sk.hasScope(ScopeSandbox) {
+		return "", false
+	}
+	return sk.Name, true
+}
+
+// serviceKey returns the configured service key matching key.
+func (p *Policy) serviceKey(key string) (*ServiceKey, bool) {
+	if key == "" {
+		return nil, false
+	}
+
+	hash := utils.HashToken(key)
+	for i, sk := range p.serviceKeys {
+		if subtle.ConstantTimeCompare([]byte(strings.ToLower(sk.KeyHash)), []byte(hash)) == 1 {
+			return &p.serviceKeys[i], true
+		}
+	}
+	return nil, false
+}
+
+func (sk *ServiceKey) hasScope(scope string) bool {
+	for _, s := range sk.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ForUser returns an exemption when the stored role of the user is exempt.
+func (p *Policy) ForUser(userID uint, role string) (*Exemption, bool) {
+	if !p.exemptRoles[role] {
+		return nil, false
+	}
+	return &Exemption{Reason: ReasonRole, Subject: fmt.Sprintf("user:%d (%s)", userID, role)}, true
+}
+
+// Summary describes the exemptions of a policy. Service keys are listed by
+// name only.
+type Summary struct {
+	ExemptPaths       []string `json:"exempt_paths"`
+	ExemptRoles       []string `json:"exempt_roles"`
+	ExemptServiceKeys []string `json:"exempt_service_keys"`
+}
+
+// Summary returns the exemptions of the policy, sorted.
+func (p *Policy) Summary() Summary {
+	summary := Summary{
+		ExemptPaths:       make([]string, 0, len(p.exemptPaths)),
+		ExemptRoles:       make([]string, 0, len(p.exemptRoles)),
+		ExemptServiceKeys: []string{},
+	}
+	for path := range p.exemptPaths {
+		summary.ExemptPaths = append(summary.ExemptPaths, path)
+	}
+	for role := range p.exemptRoles {
+		summary.ExemptRoles = append(summary.ExemptRoles, role)
+	}
+	for i := range p.serviceKeys {
+		if p.serviceKeys[i].hasScope(ScopeExempt) {
+			summary.ExemptServiceKeys = append(summary.ExemptServiceKeys, p.serviceKeys[i].Name)
+		}
+	}
+	sort.Strings(summary.ExemptPaths)
+	sort.Strings(summary.ExemptRoles)
+	sort.Strings(summary.ExemptServiceKeys)
+	return summary
+}
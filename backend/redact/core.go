@@ -0,0 +1,85 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SteaceP/coderage/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// core redacts the entries written to the core it wraps.
+type core struct {
+	zapcore.Core
+}
+
+// Core wraps a zap core so the fields and messages of its entries are
+// redacted, while the "logging.redact" setting is on. Install it with
+// zap.WrapCore.
+func Core(c zapcore.Core) zapcore.Core {
+	return &core{Core: c}
+}
+
+// With redacts the fields added to the context of the logger.
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{Core: c.Core.With(Fields(fields))}
+}
+
+// Check adds the redacting core to the entry if the wrapped core would log
+// it, so Write is called on the redacting core.
+func (c *core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write redacts the entry before writing it to the wrapped core.
+func (c *core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if config.Get().Logging.Redact {
+		entry.Message = String(entry.Message)
+	}
+	return c.Core.Write(entry, Fields(fields))
+}
+
+// Fields returns a copy of fields with the credentials redacted, while the
+// "logging.redact" setting is on.
+func Fields(fields []zapcore.Field) []zapcore.Field {
+	if !config.Get().Logging.Redact {
+		return fields
+	}
+
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = Field(field)
+	}
+	return redacted
+}
+
+// Field returns field with its value redacted: entirely when its key names
+// a credential, otherwise by scrubbing the credentials from its strings.
+func Field(field zapcore.Field) zapcore.Field {
+	if Sensitive(field.Key) {
+		return zap.String(field.Key, Placeholder)
+	}
+
+	switch field.Type {
+	case zapcore.StringType:
+		field.String = String(field.String)
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok && err != nil {
+			if message := String(err.Error()); message != err.Error() {
+				return zap.NamedError(field.Key, errors.New(message))
+			}
+		}
+	case zapcore.StringerType:
+		if stringer, ok := field.Interface.(fmt.Stringer); ok {
+			return zap.String(field.Key, String(stringer.String()))
+		}
+	case zapcore.ReflectType:
+		return zap.Any(field.Key, Value(field.Interface))
+	}
+	return field
+}
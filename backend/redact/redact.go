@@ -0,0 +1,128 @@
+// Package redact removes credentials, such as passwords, tokens, API keys
+// and Authorization headers, from what the API logs, so they can't leak to
+// whoever reads the logs.
+//
+// Values are redacted when their key names a credential, wherever they are
+// nested, and credentials are scrubbed from free text by recognising their
+// usual forms: bearer and basic authorization, JWTs, the password of URLs,
+// and query or DSN parameters such as token=... or password=...
+package redact
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Placeholder replaces the redacted values
+const Placeholder = "[REDACTED]"
+
+// sensitiveKeys are the fragments of the keys whose values are redacted
+var sensitiveKeys = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"authorization",
+	"cookie",
+	"api_key",
+	"apikey",
+	"access_key",
+	"private_key",
+	"service_key",
+	"credential",
+	"dsn",
+}
+
+// patterns match the credentials found in free text, each replaced by its
+// replacement
+var patterns = []struct {
+	re          *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`), "$1 " + Placeholder},
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), Placeholder},
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), "${1}" + Placeholder + "@"},
+	{regexp.MustCompile(`(?i)\b((?:password|passwd|pwd|secret|token|access_token|refresh_token|api_key|apikey|key|signature)=)[^&\s"']+`), "${1}" + Placeholder},
+	{regexp.MustCompile(`(?i)("(?:password|passwd|secret|token|access_token|refresh_token|api_key|apikey)"\s*:\s*)"(?:[^"\\]|\\.)*"`), `${1}"` + Placeholder + `"`},
+}
+
+// Sensitive reports whether a key names a credential, e.g. "password" or
+// "X-Api-Key".
+func Sensitive(key string) bool {
+	key = strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	for _, fragment := range sensitiveKeys {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// String scrubs the credentials found in free text.
+func String(s string) string {
+	for _, p := range patterns {
+		s = p.re.ReplaceAllString(s, p.replacement)
+	}
+	return s
+}
+
+// Value returns a copy of v with the values of the sensitive keys redacted
+// and the credentials scrubbed from its strings. Values other than strings,
+// maps and slices are converted through their JSON encoding first.
+func Value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, float64, json.Number:
+		return v
+	case string:
+		return String(v)
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if Sensitive(key) {
+				redacted[key] = Placeholder
+			} else {
+				redacted[key] = Value(value)
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, value := range v {
+			redacted[i] = Value(value)
+		}
+		return redacted
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Placeholder
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return Placeholder
+	}
+	return Value(decoded)
+}
+
+// Query returns a raw query string with the values of its sensitive
+// parameters redacted and the credentials scrubbed from the others, e.g. a
+// token in the query of an URL passed as a parameter. A query that can't be
+// parsed is scrubbed as free text.
+func Query(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return String(rawQuery)
+	}
+	for key, list := range values {
+		for i, value := range list {
+			if Sensitive(key) {
+				list[i] = Placeholder
+			} else {
+				list[i] = String(value)
+			}
+		}
+	}
+	return values.Encode()
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type AnnouncementRepository struct {
+	db *gorm.DB
+}
+
+// NewAnnouncementRepository returns a new instance of AnnouncementRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewAnnouncementRepository(db *gorm.DB) *AnnouncementRepository {
+	return &AnnouncementRepository{db: db}
+}
+
+// Create stores a new announcement.
+func (r *AnnouncementRepository) Create(announcement *models.Announcement) error {
+	return r.db.Create(announcement).Error
+}
+
+// FindByID finds an announcement by its ID.
+func (r *AnnouncementRepository) FindByID(id uint) (*models.Announcement, error) {
+	var announcement models.Announcement
+	err := r.db.First(&announcement, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &announcement, nil
+}
+
+// List retrieves every announcement, with pagination, most recent first.
+func (r *AnnouncementRepository) List(page, pageSize int) ([]models.Announcement, int64, error) {
+	var announcements []models.Announcement
+	var total int64
+
+	query := r.db.Model(&models.Announcement{})
+	query.Count(&total)
+
+	err := query.
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&announcements).Error
+
+	return announcements, total, err
+}
+
+// ListActive retrieves the announcements shown at the given time to any of
+// the given audiences, the most severe first.
+func (r *AnnouncementRepository) ListActive(at time.Time, audiences []string) ([]models.Announcement, error) {
+	var announcements []models.Announcement
+	err := r.db.
+		Where("starts_at IS NULL OR starts_at <= ?", at).
+		Where("ends_at IS NULL OR ends_at > ?", at).
+		Where("audience IN ?", audiences).
+		Order("CASE severity WHEN 'critical' THEN 0 WHEN 'warning' THEN 1 ELSE 2 END").
+		Order("id DESC").
+		Find(&announcements).Error
+	return announcements, err
+}
+
+// Update saves the changes made to an announcement.
+func (r *AnnouncementRepository) Update(announcement *models.Announcement) error {
+	return r.db.Save(announcement).Error
+}
+
+// Delete removes an announcement by its ID.
+func (r *AnnouncementRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Announcement{}, id).Error
+}
+
+// PurgeDeleted permanently removes the announcements deleted before the given
+// time, and returns the number removed.
+func (r *AnnouncementRepository) PurgeDeleted(before time.Time) (int64, error) {
+	result := r.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Delete(&models.Announcement{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditLogRepository returns a new instance of AuditLogRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+// Create stores a new audit log entry.
+func (r *AuditLogRepository) Create(entry *models.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+// List retrieves audit log entries with pagination, most recent first.
+//
+// The filters map may contain "user_id", matching the entries of actions
+// taken by the user or targeting them, "action", "action_prefix", "from"
+// (inclusive) and "to" (exclusive).
+func (r *AuditLogRepository) List(page, pageSize int, filters map[string]interface{}) ([]models.AuditLog, int64, error) {
+	var entries []models.AuditLog
+	var total int64
+
+	query := r.db.Model(&models.AuditLog{})
+	if userID, ok := filters["user_id"]; ok {
+		query = query.Where("actor_id = ? OR (target_type = ? AND target_id = ?)", userID, "user", userID)
+	}
+	if action, ok := filters["action"]; ok {
+		query = query.Where("action = ?", action)
+	}
+	if prefix, ok := filters["action_prefix"].(string); ok {
+		query = query.Where("action LIKE ?", prefix+"%")
+	}
+	if from, ok := filters["from"]; ok {
+		query = query.Where("created_at >= ?", from)
+	}
+	if to, ok := filters["to"]; ok {
+		query = query.Where("created_at < ?", to)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&entries).Error
+
+	return entries, total, err
+}
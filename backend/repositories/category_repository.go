@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type CategoryRepository struct {
+	db *gorm.DB
+}
+
+// NewCategoryRepository returns a new instance of CategoryRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewCategoryRepository(db *gorm.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+// Create stores a new category.
+func (r *CategoryRepository) Create(category *models.Category) error {
+	return r.db.Create(category).Error
+}
+
+// FindByID finds a category by its ID.
+func (r *CategoryRepository) FindByID(id uint) (*models.Category, error) {
+	var category models.Category
+	err := r.db.First(&category, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// FindBySlug finds a category by its slug.
+func (r *CategoryRepository) FindBySlug(slug string) (*models.Category, error) {
+	var category models.Category
+	err := r.db.Where("slug = ?", slug).First(&category).Error
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// List returns every category by position then name, along with the number
+// of posts filed under each.
+func (r *CategoryRepository) List() ([]models.Category, error) {
+	var categories []models.Category
+	if err := r.db.Order("position ASC, name ASC").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	// Count posts per category
+	var rows []struct {
+		CategoryID uint
+		Count      int64
+	}
+	err := r.db.Model(&models.Post{}).
+		Select("category_id, COUNT(id) AS count").
+		Where("category_id IS NOT NULL").
+		Group("category_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.CategoryID] = row.Count
+	}
+	for i := range categories {
+		categories[i].PostCount = counts[categories[i].ID]
+	}
+
+	return categories, nil
+}
+
+// Update saves the changes made to a category.
+func (r *CategoryRepository) Update(category *models.Category) error {
+	return r.db.Save(category).Error
+}
+
+// Delete removes a category by its ID. Its subcategories move to its
+// parent, and its posts, trashed ones included, are left without a
+// category.
+func (r *CategoryRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&models.Category{}).
+			Where("parent_id = ?", id).
+			Update("parent_id", category.ParentID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Model(&models.Post{}).
+			Where("category_id = ?", id).
+			UpdateColumn("category_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+}
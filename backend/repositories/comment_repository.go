@@ -1,10 +1,22 @@
 package repositories
 
 import (
+	"errors"
+	"time"
+
 	"github.com/SteaceP/coderage/models"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrAlreadyLiked is returned when a user likes a comment twice.
+	ErrAlreadyLiked = errors.New("comment already liked")
+	// ErrNotLiked is returned when a user removes a like they never gave.
+	ErrNotLiked = errors.New("comment not liked")
+	// ErrAlreadyReported is returned when a user reports a comment twice.
+	ErrAlreadyReported = errors.New("comment already reported")
+)
+
 type CommentRepository struct {
 	db *gorm.DB
 }
@@ -41,18 +53,44 @@ func (r *CommentRepository) FindByID(id uint) (*models.Comment, error) {
 
 // FindByPostID retrieves comments for the given post ID, with pagination.
 //
-// The comments are ordered by their creation time in descending order.
+// Deleted comments are left out, and so are hidden comments unless
+// includeHidden is true. The comments are ordered by their creation time in
+// descending order.
 //
 // It returns the comments, the total count of comments and an error.
-func (r *CommentRepository) FindByPostID(postID uint, page, pageSize int) ([]models.Comment, int64, error) {
+func (r *CommentRepository) FindByPostID(postID uint, includeHidden bool, page, pageSize int) ([]models.Comment, int64, error) {
 	var comments []models.Comment
 	var total int64
 
+	query := r.db.Model(&models.Comment{}).Where("post_id = ? AND status NOT IN ?", postID, excludedStatuses(includeHidden))
+
 	// Count total comments
-	r.db.Model(&models.Comment{}).Where("post_id = ?", postID).Count(&total)
+	query.Count(&total)
 
 	// Fetch paginated comments
-	err := r.db.Where("post_id = ?", postID).
+	err := query.
+		Preload("User").
+		Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&comments).Error
+
+	return comments, total, err
+}
+
+// FindThreads retrieves the top-level comments of the given post ID, with
+// pagination. Statuses are filtered as in FindByPostID.
+//
+// It returns the comments, the total count of top-level comments and an error.
+func (r *CommentRepository) FindThreads(postID uint, includeHidden bool, page, pageSize int) ([]models.Comment, int64, error) {
+	var comments []models.Comment
+	var total int64
+
+	query := r.db.Model(&models.Comment{}).
+		Where("post_id = ? AND parent_id IS NULL AND status NOT IN ?", postID, excludedStatuses(includeHidden))
+	query.Count(&total)
+
+	err := query.
 		Preload("User").
 		Order("created_at DESC").
 		Offset((page - 1) * pageSize).
@@ -62,6 +100,112 @@ func (r *CommentRepository) FindByPostID(postID uint, page, pageSize int) ([]mod
 	return comments, total, err
 }
 
+// FindPostReplies retrieves every reply on the given post ID, at any depth.
+// Statuses are filtered as in FindByPostID.
+//
+// The replies are ordered by their creation time in ascending order.
+func (r *CommentRepository) FindPostReplies(postID uint, includeHidden bool) ([]models.Comment, error) {
+	var replies []models.Comment
+	err := r.db.Where("post_id = ? AND parent_id IS NOT NULL AND status NOT IN ?", postID, excludedStatuses(includeHidden)).
+		Preload("User").
+		Order("created_at ASC").
+		Find(&replies).Error
+	return replies, err
+}
+
+// FindByPostIDs retrieves the comments of the given posts, replies included,
+// without their author. Statuses are filtered as in FindByPostID.
+//
+// The comments are ordered by their creation time in ascending order.
+func (r *CommentRepository) FindByPostIDs(postIDs []uint, includeHidden bool) ([]models.Comment, error) {
+	var comments []models.Comment
+	if len(postIDs) == 0 {
+		return comments, nil
+	}
+	err := r.db.Where("post_id IN ? AND status NOT IN ?", postIDs, excludedStatuses(includeHidden)).
+		Order("created_at ASC").
+		Find(&comments).Error
+	return comments, err
+}
+
+// TopCommenters ranks users by the number of published comments they wrote
+// since the given time, all of them when it is zero.
+func (r *CommentRepository) TopCommenters(since time.Time, limit int) ([]UserScore, error) {
+	var scores []UserScore
+	query := r.db.Model(&models.Comment{}).
+		Select("user_id, COUNT(*) AS score").
+		Where("status = ?", models.CommentPublished)
+	if !since.IsZero() {
+		query = query.Where("created_at >= ?", since)
+	}
+	err := query.
+		Group("user_id").
+		Order("score DESC").
+		Limit(limit).
+		Scan(&scores).Error
+	return scores, err
+}
+
+// CommentDate is the post and creation date of a comment.
+type CommentDate struct {
+	PostID    uint
+	CreatedAt time.Time
+}
+
+// PublishedSince returns the post and creation date of the published
+// comments written since the given time.
+func (r *CommentRepository) PublishedSince(since time.Time) ([]CommentDate, error) {
+	var dates []CommentDate
+	err := r.db.Model(&models.Comment{}).
+		Select("post_id, created_at").
+		Where("status = ? AND created_at >= ?", models.CommentPublished, since).
+		Scan(&dates).Error
+	return dates, err
+}
+
+// PublishedOnPostsSince returns the post and creation date of the published
+// comments written on the given posts since the given time.
+func (r *CommentRepository) PublishedOnPostsSince(postIDs []uint, since time.Time) ([]CommentDate, error) {
+	var dates []CommentDate
+	if len(postIDs) == 0 {
+		return dates, nil
+	}
+	err := r.db.Model(&models.Comment{}).
+		Select("post_id, created_at").
+		Where("post_id IN ? AND status = ? AND created_at >= ?", postIDs, models.CommentPublished, since).
+		Scan(&dates).Error
+	return dates, err
+}
+
+// excludedStatuses returns the comment statuses left out of listings.
+func excludedStatuses(includeHidden bool) []string {
+	excluded := []string{models.CommentDeleted}
+	if !includeHidden {
+		excluded = append(excluded, models.CommentHidden)
+	}
+	return excluded
+}
+
+// Batches calls fn with every comment, hidden ones included, in batches of
+// size comments ordered by ID. It stops at the first error returned by fn.
+func (r *CommentRepository) Batches(size int, fn func(comments []models.Comment) error) error {
+	var comments []models.Comment
+	return r.db.FindInBatches(&comments, size, func(tx *gorm.DB, batch int) error {
+		return fn(comments)
+	}).Error
+}
+
+// FindByUserID returns every comment written by a user whatever its status,
+// most recent first.
+func (r *CommentRepository) FindByUserID(userID uint) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := r.db.
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&comments).Error
+	return comments, err
+}
+
 // Update updates an existing comment in the database.
 //
 // The comment must have an ID or else an error will be returned. The comment's
@@ -79,12 +223,103 @@ func (r *CommentRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Comment{}, id).Error
 }
 
-// FindReplies finds all replies to the given comment.
+// UpdateStatus sets the moderation status of a comment and records who set
+// it, nil when it was set automatically. The open reports of the comment are
+// resolved by a moderator's decision.
+func (r *CommentRepository) UpdateStatus(commentID uint, status string, moderatorID *uint, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":            status,
+			"moderated_by_id":   moderatorID,
+			"moderated_at":      now,
+			"moderation_reason": reason,
+		}
+		if moderatorID != nil {
+			updates["report_count"] = 0
+			if err := tx.Model(&models.CommentReport{}).
+				Where("comment_id = ? AND resolved_at IS NULL", commentID).
+				Updates(map[string]interface{}{
+					"resolved_at":    now,
+					"resolved_by_id": *moderatorID,
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.Comment{}).
+			Where("id = ?", commentID).
+			Updates(updates).Error
+	})
+}
+
+// Report records a report of a comment and returns the number of open reports
+// of the comment.
+//
+// It returns ErrAlreadyReported if the user already reported the comment.
+func (r *CommentRepository) Report(report *models.CommentReport) (int, error) {
+	var reportCount int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.CommentReport{}).
+			Where("comment_id = ? AND user_id = ?", report.CommentID, report.UserID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyReported
+		}
+
+		if err := tx.Create(report).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Comment{}).
+			Where("id = ?", report.CommentID).
+			UpdateColumn("report_count", gorm.Expr("report_count + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Comment{}).
+			Select("report_count").
+			Where("id = ?", report.CommentID).
+			Scan(&reportCount).Error
+	})
+	return reportCount, err
+}
+
+// FindFlagged retrieves the comments awaiting review, with pagination: hidden
+// comments and comments with open reports, most reported first. The open
+// reports of each comment are included.
+func (r *CommentRepository) FindFlagged(page, pageSize int) ([]models.Comment, int64, error) {
+	var comments []models.Comment
+	var total int64
+
+	query := r.db.Model(&models.Comment{}).
+		Where("status = ? OR (status <> ? AND report_count > 0)", models.CommentHidden, models.CommentDeleted)
+	query.Count(&total)
+
+	err := query.
+		Preload("User").
+		Preload("Post", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "title", "slug", "user_id")
+		}).
+		Preload("Reports", "resolved_at IS NULL").
+		Order("report_count DESC").
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&comments).Error
+
+	return comments, total, err
+}
+
+// FindReplies finds the direct replies to the given comment. Statuses are
+// filtered as in FindByPostID.
 //
 // The replies are ordered by their creation time in ascending order.
-func (r *CommentRepository) FindReplies(commentID uint) ([]models.Comment, error) {
+func (r *CommentRepository) FindReplies(commentID uint, includeHidden bool) ([]models.Comment, error) {
 	var replies []models.Comment
-	err := r.db.Where("parent_id = ?", commentID).
+	err := r.db.Where("parent_id = ? AND status NOT IN ?", commentID, excludedStatuses(includeHidden)).
 		Preload("User").
 		Order("created_at ASC").
 		Find(&replies).Error
@@ -105,3 +340,76 @@ func (r *CommentRepository) UpdateLikeCount(commentID uint, increment bool) erro
 		Where("id = ?", commentID).
 		UpdateColumn("like_count", gorm.Expr(operation)).Error
 }
+
+// RecountLikes recomputes the like count of every comment from its likes,
+// and returns the number of comments whose count was wrong.
+func (r *CommentRepository) RecountLikes() (int64, error) {
+	count := r.db.Model(&models.CommentLike{}).
+		Select("COUNT(*)").
+		Where("comment_likes.comment_id = comments.id")
+	result := r.db.Model(&models.Comment{}).
+		Where("like_count <> (?)", count).
+		UpdateColumn("like_count", count)
+	return result.RowsAffected, result.Error
+}
+
+// Like records a like from the given user on a comment and increments the
+// comment's like count in the same transaction.
+//
+// It returns ErrAlreadyLiked if the user already liked the comment, which the
+// unique index of the likes tells even when the same like is sent twice at
+// once.
+func (r *CommentRepository) Like(commentID, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		like := models.CommentLike{CommentID: commentID, UserID: userID}
+		if err := tx.Create(&like).Error; err != nil {
+			if uniqueViolation(err) {
+				return ErrAlreadyLiked
+			}
+			return err
+		}
+
+		return NewCommentRepository(tx).UpdateLikeCount(commentID, true)
+	})
+}
+
+// Unlike removes the given user's like from a comment and decrements the
+// comment's like count in the same transaction.
+//
+// It returns ErrNotLiked if the user had not liked the comment.
+func (r *CommentRepository) Unlike(commentID, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("comment_id = ? AND user_id = ?", commentID, userID).
+			Delete(&models.CommentLike{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotLiked
+		}
+
+		return NewCommentRepository(tx).UpdateLikeCount(commentID, false)
+	})
+}
+
+// LikedCommentIDs returns the subset of the given comment IDs the user liked,
+// as a set keyed by comment ID.
+func (r *CommentRepository) LikedCommentIDs(userID uint, commentIDs []uint) (map[uint]bool, error) {
+	liked := make(map[uint]bool)
+	if len(commentIDs) == 0 {
+		return liked, nil
+	}
+
+	var ids []uint
+	err := r.db.Model(&models.CommentLike{}).
+		Where("user_id = ? AND comment_id IN ?", userID, commentIDs).
+		Pluck("comment_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		liked[id] = true
+	}
+	return liked, nil
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EmailSuppressionRepository struct {
+	db *gorm.DB
+}
+
+// NewEmailSuppressionRepository returns a new instance of
+// EmailSuppressionRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewEmailSuppressionRepository(db *gorm.DB) *EmailSuppressionRepository {
+	return &EmailSuppressionRepository{db: db}
+}
+
+// Suppress records a suppressed address, along with the status of the users
+// of the address, in a single transaction. A suppressed address is recorded
+// again with its latest reason.
+func (r *EmailSuppressionRepository) Suppress(suppression *models.EmailSuppression) error {
+	suppression.Email = strings.ToLower(suppression.Email)
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "email"}},
+			DoUpdates: clause.AssignmentColumns([]string{"reason", "provider", "detail", "updated_at"}),
+		}).Create(suppression).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).
+			Where("LOWER(email) = ?", suppression.Email).
+			Update("email_status", suppression.Reason).Error
+	})
+}
+
+// Suppressed returns the suppressed addresses among addresses, lower cased.
+func (r *EmailSuppressionRepository) Suppressed(addresses []string) ([]string, error) {
+	lowered := make([]string, len(addresses))
+	for i, address := range addresses {
+		lowered[i] = strings.ToLower(address)
+	}
+
+	var suppressed []string
+	err := r.db.Model(&models.EmailSuppression{}).
+		Where("email IN ?", lowered).
+		Pluck("email", &suppressed).Error
+	return suppressed, err
+}
+
+// List retrieves the suppressed addresses with pagination, most recent
+// first.
+func (r *EmailSuppressionRepository) List(page, pageSize int) ([]models.EmailSuppression, int64, error) {
+	var suppressions []models.EmailSuppression
+	var total int64
+
+	query := r.db.Model(&models.EmailSuppression{})
+	query.Count(&total)
+
+	err := query.
+		Order("updated_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&suppressions).Error
+
+	return suppressions, total, err
+}
+
+// Delete lifts the suppression of an address, clearing the status of its
+// users. It returns gorm.ErrRecordNotFound if the address isn't suppressed.
+func (r *EmailSuppressionRepository) Delete(email string) error {
+	email = strings.ToLower(email)
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("email = ?", email).Delete(&models.EmailSuppression{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&models.User{}).
+			Where("LOWER(email) = ?", email).
+			Update("email_status", "").Error
+	})
+}
@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// uniqueViolation reports whether err means a row was rejected by a unique
+// index, as when two requests insert it at once.
+func uniqueViolation(err error) bool {
+	var (
+		pgErr     *pgconn.PgError
+		sqliteErr sqlite3.Error
+	)
+	switch {
+	case errors.As(err, &pgErr):
+		return pgErr.Code == "23505"
+	case errors.As(err, &sqliteErr):
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type IdentityRepository struct {
+	db *gorm.DB
+}
+
+// NewIdentityRepository returns a new instance of IdentityRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewIdentityRepository(db *gorm.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+// Create links a provider account to a user.
+func (r *IdentityRepository) Create(identity *models.Identity) error {
+	return r.db.Create(identity).Error
+}
+
+// CreateWithUser creates a new user signing up through a login provider,
+// along with the identity linking them to the provider account.
+func (r *IdentityRepository) CreateWithUser(user *models.User, identity *models.Identity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+// FindBySubject finds the identity of a provider account.
+func (r *IdentityRepository) FindBySubject(provider, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// ListByUser retrieves the identities of a user, in the order they were
+// linked.
+func (r *IdentityRepository) ListByUser(userID uint) ([]models.Identity, error) {
+	var identities []models.Identity
+	err := r.db.Where("user_id = ?", userID).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+// Delete unlinks the account of a provider from a user. It returns
+// gorm.ErrRecordNotFound if the user has none.
+func (r *IdentityRepository) Delete(userID uint, provider string) error {
+	result := r.db.Where("user_id = ? AND provider = ?", userID, provider).Delete(&models.Identity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
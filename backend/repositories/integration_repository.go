@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+// ErrExternalIDTaken is returned when creating the comment of an external ID
+// an integration already sent.
+var ErrExternalIDTaken = errors.New("external ID already linked to a comment")
+
+type IntegrationRepository struct {
+	db *gorm.DB
+}
+
+// NewIntegrationRepository returns a new instance of IntegrationRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewIntegrationRepository(db *gorm.DB) *IntegrationRepository {
+	return &IntegrationRepository{db: db}
+}
+
+// Create stores a new integration.
+func (r *IntegrationRepository) Create(integration *models.Integration) error {
+	return r.db.Create(integration).Error
+}
+
+// FindByID finds an integration by its ID.
+func (r *IntegrationRepository) FindByID(id uint) (*models.Integration, error) {
+	var integration models.Integration
+	err := r.db.First(&integration, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &integration, nil
+}
+
+// List returns every integration, oldest first.
+func (r *IntegrationRepository) List() ([]models.Integration, error) {
+	var integrations []models.Integration
+	err := r.db.Order("id").Find(&integrations).Error
+	return integrations, err
+}
+
+// Delete removes an integration by its ID. The comments it created are kept.
+func (r *IntegrationRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Integration{}, id).Error
+}
+
+// FindComment finds the link of the comment created by an integration for
+// an external ID.
+func (r *IntegrationRepository) FindComment(integrationID uint, externalID string) (*models.IntegrationComment, error) {
+	var link models.IntegrationComment
+	err := r.db.Where("integration_id = ? AND external_id = ?", integrationID, externalID).First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// CreateComment creates a comment sent by an integration along with its
+// link to its external ID, in a single transaction. It returns
+// ErrExternalIDTaken, creating nothing, when the external ID was already
+// linked to a comment.
+func (r *IntegrationRepository) CreateComment(comment *models.Comment, link *models.IntegrationComment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		link.CommentID = comment.ID
+		err := tx.Create(link).Error
+		if uniqueViolation(err) {
+			return ErrExternalIDTaken
+		}
+		return err
+	})
+}
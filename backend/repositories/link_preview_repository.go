@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LinkPreviewRepository struct {
+	db *gorm.DB
+}
+
+// NewLinkPreviewRepository returns a new instance of LinkPreviewRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewLinkPreviewRepository(db *gorm.DB) *LinkPreviewRepository {
+	return &LinkPreviewRepository{db: db}
+}
+
+// FindByURLHash finds the cached preview of a URL by the hash of the URL.
+func (r *LinkPreviewRepository) FindByURLHash(hash string) (*models.LinkPreview, error) {
+	var preview models.LinkPreview
+	err := r.db.Where("url_hash = ?", hash).First(&preview).Error
+	if err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// Save stores a preview, replacing the cached preview of the same URL.
+func (r *LinkPreviewRepository) Save(preview *models.LinkPreview) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "url_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"url", "title", "description", "image", "site_name", "type", "error", "fetched_at"}),
+	}).Create(preview).Error
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type MediaRepository struct {
+	db *gorm.DB
+}
+
+// NewMediaRepository returns a new instance of MediaRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewMediaRepository(db *gorm.DB) *MediaRepository {
+	return &MediaRepository{db: db}
+}
+
+// Create stores a new media record.
+func (r *MediaRepository) Create(media *models.Media) error {
+	return r.db.Create(media).Error
+}
+
+// FindByID finds a media record by its ID.
+func (r *MediaRepository) FindByID(id uint) (*models.Media, error) {
+	var media models.Media
+	err := r.db.First(&media, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// Delete removes a media record by its ID.
+func (r *MediaRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Media{}, id).Error
+}
+
+// CountByUserSince counts the media uploaded by a user since the given time.
+func (r *MediaRepository) CountByUserSince(userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Media{}).Where("user_id = ? AND created_at >= ?", userID, since).Count(&count).Error
+	return count, err
+}
+
+// FindByURLs finds the media records served from the given URLs, with their
+// variants.
+func (r *MediaRepository) FindByURLs(urls []string) ([]models.Media, error) {
+	var media []models.Media
+	err := r.db.Preload("Variants").Where("url IN ?", urls).Find(&media).Error
+	return media, err
+}
+
+// SetVariants replaces the variants of a media record, and saves the
+// dimensions of the image, in a single transaction.
+func (r *MediaRepository) SetVariants(media *models.Media, variants []models.MediaVariant) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Media{}).Where("id = ?", media.ID).Updates(map[string]interface{}{
+			"width":  media.Width,
+			"height": media.Height,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("media_id = ?", media.ID).Delete(&models.MediaVariant{}).Error; err != nil {
+			return err
+		}
+		for i := range variants {
+			variants[i].MediaID = media.ID
+		}
+		if len(variants) == 0 {
+			return nil
+		}
+		return tx.Create(&variants).Error
+	})
+}
+
+// ListByBackend lists up to limit media records stored on a backend with an
+// ID above afterID, in ID order, with their variants.
+func (r *MediaRepository) ListByBackend(backend string, afterID uint, limit int) ([]models.Media, error) {
+	var media []models.Media
+	err := r.db.Preload("Variants").Where("backend = ? AND id > ?", backend, afterID).Order("id").Limit(limit).Find(&media).Error
+	return media, err
+}
+
+// Relocate saves the backend and URL of media records moved to another
+// storage backend, along with the URLs of their variants, and replaces their
+// previous URL in the content and featured image of posts, in a single
+// transaction. previousURLs holds the previous URL of every record, in the
+// same order.
+func (r *MediaRepository) Relocate(media []models.Media, previousURLs []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i, m := range media {
+			err := tx.Model(&models.Media{}).Where("id = ?", m.ID).Updates(map[string]interface{}{
+				"backend": m.Backend,
+				"url":     m.URL,
+			}).Error
+			if err != nil {
+				return err
+			}
+			for _, variant := range m.Variants {
+				err := tx.Model(&models.MediaVariant{}).Where("id = ?", variant.ID).Update("url", variant.URL).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			previous := previousURLs[i]
+			if previous == "" || previous == m.URL {
+				continue
+			}
+			err = tx.Model(&models.Post{}).Unscoped().
+				Where("content LIKE ?", "%"+previous+"%").
+				UpdateColumn("content", gorm.Expr("REPLACE(content, ?, ?)", previous, m.URL)).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Model(&models.Post{}).Unscoped().
+				Where("featured_image = ?", previous).
+				UpdateColumn("featured_image", m.URL).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
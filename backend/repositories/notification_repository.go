@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+// NewNotificationRepository returns a new instance of NotificationRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// Group records an event of the given actor on a notification.
+//
+// The event is added to the unread notification of the recipient with the
+// same group key when there is one, and a new notification is created
+// otherwise. At most maxActors actors are kept, most recent first.
+func (r *NotificationRepository) Group(n *models.Notification, actorID uint, maxActors int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Notification
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND group_key = ? AND read_at IS NULL", n.UserID, n.GroupKey).
+			First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			n.Count = 1
+			n.ActorCount = 1
+			n.LatestActorIDs = []uint{actorID}
+			n.LatestAt = time.Now()
+			return tx.Create(n).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		// Move the actor to the front of the latest actors
+		seen := false
+		actors := []uint{actorID}
+		for _, id := range existing.LatestActorIDs {
+			if id == actorID {
+				seen = true
+				continue
+			}
+			actors = append(actors, id)
+		}
+		if len(actors) > maxActors {
+			actors = actors[:maxActors]
+		}
+
+		existing.Count++
+		if !seen {
+			// Actors pushed out of the latest list may come back and be
+			// counted twice, which is acceptable for a summary
+			existing.ActorCount++
+		}
+		existing.LatestActorIDs = actors
+		existing.LatestAt = time.Now()
+		if err := tx.Save(&existing).Error; err != nil {
+			return err
+		}
+		*n = existing
+		return nil
+	})
+}
+
+// ListByUser retrieves the notifications of a user with pagination, most
+// recent activity first.
+func (r *NotificationRepository) ListByUser(userID uint, unreadOnly bool, page, pageSize int) ([]models.Notification, int64, error) {
+	var notifications []models.Notification
+	var total int64
+
+	query := r.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	query.Count(&total)
+
+	err := query.
+		Order("latest_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&notifications).Error
+
+	return notifications, total, err
+}
+
+// CountUnread counts the unread notifications of a user.
+func (r *NotificationRepository) CountUnread(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// MarkRead marks a notification of a user as read. It returns
+// gorm.ErrRecordNotFound when the user has no such notification.
+func (r *NotificationRepository) MarkRead(userID, id uint) error {
+	result := r.db.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification of a user as read.
+func (r *NotificationRepository) MarkAllRead(userID uint) error {
+	return r.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now()).Error
+}
+
+// ListForDigest retrieves the unread notifications of the users who opted in
+// to email digests with activity since they were last included in a digest,
+// grouped by user, most recent activity first.
+func (r *NotificationRepository) ListForDigest() ([]models.Notification, error) {
+	var notifications []models.Notification
+	err := r.db.Model(&models.Notification{}).
+		Joins("JOIN users ON users.id = notifications.user_id AND users.deleted_at IS NULL").
+		Where("users.email_digest = ? AND users.is_active = ? AND users.verified_at IS NOT NULL", true, true).
+		Where("notifications.read_at IS NULL").
+		Where("notifications.digested_at IS NULL OR notifications.latest_at > notifications.digested_at").
+		Order("notifications.user_id, notifications.latest_at DESC").
+		Find(&notifications).Error
+	return notifications, err
+}
+
+// MarkDigested records that notifications were included in a digest.
+func (r *NotificationRepository) MarkDigested(ids []uint, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.Notification{}).
+		Where("id IN ?", ids).
+		Update("digested_at", at).Error
+}
+
+// PurgeDeleted permanently removes the notifications deleted before the given
+// time, and returns the number removed.
+func (r *NotificationRepository) PurgeDeleted(before time.Time) (int64, error) {
+	result := r.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Delete(&models.Notification{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OnboardingStepRepository struct {
+	db *gorm.DB
+}
+
+// NewOnboardingStepRepository returns a new instance of
+// OnboardingStepRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewOnboardingStepRepository(db *gorm.DB) *OnboardingStepRepository {
+	return &OnboardingStepRepository{db: db}
+}
+
+// Create records a completed step, unless it was already recorded. It
+// reports whether the step was recorded by this call.
+func (r *OnboardingStepRepository) Create(step *models.OnboardingStep) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListByUser retrieves the steps completed by a user.
+func (r *OnboardingStepRepository) ListByUser(userID uint) ([]models.OnboardingStep, error) {
+	var steps []models.OnboardingStep
+	err := r.db.Where("user_id = ?", userID).Order("completed_at").Find(&steps).Error
+	return steps, err
+}
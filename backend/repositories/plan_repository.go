@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+// Usage is the content of the site counted against the limits of a plan.
+type Usage struct {
+	Posts        int64
+	Comments     int64
+	StorageBytes int64
+	Members      int64
+}
+
+type PlanRepository struct {
+	db *gorm.DB
+}
+
+// NewPlanRepository returns a new instance of PlanRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewPlanRepository(db *gorm.DB) *PlanRepository {
+	return &PlanRepository{db: db}
+}
+
+// Create stores a new plan.
+func (r *PlanRepository) Create(plan *models.Plan) error {
+	return r.db.Create(plan).Error
+}
+
+// FindByID finds a plan by its ID.
+func (r *PlanRepository) FindByID(id uint) (*models.Plan, error) {
+	var plan models.Plan
+	err := r.db.First(&plan, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// FindByName finds a plan by its name.
+func (r *PlanRepository) FindByName(name string) (*models.Plan, error) {
+	var plan models.Plan
+	err := r.db.Where("name = ?", name).First(&plan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// List returns every plan, by name.
+func (r *PlanRepository) List() ([]models.Plan, error) {
+	var plans []models.Plan
+	err := r.db.Order("name ASC").Find(&plans).Error
+	return plans, err
+}
+
+// Update saves the changes made to a plan.
+func (r *PlanRepository) Update(plan *models.Plan) error {
+	return r.db.Save(plan).Error
+}
+
+// Delete removes a plan by its ID.
+func (r *PlanRepository) Delete(id uint) error {
+	result := r.db.Delete(&models.Plan{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Usage counts the posts, comments, members and the size of the uploaded
+// files of the site. Deleted content isn't counted.
+func (r *PlanRepository) Usage() (*Usage, error) {
+	var usage Usage
+	if err := r.db.Model(&models.Post{}).Count(&usage.Posts).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&models.Comment{}).Count(&usage.Comments).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&models.Media{}).Select("COALESCE(SUM(size), 0)").Scan(&usage.StorageBytes).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&models.User{}).Where("member = ?", true).Count(&usage.Members).Error; err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
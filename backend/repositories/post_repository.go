@@ -1,10 +1,15 @@
 package repositories
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/SteaceP/coderage/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PostRepository struct {
@@ -20,14 +25,49 @@ func (r *PostRepository) Create(post *models.Post) error {
 	if post.Slug == "" {
 		post.Slug = generateSlug(post.Title)
 	}
+
+	// Create missing tags
+	tags, err := NewTagRepository(r.db).resolve(post.Tags)
+	if err != nil {
+		return err
+	}
+	post.Tags = tags
+
+	// Credit the lead author along with the post
+	if !creditsLead(post) {
+		post.Authors = append([]models.PostAuthor{{UserID: post.UserID, Role: models.AuthorRoleAuthor}}, post.Authors...)
+	}
+
 	return r.db.Create(post).Error
 }
 
+// creditsLead reports whether the lead author of a post is among its
+// credited authors.
+func creditsLead(post *models.Post) bool {
+	for _, author := range post.Authors {
+		if author.UserID == post.UserID {
+			return true
+		}
+	}
+	return false
+}
+
+// authorsInOrder preloads the credited authors of posts in the order they
+// were credited, so the lead author comes first.
+func authorsInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("post_authors.id ASC")
+}
+
 func (r *PostRepository) FindByID(id uint) (*models.Post, error) {
 	var post models.Post
 	err := r.db.
-		Preload("User").
+		Preload("User", publicUser).
+		Preload("Authors", authorsInOrder).
+		Preload("Authors.User", publicUser).
 		Preload("Comments").
+		Preload("Comments.User", publicUser).
+		Preload("Tags").
+		Preload("Category").
 		First(&post, id).Error
 	if err != nil {
 		return nil, err
@@ -39,8 +79,13 @@ func (r *PostRepository) FindBySlug(slug string) (*models.Post, error) {
 	var post models.Post
 	err := r.db.
 		Where("slug = ?", slug).
-		Preload("User").
+		Preload("User", publicUser).
+		Preload("Authors", authorsInOrder).
+		Preload("Authors.User", publicUser).
 		Preload("Comments").
+		Preload("Comments.User", publicUser).
+		Preload("Tags").
+		Preload("Category").
 		First(&post).Error
 	if err != nil {
 		return nil, err
@@ -52,34 +97,221 @@ func (r *PostRepository) List(page, pageSize int, filters map[string]interface{}
 	var posts []models.Post
 	var total int64
 
+	query := r.filter(filters)
+
+	// Count total, unless the caller knows it
+	if count, ok := filters["count"].(bool); !ok || count {
+		query.Count(&total)
+	}
+
+	// Leave the author and tags to be loaded by the caller if asked
+	if preload, ok := filters["preload"].(bool); !ok || preload {
+		query = query.Preload("User", publicUser).Preload("Authors", authorsInOrder).Preload("Authors.User", publicUser).Preload("Tags").Preload("Category")
+	}
+
+	// Fetch paginated posts
+	err := query.
+		Order("published_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&posts).Error
+
+	return posts, total, err
+}
+
+// EstimateCount returns the number of posts matching the filters of List,
+// as estimated by the PostgreSQL planner from the table statistics instead
+// of counting them. Other databases count them.
+func (r *PostRepository) EstimateCount(filters map[string]interface{}) (int64, error) {
+	query := r.filter(filters)
+	if r.db.Dialector.Name() != "postgres" {
+		var total int64
+		err := query.Count(&total).Error
+		return total, err
+	}
+
+	var posts []models.Post
+	stmt := query.Session(&gorm.Session{DryRun: true}).Select("id").Find(&posts).Statement
+	var output string
+	row := stmt.ConnPool.QueryRowContext(stmt.Context, "EXPLAIN (FORMAT JSON) "+stmt.SQL.String(), stmt.Vars...)
+	if err := row.Scan(&output); err != nil {
+		return 0, err
+	}
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(output), &plans); err != nil || len(plans) == 0 {
+		return 0, fmt.Errorf("invalid query plan: %s", output)
+	}
+	return int64(plans[0].Plan.Rows), nil
+}
+
+// MatchingIDs returns the IDs of the posts matching the filters of List.
+func (r *PostRepository) MatchingIDs(filters map[string]interface{}) ([]uint, error) {
+	var ids []uint
+	err := r.filter(filters).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// filter returns the query of the posts matching the filters of List.
+func (r *PostRepository) filter(filters map[string]interface{}) *gorm.DB {
 	// Base query
 	query := r.db.Model(&models.Post{})
 
 	// Apply filters
+	if ids, ok := filters["ids"].([]uint); ok {
+		query = query.Where("id IN ?", ids)
+	}
+
+	if categoryIDs, ok := filters["category_ids"].([]uint); ok {
+		query = query.Where("category_id IN ?", categoryIDs)
+	}
+
 	if status, ok := filters["status"].(string); ok && status != "" {
 		query = query.Where("status = ?", status)
 	}
 
+	// Posts with any of the tags, or all of them when all_tags is set
 	if tags, ok := filters["tags"].([]string); ok && len(tags) > 0 {
-		query = query.Where("? = ANY(tags)", tags[0])
+		tagged := r.db.Table("post_tags").
+			Select("post_tags.post_id").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").
+			Where("tags.slug IN ?", tags)
+		if all, ok := filters["all_tags"].(bool); ok && all {
+			tagged = tagged.Group("post_tags.post_id").Having("COUNT(DISTINCT tags.id) = ?", len(tags))
+		}
+		query = query.Where("id IN (?)", tagged)
+	}
+
+	if search, ok := filters["search"].(string); ok && search != "" {
+		pattern := "%" + strings.ToLower(search) + "%"
+		query = query.Where("LOWER(title) LIKE ? OR LOWER(content) LIKE ?", pattern, pattern)
 	}
 
 	if userID, ok := filters["user_id"].(uint); ok && userID > 0 {
 		query = query.Where("user_id = ?", userID)
 	}
 
-	// Count total
-	query.Count(&total)
+	if minViews, ok := filters["min_views"].(int); ok && minViews > 0 {
+		query = query.Where("view_count >= ?", minViews)
+	}
 
-	// Fetch paginated posts
-	err := query.
-		Preload("User").
+	if publicOnly, ok := filters["public_only"].(bool); ok && publicOnly {
+		query = query.Where("members_only_until IS NULL OR members_only_until <= ?", time.Now())
+	}
+
+	if since, ok := filters["published_since"].(time.Time); ok {
+		query = query.Where("published_at >= ?", since)
+	}
+
+	if before, ok := filters["published_before"].(time.Time); ok {
+		query = query.Where("published_at < ?", before)
+	}
+
+	return query
+}
+
+// FindByUserID returns every post of a user whatever its status, with its
+// tags, most recent first.
+func (r *PostRepository) FindByUserID(userID uint) ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.
+		Preload("Tags").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&posts).Error
+	return posts, err
+}
+
+// FindPublishedByUserIDs returns the published posts of the given users,
+// without their author and tags, most recent first. Posts in early access
+// are left out when publicOnly is true.
+func (r *PostRepository) FindPublishedByUserIDs(userIDs []uint, publicOnly bool) ([]models.Post, error) {
+	var posts []models.Post
+	if len(userIDs) == 0 {
+		return posts, nil
+	}
+	query := r.db.Where("user_id IN ? AND status = ?", userIDs, "published")
+	if publicOnly {
+		query = query.Where("members_only_until IS NULL OR members_only_until <= ?", time.Now())
+	}
+	err := query.Order("published_at DESC").Find(&posts).Error
+	return posts, err
+}
+
+// FindPublishedSlugs returns the slugs of the published posts among the
+// given ones.
+func (r *PostRepository) FindPublishedSlugs(slugs []string) ([]string, error) {
+	var found []string
+	if len(slugs) == 0 {
+		return found, nil
+	}
+	err := r.db.Model(&models.Post{}).
+		Where("slug IN ? AND status = ?", slugs, "published").
+		Pluck("slug", &found).Error
+	return found, err
+}
+
+// CountByUserID counts the posts of a user, whatever their status.
+func (r *PostRepository) CountByUserID(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Post{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// ListPublished returns the slug and last update of every published post
+// visible to the public, most recent first.
+func (r *PostRepository) ListPublished() ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.
+		Select("id", "slug", "locale", "updated_at").
+		Where("status = ?", "published").
+		Where("members_only_until IS NULL OR members_only_until <= ?", time.Now()).
 		Order("published_at DESC").
-		Offset((page - 1) * pageSize).
-		Limit(pageSize).
 		Find(&posts).Error
+	return posts, err
+}
 
-	return posts, total, err
+// PublishedDates returns the publication date of every published post, most
+// recent first. Posts in their members-only window are left out when
+// publicOnly is set.
+func (r *PostRepository) PublishedDates(publicOnly bool) ([]time.Time, error) {
+	query := r.db.Model(&models.Post{}).Where("status = ?", "published")
+	if publicOnly {
+		query = query.Where("members_only_until IS NULL OR members_only_until <= ?", time.Now())
+	}
+
+	var dates []time.Time
+	err := query.Order("published_at DESC").Pluck("published_at", &dates).Error
+	return dates, err
+}
+
+// UserScore is the score of a user in a ranking.
+type UserScore struct {
+	UserID uint
+	Score  int64
+}
+
+// TopAuthors ranks the authors of published posts by the sum of the given
+// counter column of their posts, "view_count" or "like_count". Only posts
+// published since the given time are counted, all of them when it is zero.
+func (r *PostRepository) TopAuthors(column string, since time.Time, limit int) ([]UserScore, error) {
+	var scores []UserScore
+	query := r.db.Model(&models.Post{}).
+		Select("user_id, SUM("+column+") AS score").
+		Where("status = ?", "published")
+	if !since.IsZero() {
+		query = query.Where("published_at >= ?", since)
+	}
+	err := query.
+		Group("user_id").
+		Having("SUM(" + column + ") > 0").
+		Order("score DESC").
+		Limit(limit).
+		Scan(&scores).Error
+	return scores, err
 }
 
 func (r *PostRepository) Update(post *models.Post) error {
@@ -87,17 +319,191 @@ func (r *PostRepository) Update(post *models.Post) error {
 	if post.Title != "" {
 		post.Slug = generateSlug(post.Title)
 	}
-	return r.db.Save(post).Error
+
+	// Create missing tags
+	tags, err := NewTagRepository(r.db).resolve(post.Tags)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Associations loaded for reading are not saved back
+		if err := tx.Omit(clause.Associations).Save(post).Error; err != nil {
+			return err
+		}
+
+		post.Tags = tags
+		return tx.Model(post).Association("Tags").Replace(tags)
+	})
+}
+
+// SaveAuthor credits a user as an author of a post, or changes their role
+// when they already are one, and reports whether they were credited.
+func (r *PostRepository) SaveAuthor(author *models.PostAuthor) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.PostAuthor
+		err := tx.Where("post_id = ? AND user_id = ?", author.PostID, author.UserID).First(&existing).Error
+		switch {
+		case err == nil:
+			author.ID = existing.ID
+			author.CreatedAt = existing.CreatedAt
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			created = true
+		default:
+			return err
+		}
+		return tx.Omit(clause.Associations).Save(author).Error
+	})
+	return created, err
+}
+
+// DeleteAuthor stops crediting a user as an author of a post, and reports
+// whether they were one.
+func (r *PostRepository) DeleteAuthor(postID, userID uint) (bool, error) {
+	result := r.db.Where("post_id = ? AND user_id = ?", postID, userID).Delete(&models.PostAuthor{})
+	return result.RowsAffected > 0, result.Error
 }
 
 func (r *PostRepository) Delete(id uint) error {
 	return r.db.Delete(&models.Post{}, id).Error
 }
 
-func (r *PostRepository) IncrementViewCount(postID uint) error {
-	return r.db.Model(&models.Post{}).
-		Where("id = ?", postID).
-		UpdateColumn("view_count", gorm.Expr("view_count + 1")).Error
+// FindByIDs retrieves the posts with the given IDs, without their
+// associations. IDs of missing posts are skipped.
+func (r *PostRepository) FindByIDs(ids []uint) ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.Where("id IN ?", ids).Find(&posts).Error
+	return posts, err
+}
+
+// BulkChange is a change applied to many posts at once. Delete excludes the
+// other changes.
+type BulkChange struct {
+	Status     string
+	AddTags    []string // Names of the tags to add, created when missing
+	RemoveTags []string // Names of the tags to remove
+	Delete     bool
+}
+
+// Bulk applies a change to the posts with the given IDs, in a single
+// transaction: either every post is changed, or none is.
+func (r *PostRepository) Bulk(ids []uint, change BulkChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if change.Delete {
+			return tx.Delete(&models.Post{}, ids).Error
+		}
+
+		if change.Status != "" {
+			err := tx.Model(&models.Post{}).Where("id IN ?", ids).Update("status", change.Status).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(change.AddTags) > 0 {
+			tags, err := NewTagRepository(tx).FindOrCreateByNames(change.AddTags)
+			if err != nil {
+				return err
+			}
+			rows := make([]map[string]interface{}, 0, len(ids)*len(tags))
+			for _, id := range ids {
+				for _, tag := range tags {
+					rows = append(rows, map[string]interface{}{"post_id": id, "tag_id": tag.ID})
+				}
+			}
+			if len(rows) > 0 {
+				err := tx.Table("post_tags").Clauses(clause.OnConflict{DoNothing: true}).Create(rows).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(change.RemoveTags) > 0 {
+			slugs := make([]string, len(change.RemoveTags))
+			for i, name := range change.RemoveTags {
+				slugs[i] = generateSlug(strings.TrimSpace(name))
+			}
+			err := tx.Table("post_tags").
+				Where("post_id IN ?", ids).
+				Where("tag_id IN (?)", tx.Model(&models.Tag{}).Select("id").Where("slug IN ?", slugs)).
+				Delete(map[string]interface{}{}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// Tag changes leave the posts untouched: bump their update time
+		return tx.Model(&models.Post{}).Where("id IN ?", ids).Update("updated_at", time.Now()).Error
+	})
+}
+
+// Import creates an imported post along with its comments, in a single
+// transaction, unless a post has its slug already, deleted or not: false is
+// returned then. Comments are created in order, and parents[i] is the index
+// of the comment comments[i] replies to, or -1 for top-level comments.
+func (r *PostRepository) Import(post *models.Post, comments []models.Comment, parents []int) (bool, error) {
+	post.Slug = generateSlug(post.Slug)
+	if post.Slug == "" {
+		post.Slug = generateSlug(post.Title)
+	}
+
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Unscoped().Model(&models.Post{}).Where("slug = ?", post.Slug).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		// Create missing tags
+		tags, err := NewTagRepository(tx).resolve(post.Tags)
+		if err != nil {
+			return err
+		}
+		post.Tags = tags
+		if err := tx.Create(post).Error; err != nil {
+			return err
+		}
+
+		for i := range comments {
+			comments[i].PostID = post.ID
+			if parents[i] >= 0 {
+				comments[i].ParentID = &comments[parents[i]].ID
+			}
+			if err := tx.Omit(clause.Associations).Create(&comments[i]).Error; err != nil {
+				return err
+			}
+		}
+		created = true
+		return nil
+	})
+	return created, err
+}
+
+// Batches calls fn with every post, drafts and archived posts included,
+// along with their author and tags, in batches of size posts ordered by ID.
+// It stops at the first error returned by fn.
+func (r *PostRepository) Batches(size int, fn func(posts []models.Post) error) error {
+	var posts []models.Post
+	return r.db.Preload("User").Preload("Tags").FindInBatches(&posts, size, func(tx *gorm.DB, batch int) error {
+		return fn(posts)
+	}).Error
+}
+
+// RecountComments recomputes the comment count of every post from its
+// published comments, and returns the number of posts whose count was wrong.
+func (r *PostRepository) RecountComments() (int64, error) {
+	count := r.db.Model(&models.Comment{}).
+		Select("COUNT(*)").
+		Where("comments.post_id = posts.id AND comments.status = ?", models.CommentPublished)
+	result := r.db.Model(&models.Post{}).
+		Where("comment_count <> (?)", count).
+		UpdateColumn("comment_count", count)
+	return result.RowsAffected, result.Error
 }
 
 func (r *PostRepository) UpdateCommentCount(postID uint, increment bool) error {
@@ -113,6 +519,12 @@ func (r *PostRepository) UpdateCommentCount(postID uint, increment bool) error {
 		UpdateColumn("comment_count", gorm.Expr(operation)).Error
 }
 
+// Slugify returns the URL-friendly slug of a name, as generated for posts,
+// tags and categories.
+func Slugify(name string) string {
+	return generateSlug(name)
+}
+
 // Helper function to generate URL-friendly slug
 func generateSlug(title string) string {
 	// Convert to lowercase
@@ -132,3 +544,102 @@ func generateSlug(title string) string {
 
 	return string(result)
 }
+
+// SumViewCount sums the view counts of the posts of a user.
+func (r *PostRepository) SumViewCount(userID uint) (int64, error) {
+	var total int64
+	err := r.db.Model(&models.Post{}).
+		Select("COALESCE(SUM(view_count), 0)").
+		Where("user_id = ?", userID).
+		Scan(&total).Error
+	return total, err
+}
+
+// PostScore is the score of a post in a ranking.
+type PostScore struct {
+	PostID uint
+	Score  int64
+}
+
+// FindPublic returns the published posts visible to the public among the
+// given ones, with the public profile of their author and their tags, in no
+// particular order.
+func (r *PostRepository) FindPublic(ids []uint) ([]models.Post, error) {
+	var posts []models.Post
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	err := r.db.
+		Preload("User", publicUser).
+		Preload("Tags").
+		Where("id IN ?", ids).
+		Where("status = ?", "published").
+		Where("members_only_until IS NULL OR members_only_until <= ?", time.Now()).
+		Find(&posts).Error
+	return posts, err
+}
+
+// PublicActivity returns the ID, like count and publication date of the
+// published posts visible to the public that are among the given ones or
+// were published since the given time.
+func (r *PostRepository) PublicActivity(ids []uint, since time.Time) ([]models.Post, error) {
+	var posts []models.Post
+	query := r.db.
+		Select("id", "like_count", "published_at").
+		Where("status = ?", "published").
+		Where("members_only_until IS NULL OR members_only_until <= ?", time.Now())
+	if len(ids) > 0 {
+		query = query.Where("id IN ? OR published_at >= ?", ids, since)
+	} else {
+		query = query.Where("published_at >= ?", since)
+	}
+	err := query.Find(&posts).Error
+	return posts, err
+}
+
+// SharingTags ranks the published posts visible to the public by the number
+// of tags they share with a post, and returns up to limit of them.
+func (r *PostRepository) SharingTags(postID uint, limit int) ([]PostScore, error) {
+	var scores []PostScore
+	err := r.db.Table("post_tags").
+		Select("post_tags.post_id, COUNT(*) AS score").
+		Joins("JOIN post_tags shared ON shared.tag_id = post_tags.tag_id AND shared.post_id = ?", postID).
+		Joins("JOIN posts ON posts.id = post_tags.post_id").
+		Where("post_tags.post_id <> ?", postID).
+		Where("posts.deleted_at IS NULL AND posts.status = ?", "published").
+		Where("posts.members_only_until IS NULL OR posts.members_only_until <= ?", time.Now()).
+		Group("post_tags.post_id").
+		Order("score DESC, post_tags.post_id DESC").
+		Limit(limit).
+		Scan(&scores).Error
+	return scores, err
+}
+
+// MatchingTitle returns the IDs of up to limit published posts visible to
+// the public, other than the given one, whose title contains one of the
+// words, most recent first.
+func (r *PostRepository) MatchingTitle(postID uint, words []string, limit int) ([]uint, error) {
+	ids := []uint{}
+	if len(words) == 0 {
+		return ids, nil
+	}
+
+	match := r.db
+	for i, word := range words {
+		pattern := "%" + strings.ToLower(word) + "%"
+		if i == 0 {
+			match = match.Where("LOWER(title) LIKE ?", pattern)
+		} else {
+			match = match.Or("LOWER(title) LIKE ?", pattern)
+		}
+	}
+	err := r.db.Model(&models.Post{}).
+		Where("id <> ?", postID).
+		Where("status = ?", "published").
+		Where("members_only_until IS NULL OR members_only_until <= ?", time.Now()).
+		Where(match).
+		Order("published_at DESC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type PostTranslationRepository struct {
+	db *gorm.DB
+}
+
+// NewPostTranslationRepository returns a new instance of
+// PostTranslationRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewPostTranslationRepository(db *gorm.DB) *PostTranslationRepository {
+	return &PostTranslationRepository{db: db}
+}
+
+// Save stores a translation, replacing the translation of the post in the
+// same locale if any, and reports whether it was created.
+func (r *PostTranslationRepository) Save(translation *models.PostTranslation) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.PostTranslation
+		err := tx.Where("post_id = ? AND locale = ?", translation.PostID, translation.Locale).First(&existing).Error
+		switch {
+		case err == nil:
+			translation.ID = existing.ID
+			translation.CreatedAt = existing.CreatedAt
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			created = true
+		default:
+			return err
+		}
+		return tx.Save(translation).Error
+	})
+	return created, err
+}
+
+// Find retrieves the translation of a post in a locale.
+func (r *PostTranslationRepository) Find(postID uint, locale string) (*models.PostTranslation, error) {
+	var translation models.PostTranslation
+	err := r.db.Where("post_id = ? AND locale = ?", postID, locale).First(&translation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &translation, nil
+}
+
+// ListByPost returns the translations of a post, sorted by locale.
+func (r *PostTranslationRepository) ListByPost(postID uint) ([]models.PostTranslation, error) {
+	var translations []models.PostTranslation
+	err := r.db.Where("post_id = ?", postID).Order("locale").Find(&translations).Error
+	return translations, err
+}
+
+// Locales returns the locales of the translations of every post, keyed by
+// post ID.
+func (r *PostTranslationRepository) Locales() (map[uint][]string, error) {
+	var rows []models.PostTranslation
+	err := r.db.Select("post_id", "locale").Order("post_id, locale").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	locales := make(map[uint][]string)
+	for _, row := range rows {
+		locales[row.PostID] = append(locales[row.PostID], row.Locale)
+	}
+	return locales, nil
+}
+
+// Delete removes the translation of a post in a locale, and reports whether
+// it existed.
+func (r *PostTranslationRepository) Delete(postID uint, locale string) (bool, error) {
+	result := r.db.Where("post_id = ? AND locale = ?", postID, locale).Delete(&models.PostTranslation{})
+	return result.RowsAffected > 0, result.Error
+}
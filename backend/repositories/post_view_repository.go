@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DailyViews is the number of views during a day.
+type DailyViews struct {
+	Day   time.Time `json:"day"`
+	Views int64     `json:"views"`
+}
+
+// PostViews is the number of views of a post.
+type PostViews struct {
+	PostID uint   `json:"post_id"`
+	Title  string `json:"title"`
+	Views  int64  `json:"views"`
+}
+
+// PostDailyViews is the number of views of a post during a day.
+type PostDailyViews struct {
+	PostID uint
+	Day    time.Time
+	Views  int64
+}
+
+type PostViewRepository struct {
+	db *gorm.DB
+}
+
+// NewPostViewRepository returns a new instance of PostViewRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewPostViewRepository(db *gorm.DB) *PostViewRepository {
+	return &PostViewRepository{db: db}
+}
+
+// Record counts a view of a post during a day, along with the view count of
+// the post, in a single transaction.
+func (r *PostViewRepository) Record(postID uint, day time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "post_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"views": gorm.Expr("post_view_days.views + 1")}),
+		}).Create(&models.PostViewDay{PostID: postID, Day: day, Views: 1}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Post{}).
+			Where("id = ?", postID).
+			UpdateColumn("view_count", gorm.Expr("view_count + 1")).Error
+	})
+}
+
+// DailyForPost returns the views of a post for each day since the given
+// one with views, in day order.
+func (r *PostViewRepository) DailyForPost(postID uint, since time.Time) ([]DailyViews, error) {
+	var days []DailyViews
+	err := r.db.Model(&models.PostViewDay{}).
+		Select("day, views").
+		Where("post_id = ? AND day >= ?", postID, since).
+		Order("day").
+		Scan(&days).Error
+	return days, err
+}
+
+// DailyForAuthor returns the views of the posts of a user for each day
+// since the given one with views, in day order.
+func (r *PostViewRepository) DailyForAuthor(userID uint, since time.Time) ([]DailyViews, error) {
+	var days []DailyViews
+	err := r.db.Model(&models.PostViewDay{}).
+		Select("post_view_days.day, SUM(post_view_days.views) AS views").
+		Joins("JOIN posts ON posts.id = post_view_days.post_id").
+		Where("posts.user_id = ? AND post_view_days.day >= ?", userID, since).
+		Group("post_view_days.day").
+		Order("post_view_days.day").
+		Scan(&days).Error
+	return days, err
+}
+
+// TopPostsForAuthor ranks the posts of a user by their views since the
+// given day, and returns up to limit of them, or all of them when limit is
+// negative.
+func (r *PostViewRepository) TopPostsForAuthor(userID uint, since time.Time, limit int) ([]PostViews, error) {
+	posts := []PostViews{}
+	err := r.db.Model(&models.PostViewDay{}).
+		Select("posts.id AS post_id, posts.title, SUM(post_view_days.views) AS views").
+		Joins("JOIN posts ON posts.id = post_view_days.post_id").
+		Where("posts.user_id = ? AND posts.deleted_at IS NULL AND post_view_days.day >= ?", userID, since).
+		Group("posts.id, posts.title").
+		Order("views DESC, posts.id").
+		Limit(limit).
+		Scan(&posts).Error
+	return posts, err
+}
+
+// DailySince returns the views of every post for each day since the given
+// one with views.
+func (r *PostViewRepository) DailySince(since time.Time) ([]PostDailyViews, error) {
+	var days []PostDailyViews
+	err := r.db.Model(&models.PostViewDay{}).
+		Select("post_id, day, views").
+		Where("day >= ?", since).
+		Scan(&days).Error
+	return days, err
+}
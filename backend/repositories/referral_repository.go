@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type ReferralRepository struct {
+	db *gorm.DB
+}
+
+// NewReferralRepository returns a new instance of ReferralRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewReferralRepository(db *gorm.DB) *ReferralRepository {
+	return &ReferralRepository{db: db}
+}
+
+// Create stores a new referral.
+func (r *ReferralRepository) Create(referral *models.Referral) error {
+	return r.db.Create(referral).Error
+}
+
+// ListByReferrer retrieves the users referred by a user, with pagination,
+// most recent first.
+func (r *ReferralRepository) ListByReferrer(referrerID uint, page, pageSize int) ([]models.Referral, int64, error) {
+	var referrals []models.Referral
+	var total int64
+
+	query := r.db.Model(&models.Referral{}).Where("referrer_id = ?", referrerID)
+	query.Count(&total)
+
+	err := query.
+		Preload("Referred", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "username", "first_name", "last_name", "profile_picture", "verified_at")
+		}).
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&referrals).Error
+
+	return referrals, total, err
+}
+
+// CountByReferrer counts the users referred by a user, and how many of them
+// verified their email address. Deleted users are not counted.
+func (r *ReferralRepository) CountByReferrer(referrerID uint) (total, verified int64, err error) {
+	var counts struct {
+		Total    int64
+		Verified int64
+	}
+	err = r.db.Model(&models.Referral{}).
+		Select("COUNT(*) AS total, COUNT(users.verified_at) AS verified").
+		Joins("JOIN users ON users.id = referrals.referred_id AND users.deleted_at IS NULL").
+		Where("referrals.referrer_id = ?", referrerID).
+		Scan(&counts).Error
+	return counts.Total, counts.Verified, err
+}
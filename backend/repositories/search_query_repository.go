@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+// QueryStats sums up the searches of a query.
+type QueryStats struct {
+	Query      string  `json:"query"`
+	Searches   int64   `json:"searches"`
+	AvgResults float64 `json:"avg_results"`
+	Clicks     int64   `json:"clicks"` // Searches followed by a click on a result
+}
+
+// SearchTotals sums up the searches of a period.
+type SearchTotals struct {
+	Searches           int64 `json:"searches"`
+	ZeroResultSearches int64 `json:"zero_result_searches"`
+	Clicks             int64 `json:"clicks"`
+}
+
+type SearchQueryRepository struct {
+	db *gorm.DB
+}
+
+// NewSearchQueryRepository returns a new instance of SearchQueryRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewSearchQueryRepository(db *gorm.DB) *SearchQueryRepository {
+	return &SearchQueryRepository{db: db}
+}
+
+// Create records a search.
+func (r *SearchQueryRepository) Create(search *models.SearchQuery) error {
+	return r.db.Create(search).Error
+}
+
+// RecordClick records the result clicked after a search. Only the first
+// click is kept. It returns gorm.ErrRecordNotFound if the search doesn't
+// exist.
+func (r *SearchQueryRepository) RecordClick(id, postID uint) error {
+	var search models.SearchQuery
+	if err := r.db.Select("id").First(&search, id).Error; err != nil {
+		return err
+	}
+	return r.db.Model(&models.SearchQuery{}).
+		Where("id = ? AND clicked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"clicked_post_id": postID,
+			"clicked_at":      time.Now(),
+		}).Error
+}
+
+// Totals sums up the searches made since the given time.
+func (r *SearchQueryRepository) Totals(since time.Time) (*SearchTotals, error) {
+	var totals SearchTotals
+	err := r.db.Model(&models.SearchQuery{}).
+		Select("COUNT(*) AS searches, "+
+			"COALESCE(SUM(CASE WHEN result_count = 0 THEN 1 ELSE 0 END), 0) AS zero_result_searches, "+
+			"COUNT(clicked_at) AS clicks").
+		Where("created_at >= ?", since).
+		Scan(&totals).Error
+	return &totals, err
+}
+
+// TopQueries ranks the queries searched since the given time by their
+// number of searches, and returns up to limit of them. Only the queries
+// finding nothing are ranked when zeroResults is set.
+func (r *SearchQueryRepository) TopQueries(since time.Time, zeroResults bool, limit int) ([]QueryStats, error) {
+	query := r.db.Model(&models.SearchQuery{}).
+		Select("query, COUNT(*) AS searches, AVG(result_count) AS avg_results, COUNT(clicked_at) AS clicks").
+		Where("created_at >= ?", since)
+	if zeroResults {
+		query = query.Where("result_count = 0")
+	}
+
+	stats := []QueryStats{}
+	err := query.
+		Group("query").
+		Order("searches DESC, query").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// PurgeBefore removes the searches made before the given time, and returns
+// how many were removed.
+func (r *SearchQueryRepository) PurgeBefore(before time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", before).Delete(&models.SearchQuery{})
+	return result.RowsAffected, result.Error
+}
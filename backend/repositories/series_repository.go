@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type SeriesRepository struct {
+	db *gorm.DB
+}
+
+// NewSeriesRepository returns a new instance of SeriesRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewSeriesRepository(db *gorm.DB) *SeriesRepository {
+	return &SeriesRepository{db: db}
+}
+
+// Create stores a new series.
+func (r *SeriesRepository) Create(series *models.Series) error {
+	return r.db.Create(series).Error
+}
+
+// FindByID finds a series by its ID.
+func (r *SeriesRepository) FindByID(id uint) (*models.Series, error) {
+	var series models.Series
+	err := r.db.First(&series, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// FindBySlug finds a series by its slug.
+func (r *SeriesRepository) FindBySlug(slug string) (*models.Series, error) {
+	var series models.Series
+	err := r.db.Where("slug = ?", slug).First(&series).Error
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// FindByPost finds the series a post belongs to.
+func (r *SeriesRepository) FindByPost(postID uint) (*models.Series, error) {
+	var series models.Series
+	err := r.db.
+		Joins("JOIN series_posts ON series_posts.series_id = series.id").
+		Where("series_posts.post_id = ?", postID).
+		First(&series).Error
+	if err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// Update saves the changes made to a series.
+func (r *SeriesRepository) Update(series *models.Series) error {
+	return r.db.Save(series).Error
+}
+
+// Delete removes a series by its ID, leaving its posts out of any series.
+func (r *SeriesRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var series models.Series
+		if err := tx.First(&series, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("series_id = ?", id).Delete(&models.SeriesPost{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&series).Error
+	})
+}
+
+// Posts returns the posts of a series in order, with the public profile of
+// their author and their tags. Trashed posts are left out.
+func (r *SeriesRepository) Posts(seriesID uint) ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.
+		Joins("JOIN series_posts ON series_posts.post_id = posts.id").
+		Where("series_posts.series_id = ?", seriesID).
+		Order("series_posts.position ASC").
+		Preload("User", publicUser).
+		Preload("Tags").
+		Find(&posts).Error
+	return posts, err
+}
+
+// Outline returns the posts of a series in order, with only the fields
+// needed to link to them and tell who can see them.
+func (r *SeriesRepository) Outline(seriesID uint) ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.
+		Select("posts.id", "posts.title", "posts.slug", "posts.user_id", "posts.status", "posts.members_only_until").
+		Joins("JOIN series_posts ON series_posts.post_id = posts.id").
+		Where("series_posts.series_id = ?", seriesID).
+		Order("series_posts.position ASC").
+		Find(&posts).Error
+	return posts, err
+}
+
+// SetPosts replaces the posts of a series with the given ones, in order.
+// Trashed posts left out leave the series.
+func (r *SeriesRepository) SetPosts(seriesID uint, postIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("series_id = ?", seriesID).Delete(&models.SeriesPost{}).Error; err != nil {
+			return err
+		}
+		if len(postIDs) == 0 {
+			return nil
+		}
+		entries := make([]models.SeriesPost, len(postIDs))
+		for i, postID := range postIDs {
+			entries[i] = models.SeriesPost{SeriesID: seriesID, PostID: postID, Position: i}
+		}
+		return tx.Create(&entries).Error
+	})
+}
+
+// InSeries reports whether a post belongs to a series.
+func (r *SeriesRepository) InSeries(postID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.SeriesPost{}).Where("post_id = ?", postID).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository returns a new instance of SessionRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create starts a session, removing the expired sessions of the user.
+func (r *SessionRepository) Create(session *models.Session) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND expires_at <= ?", session.UserID, time.Now()).
+			Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(session).Error
+	})
+}
+
+// FindByID retrieves a session of a user.
+func (r *SessionRepository) FindByID(id, userID uint) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Rotate records the refresh token issued to replace the one of refreshUUID,
+// and the client it was issued to. It returns false, changing nothing, when
+// refreshUUID isn't the refresh token issued last, such as when two refreshes
+// race.
+func (r *SessionRepository) Rotate(session *models.Session, refreshUUID string) (bool, error) {
+	result := r.db.Model(&models.Session{}).
+		Where("id = ? AND refresh_uuid = ?", session.ID, refreshUUID).
+		Updates(map[string]interface{}{
+			"refresh_uuid": session.RefreshUUID,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// activeSessions scopes a query to the sessions that didn't expire, of the
+// current token version of their user, when the user is active and wasn't
+// deleted.
+func activeSessions(db *gorm.DB) *gorm.DB {
+	return db.Where("sessions.expires_at > ?", time.Now()).
+		Where("sessions.token_version = (SELECT users.token_version FROM users WHERE users.id = sessions.user_id AND users.is_active = ? AND users.deleted_at IS NULL)", true)
+}
+
+// ListActive retrieves the active sessions of a user, the ones used last
+// first.
+func (r *SessionRepository) ListActive(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Scopes(activeSessions).
+		Where("sessions.user_id = ?", userID).
+		Order("sessions.last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Active reports whether a session of a user is active.
+func (r *SessionRepository) Active(id, userID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Session{}).Scopes(activeSessions).
+		Where("sessions.id = ? AND sessions.user_id = ?", id, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Delete revokes a session of a user. It returns gorm.ErrRecordNotFound if
+// the user has no such session.
+func (r *SessionRepository) Delete(id, userID uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Session{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteByUser revokes every session of a user, along with the refresh
+// tokens issued without a session, by incrementing their token version.
+func (r *SessionRepository) DeleteByUser(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1")).Error
+	})
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type SettingsRepository struct {
+	db *gorm.DB
+}
+
+// NewSettingsRepository returns a new instance of SettingsRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewSettingsRepository(db *gorm.DB) *SettingsRepository {
+	return &SettingsRepository{db: db}
+}
+
+// Get returns the site settings.
+//
+// If no settings have been stored yet, the defaults are returned without
+// storing them, so reads work on read-only connections and replicas.
+func (r *SettingsRepository) Get() (*models.SiteSettings, error) {
+	var settings models.SiteSettings
+	err := r.db.Order("id ASC").First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		settings = defaultSettings()
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// CreateDefaults stores the default settings, unless settings are stored
+// already. Migrations call it, before the server serves requests.
+func (r *SettingsRepository) CreateDefaults() error {
+	var count int64
+	if err := r.db.Model(&models.SiteSettings{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	settings := defaultSettings()
+	return r.db.Create(&settings).Error
+}
+
+// defaultSettings returns the settings of the "features" and "site"
+// configuration keys, in effect until settings are stored.
+func defaultSettings() models.SiteSettings {
+	cfg := config.Get()
+	return models.SiteSettings{
+		RegistrationOpen: cfg.Features.UserRegistration,
+		RequireApproval:  cfg.Features.RequireApproval,
+		CommentsEnabled:  cfg.Features.CommentsEnabled,
+		SensitiveGating:  cfg.Features.SensitiveGating,
+		ReferralsEnabled: cfg.Features.Referrals,
+		DefaultLicense:   cfg.Site.DefaultLicense,
+		SecurityContact:  cfg.Site.SecurityContact,
+		PrimaryColor:     cfg.Site.PrimaryColor,
+		AccentColor:      cfg.Site.AccentColor,
+		Timezone:         cfg.Site.Timezone,
+		DateFormat:       cfg.Site.DateFormat,
+	}
+}
+
+// Update saves the changes made to the site settings.
+func (r *SettingsRepository) Update(settings *models.SiteSettings) error {
+	return r.db.Save(settings).Error
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"strings"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TagRepository struct {
+	db *gorm.DB
+}
+
+// NewTagRepository returns a new instance of TagRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewTagRepository(db *gorm.DB) *TagRepository {
+	return &TagRepository{db: db}
+}
+
+// FindBySlug finds a tag by its slug.
+func (r *TagRepository) FindBySlug(slug string) (*models.Tag, error) {
+	var tag models.Tag
+	err := r.db.Where("slug = ?", slug).First(&tag).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// List retrieves all tags ordered by name, along with the number of posts
+// each tag is attached to.
+func (r *TagRepository) List() ([]models.Tag, error) {
+	var tags []models.Tag
+	if err := r.db.Order("name ASC").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	// Count posts per tag
+	counts := make(map[uint]int64)
+	var rows []struct {
+		TagID uint
+		Count int64
+	}
+	err := r.db.Table("post_tags").
+		Select("tag_id, COUNT(post_id) AS count").
+		Group("tag_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.TagID] = row.Count
+	}
+	for i := range tags {
+		tags[i].PostCount = counts[tags[i].ID]
+	}
+
+	return tags, nil
+}
+
+// FindByPostIDs returns the tags of the given posts, keyed by post ID and
+// ordered by name.
+func (r *TagRepository) FindByPostIDs(postIDs []uint) (map[uint][]models.Tag, error) {
+	tags := make(map[uint][]models.Tag)
+	if len(postIDs) == 0 {
+		return tags, nil
+	}
+	var rows []struct {
+		PostID uint
+		models.Tag
+	}
+	err := r.db.Table("tags").
+		Select("post_tags.post_id, tags.id, tags.created_at, tags.name, tags.slug").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Where("post_tags.post_id IN ?", postIDs).
+		Order("tags.name ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tags[row.PostID] = append(tags[row.PostID], row.Tag)
+	}
+	return tags, nil
+}
+
+// FindOrCreateByNames returns the tags with the given names, creating the
+// ones that don't exist yet. Names are trimmed, and names that produce the
+// same slug are treated as the same tag.
+func (r *TagRepository) FindOrCreateByNames(names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		slug := generateSlug(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		tag := models.Tag{Name: name, Slug: slug}
+		err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag).Error
+		if err != nil {
+			return nil, err
+		}
+		if tag.ID == 0 {
+			if err := r.db.Where("slug = ?", slug).First(&tag).Error; err != nil {
+				return nil, err
+			}
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// resolve returns the stored tags matching the given ones, creating missing
+// tags by name.
+func (r *TagRepository) resolve(tags []models.Tag) ([]models.Tag, error) {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return r.FindOrCreateByNames(names)
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/SteaceP/coderage/models"
@@ -16,24 +17,47 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-// Create creates a new user in the database.
+// Create creates a new user in the database. Users signing up through a
+// login provider have no password, and can't log in with one.
 func (r *UserRepository) Create(user *models.User) error {
 	// Hash password before storing
-	hashedPassword, err := utils.HashPassword(user.Password)
-	if err != nil {
-		return err
+	if user.Password != "" {
+		hashedPassword, err := utils.HashPassword(user.Password)
+		if err != nil {
+			return err
+		}
+		user.Password = hashedPassword
 	}
-	user.Password = hashedPassword
 
 	return r.db.Create(user).Error
 }
 
-// FindByID finds a user by its ID.
+// recentContent is the number of posts and comments loaded along with a
+// user by FindByID.
+const recentContent = 20
+
+// publicUserColumns are the columns of the public profile of users.
+var publicUserColumns = []string{"id", "created_at", "username", "first_name", "last_name", "bio", "profile_picture",
+	"twitter_handle", "linked_in_profile", "personal_website"}
+
+// publicUser preloads users with the columns of their public profile only,
+// such as the authors of posts and comments.
+func publicUser(db *gorm.DB) *gorm.DB {
+	return db.Select(publicUserColumns)
+}
+
+// FindByID finds a user by its ID, along with their latest posts and
+// comments, at most recentContent of each. FindByIDLite finds the user
+// alone.
 func (r *UserRepository) FindByID(id uint) (*models.User, error) {
 	var user models.User
 	err := r.db.
-		Preload("Posts").
-		Preload("Comments").
+		Preload("Posts", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at DESC").Limit(recentContent)
+		}).
+		Preload("Comments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at DESC").Limit(recentContent)
+		}).
 		First(&user, id).Error
 	if err != nil {
 		return nil, err
@@ -41,6 +65,17 @@ func (r *UserRepository) FindByID(id uint) (*models.User, error) {
 	return &user, nil
 }
 
+// FindByIDLite finds a user by its ID, without loading their posts and
+// comments, for the lookups needing the account only, such as checking a
+// token or a password.
+func (r *UserRepository) FindByIDLite(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 // FindByUsername finds a user by its username.
 func (r *UserRepository) FindByUsername(username string) (*models.User, error) {
 	var user models.User
@@ -66,9 +101,20 @@ func (r *UserRepository) Update(user *models.User) error {
 	return r.db.Save(user).Error
 }
 
+// UpdateProfile saves the profile fields of a user, leaving the other fields
+// and the associations of the user untouched.
+func (r *UserRepository) UpdateProfile(user *models.User) error {
+	return r.db.Model(user).
+		Select("first_name", "last_name", "bio", "profile_picture", "twitter_handle", "linked_in_profile", "personal_website").
+		Updates(user).Error
+}
+
 // UpdatePassword updates a user's password by hashing the given new password and
 // storing it in the database. It takes the ID of the user to update and the new
 // password as arguments. It returns an error if the update fails.
+//
+// The token version of the user is incremented, which invalidates the refresh
+// tokens issued before.
 func (r *UserRepository) UpdatePassword(userID uint, newPassword string) error {
 	hashedPassword, err := utils.HashPassword(newPassword)
 	if err != nil {
@@ -77,7 +123,22 @@ func (r *UserRepository) UpdatePassword(userID uint, newPassword string) error {
 
 	return r.db.Model(&models.User{}).
 		Where("id = ?", userID).
-		Update("password", hashedPassword).Error
+		Updates(map[string]interface{}{
+			"password":      hashedPassword,
+			"token_version": gorm.Expr("token_version + 1"),
+		}).Error
+}
+
+// RemovePassword removes the password of a user, who can then only log in
+// through a login provider. The refresh tokens issued before are
+// invalidated, as on a password change.
+func (r *UserRepository) RemovePassword(userID uint) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"password":      "",
+			"token_version": gorm.Expr("token_version + 1"),
+		}).Error
 }
 
 // List retrieves users with pagination and filters.
@@ -89,6 +150,9 @@ func (r *UserRepository) UpdatePassword(userID uint, newPassword string) error {
 //   - is_active: bool - Filter users by active or inactive status. If false,
 //     only inactive users are returned. If true, only active users
 //     are returned. If not provided, all users are returned.
+//   - pending_approval: bool - Filter users awaiting approval or not.
+//   - search: string - Filter users whose username or email contains the
+//     given text.
 //
 // The response will be a tuple containing the paginated users, the total count
 // of users matching the filters, and an error. If the fetch operation fails,
@@ -109,6 +173,15 @@ func (r *UserRepository) List(page, pageSize int, filters map[string]interface{}
 		query = query.Where("is_active = ?", isActive)
 	}
 
+	if pending, ok := filters["pending_approval"].(bool); ok {
+		query = query.Where("pending_approval = ?", pending)
+	}
+
+	if search, ok := filters["search"].(string); ok && search != "" {
+		pattern := "%" + search + "%"
+		query = query.Where("username LIKE ? OR email LIKE ?", pattern, pattern)
+	}
+
 	// Count total
 	query.Count(&total)
 
@@ -141,7 +214,215 @@ func (r *UserRepository) VerifyUser(userID uint) error {
 		}).Error
 }
 
+// ApproveUser clears the pending approval flag of a user.
+func (r *UserRepository) ApproveUser(userID uint) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("pending_approval", false).Error
+}
+
+// Batches calls fn with every user, in batches of size users ordered by ID.
+// It stops at the first error returned by fn.
+func (r *UserRepository) Batches(size int, fn func(users []models.User) error) error {
+	var users []models.User
+	return r.db.FindInBatches(&users, size, func(tx *gorm.DB, batch int) error {
+		return fn(users)
+	}).Error
+}
+
+// FindSummaries returns the public fields of the users with the given IDs.
+func (r *UserRepository) FindSummaries(ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Select("id", "username", "first_name", "last_name", "profile_picture").
+		Where("id IN ?", ids).
+		Find(&users).Error
+	return users, err
+}
+
+// FindPublicProfiles returns the public profile fields of the users with the
+// given IDs, leaving out deactivated accounts and accounts awaiting approval.
+func (r *UserRepository) FindPublicProfiles(ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Select(publicUserColumns).
+		Where("id IN ? AND is_active = ? AND pending_approval = ?", ids, true, false).
+		Find(&users).Error
+	return users, err
+}
+
+// FindRole returns the role of a user.
+func (r *UserRepository) FindRole(userID uint) (string, error) {
+	var user models.User
+	err := r.db.Select("id", "role").First(&user, userID).Error
+	return user.Role, err
+}
+
+// UpdateRole changes the role of a user.
+func (r *UserRepository) UpdateRole(userID uint, role string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("role", role).Error
+}
+
+// FindAccess returns a user with only the fields deciding what they can
+// access: their role and membership.
+func (r *UserRepository) FindAccess(userID uint) (*models.User, error) {
+	var user models.User
+	err := r.db.Select("id", "role", "member").First(&user, userID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateMember grants or revokes the membership of a user.
+func (r *UserRepository) UpdateMember(userID uint, member bool) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("member", member).Error
+}
+
+// FindByReferralCode finds a user by their referral code.
+func (r *UserRepository) FindByReferralCode(code string) (*models.User, error) {
+	var user models.User
+	err := r.db.Where("referral_code = ?", code).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindReferralCode returns the referral code of a user, nil if none has been
+// generated yet.
+func (r *UserRepository) FindReferralCode(userID uint) (*string, error) {
+	var user models.User
+	err := r.db.Select("id", "referral_code").First(&user, userID).Error
+	return user.ReferralCode, err
+}
+
+// SetReferralCode stores the referral code of a user unless one is already
+// set. It reports whether the code was stored.
+func (r *UserRepository) SetReferralCode(userID uint, code string) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND referral_code IS NULL", userID).
+		Update("referral_code", code)
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindShowSensitive returns the sensitive content preference of a user.
+func (r *UserRepository) FindShowSensitive(userID uint) (bool, error) {
+	var user models.User
+	err := r.db.Select("id", "show_sensitive").First(&user, userID).Error
+	return user.ShowSensitive, err
+}
+
+// UpdateShowSensitive updates the sensitive content preference of a user.
+func (r *UserRepository) UpdateShowSensitive(userID uint, show bool) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("show_sensitive", show).Error
+}
+
+// UpdateEmailDigest sets whether a user is emailed digests of their unread
+// notifications.
+func (r *UserRepository) UpdateEmailDigest(userID uint, digest bool) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("email_digest", digest).Error
+}
+
 // Delete removes a user from the database by its ID.
 func (r *UserRepository) Delete(id uint) error {
 	return r.db.Delete(&models.User{}, id).Error
 }
+
+// DeleteAccount soft deletes a user along with their posts, and anonymizes
+// their comments, which stay in the discussions they belong to. The accounts
+// of login providers linked to the user are unlinked, so they can sign up
+// again, and their sessions revoked. The personal data of the user is kept until it is purged after
+// purgeAfter, and forever when it is nil.
+func (r *UserRepository) DeleteAccount(userID uint, purgeAfter *time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.Identity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Comment{}).
+			Where("user_id = ?", userID).
+			Update("anonymized", true).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.Post{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Update("purge_after", purgeAfter).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, userID).Error
+	})
+}
+
+// FindPurgeable returns the IDs of the deleted users whose personal data is
+// due to be purged at the given time.
+func (r *UserRepository) FindPurgeable(now time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.Unscoped().Model(&models.User{}).
+		Where("deleted_at IS NOT NULL AND purge_after <= ?", now).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Purge erases the personal data of a deleted user: their profile, the
+// content of their posts, their notifications and verification tokens. The
+// user row itself is kept, stripped of anything identifying, since anonymized
+// comments and the audit log still refer to it.
+func (r *UserRepository) Purge(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.Notification{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.VerificationToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Post{}).
+			Where("user_id = ?", userID).
+			Updates(map[string]interface{}{
+				"title":            "",
+				"content":          "",
+				"excerpt":          "",
+				"featured_image":   "",
+				"meta_title":       "",
+				"meta_description": "",
+			}).Error; err != nil {
+			return err
+		}
+
+		placeholder := fmt.Sprintf("deleted-%d", userID)
+		return tx.Unscoped().Model(&models.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]interface{}{
+				"username":          placeholder,
+				"email":             placeholder + "@deleted.invalid",
+				"password":          "",
+				"first_name":        "",
+				"last_name":         "",
+				"bio":               "",
+				"profile_picture":   "",
+				"twitter_handle":    "",
+				"linked_in_profile": "",
+				"personal_website":  "",
+				"referral_code":     nil,
+				"last_login":        nil,
+				"purge_after":       nil,
+			}).Error
+	})
+}
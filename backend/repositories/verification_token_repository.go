@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type VerificationTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewVerificationTokenRepository returns a new instance of VerificationTokenRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewVerificationTokenRepository(db *gorm.DB) *VerificationTokenRepository {
+	return &VerificationTokenRepository{db: db}
+}
+
+// Create stores a new verification token.
+func (r *VerificationTokenRepository) Create(token *models.VerificationToken) error {
+	return r.db.Create(token).Error
+}
+
+// FindValidByHash finds an unused, unexpired token by its hash.
+//
+// If no such token exists, the error is gorm.ErrRecordNotFound.
+func (r *VerificationTokenRepository) FindValidByHash(hash string) (*models.VerificationToken, error) {
+	var token models.VerificationToken
+	err := r.db.
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, time.Now()).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed marks a token as used so it can't be redeemed again.
+func (r *VerificationTokenRepository) MarkUsed(id uint) error {
+	return r.db.Model(&models.VerificationToken{}).
+		Where("id = ?", id).
+		Update("used_at", time.Now()).Error
+}
+
+// DeleteByUserID removes all the tokens of a user.
+func (r *VerificationTokenRepository) DeleteByUserID(userID uint) error {
+	return r.db.Where("user_id = ?", userID).Delete(&models.VerificationToken{}).Error
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"gorm.io/gorm"
+)
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository returns a new instance of WebhookRepository.
+//
+// The returned instance is backed by the provided Gorm database connection.
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// Create stores a new webhook.
+func (r *WebhookRepository) Create(webhook *models.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+// FindByID finds a webhook by its ID.
+func (r *WebhookRepository) FindByID(id uint) (*models.Webhook, error) {
+	var webhook models.Webhook
+	err := r.db.First(&webhook, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// List returns every webhook, oldest first.
+func (r *WebhookRepository) List() ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// ListActive returns the webhooks receiving events.
+func (r *WebhookRepository) ListActive() ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Where("active = ?", true).Find(&webhooks).Error
+	return webhooks, err
+}
+
+// Delete removes a webhook by its ID.
+func (r *WebhookRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Webhook{}, id).Error
+}
+
+// CreateDelivery stores a delivery attempt.
+func (r *WebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+// FindDelivery finds a delivery of a webhook by its ID.
+func (r *WebhookRepository) FindDelivery(webhookID, deliveryID uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db.Where("webhook_id = ?", webhookID).First(&delivery, deliveryID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// FindDueRetries retrieves the failed deliveries whose retry is due, the
+// longest overdue first.
+func (r *WebhookRepository) FindDueRetries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Where("next_retry_at <= ?", now).
+		Order("next_retry_at ASC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ClaimRetry clears the retry of a delivery, and reports whether it was still
+// pending, so a retry is only sent once when several instances run.
+func (r *WebhookRepository) ClaimRetry(deliveryID uint) (bool, error) {
+	result := r.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND next_retry_at IS NOT NULL", deliveryID).
+		Update("next_retry_at", nil)
+	return result.RowsAffected == 1, result.Error
+}
+
+// ListDeliveries retrieves the deliveries of a webhook with pagination, most
+// recent first. Request and response bodies are left out.
+func (r *WebhookRepository) ListDeliveries(webhookID uint, page, pageSize int) ([]models.WebhookDelivery, int64, error) {
+	var deliveries []models.WebhookDelivery
+	var total int64
+
+	query := r.db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	query.Count(&total)
+
+	err := query.
+		Omit("request_body", "response_body").
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&deliveries).Error
+
+	return deliveries, total, err
+}
@@ -0,0 +1,72 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Elasticsearch is an Engine indexing the posts into an Elasticsearch index,
+// created with a dynamic mapping by the first document indexed.
+type Elasticsearch struct {
+	*client
+	index string
+}
+
+func (e *Elasticsearch) Index(ctx context.Context, doc Document) error {
+	err := e.do(ctx, http.MethodPut, e.path("_doc", strconv.FormatUint(uint64(doc.ID), 10)), doc, nil)
+	return err
+}
+
+func (e *Elasticsearch) Delete(ctx context.Context, id uint) error {
+	err := e.do(ctx, http.MethodDelete, e.path("_doc", strconv.FormatUint(uint64(id), 10)), nil, nil)
+	return err
+}
+
+// Search matches the query against the fields of the documents, the title,
+// tags and excerpt weighing more than the content, and tolerates typos.
+func (e *Elasticsearch) Search(ctx context.Context, query string, limit int) ([]uint, error) {
+	body := map[string]interface{}{
+		"size":    limit,
+		"_source": false,
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     query,
+				"fields":    []string{"title^3", "tags^2", "excerpt^2", "author", "content"},
+				"fuzziness": "AUTO",
+			},
+		},
+	}
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				ID string `json:"_id"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	// The index doesn't exist until a document is indexed
+	if err := e.do(ctx, http.MethodPost, e.path("_search"), body, &result); err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		id, err := strconv.ParseUint(hit.ID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid document ID %q", hit.ID)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// path returns the path of an endpoint of the index.
+func (e *Elasticsearch) path(elems ...string) string {
+	path := "/" + url.PathEscape(e.index)
+	for _, elem := range elems {
+		path += "/" + elem
+	}
+	return path
+}
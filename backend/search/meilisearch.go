@@ -0,0 +1,60 @@
+package search
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Meilisearch is an Engine indexing the posts into a Meilisearch index,
+// created by the first document indexed. Meilisearch applies the changes
+// asynchronously, so they show in the results shortly after.
+type Meilisearch struct {
+	*client
+	index string
+}
+
+func (m *Meilisearch) Index(ctx context.Context, doc Document) error {
+	err := m.do(ctx, http.MethodPost, m.path("documents")+"?primaryKey=id", []Document{doc}, nil)
+	return err
+}
+
+func (m *Meilisearch) Delete(ctx context.Context, id uint) error {
+	err := m.do(ctx, http.MethodDelete, m.path("documents", strconv.FormatUint(uint64(id), 10)), nil, nil)
+	return err
+}
+
+// Search matches the query against every field of the documents, ranked
+// with the default rules of Meilisearch, which tolerate typos.
+func (m *Meilisearch) Search(ctx context.Context, query string, limit int) ([]uint, error) {
+	body := map[string]interface{}{
+		"q":                    query,
+		"limit":                limit,
+		"attributesToRetrieve": []string{"id"},
+	}
+	var result struct {
+		Hits []struct {
+			ID uint `json:"id"`
+		} `json:"hits"`
+	}
+	// The index doesn't exist until a document is indexed
+	if err := m.do(ctx, http.MethodPost, m.path("search"), body, &result); err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(result.Hits))
+	for i, hit := range result.Hits {
+		ids[i] = hit.ID
+	}
+	return ids, nil
+}
+
+// path returns the path of an endpoint of the index.
+func (m *Meilisearch) path(elems ...string) string {
+	path := "/indexes/" + url.PathEscape(m.index)
+	for _, elem := range elems {
+		path += "/" + elem
+	}
+	return path
+}
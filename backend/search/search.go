@@ -0,0 +1,125 @@
+// Package search indexes the posts into an external search engine,
+// Elasticsearch or Meilisearch, which then ranks the post searches in place
+// of the database.
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+)
+
+// maxErrorBody is the part of an error response kept in the error
+const maxErrorBody = 1 << 10
+
+// Document is a post as indexed by the engines.
+type Document struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Slug        string    `json:"slug"`
+	Excerpt     string    `json:"excerpt"`
+	Content     string    `json:"content"`
+	Tags        []string  `json:"tags"`
+	Author      string    `json:"author"` // Username
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Engine is an external search engine holding an index of the posts.
+type Engine interface {
+	// Index adds a document to the index, or replaces it.
+	Index(ctx context.Context, doc Document) error
+	// Delete removes a document from the index. Missing documents are
+	// ignored.
+	Delete(ctx context.Context, id uint) error
+	// Search returns the IDs of the documents matching a query, the most
+	// relevant first, up to limit.
+	Search(ctx context.Context, query string, limit int) ([]uint, error)
+}
+
+// New returns the engine selected by the "search.engine.driver"
+// configuration key, or nil when none is set and the database serves the
+// searches.
+func New() (Engine, error) {
+	cfg := config.Get().Search.Engine
+	c := &client{
+		http:    &http.Client{Timeout: cfg.Timeout},
+		baseURL: strings.TrimSuffix(cfg.URL, "/"),
+	}
+
+	switch driver := cfg.Driver; driver {
+	case "":
+		return nil, nil
+	case "elasticsearch":
+		if cfg.APIKey != "" {
+			c.auth = "ApiKey " + cfg.APIKey
+		}
+		return &Elasticsearch{client: c, index: cfg.Index}, nil
+	case "meilisearch":
+		if cfg.APIKey != "" {
+			c.auth = "Bearer " + cfg.APIKey
+		}
+		return &Meilisearch{client: c, index: cfg.Index}, nil
+	default:
+		return nil, fmt.Errorf("unsupported search engine driver: %s", driver)
+	}
+}
+
+// client sends the requests of an engine to its HTTP API.
+type client struct {
+	http    *http.Client
+	baseURL string
+	auth    string // Authorization header, if any
+}
+
+// do sends a request with a JSON body, when set, and decodes the JSON
+// response into out, when set. A 404 response isn't an error, out being left
+// unchanged, as deleting a missing document or searching an index not
+// created yet are.
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, payload)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.auth != "" {
+		req.Header.Set("Authorization", c.auth)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(message))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%s %s: invalid response: %v", method, path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package securitylog
+
+import (
+	"bytes"
+	"fmt"
+	"log/syslog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/redact"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Outputs of security events
+const (
+	OutputStdout = "stdout"
+	OutputSyslog = "syslog"
+	OutputHTTP   = "http"
+)
+
+// httpQueueSize bounds the events waiting to be posted to the HTTP collector.
+// Events are dropped rather than slowing requests down when it is full.
+const httpQueueSize = 1024
+
+// New returns the logger writing security events as JSON lines to the output
+// configured under the "security_log" configuration key, and a function
+// flushing and closing the output. Events are discarded when security
+// logging is disabled.
+func New() (*zap.Logger, func(), error) {
+	cfg := config.Get().SecurityLog
+	if !cfg.Enabled {
+		return zap.NewNop(), func() {}, nil
+	}
+
+	var (
+		sink        zapcore.WriteSyncer
+		closeOutput = func() {}
+	)
+	switch output := cfg.Output; output {
+	case OutputStdout:
+		sink = zapcore.Lock(os.Stdout)
+	case OutputSyslog:
+		writer, err := syslog.Dial(
+			cfg.Syslog.Network,
+			cfg.Syslog.Address,
+			syslog.LOG_AUTH|syslog.LOG_NOTICE,
+			cfg.Syslog.Tag,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		sink = zapcore.AddSync(writer)
+		closeOutput = func() { writer.Close() }
+	case OutputHTTP:
+		url := cfg.HTTP.URL
+		if url == "" {
+			return nil, nil, fmt.Errorf("security_log.http.url is required by the http output")
+		}
+		writer := newHTTPWriter(url, cfg.HTTP.Headers, cfg.HTTP.Timeout)
+		sink = writer
+		closeOutput = writer.Close
+	default:
+		return nil, nil, fmt.Errorf("unknown security log output %q", output)
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = "timestamp"
+	encoderConfig.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), sink, zapcore.InfoLevel)
+
+	l := zap.New(redact.Core(core)).With(
+		zap.String("log_type", "security"),
+		zap.String("service", cfg.Service),
+	)
+	return l, func() {
+		l.Sync()
+		closeOutput()
+	}, nil
+}
+
+// httpWriter posts every event to an HTTP collector, such as a Splunk HTTP
+// Event Collector or a Logstash HTTP input, from a background goroutine.
+type httpWriter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	queue   chan []byte
+	done    chan struct{}
+}
+
+func newHTTPWriter(url string, headers map[string]string, timeout time.Duration) *httpWriter {
+	w := &httpWriter{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+		queue:   make(chan []byte, httpQueueSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues an event. The event is copied since zap reuses its buffers.
+func (w *httpWriter) Write(p []byte) (int, error) {
+	event := make([]byte, len(p))
+	copy(event, p)
+	select {
+	case w.queue <- event:
+	default:
+		fmt.Fprintln(os.Stderr, "security log queue is full, dropping event")
+	}
+	return len(p), nil
+}
+
+// Sync is a no-op, events are flushed on Close.
+func (w *httpWriter) Sync() error {
+	return nil
+}
+
+// Close stops accepting events and waits for the queued ones to be posted.
+func (w *httpWriter) Close() {
+	close(w.queue)
+	<-w.done
+}
+
+func (w *httpWriter) run() {
+	defer close(w.done)
+	for event := range w.queue {
+		if err := w.post(event); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to post security event: %v\n", err)
+		}
+	}
+}
+
+func (w *httpWriter) post(event []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(event))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Package securitylog emits standardized security events, such as
+// authentication failures and admin actions, on a dedicated output that
+// deployments can ship to their SIEM.
+package securitylog
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/SteaceP/coderage/apperrors"
+	"github.com/SteaceP/coderage/geoip"
+	"github.com/SteaceP/coderage/types"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Security event types
+const (
+	EventAuthFailure      = "auth_failure"
+	EventPermissionDenied = "permission_denied"
+	EventRateLimited      = "rate_limited"
+	EventAdminAction      = "admin_action"
+)
+
+// logger is the logger security events are emitted on, discarding them until
+// SetLogger is called
+var logger atomic.Pointer[zap.Logger]
+
+func init() {
+	logger.Store(zap.NewNop())
+}
+
+// SetLogger sets the logger security events are emitted on.
+func SetLogger(l *zap.Logger) {
+	logger.Store(l)
+}
+
+// AuthFailure records a failed authentication, such as an invalid token or
+// wrong credentials.
+func AuthFailure(r *http.Request, reason string, fields ...zap.Field) {
+	emit(r, EventAuthFailure, zap.WarnLevel, append(fields, zap.String("reason", reason)))
+}
+
+// PermissionDenied records an authenticated request refused for lack of
+// permissions.
+func PermissionDenied(r *http.Request, reason string) {
+	emit(r, EventPermissionDenied, zap.WarnLevel, []zap.Field{zap.String("reason", reason)})
+}
+
+// RateLimited records a request rejected by the rate limiter.
+func RateLimited(r *http.Request, key string) {
+	emit(r, EventRateLimited, zap.WarnLevel, []zap.Field{zap.String("rate_limit_key", key)})
+}
+
+// AdminAction records an admin or moderation action, as added to the audit
+// log.
+func AdminAction(r *http.Request, action, targetType string, targetID uint) {
+	emit(r, EventAdminAction, zap.InfoLevel, []zap.Field{
+		zap.String("action", action),
+		zap.String("target_type", targetType),
+		zap.Uint("target_id", targetID),
+	})
+}
+
+// emit writes a security event along with the details of the request that
+// triggered it.
+func emit(r *http.Request, event string, level zapcore.Level, fields []zap.Field) {
+	entry := logger.Load().Check(level, "Security event")
+	if entry == nil {
+		return
+	}
+
+	fields = append(fields,
+		zap.String("event", event),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("remote_addr", geoip.StoredIP(r.RemoteAddr)),
+		zap.String("country", geoip.RequestCountry(r)),
+		zap.String("user_agent", r.UserAgent()),
+		zap.String("request_id", apperrors.RequestID(r)),
+	)
+	if userID, ok := r.Context().Value(types.KeyUserID).(uint); ok {
+		fields = append(fields, zap.Uint("user_id", userID))
+	}
+	entry.Write(fields...)
+}
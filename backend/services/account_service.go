@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/utils"
+
+	"go.uber.org/zap"
+)
+
+// AccountExport holds all the data of a user, as exported on request.
+type AccountExport struct {
+	ExportedAt time.Time        `json:"exported_at"`
+	Profile    *models.User     `json:"profile"`
+	Posts      []models.Post    `json:"posts"`
+	Comments   []models.Comment `json:"comments"`
+}
+
+// AccountService lets users export their data and delete their account.
+type AccountService struct {
+	userRepo    *repositories.UserRepository
+	postRepo    *repositories.PostRepository
+	commentRepo *repositories.CommentRepository
+	logger      *zap.Logger
+}
+
+// NewAccountService returns a new instance of AccountService with the
+// provided UserRepository, PostRepository and CommentRepository.
+func NewAccountService(userRepo *repositories.UserRepository, postRepo *repositories.PostRepository, commentRepo *repositories.CommentRepository, logger *zap.Logger) *AccountService {
+	return &AccountService{
+		userRepo:    userRepo,
+		postRepo:    postRepo,
+		commentRepo: commentRepo,
+		logger:      logger,
+	}
+}
+
+// Export returns the profile, posts and comments of a user, whatever their
+// status.
+func (s *AccountService) Export(userID uint) (*AccountExport, error) {
+	user, err := s.userRepo.FindByIDLite(userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	posts, err := s.postRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.commentRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccountExport{
+		ExportedAt: time.Now(),
+		Profile:    user,
+		Posts:      posts,
+		Comments:   comments,
+	}, nil
+}
+
+// Delete deletes the account of a user after checking their password. The
+// account and posts are soft deleted and the comments anonymized. When the
+// "accounts.deletion_grace_period" setting is positive, the personal data of
+// the user is purged once it has passed.
+//
+// Admins must give up their role before deleting their account, so that a
+// site is never left without an admin by mistake.
+func (s *AccountService) Delete(userID uint, password string) error {
+	user, err := s.userRepo.FindByIDLite(userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return invalid("password is incorrect")
+	}
+	if user.Role == types.RoleAdmin {
+		return fmt.Errorf("%w: admins cannot delete their own account", ErrForbidden)
+	}
+
+	var purgeAfter *time.Time
+	if grace := config.Get().Accounts.DeletionGracePeriod; grace > 0 {
+		at := time.Now().Add(grace)
+		purgeAfter = &at
+	}
+	return s.userRepo.DeleteAccount(userID, purgeAfter)
+}
+
+// Purge erases the personal data of the deleted accounts whose grace period
+// is over, and returns the number of accounts purged. Accounts failing to be
+// purged are retried on the next run.
+func (s *AccountService) Purge() (int, error) {
+	ids, err := s.userRepo.FindPurgeable(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		if err := s.userRepo.Purge(id); err != nil {
+			s.logger.Error("Failed to purge account", zap.Uint("user_id", id), zap.Error(err))
+			continue
+		}
+		s.logger.Info("Purged deleted account", zap.Uint("user_id", id))
+		purged++
+	}
+	return purged, nil
+}
+
+// Run purges the accounts due right away, then at the given interval until
+// the context is canceled. Intervals under a minute are raised to a minute.
+func (s *AccountService) Run(ctx context.Context, interval time.Duration) {
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	s.runPurge()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runPurge()
+		}
+	}
+}
+
+func (s *AccountService) runPurge() {
+	if _, err := s.Purge(); err != nil {
+		s.logger.Error("Failed to find accounts to purge", zap.Error(err))
+	}
+}
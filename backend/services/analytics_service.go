@@ -0,0 +1,129 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/SteaceP/coderage/repositories"
+)
+
+// AuthorAnalytics is the performance of the posts of an author: the views,
+// likes and comments of each post, in total and over the last days, and the
+// views and comments of all their posts for each of these days, in UTC.
+type AuthorAnalytics struct {
+	Since  time.Time       `json:"since"`
+	Totals PostAnalytics   `json:"totals"` // Of all the posts, without their ID and title
+	Posts  []PostAnalytics `json:"posts"`  // Most viewed over the period first
+	Days   []AnalyticsDay  `json:"days"`
+}
+
+// PostAnalytics is the performance of a post.
+type PostAnalytics struct {
+	PostID         uint       `json:"post_id,omitempty"`
+	Title          string     `json:"title,omitempty"`
+	Slug           string     `json:"slug,omitempty"`
+	Status         string     `json:"status,omitempty"`
+	PublishedAt    *time.Time `json:"published_at,omitempty"`
+	Views          int64      `json:"views"`
+	Likes          int64      `json:"likes"`
+	Comments       int64      `json:"comments"`
+	PeriodViews    int64      `json:"period_views"`    // Over the period
+	PeriodComments int64      `json:"period_comments"` // Over the period
+}
+
+// AnalyticsDay is the activity on the posts of an author during a day.
+type AnalyticsDay struct {
+	Day      time.Time `json:"day"`
+	Views    int64     `json:"views"`
+	Comments int64     `json:"comments"`
+}
+
+// AnalyticsService reports to authors how their posts perform.
+type AnalyticsService struct {
+	postRepo    *repositories.PostRepository
+	commentRepo *repositories.CommentRepository
+	viewRepo    *repositories.PostViewRepository
+}
+
+// NewAnalyticsService returns a new instance of AnalyticsService with the
+// provided PostRepository, CommentRepository and PostViewRepository.
+func NewAnalyticsService(postRepo *repositories.PostRepository, commentRepo *repositories.CommentRepository, viewRepo *repositories.PostViewRepository) *AnalyticsService {
+	return &AnalyticsService{
+		postRepo:    postRepo,
+		commentRepo: commentRepo,
+		viewRepo:    viewRepo,
+	}
+}
+
+// AuthorAnalytics returns the performance of the posts of a user, drafts
+// included, over the last days, 30 by default.
+func (s *AnalyticsService) AuthorAnalytics(userID uint, days int) (*AuthorAnalytics, error) {
+	since := statsSince(days)
+	posts, err := s.postRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	views, err := s.viewRepo.TopPostsForAuthor(userID, since, -1)
+	if err != nil {
+		return nil, err
+	}
+	dailyViews, err := s.viewRepo.DailyForAuthor(userID, since)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(posts))
+	for i := range posts {
+		ids[i] = posts[i].ID
+	}
+	comments, err := s.commentRepo.PublishedOnPostsSince(ids, since)
+	if err != nil {
+		return nil, err
+	}
+
+	periodViews := make(map[uint]int64, len(views))
+	for _, v := range views {
+		periodViews[v.PostID] = v.Views
+	}
+	periodComments := make(map[uint]int64)
+	dailyComments := make(map[time.Time]int64)
+	for _, c := range comments {
+		periodComments[c.PostID]++
+		dailyComments[c.CreatedAt.UTC().Truncate(24*time.Hour)]++
+	}
+
+	analytics := &AuthorAnalytics{Since: since, Posts: make([]PostAnalytics, 0, len(posts))}
+	for _, post := range posts {
+		stats := PostAnalytics{
+			PostID:         post.ID,
+			Title:          post.Title,
+			Slug:           post.Slug,
+			Status:         post.Status,
+			Views:          int64(post.ViewCount),
+			Likes:          int64(post.LikeCount),
+			Comments:       int64(post.CommentCount),
+			PeriodViews:    periodViews[post.ID],
+			PeriodComments: periodComments[post.ID],
+		}
+		if post.Status != "draft" && !post.PublishedAt.IsZero() {
+			publishedAt := post.PublishedAt
+			stats.PublishedAt = &publishedAt
+		}
+		analytics.Posts = append(analytics.Posts, stats)
+
+		analytics.Totals.Views += stats.Views
+		analytics.Totals.Likes += stats.Likes
+		analytics.Totals.Comments += stats.Comments
+		analytics.Totals.PeriodViews += stats.PeriodViews
+		analytics.Totals.PeriodComments += stats.PeriodComments
+	}
+	sort.SliceStable(analytics.Posts, func(i, j int) bool {
+		return analytics.Posts[i].PeriodViews > analytics.Posts[j].PeriodViews
+	})
+
+	viewStats := newViewStats(since, 0, dailyViews)
+	analytics.Days = make([]AnalyticsDay, len(viewStats.Days))
+	for i, day := range viewStats.Days {
+		analytics.Days[i] = AnalyticsDay{Day: day.Day, Views: day.Views, Comments: dailyComments[day.Day]}
+	}
+	return analytics, nil
+}
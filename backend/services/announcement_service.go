@@ -0,0 +1,136 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/types"
+)
+
+// ErrAnnouncementNotFound is returned when an announcement doesn't exist.
+var ErrAnnouncementNotFound = errors.New("announcement not found")
+
+// AnnouncementUpdate holds the changes to apply to an announcement. Nil
+// fields are left unchanged.
+type AnnouncementUpdate struct {
+	Message  *string
+	Severity *string
+	Audience *string
+	StartsAt *time.Time
+	EndsAt   *time.Time
+}
+
+type AnnouncementService struct {
+	announcementRepo *repositories.AnnouncementRepository
+	userRepo         *repositories.UserRepository
+}
+
+// NewAnnouncementService returns a new instance of AnnouncementService with
+// the provided AnnouncementRepository and UserRepository.
+func NewAnnouncementService(announcementRepo *repositories.AnnouncementRepository, userRepo *repositories.UserRepository) *AnnouncementService {
+	return &AnnouncementService{
+		announcementRepo: announcementRepo,
+		userRepo:         userRepo,
+	}
+}
+
+// CreateAnnouncement validates and stores a new announcement. The severity
+// defaults to info and the audience to everyone.
+func (s *AnnouncementService) CreateAnnouncement(announcement *models.Announcement) error {
+	if announcement.Severity == "" {
+		announcement.Severity = models.SeverityInfo
+	}
+	if announcement.Audience == "" {
+		announcement.Audience = models.AudienceAll
+	}
+	if err := validateAnnouncement(announcement); err != nil {
+		return err
+	}
+	return s.announcementRepo.Create(announcement)
+}
+
+// ListAnnouncements retrieves every announcement, including past and
+// scheduled ones, with pagination.
+func (s *AnnouncementService) ListAnnouncements(page, pageSize int) ([]models.Announcement, int64, error) {
+	return s.announcementRepo.List(page, pageSize)
+}
+
+// ListActiveAnnouncements retrieves the announcements currently shown to a
+// viewer, zero for anonymous visitors.
+func (s *AnnouncementService) ListActiveAnnouncements(viewerID uint) ([]models.Announcement, error) {
+	audiences := []string{models.AudienceAll}
+	if viewerID != 0 {
+		audiences = append(audiences, models.AudienceAuthenticated)
+		if user, err := s.userRepo.FindAccess(viewerID); err == nil {
+			if user.Member || user.Role == types.RoleAdmin {
+				audiences = append(audiences, models.AudienceMembers)
+			}
+			if user.Role == types.RoleAdmin {
+				audiences = append(audiences, models.AudienceAdmins)
+			}
+		}
+	}
+	return s.announcementRepo.ListActive(time.Now(), audiences)
+}
+
+// UpdateAnnouncement applies the given changes to an announcement and saves
+// it. The changes are validated against the resulting announcement, which is
+// returned.
+func (s *AnnouncementService) UpdateAnnouncement(id uint, update AnnouncementUpdate) (*models.Announcement, error) {
+	announcement, err := s.announcementRepo.FindByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrAnnouncementNotFound)
+	}
+
+	setString(&announcement.Message, update.Message)
+	setString(&announcement.Severity, update.Severity)
+	setString(&announcement.Audience, update.Audience)
+	if update.StartsAt != nil {
+		announcement.StartsAt = update.StartsAt
+	}
+	if update.EndsAt != nil {
+		announcement.EndsAt = update.EndsAt
+	}
+
+	if err := validateAnnouncement(announcement); err != nil {
+		return nil, err
+	}
+	if err := s.announcementRepo.Update(announcement); err != nil {
+		return nil, err
+	}
+	return announcement, nil
+}
+
+// DeleteAnnouncement removes an announcement.
+func (s *AnnouncementService) DeleteAnnouncement(id uint) error {
+	if _, err := s.announcementRepo.FindByID(id); err != nil {
+		return notFound(err, ErrAnnouncementNotFound)
+	}
+	return s.announcementRepo.Delete(id)
+}
+
+// validateAnnouncement validates the fields of an announcement.
+func validateAnnouncement(announcement *models.Announcement) error {
+	if announcement.Message == "" {
+		return invalid("message is required")
+	}
+	switch announcement.Severity {
+	case models.SeverityInfo, models.SeverityWarning, models.SeverityCritical:
+	default:
+		return invalid("severity must be one of info, warning or critical")
+	}
+	switch announcement.Audience {
+	case models.AudienceAll, models.AudienceAuthenticated, models.AudienceMembers, models.AudienceAdmins:
+	default:
+		return invalid("audience must be one of all, authenticated, members or admins")
+	}
+	if err := validateStruct(announcement); err != nil {
+		return err
+	}
+	if announcement.StartsAt != nil && announcement.EndsAt != nil && !announcement.EndsAt.After(*announcement.StartsAt) {
+		return invalid("ends_at must be after starts_at")
+	}
+	return nil
+}
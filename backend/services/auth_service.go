@@ -1,20 +1,50 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"time"
 
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/jwtkeys"
 	"github.com/SteaceP/coderage/models"
 	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/types"
 	"github.com/SteaceP/coderage/utils"
 
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
-	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type AuthService struct {
-	userRepo *repositories.UserRepository
+	userRepo    *repositories.UserRepository
+	sessionRepo *repositories.SessionRepository
+	audit       *AuditService
+	bus         events.Bus
+	logger      *zap.Logger
+}
+
+var (
+	// ErrInvalidRefreshToken is returned for refresh tokens that are invalid,
+	// expired or revoked.
+	ErrInvalidRefreshToken = errors.New("invalid or revoked refresh token")
+	// ErrSessionNotFound is returned when a session doesn't exist, or was
+	// revoked.
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// refreshTokenLifetime is the time refresh tokens, and the sessions they
+// keep alive, are valid after they are issued.
+const refreshTokenLifetime = 7 * 24 * time.Hour
+
+// Client is the device a session was started or last used on.
+type Client struct {
+	UserAgent string
+	IP        string
+	RequestID string // Request the client sent, recorded in the audit log
 }
 
 type TokenDetails struct {
@@ -26,65 +56,127 @@ type TokenDetails struct {
 	RtExpires    int64
 }
 
-// NewAuthService creates a new instance of AuthService with the provided UserRepository.
-func NewAuthService(userRepo *repositories.UserRepository) *AuthService {
+// NewAuthService creates a new instance of AuthService with the provided
+// repositories, publishing the registrations on bus and recording logins
+// with audit.
+func NewAuthService(userRepo *repositories.UserRepository, sessionRepo *repositories.SessionRepository, audit *AuditService, bus events.Bus, logger *zap.Logger) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		audit:       audit,
+		bus:         bus,
+		logger:      logger,
 	}
 }
 
-// Register creates a new user in the database.
+// Register creates a new user in the database. The password is hashed by the
+// repository before it is stored. A UserRegistered event is published.
 func (s *AuthService) Register(user *models.User) error {
+	// New accounts are regular users
+	if user.Role == "" {
+		user.Role = "user"
+	}
+
 	// Validate user input
-	if err := utils.ValidateStruct(user); len(err) > 0 {
-		return errors.New(err[0])
+	if err := validateStruct(user); err != nil {
+		return err
+	}
+	if user.Password == "" {
+		return invalid("password is required")
 	}
 
 	// Check if username or email already exists
 	_, errUsername := s.userRepo.FindByUsername(user.Username)
 	if errUsername == nil {
-		return errors.New("username already exists")
+		return ErrUsernameTaken
 	}
 
 	_, errEmail := s.userRepo.FindByEmail(user.Email)
 	if errEmail == nil {
-		return errors.New("email already exists")
+		return ErrEmailTaken
 	}
 
 	// Create user
-	return s.userRepo.Create(user)
+	if err := s.userRepo.Create(user); err != nil {
+		return err
+	}
+	publish(context.Background(), s.bus, s.logger, events.UserRegistered, events.UserPayload{
+		UserID:   user.ID,
+		Username: user.Username,
+	})
+	return nil
 }
 
-// Login logs in a user by verifying their email and password.
-func (s *AuthService) Login(email, password string) (*TokenDetails, error) {
+// Login logs in a user by verifying their email and password. Accounts
+// awaiting approval and deactivated accounts can't log in. Logins and failed logins are recorded in
+// the audit log, the latter targeting the account when the email address is
+// the one of a user.
+func (s *AuthService) Login(email, password string, client Client) (*TokenDetails, error) {
 	// Find user by email
 	user, err := s.userRepo.FindByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.recordLogin(AuditLoginFailed, 0, client, "unknown_email")
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, err
 	}
 
 	// Verify password
 	if !utils.CheckPasswordHash(password, user.Password) {
-		return nil, errors.New("invalid credentials")
+		s.recordLogin(AuditLoginFailed, user.ID, client, "wrong_password")
+		return nil, ErrInvalidCredentials
+	}
+
+	// Reject accounts awaiting approval
+	if user.PendingApproval {
+		s.recordLogin(AuditLoginFailed, user.ID, client, "pending_approval")
+		return nil, ErrPendingApproval
+	}
+
+	// Reject deactivated accounts
+	if !user.IsActive {
+		s.recordLogin(AuditLoginFailed, user.ID, client, "inactive")
+		return nil, ErrAccountInactive
 	}
 
 	// Update last login
 	if err := s.userRepo.UpdateLastLogin(user.ID); err != nil {
 		return nil, err
 	}
+	s.recordLogin(AuditLogin, user.ID, client, "")
 
 	// Generate tokens
-	return s.CreateTokenPair(user)
+	return s.CreateTokenPair(user, client)
 }
 
-// CreateTokenPair creates a pair of access and refresh tokens for the given user.
-func (s *AuthService) CreateTokenPair(user *models.User) (*TokenDetails, error) {
+// CreateTokenPair starts a session of the given user on the client, and
+// creates its pair of access and refresh tokens.
+func (s *AuthService) CreateTokenPair(user *models.User, client Client) (*TokenDetails, error) {
+	now := time.Now()
+	session := &models.Session{
+		UserID:       user.ID,
+		TokenVersion: user.TokenVersion,
+		RefreshUUID:  uuid.New().String(),
+		UserAgent:    client.UserAgent,
+		IP:           client.IP,
+		LastUsedAt:   now,
+		ExpiresAt:    now.Add(refreshTokenLifetime),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+	return s.tokenPair(user, session)
+}
+
+// tokenPair creates the access and refresh tokens of a session.
+func (s *AuthService) tokenPair(user *models.User, session *models.Session) (*TokenDetails, error) {
 	td := &TokenDetails{}
-	td.AtExpires = time.Now().Add(time.Hour * 24).Unix()
+	td.AtExpires = time.Now().Add(time.Duration(config.Get().JWT.ExpirationHours) * time.Hour).Unix()
 	td.AccessUUID = uuid.New().String()
 
-	td.RtExpires = time.Now().Add(time.Hour * 24 * 7).Unix()
-	td.RefreshUUID = uuid.New().String()
+	td.RtExpires = session.ExpiresAt.Unix()
+	td.RefreshUUID = session.RefreshUUID
 
 	// Access Token
 	atClaims := jwt.MapClaims{
@@ -95,10 +187,13 @@ func (s *AuthService) CreateTokenPair(user *models.User) (*TokenDetails, error)
 		"authorized": true,
 		"exp":        td.AtExpires,
 		"uuid":       td.AccessUUID,
+		"version":    user.TokenVersion,
+		"sid":        session.ID,
+		"typ":        types.TokenAccess,
 	}
-	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
+	keys := jwtkeys.Current()
 	var err error
-	td.AccessToken, err = at.SignedString([]byte(viper.GetString("jwt.secret")))
+	td.AccessToken, err = keys.Sign(atClaims)
 	if err != nil {
 		return nil, err
 	}
@@ -108,9 +203,11 @@ func (s *AuthService) CreateTokenPair(user *models.User) (*TokenDetails, error)
 		"user_id": user.ID,
 		"uuid":    td.RefreshUUID,
 		"exp":     td.RtExpires,
+		"version": user.TokenVersion,
+		"sid":     session.ID,
+		"typ":     types.TokenRefresh,
 	}
-	rt := jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims)
-	td.RefreshToken, err = rt.SignedString([]byte(viper.GetString("jwt.secret")))
+	td.RefreshToken, err = keys.Sign(rtClaims)
 	if err != nil {
 		return nil, err
 	}
@@ -118,33 +215,117 @@ func (s *AuthService) CreateTokenPair(user *models.User) (*TokenDetails, error)
 	return td, nil
 }
 
-// RefreshToken verifies the given refresh token and generates a new pair of access and refresh tokens.
-func (s *AuthService) RefreshToken(refreshToken string) (*TokenDetails, error) {
+// RefreshToken verifies the given refresh token and generates a new pair of
+// access and refresh tokens, replacing the refresh token in its session.
+// Using a refresh token that was already replaced revokes the session, since
+// it may have been stolen. Access tokens are rejected without revoking
+// anything. Refresh tokens issued before sessions start one.
+func (s *AuthService) RefreshToken(refreshToken string, client Client) (*TokenDetails, error) {
 	// Verify refresh token
-	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid token signing method")
-		}
-		return []byte(viper.GetString("jwt.secret")), nil
-	})
-
+	token, err := jwt.Parse(refreshToken, jwtkeys.Current().Keyfunc)
 	if err != nil {
-		return nil, errors.New("invalid refresh token")
+		return nil, ErrInvalidRefreshToken
 	}
 
 	// Extract claims
 	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return nil, errors.New("invalid token claims")
+	if !ok || !token.Valid || utils.TokenType(claims) != types.TokenRefresh {
+		return nil, ErrInvalidRefreshToken
 	}
 
 	// Find user
-	userID := uint(claims["user_id"].(float64))
-	user, err := s.userRepo.FindByID(userID)
+	userIDF, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, ErrInvalidRefreshToken
+	}
+	user, err := s.userRepo.FindByIDLite(uint(userIDF))
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, ErrInvalidRefreshToken
+	}
+	if user.PendingApproval {
+		return nil, ErrPendingApproval
 	}
+	if !user.IsActive {
+		return nil, ErrAccountInactive
+	}
+
+	// Tokens issued before a password change are no longer valid. Tokens
+	// without a version predate versioning and count as version 0.
+	version, _ := claims["version"].(float64)
+	if int(version) != user.TokenVersion {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	sessionID, ok := claims["sid"].(float64)
+	if !ok {
+		return s.CreateTokenPair(user, client)
+	}
+	session, err := s.sessionRepo.FindByID(uint(sessionID), user.ID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	refreshUUID, _ := claims["uuid"].(string)
+	if session.RefreshUUID != refreshUUID {
+		s.logger.Warn("Refresh token reused, revoking the session",
+			zap.Uint("user_id", user.ID), zap.Uint("session_id", session.ID))
+		if err := s.sessionRepo.Delete(session.ID, user.ID); err != nil {
+			s.logger.Error("Failed to revoke session", zap.Uint("session_id", session.ID), zap.Error(err))
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Replace the refresh token of the session
+	now := time.Now()
+	session.RefreshUUID = uuid.New().String()
+	session.UserAgent = client.UserAgent
+	session.IP = client.IP
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(refreshTokenLifetime)
+	rotated, err := s.sessionRepo.Rotate(session, refreshUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return nil, ErrInvalidRefreshToken
+	}
+	return s.tokenPair(user, session)
+}
+
+// recordLogin adds a login attempt with a password to the audit log. The
+// user is the actor of successful logins only.
+func (s *AuthService) recordLogin(action string, userID uint, client Client, reason string) {
+	entry := &models.AuditLog{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   userID,
+		RemoteAddr: client.IP,
+		RequestID:  client.RequestID,
+	}
+	if action == AuditLogin {
+		entry.ActorID = userID
+	}
+	if reason != "" {
+		entry.Details = map[string]interface{}{"reason": reason}
+	}
+	s.audit.Record(entry)
+}
+
+// Sessions lists the active sessions of a user, the ones used last first.
+func (s *AuthService) Sessions(userID uint) ([]models.Session, error) {
+	sessions, err := s.sessionRepo.ListActive(userID)
+	if sessions == nil {
+		sessions = []models.Session{}
+	}
+	return sessions, err
+}
+
+// RevokeSession logs a user out of one of their sessions: its refresh token
+// is rejected, and so are its access tokens.
+func (s *AuthService) RevokeSession(userID, sessionID uint) error {
+	return notFound(s.sessionRepo.Delete(sessionID, userID), ErrSessionNotFound)
+}
 
-	// Generate new token pair
-	return s.CreateTokenPair(user)
+// RevokeSessions logs a user out everywhere, revoking all their sessions.
+func (s *AuthService) RevokeSessions(userID uint) error {
+	return s.sessionRepo.DeleteByUser(userID)
 }
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCategoryNotFound is returned when a category doesn't exist.
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategorySlugTaken is returned when giving a category the slug of
+	// another one.
+	ErrCategorySlugTaken = errors.New("a category with this slug already exists")
+)
+
+// CategoryUpdate holds the changes to apply to a category. Nil fields are
+// left unchanged.
+type CategoryUpdate struct {
+	Name        *string
+	Slug        *string
+	Description *string
+	ParentID    *uint // 0 to make the category top-level
+	Position    *int
+}
+
+// apply sets the fields of a category that are set in the update.
+func (u CategoryUpdate) apply(category *models.Category) {
+	setString(&category.Name, u.Name)
+	setString(&category.Slug, u.Slug)
+	setString(&category.Description, u.Description)
+	if u.ParentID != nil {
+		category.ParentID = nil
+		if *u.ParentID != 0 {
+			parentID := *u.ParentID
+			category.ParentID = &parentID
+		}
+	}
+	if u.Position != nil {
+		category.Position = *u.Position
+	}
+}
+
+// CategoryService manages the categories, the sections of the site each
+// post is filed under, which admins organize in a tree.
+type CategoryService struct {
+	categoryRepo *repositories.CategoryRepository
+}
+
+// NewCategoryService returns a new instance of CategoryService with the
+// provided CategoryRepository.
+func NewCategoryService(categoryRepo *repositories.CategoryRepository) *CategoryService {
+	return &CategoryService{
+		categoryRepo: categoryRepo,
+	}
+}
+
+// ListCategories returns the tree of the categories: the top-level
+// categories, each holding its subcategories in Children, by position then
+// name.
+func (s *CategoryService) ListCategories() ([]models.Category, error) {
+	categories, err := s.categoryRepo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint][]models.Category)
+	var roots []models.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+	for i := range roots {
+		nestCategories(&roots[i], children)
+	}
+	return roots, nil
+}
+
+// nestCategories sets the subcategories of a category, at every depth.
+func nestCategories(category *models.Category, children map[uint][]models.Category) {
+	category.Children = children[category.ID]
+	for i := range category.Children {
+		nestCategories(&category.Children[i], children)
+	}
+}
+
+// GetCategory returns a category by its slug, with its subcategories at
+// every depth.
+func (s *CategoryService) GetCategory(slug string) (*models.Category, error) {
+	tree, err := s.ListCategories()
+	if err != nil {
+		return nil, err
+	}
+	if category := findCategory(tree, slug); category != nil {
+		return category, nil
+	}
+	return nil, ErrCategoryNotFound
+}
+
+// findCategory returns the category of a slug in a tree, nil when it isn't
+// found.
+func findCategory(tree []models.Category, slug string) *models.Category {
+	for i := range tree {
+		if tree[i].Slug == slug {
+			return &tree[i]
+		}
+		if category := findCategory(tree[i].Children, slug); category != nil {
+			return category
+		}
+	}
+	return nil
+}
+
+// SubtreeIDs returns the IDs of a category and of its subcategories at
+// every depth, by the slug of the category.
+func (s *CategoryService) SubtreeIDs(slug string) ([]uint, error) {
+	category, err := s.GetCategory(slug)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint
+	var walk func(category *models.Category)
+	walk = func(category *models.Category) {
+		ids = append(ids, category.ID)
+		for i := range category.Children {
+			walk(&category.Children[i])
+		}
+	}
+	walk(category)
+	return ids, nil
+}
+
+// CheckCategory returns a ValidationError when the category a post is filed
+// under doesn't exist.
+func (s *CategoryService) CheckCategory(id uint) error {
+	_, err := s.categoryRepo.FindByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return invalid("category doesn't exist")
+	}
+	return err
+}
+
+// CreateCategory validates and stores a new category, with the given
+// fields. The slug is generated from the name when unset.
+func (s *CategoryService) CreateCategory(fields CategoryUpdate) (*models.Category, error) {
+	category := &models.Category{}
+	fields.apply(category)
+	if err := s.validateCategory(category); err != nil {
+		return nil, err
+	}
+	if err := s.categoryRepo.Create(category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// UpdateCategory applies the given changes to a category and saves it. A
+// category can be moved under another one, but not under its own
+// subcategories.
+func (s *CategoryService) UpdateCategory(id uint, update CategoryUpdate) (*models.Category, error) {
+	category, err := s.categoryRepo.FindByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrCategoryNotFound)
+	}
+
+	update.apply(category)
+	if err := s.validateCategory(category); err != nil {
+		return nil, err
+	}
+	if err := s.categoryRepo.Update(category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// DeleteCategory removes a category. Its subcategories move to its parent,
+// and its posts are left without a category.
+func (s *CategoryService) DeleteCategory(id uint) error {
+	return notFound(s.categoryRepo.Delete(id), ErrCategoryNotFound)
+}
+
+// validateCategory validates the fields of a category, and checks that its
+// slug isn't the one of another category and that its parent exists
+// without being the category itself or one of its subcategories.
+func (s *CategoryService) validateCategory(category *models.Category) error {
+	category.Name = utils.SanitizeText(strings.TrimSpace(category.Name))
+	category.Description = utils.SanitizeText(category.Description)
+	if category.Slug == "" {
+		category.Slug = category.Name
+	}
+	category.Slug = repositories.Slugify(category.Slug)
+
+	if err := validateStruct(category); err != nil {
+		return err
+	}
+	if category.Slug == "" {
+		return invalid("slug must hold letters or digits")
+	}
+	if existing, err := s.categoryRepo.FindBySlug(category.Slug); err == nil && existing.ID != category.ID {
+		return ErrCategorySlugTaken
+	}
+
+	// Walk up from the parent, which must not lead back to the category
+	for parentID := category.ParentID; parentID != nil; {
+		if category.ID != 0 && *parentID == category.ID {
+			return invalid("a category can't be moved under itself or its subcategories")
+		}
+		parent, err := s.categoryRepo.FindByID(*parentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return invalid("parent category doesn't exist")
+		}
+		if err != nil {
+			return err
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
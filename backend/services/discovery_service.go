@@ -0,0 +1,305 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+)
+
+// relatedCandidates is the number of posts sharing tags, and of posts sharing
+// title words, considered for the related posts of a post
+const relatedCandidates = 50
+
+// textSimilarityWeight is the score of two posts using the same words, as
+// many as that of two shared tags
+const textSimilarityWeight = 2.0
+
+// Words shorter than minWordLength, and stop words, are left out of the text
+// similarity of posts
+const minWordLength = 4
+
+var stopWords = map[string]bool{
+	"about": true, "after": true, "also": true, "been": true, "before": true,
+	"from": true, "have": true, "into": true, "just": true, "more": true,
+	"most": true, "only": true, "other": true, "over": true, "some": true,
+	"than": true, "that": true, "their": true, "them": true, "then": true,
+	"there": true, "these": true, "they": true, "this": true, "what": true,
+	"when": true, "where": true, "which": true, "will": true, "with": true,
+	"your": true,
+}
+
+// cachedPosts is a ranking of posts, cached until it expires.
+type cachedPosts struct {
+	posts   []models.Post
+	expires time.Time
+}
+
+// DiscoveryService ranks the trending posts and the posts related to a post,
+// for the discovery sections of the frontend. Only published posts visible
+// to the public are ranked. Rankings are cached in memory for the
+// "discovery.cache_ttl" setting, so each instance computes its own.
+type DiscoveryService struct {
+	postRepo    *repositories.PostRepository
+	viewRepo    *repositories.PostViewRepository
+	commentRepo *repositories.CommentRepository
+	rankings    *rankings
+}
+
+// rankings are the cached rankings, shared by the copies of the service
+// scoped to requests.
+type rankings struct {
+	mu        sync.Mutex
+	trending  *cachedPosts
+	related   map[uint]*cachedPosts
+	lastSweep time.Time
+}
+
+// NewDiscoveryService returns a new instance of DiscoveryService with the
+// provided PostRepository, PostViewRepository and CommentRepository.
+func NewDiscoveryService(postRepo *repositories.PostRepository, viewRepo *repositories.PostViewRepository, commentRepo *repositories.CommentRepository) *DiscoveryService {
+	return &DiscoveryService{
+		postRepo:    postRepo,
+		viewRepo:    viewRepo,
+		commentRepo: commentRepo,
+		rankings:    &rankings{related: make(map[uint]*cachedPosts), lastSweep: time.Now()},
+	}
+}
+
+// Trending returns the posts ranked on their recent views, likes and
+// comments, each counting half as much every half life, best first.
+func (s *DiscoveryService) Trending() ([]models.Post, error) {
+	r := s.rankings
+	r.mu.Lock()
+	cached := r.trending
+	r.mu.Unlock()
+	if cached != nil && time.Now().Before(cached.expires) {
+		return copyPosts(cached.posts), nil
+	}
+
+	posts, err := s.computeTrending()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.trending = &cachedPosts{posts: posts, expires: time.Now().Add(config.Get().Discovery.CacheTTL)}
+	r.mu.Unlock()
+	return copyPosts(posts), nil
+}
+
+// Related returns the posts ranked on the tags they share with a post, and
+// on the words of their titles and excerpts when text similarity is on,
+// best first.
+func (s *DiscoveryService) Related(post *models.Post) ([]models.Post, error) {
+	now := time.Now()
+	r := s.rankings
+	r.mu.Lock()
+	cached, ok := r.related[post.ID]
+	r.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return copyPosts(cached.posts), nil
+	}
+
+	posts, err := s.computeRelated(post)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := config.Get().Discovery.CacheTTL
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Sub(r.lastSweep) >= ttl {
+		for id, cached := range r.related {
+			if now.After(cached.expires) {
+				delete(r.related, id)
+			}
+		}
+		r.lastSweep = now
+	}
+	r.related[post.ID] = &cachedPosts{posts: posts, expires: now.Add(ttl)}
+	return copyPosts(posts), nil
+}
+
+// computeTrending ranks the trending posts from the database.
+func (s *DiscoveryService) computeTrending() ([]models.Post, error) {
+	cfg := config.Get().Discovery.Trending
+	now := time.Now()
+	since := now.Add(-cfg.Window)
+
+	views, err := s.viewRepo.DailySince(since.UTC().Truncate(24 * time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.commentRepo.PublishedSince(since)
+	if err != nil {
+		return nil, err
+	}
+
+	// Views are dated by day, and count from the middle of it
+	scores := make(map[uint]float64)
+	for _, v := range views {
+		scores[v.PostID] += cfg.ViewWeight * float64(v.Views) * decay(now.Sub(v.Day.Add(12*time.Hour)), cfg.HalfLife)
+	}
+	for _, c := range comments {
+		scores[c.PostID] += cfg.CommentWeight * decay(now.Sub(c.CreatedAt), cfg.HalfLife)
+	}
+
+	ids := make([]uint, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	candidates, err := s.postRepo.PublicActivity(ids, since)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range candidates {
+		scores[post.ID] += cfg.LikeWeight * float64(post.LikeCount) * decay(now.Sub(post.PublishedAt), cfg.HalfLife)
+	}
+
+	return s.load(rankPosts(candidates, scores, cfg.Size))
+}
+
+// computeRelated ranks the posts related to a post from the database.
+func (s *DiscoveryService) computeRelated(post *models.Post) ([]models.Post, error) {
+	cfg := config.Get().Discovery.Related
+
+	shared, err := s.postRepo.SharingTags(post.ID, relatedCandidates)
+	if err != nil {
+		return nil, err
+	}
+	scores := make(map[uint]float64, len(shared))
+	for _, score := range shared {
+		scores[score.PostID] = float64(score.Score)
+	}
+
+	var words map[string]bool
+	if cfg.TextSimilarity {
+		words = significantWords(post.Title + " " + post.Excerpt)
+		titleWords := make([]string, 0, len(words))
+		for word := range significantWords(post.Title) {
+			titleWords = append(titleWords, word)
+		}
+		ids, err := s.postRepo.MatchingTitle(post.ID, titleWords, relatedCandidates)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if _, ok := scores[id]; !ok {
+				scores[id] = 0
+			}
+		}
+	}
+
+	ids := make([]uint, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	candidates, err := s.postRepo.FindPublic(ids)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TextSimilarity {
+		for _, candidate := range candidates {
+			scores[candidate.ID] += textSimilarityWeight * similarity(words, significantWords(candidate.Title+" "+candidate.Excerpt))
+		}
+	}
+
+	return rankPosts(candidates, scores, cfg.Size), nil
+}
+
+// load returns the ranked posts with their content, author and tags, in
+// the same order. Posts gone since they were ranked are left out.
+func (s *DiscoveryService) load(ranked []models.Post) ([]models.Post, error) {
+	ids := make([]uint, len(ranked))
+	for i, post := range ranked {
+		ids[i] = post.ID
+	}
+	loaded, err := s.postRepo.FindPublic(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Post, len(loaded))
+	for _, post := range loaded {
+		byID[post.ID] = post
+	}
+
+	posts := make([]models.Post, 0, len(ranked))
+	for _, post := range ranked {
+		if loaded, ok := byID[post.ID]; ok {
+			posts = append(posts, loaded)
+		}
+	}
+	return posts, nil
+}
+
+// rankPosts returns up to size of the posts with a score, best first, the
+// most recent first among equals.
+func rankPosts(candidates []models.Post, scores map[uint]float64, size int) []models.Post {
+	ranked := make([]models.Post, 0, len(candidates))
+	for _, post := range candidates {
+		if scores[post.ID] > 0 {
+			ranked = append(ranked, post)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if scores[ranked[i].ID] != scores[ranked[j].ID] {
+			return scores[ranked[i].ID] > scores[ranked[j].ID]
+		}
+		return ranked[i].PublishedAt.After(ranked[j].PublishedAt)
+	})
+	if len(ranked) > size {
+		ranked = ranked[:size]
+	}
+	return ranked
+}
+
+// decay returns the weight of activity of the given age, halved every half
+// life.
+func decay(age, halfLife time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, age.Hours()/halfLife.Hours())
+}
+
+// significantWords returns the lowercase words of a text, leaving out short
+// words and stop words.
+func significantWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) >= minWordLength && !stopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// similarity returns the share of the words of two texts used by both, from
+// 0 to 1.
+func similarity(a, b map[string]bool) float64 {
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	if total := len(a) + len(b) - shared; total > 0 {
+		return float64(shared) / float64(total)
+	}
+	return 0
+}
+
+// copyPosts returns a copy of cached posts, for callers to prepare without
+// changing the cache.
+func copyPosts(posts []models.Post) []models.Post {
+	return append(make([]models.Post, 0, len(posts)), posts...)
+}
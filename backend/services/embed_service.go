@@ -0,0 +1,291 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/types"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Types of embedded content
+const (
+	EmbedPost    = "post"
+	EmbedComment = "comment" // A comment with its replies
+)
+
+// embedIssuer marks the claims of embed tokens
+const embedIssuer = "coderage-embed"
+
+var (
+	// ErrInvalidEmbedToken is returned when an embed token is malformed,
+	// wrongly signed or expired.
+	ErrInvalidEmbedToken = errors.New("invalid or expired embed token")
+	// ErrEmbedOriginMismatch is returned when an embed token is used from
+	// another site than the one it was issued to.
+	ErrEmbedOriginMismatch = errors.New("embed token is not valid for this origin")
+)
+
+// EmbedToken is a signed token letting an external site read a single post
+// or comment thread.
+type EmbedToken struct {
+	Token     string    `json:"token"`
+	Type      string    `json:"type"`
+	ID        uint      `json:"id"`
+	Origin    string    `json:"origin,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Embed is the content an embed token gives access to. Post is set for both
+// types, Comment for comment threads.
+type Embed struct {
+	Token   *EmbedToken
+	Post    *models.Post
+	Comment *models.Comment
+}
+
+type EmbedService struct {
+	postRepo    *repositories.PostRepository
+	commentRepo *repositories.CommentRepository
+	userRepo    *repositories.UserRepository
+	logger      *zap.Logger
+}
+
+// NewEmbedService creates a new instance of EmbedService.
+func NewEmbedService(postRepo *repositories.PostRepository, commentRepo *repositories.CommentRepository, userRepo *repositories.UserRepository, logger *zap.Logger) *EmbedService {
+	return &EmbedService{
+		postRepo:    postRepo,
+		commentRepo: commentRepo,
+		userRepo:    userRepo,
+		logger:      logger,
+	}
+}
+
+// Issue issues a token embedding a published post, or a comment thread of
+// one, valid for ttl, or "embeds.default_ttl" when zero, up to
+// "embeds.max_ttl". Only the author of the post and admins can issue them.
+//
+// When origin is set, the token can only be used by pages of that site.
+func (s *EmbedService) Issue(issuerID uint, embedType string, id uint, origin string, ttl time.Duration) (*EmbedToken, error) {
+	cfg := config.Get().Embeds
+	if ttl == 0 {
+		ttl = cfg.DefaultTTL
+	}
+	if ttl < 0 || ttl > cfg.MaxTTL {
+		return nil, invalid(fmt.Sprintf("expires_in must be between 1 and %d hours", int(cfg.MaxTTL.Hours())))
+	}
+	if origin != "" {
+		normalized, err := normalizeOrigin(origin)
+		if err != nil {
+			return nil, err
+		}
+		origin = normalized
+	}
+
+	embed, err := s.load(embedType, id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.canEmbed(embed.Post, issuerID) {
+		return nil, ErrForbidden
+	}
+
+	token := &EmbedToken{
+		Type:      embedType,
+		ID:        id,
+		Origin:    origin,
+		ExpiresAt: time.Now().Add(ttl).Truncate(time.Second),
+	}
+	claims := jwt.MapClaims{
+		"iss":    embedIssuer,
+		"jti":    uuid.New().String(),
+		"sub":    fmt.Sprintf("%s:%d", embedType, id),
+		"type":   embedType,
+		"id":     id,
+		"issuer": issuerID,
+		"iat":    time.Now().Unix(),
+		"exp":    token.ExpiresAt.Unix(),
+	}
+	if origin != "" {
+		claims["origin"] = origin
+	}
+	token.Token, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(embedKey())
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Embed token issued",
+		zap.Uint("issuer_id", issuerID),
+		zap.String("type", embedType),
+		zap.Uint("id", id),
+		zap.String("origin", origin),
+		zap.Time("expires_at", token.ExpiresAt),
+	)
+	return token, nil
+}
+
+// Resolve verifies an embed token and returns the content it gives access
+// to. When the token is bound to a site, origin must be that site, or empty
+// for requests which don't send one, such as those of servers.
+//
+// Content that was unpublished, hidden or deleted since the token was issued
+// is reported as not found.
+func (s *EmbedService) Resolve(tokenString, origin string) (*Embed, error) {
+	token, err := ParseEmbedToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if token.Origin != "" && origin != "" && !strings.EqualFold(origin, token.Origin) {
+		return nil, ErrEmbedOriginMismatch
+	}
+
+	embed, err := s.load(token.Type, token.ID)
+	if err != nil {
+		return nil, err
+	}
+	embed.Token = token
+	return embed, nil
+}
+
+// PublicPost returns a post embeddable from its public URL, by its slug.
+// Like the content of embed tokens, it must be published and outside of its
+// members-only window.
+func (s *EmbedService) PublicPost(slug string) (*models.Post, error) {
+	post, err := s.postRepo.FindBySlug(slug)
+	if err != nil {
+		return nil, notFound(err, ErrPostNotFound)
+	}
+	if !embeddable(post) {
+		return nil, ErrPostNotFound
+	}
+	return post, nil
+}
+
+// ParseEmbedToken verifies an embed token and returns its details, without
+// loading the content it gives access to.
+func ParseEmbedToken(tokenString string) (*EmbedToken, error) {
+	parsed, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid token signing method")
+		}
+		return embedKey(), nil
+	})
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidEmbedToken
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || claims["iss"] != embedIssuer {
+		return nil, ErrInvalidEmbedToken
+	}
+	embedType, _ := claims["type"].(string)
+	id, _ := claims["id"].(float64)
+	exp, _ := claims["exp"].(float64)
+	origin, _ := claims["origin"].(string)
+	if (embedType != EmbedPost && embedType != EmbedComment) || id <= 0 || exp == 0 {
+		return nil, ErrInvalidEmbedToken
+	}
+
+	return &EmbedToken{
+		Token:     tokenString,
+		Type:      embedType,
+		ID:        uint(id),
+		Origin:    origin,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
+
+// load returns the embeddable content of the given type and ID. Only
+// published posts, outside of their members-only window, and visible
+// comments can be embedded.
+func (s *EmbedService) load(embedType string, id uint) (*Embed, error) {
+	embed := &Embed{}
+	postID := id
+	switch embedType {
+	case EmbedPost:
+	case EmbedComment:
+		comment, err := s.commentRepo.FindByID(id)
+		if err != nil {
+			return nil, notFound(err, ErrCommentNotFound)
+		}
+		if !comment.Visible() {
+			return nil, ErrCommentNotFound
+		}
+		if user, err := s.userRepo.FindByIDLite(comment.UserID); err == nil {
+			comment.User = *user
+		}
+		embed.Comment = comment
+		postID = comment.PostID
+	default:
+		return nil, invalid("type must be post or comment")
+	}
+
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, notFound(err, ErrPostNotFound)
+	}
+	if !embeddable(post) {
+		return nil, ErrPostNotFound
+	}
+	embed.Post = post
+
+	if embed.Comment != nil {
+		replies, err := s.commentRepo.FindPostReplies(post.ID, false)
+		if err != nil {
+			return nil, err
+		}
+		children := make(map[uint][]models.Comment)
+		for _, reply := range replies {
+			children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+		}
+		nestReplies(embed.Comment, children)
+	}
+	return embed, nil
+}
+
+// embeddable reports whether a post can be embedded.
+func embeddable(post *models.Post) bool {
+	return post.Status == "published" && !post.InEarlyAccess()
+}
+
+// canEmbed reports whether a user can issue embed tokens for the content of
+// a post.
+func (s *EmbedService) canEmbed(post *models.Post, userID uint) bool {
+	if post.UserID == userID {
+		return true
+	}
+	role, err := s.userRepo.FindRole(userID)
+	return err == nil && role == types.RoleAdmin
+}
+
+// embedKey returns the key signing embed tokens, derived from the JWT secret
+// so embed tokens can never pass for access tokens.
+func embedKey() []byte {
+	mac := hmac.New(sha256.New, []byte(config.Get().JWT.Secret))
+	mac.Write([]byte(embedIssuer))
+	return mac.Sum(nil)
+}
+
+// normalizeOrigin returns the origin of a site, e.g. "https://example.com",
+// in the form browsers send in the Origin header.
+func normalizeOrigin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", invalid("origin must be the http or https origin of a site, e.g. https://example.com")
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return "", invalid("origin must not have a path, query or credentials")
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/SteaceP/coderage/i18n"
+	"github.com/SteaceP/coderage/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPostNotFound is returned when a post doesn't exist.
+	ErrPostNotFound = errors.New("post not found")
+	// ErrCommentNotFound is returned when a comment doesn't exist.
+	ErrCommentNotFound = errors.New("comment not found")
+	// ErrUserNotFound is returned when a user doesn't exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrForbidden is returned when a user isn't allowed to perform an action.
+	ErrForbidden = errors.New("forbidden")
+	// ErrInvalidCredentials is returned when logging in with a wrong email or password.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrPendingApproval is returned when logging in to an account awaiting approval.
+	ErrPendingApproval = errors.New("account is awaiting approval")
+	// ErrAccountInactive is returned when logging in to a deactivated account.
+	ErrAccountInactive = errors.New("account is deactivated")
+	// ErrUsernameTaken is returned when registering with a username in use.
+	ErrUsernameTaken = errors.New("username already exists")
+	// ErrEmailTaken is returned when registering with an email address in use.
+	ErrEmailTaken = errors.New("email already exists")
+)
+
+// ValidationError is returned when the input of a service call is invalid.
+type ValidationError struct {
+	Message string
+	Args    []interface{} // Arguments the message is formatted with, in the fmt syntax
+}
+
+func (e *ValidationError) Error() string {
+	return i18n.M(e.Message, e.Args...).String()
+}
+
+// invalid returns a ValidationError with the given message, formatted with
+// the given arguments if any. Messages with arguments are translated before
+// they are formatted.
+func invalid(message string, args ...interface{}) error {
+	return &ValidationError{Message: message, Args: args}
+}
+
+// validateStruct validates a struct against its validate tags, and returns
+// a ValidationError describing its first invalid field.
+func validateStruct(s interface{}) error {
+	if errs := utils.ValidateStruct(s); len(errs) > 0 {
+		return invalid(errs[0].Key, errs[0].Args...)
+	}
+	return nil
+}
+
+// notFound translates a missing record error to the given error, and returns
+// other errors unchanged.
+func notFound(err, target error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return target
+	}
+	return err
+}
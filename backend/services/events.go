@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/models"
+
+	"go.uber.org/zap"
+)
+
+// publish publishes an event on the bus. Failures are logged only, as they
+// must not fail the action the event reports.
+func publish(ctx context.Context, bus events.Bus, logger *zap.Logger, eventType string, payload interface{}) {
+	event, err := events.NewEvent(eventType, payload)
+	if err == nil {
+		err = bus.Publish(ctx, event)
+	}
+	if err != nil {
+		logger.Error("Failed to publish event", zap.String("event_type", eventType), zap.Error(err))
+	}
+}
+
+// postPayload returns the payload of the events of a post.
+func postPayload(post *models.Post) events.PostPayload {
+	return events.PostPayload{
+		PostID:      post.ID,
+		UserID:      post.UserID,
+		Title:       post.Title,
+		Slug:        post.Slug,
+		Status:      post.Status,
+		PublishedAt: post.PublishedAt,
+	}
+}
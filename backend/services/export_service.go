@@ -0,0 +1,504 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// TaskExport is the name of the runs of the exports.
+const TaskExport = "export"
+
+// Formats of the exports
+const (
+	ExportJSON     = "json"
+	ExportMarkdown = "markdown"
+)
+
+// exportBatchSize is the number of records loaded at once while exporting
+const exportBatchSize = 200
+
+var (
+	// ErrExportNotFound is returned when an export doesn't exist.
+	ErrExportNotFound = errors.New("export not found")
+	// ErrExportUnavailable is returned when downloading an export that
+	// isn't finished, failed, or was replaced by a newer one.
+	ErrExportUnavailable = errors.New("export file is not available")
+)
+
+// ExportService exports the posts, comments and users as a zip archive, for
+// backups or to move to another platform.
+//
+// JSON archives hold a single export.json file. Markdown archives hold a
+// Markdown file with frontmatter per post, which POST /admin/import reads
+// back, along with users.json and comments.json.
+type ExportService struct {
+	postRepo    *repositories.PostRepository
+	commentRepo *repositories.CommentRepository
+	userRepo    *repositories.UserRepository
+	tasks       *TaskService
+	logger      *zap.Logger
+
+	mu     sync.Mutex
+	latest *exportFile // Archive of the latest background export
+}
+
+// exportFile is the archive written by a background export.
+type exportFile struct {
+	runID string
+	path  string
+}
+
+// exportCounts counts the records of an export.
+type exportCounts struct {
+	users, posts, comments int
+}
+
+// ExportedUser is a user in an export, without credentials.
+type ExportedUser struct {
+	ID         uint       `json:"id"`
+	Username   string     `json:"username"`
+	Email      string     `json:"email"`
+	FirstName  string     `json:"first_name,omitempty"`
+	LastName   string     `json:"last_name,omitempty"`
+	Bio        string     `json:"bio,omitempty"`
+	Role       string     `json:"role"`
+	IsActive   bool       `json:"is_active"`
+	Member     bool       `json:"member"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ExportedPost is a post in a JSON export.
+type ExportedPost struct {
+	ID              uint      `json:"id"`
+	Title           string    `json:"title"`
+	Slug            string    `json:"slug"`
+	Content         string    `json:"content"`
+	Excerpt         string    `json:"excerpt,omitempty"`
+	Status          string    `json:"status"`
+	AuthorID        uint      `json:"author_id"`
+	Tags            []string  `json:"tags"`
+	FeaturedImage   string    `json:"featured_image,omitempty"`
+	MetaTitle       string    `json:"meta_title,omitempty"`
+	MetaDescription string    `json:"meta_description,omitempty"`
+	Sensitive       bool      `json:"sensitive"`
+	License         string    `json:"license,omitempty"`
+	PublishedAt     time.Time `json:"published_at"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// ExportedComment is a comment in an export.
+type ExportedComment struct {
+	ID        uint      `json:"id"`
+	PostID    uint      `json:"post_id"`
+	UserID    uint      `json:"user_id"`
+	ParentID  *uint     `json:"parent_id,omitempty"`
+	Content   string    `json:"content"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// exportFrontmatter is the frontmatter of the posts of Markdown exports,
+// with the names importer reads.
+type exportFrontmatter struct {
+	Title           string   `yaml:"title"`
+	Slug            string   `yaml:"slug"`
+	Date            string   `yaml:"date"`
+	Status          string   `yaml:"status"`
+	Tags            []string `yaml:"tags,omitempty"`
+	Author          string   `yaml:"author,omitempty"`
+	AuthorEmail     string   `yaml:"author_email,omitempty"`
+	Excerpt         string   `yaml:"excerpt,omitempty"`
+	FeaturedImage   string   `yaml:"featured_image,omitempty"`
+	MetaTitle       string   `yaml:"meta_title,omitempty"`
+	MetaDescription string   `yaml:"meta_description,omitempty"`
+}
+
+// NewExportService returns a new instance of ExportService with the
+// provided repositories, running the background exports through the
+// provided TaskService.
+func NewExportService(postRepo *repositories.PostRepository, commentRepo *repositories.CommentRepository, userRepo *repositories.UserRepository, tasks *TaskService, logger *zap.Logger) *ExportService {
+	return &ExportService{
+		postRepo:    postRepo,
+		commentRepo: commentRepo,
+		userRepo:    userRepo,
+		tasks:       tasks,
+		logger:      logger,
+	}
+}
+
+// Write writes the zip archive of an export in the given format to w, as
+// the records are read, until the context is canceled.
+func (s *ExportService) Write(ctx context.Context, w io.Writer, format string) error {
+	if err := validateExportFormat(format); err != nil {
+		return err
+	}
+	_, err := s.write(ctx, w, format)
+	if err != nil && ctx.Err() == nil {
+		s.logger.Error("Export failed", zap.String("format", format), zap.Error(err))
+	}
+	return err
+}
+
+// Start writes an export in the background on behalf of an admin, for the
+// blogs too large to be exported within a request, and returns its run.
+// Once it succeeded, the archive is read through Open. Only the archive of
+// the latest export is kept, and a single export runs at a time.
+func (s *ExportService) Start(format string, userID uint) (*TaskRun, error) {
+	if err := validateExportFormat(format); err != nil {
+		return nil, err
+	}
+
+	// The job learns the ID of its run once it started
+	runID := make(chan string, 1)
+	run, err := s.tasks.Run(TaskExport, userID, func(ctx context.Context) (string, error) {
+		id := <-runID
+		file, err := os.CreateTemp("", "export-*.zip")
+		if err != nil {
+			return "", err
+		}
+		counts, err := s.write(ctx, file, format)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(file.Name())
+			return "", err
+		}
+		s.replace(&exportFile{runID: id, path: file.Name()})
+		return fmt.Sprintf("Exported %d users, %d posts and %d comments as %s", counts.users, counts.posts, counts.comments, format), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	runID <- run.ID
+	return run, nil
+}
+
+// GetRun returns the run of an export by its ID.
+func (s *ExportService) GetRun(id string) (*TaskRun, error) {
+	run, err := s.tasks.GetRun(id)
+	if err != nil || run.Task != TaskExport {
+		return nil, ErrExportNotFound
+	}
+	return run, nil
+}
+
+// Open opens the archive of a background export. The caller closes it.
+func (s *ExportService) Open(id string) (*os.File, error) {
+	if _, err := s.GetRun(id); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest == nil || s.latest.runID != id {
+		return nil, ErrExportUnavailable
+	}
+	return os.Open(s.latest.path)
+}
+
+// replace makes file the archive of the latest export, removing the
+// previous one.
+func (s *ExportService) replace(file *exportFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.latest != nil {
+		if err := os.Remove(s.latest.path); err != nil {
+			s.logger.Warn("Failed to remove the previous export", zap.String("path", s.latest.path), zap.Error(err))
+		}
+	}
+	s.latest = file
+}
+
+// validateExportFormat checks the format of an export.
+func validateExportFormat(format string) error {
+	if format != ExportJSON && format != ExportMarkdown {
+		return invalid("format must be json or markdown")
+	}
+	return nil
+}
+
+// write writes the zip archive of an export to w, and returns its counts.
+func (s *ExportService) write(ctx context.Context, w io.Writer, format string) (exportCounts, error) {
+	var counts exportCounts
+	archive := zip.NewWriter(w)
+
+	var err error
+	if format == ExportJSON {
+		err = s.writeJSON(ctx, archive, &counts)
+	} else {
+		err = s.writeMarkdown(ctx, archive, &counts)
+	}
+	if err != nil {
+		return counts, err
+	}
+	return counts, archive.Close()
+}
+
+// writeJSON writes the export.json file of JSON exports.
+func (s *ExportService) writeJSON(ctx context.Context, archive *zip.Writer, counts *exportCounts) error {
+	f, err := createZipFile(archive, "export.json")
+	if err != nil {
+		return err
+	}
+	exportedAt, _ := json.Marshal(time.Now().UTC())
+	if _, err := fmt.Fprintf(f, "{\"exported_at\":%s,\n\"users\":", exportedAt); err != nil {
+		return err
+	}
+	if err := s.writeUsers(ctx, f, counts); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, ",\n\"posts\":"); err != nil {
+		return err
+	}
+
+	list := newJSONList(f)
+	err = s.postRepo.Batches(exportBatchSize, func(posts []models.Post) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, post := range posts {
+			if err := list.add(exportedPost(&post)); err != nil {
+				return err
+			}
+			counts.posts++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := list.close(); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(f, ",\n\"comments\":"); err != nil {
+		return err
+	}
+	if err := s.writeComments(ctx, f, counts); err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, "}\n")
+	return err
+}
+
+// writeMarkdown writes the files of Markdown exports.
+func (s *ExportService) writeMarkdown(ctx context.Context, archive *zip.Writer, counts *exportCounts) error {
+	err := s.postRepo.Batches(exportBatchSize, func(posts []models.Post) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, post := range posts {
+			if err := writeMarkdownPost(archive, &post); err != nil {
+				return err
+			}
+			counts.posts++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := createZipFile(archive, "users.json")
+	if err != nil {
+		return err
+	}
+	if err := s.writeUsers(ctx, f, counts); err != nil {
+		return err
+	}
+	f, err = createZipFile(archive, "comments.json")
+	if err != nil {
+		return err
+	}
+	return s.writeComments(ctx, f, counts)
+}
+
+// writeMarkdownPost writes the Markdown file of a post, named after its
+// slug.
+func writeMarkdownPost(archive *zip.Writer, post *models.Post) error {
+	meta := exportFrontmatter{
+		Title:           post.Title,
+		Slug:            post.Slug,
+		Date:            post.PublishedAt.UTC().Format(time.RFC3339),
+		Status:          post.Status,
+		Author:          post.User.Username,
+		AuthorEmail:     post.User.Email,
+		Excerpt:         post.Excerpt,
+		FeaturedImage:   post.FeaturedImage,
+		MetaTitle:       post.MetaTitle,
+		MetaDescription: post.MetaDescription,
+	}
+	for _, tag := range post.Tags {
+		meta.Tags = append(meta.Tags, tag.Name)
+	}
+	header, err := yaml.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	name := post.Slug
+	if name == "" {
+		name = "post-" + strconv.FormatUint(uint64(post.ID), 10)
+	}
+	f, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     "posts/" + name + ".md",
+		Method:   zip.Deflate,
+		Modified: post.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "---\n%s---\n\n%s\n", header, post.Content)
+	return err
+}
+
+// writeUsers writes the JSON list of the users.
+func (s *ExportService) writeUsers(ctx context.Context, w io.Writer, counts *exportCounts) error {
+	list := newJSONList(w)
+	err := s.userRepo.Batches(exportBatchSize, func(users []models.User) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, user := range users {
+			err := list.add(ExportedUser{
+				ID:         user.ID,
+				Username:   user.Username,
+				Email:      user.Email,
+				FirstName:  user.FirstName,
+				LastName:   user.LastName,
+				Bio:        user.Bio,
+				Role:       user.Role,
+				IsActive:   user.IsActive,
+				Member:     user.Member,
+				VerifiedAt: user.VerifiedAt,
+				CreatedAt:  user.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			counts.users++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return list.close()
+}
+
+// writeComments writes the JSON list of the comments.
+func (s *ExportService) writeComments(ctx context.Context, w io.Writer, counts *exportCounts) error {
+	list := newJSONList(w)
+	err := s.commentRepo.Batches(exportBatchSize, func(comments []models.Comment) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, comment := range comments {
+			err := list.add(ExportedComment{
+				ID:        comment.ID,
+				PostID:    comment.PostID,
+				UserID:    comment.UserID,
+				ParentID:  comment.ParentID,
+				Content:   comment.Content,
+				Status:    comment.Status,
+				CreatedAt: comment.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			counts.comments++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return list.close()
+}
+
+// createZipFile adds a compressed file to an archive, modified now.
+func createZipFile(archive *zip.Writer, name string) (io.Writer, error) {
+	return archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+}
+
+// exportedPost returns the exported fields of a post.
+func exportedPost(post *models.Post) ExportedPost {
+	exported := ExportedPost{
+		ID:              post.ID,
+		Title:           post.Title,
+		Slug:            post.Slug,
+		Content:         post.Content,
+		Excerpt:         post.Excerpt,
+		Status:          post.Status,
+		AuthorID:        post.UserID,
+		Tags:            make([]string, len(post.Tags)),
+		FeaturedImage:   post.FeaturedImage,
+		MetaTitle:       post.MetaTitle,
+		MetaDescription: post.MetaDescription,
+		Sensitive:       post.Sensitive,
+		License:         post.License,
+		PublishedAt:     post.PublishedAt,
+		CreatedAt:       post.CreatedAt,
+		UpdatedAt:       post.UpdatedAt,
+	}
+	for i, tag := range post.Tags {
+		exported.Tags[i] = tag.Name
+	}
+	return exported
+}
+
+// jsonList writes a JSON list one element at a time, so exports don't hold
+// every record in memory.
+type jsonList struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONList(w io.Writer) *jsonList {
+	return &jsonList{w: w}
+}
+
+// add writes an element of the list.
+func (l *jsonList) add(v interface{}) error {
+	separator := ",\n"
+	if l.count == 0 {
+		separator = "[\n"
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(l.w, separator); err != nil {
+		return err
+	}
+	l.count++
+	_, err = l.w.Write(encoded)
+	return err
+}
+
+// close ends the list.
+func (l *jsonList) close() error {
+	end := "\n]"
+	if l.count == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(l.w, end)
+	return err
+}
@@ -0,0 +1,405 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+
+	"gorm.io/gorm"
+)
+
+// errUnsupported is returned by the fake stores for the queries no test
+// needs yet.
+var errUnsupported = errors.New("not supported by the fake store")
+
+// fakePostStore is a PostStore keeping the posts in memory. Filters are
+// ignored.
+type fakePostStore struct {
+	mu     sync.Mutex
+	posts  map[uint]*models.Post
+	nextID uint
+}
+
+func newFakePostStore() *fakePostStore {
+	return &fakePostStore{posts: make(map[uint]*models.Post)}
+}
+
+func (f *fakePostStore) Create(post *models.Post) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	post.ID = f.nextID
+	if post.Slug == "" {
+		post.Slug = strings.ReplaceAll(strings.ToLower(post.Title), " ", "-")
+	}
+	stored := *post
+	f.posts[post.ID] = &stored
+	return nil
+}
+
+func (f *fakePostStore) FindByID(id uint) (*models.Post, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	post, ok := f.posts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *post
+	return &found, nil
+}
+
+func (f *fakePostStore) FindBySlug(slug string) (*models.Post, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, post := range f.posts {
+		if post.Slug == slug {
+			found := *post
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// all returns the posts stored, in the order they were created.
+func (f *fakePostStore) all() []models.Post {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	posts := make([]models.Post, 0, len(f.posts))
+	for _, post := range f.posts {
+		posts = append(posts, *post)
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+	return posts
+}
+
+func (f *fakePostStore) List(page, pageSize int, filters map[string]interface{}) ([]models.Post, int64, error) {
+	posts := f.all()
+	start := min((page-1)*pageSize, len(posts))
+	end := min(start+pageSize, len(posts))
+	return posts[start:end], int64(len(posts)), nil
+}
+
+func (f *fakePostStore) EstimateCount(filters map[string]interface{}) (int64, error) {
+	return int64(len(f.all())), nil
+}
+
+func (f *fakePostStore) MatchingIDs(filters map[string]interface{}) ([]uint, error) {
+	var ids []uint
+	for _, post := range f.all() {
+		ids = append(ids, post.ID)
+	}
+	return ids, nil
+}
+
+func (f *fakePostStore) ListPublished() ([]models.Post, error) {
+	var posts []models.Post
+	for _, post := range f.all() {
+		if post.Status == "published" {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+func (f *fakePostStore) FindPublishedByUserIDs(userIDs []uint, publicOnly bool) ([]models.Post, error) {
+	users := make(map[uint]bool, len(userIDs))
+	for _, id := range userIDs {
+		users[id] = true
+	}
+	var posts []models.Post
+	for _, post := range f.all() {
+		if post.Status == "published" && users[post.UserID] && !(publicOnly && post.InEarlyAccess()) {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+func (f *fakePostStore) FindPublishedSlugs(slugs []string) ([]string, error) {
+	var found []string
+	for _, post := range f.all() {
+		for _, slug := range slugs {
+			if post.Status == "published" && post.Slug == slug {
+				found = append(found, slug)
+			}
+		}
+	}
+	return found, nil
+}
+
+func (f *fakePostStore) PublishedDates(publicOnly bool) ([]time.Time, error) {
+	var dates []time.Time
+	for _, post := range f.all() {
+		if post.Status == "published" && !(publicOnly && post.InEarlyAccess()) {
+			dates = append(dates, post.PublishedAt)
+		}
+	}
+	return dates, nil
+}
+
+func (f *fakePostStore) Update(post *models.Post) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.posts[post.ID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	stored := *post
+	f.posts[post.ID] = &stored
+	return nil
+}
+
+func (f *fakePostStore) UpdateCommentCount(postID uint, increment bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	post, ok := f.posts[postID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if increment {
+		post.CommentCount++
+	} else if post.CommentCount > 0 {
+		post.CommentCount--
+	}
+	return nil
+}
+
+func (f *fakePostStore) Delete(id uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.posts, id)
+	return nil
+}
+
+func (f *fakePostStore) FindByIDs(ids []uint) ([]models.Post, error) {
+	var posts []models.Post
+	for _, id := range ids {
+		if post, err := f.FindByID(id); err == nil {
+			posts = append(posts, *post)
+		}
+	}
+	return posts, nil
+}
+
+func (f *fakePostStore) Bulk(ids []uint, change repositories.BulkChange) error {
+	return errUnsupported
+}
+
+func (f *fakePostStore) SaveAuthor(author *models.PostAuthor) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	post, ok := f.posts[author.PostID]
+	if !ok {
+		return false, gorm.ErrRecordNotFound
+	}
+	for i := range post.Authors {
+		if post.Authors[i].UserID == author.UserID {
+			post.Authors[i].Role = author.Role
+			return false, nil
+		}
+	}
+	post.Authors = append(post.Authors, *author)
+	return true, nil
+}
+
+func (f *fakePostStore) DeleteAuthor(postID, userID uint) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	post, ok := f.posts[postID]
+	if !ok {
+		return false, gorm.ErrRecordNotFound
+	}
+	for i := range post.Authors {
+		if post.Authors[i].UserID == userID {
+			post.Authors = append(post.Authors[:i], post.Authors[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeCommentStore is a CommentStore keeping the comments, and their likes,
+// in memory.
+type fakeCommentStore struct {
+	mu       sync.Mutex
+	comments map[uint]*models.Comment
+	likes    map[[2]uint]bool // By comment and user ID
+	nextID   uint
+}
+
+func newFakeCommentStore() *fakeCommentStore {
+	return &fakeCommentStore{comments: make(map[uint]*models.Comment), likes: make(map[[2]uint]bool)}
+}
+
+func (f *fakeCommentStore) Create(comment *models.Comment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	comment.ID = f.nextID
+	stored := *comment
+	f.comments[comment.ID] = &stored
+	return nil
+}
+
+func (f *fakeCommentStore) FindByID(id uint) (*models.Comment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	comment, ok := f.comments[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *comment
+	return &found, nil
+}
+
+// matching returns the comments for which keep is true, in the order they
+// were created.
+func (f *fakeCommentStore) matching(keep func(*models.Comment) bool) []models.Comment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var comments []models.Comment
+	for _, comment := range f.comments {
+		if keep(comment) {
+			comments = append(comments, *comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+	return comments
+}
+
+// page returns a page of comments and their total count.
+func page(comments []models.Comment, page, pageSize int) ([]models.Comment, int64, error) {
+	start := min((page-1)*pageSize, len(comments))
+	end := min(start+pageSize, len(comments))
+	return comments[start:end], int64(len(comments)), nil
+}
+
+func (f *fakeCommentStore) FindByPostID(postID uint, includeHidden bool, p, pageSize int) ([]models.Comment, int64, error) {
+	return page(f.matching(func(c *models.Comment) bool {
+		return c.PostID == postID && (includeHidden || c.Visible())
+	}), p, pageSize)
+}
+
+func (f *fakeCommentStore) FindByPostIDs(postIDs []uint, includeHidden bool) ([]models.Comment, error) {
+	posts := make(map[uint]bool, len(postIDs))
+	for _, id := range postIDs {
+		posts[id] = true
+	}
+	return f.matching(func(c *models.Comment) bool {
+		return posts[c.PostID] && (includeHidden || c.Visible())
+	}), nil
+}
+
+func (f *fakeCommentStore) FindThreads(postID uint, includeHidden bool, p, pageSize int) ([]models.Comment, int64, error) {
+	return page(f.matching(func(c *models.Comment) bool {
+		return c.PostID == postID && c.ParentID == nil && (includeHidden || c.Visible())
+	}), p, pageSize)
+}
+
+func (f *fakeCommentStore) FindPostReplies(postID uint, includeHidden bool) ([]models.Comment, error) {
+	return f.matching(func(c *models.Comment) bool {
+		return c.PostID == postID && c.ParentID != nil && (includeHidden || c.Visible())
+	}), nil
+}
+
+func (f *fakeCommentStore) FindReplies(commentID uint, includeHidden bool) ([]models.Comment, error) {
+	return f.matching(func(c *models.Comment) bool {
+		return c.ParentID != nil && *c.ParentID == commentID && (includeHidden || c.Visible())
+	}), nil
+}
+
+func (f *fakeCommentStore) FindFlagged(p, pageSize int) ([]models.Comment, int64, error) {
+	return page(f.matching(func(c *models.Comment) bool {
+		return c.Status == models.CommentHidden
+	}), p, pageSize)
+}
+
+func (f *fakeCommentStore) UpdateStatus(commentID uint, status string, moderatorID *uint, reason string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	comment, ok := f.comments[commentID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	comment.Status = status
+	return nil
+}
+
+func (f *fakeCommentStore) Report(report *models.CommentReport) (int, error) {
+	return 0, errUnsupported
+}
+
+func (f *fakeCommentStore) Like(commentID, userID uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := [2]uint{commentID, userID}
+	if f.likes[key] {
+		return repositories.ErrAlreadyLiked
+	}
+	f.likes[key] = true
+	if comment, ok := f.comments[commentID]; ok {
+		comment.LikeCount++
+	}
+	return nil
+}
+
+func (f *fakeCommentStore) Unlike(commentID, userID uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := [2]uint{commentID, userID}
+	if !f.likes[key] {
+		return repositories.ErrNotLiked
+	}
+	delete(f.likes, key)
+	if comment, ok := f.comments[commentID]; ok {
+		comment.LikeCount--
+	}
+	return nil
+}
+
+func (f *fakeCommentStore) LikedCommentIDs(userID uint, commentIDs []uint) (map[uint]bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	liked := make(map[uint]bool)
+	for _, id := range commentIDs {
+		if f.likes[[2]uint{id, userID}] {
+			liked[id] = true
+		}
+	}
+	return liked, nil
+}
+
+// fakeUserStore is a UserStore looking up the users it was given.
+type fakeUserStore map[uint]*models.User
+
+func (f fakeUserStore) FindByIDLite(id uint) (*models.User, error) {
+	user, ok := f[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+func (f fakeUserStore) FindRole(userID uint) (string, error) {
+	user, err := f.FindByIDLite(userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Role, nil
+}
+
+func (f fakeUserStore) FindAccess(userID uint) (*models.User, error) {
+	return f.FindByIDLite(userID)
+}
+
+// The fakes implement the stores
+var (
+	_ PostStore    = (*fakePostStore)(nil)
+	_ CommentStore = (*fakeCommentStore)(nil)
+	_ UserStore    = fakeUserStore(nil)
+)
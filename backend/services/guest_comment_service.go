@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SteaceP/coderage/captcha"
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/ratelimit"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/utils"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrGuestCommentsDisabled is returned when a guest comments while
+	// "comments.guests.enabled" is off.
+	ErrGuestCommentsDisabled = errors.New("guest comments are disabled")
+	// ErrGuestEmailTaken is returned when a guest comments with the email
+	// address of a registered account, whose owner must log in instead.
+	ErrGuestEmailTaken = errors.New("an account uses this email address, log in to comment")
+	// ErrTooManyComments is returned when an IP address sent more guest
+	// comments than "comments.guests.requests" within the window.
+	ErrTooManyComments = errors.New("too many comments, try again later")
+	// ErrCaptchaUnavailable is returned when the CAPTCHA service can't
+	// verify a challenge.
+	ErrCaptchaUnavailable = errors.New("CAPTCHA verification is unavailable")
+)
+
+// Guest identifies the reader without an account sending a comment.
+type Guest struct {
+	Name         string
+	Email        string
+	IP           string
+	UserAgent    string
+	Referrer     string
+	Honeypot     string // Field hidden from humans, which bots fill in
+	CaptchaToken string // Token of the CAPTCHA challenge solved
+}
+
+// GuestCommentService accepts the comments of readers without an account,
+// once they pass the spam protections of "comments.guests".
+type GuestCommentService struct {
+	userRepo *repositories.UserRepository
+	posts    *PostService
+	limiter  *guestLimiter
+	logger   *zap.Logger
+}
+
+// guestLimiter limits the guest comments per IP address, following the
+// changes of the configuration.
+type guestLimiter struct {
+	mu       sync.Mutex
+	limiter  *ratelimit.Limiter
+	requests int
+	window   time.Duration
+}
+
+// allow records a guest comment from an IP address, and reports whether it
+// is within the limit.
+func (l *guestLimiter) allow(ip string, requests int, window time.Duration) bool {
+	l.mu.Lock()
+	if l.limiter == nil || l.requests != requests || l.window != window {
+		l.limiter = ratelimit.NewLimiter(requests, window)
+		l.requests, l.window = requests, window
+	}
+	limiter := l.limiter
+	l.mu.Unlock()
+	return limiter.Allow("ip:" + ip).Allowed
+}
+
+// NewGuestCommentService returns a new instance of GuestCommentService with
+// the provided UserRepository, adding the comments through the provided
+// PostService.
+func NewGuestCommentService(userRepo *repositories.UserRepository, posts *PostService, logger *zap.Logger) *GuestCommentService {
+	return &GuestCommentService{
+		userRepo: userRepo,
+		posts:    posts,
+		limiter:  &guestLimiter{},
+		logger:   logger,
+	}
+}
+
+// AddComment adds the comment of a guest. The comment is rejected when the
+// honeypot is filled in, the IP address of the guest sent too many comments,
+// or the CAPTCHA challenge isn't solved when a provider is set.
+//
+// Guests are given a guest account without a password, which they can claim
+// by resetting its password, and reuse it as long as they don't. The email
+// address of any other account is refused, including the accounts created
+// without a password for imports and integrations. Their comments are hidden until
+// a moderator approves them when "comments.guests.moderated" is set.
+func (s *GuestCommentService) AddComment(ctx context.Context, comment *models.Comment, guest Guest) error {
+	cfg := config.Get().Comments.Guests
+	if !cfg.Enabled {
+		return ErrGuestCommentsDisabled
+	}
+
+	guest.Name = utils.SanitizeText(strings.TrimSpace(guest.Name))
+	guest.Email = strings.TrimSpace(guest.Email)
+	if guest.Name == "" {
+		return invalid("name is required")
+	}
+	if len(guest.Name) > 100 {
+		return invalid("name must be at most 100 characters")
+	}
+	if guest.Honeypot != "" {
+		s.logger.Info("Guest comment rejected by the honeypot", zap.String("ip", guest.IP))
+		return invalid("comment rejected as spam")
+	}
+	if !s.limiter.allow(guest.IP, cfg.Requests, cfg.Window) {
+		return ErrTooManyComments
+	}
+
+	verifier, err := captcha.New(cfg.Captcha)
+	if err != nil {
+		return err
+	}
+	if verifier != nil {
+		err := verifier.Verify(ctx, guest.CaptchaToken, guest.IP)
+		if errors.Is(err, captcha.ErrFailed) {
+			return invalid("CAPTCHA challenge failed")
+		}
+		if err != nil {
+			s.logger.Error("Failed to verify a CAPTCHA challenge", zap.Error(err))
+			return ErrCaptchaUnavailable
+		}
+	}
+
+	// Check the comment before giving the guest an account
+	comment.Content = utils.SanitizeText(comment.Content)
+	if err := validateComment(comment); err != nil {
+		return err
+	}
+	if _, err := s.posts.FindPost(comment.PostID, 0); err != nil {
+		return err
+	}
+
+	author, _, err := accountForEmail(s.userRepo, guest.Email, "", guest.Name, true)
+	if err != nil {
+		return err
+	}
+	if !author.Guest || author.Password != "" || author.LastLogin != nil || author.Role != types.RoleUser {
+		return ErrGuestEmailTaken
+	}
+
+	comment.UserID = author.ID
+	comment.Status = models.CommentPublished
+	if cfg.Moderated {
+		comment.Status = models.CommentHidden
+	}
+	return s.posts.AddComment(comment, CommentOrigin{IP: guest.IP, UserAgent: guest.UserAgent, Referrer: guest.Referrer})
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/oauth"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/utils"
+
+	"go.uber.org/zap"
+)
+
+// PasswordLogin names logging in with a password, among the login methods
+// of a user
+const PasswordLogin = "password"
+
+var (
+	// ErrIdentityNotFound is returned when a user has no such login method.
+	ErrIdentityNotFound = errors.New("login method not linked")
+	// ErrIdentityTaken is returned when linking a provider account already
+	// linked to another user.
+	ErrIdentityTaken = errors.New("this provider account is linked to another user")
+	// ErrIdentityLinked is returned when linking a provider to a user who
+	// already linked an account of it, or a password to a user who has one.
+	ErrIdentityLinked = errors.New("login method already linked")
+	// ErrIdentityEmailTaken is returned when logging in with a provider
+	// account that isn't linked, but whose email address is the one of an
+	// existing user. Accounts are never merged on the email address alone:
+	// the user must log in and link the provider account.
+	ErrIdentityEmailTaken = errors.New("an account already uses the email address of this provider account, log in to it and link the provider")
+	// ErrLastLoginMethod is returned when unlinking the only login method of
+	// a user.
+	ErrLastLoginMethod = errors.New("the last login method of an account can't be removed")
+)
+
+// LoginMethods are the ways a user logs in.
+type LoginMethods struct {
+	Password   bool              `json:"password"`
+	Identities []models.Identity `json:"identities"`
+}
+
+// count returns the number of login methods.
+func (m *LoginMethods) count() int {
+	if m.Password {
+		return len(m.Identities) + 1
+	}
+	return len(m.Identities)
+}
+
+type IdentityService struct {
+	identityRepo *repositories.IdentityRepository
+	userRepo     *repositories.UserRepository
+	bus          events.Bus
+	logger       *zap.Logger
+}
+
+// NewIdentityService returns a new instance of IdentityService with the
+// provided IdentityRepository and UserRepository, publishing the sign-ups on
+// bus.
+func NewIdentityService(identityRepo *repositories.IdentityRepository, userRepo *repositories.UserRepository, bus events.Bus, logger *zap.Logger) *IdentityService {
+	return &IdentityService{
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		bus:          bus,
+		logger:       logger,
+	}
+}
+
+// Profile exchanges the authorization code received from a provider for
+// the profile of the account that granted it.
+func (s *IdentityService) Profile(ctx context.Context, provider, code string) (*oauth.Profile, error) {
+	p, err := oauth.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	return s.exchange(ctx, p, code)
+}
+
+// CallbackProfile is Profile for codes a provider redirected the user to the
+// callback endpoint of the API with.
+func (s *IdentityService) CallbackProfile(ctx context.Context, provider, code string) (*oauth.Profile, error) {
+	p, err := oauth.Callback(provider)
+	if err != nil {
+		return nil, err
+	}
+	return s.exchange(ctx, p, code)
+}
+
+// exchange exchanges an authorization code with a provider.
+func (s *IdentityService) exchange(ctx context.Context, p *oauth.Provider, code string) (*oauth.Profile, error) {
+	provider := p.Name()
+	if code == "" {
+		return nil, invalid("code is required")
+	}
+
+	profile, err := p.Exchange(ctx, code)
+	if err != nil {
+		s.logger.Info("Login provider exchange failed", zap.String("provider", provider), zap.Error(err))
+		return nil, oauth.ErrExchangeFailed
+	}
+	return profile, nil
+}
+
+// Login returns the user a provider account is linked to. Accounts awaiting
+// approval can't log in.
+//
+// It returns ErrIdentityNotFound when the provider account isn't linked, so
+// the caller can sign the user up, or ErrIdentityEmailTaken when its email
+// address is the one of an existing user.
+func (s *IdentityService) Login(profile *oauth.Profile) (*models.User, error) {
+	identity, err := s.identityRepo.FindBySubject(profile.Provider, profile.Subject)
+	if err != nil {
+		if err = notFound(err, ErrIdentityNotFound); err != ErrIdentityNotFound {
+			return nil, err
+		}
+		if profile.Email != "" {
+			if _, err := s.userRepo.FindByEmail(profile.Email); err == nil {
+				return nil, ErrIdentityEmailTaken
+			}
+		}
+		return nil, ErrIdentityNotFound
+	}
+
+	user, err := s.userRepo.FindByIDLite(identity.UserID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	if user.PendingApproval {
+		return nil, ErrPendingApproval
+	}
+	if !user.IsActive {
+		return nil, ErrAccountInactive
+	}
+	if err := s.userRepo.UpdateLastLogin(user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SignUp creates a new user from a provider account, linked to it. The user
+// has no password, and their email address is verified if the provider
+// verified it. The username is derived from the login of the account. A
+// UserRegistered event is published.
+func (s *IdentityService) SignUp(profile *oauth.Profile, pendingApproval bool) (*models.User, error) {
+	if profile.Email == "" {
+		return nil, invalid("the provider account has no email address")
+	}
+	if _, err := s.userRepo.FindByEmail(profile.Email); err == nil {
+		return nil, ErrIdentityEmailTaken
+	}
+
+	username, err := availableUsername(s.userRepo, profile.Login)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		Username:        username,
+		Email:           profile.Email,
+		Role:            "user",
+		PendingApproval: pendingApproval,
+	}
+	if profile.EmailVerified {
+		now := time.Now()
+		user.VerifiedAt = &now
+	}
+	if err := validateStruct(user); err != nil {
+		return nil, err
+	}
+
+	identity := &models.Identity{
+		Provider: profile.Provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	}
+	if err := s.identityRepo.CreateWithUser(user, identity); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("User signed up through a login provider",
+		zap.Uint("user_id", user.ID),
+		zap.String("provider", profile.Provider),
+	)
+	publish(context.Background(), s.bus, s.logger, events.UserRegistered, events.UserPayload{
+		UserID:   user.ID,
+		Username: user.Username,
+		Provider: profile.Provider,
+	})
+	return user, nil
+}
+
+// LoginMethods returns the login methods of a user.
+func (s *IdentityService) LoginMethods(userID uint) (*LoginMethods, error) {
+	user, err := s.userRepo.FindByIDLite(userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	identities, err := s.identityRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginMethods{Password: user.Password != "", Identities: identities}, nil
+}
+
+// Link links a provider account to a user. A provider account is linked to
+// a single user, and a user links a single account of each provider.
+func (s *IdentityService) Link(userID uint, profile *oauth.Profile) (*models.Identity, error) {
+	existing, err := s.identityRepo.FindBySubject(profile.Provider, profile.Subject)
+	if err == nil {
+		if existing.UserID == userID {
+			return nil, ErrIdentityLinked
+		}
+		return nil, ErrIdentityTaken
+	}
+	if err = notFound(err, ErrIdentityNotFound); err != ErrIdentityNotFound {
+		return nil, err
+	}
+
+	methods, err := s.LoginMethods(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range methods.Identities {
+		if identity.Provider == profile.Provider {
+			return nil, ErrIdentityLinked
+		}
+	}
+
+	identity := &models.Identity{
+		UserID:   userID,
+		Provider: profile.Provider,
+		Subject:  profile.Subject,
+		Email:    profile.Email,
+	}
+	if err := s.identityRepo.Create(identity); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Login provider linked", zap.Uint("user_id", userID), zap.String("provider", profile.Provider))
+	return identity, nil
+}
+
+// SetPassword lets a user without a password, who signed up through a
+// provider, log in with one too.
+func (s *IdentityService) SetPassword(userID uint, password string) error {
+	methods, err := s.LoginMethods(userID)
+	if err != nil {
+		return err
+	}
+	if methods.Password {
+		return ErrIdentityLinked
+	}
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	return s.userRepo.UpdatePassword(userID, password)
+}
+
+// Unlink removes a login method of a user, a provider or PasswordLogin. The
+// last login method of a user can't be removed.
+func (s *IdentityService) Unlink(userID uint, provider string) error {
+	methods, err := s.LoginMethods(userID)
+	if err != nil {
+		return err
+	}
+
+	linked := methods.Password && provider == PasswordLogin
+	for _, identity := range methods.Identities {
+		linked = linked || identity.Provider == provider
+	}
+	if !linked {
+		return ErrIdentityNotFound
+	}
+	if methods.count() == 1 {
+		return ErrLastLoginMethod
+	}
+
+	if provider == PasswordLogin {
+		err = s.userRepo.RemovePassword(userID)
+	} else {
+		err = notFound(s.identityRepo.Delete(userID, provider), ErrIdentityNotFound)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("Login method unlinked", zap.Uint("user_id", userID), zap.String("provider", provider))
+	return nil
+}
+
+// availableUsername returns a username for a new user, based on a login
+// such as the one of their provider account, suffixed with random
+// characters when taken.
+func availableUsername(userRepo *repositories.UserRepository, login string) (string, error) {
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)), r == '_', r == '-':
+			return unicode.ToLower(r)
+		case r == ' ', r == '.':
+			return '_'
+		}
+		return -1
+	}, login)
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	username := base
+	for attempt := 0; attempt < 5; attempt++ {
+		if _, err := userRepo.FindByUsername(username); err != nil {
+			return username, nil
+		}
+		suffix, err := utils.GenerateRandomToken(3)
+		if err != nil {
+			return "", err
+		}
+		username = base + "_" + suffix
+	}
+	return "", ErrUsernameTaken
+}
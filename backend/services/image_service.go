@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/imaging"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/storage"
+
+	"go.uber.org/zap"
+)
+
+// TaskImageVariants is the name of the runs generating the variants of an
+// uploaded image, followed by the ID of its media record.
+const TaskImageVariants = "image-variants"
+
+// webpName is the name of the WebP version of an image at its full size
+const webpName = "original"
+
+// ImageService generates the resized and WebP variants of the uploaded
+// images, as jobs of the TaskService, and describes them for responsive
+// images.
+type ImageService struct {
+	mediaRepo *repositories.MediaRepository
+	tasks     *TaskService
+	slots     chan struct{} // Limits the images processed at once
+	logger    *zap.Logger
+}
+
+// NewImageService returns a new instance of ImageService with the provided
+// MediaRepository, generating the variants through the provided
+// TaskService.
+func NewImageService(mediaRepo *repositories.MediaRepository, tasks *TaskService, logger *zap.Logger) *ImageService {
+	return &ImageService{
+		mediaRepo: mediaRepo,
+		tasks:     tasks,
+		slots:     make(chan struct{}, runtime.NumCPU()),
+		logger:    logger,
+	}
+}
+
+// GenerateVariants generates the variants of an uploaded image in the
+// background, as configured by "uploads.variants", stores them next to it
+// and returns the run generating them. Images are only downscaled, to the
+// configured sizes narrower than they are.
+func (s *ImageService) GenerateVariants(store storage.Storage, media *models.Media) (*TaskRun, error) {
+	if !imaging.Resizable(media.ContentType) {
+		return nil, invalid("images of type %s have no variants", media.ContentType)
+	}
+	job := *media
+	return s.tasks.Run(fmt.Sprintf("%s:%d", TaskImageVariants, media.ID), media.UserID, func(ctx context.Context) (string, error) {
+		return s.generate(ctx, store, &job)
+	})
+}
+
+// generate generates and records the variants of an image, and returns a
+// summary of what it did. The variants stored are removed when it fails.
+func (s *ImageService) generate(ctx context.Context, store storage.Storage, media *models.Media) (string, error) {
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	img, err := s.open(ctx, store, media)
+	if err != nil {
+		return "", err
+	}
+	media.Width, media.Height = img.Bounds().Dx(), img.Bounds().Dy()
+
+	cfg := config.Get().Uploads.Variants
+	sizes := append([]config.VariantSizeConfig(nil), cfg.Sizes...)
+	sort.SliceStable(sizes, func(i, j int) bool { return sizes[i].Width < sizes[j].Width })
+	webp := cfg.WebP && media.ContentType != "image/webp"
+
+	var variants []models.MediaVariant
+	put := func(name string, img image.Image, contentType string) error {
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, img, contentType, cfg.Quality); err != nil {
+			return err
+		}
+		key := variantKey(media.StorageKey, name, contentType)
+		if err := store.Put(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), contentType); err != nil {
+			return err
+		}
+		variants = append(variants, models.MediaVariant{
+			Name:        name,
+			StorageKey:  key,
+			URL:         store.URL(key),
+			ContentType: contentType,
+			Width:       img.Bounds().Dx(),
+			Height:      img.Bounds().Dy(),
+			Size:        int64(buf.Len()),
+		})
+		return nil
+	}
+
+	err = func() error {
+		if webp {
+			if err := put(webpName, img, "image/webp"); err != nil {
+				return err
+			}
+		}
+		for _, size := range sizes {
+			if size.Width >= media.Width {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			resized := imaging.Resize(img, size.Width)
+			if err := put(size.Name, resized, media.ContentType); err != nil {
+				return err
+			}
+			if webp {
+				if err := put(size.Name, resized, "image/webp"); err != nil {
+					return err
+				}
+			}
+		}
+		return s.mediaRepo.SetVariants(media, variants)
+	}()
+	if err != nil {
+		for _, variant := range variants {
+			if err := store.Delete(context.Background(), variant.StorageKey); err != nil {
+				s.logger.Warn("Failed to delete an image variant", zap.String("key", variant.StorageKey), zap.Error(err))
+			}
+		}
+		return "", err
+	}
+	return fmt.Sprintf("Generated %d variants of media %d", len(variants), media.ID), nil
+}
+
+// open reads and decodes the image of a media record.
+func (s *ImageService) open(ctx context.Context, store storage.Storage, media *models.Media) (image.Image, error) {
+	r, err := store.Get(ctx, media.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return imaging.Decode(bytes.NewReader(data))
+}
+
+// variantKey returns the storage key of a variant of the image stored under
+// key, next to it.
+func variantKey(key, name, contentType string) string {
+	ext := path.Ext(key)
+	base := strings.TrimSuffix(key, ext)
+	if contentType == "image/webp" {
+		ext = ".webp"
+	}
+	return base + "-" + name + ext
+}
+
+// ImageSets describes the uploaded images served from the given URLs for
+// responsive images, keyed by URL. Images without variants, and URLs of
+// other images, are left out.
+func (s *ImageService) ImageSets(urls []string) (map[string]*models.ImageSet, error) {
+	unique := make([]string, 0, len(urls))
+	seen := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		if url != "" && !seen[url] {
+			seen[url] = true
+			unique = append(unique, url)
+		}
+	}
+	sets := make(map[string]*models.ImageSet, len(unique))
+	if len(unique) == 0 {
+		return sets, nil
+	}
+
+	media, err := s.mediaRepo.FindByURLs(unique)
+	if err != nil {
+		return nil, err
+	}
+	for i := range media {
+		if len(media[i].Variants) > 0 {
+			sets[media[i].URL] = imageSet(&media[i])
+		}
+	}
+	return sets, nil
+}
+
+// ImageSet describes the uploaded image served from a URL for responsive
+// images, nil when it has no variants or isn't an upload.
+func (s *ImageService) ImageSet(url string) (*models.ImageSet, error) {
+	sets, err := s.ImageSets([]string{url})
+	if err != nil {
+		return nil, err
+	}
+	return sets[url], nil
+}
+
+// imageSet describes an image and its variants, with a source per format
+// listing its widths, WebP first and the format of the image last.
+func imageSet(media *models.Media) *models.ImageSet {
+	variants := append([]models.MediaVariant(nil), media.Variants...)
+	sort.SliceStable(variants, func(i, j int) bool { return variants[i].Width < variants[j].Width })
+
+	srcsets := make(map[string][]string)
+	for _, variant := range variants {
+		srcsets[variant.ContentType] = append(srcsets[variant.ContentType], fmt.Sprintf("%s %dw", variant.URL, variant.Width))
+	}
+	if media.Width > 0 {
+		srcsets[media.ContentType] = append(srcsets[media.ContentType], fmt.Sprintf("%s %dw", media.URL, media.Width))
+	}
+
+	set := &models.ImageSet{
+		Src:      media.URL,
+		Width:    media.Width,
+		Height:   media.Height,
+		Sources:  []models.ImageSource{},
+		Variants: variants,
+	}
+	types := []string{"image/webp", media.ContentType}
+	if media.ContentType == "image/webp" {
+		types = types[:1]
+	}
+	for _, contentType := range types {
+		if srcset := srcsets[contentType]; len(srcset) > 0 {
+			set.Sources = append(set.Sources, models.ImageSource{Type: contentType, Srcset: strings.Join(srcset, ", ")})
+		}
+	}
+	return set
+}
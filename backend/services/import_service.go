@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/importer"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/utils"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// TaskImport is the name of the runs of the imports.
+const TaskImport = "import"
+
+// ErrImportNotFound is returned when an import doesn't exist.
+var ErrImportNotFound = errors.New("import not found")
+
+// ImportService imports the content exported from WordPress, Ghost or a
+// static site generator. Imports run in the background as jobs of the
+// TaskService, and are polled through their runs.
+type ImportService struct {
+	postRepo *repositories.PostRepository
+	userRepo *repositories.UserRepository
+	tasks    *TaskService
+	logger   *zap.Logger
+}
+
+// NewImportService returns a new instance of ImportService with the
+// provided repositories, running the imports through the provided
+// TaskService.
+func NewImportService(postRepo *repositories.PostRepository, userRepo *repositories.UserRepository, tasks *TaskService, logger *zap.Logger) *ImportService {
+	return &ImportService{
+		postRepo: postRepo,
+		userRepo: userRepo,
+		tasks:    tasks,
+		logger:   logger,
+	}
+}
+
+// Start reads an export and imports its content in the background on behalf
+// of an admin, and returns the run of the import. An export that can't be
+// read is rejected right away, and a single import runs at a time.
+//
+// Posts whose slug is taken are skipped, so importing the same export twice
+// only adds what the first import missed. Authors and commenters are matched
+// to the accounts by email address, and the missing accounts are created
+// without a password, which their owners set by resetting it. Posts whose
+// author has no email address are attributed to the admin.
+//
+// Imported posts publish no events, so nobody is notified of them.
+func (s *ImportService) Start(data []byte, userID uint) (*TaskRun, error) {
+	archive, err := importer.Parse(data)
+	if err != nil {
+		return nil, invalid(err.Error())
+	}
+	if len(archive.Posts) == 0 {
+		return nil, invalid("the export holds no posts")
+	}
+	return s.tasks.Run(TaskImport, userID, func(ctx context.Context) (string, error) {
+		return s.run(ctx, archive, userID)
+	})
+}
+
+// GetRun returns the run of an import by its ID.
+func (s *ImportService) GetRun(id string) (*TaskRun, error) {
+	run, err := s.tasks.GetRun(id)
+	if err != nil || run.Task != TaskImport {
+		return nil, ErrImportNotFound
+	}
+	return run, nil
+}
+
+// importRun is the state of an import.
+type importRun struct {
+	*ImportService
+	users map[string]uint // Accounts by lowercase email address
+
+	posts, comments, accounts int
+	duplicates, failed        int
+}
+
+// run imports the content of an archive, and returns a summary of what it
+// did. Posts that fail to be imported are logged and skipped.
+func (s *ImportService) run(ctx context.Context, archive *importer.Archive, userID uint) (string, error) {
+	im := &importRun{ImportService: s, users: make(map[string]uint)}
+	authors := make(map[string]importer.Author, len(archive.Authors))
+	for _, author := range archive.Authors {
+		authors[author.Key] = author
+	}
+
+	for _, p := range archive.Posts {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		authorID := userID
+		if author, ok := authors[p.AuthorKey]; ok && author.Email != "" {
+			login := author.Username
+			if login == "" {
+				login = author.Name
+			}
+			id, err := im.account(author.Email, login, author.Name)
+			if err != nil {
+				s.logger.Warn("Failed to create the account of an imported author", zap.String("email", author.Email), zap.Error(err))
+			} else {
+				authorID = id
+			}
+		}
+		if err := im.post(p, authorID); err != nil {
+			im.failed++
+			s.logger.Warn("Failed to import a post", zap.String("title", p.Title), zap.String("slug", p.Slug), zap.Error(err))
+		}
+	}
+
+	return fmt.Sprintf("Imported %d posts, %d comments and %d new accounts from the %s export, skipped %d posts whose slug is taken and %d that failed",
+		im.posts, im.comments, im.accounts, archive.Format, im.duplicates, im.failed), nil
+}
+
+// post imports a post and its comments.
+func (im *importRun) post(p importer.Post, authorID uint) error {
+	post := &models.Post{
+		Title:           p.Title,
+		Slug:            p.Slug,
+		Content:         p.Content,
+		Excerpt:         p.Excerpt,
+		UserID:          authorID,
+		Status:          p.Status,
+		PublishedAt:     p.PublishedAt,
+		Tags:            make([]models.Tag, len(p.Tags)),
+		FeaturedImage:   p.FeaturedImage,
+		MetaTitle:       p.MetaTitle,
+		MetaDescription: p.MetaDescription,
+	}
+	for i, name := range p.Tags {
+		post.Tags[i] = models.Tag{Name: name}
+	}
+	if post.PublishedAt.IsZero() {
+		post.PublishedAt = time.Now()
+	}
+	post.CreatedAt = post.PublishedAt
+	sanitizePost(post)
+	if err := validatePost(post); err != nil {
+		return err
+	}
+
+	// Comments of commenters without an email address are skipped, and
+	// their replies become top-level comments
+	comments := make([]models.Comment, 0, len(p.Comments))
+	parents := make([]int, 0, len(p.Comments))
+	indexes := make(map[string]int, len(p.Comments))
+	for _, c := range p.Comments {
+		content := utils.SanitizeText(c.Content)
+		if c.AuthorEmail == "" || content == "" {
+			continue
+		}
+		userID, err := im.account(c.AuthorEmail, c.AuthorName, c.AuthorName)
+		if err != nil {
+			im.logger.Warn("Failed to create the account of an imported commenter", zap.String("email", c.AuthorEmail), zap.Error(err))
+			continue
+		}
+
+		comment := models.Comment{Content: content, UserID: userID, Status: models.CommentHidden}
+		if c.Approved {
+			comment.Status = models.CommentPublished
+			post.CommentCount++
+		}
+		if !c.CreatedAt.IsZero() {
+			comment.CreatedAt = c.CreatedAt
+		}
+		parent, ok := indexes[c.ParentKey]
+		if !ok {
+			parent = -1
+		}
+		indexes[c.Key] = len(comments)
+		comments = append(comments, comment)
+		parents = append(parents, parent)
+	}
+
+	created, err := im.postRepo.Import(post, comments, parents)
+	if err != nil {
+		return err
+	}
+	if !created {
+		im.duplicates++
+		return nil
+	}
+	im.posts++
+	im.comments += len(comments)
+	return nil
+}
+
+// account returns the ID of the account of an email address, creating it
+// with a username based on the login when it doesn't exist.
+func (im *importRun) account(email, login, name string) (uint, error) {
+	key := strings.ToLower(email)
+	if id, ok := im.users[key]; ok {
+		return id, nil
+	}
+
+	user, created, err := accountForEmail(im.userRepo, email, login, name, false)
+	if err != nil {
+		return 0, err
+	}
+	im.users[key] = user.ID
+	if created {
+		im.accounts++
+	}
+	return user.ID, nil
+}
+
+// accountForEmail returns the account of an email address, and creates it
+// without a password when it doesn't exist, with a username based on the
+// login and reporting it was created. The accounts created for guests are
+// marked as guest accounts. Owners of the created accounts set their
+// password by resetting it.
+func accountForEmail(userRepo *repositories.UserRepository, email, login, name string, guest bool) (*models.User, bool, error) {
+	if !utils.IsValidEmail(email) {
+		return nil, false, invalid("invalid email address")
+	}
+
+	user, err := userRepo.FindByEmail(email)
+	if err == nil {
+		return user, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	if login == "" {
+		login, _, _ = strings.Cut(email, "@")
+	}
+	username, err := availableUsername(userRepo, login)
+	if err != nil {
+		return nil, false, err
+	}
+	firstName, lastName, _ := strings.Cut(strings.TrimSpace(name), " ")
+	user = &models.User{
+		Username:  username,
+		Email:     email,
+		FirstName: firstName,
+		LastName:  lastName,
+		Role:      types.RoleUser,
+		IsActive:  true,
+		Guest:     guest,
+	}
+	if err := userRepo.Create(user); err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/utils"
+	"github.com/SteaceP/coderage/webhooks"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrIntegrationNotFound is returned when an integration doesn't exist.
+	ErrIntegrationNotFound = errors.New("integration not found")
+	// ErrInvalidSignature is returned when the payload sent to an inbound
+	// webhook isn't signed with the secret of its integration.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// InboundComment is the payload of a comment sent to the inbound webhook of
+// an integration. The post is given by ID or by slug, and the comment
+// replied to, if any, by ID or by its ID in the external service.
+type InboundComment struct {
+	ExternalID       string     `json:"external_id" validate:"required,max=255"` // ID of the comment in the external service
+	PostID           uint       `json:"post_id,omitempty"`
+	PostSlug         string     `json:"post_slug,omitempty"`
+	ParentID         *uint      `json:"parent_id,omitempty"`
+	ParentExternalID string     `json:"parent_external_id,omitempty" validate:"max=255"`
+	AuthorEmail      string     `json:"author_email" validate:"required,email"`
+	AuthorName       string     `json:"author_name,omitempty" validate:"max=100"`
+	Content          string     `json:"content" validate:"required,max=500"`
+	CreatedAt        *time.Time `json:"created_at,omitempty"` // Defaults to the time of the request
+}
+
+// IntegrationService manages the integrations of external services, and
+// creates the comments they send to their inbound webhook.
+type IntegrationService struct {
+	integrationRepo *repositories.IntegrationRepository
+	postRepo        *repositories.PostRepository
+	userRepo        *repositories.UserRepository
+	commentRepo     *repositories.CommentRepository
+	posts           *PostService
+	logger          *zap.Logger
+}
+
+// NewIntegrationService returns a new instance of IntegrationService with
+// the provided repositories, creating the comments through the provided
+// PostService.
+func NewIntegrationService(integrationRepo *repositories.IntegrationRepository, postRepo *repositories.PostRepository, userRepo *repositories.UserRepository, commentRepo *repositories.CommentRepository, posts *PostService, logger *zap.Logger) *IntegrationService {
+	return &IntegrationService{
+		integrationRepo: integrationRepo,
+		postRepo:        postRepo,
+		userRepo:        userRepo,
+		commentRepo:     commentRepo,
+		posts:           posts,
+		logger:          logger,
+	}
+}
+
+// CreateIntegration registers a new integration. A secret is generated when
+// none is provided.
+func (s *IntegrationService) CreateIntegration(integration *models.Integration) error {
+	integration.Name = strings.TrimSpace(integration.Name)
+	if integration.Name == "" {
+		return invalid("name is required")
+	}
+	if integration.Secret == "" {
+		secret, err := utils.GenerateRandomToken(32)
+		if err != nil {
+			return err
+		}
+		integration.Secret = secret
+	}
+	return s.integrationRepo.Create(integration)
+}
+
+// ListIntegrations retrieves every integration.
+func (s *IntegrationService) ListIntegrations() ([]models.Integration, error) {
+	return s.integrationRepo.List()
+}
+
+// GetIntegration retrieves an integration by its ID.
+func (s *IntegrationService) GetIntegration(id uint) (*models.Integration, error) {
+	integration, err := s.integrationRepo.FindByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrIntegrationNotFound)
+	}
+	return integration, nil
+}
+
+// DeleteIntegration removes an integration. The comments it created are
+// kept.
+func (s *IntegrationService) DeleteIntegration(id uint) error {
+	if _, err := s.GetIntegration(id); err != nil {
+		return err
+	}
+	return s.integrationRepo.Delete(id)
+}
+
+// Verify checks that a payload sent to the inbound webhook of an integration
+// is signed with its secret, as the outgoing webhooks are, and returns the
+// integration. Unknown integrations fail the check as well, so their IDs
+// can't be probed.
+func (s *IntegrationService) Verify(id uint, signature string, body []byte) (*models.Integration, error) {
+	integration, err := s.integrationRepo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidSignature
+		}
+		return nil, err
+	}
+	if !webhooks.Verify(integration.Secret, body, signature) {
+		return nil, ErrInvalidSignature
+	}
+	return integration, nil
+}
+
+// CreateComment creates the comment sent by an integration, and reports
+// whether it was created: a comment whose external ID was already received
+// is returned as is, so integrations can safely send a payload again.
+//
+// Comments are attributed to the account of the author's email address,
+// which is created without a password when missing, and are held for
+// moderation when the integration is moderated.
+func (s *IntegrationService) CreateComment(integration *models.Integration, payload InboundComment) (*models.Comment, bool, error) {
+	payload.ExternalID = strings.TrimSpace(payload.ExternalID)
+	payload.AuthorEmail = strings.TrimSpace(payload.AuthorEmail)
+	if err := validateStruct(payload); err != nil {
+		return nil, false, err
+	}
+	if (payload.PostID == 0) == (payload.PostSlug == "") {
+		return nil, false, invalid("either post_id or post_slug is required")
+	}
+	if payload.ParentID != nil && payload.ParentExternalID != "" {
+		return nil, false, invalid("parent_id and parent_external_id are exclusive")
+	}
+
+	// Return the comment already created for the external ID
+	if comment, err := s.linkedComment(integration.ID, payload.ExternalID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		return comment, false, err
+	}
+
+	postID := payload.PostID
+	if payload.PostSlug != "" {
+		post, err := s.postRepo.FindBySlug(payload.PostSlug)
+		if err != nil {
+			return nil, false, notFound(err, ErrPostNotFound)
+		}
+		postID = post.ID
+	}
+	parentID := payload.ParentID
+	if payload.ParentExternalID != "" {
+		parent, err := s.integrationRepo.FindComment(integration.ID, payload.ParentExternalID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, false, invalid("parent comment not found")
+			}
+			return nil, false, err
+		}
+		parentID = &parent.CommentID
+	}
+
+	author, _, err := accountForEmail(s.userRepo, payload.AuthorEmail, "", payload.AuthorName, false)
+	if err != nil {
+		return nil, false, err
+	}
+
+	comment := &models.Comment{
+		Content:  payload.Content,
+		UserID:   author.ID,
+		PostID:   postID,
+		ParentID: parentID,
+	}
+	if integration.Moderated {
+		comment.Status = models.CommentHidden
+	}
+	if payload.CreatedAt != nil {
+		comment.CreatedAt = *payload.CreatedAt
+	}
+
+	// The comment and its link are created together, and a payload sent
+	// again while the first one is handled returns the comment it created
+	link := &models.IntegrationComment{
+		IntegrationID: integration.ID,
+		ExternalID:    payload.ExternalID,
+	}
+	err = s.posts.addComment(comment, CommentOrigin{}, func(comment *models.Comment) error {
+		return s.integrationRepo.CreateComment(comment, link)
+	})
+	if errors.Is(err, repositories.ErrExternalIDTaken) {
+		comment, err := s.linkedComment(integration.ID, payload.ExternalID)
+		return comment, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.logger.Info("Comment created by integration",
+		zap.Uint("integration_id", integration.ID),
+		zap.Uint("comment_id", comment.ID),
+	)
+	return comment, true, nil
+}
+
+// linkedComment returns the comment an integration created for an external
+// ID. It returns gorm.ErrRecordNotFound when the external ID wasn't linked
+// to a comment yet.
+func (s *IntegrationService) linkedComment(integrationID uint, externalID string) (*models.Comment, error) {
+	link, err := s.integrationRepo.FindComment(integrationID, externalID)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := s.commentRepo.FindByID(link.CommentID)
+	if err != nil {
+		return nil, notFound(err, ErrCommentNotFound)
+	}
+	return comment, nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+
+	"go.uber.org/zap"
+)
+
+// Leaderboard windows
+const (
+	LeaderboardWeek  = "week"
+	LeaderboardMonth = "month"
+	LeaderboardYear  = "year"
+	LeaderboardAll   = "all"
+)
+
+// leaderboardWindows maps the leaderboard windows to their duration, zero
+// for all time
+var leaderboardWindows = map[string]time.Duration{
+	LeaderboardWeek:  7 * 24 * time.Hour,
+	LeaderboardMonth: 30 * 24 * time.Hour,
+	LeaderboardYear:  365 * 24 * time.Hour,
+	LeaderboardAll:   0,
+}
+
+// LeaderboardEntry is a ranked user of a leaderboard.
+type LeaderboardEntry struct {
+	Rank  int          `json:"rank"`
+	User  *models.User `json:"user"`
+	Score int64        `json:"score"`
+}
+
+// Leaderboard ranks the users over a window. Authors are ranked on the posts
+// they published during the window, and commenters on the comments they
+// wrote during the window.
+type Leaderboard struct {
+	Window         string             `json:"window"`
+	Since          *time.Time         `json:"since,omitempty"` // Start of the window, unset for all time
+	ComputedAt     time.Time          `json:"computed_at"`
+	AuthorsByViews []LeaderboardEntry `json:"authors_by_views"`
+	AuthorsByLikes []LeaderboardEntry `json:"authors_by_likes"`
+	Commenters     []LeaderboardEntry `json:"commenters"`
+}
+
+// LeaderboardService computes the leaderboards and caches them until the
+// next scheduled refresh.
+type LeaderboardService struct {
+	postRepo    *repositories.PostRepository
+	commentRepo *repositories.CommentRepository
+	userRepo    *repositories.UserRepository
+	logger      *zap.Logger
+	cache       *leaderboardCache
+}
+
+// leaderboardCache holds the leaderboards by window, shared by the copies of
+// the service scoped to requests.
+type leaderboardCache struct {
+	mu     sync.RWMutex
+	boards map[string]*Leaderboard
+}
+
+// get returns the cached leaderboard of a window.
+func (c *leaderboardCache) get(window string) (*Leaderboard, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	board, ok := c.boards[window]
+	return board, ok
+}
+
+// set caches the leaderboard of a window.
+func (c *leaderboardCache) set(window string, board *Leaderboard) {
+	c.mu.Lock()
+	c.boards[window] = board
+	c.mu.Unlock()
+}
+
+// NewLeaderboardService returns a new instance of LeaderboardService with the
+// provided PostRepository, CommentRepository and UserRepository.
+func NewLeaderboardService(postRepo *repositories.PostRepository, commentRepo *repositories.CommentRepository, userRepo *repositories.UserRepository, logger *zap.Logger) *LeaderboardService {
+	return &LeaderboardService{
+		postRepo:    postRepo,
+		commentRepo: commentRepo,
+		userRepo:    userRepo,
+		logger:      logger,
+		cache:       &leaderboardCache{boards: make(map[string]*Leaderboard)},
+	}
+}
+
+// GetLeaderboard returns the leaderboard of a window from the cache. It is
+// computed on the spot when it isn't cached yet.
+func (s *LeaderboardService) GetLeaderboard(window string) (*Leaderboard, error) {
+	if _, ok := leaderboardWindows[window]; !ok {
+		return nil, invalid("window must be one of week, month, year or all")
+	}
+
+	if board, ok := s.cache.get(window); ok {
+		return board, nil
+	}
+
+	board, err := s.compute(window)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.set(window, board)
+	return board, nil
+}
+
+// Refresh recomputes the leaderboards of every window. The cached
+// leaderboard of a window is kept when it fails to be recomputed.
+func (s *LeaderboardService) Refresh() {
+	for window := range leaderboardWindows {
+		board, err := s.compute(window)
+		if err != nil {
+			s.logger.Error("Failed to compute leaderboard", zap.String("window", window), zap.Error(err))
+			continue
+		}
+		s.cache.set(window, board)
+	}
+}
+
+// Run refreshes the leaderboards right away, then at the given interval until
+// the context is canceled. Intervals under a minute are raised to a minute.
+func (s *LeaderboardService) Run(ctx context.Context, interval time.Duration) {
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	s.Refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Refresh()
+		}
+	}
+}
+
+// compute builds the leaderboard of a window from the database.
+func (s *LeaderboardService) compute(window string) (*Leaderboard, error) {
+	now := time.Now()
+	board := &Leaderboard{Window: window, ComputedAt: now}
+
+	var since time.Time
+	if duration := leaderboardWindows[window]; duration > 0 {
+		since = now.Add(-duration)
+		board.Since = &since
+	}
+
+	size := config.Get().Leaderboards.Size
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	views, err := s.postRepo.TopAuthors("view_count", since, size)
+	if err != nil {
+		return nil, err
+	}
+	likes, err := s.postRepo.TopAuthors("like_count", since, size)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.commentRepo.TopCommenters(since, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the users ranked on any of the leaderboards at once
+	var ids []uint
+	for _, scores := range [][]repositories.UserScore{views, likes, comments} {
+		for _, score := range scores {
+			ids = append(ids, score.UserID)
+		}
+	}
+	users, err := s.userRepo.FindSummaries(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.User, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+
+	board.AuthorsByViews = rank(views, byID)
+	board.AuthorsByLikes = rank(likes, byID)
+	board.Commenters = rank(comments, byID)
+	return board, nil
+}
+
+// rank turns scores into leaderboard entries. Users that no longer exist are
+// left out.
+func rank(scores []repositories.UserScore, users map[uint]*models.User) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(scores))
+	for _, score := range scores {
+		user, ok := users[score.UserID]
+		if !ok {
+			continue
+		}
+		entries = append(entries, LeaderboardEntry{
+			Rank:  len(entries) + 1,
+			User:  user,
+			Score: score.Score,
+		})
+	}
+	return entries
+}
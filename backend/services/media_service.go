@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/storage"
+)
+
+type MediaService struct {
+	mediaRepo *repositories.MediaRepository
+}
+
+// NewMediaService returns a new instance of MediaService with the provided
+// MediaRepository.
+func NewMediaService(mediaRepo *repositories.MediaRepository) *MediaService {
+	return &MediaService{
+		mediaRepo: mediaRepo,
+	}
+}
+
+// CreateMedia records an uploaded file.
+func (s *MediaService) CreateMedia(media *models.Media) error {
+	return s.mediaRepo.Create(media)
+}
+
+// CountRecentUploads counts the files uploaded by a user over the given period.
+func (s *MediaService) CountRecentUploads(userID uint, period time.Duration) (int64, error) {
+	return s.mediaRepo.CountByUserSince(userID, time.Now().Add(-period))
+}
+
+// MigrateMedia moves the next batch of up to limit files stored on from with
+// an ID above afterID to another backend, along with the variants of the
+// images. The files are copied first, then
+// their records and the posts linking to them are updated in a single
+// transaction, and the copied files are finally deleted from from when
+// deleteSource is set. It returns the number of files moved, 0 once none is
+// left, and the ID of the last one.
+//
+// Moved records no longer belong to from, so an interrupted migration
+// resumes where it stopped when started again.
+func (s *MediaService) MigrateMedia(ctx context.Context, from, to storage.Storage, afterID uint, limit int, deleteSource bool) (int, uint, error) {
+	media, err := s.mediaRepo.ListByBackend(from.Name(), afterID, limit)
+	if err != nil || len(media) == 0 {
+		return 0, 0, err
+	}
+
+	previousURLs := make([]string, len(media))
+	for i := range media {
+		if err := copyObject(ctx, from, to, media[i].StorageKey, media[i].Size, media[i].ContentType); err != nil {
+			return 0, 0, fmt.Errorf("failed to copy media %d: %v", media[i].ID, err)
+		}
+		for j := range media[i].Variants {
+			variant := &media[i].Variants[j]
+			if err := copyObject(ctx, from, to, variant.StorageKey, variant.Size, variant.ContentType); err != nil {
+				return 0, 0, fmt.Errorf("failed to copy the %s variant of media %d: %v", variant.Name, media[i].ID, err)
+			}
+			variant.URL = to.URL(variant.StorageKey)
+		}
+		previousURLs[i] = media[i].URL
+		media[i].Backend = to.Name()
+		media[i].URL = to.URL(media[i].StorageKey)
+	}
+	if err := s.mediaRepo.Relocate(media, previousURLs); err != nil {
+		return 0, 0, fmt.Errorf("failed to update media records: %v", err)
+	}
+
+	if deleteSource {
+		for _, m := range media {
+			keys := []string{m.StorageKey}
+			for _, variant := range m.Variants {
+				keys = append(keys, variant.StorageKey)
+			}
+			for _, key := range keys {
+				if err := from.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+					return len(media), 0, fmt.Errorf("failed to delete media %d from %s: %v", m.ID, from.Name(), err)
+				}
+			}
+		}
+	}
+	return len(media), media[len(media)-1].ID, nil
+}
+
+// copyObject copies a stored file from a backend to another.
+func copyObject(ctx context.Context, from, to storage.Storage, key string, size int64, contentType string) error {
+	r, err := from.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return to.Put(ctx, key, r, size, contentType)
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotificationNotFound is returned when a notification doesn't exist.
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// Notification types
+const (
+	NotificationPostCommented  = "post.commented"
+	NotificationCommentReplied = "comment.replied"
+	NotificationCommentLiked   = "comment.liked"
+)
+
+// maxLatestActors is the number of actors kept on a grouped notification
+const maxLatestActors = 3
+
+// NotificationEvents are the events users are notified of, to subscribe
+// HandleEvent to.
+var NotificationEvents = []string{events.CommentCreated, events.CommentLiked}
+
+type NotificationService struct {
+	notificationRepo *repositories.NotificationRepository
+	userRepo         *repositories.UserRepository
+	postRepo         *repositories.PostRepository
+	commentRepo      *repositories.CommentRepository
+	mailer           mailer.Mailer
+	logger           *zap.Logger
+}
+
+// NewNotificationService returns a new instance of NotificationService with
+// the provided repositories, sending the email digests through m.
+func NewNotificationService(notificationRepo *repositories.NotificationRepository, userRepo *repositories.UserRepository, postRepo *repositories.PostRepository, commentRepo *repositories.CommentRepository, m mailer.Mailer, logger *zap.Logger) *NotificationService {
+	return &NotificationService{
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+		postRepo:         postRepo,
+		commentRepo:      commentRepo,
+		mailer:           m,
+		logger:           logger,
+	}
+}
+
+// HandleEvent notifies users of the activity an event reports: the author
+// of a post of a new comment, the author of a comment of a reply or a like.
+func (s *NotificationService) HandleEvent(ctx context.Context, event events.Event) error {
+	switch event.Type {
+	case events.CommentCreated:
+		var payload events.CommentPayload
+		if err := event.Decode(&payload); err != nil {
+			return err
+		}
+		comment := &models.Comment{UserID: payload.UserID, PostID: payload.PostID}
+		comment.ID = payload.CommentID
+		if payload.ParentID == nil {
+			post, err := s.postRepo.FindByID(payload.PostID)
+			if err != nil {
+				return err
+			}
+			s.PostCommented(post, comment)
+			return nil
+		}
+		parent, err := s.commentRepo.FindByID(*payload.ParentID)
+		if err != nil {
+			return err
+		}
+		s.CommentReplied(parent, comment)
+	case events.CommentLiked:
+		var payload events.CommentLikePayload
+		if err := event.Decode(&payload); err != nil {
+			return err
+		}
+		comment := &models.Comment{UserID: payload.AuthorID, PostID: payload.PostID}
+		comment.ID = payload.CommentID
+		s.CommentLiked(comment, payload.UserID)
+	}
+	return nil
+}
+
+// PostCommented notifies the author of a post of a new top-level comment.
+// Comments on the same post are grouped.
+func (s *NotificationService) PostCommented(post *models.Post, comment *models.Comment) {
+	s.notify(&models.Notification{
+		UserID:    post.UserID,
+		Type:      NotificationPostCommented,
+		GroupKey:  fmt.Sprintf("%s:%d", NotificationPostCommented, post.ID),
+		PostID:    &post.ID,
+		CommentID: &comment.ID,
+	}, comment.UserID)
+}
+
+// CommentReplied notifies the author of a comment of a reply. Replies in the
+// same thread are grouped.
+func (s *NotificationService) CommentReplied(parent *models.Comment, reply *models.Comment) {
+	s.notify(&models.Notification{
+		UserID:    parent.UserID,
+		Type:      NotificationCommentReplied,
+		GroupKey:  fmt.Sprintf("%s:%d", NotificationCommentReplied, parent.ID),
+		PostID:    &parent.PostID,
+		CommentID: &parent.ID,
+	}, reply.UserID)
+}
+
+// CommentLiked notifies the author of a comment of a like. Likes on the same
+// comment are grouped.
+func (s *NotificationService) CommentLiked(comment *models.Comment, userID uint) {
+	s.notify(&models.Notification{
+		UserID:    comment.UserID,
+		Type:      NotificationCommentLiked,
+		GroupKey:  fmt.Sprintf("%s:%d", NotificationCommentLiked, comment.ID),
+		PostID:    &comment.PostID,
+		CommentID: &comment.ID,
+	}, userID)
+}
+
+// notify groups the activity of the actor into a notification. Users aren't
+// notified of their own activity. Notifications are a side effect of the
+// activity, so failures are logged rather than returned.
+func (s *NotificationService) notify(n *models.Notification, actorID uint) {
+	if n.UserID == 0 || n.UserID == actorID {
+		return
+	}
+
+	if err := s.notificationRepo.Group(n, actorID, maxLatestActors); err != nil {
+		s.logger.Error("Failed to record notification",
+			zap.String("type", n.Type),
+			zap.Uint("user_id", n.UserID),
+			zap.Error(err),
+		)
+	}
+}
+
+// ListNotifications retrieves the notifications of a user with pagination,
+// along with the latest actors of each notification.
+func (s *NotificationService) ListNotifications(userID uint, unreadOnly bool, page, pageSize int) ([]models.Notification, int64, error) {
+	notifications, total, err := s.notificationRepo.ListByUser(userID, unreadOnly, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Load every actor at once
+	var ids []uint
+	for _, n := range notifications {
+		ids = append(ids, n.LatestActorIDs...)
+	}
+	users, err := s.userRepo.FindSummaries(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uint]models.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	for i := range notifications {
+		for _, id := range notifications[i].LatestActorIDs {
+			if u, ok := byID[id]; ok {
+				notifications[i].Actors = append(notifications[i].Actors, u)
+			}
+		}
+	}
+
+	return notifications, total, nil
+}
+
+// CountUnread counts the unread notifications of a user.
+func (s *NotificationService) CountUnread(userID uint) (int64, error) {
+	return s.notificationRepo.CountUnread(userID)
+}
+
+// MarkRead marks a notification of a user as read. Later activity on the
+// same subject starts a new notification.
+func (s *NotificationService) MarkRead(userID, notificationID uint) error {
+	return notFound(s.notificationRepo.MarkRead(userID, notificationID), ErrNotificationNotFound)
+}
+
+// MarkAllRead marks every notification of a user as read.
+func (s *NotificationService) MarkAllRead(userID uint) error {
+	return s.notificationRepo.MarkAllRead(userID)
+}
+
+// SendDigests emails the users who opted in to digests a summary of their
+// unread notifications with new activity since their last digest, and
+// returns the number of digests sent. A failed email is logged, and retried
+// with the next digests.
+func (s *NotificationService) SendDigests(ctx context.Context) (int, error) {
+	notifications, err := s.notificationRepo.ListForDigest()
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	titles := make(map[uint]string)
+	for start := 0; start < len(notifications); {
+		// Notifications are grouped by user
+		end := start + 1
+		for end < len(notifications) && notifications[end].UserID == notifications[start].UserID {
+			end++
+		}
+		batch := notifications[start:end]
+		start = end
+
+		user, err := s.userRepo.FindByIDLite(batch[0].UserID)
+		if err != nil {
+			continue
+		}
+		if err := s.sendDigest(ctx, user, batch, titles); err != nil {
+			s.logger.Error("Failed to send notification digest", zap.Uint("user_id", user.ID), zap.Error(err))
+			continue
+		}
+
+		ids := make([]uint, len(batch))
+		for i, n := range batch {
+			ids[i] = n.ID
+		}
+		if err := s.notificationRepo.MarkDigested(ids, time.Now()); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// sendDigest emails a user the digest of their notifications. The titles of
+// the posts are cached in titles across digests.
+func (s *NotificationService) sendDigest(ctx context.Context, user *models.User, notifications []models.Notification, titles map[uint]string) error {
+	items := make([]string, 0, len(notifications))
+	for _, n := range notifications {
+		title := "a post"
+		if n.PostID != nil {
+			if _, ok := titles[*n.PostID]; !ok {
+				if post, err := s.postRepo.FindByID(*n.PostID); err == nil {
+					titles[*n.PostID] = fmt.Sprintf("%q", post.Title)
+				} else {
+					titles[*n.PostID] = title
+				}
+			}
+			title = titles[*n.PostID]
+		}
+		items = append(items, describeNotification(n, title))
+	}
+
+	msg, err := mailer.Render(mailer.TemplateDigest, []string{user.Email}, map[string]interface{}{
+		"Username": user.Username,
+		"Count":    plural(len(notifications), "unread notification", "unread notifications"),
+		"Items":    items,
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, msg)
+}
+
+// describeNotification returns a line describing a notification, about the
+// post with the given title.
+func describeNotification(n models.Notification, title string) string {
+	switch n.Type {
+	case NotificationPostCommented:
+		return fmt.Sprintf("%s on your post %s", plural(n.Count, "new comment", "new comments"), title)
+	case NotificationCommentReplied:
+		return fmt.Sprintf("%s to your comment on %s", plural(n.Count, "new reply", "new replies"), title)
+	case NotificationCommentLiked:
+		return fmt.Sprintf("%s on your comment on %s", plural(n.Count, "new like", "new likes"), title)
+	}
+	return fmt.Sprintf("%s on %s", plural(n.Count, "new activity", "new activities"), title)
+}
+
+// plural returns a count followed by the singular or plural form of a noun.
+func plural(count int, one, many string) string {
+	if count == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", count, many)
+}
+
+// RunDigests sends the digests at the given interval until the context is
+// canceled. Intervals under a minute are raised to a minute.
+func (s *NotificationService) RunDigests(ctx context.Context, interval time.Duration) {
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := s.SendDigests(ctx)
+			if err != nil {
+				s.logger.Error("Failed to send notification digests", zap.Error(err))
+				continue
+			}
+			s.logger.Info("Notification digests sent", zap.Int("count", sent))
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+
+	"go.uber.org/zap"
+)
+
+// Onboarding steps, in the order new users are guided through them
+const (
+	StepVerifyEmail     = "verify_email"
+	StepCompleteProfile = "complete_profile" // First name, bio and profile picture set
+	StepFirstPost       = "first_post"
+)
+
+// onboardingSteps lists the steps in order
+var onboardingSteps = []string{StepVerifyEmail, StepCompleteProfile, StepFirstPost}
+
+// Events published on the bus as users progress
+const (
+	EventOnboardingStepCompleted = "onboarding.step_completed"
+	EventOnboardingCompleted     = "onboarding.completed"
+)
+
+// OnboardingStatus is the progress of a user through the onboarding.
+type OnboardingStatus struct {
+	Steps     []OnboardingStepStatus `json:"steps"`
+	Completed bool                   `json:"completed"`
+	// Next is the first step left, empty once every step is completed
+	Next string `json:"next,omitempty"`
+}
+
+// OnboardingStepStatus is the state of a step of the onboarding.
+type OnboardingStepStatus struct {
+	Step        string     `json:"step"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+type OnboardingService struct {
+	stepRepo *repositories.OnboardingStepRepository
+	userRepo *repositories.UserRepository
+	postRepo *repositories.PostRepository
+	bus      events.Bus
+	logger   *zap.Logger
+}
+
+// NewOnboardingService returns a new instance of OnboardingService with the
+// provided repositories, publishing the progress of users on bus.
+func NewOnboardingService(stepRepo *repositories.OnboardingStepRepository, userRepo *repositories.UserRepository, postRepo *repositories.PostRepository, bus events.Bus, logger *zap.Logger) *OnboardingService {
+	return &OnboardingService{
+		stepRepo: stepRepo,
+		userRepo: userRepo,
+		postRepo: postRepo,
+		bus:      bus,
+		logger:   logger,
+	}
+}
+
+// Status returns the progress of a user through the onboarding. Steps found
+// completed for the first time are recorded, and their completion published
+// as an EventOnboardingStepCompleted event, followed by an
+// EventOnboardingCompleted event once the last one is completed.
+//
+// Completed steps stay completed, even if the user undoes them later, e.g.
+// by clearing their bio.
+func (s *OnboardingService) Status(ctx context.Context, userID uint) (*OnboardingStatus, error) {
+	user, err := s.userRepo.FindByIDLite(userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	recorded, err := s.stepRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	completedAt := make(map[string]time.Time, len(recorded))
+	for _, step := range recorded {
+		completedAt[step.Step] = step.CompletedAt
+	}
+
+	status := &OnboardingStatus{Steps: make([]OnboardingStepStatus, 0, len(onboardingSteps))}
+	newlyCompleted := false
+	for _, step := range onboardingSteps {
+		at, completed := completedAt[step]
+		if !completed {
+			done, err := s.done(user, step)
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				at = time.Now()
+				created, err := s.record(ctx, userID, step, at)
+				if err != nil {
+					return nil, err
+				}
+				newlyCompleted = newlyCompleted || created
+				completed = true
+			}
+		}
+
+		stepStatus := OnboardingStepStatus{Step: step, Completed: completed}
+		if completed {
+			stepStatus.CompletedAt = &at
+		} else if status.Next == "" {
+			status.Next = step
+		}
+		status.Steps = append(status.Steps, stepStatus)
+	}
+	status.Completed = status.Next == ""
+
+	if status.Completed && newlyCompleted {
+		publish(ctx, s.bus, s.logger, EventOnboardingCompleted, map[string]interface{}{
+			"user_id": userID,
+		})
+	}
+	return status, nil
+}
+
+// Refresh records the steps a user just completed, publishing their
+// events. Failures are logged, as they must not fail the action that
+// completed the step.
+func (s *OnboardingService) Refresh(ctx context.Context, userID uint) {
+	if _, err := s.Status(ctx, userID); err != nil {
+		s.logger.Error("Failed to refresh onboarding", zap.Uint("user_id", userID), zap.Error(err))
+	}
+}
+
+// done reports whether a user completed a step.
+func (s *OnboardingService) done(user *models.User, step string) (bool, error) {
+	switch step {
+	case StepVerifyEmail:
+		return user.VerifiedAt != nil, nil
+	case StepCompleteProfile:
+		return user.FirstName != "" && user.Bio != "" && user.ProfilePicture != "", nil
+	case StepFirstPost:
+		count, err := s.postRepo.CountByUserID(user.ID)
+		return count > 0, err
+	}
+	return false, nil
+}
+
+// record records a completed step and publishes its event. It reports
+// whether the step was recorded by this call, and not concurrently by
+// another.
+func (s *OnboardingService) record(ctx context.Context, userID uint, step string, at time.Time) (bool, error) {
+	created, err := s.stepRepo.Create(&models.OnboardingStep{UserID: userID, Step: step, CompletedAt: at})
+	if err != nil || !created {
+		return false, err
+	}
+
+	publish(ctx, s.bus, s.logger, EventOnboardingStepCompleted, map[string]interface{}{
+		"user_id":      userID,
+		"step":         step,
+		"completed_at": at,
+	})
+	return true, nil
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+
+	"go.uber.org/zap"
+)
+
+// Resources limited by plans
+const (
+	QuotaPosts    = "posts"
+	QuotaComments = "comments"
+	QuotaStorage  = "storage" // In bytes
+	QuotaMembers  = "members"
+)
+
+var (
+	// ErrPlanNotFound is returned when a plan doesn't exist.
+	ErrPlanNotFound = errors.New("plan not found")
+	// ErrPlanInUse is returned when deleting the plan of the site.
+	ErrPlanInUse = errors.New("the plan is assigned to the site")
+	// ErrPlanNameTaken is returned when naming a plan like another one.
+	ErrPlanNameTaken = errors.New("a plan with this name already exists")
+)
+
+// QuotaExceededError is returned when an action would take the site over a
+// limit of its plan.
+type QuotaExceededError struct {
+	Plan     string
+	Resource string
+	Limit    int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	if e.Resource == QuotaStorage {
+		return fmt.Sprintf("the %s plan is limited to %d bytes of storage", e.Plan, e.Limit)
+	}
+	return fmt.Sprintf("the %s plan is limited to %d %s", e.Plan, e.Limit, e.Resource)
+}
+
+// PlanUpdate holds the changes to apply to a plan. Nil fields are left
+// unchanged.
+type PlanUpdate struct {
+	Name            *string
+	MaxPosts        *int64
+	MaxComments     *int64
+	MaxStorageBytes *int64
+	MaxMembers      *int64
+}
+
+// apply sets the fields of a plan that are set in the update.
+func (u PlanUpdate) apply(plan *models.Plan) {
+	setString(&plan.Name, u.Name)
+	for _, field := range []struct {
+		limit *int64
+		value *int64
+	}{
+		{&plan.MaxPosts, u.MaxPosts},
+		{&plan.MaxComments, u.MaxComments},
+		{&plan.MaxStorageBytes, u.MaxStorageBytes},
+		{&plan.MaxMembers, u.MaxMembers},
+	} {
+		if field.value != nil {
+			*field.limit = *field.value
+		}
+	}
+}
+
+// ResourceUsage is the usage of a resource against the limit of the plan of
+// the site.
+type ResourceUsage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"` // 0 for unlimited
+	// Exceeded is set when the usage went over the limit, as when the site
+	// moves to a smaller plan. Nothing is removed, but no more is allowed.
+	Exceeded bool `json:"exceeded"`
+}
+
+// PlanUsage is the usage of the site against the limits of its plan.
+type PlanUsage struct {
+	Plan      *models.Plan             `json:"plan"` // Unset when the site has no plan, and no limits
+	Resources map[string]ResourceUsage `json:"resources"`
+}
+
+// PlanService manages the plans and enforces the limits of the plan of the
+// site. Limits are soft: content is never removed for being over a limit,
+// only new content is refused.
+type PlanService struct {
+	planRepo     *repositories.PlanRepository
+	settingsRepo *repositories.SettingsRepository
+	logger       *zap.Logger
+}
+
+// NewPlanService returns a new instance of PlanService with the provided
+// PlanRepository and SettingsRepository.
+func NewPlanService(planRepo *repositories.PlanRepository, settingsRepo *repositories.SettingsRepository, logger *zap.Logger) *PlanService {
+	return &PlanService{
+		planRepo:     planRepo,
+		settingsRepo: settingsRepo,
+		logger:       logger,
+	}
+}
+
+// ListPlans returns every plan.
+func (s *PlanService) ListPlans() ([]models.Plan, error) {
+	return s.planRepo.List()
+}
+
+// GetPlan returns a plan by its ID.
+func (s *PlanService) GetPlan(id uint) (*models.Plan, error) {
+	plan, err := s.planRepo.FindByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrPlanNotFound)
+	}
+	return plan, nil
+}
+
+// CreatePlan validates and stores a new plan, with the given fields. Limits
+// left unset are unlimited.
+func (s *PlanService) CreatePlan(fields PlanUpdate) (*models.Plan, error) {
+	plan := &models.Plan{}
+	fields.apply(plan)
+	if err := s.validatePlan(plan); err != nil {
+		return nil, err
+	}
+	if err := s.planRepo.Create(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// UpdatePlan applies the given changes to a plan and saves it. Lowering a
+// limit under the current usage is allowed: the usage is then reported as
+// exceeded.
+func (s *PlanService) UpdatePlan(id uint, update PlanUpdate) (*models.Plan, error) {
+	plan, err := s.GetPlan(id)
+	if err != nil {
+		return nil, err
+	}
+
+	update.apply(plan)
+	if err := s.validatePlan(plan); err != nil {
+		return nil, err
+	}
+	if err := s.planRepo.Update(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// DeletePlan removes a plan. The plan of the site can't be removed.
+func (s *PlanService) DeletePlan(id uint) error {
+	settings, err := s.settingsRepo.Get()
+	if err != nil {
+		return err
+	}
+	if settings.PlanID != nil && *settings.PlanID == id {
+		return ErrPlanInUse
+	}
+	return notFound(s.planRepo.Delete(id), ErrPlanNotFound)
+}
+
+// Usage returns the usage of the site against the limits of its plan.
+func (s *PlanService) Usage() (*PlanUsage, error) {
+	plan, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.planRepo.Usage()
+	if err != nil {
+		return nil, err
+	}
+
+	var limits models.Plan
+	if plan != nil {
+		limits = *plan
+	}
+	usage := &PlanUsage{Plan: plan, Resources: make(map[string]ResourceUsage)}
+	for resource, used := range map[string][2]int64{
+		QuotaPosts:    {counts.Posts, limits.MaxPosts},
+		QuotaComments: {counts.Comments, limits.MaxComments},
+		QuotaStorage:  {counts.StorageBytes, limits.MaxStorageBytes},
+		QuotaMembers:  {counts.Members, limits.MaxMembers},
+	} {
+		usage.Resources[resource] = ResourceUsage{
+			Used:     used[0],
+			Limit:    used[1],
+			Exceeded: used[1] > 0 && used[0] > used[1],
+		}
+	}
+	return usage, nil
+}
+
+// Check returns a QuotaExceededError when adding the given amount of a
+// resource, e.g. one post or the bytes of an upload, would take the site
+// over the limit of its plan.
+func (s *PlanService) Check(resource string, amount int64) error {
+	usage, err := s.Usage()
+	if err != nil {
+		return err
+	}
+	if usage.Plan == nil {
+		return nil
+	}
+
+	current := usage.Resources[resource]
+	if current.Limit > 0 && current.Used+amount > current.Limit {
+		s.logger.Info("Plan limit reached",
+			zap.String("plan", usage.Plan.Name),
+			zap.String("resource", resource),
+			zap.Int64("limit", current.Limit),
+		)
+		return &QuotaExceededError{Plan: usage.Plan.Name, Resource: resource, Limit: current.Limit}
+	}
+	return nil
+}
+
+// validatePlan validates the fields of a plan, and checks that its name
+// isn't the one of another plan.
+func (s *PlanService) validatePlan(plan *models.Plan) error {
+	if err := validateStruct(plan); err != nil {
+		return err
+	}
+	if plan.MaxPosts < 0 || plan.MaxComments < 0 || plan.MaxStorageBytes < 0 || plan.MaxMembers < 0 {
+		return invalid("limits must be positive, or 0 for unlimited")
+	}
+	if existing, err := s.planRepo.FindByName(plan.Name); err == nil && existing.ID != plan.ID {
+		return ErrPlanNameTaken
+	}
+	return nil
+}
+
+// current returns the plan of the site, nil when it has none.
+func (s *PlanService) current() (*models.Plan, error) {
+	settings, err := s.settingsRepo.Get()
+	if err != nil {
+		return nil, err
+	}
+	if settings.PlanID == nil {
+		return nil, nil
+	}
+	plan, err := s.planRepo.FindByID(*settings.PlanID)
+	if err != nil {
+		// The plan was removed behind our back, leave the site unlimited
+		return nil, notFound(err, nil)
+	}
+	return plan, nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SteaceP/coderage/models"
+)
+
+// ErrAuthorNotFound is returned when a user isn't credited as an author of a
+// post.
+var ErrAuthorNotFound = errors.New("author not found")
+
+// CreditAuthor credits a user as an author of a post, with the role of an
+// author or a contributor, or changes their role when they already are one.
+// It returns the post with its authors, and reports whether the user was
+// credited. Only the lead author of the post can credit its authors, and
+// their own role can't change.
+func (s *PostService) CreditAuthor(postID, userID uint, role string, editorID uint) (*models.Post, bool, error) {
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, false, notFound(err, ErrPostNotFound)
+	}
+	if post.UserID != editorID {
+		return nil, false, fmt.Errorf("%w: only the lead author can credit the authors of this post", ErrForbidden)
+	}
+	if userID == post.UserID {
+		return nil, false, invalid("the role of the lead author can't change")
+	}
+	if role != models.AuthorRoleAuthor && role != models.AuthorRoleContributor {
+		return nil, false, invalid("role must be author or contributor")
+	}
+	if _, err := s.userRepo.FindByIDLite(userID); err != nil {
+		return nil, false, notFound(err, ErrUserNotFound)
+	}
+
+	author := &models.PostAuthor{PostID: post.ID, UserID: userID, Role: role}
+	created, err := s.postRepo.SaveAuthor(author)
+	if err != nil {
+		return nil, false, err
+	}
+
+	post, err = s.postRepo.FindByID(post.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	return post, created, nil
+}
+
+// UncreditAuthor stops crediting a user as an author of a post, and returns
+// the post with its remaining authors. The lead author can uncredit the
+// others, and each author can uncredit themselves, but the lead author stays
+// credited.
+func (s *PostService) UncreditAuthor(postID, userID, editorID uint) (*models.Post, error) {
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, notFound(err, ErrPostNotFound)
+	}
+	if post.UserID != editorID && userID != editorID {
+		return nil, fmt.Errorf("%w: only the lead author can uncredit the authors of this post", ErrForbidden)
+	}
+	if userID == post.UserID {
+		return nil, invalid("the lead author can't be uncredited")
+	}
+
+	deleted, err := s.postRepo.DeleteAuthor(post.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !deleted {
+		return nil, ErrAuthorNotFound
+	}
+
+	post, err = s.postRepo.FindByID(post.ID)
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
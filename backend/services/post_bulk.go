@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/repositories"
+)
+
+// MaxBulkPosts is the maximum number of posts changed by a bulk operation.
+const MaxBulkPosts = 500
+
+// Results of the posts of a bulk operation
+const (
+	BulkUpdated  = "updated"
+	BulkDeleted  = "deleted"
+	BulkNotFound = "not_found"
+)
+
+// BulkPostChange is a change applied to many posts at once by an admin.
+type BulkPostChange struct {
+	PostIDs    []uint
+	Status     string   // New status of the posts, unchanged when empty
+	AddTags    []string // Names of the tags to add to the posts
+	RemoveTags []string // Names of the tags to remove from the posts
+	Delete     bool     // Delete the posts, which excludes the other changes
+}
+
+// BulkPostResult is the outcome of a bulk operation for one of its posts.
+type BulkPostResult struct {
+	PostID uint   `json:"post_id"`
+	Status string `json:"status"`
+}
+
+// BulkUpdate applies a change to many posts at once, whoever their authors
+// are. The posts found are changed in a single transaction, and the result
+// of every requested post is returned in the order of the request, the
+// missing ones being reported as not found.
+//
+// Posts published this way skip the checks of PublishPost.
+func (s *PostService) BulkUpdate(change BulkPostChange) ([]BulkPostResult, error) {
+	// Validate the change
+	if len(change.PostIDs) == 0 {
+		return nil, invalid("no post IDs given")
+	}
+	if len(change.PostIDs) > MaxBulkPosts {
+		return nil, invalid(fmt.Sprintf("at most %d posts can be changed at once", MaxBulkPosts))
+	}
+	switch change.Status {
+	case "", "draft", "published", "archived":
+	default:
+		return nil, invalid("status must be draft, published or archived")
+	}
+	edits := change.Status != "" || len(change.AddTags) > 0 || len(change.RemoveTags) > 0
+	if change.Delete && edits {
+		return nil, invalid("deleted posts can't be changed as well")
+	}
+	if !change.Delete && !edits {
+		return nil, invalid("no change given")
+	}
+
+	// Skip duplicates and the missing posts
+	ids := make([]uint, 0, len(change.PostIDs))
+	seen := make(map[uint]bool, len(change.PostIDs))
+	for _, id := range change.PostIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	posts, err := s.postRepo.FindByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[uint]bool, len(posts))
+	existing := make([]uint, len(posts))
+	for i, post := range posts {
+		found[post.ID] = true
+		existing[i] = post.ID
+	}
+
+	if len(existing) > 0 {
+		err := s.postRepo.Bulk(existing, repositories.BulkChange{
+			Status:     change.Status,
+			AddTags:    change.AddTags,
+			RemoveTags: change.RemoveTags,
+			Delete:     change.Delete,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Publish the events of the changed posts
+	ctx := context.Background()
+	for i := range posts {
+		post := &posts[i]
+		if change.Delete {
+			publish(ctx, s.bus, s.logger, events.PostDeleted, postPayload(post))
+			continue
+		}
+		wasPublished := post.Status == "published"
+		if change.Status != "" {
+			post.Status = change.Status
+		}
+		publish(ctx, s.bus, s.logger, events.PostUpdated, postPayload(post))
+		if post.Status == "published" && !wasPublished {
+			publish(ctx, s.bus, s.logger, events.PostPublished, postPayload(post))
+		}
+	}
+
+	results := make([]BulkPostResult, len(ids))
+	for i, id := range ids {
+		results[i] = BulkPostResult{PostID: id, Status: BulkNotFound}
+		if found[id] && change.Delete {
+			results[i].Status = BulkDeleted
+		} else if found[id] {
+			results[i].Status = BulkUpdated
+		}
+	}
+	return results, nil
+}
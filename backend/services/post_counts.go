@@ -0,0 +1,103 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+)
+
+// Modes of the total counts of the post listings
+const (
+	CountExact     = "exact"     // Counted on every listing
+	CountCached    = "cached"    // Counted once per "posts.counts.cache_ttl"
+	CountEstimated = "estimated" // Estimated by the database planner
+)
+
+// countCache keeps the total counts of the post listings, by filters, until
+// they expire. Each instance keeps its own.
+type countCache struct {
+	mu        sync.Mutex
+	totals    map[string]cachedCount
+	lastSweep time.Time
+}
+
+// cachedCount is the total count of a listing, cached until it expires.
+type cachedCount struct {
+	total   int64
+	expires time.Time
+}
+
+func newCountCache() *countCache {
+	return &countCache{totals: make(map[string]cachedCount), lastSweep: time.Now()}
+}
+
+// get returns the cached total count of the listing of a key.
+func (c *countCache) get(key string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.totals[key]
+	if !ok || time.Now().After(cached.expires) {
+		return 0, false
+	}
+	return cached.total, true
+}
+
+// set caches the total count of the listing of a key for ttl, and drops the
+// expired counts at most once per ttl.
+func (c *countCache) set(key string, total int64, ttl time.Duration) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastSweep) >= ttl {
+		for k, cached := range c.totals {
+			if now.After(cached.expires) {
+				delete(c.totals, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.totals[key] = cachedCount{total: total, expires: now.Add(ttl)}
+}
+
+// listApproximately returns a page of the posts matching the filters, with
+// their total count cached or estimated as set by "posts.counts.mode",
+// sparing the listings a full count of the posts. The estimate is raised to
+// the posts found up to the page when it falls short.
+func (s *PostService) listApproximately(page, pageSize int, filters map[string]interface{}, mode string) ([]models.Post, int64, error) {
+	uncounted := map[string]interface{}{"count": false}
+	for k, v := range filters {
+		uncounted[k] = v
+	}
+
+	switch mode {
+	case CountCached:
+		key := fmt.Sprint(filters)
+		if total, ok := s.counts.get(key); ok {
+			posts, _, err := s.postRepo.List(page, pageSize, uncounted)
+			return posts, total, err
+		}
+		posts, total, err := s.postRepo.List(page, pageSize, filters)
+		if err != nil {
+			return nil, 0, err
+		}
+		s.counts.set(key, total, config.Get().Posts.Counts.CacheTTL)
+		return posts, total, nil
+	case CountEstimated:
+		posts, _, err := s.postRepo.List(page, pageSize, uncounted)
+		if err != nil {
+			return nil, 0, err
+		}
+		total, err := s.postRepo.EstimateCount(filters)
+		if err != nil {
+			return nil, 0, err
+		}
+		if found := int64((page-1)*pageSize + len(posts)); total < found {
+			total = found
+		}
+		return posts, total, nil
+	}
+	return s.postRepo.List(page, pageSize, filters)
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/markdown"
+	"github.com/SteaceP/coderage/models"
+)
+
+// ErrAlreadyPublished is returned when publishing a published post.
+var ErrAlreadyPublished = errors.New("post is already published")
+
+// Severities of the lint checks, set per check in the "posts.lint" settings
+const (
+	LintOff      = "off"
+	LintAdvisory = "advisory"
+	LintBlocking = "blocking"
+)
+
+// Lint checks
+const (
+	LintFeaturedImage   = "featured_image"
+	LintExcerpt         = "excerpt"
+	LintInternalLinks   = "internal_links"
+	LintHeadings        = "headings"
+	LintMetaDescription = "meta_description"
+)
+
+// LintIssue is a problem found in a post when publishing it.
+type LintIssue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"` // error when it blocks the publication, warning otherwise
+	Message  string `json:"message"`
+}
+
+// LintError is returned when publishing a post with blocking issues. It holds
+// every issue found, the advisory ones included.
+type LintError struct {
+	Issues []LintIssue
+}
+
+func (e *LintError) Error() string {
+	return "post has issues blocking its publication"
+}
+
+// PublishPost publishes a post the editor is an author of, contributors
+// excluded, once it passes the blocking checks of the "posts.lint" settings.
+// The advisory issues found are returned with the published post.
+//
+// A LintError listing the issues is returned when one of them blocks the
+// publication, and the post stays as it was.
+func (s *PostService) PublishPost(postID, editorID uint) (*models.Post, []LintIssue, error) {
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, nil, notFound(err, ErrPostNotFound)
+	}
+	if post.AuthorRole(editorID) != models.AuthorRoleAuthor {
+		return nil, nil, fmt.Errorf("%w: only the authors can publish this post", ErrForbidden)
+	}
+	if post.Status == "published" {
+		return nil, nil, ErrAlreadyPublished
+	}
+
+	issues, err := s.LintPost(post)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, issue := range issues {
+		if issue.Severity == "error" {
+			return nil, issues, &LintError{Issues: issues}
+		}
+	}
+
+	status := "published"
+	post, err = s.UpdatePost(postID, editorID, PostUpdate{Status: &status})
+	if err != nil {
+		return nil, nil, err
+	}
+	return post, issues, nil
+}
+
+// LintPost runs the checks of the "posts.lint" settings on a post, and
+// returns the issues found, in the order of the checks.
+func (s *PostService) LintPost(post *models.Post) ([]LintIssue, error) {
+	cfg := config.Get().Posts.Lint
+	var issues []LintIssue
+	report := func(rule, severity, message string) {
+		if severity == LintOff || severity == "" {
+			return
+		}
+		level := "warning"
+		if severity == LintBlocking {
+			level = "error"
+		}
+		issues = append(issues, LintIssue{Rule: rule, Severity: level, Message: message})
+	}
+
+	if strings.TrimSpace(post.FeaturedImage) == "" {
+		report(LintFeaturedImage, cfg.FeaturedImage, "The post has no featured image")
+	}
+	if strings.TrimSpace(post.Excerpt) == "" {
+		report(LintExcerpt, cfg.Excerpt, "The post has no excerpt")
+	}
+	if length := utf8.RuneCountInString(post.MetaDescription); length > cfg.MetaDescriptionLength {
+		report(LintMetaDescription, cfg.MetaDescription, fmt.Sprintf("The meta description is %d characters long, search engines only show %d", length, cfg.MetaDescriptionLength))
+	}
+
+	// Only parse the content for the checks that are on
+	if cfg.Headings == LintOff && cfg.InternalLinks == LintOff {
+		return issues, nil
+	}
+	outline := markdown.Inspect(post.Content)
+
+	// The title is the heading of level 1
+	level := 1
+	for _, heading := range outline.Headings {
+		switch {
+		case heading.Level == 1:
+			report(LintHeadings, cfg.Headings, fmt.Sprintf("Heading %q is of level 1, the level of the title", heading.Text))
+		case heading.Level > level+1:
+			report(LintHeadings, cfg.Headings, fmt.Sprintf("Heading %q skips from level %d to %d", heading.Text, level, heading.Level))
+		}
+		level = heading.Level
+	}
+
+	if cfg.InternalLinks != LintOff {
+		broken, err := s.brokenPostLinks(outline.Links)
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range broken {
+			report(LintInternalLinks, cfg.InternalLinks, fmt.Sprintf("Link %s leads to no published post", link))
+		}
+	}
+	return issues, nil
+}
+
+// brokenPostLinks returns the links to posts of the site, among the given
+// ones, that lead to no published post.
+func (s *PostService) brokenPostLinks(links []string) ([]string, error) {
+	slugs := make(map[string][]string)
+	var ordered []string
+	for _, link := range links {
+		if slug, ok := sitePostSlug(link); ok {
+			if _, seen := slugs[slug]; !seen {
+				ordered = append(ordered, slug)
+			}
+			slugs[slug] = append(slugs[slug], link)
+		}
+	}
+	if len(ordered) == 0 {
+		return nil, nil
+	}
+
+	found, err := s.postRepo.FindPublishedSlugs(ordered)
+	if err != nil {
+		return nil, err
+	}
+	published := make(map[string]bool, len(found))
+	for _, slug := range found {
+		published[slug] = true
+	}
+
+	var broken []string
+	for _, slug := range ordered {
+		if !published[slug] {
+			broken = append(broken, slugs[slug]...)
+		}
+	}
+	return broken, nil
+}
+
+// sitePostSlug returns the slug of a link to a post of the site, either
+// absolute or relative to the site root.
+func sitePostSlug(link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+	site, err := url.Parse(config.Get().Site.URL)
+	if err != nil {
+		return "", false
+	}
+	if u.Host != "" && !strings.EqualFold(site.Host, u.Host) {
+		return "", false
+	}
+	if u.Host == "" && !strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+	slug, ok := strings.CutPrefix(u.Path, strings.TrimRight(site.Path, "/")+"/posts/")
+	if !ok || slug == "" || strings.Contains(slug, "/") {
+		return "", false
+	}
+	return slug, true
+}
@@ -1,36 +1,96 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/licenses"
+	"github.com/SteaceP/coderage/markdown"
 	"github.com/SteaceP/coderage/models"
-	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/search"
+	"github.com/SteaceP/coderage/spam"
+	"github.com/SteaceP/coderage/types"
+	"github.com/SteaceP/coderage/utils"
+
 	"go.uber.org/zap"
 )
 
+// PostUpdate holds the changes to apply to a post. Nil fields are left
+// unchanged.
+type PostUpdate struct {
+	Title           *string
+	Content         *string
+	Excerpt         *string
+	Status          *string
+	Tags            []models.Tag
+	FeaturedImage   *string
+	MetaTitle       *string
+	MetaDescription *string
+	Sensitive       *bool
+	License         *string
+	Locale          *string
+	CategoryID      *uint // 0 to file the post under no category
+	// EarlyAccess opens or closes the members-only window of the post,
+	// lasting EarlyAccessHours or the configured default when zero
+	EarlyAccess      *bool
+	EarlyAccessHours int
+}
+
+// maxEarlyAccessHours is the longest members-only window of a post
+const maxEarlyAccessHours = 30 * 24
+
 type PostService struct {
-	postRepo    *repositories.PostRepository
-	userRepo    *repositories.UserRepository
-	commentRepo *repositories.CommentRepository
+	postRepo    PostStore
+	userRepo    UserStore
+	commentRepo CommentStore
+	bus         events.Bus
+	engine      search.Engine // Ranks the searches when set
+	spam        spam.Checker  // Checks the new comments when set
+	counts      *countCache   // Total counts of the anonymous listings
 	logger      *zap.Logger
 }
 
+// CommentOrigin describes the request a comment was sent with, which the
+// spam checks weigh.
+type CommentOrigin struct {
+	IP        string
+	UserAgent string
+	Referrer  string
+}
+
 // NewPostService returns a new instance of PostService, which is used to manage the
 // lifecycle of posts.
 //
-// The returned instance is backed by the provided PostRepository, UserRepository,
-// CommentRepository, and logger.
+// The returned instance is backed by the provided post, user and comment
+// stores, usually the repositories of the same names, or fakes in the
+// tests, and logger. It publishes the post and comment events on the
+// provided bus, from which authors are notified of activity on their posts
+// and comments. Searches are ranked by the provided search engine, or
+// by the database when it is nil, and new comments are checked by the
+// provided spam checker, unless it is nil.
 func NewPostService(
-	postRepo *repositories.PostRepository,
-	userRepo *repositories.UserRepository,
-	commentRepo *repositories.CommentRepository,
+	postRepo PostStore,
+	userRepo UserStore,
+	commentRepo CommentStore,
+	bus events.Bus,
+	engine search.Engine,
+	checker spam.Checker,
 	logger *zap.Logger,
 ) *PostService {
 	return &PostService{
 		postRepo:    postRepo,
 		userRepo:    userRepo,
 		commentRepo: commentRepo,
+		bus:         bus,
+		engine:      engine,
+		spam:        checker,
+		counts:      newCountCache(),
 		logger:      logger,
 	}
 }
@@ -39,12 +99,15 @@ func NewPostService(
 //
 // It first validates the post's fields, and returns an error if any of them are
 // invalid. It then sets the post's published date to the current time if it is
-// zero. It also ensures that the post is associated with a valid user.
+// zero. It also ensures that the post is associated with a valid user, who is
+// allowed to publish posts.
 //
 // Finally, it creates the post in the database and returns an error if that
-// fails.
+// fails. A PostCreated event is published, followed by a PostPublished event
+// when the post isn't a draft.
 func (s *PostService) CreatePost(post *models.Post) error {
 	// Validate post
+	sanitizePost(post)
 	if err := validatePost(post); err != nil {
 		return err
 	}
@@ -55,12 +118,24 @@ func (s *PostService) CreatePost(post *models.Post) error {
 	}
 
 	// Ensure post is associated with a valid user
-	_, err := s.userRepo.FindByID(post.UserID)
+	author, err := s.userRepo.FindByIDLite(post.UserID)
 	if err != nil {
-		return errors.New("invalid user")
+		return notFound(err, ErrUserNotFound)
+	}
+	if author.Role != types.RoleAdmin {
+		return fmt.Errorf("%w: only admins can create posts", ErrForbidden)
 	}
 
-	return s.postRepo.Create(post)
+	if err := s.postRepo.Create(post); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	publish(ctx, s.bus, s.logger, events.PostCreated, postPayload(post))
+	if post.Status == "published" {
+		publish(ctx, s.bus, s.logger, events.PostPublished, postPayload(post))
+	}
+	return nil
 }
 
 // GetPost retrieves a post from the database using either its ID or slug.
@@ -72,10 +147,9 @@ func (s *PostService) CreatePost(post *models.Post) error {
 // If the identifier is not of a valid type (neither uint nor string), it returns an error
 // indicating the invalid identifier type.
 //
-// Upon successfully retrieving the post, it increments the post's view count. Any error
-// encountered during the increment of the view count is logged but does not affect the
-// retrieval process.
-func (s *PostService) GetPost(identifier interface{}) (*models.Post, error) {
+// Posts still in their members-only window are reported as not found unless
+// the viewer has early access. Views are counted by ViewService.Record.
+func (s *PostService) GetPost(identifier interface{}, viewerID uint) (*models.Post, error) {
 	var post *models.Post
 	var err error
 
@@ -89,25 +163,37 @@ func (s *PostService) GetPost(identifier interface{}) (*models.Post, error) {
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, ErrPostNotFound)
 	}
 
-	// Log any error from incrementing view count
-	if err := s.postRepo.IncrementViewCount(post.ID); err != nil {
-		s.logger.Error("Failed to increment view count",
-			zap.Uint("post_id", post.ID),
-			zap.Error(err),
-		)
+	// Posts in early access don't exist for other readers yet
+	if post.InEarlyAccess() && !s.HasEarlyAccess(viewerID) {
+		return nil, ErrPostNotFound
 	}
 
 	return post, nil
 }
 
+// FindPost retrieves a post by its ID without counting a view. Posts still in
+// their members-only window are reported as not found unless the viewer has
+// early access.
+func (s *PostService) FindPost(postID, viewerID uint) (*models.Post, error) {
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, notFound(err, ErrPostNotFound)
+	}
+	if post.InEarlyAccess() && !s.HasEarlyAccess(viewerID) {
+		return nil, ErrPostNotFound
+	}
+	return post, nil
+}
+
 // ListPosts retrieves posts with pagination and preload user
 //
 // It expects the following query parameters:
 // page: int - Page number to retrieve (default: 1)
 // limit: int - Number of posts to retrieve per page (default: 10, max: 100)
+// q: string - Text contained in the title or content of the posts
 //
 // The response will be a JSON object with the following structure:
 //
@@ -132,7 +218,15 @@ func (s *PostService) GetPost(identifier interface{}) (*models.Post, error) {
 //	        "total_pages": <total number of pages>
 //	    }
 //	}
-func (s *PostService) ListPosts(page, pageSize int, filters map[string]interface{}) ([]models.Post, int64, error) {
+//
+// Posts still in their members-only window are left out unless the viewer
+// has early access.
+//
+// Searches are ranked by relevance when a search engine is configured, the
+// database matching them while it fails. The total count of the listings of
+// anonymous readers is cached or estimated when "posts.counts.mode" is set
+// to do so; users get exact counts.
+func (s *PostService) ListPosts(page, pageSize int, filters map[string]interface{}, viewerID uint) ([]models.Post, int64, error) {
 	// Validate page and pageSize
 	if page < 1 {
 		page = 1
@@ -141,54 +235,243 @@ func (s *PostService) ListPosts(page, pageSize int, filters map[string]interface
 		pageSize = 10
 	}
 
+	// Leave out the posts in early access unless the viewer has access
+	if !s.HasEarlyAccess(viewerID) {
+		public := map[string]interface{}{"public_only": true}
+		for k, v := range filters {
+			public[k] = v
+		}
+		filters = public
+	}
+
+	if query, ok := filters["search"].(string); ok && query != "" && s.engine != nil {
+		posts, total, err := s.searchPosts(query, page, pageSize, filters)
+		if err == nil {
+			return posts, total, nil
+		}
+		s.logger.Warn("Search engine failed, searching the database", zap.Error(err))
+	}
+
+	if mode := config.Get().Posts.Counts.Mode; viewerID == 0 && mode != CountExact {
+		return s.listApproximately(page, pageSize, filters, mode)
+	}
 	return s.postRepo.List(page, pageSize, filters)
 }
 
-// UpdatePost updates a post in the database.
+// searchPosts returns a page of the posts found by the search engine for a
+// query, most relevant first, and their total count. Posts not matching the
+// other filters are left out.
+func (s *PostService) searchPosts(query string, page, pageSize int, filters map[string]interface{}) ([]models.Post, int64, error) {
+	ranked, err := s.engine.Search(context.Background(), query, config.Get().Search.Engine.MaxHits)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Keep the results matching the other filters, in their order
+	scoped := map[string]interface{}{"ids": ranked}
+	for k, v := range filters {
+		if k != "search" {
+			scoped[k] = v
+		}
+	}
+	matching, err := s.postRepo.MatchingIDs(scoped)
+	if err != nil {
+		return nil, 0, err
+	}
+	found := make(map[uint]bool, len(matching))
+	for _, id := range matching {
+		found[id] = true
+	}
+	ids := make([]uint, 0, len(matching))
+	for _, id := range ranked {
+		if found[id] {
+			ids = append(ids, id)
+			delete(found, id)
+		}
+	}
+
+	total := int64(len(ids))
+	start := (page - 1) * pageSize
+	if start >= len(ids) {
+		return []models.Post{}, total, nil
+	}
+	ids = ids[start:min(start+pageSize, len(ids))]
+	scoped = map[string]interface{}{"ids": ids}
+	if preload, ok := filters["preload"]; ok {
+		scoped["preload"] = preload
+	}
+	posts, _, err := s.postRepo.List(1, len(ids), scoped)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	position := make(map[uint]int, len(ids))
+	for i, id := range ids {
+		position[id] = i
+	}
+	sort.Slice(posts, func(i, j int) bool { return position[posts[i].ID] < position[posts[j].ID] })
+	return posts, total, nil
+}
+
+// PostsByAuthors retrieves the published posts of the given users, keyed by
+// user ID, most recent first, without their author and tags. Posts still in
+// their members-only window are left out unless the viewer has early access.
+func (s *PostService) PostsByAuthors(userIDs []uint, viewerID uint) (map[uint][]models.Post, error) {
+	posts, err := s.postRepo.FindPublishedByUserIDs(userIDs, !s.HasEarlyAccess(viewerID))
+	if err != nil {
+		return nil, err
+	}
+	byAuthor := make(map[uint][]models.Post)
+	for _, post := range posts {
+		byAuthor[post.UserID] = append(byAuthor[post.UserID], post)
+	}
+	return byAuthor, nil
+}
+
+// ArchiveMonth is a month of the archive, with the number of posts published
+// during it.
+type ArchiveMonth struct {
+	Month string `json:"month"` // YYYY-MM
+	Label string `json:"label"` // e.g. March 2024
+	Count int    `json:"count"`
+}
+
+// Archive groups the published posts by the month they were published in,
+// in the given time zone, most recent first. Dates are stored in UTC and
+// grouped here rather than by the database, whose time zone support varies.
 //
-// It first validates the post's fields, and returns an error if any of them are
-// invalid. It then ensures that the post exists in the database, and returns an
-// error if it does not. It then updates the post's fields and saves it back to the
-// database, returning an error if that fails.
-func (s *PostService) UpdatePost(post *models.Post) error {
-	// Validate post
-	if err := validatePost(post); err != nil {
-		return err
+// Posts still in their members-only window are left out unless the viewer
+// has early access.
+func (s *PostService) Archive(loc *time.Location, viewerID uint) ([]ArchiveMonth, error) {
+	dates, err := s.postRepo.PublishedDates(!s.HasEarlyAccess(viewerID))
+	if err != nil {
+		return nil, err
+	}
+
+	months := []ArchiveMonth{}
+	for _, date := range dates {
+		local := date.In(loc)
+		month := local.Format("2006-01")
+		if n := len(months); n > 0 && months[n-1].Month == month {
+			months[n-1].Count++
+			continue
+		}
+		months = append(months, ArchiveMonth{Month: month, Label: local.Format("January 2006"), Count: 1})
+	}
+	return months, nil
+}
+
+// MonthRange returns the start of a month given as YYYY-MM in a time zone,
+// and the start of the next one, in UTC as dates are stored.
+func MonthRange(month string, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", month, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, invalid("month must be in the YYYY-MM format")
 	}
+	return start.UTC(), start.AddDate(0, 1, 0).UTC(), nil
+}
 
+// ListPublishedPosts retrieves the slug and last update of every published
+// post, for indexes such as sitemaps.
+func (s *PostService) ListPublishedPosts() ([]models.Post, error) {
+	return s.postRepo.ListPublished()
+}
+
+// UpdatePost applies the given changes to a post and saves it.
+//
+// Only the authors of the post, contributors included, can update it. The
+// changes are validated against the resulting post, and the updated post is
+// returned. A PostUpdated event is published, followed by a PostPublished
+// event when a draft is published.
+func (s *PostService) UpdatePost(postID, editorID uint, update PostUpdate) (*models.Post, error) {
 	// Ensure the post exists
-	existingPost, err := s.postRepo.FindByID(post.ID)
+	post, err := s.postRepo.FindByID(postID)
 	if err != nil {
-		return errors.New("post not found")
+		return nil, notFound(err, ErrPostNotFound)
+	}
+
+	// Check if the user is one of the authors of the post
+	if post.AuthorRole(editorID) == "" {
+		return nil, fmt.Errorf("%w: only the authors can update this post", ErrForbidden)
 	}
 
 	// Update fields
-	existingPost.Title = post.Title
-	existingPost.Content = post.Content
-	existingPost.Excerpt = post.Excerpt
-	existingPost.Status = post.Status
-	existingPost.Tags = post.Tags
-	existingPost.FeaturedImage = post.FeaturedImage
-	existingPost.MetaTitle = post.MetaTitle
-	existingPost.MetaDescription = post.MetaDescription
+	wasPublished := post.Status == "published"
+	setString(&post.Title, update.Title)
+	setString(&post.Content, update.Content)
+	setString(&post.Excerpt, update.Excerpt)
+	setString(&post.Status, update.Status)
+	setString(&post.FeaturedImage, update.FeaturedImage)
+	setString(&post.MetaTitle, update.MetaTitle)
+	setString(&post.MetaDescription, update.MetaDescription)
+	setString(&post.License, update.License)
+	setString(&post.Locale, update.Locale)
+	if update.Sensitive != nil {
+		post.Sensitive = *update.Sensitive
+	}
+	if update.Tags != nil {
+		post.Tags = update.Tags
+	}
+	if update.CategoryID != nil {
+		post.CategoryID, post.Category = nil, nil
+		if *update.CategoryID != 0 {
+			categoryID := *update.CategoryID
+			post.CategoryID = &categoryID
+		}
+	}
+	if update.EarlyAccess != nil {
+		post.MembersOnlyUntil = nil
+		if *update.EarlyAccess {
+			until, err := EarlyAccessUntil(post.PublishedAt, update.EarlyAccessHours)
+			if err != nil {
+				return nil, err
+			}
+			post.MembersOnlyUntil = &until
+		}
+	}
+
+	// Validate post
+	sanitizePost(post)
+	if err := validatePost(post); err != nil {
+		return nil, err
+	}
+
+	if err := s.postRepo.Update(post); err != nil {
+		return nil, err
+	}
 
-	return s.postRepo.Update(existingPost)
+	ctx := context.Background()
+	publish(ctx, s.bus, s.logger, events.PostUpdated, postPayload(post))
+	if post.Status == "published" && !wasPublished {
+		publish(ctx, s.bus, s.logger, events.PostPublished, postPayload(post))
+	}
+	return post, nil
 }
 
 // DeletePost removes a post from the database by its ID.
 //
 // It verifies the existence of the post before attempting to delete it.
 // If the post is not found, it returns an error indicating that the post
-// was not found. If the post exists, it deletes the post and returns an
-// error if the deletion fails.
-func (s *PostService) DeletePost(postID uint) error {
+// was not found. Only the authors of the post can delete it, contributors
+// can't. If the post exists, it deletes the post and returns an error if the
+// deletion fails.
+func (s *PostService) DeletePost(postID, userID uint) error {
 	// Check if post exists
-	_, err := s.postRepo.FindByID(postID)
+	post, err := s.postRepo.FindByID(postID)
 	if err != nil {
-		return errors.New("post not found")
+		return notFound(err, ErrPostNotFound)
 	}
 
-	return s.postRepo.Delete(postID)
+	// Contributors can't delete the post
+	if post.AuthorRole(userID) != models.AuthorRoleAuthor {
+		return fmt.Errorf("%w: only the authors can delete this post", ErrForbidden)
+	}
+
+	if err := s.postRepo.Delete(postID); err != nil {
+		return err
+	}
+	publish(context.Background(), s.bus, s.logger, events.PostDeleted, postPayload(post))
+	return nil
 }
 
 // AddComment creates a new comment in the database.
@@ -197,26 +480,340 @@ func (s *PostService) DeletePost(postID uint) error {
 // invalid. It then ensures that the post exists in the database, and returns an
 // error if it does not. It then creates the comment in the database and returns an
 // error if that fails. Finally, it increments the post's comment count and returns
-// an error if that fails.
-func (s *PostService) AddComment(comment *models.Comment) error {
+// an error if that fails, and publishes a CommentCreated event, from which the
+// author of the post, or of the comment replied to, is notified.
+func (s *PostService) AddComment(comment *models.Comment, origin CommentOrigin) error {
+	return s.addComment(comment, origin, s.commentRepo.Create)
+}
+
+// addComment adds a comment as AddComment does, storing it with create.
+func (s *PostService) addComment(comment *models.Comment, origin CommentOrigin, create func(*models.Comment) error) error {
 	// Validate comment
+	comment.Content = utils.SanitizeText(comment.Content)
 	if err := validateComment(comment); err != nil {
 		return err
 	}
 
 	// Ensure post exists
-	_, err := s.postRepo.FindByID(comment.PostID)
+	post, err := s.postRepo.FindByID(comment.PostID)
 	if err != nil {
-		return errors.New("post not found")
+		return notFound(err, ErrPostNotFound)
+	}
+	author, err := s.userRepo.FindByIDLite(comment.UserID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+
+	// Replies must answer a visible comment of the same post, within the
+	// nesting limit
+	if comment.ParentID != nil {
+		parent, err := s.commentRepo.FindByID(*comment.ParentID)
+		if err != nil || !parent.Visible() {
+			return invalid("parent comment not found")
+		}
+		if parent.PostID != comment.PostID {
+			return invalid("parent comment must belong to the same post")
+		}
+		depth, err := s.commentDepth(parent)
+		if err != nil {
+			return err
+		}
+		if maxDepth := config.Get().Comments.MaxDepth; maxDepth > 0 && depth >= maxDepth {
+			return invalid(fmt.Sprintf("replies cannot be nested more than %d levels deep", maxDepth))
+		}
 	}
 
-	// Create comment
-	if err := s.commentRepo.Create(comment); err != nil {
+	// Create comment, hidden for review when it looks like spam
+	if comment.Status == "" {
+		comment.Status = models.CommentPublished
+	}
+	if comment.Status == models.CommentPublished {
+		s.checkSpam(comment, post, author, origin)
+	}
+	if err := create(comment); err != nil {
 		return err
 	}
 
-	// Update post comment count
-	return s.postRepo.UpdateCommentCount(comment.PostID, true)
+	// Update post comment count, which counts the visible comments
+	if comment.Visible() {
+		if err := s.postRepo.UpdateCommentCount(comment.PostID, true); err != nil {
+			return err
+		}
+	}
+
+	publish(context.Background(), s.bus, s.logger, events.CommentCreated, events.CommentPayload{
+		CommentID: comment.ID,
+		PostID:    comment.PostID,
+		UserID:    comment.UserID,
+		ParentID:  comment.ParentID,
+		Status:    comment.Status,
+	})
+
+	comment.User = *author
+	return nil
+}
+
+// checkSpam hides a comment suspected of spam, recording why so moderators
+// find it among the flagged comments. The comments of the moderators of the
+// post aren't checked, and comments are let through when the check fails.
+func (s *PostService) checkSpam(comment *models.Comment, post *models.Post, author *models.User, origin CommentOrigin) {
+	if s.spam == nil || s.canModerate(post, author.ID) {
+		return
+	}
+	verdict, err := s.spam.Check(context.Background(), spam.Comment{
+		Content:     comment.Content,
+		AuthorName:  author.Username,
+		AuthorEmail: author.Email,
+		IP:          origin.IP,
+		UserAgent:   origin.UserAgent,
+		Referrer:    origin.Referrer,
+		Permalink:   strings.TrimRight(config.Get().Site.URL, "/") + "/posts/" + post.Slug,
+		Reply:       comment.ParentID != nil,
+	})
+	if err != nil {
+		s.logger.Warn("Failed to check a comment for spam", zap.Uint("post_id", post.ID), zap.Error(err))
+		return
+	}
+	if verdict.Spam {
+		now := time.Now()
+		comment.Status = models.CommentHidden
+		comment.ModeratedAt = &now
+		comment.ModerationReason = "Suspected spam: " + verdict.Reason
+		s.logger.Info("Comment hidden as suspected spam",
+			zap.Uint("post_id", post.ID),
+			zap.Uint("user_id", author.ID),
+			zap.String("reason", verdict.Reason),
+		)
+	}
+}
+
+// ListComments retrieves the comments of a post with pagination. Hidden
+// comments are only listed for moderators of the post.
+//
+// When viewerID is set, the comments liked by that user are marked as such.
+func (s *PostService) ListComments(postID uint, page, pageSize int, viewerID uint) ([]models.Comment, int64, error) {
+	// Validate page and pageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	// Ensure post exists
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, 0, notFound(err, ErrPostNotFound)
+	}
+
+	// Moderators also see the hidden comments
+	includeHidden := viewerID != 0 && s.canModerate(post, viewerID)
+	comments, total, err := s.commentRepo.FindByPostID(postID, includeHidden, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.markLiked(comments, viewerID); err != nil {
+		return nil, 0, err
+	}
+	return comments, total, nil
+}
+
+// CommentsByPosts retrieves the visible comments of the given posts, replies
+// included, keyed by post ID, oldest first, without their author. Hidden
+// comments are left out whoever the viewer is.
+func (s *PostService) CommentsByPosts(postIDs []uint) (map[uint][]models.Comment, error) {
+	comments, err := s.commentRepo.FindByPostIDs(postIDs, false)
+	if err != nil {
+		return nil, err
+	}
+	byPost := make(map[uint][]models.Comment)
+	for _, comment := range comments {
+		byPost[comment.PostID] = append(byPost[comment.PostID], comment)
+	}
+	return byPost, nil
+}
+
+// ListCommentThreads retrieves the top-level comments of a post with
+// pagination, each with its replies nested at every depth. Hidden comments
+// are only listed for moderators of the post, and so are their replies.
+//
+// When viewerID is set, the comments liked by that user are marked as such.
+func (s *PostService) ListCommentThreads(postID uint, page, pageSize int, viewerID uint) ([]models.Comment, int64, error) {
+	// Validate page and pageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	// Ensure post exists
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, 0, notFound(err, ErrPostNotFound)
+	}
+
+	includeHidden := viewerID != 0 && s.canModerate(post, viewerID)
+	threads, total, err := s.commentRepo.FindThreads(postID, includeHidden, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	replies, err := s.commentRepo.FindPostReplies(postID, includeHidden)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Mark the likes before nesting, which copies the replies
+	if err := s.markLiked(threads, viewerID); err != nil {
+		return nil, 0, err
+	}
+	if err := s.markLiked(replies, viewerID); err != nil {
+		return nil, 0, err
+	}
+
+	children := make(map[uint][]models.Comment)
+	for _, reply := range replies {
+		children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+	}
+	for i := range threads {
+		nestReplies(&threads[i], children)
+	}
+	return threads, total, nil
+}
+
+// ListReplies retrieves the direct replies to a comment. Hidden replies are
+// only listed for moderators of the post.
+//
+// When viewerID is set, the replies liked by that user are marked as such.
+func (s *PostService) ListReplies(commentID, viewerID uint) ([]models.Comment, error) {
+	comment, err := s.commentRepo.FindByID(commentID)
+	if err != nil {
+		return nil, notFound(err, ErrCommentNotFound)
+	}
+	post, err := s.postRepo.FindByID(comment.PostID)
+	if err != nil {
+		return nil, notFound(err, ErrPostNotFound)
+	}
+
+	includeHidden := viewerID != 0 && s.canModerate(post, viewerID)
+	if comment.Status == models.CommentDeleted || (!comment.Visible() && !includeHidden) {
+		return nil, ErrCommentNotFound
+	}
+
+	replies, err := s.commentRepo.FindReplies(comment.ID, includeHidden)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.markLiked(replies, viewerID); err != nil {
+		return nil, err
+	}
+	return replies, nil
+}
+
+// markLiked marks the comments liked by the viewer, if any.
+func (s *PostService) markLiked(comments []models.Comment, viewerID uint) error {
+	if viewerID == 0 || len(comments) == 0 {
+		return nil
+	}
+
+	commentIDs := make([]uint, len(comments))
+	for i, comment := range comments {
+		commentIDs[i] = comment.ID
+	}
+
+	liked, err := s.commentRepo.LikedCommentIDs(viewerID, commentIDs)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Liked = liked[comments[i].ID]
+	}
+	return nil
+}
+
+// nestReplies attaches the replies to a comment, recursively, from the
+// replies grouped by parent ID.
+func nestReplies(comment *models.Comment, children map[uint][]models.Comment) {
+	comment.Replies = children[comment.ID]
+	for i := range comment.Replies {
+		nestReplies(&comment.Replies[i], children)
+	}
+}
+
+// commentDepth returns the nesting depth of a comment, 1 for top-level
+// comments.
+func (s *PostService) commentDepth(comment *models.Comment) (int, error) {
+	depth := 1
+	for comment.ParentID != nil {
+		parent, err := s.commentRepo.FindByID(*comment.ParentID)
+		if err != nil {
+			return 0, err
+		}
+		comment = parent
+		depth++
+	}
+	return depth, nil
+}
+
+// LikeComment records a like from the user on a comment, and returns the
+// comment with its updated like count.
+//
+// A CommentLiked event is published, from which the author of the comment is
+// notified.
+//
+// It returns repositories.ErrAlreadyLiked if the user already liked it.
+func (s *PostService) LikeComment(commentID, userID uint) (*models.Comment, error) {
+	comment, err := s.commentRepo.FindByID(commentID)
+	if err != nil {
+		return nil, notFound(err, ErrCommentNotFound)
+	}
+
+	if err := s.commentRepo.Like(comment.ID, userID); err != nil {
+		return nil, err
+	}
+	publish(context.Background(), s.bus, s.logger, events.CommentLiked, events.CommentLikePayload{
+		CommentID: comment.ID,
+		PostID:    comment.PostID,
+		AuthorID:  comment.UserID,
+		UserID:    userID,
+	})
+
+	comment.LikeCount++
+	comment.Liked = true
+	return comment, nil
+}
+
+// UnlikeComment removes the user's like from a comment, and returns the
+// comment with its updated like count.
+//
+// It returns repositories.ErrNotLiked if the user had not liked it.
+func (s *PostService) UnlikeComment(commentID, userID uint) (*models.Comment, error) {
+	comment, err := s.commentRepo.FindByID(commentID)
+	if err != nil {
+		return nil, notFound(err, ErrCommentNotFound)
+	}
+
+	if err := s.commentRepo.Unlike(comment.ID, userID); err != nil {
+		return nil, err
+	}
+
+	comment.LikeCount--
+	comment.Liked = false
+	return comment, nil
+}
+
+// sanitizePost strips the markup that could run scripts from the fields of a
+// post before it is stored: the raw HTML of its Markdown content is
+// sanitized as it is when rendered, and its plain text fields lose all
+// markup.
+func sanitizePost(post *models.Post) {
+	post.Content = markdown.Sanitize(post.Content)
+	post.Title = utils.SanitizeText(post.Title)
+	post.Excerpt = utils.SanitizeText(post.Excerpt)
+	post.MetaTitle = utils.SanitizeText(post.MetaTitle)
+	post.MetaDescription = utils.SanitizeText(post.MetaDescription)
+	post.Locale = canonicalLocale(post.Locale)
 }
 
 // validatePost validates a post's fields, and returns an error if any of them
@@ -226,22 +823,172 @@ func (s *PostService) AddComment(comment *models.Comment) error {
 //
 // - The title and content are required.
 // - The title must be between 5 and 200 characters long.
+// - The license, if set, must be supported.
+// - The locale, if set, must be a valid language tag.
 func validatePost(post *models.Post) error {
 	if post.Title == "" {
-		return errors.New("title is required")
+		return invalid("title is required")
 	}
 
 	if post.Content == "" {
-		return errors.New("content is required")
+		return invalid("content is required")
 	}
 
 	if len(post.Title) < 5 || len(post.Title) > 200 {
-		return errors.New("title must be between 5 and 200 characters")
+		return invalid("title must be between 5 and 200 characters")
+	}
+
+	if post.License != "" && !licenses.Valid(post.License) {
+		return invalid("unsupported license")
+	}
+
+	if post.Locale != "" && !validLocale(post.Locale) {
+		return invalid("locale must be a language tag such as en or pt-BR")
+	}
+
+	return nil
+}
+
+// HasEarlyAccess reports whether a user can see posts during their
+// members-only window. Members and admins can.
+func (s *PostService) HasEarlyAccess(userID uint) bool {
+	if userID == 0 {
+		return false
+	}
+	user, err := s.userRepo.FindAccess(userID)
+	return err == nil && (user.Member || user.Role == types.RoleAdmin)
+}
+
+// EarlyAccessUntil returns the end of the members-only window of a post
+// published at the given time. The window lasts the given number of hours,
+// or "posts.early_access_window" when zero.
+func EarlyAccessUntil(publishedAt time.Time, hours int) (time.Time, error) {
+	if hours < 0 || hours > maxEarlyAccessHours {
+		return time.Time{}, invalid(fmt.Sprintf("early access must last between 0 and %d hours", maxEarlyAccessHours))
+	}
+
+	window := time.Duration(hours) * time.Hour
+	if hours == 0 {
+		window = config.Get().Posts.EarlyAccessWindow
+	}
+	if publishedAt.IsZero() {
+		publishedAt = time.Now()
+	}
+	return publishedAt.Add(window), nil
+}
+
+// ModerateComment sets the status of a comment and returns the updated
+// comment. Admins can moderate every comment, and authors the comments on
+// their posts.
+func (s *PostService) ModerateComment(commentID, moderatorID uint, status, reason string) (*models.Comment, error) {
+	if status != models.CommentPublished && status != models.CommentHidden && status != models.CommentDeleted {
+		return nil, invalid("status must be one of published, hidden or deleted")
+	}
+	if len(reason) > 255 {
+		return nil, invalid("reason cannot exceed 255 characters")
+	}
+
+	comment, err := s.commentRepo.FindByID(commentID)
+	if err != nil {
+		return nil, notFound(err, ErrCommentNotFound)
+	}
+	post, err := s.postRepo.FindByID(comment.PostID)
+	if err != nil {
+		return nil, notFound(err, ErrPostNotFound)
 	}
+	if !s.canModerate(post, moderatorID) {
+		return nil, fmt.Errorf("%w: only admins and the author of the post can moderate its comments", ErrForbidden)
+	}
+
+	wasVisible := comment.Visible()
+	if err := s.commentRepo.UpdateStatus(comment.ID, status, &moderatorID, reason); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	comment.Status = status
+	comment.ModeratedByID = &moderatorID
+	comment.ModeratedAt = &now
+	comment.ModerationReason = reason
+	comment.ReportCount = 0
+
+	// Keep the comment count of the post in line with the visible comments
+	if wasVisible != comment.Visible() {
+		if err := s.postRepo.UpdateCommentCount(post.ID, comment.Visible()); err != nil {
+			return nil, err
+		}
+	}
+	return comment, nil
+}
 
+// ListModerationQueue retrieves the comments awaiting review, with
+// pagination: hidden comments and comments with open reports.
+func (s *PostService) ListModerationQueue(page, pageSize int) ([]models.Comment, int64, error) {
+	return s.commentRepo.FindFlagged(page, pageSize)
+}
+
+// ReportComment records a report of a comment by a user. The comment is
+// hidden once its open reports reach "moderation.report_threshold", until a
+// moderator reviews it.
+//
+// It returns repositories.ErrAlreadyReported if the user already reported it.
+func (s *PostService) ReportComment(commentID, userID uint, reason, details string) error {
+	switch reason {
+	case models.ReportSpam, models.ReportHarassment, models.ReportHate, models.ReportOffTopic, models.ReportOther:
+	default:
+		return invalid("reason must be one of spam, harassment, hate_speech, off_topic or other")
+	}
+	if len(details) > 500 {
+		return invalid("details cannot exceed 500 characters")
+	}
+
+	comment, err := s.commentRepo.FindByID(commentID)
+	if err != nil {
+		return notFound(err, ErrCommentNotFound)
+	}
+	if comment.Status == models.CommentDeleted {
+		return ErrCommentNotFound
+	}
+	if comment.UserID == userID {
+		return invalid("you cannot report your own comment")
+	}
+
+	reportCount, err := s.commentRepo.Report(&models.CommentReport{
+		CommentID: comment.ID,
+		UserID:    userID,
+		Reason:    reason,
+		Details:   details,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Hide the comment until a moderator reviews it
+	threshold := config.Get().Moderation.ReportThreshold
+	if threshold > 0 && reportCount >= threshold && comment.Status == models.CommentPublished {
+		reason := fmt.Sprintf("Hidden automatically after %d reports", reportCount)
+		if err := s.commentRepo.UpdateStatus(comment.ID, models.CommentHidden, nil, reason); err != nil {
+			return err
+		}
+		if err := s.postRepo.UpdateCommentCount(comment.PostID, false); err != nil {
+			return err
+		}
+		s.logger.Info("Comment hidden after reports",
+			zap.Uint("comment_id", comment.ID),
+			zap.Int("reports", reportCount),
+		)
+	}
 	return nil
 }
 
+// canModerate reports whether a user can moderate the comments of a post.
+func (s *PostService) canModerate(post *models.Post, userID uint) bool {
+	if post.UserID == userID {
+		return true
+	}
+	role, err := s.userRepo.FindRole(userID)
+	return err == nil && role == types.RoleAdmin
+}
+
 // validateComment validates a comment's fields, and returns an error if any of them
 // are invalid.
 //
@@ -251,12 +998,19 @@ func validatePost(post *models.Post) error {
 // - The comment must be max 500 characters.
 func validateComment(comment *models.Comment) error {
 	if comment.Content == "" {
-		return errors.New("comment content is required")
+		return invalid("comment content is required")
 	}
 
 	if len(comment.Content) > 500 {
-		return errors.New("comment must be max 500 characters")
+		return invalid("comment must be max 500 characters")
 	}
 
 	return nil
 }
+
+// setString sets a field to the given value, unless the value is nil.
+func setString(field *string, value *string) {
+	if value != nil {
+		*field = *value
+	}
+}
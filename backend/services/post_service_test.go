@@ -0,0 +1,183 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/types"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Users of the post service tests
+const (
+	testAdminID uint = iota + 1
+	testReaderID
+	testMemberID
+)
+
+// newTestPostService returns a PostService running on fake stores, with an
+// admin, a reader and a member, and comments nested at most maxDepth deep.
+func newTestPostService(t *testing.T, maxDepth int) (*PostService, *fakePostStore, *fakeCommentStore) {
+	t.Helper()
+	previous := config.Get()
+	cfg := &config.Config{}
+	cfg.Comments.MaxDepth = maxDepth
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(previous) })
+
+	users := fakeUserStore{
+		testAdminID:  {Model: gorm.Model{ID: testAdminID}, Username: "admin", Role: types.RoleAdmin},
+		testReaderID: {Model: gorm.Model{ID: testReaderID}, Username: "reader", Role: types.RoleUser},
+		testMemberID: {Model: gorm.Model{ID: testMemberID}, Username: "member", Role: types.RoleUser, Member: true},
+	}
+	posts, comments := newFakePostStore(), newFakeCommentStore()
+	return NewPostService(posts, users, comments, events.Discard, nil, nil, zap.NewNop()), posts, comments
+}
+
+// createTestPost creates a published post of the admin.
+func createTestPost(t *testing.T, s *PostService) *models.Post {
+	t.Helper()
+	post := &models.Post{Title: "A test post", Content: "Some content", UserID: testAdminID, Status: "published"}
+	if err := s.CreatePost(post); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	return post
+}
+
+func TestCreatePost(t *testing.T) {
+	tests := []struct {
+		name   string
+		post   models.Post
+		target error
+	}{
+		{"admin", models.Post{Title: "A test post", Content: "Some content", UserID: testAdminID}, nil},
+		{"reader", models.Post{Title: "A test post", Content: "Some content", UserID: testReaderID}, ErrForbidden},
+		{"unknown author", models.Post{Title: "A test post", Content: "Some content", UserID: 42}, ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, posts, _ := newTestPostService(t, 0)
+			err := s.CreatePost(&tt.post)
+			if !errors.Is(err, tt.target) {
+				t.Fatalf("CreatePost returned %v, want %v", err, tt.target)
+			}
+			if stored := len(posts.all()); (tt.target == nil) != (stored == 1) {
+				t.Fatalf("%d posts stored", stored)
+			}
+		})
+	}
+
+	t.Run("short title", func(t *testing.T) {
+		s, _, _ := newTestPostService(t, 0)
+		var validationErr *ValidationError
+		err := s.CreatePost(&models.Post{Title: "Tiny", Content: "Some content", UserID: testAdminID})
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("CreatePost returned %v, want a validation error", err)
+		}
+	})
+}
+
+func TestAddComment(t *testing.T) {
+	s, posts, _ := newTestPostService(t, 2)
+	post := createTestPost(t, s)
+
+	comment := &models.Comment{Content: "First", UserID: testReaderID, PostID: post.ID}
+	if err := s.AddComment(comment, CommentOrigin{}); err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	if comment.Status != models.CommentPublished {
+		t.Errorf("comment status is %q, want %q", comment.Status, models.CommentPublished)
+	}
+	reply := &models.Comment{Content: "Reply", UserID: testAdminID, PostID: post.ID, ParentID: &comment.ID}
+	if err := s.AddComment(reply, CommentOrigin{}); err != nil {
+		t.Fatalf("AddComment reply: %v", err)
+	}
+	stored, _ := posts.FindByID(post.ID)
+	if stored.CommentCount != 2 {
+		t.Errorf("comment count is %d, want 2", stored.CommentCount)
+	}
+
+	// Replies can't be nested deeper than the limit, nor answer another post
+	other := &models.Post{Title: "Another test post", Content: "Some content", UserID: testAdminID, Status: "published"}
+	if err := s.CreatePost(other); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	for name, c := range map[string]*models.Comment{
+		"too deep":     {Content: "Nested", UserID: testReaderID, PostID: post.ID, ParentID: &reply.ID},
+		"other post":   {Content: "Elsewhere", UserID: testReaderID, PostID: other.ID, ParentID: &comment.ID},
+		"empty":        {Content: "", UserID: testReaderID, PostID: post.ID},
+		"unknown post": {Content: "Lost", UserID: testReaderID, PostID: 42},
+	} {
+		if err := s.AddComment(c, CommentOrigin{}); err == nil {
+			t.Errorf("%s: AddComment succeeded", name)
+		}
+	}
+	stored, _ = posts.FindByID(post.ID)
+	if stored.CommentCount != 2 {
+		t.Errorf("comment count is %d after the rejected comments, want 2", stored.CommentCount)
+	}
+}
+
+func TestLikeComment(t *testing.T) {
+	s, _, _ := newTestPostService(t, 0)
+	post := createTestPost(t, s)
+	comment := &models.Comment{Content: "Like me", UserID: testAdminID, PostID: post.ID}
+	if err := s.AddComment(comment, CommentOrigin{}); err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+
+	liked, err := s.LikeComment(comment.ID, testReaderID)
+	if err != nil {
+		t.Fatalf("LikeComment: %v", err)
+	}
+	if liked.LikeCount != 1 || !liked.Liked {
+		t.Errorf("liked comment has %d likes, liked %v", liked.LikeCount, liked.Liked)
+	}
+	if _, err := s.LikeComment(comment.ID, testReaderID); err == nil {
+		t.Error("liking a comment twice succeeded")
+	}
+}
+
+func TestDeletePost(t *testing.T) {
+	s, posts, _ := newTestPostService(t, 0)
+	post := createTestPost(t, s)
+	if _, err := posts.SaveAuthor(&models.PostAuthor{PostID: post.ID, UserID: testReaderID, Role: models.AuthorRoleContributor}); err != nil {
+		t.Fatalf("SaveAuthor: %v", err)
+	}
+
+	if err := s.DeletePost(post.ID, testReaderID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("DeletePost by a contributor returned %v, want %v", err, ErrForbidden)
+	}
+	if err := s.DeletePost(post.ID, testAdminID); err != nil {
+		t.Fatalf("DeletePost: %v", err)
+	}
+	if _, err := s.GetPost(post.ID, testAdminID); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("GetPost of a deleted post returned %v, want %v", err, ErrPostNotFound)
+	}
+}
+
+func TestGetPostInEarlyAccess(t *testing.T) {
+	s, posts, _ := newTestPostService(t, 0)
+	post := createTestPost(t, s)
+	until := time.Now().Add(time.Hour)
+	post.MembersOnlyUntil = &until
+	if err := posts.Update(post); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	for viewerID, visible := range map[uint]bool{0: false, testReaderID: false, testMemberID: true, testAdminID: true} {
+		_, err := s.GetPost(post.Slug, viewerID)
+		if visible && err != nil {
+			t.Errorf("viewer %d: GetPost returned %v", viewerID, err)
+		}
+		if !visible && !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("viewer %d: GetPost returned %v, want %v", viewerID, err, ErrPostNotFound)
+		}
+	}
+}
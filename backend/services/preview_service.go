@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/unfurl"
+
+	"go.uber.org/zap"
+)
+
+// Errors returned by the preview service
+var (
+	ErrPreviewNotFound    = errors.New("preview not found")
+	ErrPreviewUnavailable = errors.New("preview unavailable")
+)
+
+// maxPreviewURLLength is the length of the longest URL that can be previewed
+const maxPreviewURLLength = 2048
+
+type PreviewService struct {
+	previewRepo *repositories.LinkPreviewRepository
+	fetcher     *unfurl.Fetcher
+	logger      *zap.Logger
+}
+
+// NewPreviewService returns a new instance of PreviewService with the provided
+// LinkPreviewRepository, fetching pages with fetcher.
+func NewPreviewService(previewRepo *repositories.LinkPreviewRepository, fetcher *unfurl.Fetcher, logger *zap.Logger) *PreviewService {
+	return &PreviewService{
+		previewRepo: previewRepo,
+		fetcher:     fetcher,
+		logger:      logger,
+	}
+}
+
+// Preview returns the preview of the page at rawURL. Previews are cached for
+// "unfurl.cache_ttl" and failed fetches for "unfurl.error_cache_ttl". When
+// fetch is false, only a cached preview is returned.
+func (s *PreviewService) Preview(ctx context.Context, rawURL string, fetch bool) (*models.LinkPreview, error) {
+	normalized, err := normalizePreviewURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	hash := hex.EncodeToString(sum[:])
+
+	cached, err := s.previewRepo.FindByURLHash(hash)
+	if err = notFound(err, ErrPreviewNotFound); err != nil && err != ErrPreviewNotFound {
+		return nil, err
+	}
+	if cached != nil && s.fresh(cached) {
+		if cached.Error != "" {
+			return nil, ErrPreviewUnavailable
+		}
+		return cached, nil
+	}
+	if !fetch {
+		return nil, ErrPreviewNotFound
+	}
+
+	preview := &models.LinkPreview{URLHash: hash, URL: normalized, FetchedAt: time.Now()}
+	metadata, err := s.fetcher.Fetch(ctx, normalized)
+	if err != nil {
+		s.logger.Info("Link preview failed", zap.String("url", normalized), zap.Error(err))
+		preview.Error = err.Error()
+		if len(preview.Error) > 255 {
+			preview.Error = preview.Error[:255]
+		}
+	} else {
+		preview.URL = metadata.URL
+		preview.Title = metadata.Title
+		preview.Description = metadata.Description
+		preview.Image = metadata.Image
+		preview.SiteName = metadata.SiteName
+		preview.Type = metadata.Type
+	}
+
+	if err := s.previewRepo.Save(preview); err != nil {
+		return nil, err
+	}
+	if preview.Error != "" {
+		return nil, ErrPreviewUnavailable
+	}
+	return preview, nil
+}
+
+// fresh reports whether a cached preview can still be served.
+func (s *PreviewService) fresh(preview *models.LinkPreview) bool {
+	ttl := config.Get().Unfurl.CacheTTL
+	if preview.Error != "" {
+		ttl = config.Get().Unfurl.ErrorCacheTTL
+	}
+	return time.Since(preview.FetchedAt) < ttl
+}
+
+// normalizePreviewURL validates a URL to preview and returns it without its
+// fragment and with a lowercase host, so variants share a cached preview.
+func normalizePreviewURL(rawURL string) (string, error) {
+	if rawURL == "" {
+		return "", invalid("url is required")
+	}
+	if len(rawURL) > maxPreviewURLLength {
+		return "", invalid("url is too long")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", invalid("url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return "", invalid("url must not contain credentials")
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String(), nil
+}
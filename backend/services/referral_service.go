@@ -0,0 +1,193 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/utils"
+
+	"go.uber.org/zap"
+)
+
+// ErrReferralsDisabled is returned when the referral program is turned off.
+var ErrReferralsDisabled = errors.New("referral program is disabled")
+
+// BadgeReferrer is the badge earned by users who referred enough users
+const BadgeReferrer = "referrer"
+
+// ReferralSummary describes the referrals of a user and the perks they earned.
+type ReferralSummary struct {
+	Code     string `json:"code"`
+	Total    int64  `json:"total"`
+	Verified int64  `json:"verified"` // Referred users who verified their email address
+	Perks    Perks  `json:"perks"`
+}
+
+// Perks are the advantages granted to a user for their referrals.
+type Perks struct {
+	Badges      []string `json:"badges"`
+	UploadBonus int64    `json:"upload_bonus"` // Extra files allowed by the daily upload quota
+}
+
+type ReferralService struct {
+	referralRepo *repositories.ReferralRepository
+	userRepo     *repositories.UserRepository
+	settingsRepo *repositories.SettingsRepository
+	logger       *zap.Logger
+}
+
+// NewReferralService returns a new instance of ReferralService with the
+// provided ReferralRepository, UserRepository and SettingsRepository.
+func NewReferralService(referralRepo *repositories.ReferralRepository, userRepo *repositories.UserRepository, settingsRepo *repositories.SettingsRepository, logger *zap.Logger) *ReferralService {
+	return &ReferralService{
+		referralRepo: referralRepo,
+		userRepo:     userRepo,
+		settingsRepo: settingsRepo,
+		logger:       logger,
+	}
+}
+
+// enabled reports whether the referral program is turned on in the site
+// settings.
+func (s *ReferralService) enabled() (bool, error) {
+	settings, err := s.settingsRepo.Get()
+	if err != nil {
+		return false, err
+	}
+	return settings.ReferralsEnabled, nil
+}
+
+// Code returns the referral code of a user, generating it the first time.
+func (s *ReferralService) Code(userID uint) (string, error) {
+	code, err := s.userRepo.FindReferralCode(userID)
+	if err != nil {
+		return "", notFound(err, ErrUserNotFound)
+	}
+	if code != nil {
+		return *code, nil
+	}
+
+	// Codes are short, so retry on the unlikely collision
+	var lastErr error
+	for attempt := 0; attempt < 3; attempt++ {
+		token, err := utils.GenerateRandomToken(4)
+		if err != nil {
+			return "", err
+		}
+		generated := strings.ToUpper(token)
+
+		stored, err := s.userRepo.SetReferralCode(userID, generated)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if !stored {
+			// Generated concurrently by another request
+			return s.Code(userID)
+		}
+		return generated, nil
+	}
+	return "", lastErr
+}
+
+// Attribute records that a new user signed up with a referral code. Unknown
+// codes are ignored so they never prevent a sign-up.
+func (s *ReferralService) Attribute(referredID uint, code string) error {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return nil
+	}
+	if enabled, err := s.enabled(); err != nil || !enabled {
+		return err
+	}
+
+	referrer, err := s.userRepo.FindByReferralCode(code)
+	if err != nil {
+		s.logger.Info("Unknown referral code", zap.String("code", code), zap.Uint("user_id", referredID))
+		return nil
+	}
+	if referrer.ID == referredID {
+		return nil
+	}
+
+	err = s.referralRepo.Create(&models.Referral{
+		ReferrerID: referrer.ID,
+		ReferredID: referredID,
+		Code:       code,
+	})
+	if err != nil {
+		s.logger.Error("Failed to record referral",
+			zap.Uint("referrer_id", referrer.ID),
+			zap.Uint("referred_id", referredID),
+			zap.Error(err),
+		)
+	}
+	return err
+}
+
+// GetSummary returns the referral code of a user, the number of users they
+// referred and the perks they earned.
+func (s *ReferralService) GetSummary(userID uint) (*ReferralSummary, error) {
+	if enabled, err := s.enabled(); err != nil {
+		return nil, err
+	} else if !enabled {
+		return nil, ErrReferralsDisabled
+	}
+
+	code, err := s.Code(userID)
+	if err != nil {
+		return nil, err
+	}
+	total, verified, err := s.referralRepo.CountByReferrer(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReferralSummary{
+		Code:     code,
+		Total:    total,
+		Verified: verified,
+		Perks:    perksFor(verified),
+	}, nil
+}
+
+// ListReferrals retrieves the users referred by a user, with pagination.
+func (s *ReferralService) ListReferrals(userID uint, page, pageSize int) ([]models.Referral, int64, error) {
+	if enabled, err := s.enabled(); err != nil {
+		return nil, 0, err
+	} else if !enabled {
+		return nil, 0, ErrReferralsDisabled
+	}
+	return s.referralRepo.ListByReferrer(userID, page, pageSize)
+}
+
+// GetPerks returns the perks earned by a user for their referrals. No perks
+// are granted while the referral program is turned off.
+func (s *ReferralService) GetPerks(userID uint) (Perks, error) {
+	if enabled, err := s.enabled(); err != nil || !enabled {
+		return perksFor(0), err
+	}
+	_, verified, err := s.referralRepo.CountByReferrer(userID)
+	if err != nil {
+		return perksFor(0), err
+	}
+	return perksFor(verified), nil
+}
+
+// perksFor returns the perks earned for the given number of verified
+// referrals, following the "referrals" configuration.
+func perksFor(verified int64) Perks {
+	perks := Perks{Badges: []string{}}
+	if threshold := config.Get().Referrals.BadgeThreshold; threshold > 0 && verified >= threshold {
+		perks.Badges = append(perks.Badges, BadgeReferrer)
+	}
+
+	perks.UploadBonus = verified * config.Get().Referrals.UploadBonus
+	if max := config.Get().Referrals.MaxUploadBonus; max > 0 && perks.UploadBonus > max {
+		perks.UploadBonus = max
+	}
+	return perks
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"github.com/SteaceP/coderage/repositories"
+
+	"gorm.io/gorm"
+)
+
+// WithDB returns a copy of the services running their queries on db, such
+// as the connection of a request, which carries its context and may read
+// from the replicas. Handlers scope the services once per request.
+//
+// Only the repositories of the services are replaced: each service is
+// copied with the other fields it was built with, so the copies share the
+// in-memory state of the services, such as the cached counts and the guest
+// comment counters. The maintenance tasks, imports and exports, and the
+// image variants aren't scoped, as they outlive the requests starting them.
+func (s *Services) WithDB(db *gorm.DB) *Services {
+	scoped := *s
+	scoped.Posts = s.Posts.withDB(db)
+	scoped.Translations = s.Translations.withDB(db, scoped.Posts)
+	scoped.Users = s.Users.withDB(db)
+	scoped.Audit = s.Audit.withDB(db)
+	scoped.Auth = s.Auth.withDB(db, scoped.Audit)
+	scoped.Verification = s.Verification.withDB(db)
+	scoped.Settings = s.Settings.withDB(db)
+	scoped.Tags = s.Tags.withDB(db)
+	scoped.Categories = s.Categories.withDB(db)
+	scoped.Series = s.Series.withDB(db, scoped.Posts)
+	scoped.Media = s.Media.withDB(db)
+	scoped.Webhooks = s.Webhooks.withDB(db)
+	scoped.Integrations = s.Integrations.withDB(db, scoped.Posts)
+	scoped.GuestComments = s.GuestComments.withDB(db, scoped.Posts)
+	scoped.Notifications = s.Notifications.withDB(db)
+	scoped.Referrals = s.Referrals.withDB(db)
+	scoped.Leaderboards = s.Leaderboards.withDB(db)
+	scoped.Announcements = s.Announcements.withDB(db)
+	scoped.Accounts = s.Accounts.withDB(db)
+	scoped.Previews = s.Previews.withDB(db)
+	scoped.Embeds = s.Embeds.withDB(db)
+	scoped.Identities = s.Identities.withDB(db)
+	scoped.Onboarding = s.Onboarding.withDB(db)
+	scoped.Suppressions = s.Suppressions.withDB(db)
+	scoped.Plans = s.Plans.withDB(db)
+	scoped.Search = s.Search.withDB(db)
+	scoped.Views = s.Views.withDB(db)
+	scoped.Analytics = s.Analytics.withDB(db)
+	scoped.Discovery = s.Discovery.withDB(db)
+	return &scoped
+}
+
+func (s *PostService) withDB(db *gorm.DB) *PostService {
+	scoped := *s
+	scoped.postRepo = repositories.NewPostRepository(db)
+	scoped.userRepo = repositories.NewUserRepository(db)
+	scoped.commentRepo = repositories.NewCommentRepository(db)
+	return &scoped
+}
+
+func (s *TranslationService) withDB(db *gorm.DB, posts *PostService) *TranslationService {
+	scoped := *s
+	scoped.translationRepo = repositories.NewPostTranslationRepository(db)
+	scoped.posts = posts
+	return &scoped
+}
+
+func (s *UserService) withDB(db *gorm.DB) *UserService {
+	scoped := *s
+	scoped.userRepo = repositories.NewUserRepository(db)
+	scoped.sessionRepo = repositories.NewSessionRepository(db)
+	return &scoped
+}
+
+func (s *AuditService) withDB(db *gorm.DB) *AuditService {
+	scoped := *s
+	scoped.auditRepo = repositories.NewAuditLogRepository(db)
+	return &scoped
+}
+
+func (s *AuthService) withDB(db *gorm.DB, audit *AuditService) *AuthService {
+	scoped := *s
+	scoped.userRepo = repositories.NewUserRepository(db)
+	scoped.sessionRepo = repositories.NewSessionRepository(db)
+	scoped.audit = audit
+	return &scoped
+}
+
+func (s *VerificationService) withDB(db *gorm.DB) *VerificationService {
+	scoped := *s
+	scoped.userRepo = repositories.NewUserRepository(db)
+	scoped.tokenRepo = repositories.NewVerificationTokenRepository(db)
+	return &scoped
+}
+
+func (s *SettingsService) withDB(db *gorm.DB) *SettingsService {
+	scoped := *s
+	scoped.settingsRepo = repositories.NewSettingsRepository(db)
+	scoped.mediaRepo = repositories.NewMediaRepository(db)
+	return &scoped
+}
+
+func (s *TagService) withDB(db *gorm.DB) *TagService {
+	scoped := *s
+	scoped.tagRepo = repositories.NewTagRepository(db)
+	return &scoped
+}
+
+func (s *CategoryService) withDB(db *gorm.DB) *CategoryService {
+	scoped := *s
+	scoped.categoryRepo = repositories.NewCategoryRepository(db)
+	return &scoped
+}
+
+func (s *SeriesService) withDB(db *gorm.DB, posts *PostService) *SeriesService {
+	scoped := *s
+	scoped.seriesRepo = repositories.NewSeriesRepository(db)
+	scoped.posts = posts
+	return &scoped
+}
+
+func (s *MediaService) withDB(db *gorm.DB) *MediaService {
+	scoped := *s
+	scoped.mediaRepo = repositories.NewMediaRepository(db)
+	return &scoped
+}
+
+func (s *WebhookService) withDB(db *gorm.DB) *WebhookService {
+	scoped := *s
+	scoped.webhookRepo = repositories.NewWebhookRepository(db)
+	return &scoped
+}
+
+func (s *IntegrationService) withDB(db *gorm.DB, posts *PostService) *IntegrationService {
+	scoped := *s
+	scoped.integrationRepo = repositories.NewIntegrationRepository(db)
+	scoped.postRepo = repositories.NewPostRepository(db)
+	scoped.userRepo = repositories.NewUserRepository(db)
+	scoped.commentRepo = repositories.NewCommentRepository(db)
+	scoped.posts = posts
+	return &scoped
+}
+
+func (s *GuestCommentService) withDB(db *gorm.DB, posts *PostService) *GuestCommentService {
+	scoped := *s
+	scoped.userRepo = repositories.NewUserRepository(db)
+	scoped.posts = posts
+	return &scoped
+}
+
+func (s *NotificationService) withDB(db *gorm.DB) *NotificationService {
+	scoped := *s
+	scoped.notificationRepo = repositories.NewNotificationRepository(db)
+	scoped.userRepo = repositories.NewUserRepository(db)
+	scoped.postRepo = repositories.NewPostRepository(db)
+	scoped.commentRepo = repositories.NewCommentRepository(db)
+	return &scoped
+}
+
+func (s *ReferralService) withDB(db *gorm.DB) *ReferralService {
+	scoped := *s
+	scoped.referralRepo = repositories.NewReferralRepository(db)
+	scoped.userRepo = repositories.NewUserRepository(db)
+	scoped.settingsRepo = repositories.NewSettingsRepository(db)
+	return &scoped
+}
+
+func (s *LeaderboardService) withDB(db *gorm.DB) *LeaderboardService {
+	scoped := *s
+	scoped.postRepo = repositories.NewPostRepository(db)
+	scoped.commentRepo = repositories.NewCommentRepository(db)
+	scoped.userRepo = repositories.NewUserRepository(db)
+	return &scoped
+}
+
+func (s *AnnouncementService) withDB(db *gorm.DB) *AnnouncementService {
+	scoped := *s
+	scoped.announcementRepo = repositories.NewAnnouncementRepository(db)
+	scoped.userRepo = repositories.NewUserRepository(db)
+	return &scoped
+}
+
+func (s *AccountService) withDB(db *gorm.DB) *AccountService {
+	scoped := *s
+	scoped.userRepo = repositories.NewUserRepository(db)
+	scoped.postRepo = repositories.NewPostRepository(db)
+	scoped.commentRepo = repositories.NewCommentRepository(db)
+	return &scoped
+}
+
+func (s *PreviewService) withDB(db *gorm.DB) *PreviewService {
+	scoped := *s
+	scoped.previewRepo = repositories.NewLinkPreviewRepository(db)
+	return &scoped
+}
+
+func (s *EmbedService) withDB(db *gorm.DB) *EmbedService {
+	scoped := *s
+	scoped.postRepo = repositories.NewPostRepository(db)
+	scoped.commentRepo = repositories.NewCommentRepository(db)
+	scoped.userRepo = repositories.NewUserRepository(db)
+	return &scoped
+}
+
+func (s *IdentityService) withDB(db *gorm.DB) *IdentityService {
+	scoped := *s
+	scoped.identityRepo = repositories.NewIdentityRepository(db)
+	scoped.userRepo = repositories.NewUserRepository(db)
+	return &scoped
+}
+
+func (s *OnboardingService) withDB(db *gorm.DB) *OnboardingService {
+	scoped := *s
+	scoped.stepRepo = repositories.NewOnboardingStepRepository(db)
+	scoped.userRepo = repositories.NewUserRepository(db)
+	scoped.postRepo = repositories.NewPostRepository(db)
+	return &scoped
+}
+
+func (s *SuppressionService) withDB(db *gorm.DB) *SuppressionService {
+	scoped := *s
+	scoped.suppressionRepo = repositories.NewEmailSuppressionRepository(db)
+	return &scoped
+}
+
+func (s *PlanService) withDB(db *gorm.DB) *PlanService {
+	scoped := *s
+	scoped.planRepo = repositories.NewPlanRepository(db)
+	scoped.settingsRepo = repositories.NewSettingsRepository(db)
+	return &scoped
+}
+
+func (s *SearchService) withDB(db *gorm.DB) *SearchService {
+	scoped := *s
+	scoped.searchRepo = repositories.NewSearchQueryRepository(db)
+	scoped.postRepo = repositories.NewPostRepository(db)
+	return &scoped
+}
+
+func (s *ViewService) withDB(db *gorm.DB) *ViewService {
+	scoped := *s
+	scoped.viewRepo = repositories.NewPostViewRepository(db)
+	scoped.postRepo = repositories.NewPostRepository(db)
+	scoped.userRepo = repositories.NewUserRepository(db)
+	return &scoped
+}
+
+func (s *AnalyticsService) withDB(db *gorm.DB) *AnalyticsService {
+	scoped := *s
+	scoped.postRepo = repositories.NewPostRepository(db)
+	scoped.commentRepo = repositories.NewCommentRepository(db)
+	scoped.viewRepo = repositories.NewPostViewRepository(db)
+	return &scoped
+}
+
+func (s *DiscoveryService) withDB(db *gorm.DB) *DiscoveryService {
+	scoped := *s
+	scoped.postRepo = repositories.NewPostRepository(db)
+	scoped.viewRepo = repositories.NewPostViewRepository(db)
+	scoped.commentRepo = repositories.NewCommentRepository(db)
+	return &scoped
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/webhooks"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestWithDB(t *testing.T) {
+	s := New(&gorm.DB{}, mailer.Discard, webhooks.Discard, events.Discard, nil, nil, zap.NewNop())
+	scoped := s.WithDB(&gorm.DB{})
+
+	if scoped.Posts == s.Posts || scoped.Posts.postRepo == s.Posts.postRepo {
+		t.Error("the post service isn't scoped")
+	}
+	if scoped.Series.posts != scoped.Posts || scoped.GuestComments.posts != scoped.Posts {
+		t.Error("the services using the posts don't use the scoped post service")
+	}
+	if scoped.Auth.audit != scoped.Audit {
+		t.Error("the auth service doesn't use the scoped audit service")
+	}
+
+	// The in-memory state is shared
+	if scoped.Posts.counts != s.Posts.counts ||
+		scoped.Leaderboards.cache != s.Leaderboards.cache ||
+		scoped.Views.viewed != s.Views.viewed ||
+		scoped.Discovery.rankings != s.Discovery.rankings ||
+		scoped.Previews.fetcher != s.Previews.fetcher ||
+		scoped.GuestComments.limiter != s.GuestComments.limiter {
+		t.Error("the scoped services don't share the in-memory state")
+	}
+	if scoped.Tasks != s.Tasks || scoped.Images != s.Images {
+		t.Error("the background services are scoped")
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/search"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrSearchNotFound is returned when a recorded search doesn't exist.
+var ErrSearchNotFound = errors.New("search not found")
+
+// maxSearchQueryLength is the length of the longest query recorded, in
+// characters
+const maxSearchQueryLength = 100
+
+// reindexBatchSize is the number of posts loaded at once by Reindex
+const reindexBatchSize = 100
+
+// SearchIndexEvents are the events of the posts whose changes are indexed
+// into the search engine.
+var SearchIndexEvents = []string{events.PostCreated, events.PostPublished, events.PostUpdated, events.PostDeleted}
+
+// SearchReport sums up the searches of a period, for editors to see what
+// readers look for and don't find.
+type SearchReport struct {
+	Since time.Time `json:"since"`
+	repositories.SearchTotals
+	TopQueries        []repositories.QueryStats `json:"top_queries"`
+	ZeroResultQueries []repositories.QueryStats `json:"zero_result_queries"`
+}
+
+type SearchService struct {
+	searchRepo *repositories.SearchQueryRepository
+	postRepo   *repositories.PostRepository
+	engine     search.Engine
+	logger     *zap.Logger
+}
+
+// NewSearchService returns a new instance of SearchService with the provided
+// repositories, indexing the posts into the provided search engine when it
+// isn't nil.
+func NewSearchService(searchRepo *repositories.SearchQueryRepository, postRepo *repositories.PostRepository, engine search.Engine, logger *zap.Logger) *SearchService {
+	return &SearchService{
+		searchRepo: searchRepo,
+		postRepo:   postRepo,
+		engine:     engine,
+		logger:     logger,
+	}
+}
+
+// Record records a search of posts and its number of results, when search
+// analytics are enabled, and returns its ID for reporting the result
+// clicked. It returns 0 when the search isn't recorded. Failures are only
+// logged, so they never fail the search.
+func (s *SearchService) Record(query string, results int64) uint {
+	query = NormalizeSearchQuery(query)
+	if !config.Get().Search.Analytics.Enabled || query == "" {
+		return 0
+	}
+
+	recorded := &models.SearchQuery{Query: query, ResultCount: results}
+	if err := s.searchRepo.Create(recorded); err != nil {
+		s.logger.Error("Failed to record search", zap.Error(err))
+		return 0
+	}
+	return recorded.ID
+}
+
+// RecordClick records the post clicked in the results of a search.
+func (s *SearchService) RecordClick(searchID, postID uint) error {
+	if postID == 0 {
+		return invalid("post_id is required")
+	}
+	return notFound(s.searchRepo.RecordClick(searchID, postID), ErrSearchNotFound)
+}
+
+// Report sums up the searches made since the given time, with the limit
+// most searched queries and queries finding nothing.
+func (s *SearchService) Report(since time.Time, limit int) (*SearchReport, error) {
+	totals, err := s.searchRepo.Totals(since)
+	if err != nil {
+		return nil, err
+	}
+	top, err := s.searchRepo.TopQueries(since, false, limit)
+	if err != nil {
+		return nil, err
+	}
+	zero, err := s.searchRepo.TopQueries(since, true, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &SearchReport{
+		Since:             since,
+		SearchTotals:      *totals,
+		TopQueries:        top,
+		ZeroResultQueries: zero,
+	}, nil
+}
+
+// Purge removes the searches older than the retention period, and returns
+// how many were removed.
+func (s *SearchService) Purge() (int64, error) {
+	return s.searchRepo.PurgeBefore(time.Now().Add(-config.Get().Search.Analytics.Retention))
+}
+
+// HandleEvent updates the document of a post in the index of the search
+// engine, from one of the SearchIndexEvents.
+func (s *SearchService) HandleEvent(ctx context.Context, event events.Event) error {
+	if s.engine == nil {
+		return nil
+	}
+	var payload events.PostPayload
+	if err := event.Decode(&payload); err != nil {
+		return err
+	}
+
+	if event.Type == events.PostDeleted {
+		return s.engine.Delete(ctx, payload.PostID)
+	}
+	post, err := s.postRepo.FindByID(payload.PostID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Deleted since
+		return s.engine.Delete(ctx, payload.PostID)
+	}
+	if err != nil {
+		return err
+	}
+	return s.engine.Index(ctx, searchDocument(post))
+}
+
+// Reindex indexes every post into the search engine, such as when it is
+// set up, and returns how many were indexed. Posts indexed before being
+// deleted are left in the index, but never found.
+func (s *SearchService) Reindex(ctx context.Context) (int, error) {
+	if s.engine == nil {
+		return 0, invalid("no search engine is configured")
+	}
+
+	indexed := 0
+	err := s.postRepo.Batches(reindexBatchSize, func(posts []models.Post) error {
+		for i := range posts {
+			if err := s.engine.Index(ctx, searchDocument(&posts[i])); err != nil {
+				return err
+			}
+			indexed++
+		}
+		return nil
+	})
+	return indexed, err
+}
+
+// searchDocument returns the document of a post, whose author and tags are
+// loaded.
+func searchDocument(post *models.Post) search.Document {
+	tags := make([]string, len(post.Tags))
+	for i, tag := range post.Tags {
+		tags[i] = tag.Name
+	}
+	return search.Document{
+		ID:          post.ID,
+		Title:       post.Title,
+		Slug:        post.Slug,
+		Excerpt:     post.Excerpt,
+		Content:     post.Content,
+		Tags:        tags,
+		Author:      post.User.Username,
+		PublishedAt: post.PublishedAt,
+	}
+}
+
+// NormalizeSearchQuery lower cases a query and collapses its spaces, so the
+// searches of a query are counted together whatever their spelling.
+func NormalizeSearchQuery(query string) string {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if runes := []rune(query); len(runes) > maxSearchQueryLength {
+		query = strings.TrimSpace(string(runes[:maxSearchQueryLength]))
+	}
+	return query
+}
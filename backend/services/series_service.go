@@ -0,0 +1,343 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSeriesNotFound is returned when a series doesn't exist.
+	ErrSeriesNotFound = errors.New("series not found")
+	// ErrSeriesSlugTaken is returned when giving a series the slug of another
+	// one.
+	ErrSeriesSlugTaken = errors.New("a series with this slug already exists")
+	// ErrPostInSeries is returned when adding a post to a series while it
+	// already belongs to one.
+	ErrPostInSeries = errors.New("post already belongs to a series")
+)
+
+// SeriesUpdate holds the changes to apply to a series. Nil fields are left
+// unchanged.
+type SeriesUpdate struct {
+	Title       *string
+	Slug        *string
+	Description *string
+}
+
+// apply sets the fields of a series that are set in the update.
+func (u SeriesUpdate) apply(series *models.Series) {
+	setString(&series.Title, u.Title)
+	setString(&series.Slug, u.Slug)
+	setString(&series.Description, u.Description)
+}
+
+// SeriesService manages the series, the ordered collections authors group
+// their posts into, such as the parts of a tutorial.
+type SeriesService struct {
+	seriesRepo *repositories.SeriesRepository
+	posts      *PostService
+}
+
+// NewSeriesService returns a new instance of SeriesService with the provided
+// SeriesRepository, finding the posts through the provided PostService so
+// hidden posts stay hidden.
+func NewSeriesService(seriesRepo *repositories.SeriesRepository, posts *PostService) *SeriesService {
+	return &SeriesService{
+		seriesRepo: seriesRepo,
+		posts:      posts,
+	}
+}
+
+// CreateSeries validates and stores a new, empty series of a user. The slug
+// is generated from the title when unset.
+func (s *SeriesService) CreateSeries(userID uint, fields SeriesUpdate) (*models.Series, error) {
+	series := &models.Series{UserID: userID}
+	fields.apply(series)
+	if err := s.validateSeries(series); err != nil {
+		return nil, err
+	}
+	if err := s.seriesRepo.Create(series); err != nil {
+		return nil, err
+	}
+	series.Posts = []models.Post{}
+	return series, nil
+}
+
+// GetSeries returns a series by its slug, with its posts in order. Drafts
+// are only listed to the author of the series, and posts in early access
+// to the viewers who have access.
+func (s *SeriesService) GetSeries(slug string, viewerID uint) (*models.Series, error) {
+	series, err := s.seriesRepo.FindBySlug(slug)
+	if err != nil {
+		return nil, notFound(err, ErrSeriesNotFound)
+	}
+	if err := s.loadPosts(series, viewerID); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// UpdateSeries applies the given changes to a series of the editor and
+// saves it.
+func (s *SeriesService) UpdateSeries(id, editorID uint, update SeriesUpdate) (*models.Series, error) {
+	series, err := s.ownedSeries(id, editorID)
+	if err != nil {
+		return nil, err
+	}
+
+	update.apply(series)
+	if err := s.validateSeries(series); err != nil {
+		return nil, err
+	}
+	if err := s.seriesRepo.Update(series); err != nil {
+		return nil, err
+	}
+	if err := s.loadPosts(series, editorID); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// DeleteSeries removes a series of the editor. Its posts are kept, out of
+// any series.
+func (s *SeriesService) DeleteSeries(id, editorID uint) error {
+	if _, err := s.ownedSeries(id, editorID); err != nil {
+		return err
+	}
+	return notFound(s.seriesRepo.Delete(id), ErrSeriesNotFound)
+}
+
+// AddPost adds a post of the editor to a series of theirs, at a position
+// counted from 1, or last when the position is 0 or past the end. A post
+// belongs to at most one series.
+func (s *SeriesService) AddPost(seriesID, postID uint, position int, editorID uint) (*models.Series, error) {
+	series, err := s.ownedSeries(seriesID, editorID)
+	if err != nil {
+		return nil, err
+	}
+	post, err := s.posts.FindPost(postID, editorID)
+	if err != nil {
+		return nil, err
+	}
+	if post.UserID != editorID {
+		return nil, fmt.Errorf("%w: only the author can add this post to a series", ErrForbidden)
+	}
+	inSeries, err := s.seriesRepo.InSeries(post.ID)
+	if err != nil {
+		return nil, err
+	}
+	if inSeries {
+		return nil, ErrPostInSeries
+	}
+	if position < 0 {
+		return nil, invalid("position must be positive")
+	}
+
+	ids, err := s.postIDs(series.ID)
+	if err != nil {
+		return nil, err
+	}
+	if position == 0 || position > len(ids) {
+		position = len(ids) + 1
+	}
+	ids = append(ids[:position-1], append([]uint{post.ID}, ids[position-1:]...)...)
+	if err := s.seriesRepo.SetPosts(series.ID, ids); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadPosts(series, editorID); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// ReorderPosts puts the posts of a series of the editor in the given order,
+// which must list each of its posts once.
+func (s *SeriesService) ReorderPosts(seriesID uint, postIDs []uint, editorID uint) (*models.Series, error) {
+	series, err := s.ownedSeries(seriesID, editorID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.postIDs(series.ID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		remaining[id] = true
+	}
+	for _, id := range postIDs {
+		if !remaining[id] {
+			return nil, invalid("post %d isn't in the series or is listed twice", id)
+		}
+		delete(remaining, id)
+	}
+	if len(remaining) > 0 {
+		return nil, invalid("the new order must list every post of the series")
+	}
+
+	if err := s.seriesRepo.SetPosts(series.ID, postIDs); err != nil {
+		return nil, err
+	}
+	if err := s.loadPosts(series, editorID); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// RemovePost takes a post out of a series of the editor.
+func (s *SeriesService) RemovePost(seriesID, postID, editorID uint) (*models.Series, error) {
+	series, err := s.ownedSeries(seriesID, editorID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := s.postIDs(series.ID)
+	if err != nil {
+		return nil, err
+	}
+	kept := ids[:0]
+	for _, id := range ids {
+		if id != postID {
+			kept = append(kept, id)
+		}
+	}
+	if len(kept) == len(ids) {
+		return nil, ErrPostNotFound
+	}
+	if err := s.seriesRepo.SetPosts(series.ID, kept); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadPosts(series, editorID); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// Navigate returns the place of a post in its series, among the posts the
+// viewer can see, with links to the posts before and after it. It returns
+// nil when the post isn't part of a series.
+func (s *SeriesService) Navigate(post *models.Post, viewerID uint) (*models.SeriesNavigation, error) {
+	series, err := s.seriesRepo.FindByPost(post.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	outline, err := s.seriesRepo.Outline(series.ID)
+	if err != nil {
+		return nil, err
+	}
+	outline = s.visiblePosts(series, outline, viewerID)
+
+	for i := range outline {
+		if outline[i].ID != post.ID {
+			continue
+		}
+		navigation := &models.SeriesNavigation{
+			ID:       series.ID,
+			Slug:     series.Slug,
+			Title:    series.Title,
+			Position: i + 1,
+			Total:    len(outline),
+		}
+		if i > 0 {
+			navigation.Previous = seriesLink(&outline[i-1])
+		}
+		if i < len(outline)-1 {
+			navigation.Next = seriesLink(&outline[i+1])
+		}
+		return navigation, nil
+	}
+	// The post is hidden from the rest of its series
+	return nil, nil
+}
+
+// seriesLink returns the link to a post of a series.
+func seriesLink(post *models.Post) *models.SeriesLink {
+	return &models.SeriesLink{ID: post.ID, Slug: post.Slug, Title: post.Title}
+}
+
+// ownedSeries returns a series, when the editor is its author.
+func (s *SeriesService) ownedSeries(id, editorID uint) (*models.Series, error) {
+	series, err := s.seriesRepo.FindByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrSeriesNotFound)
+	}
+	if series.UserID != editorID {
+		return nil, fmt.Errorf("%w: only the author can change this series", ErrForbidden)
+	}
+	return series, nil
+}
+
+// postIDs returns the IDs of the posts of a series in order.
+func (s *SeriesService) postIDs(seriesID uint) ([]uint, error) {
+	outline, err := s.seriesRepo.Outline(seriesID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(outline))
+	for i, post := range outline {
+		ids[i] = post.ID
+	}
+	return ids, nil
+}
+
+// loadPosts sets the posts of a series the viewer can see, in order.
+func (s *SeriesService) loadPosts(series *models.Series, viewerID uint) error {
+	posts, err := s.seriesRepo.Posts(series.ID)
+	if err != nil {
+		return err
+	}
+	series.Posts = s.visiblePosts(series, posts, viewerID)
+	return nil
+}
+
+// visiblePosts filters the posts of a series down to those the viewer can
+// see. The author of the series sees them all.
+func (s *SeriesService) visiblePosts(series *models.Series, posts []models.Post, viewerID uint) []models.Post {
+	visible := make([]models.Post, 0, len(posts))
+	if viewerID != 0 && viewerID == series.UserID {
+		return append(visible, posts...)
+	}
+	earlyAccess := s.posts.HasEarlyAccess(viewerID)
+	for _, post := range posts {
+		if post.Status == "draft" || (post.InEarlyAccess() && !earlyAccess) {
+			continue
+		}
+		visible = append(visible, post)
+	}
+	return visible
+}
+
+// validateSeries validates the fields of a series, and checks that its slug
+// isn't the one of another series.
+func (s *SeriesService) validateSeries(series *models.Series) error {
+	series.Title = utils.SanitizeText(strings.TrimSpace(series.Title))
+	series.Description = utils.SanitizeText(series.Description)
+	if series.Slug == "" {
+		series.Slug = series.Title
+	}
+	series.Slug = repositories.Slugify(series.Slug)
+
+	if err := validateStruct(series); err != nil {
+		return err
+	}
+	if series.Slug == "" {
+		return invalid("slug must hold letters or digits")
+	}
+	if existing, err := s.seriesRepo.FindBySlug(series.Slug); err == nil && existing.ID != series.ID {
+		return ErrSeriesSlugTaken
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/search"
+	"github.com/SteaceP/coderage/spam"
+	"github.com/SteaceP/coderage/unfurl"
+	"github.com/SteaceP/coderage/webhooks"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Services groups the services used by the handlers.
+type Services struct {
+	Posts         *PostService
+	Translations  *TranslationService
+	Users         *UserService
+	Auth          *AuthService
+	Verification  *VerificationService
+	Settings      *SettingsService
+	Tags          *TagService
+	Categories    *CategoryService
+	Series        *SeriesService
+	Media         *MediaService
+	Audit         *AuditService
+	Webhooks      *WebhookService
+	Integrations  *IntegrationService
+	GuestComments *GuestCommentService
+	Notifications *NotificationService
+	Referrals     *ReferralService
+	Leaderboards  *LeaderboardService
+	Announcements *AnnouncementService
+	Accounts      *AccountService
+	Tasks         *TaskService
+	Imports       *ImportService
+	Exports       *ExportService
+	Previews      *PreviewService
+	Images        *ImageService
+	Embeds        *EmbedService
+	Identities    *IdentityService
+	Onboarding    *OnboardingService
+	Suppressions  *SuppressionService
+	Plans         *PlanService
+	Search        *SearchService
+	Views         *ViewService
+	Analytics     *AnalyticsService
+	Discovery     *DiscoveryService
+}
+
+// New returns the services of the application.
+//
+// The returned services share repositories backed by the provided Gorm
+// database connection, send emails through the provided mailer, deliver
+// webhooks through the provided sender, publish events on the provided bus
+// and index the posts into the provided search engine, the database serving
+// the searches when it is nil. New comments are checked by the provided spam
+// checker, unless it is nil.
+func New(db *gorm.DB, m mailer.Mailer, sender webhooks.Sender, bus events.Bus, engine search.Engine, checker spam.Checker, logger *zap.Logger) *Services {
+	postRepo := repositories.NewPostRepository(db)
+	userRepo := repositories.NewUserRepository(db)
+	commentRepo := repositories.NewCommentRepository(db)
+	settingsRepo := repositories.NewSettingsRepository(db)
+	mediaRepo := repositories.NewMediaRepository(db)
+	notificationRepo := repositories.NewNotificationRepository(db)
+	announcementRepo := repositories.NewAnnouncementRepository(db)
+	notifications := NewNotificationService(notificationRepo, userRepo, postRepo, commentRepo, m, logger)
+	leaderboards := NewLeaderboardService(postRepo, commentRepo, userRepo, logger)
+	accounts := NewAccountService(userRepo, postRepo, commentRepo, logger)
+	searches := NewSearchService(repositories.NewSearchQueryRepository(db), postRepo, engine, logger)
+	audit := NewAuditService(repositories.NewAuditLogRepository(db), logger)
+	sessionRepo := repositories.NewSessionRepository(db)
+	posts := NewPostService(postRepo, userRepo, commentRepo, bus, engine, checker, logger)
+	tasks := NewTaskService(postRepo, commentRepo, notificationRepo, announcementRepo, leaderboards, accounts, searches, logger)
+
+	return &Services{
+		Posts:         posts,
+		Translations:  NewTranslationService(repositories.NewPostTranslationRepository(db), posts, logger),
+		Users:         NewUserService(userRepo, sessionRepo),
+		Auth:          NewAuthService(userRepo, sessionRepo, audit, bus, logger),
+		Verification:  NewVerificationService(userRepo, repositories.NewVerificationTokenRepository(db), m, logger),
+		Settings:      NewSettingsService(settingsRepo, mediaRepo),
+		Tags:          NewTagService(repositories.NewTagRepository(db)),
+		Categories:    NewCategoryService(repositories.NewCategoryRepository(db)),
+		Series:        NewSeriesService(repositories.NewSeriesRepository(db), posts),
+		Media:         NewMediaService(mediaRepo),
+		Audit:         audit,
+		Webhooks:      NewWebhookService(repositories.NewWebhookRepository(db), sender, logger),
+		Integrations:  NewIntegrationService(repositories.NewIntegrationRepository(db), postRepo, userRepo, commentRepo, posts, logger),
+		GuestComments: NewGuestCommentService(userRepo, posts, logger),
+		Notifications: notifications,
+		Referrals:     NewReferralService(repositories.NewReferralRepository(db), userRepo, settingsRepo, logger),
+		Leaderboards:  leaderboards,
+		Announcements: NewAnnouncementService(announcementRepo, userRepo),
+		Accounts:      accounts,
+		Tasks:         tasks,
+		Imports:       NewImportService(postRepo, userRepo, tasks, logger),
+		Exports:       NewExportService(postRepo, commentRepo, userRepo, tasks, logger),
+		Images:        NewImageService(mediaRepo, tasks, logger),
+		Previews:      NewPreviewService(repositories.NewLinkPreviewRepository(db), unfurl.NewFetcher(), logger),
+		Embeds:        NewEmbedService(postRepo, commentRepo, userRepo, logger),
+		Identities:    NewIdentityService(repositories.NewIdentityRepository(db), userRepo, bus, logger),
+		Onboarding:    NewOnboardingService(repositories.NewOnboardingStepRepository(db), userRepo, postRepo, bus, logger),
+		Suppressions:  NewSuppressionService(repositories.NewEmailSuppressionRepository(db), logger),
+		Plans:         NewPlanService(repositories.NewPlanRepository(db), settingsRepo, logger),
+		Search:        searches,
+		Views:         NewViewService(repositories.NewPostViewRepository(db), postRepo, userRepo, logger),
+		Analytics:     NewAnalyticsService(postRepo, commentRepo, repositories.NewPostViewRepository(db)),
+		Discovery:     NewDiscoveryService(postRepo, repositories.NewPostViewRepository(db), commentRepo),
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+)
+
+// hexColor matches colors in the #rrggbb notation
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// DateFormats maps the date formats of the site to their layout
+var DateFormats = map[string]string{
+	"iso":  "2006-01-02",
+	"us":   "01/02/2006",
+	"eu":   "02/01/2006",
+	"long": "January 2, 2006",
+}
+
+// Branding is the public branding of the site, with its media resolved to
+// their URLs.
+type Branding struct {
+	LogoMediaID    *uint  `json:"logo_media_id,omitempty"`
+	LogoURL        string `json:"logo_url,omitempty"`
+	FaviconMediaID *uint  `json:"favicon_media_id,omitempty"`
+	FaviconURL     string `json:"favicon_url,omitempty"`
+	PrimaryColor   string `json:"primary_color,omitempty"`
+	AccentColor    string `json:"accent_color,omitempty"`
+	CustomHead     string `json:"custom_head,omitempty"`
+}
+
+type SettingsService struct {
+	settingsRepo *repositories.SettingsRepository
+	mediaRepo    *repositories.MediaRepository
+}
+
+// NewSettingsService returns a new instance of SettingsService with the
+// provided SettingsRepository and MediaRepository.
+func NewSettingsService(settingsRepo *repositories.SettingsRepository, mediaRepo *repositories.MediaRepository) *SettingsService {
+	return &SettingsService{
+		settingsRepo: settingsRepo,
+		mediaRepo:    mediaRepo,
+	}
+}
+
+// Get returns the site settings.
+func (s *SettingsService) Get() (*models.SiteSettings, error) {
+	return s.settingsRepo.Get()
+}
+
+// Update saves the changes made to the site settings.
+func (s *SettingsService) Update(settings *models.SiteSettings) error {
+	return s.settingsRepo.Update(settings)
+}
+
+// Branding returns the branding of the site settings. Logos and favicons
+// whose media were removed are left out.
+func (s *SettingsService) Branding(settings *models.SiteSettings) Branding {
+	return Branding{
+		LogoMediaID:    settings.LogoMediaID,
+		LogoURL:        s.mediaURL(settings.LogoMediaID),
+		FaviconMediaID: settings.FaviconMediaID,
+		FaviconURL:     s.mediaURL(settings.FaviconMediaID),
+		PrimaryColor:   settings.PrimaryColor,
+		AccentColor:    settings.AccentColor,
+		CustomHead:     settings.CustomHead,
+	}
+}
+
+// ValidateBrandingMedia checks that a media can be used as a logo or favicon:
+// it must exist and be an image.
+func (s *SettingsService) ValidateBrandingMedia(id uint) error {
+	media, err := s.mediaRepo.FindByID(id)
+	if err != nil {
+		return notFound(err, invalid("media not found"))
+	}
+	if !strings.HasPrefix(media.ContentType, "image/") {
+		return invalid("branding media must be images")
+	}
+	return nil
+}
+
+// ValidateColor checks that a branding color is empty or in the #rrggbb
+// notation.
+func ValidateColor(color string) error {
+	if color != "" && !hexColor.MatchString(color) {
+		return invalid("colors must be in the #rrggbb notation")
+	}
+	return nil
+}
+
+// ValidateTimezone checks that a time zone is a known IANA name, such as
+// Europe/Paris.
+func ValidateTimezone(timezone string) error {
+	if timezone == "" {
+		return invalid("timezone is required")
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return invalid("unknown timezone")
+	}
+	return nil
+}
+
+// ValidateDateFormat checks that a date format is one of DateFormats.
+func ValidateDateFormat(format string) error {
+	if _, ok := DateFormats[format]; !ok {
+		return invalid("date format must be one of iso, us, eu or long")
+	}
+	return nil
+}
+
+// SiteLocation returns the time zone of the site, in which dates are grouped
+// by day and month and presented. Dates are stored in UTC, which is also the
+// fallback when the time zone is unset or unknown.
+func SiteLocation(settings *models.SiteSettings) *time.Location {
+	if settings.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// mediaURL returns the URL of a media, or an empty string when it is unset
+// or doesn't exist anymore.
+func (s *SettingsService) mediaURL(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	media, err := s.mediaRepo.FindByID(*id)
+	if err != nil {
+		return ""
+	}
+	return media.URL
+}
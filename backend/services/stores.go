@@ -0,0 +1,108 @@
+package services
+
+import (
+	"time"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+)
+
+// PostStore stores the posts managed by PostService. It is implemented by
+// repositories.PostRepository, and can be replaced by a fake to test the
+// service without a database.
+type PostStore interface {
+	// Create stores a new post.
+	Create(post *models.Post) error
+	// FindByID retrieves a post along with its author and tags.
+	FindByID(id uint) (*models.Post, error)
+	// FindBySlug retrieves a post by its slug.
+	FindBySlug(slug string) (*models.Post, error)
+	// List retrieves a page of the posts matching the filters, and their
+	// total count.
+	List(page, pageSize int, filters map[string]interface{}) ([]models.Post, int64, error)
+	// EstimateCount estimates the number of posts matching the filters of
+	// List.
+	EstimateCount(filters map[string]interface{}) (int64, error)
+	// MatchingIDs returns the IDs of the posts matching the filters of List.
+	MatchingIDs(filters map[string]interface{}) ([]uint, error)
+	// ListPublished retrieves the slug and last update of every public post.
+	ListPublished() ([]models.Post, error)
+	// FindPublishedByUserIDs retrieves the published posts of the users.
+	FindPublishedByUserIDs(userIDs []uint, publicOnly bool) ([]models.Post, error)
+	// FindPublishedSlugs returns the slugs of published posts among slugs.
+	FindPublishedSlugs(slugs []string) ([]string, error)
+	// PublishedDates returns the publication dates of the published posts.
+	PublishedDates(publicOnly bool) ([]time.Time, error)
+	// Update saves the changes of a post.
+	Update(post *models.Post) error
+	// UpdateCommentCount increments or decrements the comment count of a
+	// post.
+	UpdateCommentCount(postID uint, increment bool) error
+	// Delete removes a post.
+	Delete(id uint) error
+	// FindByIDs retrieves the posts with the given IDs.
+	FindByIDs(ids []uint) ([]models.Post, error)
+	// Bulk applies a change to the posts with the given IDs, in a single
+	// transaction.
+	Bulk(ids []uint, change repositories.BulkChange) error
+	// SaveAuthor credits a user as an author of a post, or changes their
+	// role, and reports whether they were credited.
+	SaveAuthor(author *models.PostAuthor) (bool, error)
+	// DeleteAuthor stops crediting a user as an author of a post, and
+	// reports whether they were one.
+	DeleteAuthor(postID, userID uint) (bool, error)
+}
+
+// CommentStore stores the comments managed by PostService. It is
+// implemented by repositories.CommentRepository.
+type CommentStore interface {
+	// Create stores a new comment.
+	Create(comment *models.Comment) error
+	// FindByID retrieves a comment.
+	FindByID(id uint) (*models.Comment, error)
+	// FindByPostID retrieves a page of the comments of a post, and their
+	// total count.
+	FindByPostID(postID uint, includeHidden bool, page, pageSize int) ([]models.Comment, int64, error)
+	// FindByPostIDs retrieves the comments of the posts.
+	FindByPostIDs(postIDs []uint, includeHidden bool) ([]models.Comment, error)
+	// FindThreads retrieves a page of the top-level comments of a post, and
+	// their total count.
+	FindThreads(postID uint, includeHidden bool, page, pageSize int) ([]models.Comment, int64, error)
+	// FindPostReplies retrieves the replies to the comments of a post.
+	FindPostReplies(postID uint, includeHidden bool) ([]models.Comment, error)
+	// FindReplies retrieves the replies to a comment.
+	FindReplies(commentID uint, includeHidden bool) ([]models.Comment, error)
+	// FindFlagged retrieves a page of the comments awaiting moderation, and
+	// their total count.
+	FindFlagged(page, pageSize int) ([]models.Comment, int64, error)
+	// UpdateStatus sets the moderation status of a comment.
+	UpdateStatus(commentID uint, status string, moderatorID *uint, reason string) error
+	// Report records a report of a comment, and returns its number of
+	// reports.
+	Report(report *models.CommentReport) (int, error)
+	// Like records that a user likes a comment.
+	Like(commentID, userID uint) error
+	// Unlike removes the like of a user from a comment.
+	Unlike(commentID, userID uint) error
+	// LikedCommentIDs returns which of the comments a user likes.
+	LikedCommentIDs(userID uint, commentIDs []uint) (map[uint]bool, error)
+}
+
+// UserStore looks up the users PostService checks permissions of. It is
+// implemented by repositories.UserRepository.
+type UserStore interface {
+	// FindByIDLite retrieves a user, without their posts and comments.
+	FindByIDLite(id uint) (*models.User, error)
+	// FindRole returns the role of a user.
+	FindRole(userID uint) (string, error)
+	// FindAccess retrieves the fields of a user deciding what they may
+	// access.
+	FindAccess(userID uint) (*models.User, error)
+}
+
+// The repositories implement the stores
+var (
+	_ PostStore    = (*repositories.PostRepository)(nil)
+	_ CommentStore = (*repositories.CommentRepository)(nil)
+	_ UserStore    = (*repositories.UserRepository)(nil)
+)
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+
+	"go.uber.org/zap"
+)
+
+// ErrSuppressionNotFound is returned when an address isn't suppressed.
+var ErrSuppressionNotFound = errors.New("email suppression not found")
+
+// maxSuppressionDetailLength is the length of the longest diagnostic kept
+const maxSuppressionDetailLength = 1000
+
+type SuppressionService struct {
+	suppressionRepo *repositories.EmailSuppressionRepository
+	logger          *zap.Logger
+}
+
+// NewSuppressionService returns a new instance of SuppressionService with
+// the provided EmailSuppressionRepository.
+func NewSuppressionService(suppressionRepo *repositories.EmailSuppressionRepository, logger *zap.Logger) *SuppressionService {
+	return &SuppressionService{
+		suppressionRepo: suppressionRepo,
+		logger:          logger,
+	}
+}
+
+// Record suppresses the addresses reported by the feedback of an email
+// provider, so no more emails are sent to them. The users of the addresses
+// get the reason as email status.
+func (s *SuppressionService) Record(provider string, feedback []mailer.Feedback) error {
+	for _, f := range feedback {
+		if f.Email == "" {
+			continue
+		}
+		detail := f.Detail
+		if len(detail) > maxSuppressionDetailLength {
+			detail = detail[:maxSuppressionDetailLength]
+		}
+		if err := s.suppressionRepo.Suppress(&models.EmailSuppression{
+			Email:    f.Email,
+			Reason:   f.Kind,
+			Provider: provider,
+			Detail:   detail,
+		}); err != nil {
+			return err
+		}
+
+		s.logger.Info("Email address suppressed",
+			zap.String("email", f.Email),
+			zap.String("reason", f.Kind),
+			zap.String("provider", provider),
+		)
+	}
+	return nil
+}
+
+// List retrieves the suppressed addresses with pagination, most recent
+// first.
+func (s *SuppressionService) List(page, pageSize int) ([]models.EmailSuppression, int64, error) {
+	return s.suppressionRepo.List(page, pageSize)
+}
+
+// Lift lifts the suppression of an address, e.g. once its user fixed their
+// mailbox, so emails are sent to it again.
+func (s *SuppressionService) Lift(email string) error {
+	return notFound(s.suppressionRepo.Delete(email), ErrSuppressionNotFound)
+}
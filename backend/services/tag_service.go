@@ -0,0 +1,42 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+)
+
+// ErrTagNotFound is returned when a tag doesn't exist.
+var ErrTagNotFound = errors.New("tag not found")
+
+type TagService struct {
+	tagRepo *repositories.TagRepository
+}
+
+// NewTagService returns a new instance of TagService with the provided
+// TagRepository.
+func NewTagService(tagRepo *repositories.TagRepository) *TagService {
+	return &TagService{
+		tagRepo: tagRepo,
+	}
+}
+
+// ListTags retrieves all tags with their post counts.
+func (s *TagService) ListTags() ([]models.Tag, error) {
+	return s.tagRepo.List()
+}
+
+// TagsByPosts retrieves the tags of the given posts, keyed by post ID.
+func (s *TagService) TagsByPosts(postIDs []uint) (map[uint][]models.Tag, error) {
+	return s.tagRepo.FindByPostIDs(postIDs)
+}
+
+// GetTag retrieves a tag by its slug.
+func (s *TagService) GetTag(slug string) (*models.Tag, error) {
+	tag, err := s.tagRepo.FindBySlug(slug)
+	if err != nil {
+		return nil, notFound(err, ErrTagNotFound)
+	}
+	return tag, nil
+}
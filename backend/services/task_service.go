@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/repositories"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Maintenance tasks
+const (
+	TaskRecountComments     = "recount-comments"
+	TaskRefreshLeaderboards = "refresh-leaderboards"
+	TaskPurgeTrash          = "purge-trash"
+	TaskReindexSearch       = "reindex-search"
+)
+
+// Task run statuses
+const (
+	TaskRunning   = "running"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+)
+
+// maxTaskRuns is the number of task runs kept for status polling
+const maxTaskRuns = 100
+
+var (
+	// ErrTaskNotFound is returned when a task isn't registered.
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrTaskRunNotFound is returned when a task run doesn't exist.
+	ErrTaskRunNotFound = errors.New("task run not found")
+	// ErrTaskRunning is returned when starting a task already running.
+	ErrTaskRunning = errors.New("task is already running")
+)
+
+// Task is a maintenance task admins can trigger.
+type Task struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// run performs the task and returns a summary of what it did
+	run func(ctx context.Context) (string, error)
+}
+
+// TaskRun is an execution of a task.
+type TaskRun struct {
+	ID            string     `json:"id"`
+	Task          string     `json:"task"`
+	Status        string     `json:"status"`
+	Result        string     `json:"result,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	RequestedByID uint       `json:"requested_by_id"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+// TaskService runs maintenance tasks in the background and keeps their
+// recent runs in memory, so admins can poll their status.
+type TaskService struct {
+	tasks  map[string]*Task
+	logger *zap.Logger
+
+	mu   sync.Mutex
+	runs []*TaskRun // Oldest first
+}
+
+// NewTaskService returns a new instance of TaskService with the maintenance
+// tasks built on the provided repositories and services.
+func NewTaskService(postRepo *repositories.PostRepository, commentRepo *repositories.CommentRepository, notificationRepo *repositories.NotificationRepository, announcementRepo *repositories.AnnouncementRepository, leaderboards *LeaderboardService, accounts *AccountService, search *SearchService, logger *zap.Logger) *TaskService {
+	tasks := []*Task{
+		{
+			Name:        TaskRecountComments,
+			Description: "Recompute the comment count of posts and the like count of comments",
+			run: func(ctx context.Context) (string, error) {
+				posts, err := postRepo.RecountComments()
+				if err != nil {
+					return "", err
+				}
+				comments, err := commentRepo.RecountLikes()
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Fixed the comment count of %d posts and the like count of %d comments", posts, comments), nil
+			},
+		},
+		{
+			Name:        TaskRefreshLeaderboards,
+			Description: "Recompute the cached leaderboards",
+			run: func(ctx context.Context) (string, error) {
+				leaderboards.Refresh()
+				return "Refreshed the leaderboards", nil
+			},
+		},
+		{
+			Name:        TaskPurgeTrash,
+			Description: "Permanently remove the notifications and announcements deleted past the retention period and the searches past theirs, and purge the deleted accounts due",
+			run: func(ctx context.Context) (string, error) {
+				before := time.Now().Add(-config.Get().Tasks.TrashRetention)
+				notifications, err := notificationRepo.PurgeDeleted(before)
+				if err != nil {
+					return "", err
+				}
+				announcements, err := announcementRepo.PurgeDeleted(before)
+				if err != nil {
+					return "", err
+				}
+				searches, err := search.Purge()
+				if err != nil {
+					return "", err
+				}
+				users, err := accounts.Purge()
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Removed %d notifications, %d announcements and %d searches, and purged %d accounts", notifications, announcements, searches, users), nil
+			},
+		},
+		{
+			Name:        TaskReindexSearch,
+			Description: "Index every post into the search engine",
+			run: func(ctx context.Context) (string, error) {
+				indexed, err := search.Reindex(ctx)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Indexed %d posts", indexed), nil
+			},
+		},
+	}
+
+	s := &TaskService{tasks: make(map[string]*Task, len(tasks)), logger: logger}
+	for _, task := range tasks {
+		s.tasks[task.Name] = task
+	}
+	return s
+}
+
+// ListTasks returns the registered tasks, sorted by name.
+func (s *TaskService) ListTasks() []Task {
+	tasks := make([]Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, *task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	return tasks
+}
+
+// Start runs a task in the background on behalf of a user, and returns its
+// run. A task can't be started again before its previous run finished.
+func (s *TaskService) Start(name string, userID uint) (*TaskRun, error) {
+	task, ok := s.tasks[name]
+	if !ok {
+		return nil, ErrTaskNotFound
+	}
+	return s.Run(name, userID, task.run)
+}
+
+// Run runs a one-off job in the background on behalf of a user, such as an
+// import, and returns its run, kept along with the runs of the tasks. A job
+// can't be started again before the previous run of the same name finished.
+func (s *TaskService) Run(name string, userID uint, job func(ctx context.Context) (string, error)) (*TaskRun, error) {
+	s.mu.Lock()
+	for _, run := range s.runs {
+		if run.Task == name && run.Status == TaskRunning {
+			s.mu.Unlock()
+			return nil, ErrTaskRunning
+		}
+	}
+	run := &TaskRun{
+		ID:            uuid.New().String(),
+		Task:          name,
+		Status:        TaskRunning,
+		RequestedByID: userID,
+		StartedAt:     time.Now(),
+	}
+	s.runs = append(s.runs, run)
+	if len(s.runs) > maxTaskRuns {
+		s.runs = s.runs[len(s.runs)-maxTaskRuns:]
+	}
+	started := *run
+	s.mu.Unlock()
+
+	go s.execute(job, run)
+	return &started, nil
+}
+
+// ListRuns returns the recent runs of every task, most recent first.
+func (s *TaskService) ListRuns() []TaskRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]TaskRun, 0, len(s.runs))
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		runs = append(runs, *s.runs[i])
+	}
+	return runs
+}
+
+// GetRun returns a run of a task by its ID.
+func (s *TaskService) GetRun(id string) (*TaskRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, run := range s.runs {
+		if run.ID == id {
+			found := *run
+			return &found, nil
+		}
+	}
+	return nil, ErrTaskRunNotFound
+}
+
+// execute performs a task or a job and records the outcome of its run.
+func (s *TaskService) execute(job func(ctx context.Context) (string, error), run *TaskRun) {
+	result, err := job(context.Background())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	run.FinishedAt = &now
+	if err != nil {
+		run.Status = TaskFailed
+		run.Error = err.Error()
+		s.logger.Error("Task failed", zap.String("task", run.Task), zap.String("run_id", run.ID), zap.Error(err))
+		return
+	}
+	run.Status = TaskSucceeded
+	run.Result = result
+	s.logger.Info("Task succeeded", zap.String("task", run.Task), zap.String("run_id", run.ID), zap.String("result", result))
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/markdown"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/utils"
+
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+)
+
+// ErrTranslationNotFound is returned when a post isn't translated to a
+// locale.
+var ErrTranslationNotFound = errors.New("translation not found")
+
+// TranslationService manages the translations of posts, and serves each
+// reader the version of a post in the language they prefer.
+type TranslationService struct {
+	translationRepo *repositories.PostTranslationRepository
+	posts           *PostService
+	logger          *zap.Logger
+}
+
+// NewTranslationService returns a new instance of TranslationService with
+// the provided repository, finding the posts through the provided
+// PostService so the translations of hidden posts stay hidden.
+func NewTranslationService(translationRepo *repositories.PostTranslationRepository, posts *PostService, logger *zap.Logger) *TranslationService {
+	return &TranslationService{
+		translationRepo: translationRepo,
+		posts:           posts,
+		logger:          logger,
+	}
+}
+
+// Save creates or replaces the translation of a post to a locale, and
+// reports whether it was created. Only the authors of the post, contributors
+// included, can translate it, and not to the language it is written in.
+func (s *TranslationService) Save(postID, editorID uint, translation *models.PostTranslation) (*models.PostTranslation, bool, error) {
+	post, err := s.posts.FindPost(postID, editorID)
+	if err != nil {
+		return nil, false, err
+	}
+	if post.AuthorRole(editorID) == "" {
+		return nil, false, fmt.Errorf("%w: only the authors can translate this post", ErrForbidden)
+	}
+
+	locale := canonicalLocale(translation.Locale)
+	if !validLocale(locale) {
+		return nil, false, invalid("locale must be a language tag such as en or pt-BR")
+	}
+	if locale == PostLocale(post) {
+		return nil, false, invalid(fmt.Sprintf("the post is written in %s", locale))
+	}
+
+	translation.PostID = post.ID
+	translation.Locale = locale
+	translation.Title = utils.SanitizeText(translation.Title)
+	translation.Content = markdown.Sanitize(translation.Content)
+	translation.Excerpt = utils.SanitizeText(translation.Excerpt)
+	translation.MetaTitle = utils.SanitizeText(translation.MetaTitle)
+	translation.MetaDescription = utils.SanitizeText(translation.MetaDescription)
+	if translation.Title == "" {
+		return nil, false, invalid("title is required")
+	}
+	if translation.Content == "" {
+		return nil, false, invalid("content is required")
+	}
+	if len(translation.Title) < 5 || len(translation.Title) > 200 {
+		return nil, false, invalid("title must be between 5 and 200 characters")
+	}
+	if len(translation.Excerpt) > 500 {
+		return nil, false, invalid("excerpt must be at most 500 characters")
+	}
+
+	created, err := s.translationRepo.Save(translation)
+	if err != nil {
+		return nil, false, err
+	}
+	return translation, created, nil
+}
+
+// Get returns the translation of a post to a locale, along with the post.
+func (s *TranslationService) Get(postID uint, locale string, viewerID uint) (*models.PostTranslation, *models.Post, error) {
+	post, err := s.posts.FindPost(postID, viewerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	translation, err := s.translationRepo.Find(postID, canonicalLocale(locale))
+	if err != nil {
+		return nil, nil, notFound(err, ErrTranslationNotFound)
+	}
+	return translation, post, nil
+}
+
+// List returns the translations of a post, sorted by locale, along with the
+// post.
+func (s *TranslationService) List(postID, viewerID uint) ([]models.PostTranslation, *models.Post, error) {
+	post, err := s.posts.FindPost(postID, viewerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	translations, err := s.translationRepo.ListByPost(postID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return translations, post, nil
+}
+
+// Delete removes the translation of a post to a locale. Only the authors of
+// the post, contributors included, can delete it.
+func (s *TranslationService) Delete(postID uint, locale string, editorID uint) error {
+	post, err := s.posts.FindPost(postID, editorID)
+	if err != nil {
+		return err
+	}
+	if post.AuthorRole(editorID) == "" {
+		return fmt.Errorf("%w: only the authors can delete the translations of this post", ErrForbidden)
+	}
+
+	deleted, err := s.translationRepo.Delete(postID, canonicalLocale(locale))
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrTranslationNotFound
+	}
+	return nil
+}
+
+// Locales returns the locales of the translations of every post, keyed by
+// post ID, for indexes such as sitemaps.
+func (s *TranslationService) Locales() (map[uint][]string, error) {
+	return s.translationRepo.Locales()
+}
+
+// Localize replaces the content of a post with its translation to the
+// language the reader prefers, and returns the locale it is served in.
+//
+// The language is requested explicitly, as a language tag, or negotiated
+// from the Accept-Language header of the reader otherwise. The post is
+// served as written when neither matches one of its translations. The
+// fields missing from the translation are kept from the post, and the
+// locales of the translations are listed in the post.
+func (s *TranslationService) Localize(post *models.Post, requested, acceptLanguage string) (string, error) {
+	original := PostLocale(post)
+	translations, err := s.translationRepo.ListByPost(post.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(translations) == 0 {
+		return original, nil
+	}
+
+	tags := []language.Tag{language.Make(original)}
+	post.Translations = make([]string, len(translations))
+	for i, translation := range translations {
+		tags = append(tags, language.Make(translation.Locale))
+		post.Translations[i] = translation.Locale
+	}
+
+	// Unreadable preferences get the post as written
+	var preferred []language.Tag
+	if requested != "" {
+		preferred, _, err = language.ParseAcceptLanguage(requested)
+	} else {
+		preferred, _, err = language.ParseAcceptLanguage(acceptLanguage)
+	}
+	if err != nil || len(preferred) == 0 {
+		return original, nil
+	}
+	_, index, confidence := language.NewMatcher(tags).Match(preferred...)
+	if index == 0 || confidence == language.No {
+		return original, nil
+	}
+
+	translation := translations[index-1]
+	post.Title = translation.Title
+	post.Content = translation.Content
+	if translation.Excerpt != "" {
+		post.Excerpt = translation.Excerpt
+	}
+	if translation.MetaTitle != "" {
+		post.MetaTitle = translation.MetaTitle
+	}
+	if translation.MetaDescription != "" {
+		post.MetaDescription = translation.MetaDescription
+	}
+	post.Locale = translation.Locale
+	return translation.Locale, nil
+}
+
+// PostLocale returns the locale a post is written in, "site.locale" unless
+// set on the post.
+func PostLocale(post *models.Post) string {
+	if post.Locale != "" {
+		return post.Locale
+	}
+	return canonicalLocale(config.Get().Site.Locale)
+}
+
+// canonicalLocale returns the canonical form of a language tag, such as
+// pt-BR for pt_br, or the trimmed value when it isn't a valid tag.
+func canonicalLocale(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	tag, err := language.Parse(strings.ReplaceAll(value, "_", "-"))
+	if err != nil {
+		return value
+	}
+	return tag.String()
+}
+
+// validLocale reports whether a value is a valid language tag, at most as
+// long as the locale columns.
+func validLocale(value string) bool {
+	if len(value) > 35 || strings.Contains(value, "_") {
+		return false
+	}
+	_, err := language.Parse(value)
+	return err == nil
+}
@@ -1,71 +1,190 @@
 package services
 
 import (
-	"errors"
+	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/SteaceP/coderage/models"
 	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/types"
 	"github.com/SteaceP/coderage/utils"
 )
 
+// ProfileUpdate holds the changes to apply to a user's profile. Nil fields
+// are left unchanged, and empty strings clear the field.
+type ProfileUpdate struct {
+	FirstName       *string
+	LastName        *string
+	Bio             *string
+	ProfilePicture  *string
+	TwitterHandle   *string
+	LinkedInProfile *string
+	PersonalWebsite *string
+}
+
+// twitterHandle matches Twitter handles, without their leading @
+var twitterHandle = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+
 type UserService struct {
-	userRepo *repositories.UserRepository
+	userRepo    *repositories.UserRepository
+	sessionRepo *repositories.SessionRepository
 }
 
-// NewUserService returns a new instance of UserService with the provided UserRepository.
-func NewUserService(userRepo *repositories.UserRepository) *UserService {
+// NewUserService returns a new instance of UserService with the provided
+// repositories.
+func NewUserService(userRepo *repositories.UserRepository, sessionRepo *repositories.SessionRepository) *UserService {
 	return &UserService{
-		userRepo: userRepo,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
 	}
 }
 
 // GetUserProfile retrieves a user's profile by their ID.
 func (s *UserService) GetUserProfile(userID uint) (*models.User, error) {
-	return s.userRepo.FindByID(userID)
+	user, err := s.userRepo.FindByIDLite(userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	return user, nil
 }
 
-// UpdateProfile updates a user's profile information.
-func (s *UserService) UpdateProfile(user *models.User) error {
-	// Validate input
-	if err := validateUserUpdate(user); err != nil {
-		return err
+// GetPublicProfile retrieves the profile of a user by their username.
+// Deactivated accounts and accounts awaiting approval have no public profile.
+func (s *UserService) GetPublicProfile(username string) (*models.User, error) {
+	user, err := s.userRepo.FindByUsername(username)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	if !user.IsActive || user.PendingApproval {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// PublicProfiles retrieves the public profiles of the users with the given
+// IDs, keyed by user ID. Users without a public profile are left out.
+func (s *UserService) PublicProfiles(ids []uint) (map[uint]*models.User, error) {
+	users, err := s.userRepo.FindPublicProfiles(ids)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[uint]*models.User, len(users))
+	for i := range users {
+		profiles[users[i].ID] = &users[i]
+	}
+	return profiles, nil
+}
+
+// RequireAdmin returns an error unless the user is an admin.
+func (s *UserService) RequireAdmin(userID uint) error {
+	role, err := s.userRepo.FindRole(userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if role != types.RoleAdmin {
+		return fmt.Errorf("%w: admin role required", ErrForbidden)
 	}
+	return nil
+}
+
+// Preferences are the preferences of a user. Nil preferences are left
+// unchanged by UpdatePreferences.
+type Preferences struct {
+	ShowSensitive *bool
+	EmailDigest   *bool // Emailed a digest of their unread notifications
+}
+
+// UpdatePreferences updates a user's preferences and returns the updated user.
+func (s *UserService) UpdatePreferences(userID uint, prefs Preferences) (*models.User, error) {
+	user, err := s.userRepo.FindByIDLite(userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	if prefs.ShowSensitive != nil {
+		if err := s.userRepo.UpdateShowSensitive(userID, *prefs.ShowSensitive); err != nil {
+			return nil, err
+		}
+		user.ShowSensitive = *prefs.ShowSensitive
+	}
+	if prefs.EmailDigest != nil {
+		if err := s.userRepo.UpdateEmailDigest(userID, *prefs.EmailDigest); err != nil {
+			return nil, err
+		}
+		user.EmailDigest = *prefs.EmailDigest
+	}
+	return user, nil
+}
+
+// ShowsSensitive reports whether the user chose to see sensitive content
+// without acknowledging it every time.
+func (s *UserService) ShowsSensitive(userID uint) bool {
+	show, err := s.userRepo.FindShowSensitive(userID)
+	return err == nil && show
+}
 
+// UpdateProfile applies the given changes to a user's profile information
+// and saves them. The changes are validated against the resulting profile,
+// and the updated user is returned.
+func (s *UserService) UpdateProfile(userID uint, update ProfileUpdate) (*models.User, error) {
 	// Fetch existing user
-	existingUser, err := s.userRepo.FindByID(user.ID)
+	user, err := s.userRepo.FindByIDLite(userID)
 	if err != nil {
-		return errors.New("user not found")
+		return nil, notFound(err, ErrUserNotFound)
 	}
 
 	// Update allowed fields
-	existingUser.FirstName = user.FirstName
-	existingUser.LastName = user.LastName
-	existingUser.Bio = user.Bio
-	existingUser.ProfilePicture = user.ProfilePicture
-	existingUser.TwitterHandle = user.TwitterHandle
-	existingUser.LinkedInProfile = user.LinkedInProfile
-	existingUser.PersonalWebsite = user.PersonalWebsite
+	setString(&user.FirstName, update.FirstName)
+	setString(&user.LastName, update.LastName)
+	setString(&user.Bio, update.Bio)
+	setString(&user.ProfilePicture, update.ProfilePicture)
+	setString(&user.TwitterHandle, update.TwitterHandle)
+	setString(&user.LinkedInProfile, update.LinkedInProfile)
+	setString(&user.PersonalWebsite, update.PersonalWebsite)
+	user.TwitterHandle = strings.TrimPrefix(user.TwitterHandle, "@")
+
+	// Strip markup from the text shown on the profile
+	user.FirstName = utils.SanitizeText(user.FirstName)
+	user.LastName = utils.SanitizeText(user.LastName)
+	user.Bio = utils.SanitizeText(user.Bio)
+
+	// Validate input
+	if err := validateUserUpdate(user); err != nil {
+		return nil, err
+	}
+	if err := validateStruct(user); err != nil {
+		return nil, err
+	}
 
-	return s.userRepo.Update(existingUser)
+	if err := s.userRepo.UpdateProfile(user); err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
-// ChangePassword updates a user's password in the database.
+// ChangePassword updates a user's password in the database, after checking
+// their current password. The refresh tokens issued before the change are
+// invalidated.
 func (s *UserService) ChangePassword(userID uint, currentPassword, newPassword string) error {
 	// Fetch user
-	user, err := s.userRepo.FindByID(userID)
+	user, err := s.userRepo.FindByIDLite(userID)
 	if err != nil {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
 	// Verify current password
 	if !utils.CheckPasswordHash(currentPassword, user.Password) {
-		return errors.New("current password is incorrect")
+		return invalid("current password is incorrect")
 	}
 
 	// Validate new password
 	if err := validatePassword(newPassword); err != nil {
 		return err
 	}
+	if newPassword == currentPassword {
+		return invalid("new password must be different from the current password")
+	}
 
 	// Update password
 	return s.userRepo.UpdatePassword(userID, newPassword)
@@ -84,23 +203,95 @@ func (s *UserService) ListUsers(page, pageSize int, filters map[string]interface
 	return s.userRepo.List(page, pageSize, filters)
 }
 
+// VerifyUser marks a user's email address as verified.
 func (s *UserService) VerifyUser(userID uint) error {
+	if _, err := s.userRepo.FindByIDLite(userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+
 	return s.userRepo.VerifyUser(userID)
 }
 
-// DeactivateUser sets a user's IsActive field to false, deactivating the account.
-func (s *UserService) DeactivateUser(userID uint) error {
-	user, err := s.userRepo.FindByID(userID)
+// ChangeRole changes the role of a user and returns the updated user. Admins
+// cannot change their own role, so a site always keeps at least one admin.
+func (s *UserService) ChangeRole(actorID, userID uint, role string) (*models.User, error) {
+	if role != types.RoleUser && role != types.RoleEditor && role != types.RoleAdmin {
+		return nil, invalid("role must be one of user, editor or admin")
+	}
+	if actorID == userID {
+		return nil, fmt.Errorf("%w: admins cannot change their own role", ErrForbidden)
+	}
+
+	user, err := s.userRepo.FindByIDLite(userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	if err := s.userRepo.UpdateRole(userID, role); err != nil {
+		return nil, err
+	}
+	user.Role = role
+	return user, nil
+}
+
+// ApproveUser approves a user registered while the site required approval.
+func (s *UserService) ApproveUser(userID uint) error {
+	if _, err := s.userRepo.FindByIDLite(userID); err != nil {
+		return ErrUserNotFound
+	}
+
+	return s.userRepo.ApproveUser(userID)
+}
+
+// SetMembership grants or revokes the membership of a user, which gives early
+// access to posts, and returns the updated user.
+func (s *UserService) SetMembership(userID uint, member bool) (*models.User, error) {
+	user, err := s.userRepo.FindByIDLite(userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	if err := s.userRepo.UpdateMember(userID, member); err != nil {
+		return nil, err
+	}
+	user.Member = member
+	return user, nil
+}
+
+// DeactivateUser sets a user's IsActive field to false, deactivating the
+// account, and revokes its sessions. Admins cannot deactivate their own
+// account.
+func (s *UserService) DeactivateUser(actorID, userID uint) error {
+	if actorID == userID {
+		return fmt.Errorf("%w: admins cannot deactivate their own account", ErrForbidden)
+	}
+
+	user, err := s.userRepo.FindByIDLite(userID)
 	if err != nil {
-		return errors.New("user not found")
+		return ErrUserNotFound
 	}
 
 	user.IsActive = false
-	return s.userRepo.Update(user)
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	return s.sessionRepo.DeleteByUser(userID)
 }
 
-// DeleteUser removes a user from the database by its ID.
-func (s *UserService) DeleteUser(userID uint) error {
+// DeleteUser removes a user from the database by its ID, and revokes its
+// sessions. Admins cannot delete their own account.
+func (s *UserService) DeleteUser(actorID, userID uint) error {
+	if actorID == userID {
+		return fmt.Errorf("%w: admins cannot delete their own account", ErrForbidden)
+	}
+
+	if _, err := s.userRepo.FindByIDLite(userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+
+	if err := s.sessionRepo.DeleteByUser(userID); err != nil {
+		return err
+	}
 	return s.userRepo.Delete(userID)
 }
 
@@ -108,51 +299,32 @@ func (s *UserService) DeleteUser(userID uint) error {
 func validateUserUpdate(user *models.User) error {
 	// Validate first name and last name
 	if user.FirstName != "" && (len(user.FirstName) < 2 || len(user.FirstName) > 50) {
-		return errors.New("first name must be between 2 and 50 characters")
+		return invalid("first name must be between 2 and 50 characters")
 	}
 
 	if user.LastName != "" && (len(user.LastName) < 2 || len(user.LastName) > 50) {
-		return errors.New("last name must be between 2 and 50 characters")
+		return invalid("last name must be between 2 and 50 characters")
 	}
 
 	// Validate bio
 	if user.Bio != "" && len(user.Bio) > 500 {
-		return errors.New("bio cannot exceed 500 characters")
+		return invalid("bio cannot exceed 500 characters")
+	}
+
+	// Validate social links
+	if user.TwitterHandle != "" && !twitterHandle.MatchString(user.TwitterHandle) {
+		return invalid("twitter handle must be at most 15 letters, digits or underscores")
 	}
 
 	return nil
 }
 
-// validatePassword validates a password according to the following rules:
+// validatePassword validates a password against the strong password rules:
+// at least 8 characters, with an uppercase letter, a lowercase letter, a digit
+// and a special character.
 func validatePassword(password string) error {
-	// Check password complexity
-	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters long")
-	}
-
-	// Additional complexity checks can be added here
-	// For example, checking for uppercase, lowercase, numbers, special characters
-	hasUpper := false
-	hasLower := false
-	hasNumber := false
-	hasSpecial := false
-
-	for _, char := range password {
-		switch {
-		case char >= 'A' && char <= 'Z':
-			hasUpper = true
-		case char >= 'a' && char <= 'z':
-			hasLower = true
-		case char >= '0' && char <= '9':
-			hasNumber = true
-		case (char >= '!' && char <= '/') || (char >= ':' && char <= '@'):
-			hasSpecial = true
-		}
+	if !utils.IsStrongPassword(password) {
+		return invalid("password must be at least 8 characters and include uppercase, lowercase, number, and special character")
 	}
-
-	if !hasUpper || !hasLower || !hasNumber || !hasSpecial {
-		return errors.New("password must include uppercase, lowercase, number, and special character")
-	}
-
 	return nil
 }
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/mailer"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/utils"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrAlreadyVerified is returned when requesting verification of a verified user.
+var ErrAlreadyVerified = errors.New("email already verified")
+
+type VerificationService struct {
+	userRepo  *repositories.UserRepository
+	tokenRepo *repositories.VerificationTokenRepository
+	mailer    mailer.Mailer
+	logger    *zap.Logger
+}
+
+// NewVerificationService returns a new instance of VerificationService, which
+// issues email verification tokens and redeems them.
+func NewVerificationService(
+	userRepo *repositories.UserRepository,
+	tokenRepo *repositories.VerificationTokenRepository,
+	mailer mailer.Mailer,
+	logger *zap.Logger,
+) *VerificationService {
+	return &VerificationService{
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		mailer:    mailer,
+		logger:    logger,
+	}
+}
+
+// SendVerification issues a new verification token for the user and emails
+// the verification link. Previously issued tokens are revoked.
+func (s *VerificationService) SendVerification(ctx context.Context, user *models.User) error {
+	if user.VerifiedAt != nil {
+		return ErrAlreadyVerified
+	}
+
+	// Revoke previous tokens
+	if err := s.tokenRepo.DeleteByUserID(user.ID); err != nil {
+		return err
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Duration(config.Get().Email.VerificationTTLHours) * time.Hour
+	if err := s.tokenRepo.Create(&models.VerificationToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/users/verify?token=%s", config.Get().Server.BaseURL, token)
+	msg, err := mailer.Render(mailer.TemplateVerification, []string{user.Email}, map[string]interface{}{
+		"Username":  user.Username,
+		"Link":      link,
+		"ExpiresIn": ttl,
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, msg)
+}
+
+// ResendVerification sends a new verification email to the user with the
+// given email address. Unknown and verified addresses are ignored, and other
+// failures logged, rather than reported, so that requests can't tell them
+// apart.
+func (s *VerificationService) ResendVerification(ctx context.Context, email string) {
+	user, err := s.userRepo.FindByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	if err == nil {
+		err = s.SendVerification(ctx, user)
+	}
+	if err != nil && !errors.Is(err, ErrAlreadyVerified) {
+		s.logger.Error("Failed to resend a verification email", zap.Error(err))
+	}
+}
+
+// Verify redeems a verification token and marks the user as verified. It
+// returns the ID of the verified user.
+func (s *VerificationService) Verify(token string) (uint, error) {
+	record, err := s.tokenRepo.FindValidByHash(utils.HashToken(token))
+	if err != nil {
+		return 0, errors.New("invalid or expired verification token")
+	}
+
+	if err := s.tokenRepo.MarkUsed(record.ID); err != nil {
+		return 0, err
+	}
+
+	return record.UserID, s.userRepo.VerifyUser(record.UserID)
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/types"
+
+	"go.uber.org/zap"
+)
+
+// View statistics periods, in days
+const (
+	defaultStatsDays = 30
+	maxStatsDays     = 365
+)
+
+// topPostsSize is the number of posts ranked in the stats of an author
+const topPostsSize = 10
+
+// ViewStats are the views of a post or of the posts of an author over the
+// last days, with a row for every day, in UTC.
+type ViewStats struct {
+	Since      time.Time                 `json:"since"`
+	TotalViews int64                     `json:"total_views"` // Since the post or the author started
+	Views      int64                     `json:"views"`       // Over the period
+	Days       []repositories.DailyViews `json:"days"`
+	TopPosts   []repositories.PostViews  `json:"top_posts,omitempty"` // Most viewed posts of the author over the period
+}
+
+// ViewService counts the views of posts, once per visitor within the
+// deduplication window, and reports them over time. Visitors are kept
+// hashed, in memory, so each instance deduplicates the views it serves.
+type ViewService struct {
+	viewRepo *repositories.PostViewRepository
+	postRepo *repositories.PostRepository
+	userRepo *repositories.UserRepository
+	logger   *zap.Logger
+	viewed   *viewedPosts
+}
+
+// viewedPosts remembers the last counted view of each visitor on each post,
+// shared by the copies of the service scoped to requests.
+type viewedPosts struct {
+	mu        sync.Mutex
+	seen      map[[sha256.Size]byte]time.Time // Last counted view of a visitor on a post
+	lastSweep time.Time
+}
+
+// NewViewService returns a new instance of ViewService with the provided
+// PostViewRepository, PostRepository and UserRepository.
+func NewViewService(viewRepo *repositories.PostViewRepository, postRepo *repositories.PostRepository, userRepo *repositories.UserRepository, logger *zap.Logger) *ViewService {
+	return &ViewService{
+		viewRepo: viewRepo,
+		postRepo: postRepo,
+		userRepo: userRepo,
+		logger:   logger,
+		viewed:   &viewedPosts{seen: make(map[[sha256.Size]byte]time.Time), lastSweep: time.Now()},
+	}
+}
+
+// Record counts a view of a post by a visitor, e.g. a user ID or else an IP
+// address and user agent, unless the visitor viewed the post within the
+// deduplication window or the user agent is an ignored one. Failures are
+// only logged, so they never fail the view.
+func (s *ViewService) Record(postID uint, visitor, userAgent string) {
+	cfg := config.Get().Views
+	agent := strings.ToLower(userAgent)
+	for _, ignored := range cfg.IgnoredAgents {
+		if ignored != "" && strings.Contains(agent, strings.ToLower(ignored)) {
+			return
+		}
+	}
+
+	if !s.firstView(postID, visitor, cfg.DedupWindow) {
+		return
+	}
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	if err := s.viewRepo.Record(postID, day); err != nil {
+		s.logger.Error("Failed to record view", zap.Uint("post_id", postID), zap.Error(err))
+	}
+}
+
+// firstView reports whether a visitor didn't view a post within the window,
+// and remembers the view. Expired views are swept once per window.
+func (s *ViewService) firstView(postID uint, visitor string, window time.Duration) bool {
+	key := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s", postID, visitor)))
+	now := time.Now()
+
+	viewed := s.viewed
+	viewed.mu.Lock()
+	defer viewed.mu.Unlock()
+	if now.Sub(viewed.lastSweep) >= window {
+		for k, seenAt := range viewed.seen {
+			if now.Sub(seenAt) >= window {
+				delete(viewed.seen, k)
+			}
+		}
+		viewed.lastSweep = now
+	}
+
+	if seenAt, ok := viewed.seen[key]; ok && now.Sub(seenAt) < window {
+		return false
+	}
+	viewed.seen[key] = now
+	return true
+}
+
+// PostStats returns the views of a post over the last days, 30 by default.
+// Only the authors of the post and admins can see them.
+func (s *ViewService) PostStats(postID, userID uint, days int) (*ViewStats, error) {
+	post, err := s.postRepo.FindByID(postID)
+	if err != nil {
+		return nil, notFound(err, ErrPostNotFound)
+	}
+	if post.AuthorRole(userID) == "" {
+		role, err := s.userRepo.FindRole(userID)
+		if err != nil {
+			return nil, notFound(err, ErrUserNotFound)
+		}
+		if role != types.RoleAdmin {
+			return nil, fmt.Errorf("%w: only the author can see the stats of this post", ErrForbidden)
+		}
+	}
+
+	since := statsSince(days)
+	daily, err := s.viewRepo.DailyForPost(postID, since)
+	if err != nil {
+		return nil, err
+	}
+	return newViewStats(since, int64(post.ViewCount), daily), nil
+}
+
+// AuthorStats returns the views of the posts of a user over the last days,
+// 30 by default, with their most viewed posts.
+func (s *ViewService) AuthorStats(userID uint, days int) (*ViewStats, error) {
+	since := statsSince(days)
+	daily, err := s.viewRepo.DailyForAuthor(userID, since)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.postRepo.SumViewCount(userID)
+	if err != nil {
+		return nil, err
+	}
+	top, err := s.viewRepo.TopPostsForAuthor(userID, since, topPostsSize)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := newViewStats(since, total, daily)
+	stats.TopPosts = top
+	return stats, nil
+}
+
+// statsSince returns the first day of the stats over the last days,
+// including today.
+func statsSince(days int) time.Time {
+	if days < 1 || days > maxStatsDays {
+		days = defaultStatsDays
+	}
+	return time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+}
+
+// newViewStats returns the stats of the daily views since the given day,
+// adding the days without views.
+func newViewStats(since time.Time, total int64, daily []repositories.DailyViews) *ViewStats {
+	views := make(map[time.Time]int64, len(daily))
+	for _, d := range daily {
+		views[d.Day.UTC()] += d.Views
+	}
+
+	stats := &ViewStats{Since: since, TotalViews: total, Days: []repositories.DailyViews{}}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for day := since; !day.After(today); day = day.AddDate(0, 0, 1) {
+		stats.Days = append(stats.Days, repositories.DailyViews{Day: day, Views: views[day]})
+		stats.Views += views[day]
+	}
+	return stats
+}
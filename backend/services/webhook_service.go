@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/events"
+	"github.com/SteaceP/coderage/models"
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/utils"
+	"github.com/SteaceP/coderage/webhooks"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Errors returned by the webhook service
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// Automatic retries of the failed deliveries
+const (
+	// maxRetryBackoff caps the wait between two attempts
+	maxRetryBackoff = 24 * time.Hour
+	// retryBatchSize is the number of due retries sent per round
+	retryBatchSize = 100
+)
+
+type WebhookService struct {
+	webhookRepo *repositories.WebhookRepository
+	sender      webhooks.Sender
+	logger      *zap.Logger
+}
+
+// NewWebhookService returns a new instance of WebhookService with the provided
+// WebhookRepository, delivering events through sender.
+func NewWebhookService(webhookRepo *repositories.WebhookRepository, sender webhooks.Sender, logger *zap.Logger) *WebhookService {
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		sender:      sender,
+		logger:      logger,
+	}
+}
+
+// CreateWebhook registers a new webhook. A secret is generated when none is
+// provided.
+func (s *WebhookService) CreateWebhook(webhook *models.Webhook) error {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalid("url must be an absolute http or https URL")
+	}
+	if len(webhook.Events) == 0 {
+		return invalid("at least one event is required")
+	}
+	if webhook.Secret == "" {
+		secret, err := utils.GenerateRandomToken(32)
+		if err != nil {
+			return err
+		}
+		webhook.Secret = secret
+	}
+	webhook.Active = true
+
+	return s.webhookRepo.Create(webhook)
+}
+
+// ListWebhooks retrieves every webhook.
+func (s *WebhookService) ListWebhooks() ([]models.Webhook, error) {
+	return s.webhookRepo.List()
+}
+
+// GetWebhook retrieves a webhook by its ID.
+func (s *WebhookService) GetWebhook(id uint) (*models.Webhook, error) {
+	webhook, err := s.webhookRepo.FindByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrWebhookNotFound)
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes a webhook.
+func (s *WebhookService) DeleteWebhook(id uint) error {
+	if _, err := s.GetWebhook(id); err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(id)
+}
+
+// ListDeliveries retrieves the deliveries of a webhook with pagination.
+func (s *WebhookService) ListDeliveries(webhookID uint, page, pageSize int) ([]models.WebhookDelivery, int64, error) {
+	if _, err := s.GetWebhook(webhookID); err != nil {
+		return nil, 0, err
+	}
+	return s.webhookRepo.ListDeliveries(webhookID, page, pageSize)
+}
+
+// GetDelivery retrieves a delivery of a webhook, with its request and response.
+func (s *WebhookService) GetDelivery(webhookID, deliveryID uint) (*models.WebhookDelivery, error) {
+	delivery, err := s.webhookRepo.FindDelivery(webhookID, deliveryID)
+	if err != nil {
+		return nil, notFound(err, ErrDeliveryNotFound)
+	}
+	return delivery, nil
+}
+
+// SendTest sends a sample ping event to a webhook, whether or not it is
+// active or subscribed to it, and returns the delivery.
+func (s *WebhookService) SendTest(ctx context.Context, webhookID uint) (*models.WebhookDelivery, error) {
+	webhook, err := s.GetWebhook(webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := events.NewEvent(webhooks.PingEvent, map[string]interface{}{
+		"message":    "This is a test delivery.",
+		"webhook_id": webhook.ID,
+		"events":     webhook.Events,
+	})
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.deliver(ctx, webhook, event.Type, body, nil, 0)
+}
+
+// Redeliver sends the payload of a previous delivery again, signed with the
+// current secret of the webhook, and returns the new delivery.
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID, deliveryID uint) (*models.WebhookDelivery, error) {
+	webhook, err := s.GetWebhook(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.GetDelivery(webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.deliver(ctx, webhook, previous.EventType, []byte(previous.RequestBody), &previous.ID, 0)
+}
+
+// HandleEvent delivers a bus event to the active webhooks subscribed to it.
+// Failed deliveries are retried by RunRetries.
+func (s *WebhookService) HandleEvent(ctx context.Context, event events.Event) error {
+	hooks, err := s.webhookRepo.ListActive()
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	for i := range hooks {
+		if !hooks[i].Subscribes(event.Type) {
+			continue
+		}
+		if _, err := s.deliver(ctx, &hooks[i], event.Type, body, nil, 1); err != nil {
+			s.logger.Error("Failed to record webhook delivery",
+				zap.Uint("webhook_id", hooks[i].ID),
+				zap.String("event_id", event.ID),
+				zap.Error(err),
+			)
+		}
+	}
+	return nil
+}
+
+// RetryDeliveries sends the retries that are due, and returns the number of
+// deliveries sent. Retries of the webhooks deleted or deactivated since are
+// dropped.
+func (s *WebhookService) RetryDeliveries(ctx context.Context) (int, error) {
+	due, err := s.webhookRepo.FindDueRetries(time.Now(), retryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	hooks := make(map[uint]*models.Webhook)
+	for i := range due {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		previous := &due[i]
+		webhook, ok := hooks[previous.WebhookID]
+		if !ok {
+			webhook, err = s.webhookRepo.FindByID(previous.WebhookID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return sent, err
+			}
+			hooks[previous.WebhookID] = webhook
+		}
+
+		// Claiming the retry also drops those of the missing webhooks
+		claimed, err := s.webhookRepo.ClaimRetry(previous.ID)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed || webhook == nil || !webhook.Active {
+			continue
+		}
+
+		if _, err := s.deliver(ctx, webhook, previous.EventType, []byte(previous.RequestBody), &previous.ID, previous.Attempt+1); err != nil {
+			s.logger.Error("Failed to record webhook delivery",
+				zap.Uint("webhook_id", webhook.ID),
+				zap.Uint("redelivery_of", previous.ID),
+				zap.Error(err),
+			)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// RunRetries sends the due retries every interval until the context is
+// canceled.
+func (s *WebhookService) RunRetries(ctx context.Context, interval time.Duration) {
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := s.RetryDeliveries(ctx)
+			if err != nil {
+				s.logger.Error("Failed to retry webhook deliveries", zap.Error(err))
+				continue
+			}
+			if sent > 0 {
+				s.logger.Info("Webhook deliveries retried", zap.Int("count", sent))
+			}
+		}
+	}
+}
+
+// deliver sends a payload to a webhook and stores the delivery. Automatic
+// attempts that fail are scheduled for a retry, waiting twice as long after
+// each one, until the maximum number of attempts is reached.
+func (s *WebhookService) deliver(ctx context.Context, webhook *models.Webhook, eventType string, body []byte, redeliveryOf *uint, attempt int) (*models.WebhookDelivery, error) {
+	req := webhooks.Request{
+		URL:        webhook.URL,
+		Secret:     webhook.Secret,
+		EventType:  eventType,
+		DeliveryID: uuid.New().String(),
+		Body:       body,
+	}
+	result := s.sender.Send(ctx, req)
+
+	delivery := &models.WebhookDelivery{
+		WebhookID:          webhook.ID,
+		GUID:               req.DeliveryID,
+		EventType:          eventType,
+		RedeliveryOf:       redeliveryOf,
+		Attempt:            attempt,
+		Success:            result.Err == nil && result.StatusCode >= 200 && result.StatusCode < 300,
+		DurationMs:         result.Duration.Milliseconds(),
+		RequestHeaders:     result.RequestHeaders,
+		RequestBody:        string(body),
+		StatusCode:         result.StatusCode,
+		ResponseHeaders:    result.ResponseHeaders,
+		ResponseBody:       result.ResponseBody,
+		SignatureAlgorithm: webhooks.SignatureAlgorithm,
+		Signature:          result.RequestHeaders[webhooks.SignatureHeader],
+		PayloadSHA256:      webhooks.PayloadDigest(body),
+		SecretFingerprint:  webhooks.SecretFingerprint(webhook.Secret),
+	}
+	if result.Err != nil {
+		delivery.Error = result.Err.Error()
+	}
+	if retries := config.Get().Webhooks.Retries; !delivery.Success && attempt > 0 && attempt < retries.MaxAttempts {
+		backoff := retries.Backoff
+		for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+			backoff *= 2
+		}
+		next := time.Now().Add(min(backoff, maxRetryBackoff))
+		delivery.NextRetryAt = &next
+	}
+
+	if err := s.webhookRepo.CreateDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
@@ -0,0 +1,79 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+)
+
+// Akismet checks the comments with the Akismet service, on behalf of
+// "site.url".
+type Akismet struct {
+	http   *http.Client
+	url    string
+	apiKey string
+}
+
+// NewAkismet returns a new instance of Akismet with the provided
+// configuration.
+func NewAkismet(cfg config.AkismetConfig) *Akismet {
+	return &Akismet{
+		http:   &http.Client{Timeout: cfg.Timeout},
+		url:    strings.TrimSuffix(cfg.URL, "/") + "/1.1/comment-check",
+		apiKey: cfg.APIKey,
+	}
+}
+
+func (a *Akismet) Check(ctx context.Context, comment Comment) (Verdict, error) {
+	commentType := "comment"
+	if comment.Reply {
+		commentType = "reply"
+	}
+	form := url.Values{
+		"api_key":              {a.apiKey},
+		"blog":                 {config.Get().Site.URL},
+		"blog_lang":            {config.Get().Site.Locale},
+		"user_ip":              {comment.IP},
+		"user_agent":           {comment.UserAgent},
+		"referrer":             {comment.Referrer},
+		"permalink":            {comment.Permalink},
+		"comment_type":         {commentType},
+		"comment_author":       {comment.AuthorName},
+		"comment_author_email": {comment.AuthorEmail},
+		"comment_content":      {comment.Content},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	// Akismet answers true or false, and explains anything else in a header
+	switch strings.TrimSpace(string(body)) {
+	case "true":
+		return Verdict{Spam: true, Reason: "flagged by Akismet"}, nil
+	case "false":
+		return Verdict{}, nil
+	default:
+		help := resp.Header.Get("X-akismet-debug-help")
+		if help == "" {
+			help = resp.Status
+		}
+		return Verdict{}, fmt.Errorf("akismet rejected the check: %s", help)
+	}
+}
@@ -0,0 +1,35 @@
+package spam
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+)
+
+// linkPattern matches the links of a comment, written as URLs or bare
+// www. addresses
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// Heuristics checks the comments against "moderation.spam.max_links" and
+// "moderation.spam.banned_words", read on each check.
+type Heuristics struct{}
+
+func (Heuristics) Check(_ context.Context, comment Comment) (Verdict, error) {
+	cfg := config.Get().Moderation.Spam
+
+	if links := len(linkPattern.FindAllString(comment.Content, -1)); cfg.MaxLinks > 0 && links > cfg.MaxLinks {
+		return Verdict{Spam: true, Reason: fmt.Sprintf("holds %d links, more than %d", links, cfg.MaxLinks)}, nil
+	}
+
+	text := strings.ToLower(comment.AuthorName + "\n" + comment.Content)
+	for _, word := range cfg.BannedWords {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" && strings.Contains(text, word) {
+			return Verdict{Spam: true, Reason: fmt.Sprintf("contains the banned word %q", word)}, nil
+		}
+	}
+	return Verdict{}, nil
+}
@@ -0,0 +1,77 @@
+// Package spam detects the comments likely to be spam, with the Akismet
+// service or with heuristics on their content.
+package spam
+
+import (
+	"context"
+
+	"github.com/SteaceP/coderage/config"
+
+	"go.uber.org/zap"
+)
+
+// Comment is a comment to check, along with its author and the request it
+// was sent with.
+type Comment struct {
+	Content     string
+	AuthorName  string
+	AuthorEmail string
+	IP          string
+	UserAgent   string
+	Referrer    string
+	Permalink   string // URL of the post commented on
+	Reply       bool   // Whether the comment replies to another one
+}
+
+// Verdict is the result of the check of a comment.
+type Verdict struct {
+	Spam   bool
+	Reason string // Why the comment is spam
+}
+
+// Checker checks whether comments are spam.
+type Checker interface {
+	Check(ctx context.Context, comment Comment) (Verdict, error)
+}
+
+// New returns the checker configured by "moderation.spam": Akismet, falling
+// back to the heuristics while it fails, when its API key is set, and the
+// heuristics alone otherwise. Disabled checks are skipped on each call, so
+// they can be turned on and off at runtime.
+func New(logger *zap.Logger) Checker {
+	var checker Checker = Heuristics{}
+	if cfg := config.Get().Moderation.Spam.Akismet; cfg.APIKey != "" {
+		checker = &fallback{primary: NewAkismet(cfg), secondary: checker, logger: logger}
+	}
+	return enabled{checker}
+}
+
+// enabled skips the checks of a checker while "moderation.spam.enabled" is
+// off.
+type enabled struct {
+	Checker
+}
+
+func (e enabled) Check(ctx context.Context, comment Comment) (Verdict, error) {
+	if !config.Get().Moderation.Spam.Enabled {
+		return Verdict{}, nil
+	}
+	return e.Checker.Check(ctx, comment)
+}
+
+// fallback checks the comments with a secondary checker while the primary
+// one fails.
+type fallback struct {
+	primary   Checker
+	secondary Checker
+	logger    *zap.Logger
+}
+
+func (f *fallback) Check(ctx context.Context, comment Comment) (Verdict, error) {
+	verdict, err := f.primary.Check(ctx, comment)
+	if err == nil {
+		return verdict, nil
+	}
+	f.logger.Warn("Spam check failed, falling back to the heuristics", zap.Error(err))
+	return f.secondary.Check(ctx, comment)
+}
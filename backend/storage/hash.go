@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+// HashLength is the number of hex digits of the content hash put in keys,
+// enough to tell versions of a file apart
+const HashLength = 16
+
+// CacheControl is the Cache-Control of the stored objects. Objects are
+// never overwritten, a new upload getting a new key with the hash of its
+// content, so clients and CDNs can cache them for good.
+const CacheControl = "public, max-age=31536000, immutable"
+
+// ContentHash returns the hex encoded SHA-256 hash of the content read from r.
+func ContentHash(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Config holds the settings of an S3 compatible bucket.
+type S3Config struct {
+	Bucket    string
+	Region    string
+	Endpoint  string // Optional, for S3 compatible services such as MinIO
+	AccessKey string // Optional, the default AWS credential chain is used otherwise
+	SecretKey string
+	PublicURL string // Optional, e.g. a CDN in front of the bucket
+}
+
+// S3Storage stores files in an S3 bucket.
+type S3Storage struct {
+	client    *s3.Client
+	bucket    string
+	publicURL string
+}
+
+// NewS3Storage returns a new S3Storage for the configured bucket.
+func NewS3Storage(ctx context.Context, config S3Config) (*S3Storage, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("s3 bucket is not set")
+	}
+
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(config.Region),
+	}
+	if config.AccessKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(config.AccessKey, config.SecretKey, ""),
+		))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(config.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	publicURL := config.PublicURL
+	if publicURL == "" {
+		if config.Endpoint != "" {
+			publicURL = joinURL(config.Endpoint, config.Bucket)
+		} else {
+			publicURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", config.Bucket, config.Region)
+		}
+	}
+
+	return &S3Storage{client: client, bucket: config.Bucket, publicURL: publicURL}, nil
+}
+
+// Name returns "s3".
+func (s *S3Storage) Name() string {
+	return "s3"
+}
+
+// Put uploads the content to the bucket.
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          r,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(contentType),
+		CacheControl:  aws.String(CacheControl),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %v", err)
+	}
+	return nil
+}
+
+// Get downloads the object stored under key.
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to download object: %v", err)
+	}
+	return out.Body, nil
+}
+
+// Delete removes the object stored under key.
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %v", err)
+	}
+	return nil
+}
+
+// URL returns the public URL of the object stored under key.
+func (s *S3Storage) URL(key string) string {
+	return joinURL(s.publicURL, key)
+}
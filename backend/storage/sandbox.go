@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// sandboxStorage reads through to a storage backend but drops every write.
+type sandboxStorage struct {
+	Storage
+}
+
+// NewSandboxStorage returns a Storage serving the objects of s that discards
+// new objects and ignores deletions, for sandbox requests.
+func NewSandboxStorage(s Storage) Storage {
+	return sandboxStorage{Storage: s}
+}
+
+// Sandboxed reports whether s drops every write, serving sandbox requests.
+func Sandboxed(s Storage) bool {
+	_, ok := s.(sandboxStorage)
+	return ok
+}
+
+// Put reads the content to validate it can be stored, and discards it.
+func (s sandboxStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
+// Delete does nothing.
+func (s sandboxStorage) Delete(ctx context.Context, key string) error {
+	return nil
+}
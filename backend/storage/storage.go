@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/SteaceP/coderage/config"
+)
+
+// ErrNotFound is returned when an object doesn't exist in the storage.
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores uploaded files under keys and exposes them through public URLs.
+type Storage interface {
+	// Name identifies the backend, e.g. "local" or "s3".
+	Name() string
+	// Put stores the content read from r under key.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key.
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL of the object stored under key.
+	URL(key string) string
+}
+
+// New returns the storage backend selected by the "storage.driver"
+// configuration key. Local disk storage is used by default.
+func New(ctx context.Context) (Storage, error) {
+	return NewDriver(ctx, config.Get().Storage.Driver)
+}
+
+// NewDriver returns the storage backend of the given driver, configured by
+// its section of the storage configuration, e.g. for migrating files
+// between backends.
+func NewDriver(ctx context.Context, driver string) (Storage, error) {
+	cfg := config.Get().Storage
+	switch driver {
+	case "", "local":
+		return NewLocalStorage(
+			cfg.Local.Path,
+			cfg.Local.BaseURL,
+		)
+	case "s3":
+		return NewS3Storage(ctx, S3Config{
+			Bucket:    cfg.S3.Bucket,
+			Region:    cfg.S3.Region,
+			Endpoint:  cfg.S3.Endpoint,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			PublicURL: cfg.S3.PublicURL,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported storage driver: %s", driver)
+	}
+}
+
+// joinURL joins a base URL and an object key.
+func joinURL(base, key string) string {
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(key, "/")
+}
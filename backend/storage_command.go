@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os/signal"
+	"syscall"
+
+	"github.com/SteaceP/coderage/repositories"
+	"github.com/SteaceP/coderage/services"
+	"github.com/SteaceP/coderage/storage"
+
+	"go.uber.org/zap"
+)
+
+const storageUsage = `Usage:
+  api storage migrate -to s3 [-from local] [-batch 100] [-delete-source]
+      Move the uploaded files to another storage backend, rewriting the
+      media records and the posts linking to them. Run it again to resume
+      an interrupted migration, then switch storage.driver to the new backend.
+`
+
+// runStorageCommand runs the storage subcommand with its arguments, and
+// returns the exit code.
+func runStorageCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "migrate" {
+		fmt.Fprint(stderr, storageUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	from := flags.String("from", "local", "storage driver holding the files")
+	to := flags.String("to", "", "storage driver to move the files to")
+	batch := flags.Int("batch", 100, "number of files moved per transaction")
+	deleteSource := flags.Bool("delete-source", false, "delete the files from the previous backend once moved")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *to == "" || *to == *from || *batch < 1 {
+		fmt.Fprint(stderr, storageUsage)
+		return 2
+	}
+
+	// Load configuration, and connect to the database
+	_, logger, db, err := bootstrap(false)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer logger.Sync()
+
+	// Stop between batches on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	source, err := storage.NewDriver(ctx, *from)
+	if err != nil {
+		logger.Error("Storage initialization failed", zap.String("driver", *from), zap.Error(err))
+		return 1
+	}
+	target, err := storage.NewDriver(ctx, *to)
+	if err != nil {
+		logger.Error("Storage initialization failed", zap.String("driver", *to), zap.Error(err))
+		return 1
+	}
+
+	media := services.NewMediaService(repositories.NewMediaRepository(db))
+	var afterID uint
+	moved := 0
+	for ctx.Err() == nil {
+		n, lastID, err := media.MigrateMedia(ctx, source, target, afterID, *batch, *deleteSource)
+		moved += n
+		if err != nil {
+			logger.Error("Storage migration failed, run it again to resume", zap.Int("moved", moved), zap.Error(err))
+			return 1
+		}
+		if n == 0 {
+			fmt.Fprintf(stdout, "Moved %d files from %s to %s\n", moved, source.Name(), target.Name())
+			return 0
+		}
+		afterID = lastID
+		logger.Info("Moved a batch of files", zap.Int("moved", moved), zap.Uint("last_media_id", lastID))
+	}
+	logger.Warn("Storage migration interrupted, run it again to resume", zap.Int("moved", moved))
+	return 1
+}
@@ -0,0 +1,101 @@
+// Package tlsserver sets up the HTTPS serving of the API without a reverse
+// proxy, from the "server.tls" configuration key: a static certificate or
+// certificates obtained from Let's Encrypt, and the listener redirecting
+// HTTP to HTTPS.
+package tlsserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Config returns the TLS configuration of the server, and the handler of
+// the redirect listener, which answers the ACME challenges of Let's Encrypt
+// before redirecting to HTTPS. Both are nil when TLS is disabled.
+//
+// HTTP/2 is negotiated by the server on top of the returned configuration.
+func Config() (*tls.Config, http.Handler, error) {
+	cfg := config.Get().Server
+	if !cfg.TLS.Enabled {
+		return nil, nil, nil
+	}
+
+	redirect := Redirect(cfg.Port)
+	var tlsConfig *tls.Config
+	switch {
+	case cfg.TLS.CertFile != "" && len(cfg.TLS.Autocert.Domains) > 0:
+		return nil, nil, errors.New("server.tls: set either cert_file and key_file, or autocert.domains")
+	case cfg.TLS.CertFile != "":
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load the TLS certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	case len(cfg.TLS.Autocert.Domains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLS.Autocert.Domains...),
+			Cache:      autocert.DirCache(cfg.TLS.Autocert.CacheDir),
+			Email:      cfg.TLS.Autocert.Email,
+		}
+		if cfg.TLS.Autocert.DirectoryURL != "" {
+			manager.Client = &acme.Client{DirectoryURL: cfg.TLS.Autocert.DirectoryURL}
+		}
+		tlsConfig = manager.TLSConfig()
+		redirect = manager.HTTPHandler(redirect)
+	default:
+		return nil, nil, errors.New("server.tls: cert_file and key_file, or autocert.domains, are required")
+	}
+
+	tlsConfig.MinVersion = tls.VersionTLS12
+	tlsConfig.CurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256}
+	// Only forward secret AEAD suites for TLS 1.2, TLS 1.3 picks its own
+	tlsConfig.CipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	}
+	return tlsConfig, redirect, nil
+}
+
+// Redirect returns the handler redirecting requests to the same URL over
+// HTTPS, on the given port.
+func Redirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// RedirectServer returns the server listening for HTTP on the redirect port
+// with the given handler, nil when the redirect is disabled.
+func RedirectServer(handler http.Handler) *http.Server {
+	port := config.Get().Server.TLS.RedirectPort
+	if handler == nil || port == "" {
+		return nil
+	}
+	return &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       30 * time.Second,
+	}
+}
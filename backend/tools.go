@@ -0,0 +1,8 @@
+//go:build tools
+
+package main
+
+// Tools run by go generate, tracked here so they stay in go.mod
+import (
+	_ "github.com/99designs/gqlgen"
+)
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey stores the span of a query on its statement
+const spanKey = "tracing:span"
+
+// querySpan is the span of a query, with the context it replaced.
+type querySpan struct {
+	span   trace.Span
+	parent context.Context
+}
+
+// GormPlugin records a span for every query run with the context of a traced
+// request. Queries without a parent span, such as those of the background
+// jobs, are not traced.
+//
+// The spans hold the SQL statement with its placeholders, never the values.
+type GormPlugin struct{}
+
+// Name returns the name of the plugin.
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize registers the callbacks of the plugin on db.
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("gorm:create").Register("tracing:before_insert", p.before("insert")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_insert", p.after),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_select", p.before("select")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_select", p.after),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", p.before("update")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", p.after),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", p.before("delete")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", p.after),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", p.before("row")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", p.after),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", p.before("raw")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", p.after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// before starts the span of a query.
+func (GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		parent := db.Statement.Context
+		if parent == nil || !trace.SpanContextFromContext(parent).IsValid() {
+			return
+		}
+
+		name := operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		ctx, span := Tracer().Start(parent, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(db.Dialector.Name()),
+				semconv.DBOperationName(operation),
+			),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, &querySpan{span: span, parent: parent})
+	}
+}
+
+// after ends the span of a query, recording its statement and outcome.
+func (GormPlugin) after(db *gorm.DB) {
+	v, _ := db.InstanceGet(spanKey)
+	qs, ok := v.(*querySpan)
+	if !ok || qs == nil {
+		return
+	}
+	db.InstanceSet(spanKey, (*querySpan)(nil))
+	// Later queries of the same statement are siblings, not children
+	db.Statement.Context = qs.parent
+
+	qs.span.SetAttributes(
+		semconv.DBCollectionName(db.Statement.Table),
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		qs.span.RecordError(db.Error)
+		qs.span.SetStatus(codes.Error, db.Error.Error())
+	}
+	qs.span.End()
+}
@@ -0,0 +1,65 @@
+// Package tracing exports OpenTelemetry traces of the requests and of the
+// database queries they run to an OTLP collector, such as Jaeger or Tempo.
+package tracing
+
+import (
+	"context"
+
+	"github.com/SteaceP/coderage/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation names the tracer of the API
+const instrumentation = "github.com/SteaceP/coderage"
+
+// Tracer returns the tracer of the API. Its spans are dropped until Init has
+// installed an exporter.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Init installs the OTLP exporter configured by the "tracing" settings as the
+// global tracer provider, and returns the function flushing the pending spans
+// on shutdown.
+//
+// Incoming requests continue the trace of their W3C traceparent header, so
+// the API can be traced from its callers. Sampling follows the decision of
+// the caller, and applies "tracing.sample_ratio" to new traces.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	cfg := config.Get().Tracing
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if headers := cfg.Headers; len(headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
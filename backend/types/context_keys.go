@@ -4,13 +4,30 @@ type contextKey string
 
 // Context keys
 const (
-	KeyUserID contextKey = "user_id"
-	KeyDB     contextKey = "db"
+	KeyUserID     contextKey = "user_id"
+	KeySessionID  contextKey = "session_id"
+	KeyDB         contextKey = "db"
+	KeyMailer     contextKey = "mailer"
+	KeyStorage    contextKey = "storage"
+	KeyExemption  contextKey = "ratelimit_exemption"
+	KeyRequestID  contextKey = "request_id"
+	KeyServices   contextKey = "services"
+	KeyCountry    contextKey = "country"
+	KeyQueryPlans contextKey = "query_plans"
+	KeyGraphQL    contextKey = "graphql"
 )
 
 // Constants
 const (
-	RoleAdmin string = "admin"
-	IDField   string = "id"
-	UserID    string = "user_id"
+	RoleAdmin  string = "admin"
+	RoleEditor string = "editor"
+	RoleUser   string = "user"
+	IDField    string = "id"
+	UserID     string = "user_id"
+)
+
+// Token types, of the "typ" claim of the tokens issued by the API
+const (
+	TokenAccess  string = "access"
+	TokenRefresh string = "refresh"
 )
@@ -0,0 +1,81 @@
+// Package unfurl fetches the OpenGraph and Twitter card metadata of web
+// pages, so links can be shown as previews.
+//
+// The pages are fetched on behalf of users through the guarded client of
+// the httpclient package, restricted to the standard web ports, and the size
+// of every fetch is bounded.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/httpclient"
+)
+
+// ErrNotHTML is returned when the fetched page isn't an HTML document
+var ErrNotHTML = errors.New("page is not an HTML document")
+
+// Fetcher fetches and parses web pages.
+type Fetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+}
+
+// NewFetcher returns a new instance of Fetcher configured from the "unfurl"
+// configuration.
+func NewFetcher() *Fetcher {
+	cfg := config.Get().Unfurl
+	return &Fetcher{
+		client: httpclient.New(httpclient.Options{
+			Name:    "unfurl",
+			Timeout: cfg.Timeout,
+			Ports:   []uint16{80, 443},
+		}),
+		maxBytes:  cfg.MaxBodySize,
+		userAgent: cfg.UserAgent,
+	}
+}
+
+// Fetch fetches the page at rawURL and returns its metadata. Redirects are
+// followed, and the returned metadata describes the final page.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := httpclient.CheckURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("page returned status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	// Metadata lives in the head, a truncated body is fine
+	return parse(io.LimitReader(resp.Body, f.maxBytes), resp.Request.URL)
+}
@@ -0,0 +1,145 @@
+package unfurl
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Field limits keep oversized metadata out of the previews
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+	maxURLLength         = 2048
+)
+
+// Metadata describes a page, from its OpenGraph properties, falling back to
+// its Twitter card and then to its title and description tags.
+type Metadata struct {
+	URL         string // Canonical URL of the page
+	Title       string
+	Description string
+	Image       string
+	SiteName    string
+	Type        string
+}
+
+// parse reads the metadata of the HTML document r, fetched from base. It
+// stops at the end of the head.
+func parse(r io.Reader, base *url.URL) (*Metadata, error) {
+	var (
+		properties = make(map[string]string)
+		title      strings.Builder
+		inTitle    bool
+	)
+
+	tokenizer := html.NewTokenizer(r)
+parse:
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				break parse
+			}
+			return nil, tokenizer.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Meta:
+				var key, content string
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "property", "name":
+						key = strings.ToLower(attr.Val)
+					case "content":
+						content = strings.TrimSpace(attr.Val)
+					}
+				}
+				// The first occurrence of a property wins
+				if _, ok := properties[key]; key != "" && !ok {
+					properties[key] = content
+				}
+			case atom.Link:
+				var rel, href string
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "rel":
+						rel = strings.ToLower(attr.Val)
+					case "href":
+						href = attr.Val
+					}
+				}
+				if rel == "canonical" {
+					properties["canonical"] = href
+				}
+			case atom.Title:
+				inTitle = true
+			case atom.Body:
+				break parse
+			}
+		case html.TextToken:
+			if inTitle {
+				title.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			switch tokenizer.Token().DataAtom {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				break parse
+			}
+		}
+	}
+
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if v := properties[key]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	metadata := &Metadata{
+		URL:         resolve(base, first("og:url", "canonical")),
+		Title:       truncate(first("og:title", "twitter:title"), maxTitleLength),
+		Description: truncate(first("og:description", "twitter:description", "description"), maxDescriptionLength),
+		Image:       resolve(base, first("og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src")),
+		SiteName:    truncate(first("og:site_name"), maxTitleLength),
+		Type:        truncate(first("og:type"), 50),
+	}
+	if metadata.URL == "" {
+		metadata.URL = base.String()
+	}
+	if metadata.Title == "" {
+		metadata.Title = truncate(strings.Join(strings.Fields(title.String()), " "), maxTitleLength)
+	}
+	return metadata, nil
+}
+
+// resolve returns ref as an absolute http or https URL relative to base, or
+// an empty string when it isn't one.
+func resolve(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	if s := u.String(); len(s) <= maxURLLength {
+		return s
+	}
+	return ""
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
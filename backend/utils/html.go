@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+)
+
+// headLinkRels are the link relations allowed in custom head snippets
+var headLinkRels = map[string]bool{
+	"icon":             true,
+	"apple-touch-icon": true,
+	"manifest":         true,
+	"stylesheet":       true,
+	"preconnect":       true,
+	"dns-prefetch":     true,
+	"me":               true,
+	"alternate":        true,
+}
+
+// headAttrs are the attributes kept on the elements allowed in custom head
+// snippets
+var headAttrs = map[string]map[string]bool{
+	"meta": {"name": true, "property": true, "content": true},
+	"link": {"rel": true, "href": true, "type": true, "sizes": true, "media": true, "hreflang": true, "title": true},
+}
+
+// SanitizeHeadSnippet strips an HTML snippet meant for the <head> of pages
+// down to <meta> and <link> tags. Scripts, styles, text and any other element
+// are dropped, along with event handler and http-equiv attributes, meta tags
+// without a name, links of other relations and links to URLs that aren't
+// https or relative.
+func SanitizeHeadSnippet(snippet string) string {
+	var b strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(snippet))
+	for {
+		if tokenizer.Next() == html.ErrorToken {
+			if tokenizer.Err() != io.EOF {
+				return ""
+			}
+			return strings.TrimSpace(b.String())
+		}
+
+		token := tokenizer.Token()
+		if token.Type != html.StartTagToken && token.Type != html.SelfClosingTagToken {
+			continue
+		}
+		allowed, ok := headAttrs[token.Data]
+		if !ok {
+			continue
+		}
+
+		attrs := make([]html.Attribute, 0, len(token.Attr))
+		for _, attr := range token.Attr {
+			if attr.Namespace == "" && allowed[attr.Key] {
+				attrs = append(attrs, attr)
+			}
+		}
+		token.Attr = attrs
+		if token.Data == "link" && !safeHeadLink(attrs) || token.Data == "meta" && !namedMeta(attrs) {
+			continue
+		}
+
+		token.Type = html.SelfClosingTagToken
+		b.WriteString(token.String())
+		b.WriteByte('\n')
+	}
+}
+
+// namedMeta reports whether a meta tag has a name or property, which leaves
+// out tags carrying nothing but their content.
+func namedMeta(attrs []html.Attribute) bool {
+	for _, attr := range attrs {
+		if (attr.Key == "name" || attr.Key == "property") && attr.Val != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// safeHeadLink reports whether a link tag has an allowed relation and an
+// https or relative URL.
+func safeHeadLink(attrs []html.Attribute) bool {
+	var rel, href string
+	for _, attr := range attrs {
+		switch attr.Key {
+		case "rel":
+			rel = strings.ToLower(strings.TrimSpace(attr.Val))
+		case "href":
+			href = strings.TrimSpace(attr.Val)
+		}
+	}
+	for _, r := range strings.Fields(rel) {
+		if !headLinkRels[r] {
+			return false
+		}
+	}
+	if rel == "" || href == "" {
+		return false
+	}
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "https" || (u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/"))
+}
+
+// textPolicy strips all markup from plain text fields
+var textPolicy = bluemonday.StrictPolicy()
+
+// maxUnescapes bounds the rounds of SanitizeText
+const maxUnescapes = 4
+
+// SanitizeText strips the HTML markup of a plain text field, such as a
+// comment or a bio, keeping its text. Unlike the output of bluemonday, the
+// text isn't left escaped, so characters such as & and ' are stored as
+// written. Since unescaping can turn escaped markup, e.g. &lt;script&gt;,
+// into markup, the text is stripped again until none is left, and left
+// escaped when that takes too many rounds.
+func SanitizeText(text string) string {
+	for i := 0; i < maxUnescapes; i++ {
+		sanitized := html.UnescapeString(textPolicy.Sanitize(text))
+		if sanitized == text {
+			return text
+		}
+		text = sanitized
+	}
+	return textPolicy.Sanitize(text)
+}
@@ -5,54 +5,17 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/SteaceP/coderage/jwtkeys"
+	"github.com/SteaceP/coderage/types"
+
 	"github.com/golang-jwt/jwt"
-	"github.com/spf13/viper"
 )
 
-// GenerateJWTToken generates a JSON Web Token (JWT) containing the given user ID.
-// The token's expiration time is configured using the "jwt.expiration" configuration
-// key. If the key is not set, the token will expire after 24 hours. The JWT secret
-// is configured using the "jwt.secret" key. If the key is not set, the function
-// returns an error.
-func GenerateJWTToken(userID uint) (string, error) {
-	// Get JWT secret from configuration
-	secret := viper.GetString("jwt.secret")
-	if secret == "" {
-		return "", fmt.Errorf("JWT secret is not configured")
-	}
-
-	// Get JWT expiration time from configuration
-	expiration := viper.GetInt("jwt.expiration")
-	if expiration == 0 {
-		// Use a default value if the configuration is missing or invalid
-		expiration = 24 * 60 * 60 // 24 hours in seconds
-	}
-
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Duration(expiration) * time.Second).Unix(),
-	})
-
-	// Sign and get the complete encoded token as a string
-	return token.SignedString([]byte(secret))
-}
-
+// ValidateJWTToken parses a token and verifies its signature, with the key
+// of its key ID or the "jwt.secret" key, and its expiration.
 func ValidateJWTToken(tokenString string) (*jwt.Token, error) {
-	// Get JWT secret from configuration
-	secret := viper.GetString("jwt.secret")
-	if secret == "" {
-		return nil, fmt.Errorf("JWT secret is not configured")
-	}
-
-	// Parse token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
+	// Parse token, verifying its signing method
+	token, err := jwt.Parse(tokenString, jwtkeys.Current().Keyfunc)
 
 	if err != nil {
 		return nil, err
@@ -72,34 +35,17 @@ func ValidateJWTToken(tokenString string) (*jwt.Token, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
-// RefreshJWTToken refreshes a given JWT, issuing a new one with an updated expiry.
-// It validates the old token first and extracts the user ID.
-func RefreshJWTToken(tokenString string) (string, error) {
-	// Validate the existing token
-	token, err := ValidateJWTToken(tokenString)
-	if err != nil {
-		return "", fmt.Errorf("invalid token: %w", err) // Wrap error for more context
+// TokenType returns the type of a token issued by the API, types.TokenAccess
+// or types.TokenRefresh. Tokens issued before the "typ" claim was added are
+// told apart by the "authorized" claim, which only access tokens carried.
+func TokenType(claims jwt.MapClaims) string {
+	if typ, ok := claims["typ"].(string); ok {
+		return typ
 	}
-
-	// Extract user ID from claims
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", fmt.Errorf("invalid token claims")
-	}
-
-	userIDF, ok := claims["user_id"].(float64)
-	if !ok {
-		return "", fmt.Errorf("invalid user ID in token")
+	if _, ok := claims["authorized"]; ok {
+		return types.TokenAccess
 	}
-	userID := uint(userIDF)
-
-	// Generate a new token with the same user ID
-	newToken, err := GenerateJWTToken(userID)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate new token: %w", err) // Wrap error
-	}
-
-	return newToken, nil
+	return types.TokenRefresh
 }
 
 // UintToString safely converts a uint to a string.
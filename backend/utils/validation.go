@@ -1,10 +1,11 @@
 package utils
 
 import (
-	"fmt"
 	"regexp"
 	"strings"
 
+	"github.com/SteaceP/coderage/i18n"
+
 	"github.com/go-playground/validator/v10"
 )
 
@@ -29,32 +30,36 @@ func init() {
 // one uppercase letter, one lowercase letter, one digit, and one special
 // character.
 //
-// The returned errors are human-readable and can be used to display the
-// validation errors to the user.
-func ValidateStruct(s interface{}) []string {
-	var errors []string
+// The returned errors are human-readable messages, in English through their
+// String method and translated to the language of the client through the
+// i18n package, and can be used to display the validation errors to the
+// user.
+func ValidateStruct(s interface{}) []i18n.Message {
+	var errors []i18n.Message
 
 	err := validate.Struct(s)
 	if err != nil {
 		if _, ok := err.(*validator.InvalidValidationError); ok {
-			return []string{"Invalid validation"}
+			return []i18n.Message{i18n.M("Invalid validation")}
 		}
 
 		for _, err := range err.(validator.ValidationErrors) {
-			var errorMessage string
+			var errorMessage i18n.Message
 			switch err.Tag() {
 			case "required":
-				errorMessage = fmt.Sprintf("%s is required", err.Field())
+				errorMessage = i18n.M("%s is required", err.Field())
 			case "email":
-				errorMessage = fmt.Sprintf("%s must be a valid email", err.Field())
+				errorMessage = i18n.M("%s must be a valid email", err.Field())
 			case "min":
-				errorMessage = fmt.Sprintf("%s must be at least %s characters", err.Field(), err.Param())
+				errorMessage = i18n.M("%s must be at least %s characters", err.Field(), err.Param())
+			case "url":
+				errorMessage = i18n.M("%s must be a valid URL", err.Field())
 			case "max":
-				errorMessage = fmt.Sprintf("%s must be at most %s characters", err.Field(), err.Param())
+				errorMessage = i18n.M("%s must be at most %s characters", err.Field(), err.Param())
 			case "strong_password":
-				errorMessage = fmt.Sprintf("%s does not meet password complexity requirements", err.Field())
+				errorMessage = i18n.M("%s does not meet password complexity requirements", err.Field())
 			default:
-				errorMessage = fmt.Sprintf("%s is invalid", err.Field())
+				errorMessage = i18n.M("%s is invalid", err.Field())
 			}
 			errors = append(errors, errorMessage)
 		}
@@ -99,6 +104,12 @@ func validateStrongPassword(fl validator.FieldLevel) bool {
 	return true
 }
 
+// IsStrongPassword reports whether a password meets the requirements of the
+// "strong_password" validation tag.
+func IsStrongPassword(password string) bool {
+	return validate.Var(password, "strong_password") == nil
+}
+
 // SanitizeInput removes any leading or trailing whitespace from a given string.
 // It is meant to be used when accepting user input to prevent any malicious
 // or accidental whitespace from causing issues in the application.
@@ -0,0 +1,163 @@
+// Package webhooks delivers events to the HTTP endpoints registered by
+// integrators, signing each payload with the secret of the endpoint.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/SteaceP/coderage/config"
+	"github.com/SteaceP/coderage/httpclient"
+)
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-Coderage-Event"
+	DeliveryHeader  = "X-Coderage-Delivery"
+	SignatureHeader = "X-Coderage-Signature-256"
+)
+
+// PingEvent is the type of the sample event sent by test deliveries
+const PingEvent = "ping"
+
+// SignatureAlgorithm describes how payloads are signed
+const SignatureAlgorithm = "HMAC-SHA256"
+
+// maxResponseBody is the number of bytes of the response body kept for the
+// delivery log
+const maxResponseBody = 64 << 10
+
+// Request is a delivery to send to an endpoint.
+type Request struct {
+	URL        string
+	Secret     string
+	EventType  string
+	DeliveryID string
+	Body       []byte
+}
+
+// Result describes what happened when a request was sent. Err is set when no
+// response was received.
+type Result struct {
+	RequestHeaders  map[string]string
+	StatusCode      int
+	ResponseHeaders map[string]string
+	ResponseBody    string
+	Duration        time.Duration
+	Err             error
+}
+
+// Sender sends deliveries to endpoints.
+type Sender interface {
+	Send(ctx context.Context, req Request) Result
+}
+
+// HTTPSender sends deliveries as HTTP POST requests.
+type HTTPSender struct {
+	client *http.Client
+}
+
+// NewSender returns a new instance of HTTPSender configured from the
+// "webhooks" configuration. Endpoints are reached through the guarded client
+// of the httpclient package, as their URLs are chosen by integrators.
+func NewSender() *HTTPSender {
+	return &HTTPSender{
+		client: httpclient.New(httpclient.Options{
+			Name:    "webhooks",
+			Timeout: config.Get().Webhooks.Timeout,
+		}),
+	}
+}
+
+// Send posts the request body to the endpoint and records the exchange.
+func (s *HTTPSender) Send(ctx context.Context, req Request) Result {
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"User-Agent":    "Coderage-Webhooks/1.0",
+		EventHeader:     req.EventType,
+		DeliveryHeader:  req.DeliveryID,
+		SignatureHeader: Sign(req.Secret, req.Body),
+	}
+	result := Result{RequestHeaders: headers}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	for name, value := range headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(httpReq)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	result.StatusCode = resp.StatusCode
+	result.ResponseBody = string(body)
+	result.ResponseHeaders = make(map[string]string, len(resp.Header))
+	for name := range resp.Header {
+		result.ResponseHeaders[name] = resp.Header.Get(name)
+	}
+	return result
+}
+
+// Sign returns the signature header value of a payload: the hex encoded
+// HMAC-SHA256 of the body keyed with the secret, prefixed with "sha256=".
+// Receivers recompute it over the raw request body to authenticate it.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether a signature header value was computed over the
+// payload with the secret, comparing them in constant time.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// PayloadDigest returns the hex encoded SHA-256 of a payload, which lets
+// integrators check they compute the signature over the exact bytes sent.
+func PayloadDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// SecretFingerprint identifies a secret without revealing it, so integrators
+// can check which secret signed a delivery.
+func SecretFingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Discard is a Sender that never contacts the endpoint, used for sandbox
+// requests. Deliveries are reported as accepted.
+var Discard Sender = discardSender{}
+
+type discardSender struct{}
+
+// Send reports the delivery as accepted without sending it.
+func (discardSender) Send(ctx context.Context, req Request) Result {
+	return Result{
+		RequestHeaders: map[string]string{
+			"Content-Type":  "application/json",
+			EventHeader:     req.EventType,
+			DeliveryHeader:  req.DeliveryID,
+			SignatureHeader: Sign(req.Secret, req.Body),
+		},
+		StatusCode: http.StatusAccepted,
+	}
+}